@@ -22,6 +22,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
@@ -77,7 +78,20 @@ type Label struct {
 	Previously []Label `json:"previously,omitempty"`
 	// DeleteAfter specifies the label is retired and a safe date for deletion
 	DeleteAfter *time.Time `json:"deleteAfter,omitempty"`
-	parent      *Label     // Current name for previous labels (used internally)
+	// Permissions restricts who may add or remove the label
+	Permissions *LabelPermissions `json:"permissions,omitempty"`
+	parent      *Label            // Current name for previous labels (used internally)
+}
+
+// LabelPermissions lists who may add or remove a label.
+// Entries are either GitHub logins or org/team-slug team references.
+// An empty list leaves that action unrestricted. The label-guard prow
+// plugin enforces these by reverting changes made by anyone else.
+type LabelPermissions struct {
+	// AddableBy lists the users and teams allowed to add the label
+	AddableBy []string `json:"addableBy,omitempty"`
+	// RemovableBy lists the users and teams allowed to remove the label
+	RemovableBy []string `json:"removableBy,omitempty"`
 }
 
 // Configuration is a list of Repos defining Required Labels to sync into them
@@ -107,6 +121,16 @@ type Update struct {
 // RepoUpdates Repositories to update: map repo name --> list of Updates
 type RepoUpdates map[string][]Update
 
+// AuditFinding lists the issues and PRs carrying a label missing from the config
+type AuditFinding struct {
+	Label        string `json:"label"`
+	Issues       []int  `json:"issues,omitempty"`
+	PullRequests []int  `json:"pullRequests,omitempty"`
+}
+
+// RepoAudit Repositories audited: map repo name --> list of AuditFindings
+type RepoAudit map[string][]AuditFinding
+
 const (
 	defaultTokens = 300
 	defaultBurst  = 100
@@ -144,7 +168,7 @@ func gatherOptions() options {
 	fs.StringVar(&o.orgs, "orgs", "", "Comma separated list of orgs to sync")
 	fs.StringVar(&o.skipRepos, "skip", "", "Comma separated list of org/repos to skip syncing")
 	fs.StringVar(&o.token, "token", "", "Path to github oauth secret")
-	fs.StringVar(&o.action, "action", "sync", "One of: sync, docs")
+	fs.StringVar(&o.action, "action", "sync", "One of: sync, audit, docs, css")
 	fs.StringVar(&o.cssTemplate, "css-template", "", "Path to template file for label css")
 	fs.StringVar(&o.cssOutput, "css-output", "", "Path to output file for css")
 	fs.StringVar(&o.docsTemplate, "docs-template", "", "Path to template file for label docs")
@@ -200,7 +224,8 @@ func writeTemplate(templatePath string, outputPath string, data interface{}) err
 
 // validate runs checks to ensure the label inputs are valid
 // It ensures that no two label names (including previous names) have the same
-// lowercase value, and that the description is not over 100 characters.
+// lowercase value, that the description is not over 100 characters and that
+// permissions are only set on current labels and name valid users or teams.
 func validate(labels []Label, parent string, seen map[string]string) (map[string]string, error) {
 	newSeen := copyStringMap(seen)
 	for _, l := range labels {
@@ -216,10 +241,37 @@ func validate(labels []Label, parent string, seen map[string]string) (map[string
 		if len(l.Description) > 100 { // github limits the description field to 100 chars
 			return newSeen, fmt.Errorf("description for %s is too long", name)
 		}
+		for _, previous := range l.Previously {
+			if previous.Permissions != nil {
+				return newSeen, fmt.Errorf("previous label %s of %s cannot set permissions", previous.Name, name)
+			}
+		}
+		if err := validatePermissions(l.Permissions); err != nil {
+			return newSeen, fmt.Errorf("invalid permissions for %s: %v", name, err)
+		}
 	}
 	return newSeen, nil
 }
 
+// validatePermissions ensures every entry is a login or an org/team-slug reference
+func validatePermissions(p *LabelPermissions) error {
+	if p == nil {
+		return nil
+	}
+	for _, entry := range append(append([]string{}, p.AddableBy...), p.RemovableBy...) {
+		parts := strings.Split(entry, "/")
+		if len(parts) > 2 {
+			return fmt.Errorf("%q is neither a login nor an org/team", entry)
+		}
+		for _, part := range parts {
+			if strings.TrimSpace(part) == "" {
+				return fmt.Errorf("%q is neither a login nor an org/team", entry)
+			}
+		}
+	}
+	return nil
+}
+
 func copyStringMap(originalMap map[string]string) map[string]string {
 	newMap := make(map[string]string)
 	for k, v := range originalMap {
@@ -486,28 +538,36 @@ func copyLabelMap(originalMap map[string]Label) map[string]Label {
 	return newMap
 }
 
-func syncLabels(config Configuration, org string, repos RepoLabels) (RepoUpdates, error) {
-	// Find required, dead and archaic labels
-	defaultRequired, defaultArchaic, defaultDead := classifyLabels(config.Default.Labels, make(map[string]Label), make(map[string]Label), make(map[string]Label), time.Now(), nil)
+// classifyOrgLabels returns the required, archaic and dead labels shared by all repos in the org
+func classifyOrgLabels(config Configuration, org string) (map[string]Label, map[string]Label, map[string]Label) {
+	required, archaic, dead := classifyLabels(config.Default.Labels, make(map[string]Label), make(map[string]Label), make(map[string]Label), time.Now(), nil)
 	if orgLabels, ok := config.Orgs[org]; ok {
-		defaultRequired, defaultArchaic, defaultDead = classifyLabels(orgLabels.Labels, defaultRequired, defaultArchaic, defaultDead, time.Now(), nil)
+		required, archaic, dead = classifyLabels(orgLabels.Labels, required, archaic, dead, time.Now(), nil)
+	}
+	return required, archaic, dead
+}
+
+// classifyRepoLabels adds the labels configured for org/repo to the org ones
+func classifyRepoLabels(config Configuration, org, repo string, defaultRequired, defaultArchaic, defaultDead map[string]Label) (map[string]Label, map[string]Label, map[string]Label) {
+	// Check if we have more labels for repo
+	if repoconfig, ok := config.Repos[org+"/"+repo]; ok {
+		// Use classifyLabels() to add them to default ones
+		return classifyLabels(repoconfig.Labels, defaultRequired, defaultArchaic, defaultDead, time.Now(), nil)
 	}
+	// Otherwise just copy the pointers
+	return defaultRequired, defaultArchaic, defaultDead
+}
+
+func syncLabels(config Configuration, org string, repos RepoLabels) (RepoUpdates, error) {
+	// Find required, dead and archaic labels
+	defaultRequired, defaultArchaic, defaultDead := classifyOrgLabels(config, org)
 
 	var validationErrors []error
 	var actions []Update
 	// Process all repos
 	for repo, repoLabels := range repos {
-		var required, archaic, dead map[string]Label
-		// Check if we have more labels for repo
-		if repoconfig, ok := config.Repos[org+"/"+repo]; ok {
-			// Use classifyLabels() to add them to default ones
-			required, archaic, dead = classifyLabels(repoconfig.Labels, defaultRequired, defaultArchaic, defaultDead, time.Now(), nil)
-		} else {
-			// Otherwise just copy the pointers
-			required = defaultRequired // Must exist
-			archaic = defaultArchaic   // Migrate
-			dead = defaultDead         // Delete
-		}
+		// required labels must exist, archaic ones are migrated and dead ones deleted
+		required, archaic, dead := classifyRepoLabels(config, org, repo, defaultRequired, defaultArchaic, defaultDead)
 		// Convert github.Label to Label
 		var labels []Label
 		for _, l := range repoLabels {
@@ -582,6 +642,52 @@ func syncLabels(config Configuration, org string, repos RepoLabels) (RepoUpdates
 	return u, overallErr
 }
 
+// auditLabels finds the issues and PRs carrying a label that the config
+// neither requires, migrates nor deletes.
+func auditLabels(config Configuration, org string, repos RepoLabels, gc client) (RepoAudit, error) {
+	defaultRequired, defaultArchaic, defaultDead := classifyOrgLabels(config, org)
+
+	var searchErrors []error
+	audit := RepoAudit{}
+	for repo, repoLabels := range repos {
+		required, archaic, dead := classifyRepoLabels(config, org, repo, defaultRequired, defaultArchaic, defaultDead)
+		for _, l := range repoLabels {
+			lower := strings.ToLower(l.Name)
+			if _, found := required[lower]; found {
+				continue
+			}
+			if _, found := archaic[lower]; found {
+				continue
+			}
+			if _, found := dead[lower]; found {
+				continue
+			}
+			logrus.WithField("repo", repo).WithField("label", l.Name).Info("unknown")
+			issues, err := gc.FindIssues(fmt.Sprintf("repo:%s/%s label:\"%s\"", org, repo, l.Name), "", false)
+			if err != nil {
+				searchErrors = append(searchErrors, err)
+				continue
+			}
+			finding := AuditFinding{Label: l.Name}
+			for _, i := range issues {
+				if i.IsPullRequest() {
+					finding.PullRequests = append(finding.PullRequests, i.Number)
+				} else {
+					finding.Issues = append(finding.Issues, i.Number)
+				}
+			}
+			audit[repo] = append(audit[repo], finding)
+		}
+		sort.Slice(audit[repo], func(i, j int) bool { return audit[repo][i].Label < audit[repo][j].Label })
+	}
+
+	var overallErr error
+	if len(searchErrors) > 0 {
+		overallErr = fmt.Errorf("failed to search issues: %v", searchErrors)
+	}
+	return audit, overallErr
+}
+
 type repoUpdate struct {
 	repo   string
 	update Update
@@ -632,24 +738,9 @@ func (ru RepoUpdates) DoUpdates(org string, gc client) error {
 						errChan <- err
 					}
 				case "migrate":
-					issues, err := gc.FindIssues(fmt.Sprintf("is:open repo:%s/%s label:\"%s\" -label:\"%s\"", org, repo, update.Current.Name, update.Wanted.Name), "", false)
-					if err != nil {
+					if err := migrateLabel(gc, org, repo, *update.Current, *update.Wanted); err != nil {
 						errChan <- err
 					}
-					if len(issues) == 0 {
-						if err = gc.DeleteRepoLabel(org, repo, update.Current.Name); err != nil {
-							errChan <- err
-						}
-					}
-					for _, i := range issues {
-						if err = gc.AddLabel(org, repo, i.Number, update.Wanted.Name); err != nil {
-							errChan <- err
-							continue
-						}
-						if err = gc.RemoveLabel(org, repo, i.Number, update.Current.Name); err != nil {
-							errChan <- err
-						}
-					}
 				default:
 					errChan <- errors.New("unknown label operation: " + update.Why)
 				}
@@ -672,6 +763,36 @@ func (ru RepoUpdates) DoUpdates(org string, gc client) error {
 	return overallErr
 }
 
+// migrateLabel moves every issue and PR, open or closed, from the previous
+// label to the wanted one. As search returns at most 1000 issues, the
+// previous label is only deleted once a search finds no issues with it;
+// until then, every run migrates the next batch.
+func migrateLabel(gc client, org, repo string, previous, wanted Label) error {
+	issues, err := gc.FindIssues(fmt.Sprintf("repo:%s/%s label:\"%s\"", org, repo, previous.Name), "", false)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return gc.DeleteRepoLabel(org, repo, previous.Name)
+	}
+	var migrateErrs []error
+	for _, i := range issues {
+		if !github.HasLabel(wanted.Name, i.Labels) {
+			if err := gc.AddLabel(org, repo, i.Number, wanted.Name); err != nil {
+				migrateErrs = append(migrateErrs, err)
+				continue
+			}
+		}
+		if err := gc.RemoveLabel(org, repo, i.Number, previous.Name); err != nil {
+			migrateErrs = append(migrateErrs, err)
+		}
+	}
+	if len(migrateErrs) > 0 {
+		return fmt.Errorf("failed to migrate %s to %s: %v", previous.Name, wanted.Name, migrateErrs)
+	}
+	return nil
+}
+
 type client interface {
 	AddRepoLabel(org, repo, name, description, color string) error
 	UpdateRepoLabel(org, repo, currentName, newName, description, color string) error
@@ -744,12 +865,21 @@ func main() {
 		if err := writeCSS(o.cssTemplate, o.cssOutput, *config); err != nil {
 			logrus.WithError(err).Fatalf("failed to write css file using css-template %s to css-output %s", o.cssTemplate, o.cssOutput)
 		}
-	case o.action == "sync":
+	case o.action == "sync" || o.action == "audit":
 		githubClient, err := newClient(o.token, o.tokens, o.tokenBurst, !o.confirm, o.graphqlEndpoint, o.endpoint.Strings()...)
 		if err != nil {
 			logrus.WithError(err).Fatal("failed to create client")
 		}
 
+		run := func(org string, repos []string) error {
+			return syncOrg(org, githubClient, *config, repos, o.confirm)
+		}
+		if o.action == "audit" {
+			run = func(org string, repos []string) error {
+				return auditOrg(org, githubClient, *config, repos, os.Stdout)
+			}
+		}
+
 		// there are three ways to configure which repos to sync:
 		//  - a list of org/repo values
 		//  - a list of orgs for which we sync all repos
@@ -760,8 +890,8 @@ func main() {
 				logrus.WithError(err).Fatal("invalid value for --only")
 			}
 			for org := range reposToSync {
-				if err = syncOrg(org, githubClient, *config, reposToSync[org], o.confirm); err != nil {
-					logrus.WithError(err).Fatalf("failed to update %s", org)
+				if err = run(org, reposToSync[org]); err != nil {
+					logrus.WithError(err).Fatalf("failed to %s %s", o.action, org)
 				}
 			}
 			return
@@ -787,8 +917,8 @@ func main() {
 			if skipped, exist := skippedRepos[org]; exist {
 				repos = sets.NewString(repos...).Difference(sets.NewString(skipped...)).UnsortedList()
 			}
-			if err = syncOrg(org, githubClient, *config, repos, o.confirm); err != nil {
-				logrus.WithError(err).Fatalf("failed to update %s", org)
+			if err = run(org, repos); err != nil {
+				logrus.WithError(err).Fatalf("failed to %s %s", o.action, org)
 			}
 		}
 	default:
@@ -921,6 +1051,30 @@ func syncOrg(org string, githubClient client, config Configuration, repos []stri
 	return nil
 }
 
+// auditOrg writes a yaml report of the issues and PRs in the org's repos that
+// carry labels missing from the config
+func auditOrg(org string, githubClient client, config Configuration, repos []string, out io.Writer) error {
+	logger := logrus.WithField("org", org)
+	logger.Infof("Found %d repos", len(repos))
+	currLabels, err := loadLabels(githubClient, org, repos)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Auditing labels for %d repos", len(repos))
+	audit, err := auditLabels(config, org, *currLabels, githubClient)
+	if err != nil {
+		return err
+	}
+
+	y, err := yaml.Marshal(map[string]RepoAudit{org: audit})
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(y)
+	return err
+}
+
 type labelCSSData struct {
 	BackgroundColor, Color, Name string
 }
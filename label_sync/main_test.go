@@ -18,12 +18,15 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"k8s.io/test-infra/prow/github"
 )
 
 // Tests for getting data from GitHub are not needed:
@@ -114,6 +117,33 @@ func TestValidate(t *testing.T) {
 			},
 			expectedError: true,
 		},
+		{
+			name: "Permissions with users and teams",
+			config: Configuration{Default: RepoConfig{Labels: []Label{
+				{Name: "lgtm", Description: "Test Label 1", Color: "deadbe", Permissions: &LabelPermissions{
+					AddableBy:   []string{"bot"},
+					RemovableBy: []string{"bot", "org/leads"},
+				}},
+			}}},
+		},
+		{
+			name: "Permissions with an invalid entry",
+			config: Configuration{Default: RepoConfig{Labels: []Label{
+				{Name: "lgtm", Description: "Test Label 1", Color: "deadbe", Permissions: &LabelPermissions{
+					AddableBy: []string{"org/team/extra"},
+				}},
+			}}},
+			expectedError: true,
+		},
+		{
+			name: "Permissions on a previous label",
+			config: Configuration{Default: RepoConfig{Labels: []Label{
+				{Name: "lgtm", Description: "Test Label 1", Color: "deadbe", Previously: []Label{
+					{Name: "looks-good", Permissions: &LabelPermissions{AddableBy: []string{"bot"}}},
+				}},
+			}}},
+			expectedError: true,
+		},
 	}
 	// Do tests
 	for _, tc := range testcases {
@@ -519,3 +549,105 @@ func TestLoadYAML(t *testing.T) {
 		}
 	}
 }
+
+type fakeClient struct {
+	client
+	issues  map[string][]github.Issue
+	queries []string
+	added   []string
+	removed []string
+	deleted []string
+}
+
+func (f *fakeClient) FindIssues(query, order string, ascending bool) ([]github.Issue, error) {
+	f.queries = append(f.queries, query)
+	for label, issues := range f.issues {
+		if strings.Contains(query, fmt.Sprintf("label:%q", label)) {
+			return issues, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeClient) AddLabel(org, repo string, number int, label string) error {
+	f.added = append(f.added, fmt.Sprintf("%s/%s#%d:%s", org, repo, number, label))
+	return nil
+}
+
+func (f *fakeClient) RemoveLabel(org, repo string, number int, label string) error {
+	f.removed = append(f.removed, fmt.Sprintf("%s/%s#%d:%s", org, repo, number, label))
+	return nil
+}
+
+func (f *fakeClient) DeleteRepoLabel(org, repo, label string) error {
+	f.deleted = append(f.deleted, fmt.Sprintf("%s/%s:%s", org, repo, label))
+	return nil
+}
+
+func TestAuditLabels(t *testing.T) {
+	config := Configuration{
+		Default: RepoConfig{Labels: []Label{
+			{Name: "lgtm", Color: "green", Previously: []Label{{Name: "looks-good", Color: "green"}}},
+		}},
+		Repos: map[string]RepoConfig{
+			"org/repo1": {Labels: []Label{{Name: "repo-only", Color: "blue"}}},
+		},
+	}
+	repos := RepoLabels{
+		"repo1": {{Name: "LGTM"}, {Name: "looks-good"}, {Name: "repo-only"}, {Name: "stray"}},
+		"repo2": {{Name: "lgtm"}, {Name: "repo-only"}},
+	}
+	gc := &fakeClient{issues: map[string][]github.Issue{
+		"stray":     {{Number: 1}, {Number: 2, PullRequest: &struct{}{}}},
+		"repo-only": {{Number: 3}},
+	}}
+
+	audit, err := auditLabels(config, "org", repos, gc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := RepoAudit{
+		"repo1": {{Label: "stray", Issues: []int{1}, PullRequests: []int{2}}},
+		"repo2": {{Label: "repo-only", Issues: []int{3}}},
+	}
+	if diff := cmp.Diff(expected, audit, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("unexpected audit (-want +got):\n%s", diff)
+	}
+}
+
+func TestMigrateLabel(t *testing.T) {
+	gc := &fakeClient{issues: map[string][]github.Issue{
+		"old": {
+			{Number: 1, State: "closed", Labels: []github.Label{{Name: "old"}}},
+			{Number: 2, Labels: []github.Label{{Name: "old"}, {Name: "new"}}},
+		},
+	}}
+	if err := migrateLabel(gc, "org", "repo", Label{Name: "old"}, Label{Name: "new"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{`repo:org/repo label:"old"`}, gc.queries); diff != "" {
+		t.Errorf("unexpected queries (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"org/repo#1:new"}, gc.added); diff != "" {
+		t.Errorf("unexpected added labels (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"org/repo#1:old", "org/repo#2:old"}, gc.removed); diff != "" {
+		t.Errorf("unexpected removed labels (-want +got):\n%s", diff)
+	}
+	if len(gc.deleted) != 0 {
+		t.Errorf("expected the label to be kept until a search finds no issues with it, got deleted labels %v", gc.deleted)
+	}
+}
+
+func TestMigrateLabelWithoutIssues(t *testing.T) {
+	gc := &fakeClient{}
+	if err := migrateLabel(gc, "org", "repo", Label{Name: "old"}, Label{Name: "new"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gc.added) != 0 || len(gc.removed) != 0 {
+		t.Errorf("expected no issues to be relabeled, got added %v and removed %v", gc.added, gc.removed)
+	}
+	if diff := cmp.Diff([]string{"org/repo:old"}, gc.deleted); diff != "" {
+		t.Errorf("unexpected deleted labels (-want +got):\n%s", diff)
+	}
+}
@@ -120,6 +120,16 @@ type ProwJobSpec struct {
 	// to run the job, only applicable for that
 	// specific agent
 	Cluster string `json:"cluster,omitempty"`
+	// Clusters lists candidate build clusters for the
+	// job. When set, plank picks one of them when the
+	// job starts and may move the job to another one
+	// if its pod cannot be scheduled. Cluster is then
+	// ignored.
+	Clusters []string `json:"clusters,omitempty"`
+	// ClusterGroup names a group of candidate build
+	// clusters configured in plank.cluster_groups and
+	// behaves like Clusters otherwise.
+	ClusterGroup string `json:"cluster_group,omitempty"`
 	// Namespace defines where to create pods/resources.
 	Namespace string `json:"namespace,omitempty"`
 	// Job is the name of the job
@@ -176,6 +186,24 @@ type ProwJobSpec struct {
 	// Presubmits and Postsubmits can also be set to hidden by
 	// adding their repository in Decks `hidden_repo` setting.
 	Hidden bool `json:"hidden,omitempty"`
+
+	// Upstream records the event that triggered a periodic with
+	// `triggered_by` configured, e.g. the ProwJob it was chained to.
+	Upstream *Upstream `json:"upstream,omitempty"`
+}
+
+// Upstream is the event that triggered a job. Either ProwJob or
+// GCSObject is set.
+type Upstream struct {
+	// ProwJob is the name of the ProwJob whose completion triggered the job.
+	ProwJob string `json:"prowjob,omitempty"`
+	// Job is the name of the job of the upstream ProwJob.
+	Job string `json:"job,omitempty"`
+	// GCSObject is the gs://bucket/path of the object whose change
+	// triggered the job.
+	GCSObject string `json:"gcs_object,omitempty"`
+	// Generation is the generation of the GCS object that triggered the job.
+	Generation int64 `json:"generation,omitempty"`
 }
 
 type GitHubTeamSlug struct {
@@ -367,6 +395,49 @@ type DecorationConfig struct {
 	// OauthTokenSecret is a Kubernetes secret that contains the OAuth token,
 	// which is going to be used for fetching a private repository.
 	OauthTokenSecret *OauthTokenSecret `json:"oauth_token_secret,omitempty"`
+	// GitCache is a volume of bare git mirrors that clonerefs
+	// borrows objects from instead of fetching them again.
+	GitCache *GitCache `json:"git_cache,omitempty"`
+	// ResourceSampleInterval enables profiling of test containers.
+	// When set, entrypoint samples the CPU and memory usage of the
+	// container at this interval and sidecar uploads the samples.
+	ResourceSampleInterval *Duration `json:"resource_sample_interval,omitempty"`
+	// GracefulAbortTimeout enables graceful aborts. When set, plank asks
+	// the pod of an aborted or timed out job to terminate the test process
+	// and upload the artifacts, and only deletes the pod once sidecar is
+	// done or this timeout passed. The kubelet can take a minute to tell
+	// the pod, so this should exceed GracePeriod by a few minutes.
+	GracefulAbortTimeout *Duration `json:"graceful_abort_timeout,omitempty"`
+	// Steps split the job into ordered, named steps that replace the
+	// command of its only container. Entrypoint runs them one after the
+	// other and records how long each took in finished.json.
+	Steps []Step `json:"steps,omitempty"`
+}
+
+// Step is a named phase of a multi-step job, e.g. setup or teardown.
+type Step struct {
+	// Name identifies the step in the results of the job.
+	Name string `json:"name"`
+	// Command is the process to run and its arguments.
+	Command []string `json:"command"`
+	// Timeout is how long the step may run. The step never runs past
+	// the timeout of the job unless AlwaysRun is set.
+	Timeout *Duration `json:"timeout,omitempty"`
+	// AlwaysRun runs the step even if an earlier step failed or the
+	// job timed out, which is what teardown steps need. No step runs
+	// once the job was interrupted or aborted.
+	AlwaysRun bool `json:"always_run,omitempty"`
+}
+
+// GitCache holds the location of a shared cache of bare git mirrors,
+// laid out as <host>/<org>/<repo>.git. Exactly one source must be set.
+type GitCache struct {
+	// HostPath is a directory on the node holding the mirrors,
+	// usually kept fresh by the git-cache-updater DaemonSet.
+	HostPath string `json:"host_path,omitempty"`
+	// PVC is the name of a PersistentVolumeClaim holding the
+	// mirrors. It is mounted read-only.
+	PVC string `json:"pvc,omitempty"`
 }
 
 // Resources holds resource requests and limits for
@@ -461,6 +532,18 @@ func (d *DecorationConfig) ApplyDefault(def *DecorationConfig) *DecorationConfig
 	if merged.OauthTokenSecret == nil {
 		merged.OauthTokenSecret = def.OauthTokenSecret
 	}
+	if merged.GitCache == nil {
+		merged.GitCache = def.GitCache
+	}
+	if merged.ResourceSampleInterval == nil {
+		merged.ResourceSampleInterval = def.ResourceSampleInterval
+	}
+	if merged.GracefulAbortTimeout == nil {
+		merged.GracefulAbortTimeout = def.GracefulAbortTimeout
+	}
+	if merged.Steps == nil {
+		merged.Steps = def.Steps
+	}
 
 	return &merged
 }
@@ -500,6 +583,22 @@ func (d *DecorationConfig) Validate() error {
 	if d.OauthTokenSecret != nil && len(d.SSHKeySecrets) > 0 {
 		return errors.New("both OAuth token and SSH key secrets are specified")
 	}
+	if c := d.GitCache; c != nil && (c.HostPath == "") == (c.PVC == "") {
+		return errors.New("git cache must specify exactly one of host_path or pvc")
+	}
+	names := map[string]bool{}
+	for i, step := range d.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("step %d has no name", i)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("step %q is defined more than once", step.Name)
+		}
+		names[step.Name] = true
+		if len(step.Command) == 0 || step.Command[0] == "" {
+			return fmt.Errorf("step %q has no command", step.Name)
+		}
+	}
 	return nil
 }
 
@@ -722,6 +821,15 @@ type ProwJobStatus struct {
 	// PrevReportStates stores the previous reported prowjob state per reporter
 	// So crier won't make duplicated report attempt
 	PrevReportStates map[string]ProwJobState `json:"prev_report_states,omitempty"`
+
+	// Cluster is the build cluster plank picked for a
+	// job that lists candidate clusters.
+	Cluster string `json:"cluster,omitempty"`
+
+	// RescheduledFrom lists the build clusters in which
+	// the pod of this job could not be scheduled, in the
+	// order plank moved the job away from them.
+	RescheduledFrom []string `json:"rescheduled_from,omitempty"`
 }
 
 // Complete returns true if the prow job has finished
@@ -739,7 +847,11 @@ func (j *ProwJob) SetComplete() {
 // ClusterAlias specifies the key in the clusters map to use.
 //
 // This allows scheduling a prow job somewhere aside from the default build cluster.
+// Jobs with candidate clusters use the cluster plank picked for them.
 func (j *ProwJob) ClusterAlias() string {
+	if j.Status.Cluster != "" {
+		return j.Status.Cluster
+	}
 	if j.Spec.Cluster == "" {
 		return DefaultClusterAlias
 	}
@@ -807,6 +919,14 @@ type Refs struct {
 	// Multiheaded repos may need to not make this call.
 	// The git fetch <remote> <BaseRef> call occurs regardless.
 	SkipFetchHead bool `json:"skip_fetch_head,omitempty"`
+	// SparseCheckout lists the directories to check out, using
+	// cone mode sparse checkout. Files at the root of the repository
+	// are always checked out. If empty, the whole tree is checked out.
+	SparseCheckout []string `json:"sparse_checkout,omitempty"`
+	// CloneFilter is a partial clone filter such as `blob:none`
+	// or `tree:0`. Objects filtered out are fetched lazily when
+	// git needs them, e.g. when merging pull requests.
+	CloneFilter string `json:"clone_filter,omitempty"`
 }
 
 func (r Refs) String() string {
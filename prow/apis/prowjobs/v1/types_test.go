@@ -205,6 +205,46 @@ func TestDecorationDefaultingDoesntOverwrite(t *testing.T) {
 				return def
 			},
 		},
+		{
+			name: "git cache provided",
+			provided: &DecorationConfig{
+				GitCache: &GitCache{PVC: "mirrors"},
+			},
+			expected: func(orig, def *DecorationConfig) *DecorationConfig {
+				def.GitCache = orig.GitCache
+				return def
+			},
+		},
+		{
+			name: "resource sample interval provided",
+			provided: &DecorationConfig{
+				ResourceSampleInterval: &Duration{Duration: 5 * time.Second},
+			},
+			expected: func(orig, def *DecorationConfig) *DecorationConfig {
+				def.ResourceSampleInterval = orig.ResourceSampleInterval
+				return def
+			},
+		},
+		{
+			name: "graceful abort timeout provided",
+			provided: &DecorationConfig{
+				GracefulAbortTimeout: &Duration{Duration: 10 * time.Minute},
+			},
+			expected: func(orig, def *DecorationConfig) *DecorationConfig {
+				def.GracefulAbortTimeout = orig.GracefulAbortTimeout
+				return def
+			},
+		},
+		{
+			name: "steps provided",
+			provided: &DecorationConfig{
+				Steps: []Step{{Name: "test", Command: []string{"make", "test"}}},
+			},
+			expected: func(orig, def *DecorationConfig) *DecorationConfig {
+				def.Steps = orig.Steps
+				return def
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -227,11 +267,15 @@ func TestDecorationDefaultingDoesntOverwrite(t *testing.T) {
 					DefaultOrg:   "org",
 					DefaultRepo:  "repo",
 				},
-				GCSCredentialsSecret: pStr("secretName"),
-				S3CredentialsSecret:  pStr("s3-secret"),
-				SSHKeySecrets:        []string{"first", "second"},
-				SSHHostFingerprints:  []string{"primero", "segundo"},
-				SkipCloning:          &truth,
+				GCSCredentialsSecret:   pStr("secretName"),
+				S3CredentialsSecret:    pStr("s3-secret"),
+				SSHKeySecrets:          []string{"first", "second"},
+				SSHHostFingerprints:    []string{"primero", "segundo"},
+				SkipCloning:            &truth,
+				GitCache:               &GitCache{HostPath: "/var/lib/git-cache"},
+				ResourceSampleInterval: &Duration{Duration: 30 * time.Second},
+				GracefulAbortTimeout:   &Duration{Duration: 5 * time.Minute},
+				Steps:                  []Step{{Name: "build", Command: []string{"make"}}},
 			}
 
 			expected := tc.expected(tc.provided, defaults)
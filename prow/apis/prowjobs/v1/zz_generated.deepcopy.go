@@ -91,6 +91,28 @@ func (in *DecorationConfig) DeepCopyInto(out *DecorationConfig) {
 		*out = new(OauthTokenSecret)
 		**out = **in
 	}
+	if in.GitCache != nil {
+		in, out := &in.GitCache, &out.GitCache
+		*out = new(GitCache)
+		**out = **in
+	}
+	if in.ResourceSampleInterval != nil {
+		in, out := &in.ResourceSampleInterval, &out.ResourceSampleInterval
+		*out = new(Duration)
+		**out = **in
+	}
+	if in.GracefulAbortTimeout != nil {
+		in, out := &in.GracefulAbortTimeout, &out.GracefulAbortTimeout
+		*out = new(Duration)
+		**out = **in
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]Step, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -143,6 +165,22 @@ func (in *GCSConfiguration) DeepCopy() *GCSConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitCache) DeepCopyInto(out *GitCache) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitCache.
+func (in *GitCache) DeepCopy() *GitCache {
+	if in == nil {
+		return nil
+	}
+	out := new(GitCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitHubTeamSlug) DeepCopyInto(out *GitHubTeamSlug) {
 	*out = *in
@@ -255,6 +293,11 @@ func (in *ProwJobList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProwJobSpec) DeepCopyInto(out *ProwJobSpec) {
 	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Refs != nil {
 		in, out := &in.Refs, &out.Refs
 		*out = new(Refs)
@@ -297,6 +340,11 @@ func (in *ProwJobSpec) DeepCopyInto(out *ProwJobSpec) {
 		*out = new(RerunAuthConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Upstream != nil {
+		in, out := &in.Upstream, &out.Upstream
+		*out = new(Upstream)
+		**out = **in
+	}
 	return
 }
 
@@ -329,6 +377,11 @@ func (in *ProwJobStatus) DeepCopyInto(out *ProwJobStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.RescheduledFrom != nil {
+		in, out := &in.RescheduledFrom, &out.RescheduledFrom
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -387,6 +440,11 @@ func (in *Refs) DeepCopyInto(out *Refs) {
 		*out = make([]Pull, len(*in))
 		copy(*out, *in)
 	}
+	if in.SparseCheckout != nil {
+		in, out := &in.SparseCheckout, &out.SparseCheckout
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -514,6 +572,48 @@ func (in *SlackReporterConfig) DeepCopy() *SlackReporterConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Upstream) DeepCopyInto(out *Upstream) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Upstream.
+func (in *Upstream) DeepCopy() *Upstream {
+	if in == nil {
+		return nil
+	}
+	out := new(Upstream)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Step) DeepCopyInto(out *Step) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Step.
+func (in *Step) DeepCopy() *Step {
+	if in == nil {
+		return nil
+	}
+	out := new(Step)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UtilityImages) DeepCopyInto(out *UtilityImages) {
 	*out = *in
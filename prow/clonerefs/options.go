@@ -59,6 +59,11 @@ type Options struct {
 	// when cloning. Will be added to ~/.ssh/known_hosts
 	HostFingerprints []string `json:"host_fingerprints,omitempty"`
 
+	// ReferenceCache is the root of a directory of bare mirrors,
+	// laid out as <host>/<org>/<repo>.git, that clones borrow
+	// objects from when a mirror for the repository is present.
+	ReferenceCache string `json:"reference_cache,omitempty"`
+
 	// MaxParallelWorkers determines how many repositories
 	// can be cloned in parallel. If 0, interpreted as no
 	// limit to parallelism
@@ -158,6 +163,7 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 	fs.Var(&o.cloneURI, "uri-prefix", "Format string for the URI prefix to clone from")
 	fs.IntVar(&o.MaxParallelWorkers, "max-workers", 0, "Maximum number of parallel workers, unset for unlimited.")
 	fs.StringVar(&o.CookiePath, "cookiefile", "", "Path to git http.cookiefile")
+	fs.StringVar(&o.ReferenceCache, "reference-cache", "", "Path to a directory of bare mirrors to borrow objects from")
 	fs.BoolVar(&o.Fail, "fail", false, "Exit with failure if any of the refs can't be fetched.")
 }
 
@@ -57,12 +57,13 @@ func TestRun(t *testing.T) {
 		cookiePath  string
 		env         []string
 		oauthToken  string
+		cache       string
 	}
 
 	var recordedClones []cloneRec
 	var lock sync.Mutex
 	cloneFuncOld := cloneFunc
-	cloneFunc = func(refs prowapi.Refs, root, user, email, cookiePath string, env []string, oauthToken string) clone.Record {
+	cloneFunc = func(refs prowapi.Refs, root, user, email, cookiePath string, env []string, oauthToken, cache string) clone.Record {
 		lock.Lock()
 		defer lock.Unlock()
 		recordedClones = append(recordedClones, cloneRec{
@@ -73,6 +74,7 @@ func TestRun(t *testing.T) {
 			cookiePath: cookiePath,
 			env:        env,
 			oauthToken: oauthToken,
+			cache:      cache,
 		})
 		return clone.Record{}
 	}
@@ -227,6 +229,36 @@ func TestRun(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "clone with reference cache",
+			opts: Options{
+				SrcRoot:        srcRoot,
+				Log:            path.Join(srcRoot, "log.txt"),
+				GitUserName:    "me",
+				GitUserEmail:   "me@domain.com",
+				ReferenceCache: "/git-cache",
+				GitRefs: []prowapi.Refs{
+					{
+						Org:     "kubernetes",
+						Repo:    "test-infra",
+						BaseRef: "master",
+					},
+				},
+			},
+			expectedClones: []cloneRec{
+				{
+					refs: prowapi.Refs{
+						Org:     "kubernetes",
+						Repo:    "test-infra",
+						BaseRef: "master",
+					},
+					root:  srcRoot,
+					user:  "me",
+					email: "me@domain.com",
+					cache: "/git-cache",
+				},
+			},
+		},
 	}
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -0,0 +1,309 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// artifact-retention deletes the artifacts of old runs from the buckets
+// jobs upload to, according to the artifact_retention policies.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/pkg/flagutil"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/retention"
+)
+
+var runsCleaned = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "artifact_retention_runs",
+	Help: "Number of runs the artifact retention controller looked at, by result.",
+}, []string{"job_type", "result"})
+
+func init() {
+	prometheus.MustRegister(runsCleaned)
+}
+
+type options struct {
+	runOnce                bool
+	configPath             string
+	jobConfigPath          string
+	dryRun                 bool
+	storage                prowflagutil.StorageClientOptions
+	instrumentationOptions prowflagutil.InstrumentationOptions
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{}
+	fs.BoolVar(&o.runOnce, "run-once", false, "If true, run only once then quit.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether or not to delete artifacts.")
+	for _, group := range []flagutil.OptionGroup{&o.storage, &o.instrumentationOptions} {
+		group.AddFlags(fs)
+	}
+	fs.Parse(args)
+	return o
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("--config-path is required")
+	}
+	return o.storage.Validate(o.dryRun)
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	pjutil.ServePProf(o.instrumentationOptions.PProfPort)
+
+	configAgent := &config.Agent{}
+	if err := configAgent.Start(o.configPath, o.jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+	cfg := configAgent.Config
+
+	metrics.ExposeMetrics("artifact-retention", cfg().PushGateway, o.instrumentationOptions.MetricsPort)
+
+	opener, err := o.storage.StorageClient(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating opener.")
+	}
+
+	c := controller{config: cfg, opener: opener, dryRun: o.dryRun}
+	if o.runOnce {
+		c.clean(interrupts.Context(), time.Now())
+		return
+	}
+	interrupts.Tick(func() {
+		c.clean(interrupts.Context(), time.Now())
+	}, func() time.Duration {
+		return cfg().ArtifactRetention.ResyncPeriod.Duration
+	})
+}
+
+type controller struct {
+	config config.Getter
+	opener pkgio.Opener
+	dryRun bool
+}
+
+// job is a decorated job whose artifacts have a retention policy.
+type job struct {
+	name    string
+	jobType prowapi.ProwJobType
+	repo    string
+	gcs     *prowapi.GCSConfiguration
+	policy  *config.RetentionPolicy
+}
+
+func (c *controller) clean(ctx context.Context, now time.Time) {
+	start := time.Now()
+	results := map[retention.Result]int{}
+	for _, j := range jobsWithPolicies(c.config()) {
+		log := logrus.WithFields(logrus.Fields{"job": j.name, "type": j.jobType})
+		bucket, err := jobhistory.ParseBucket(j.gcs.Bucket, c.opener)
+		if err != nil {
+			log.WithError(err).Warn("Failed to parse bucket.")
+			continue
+		}
+		dirs, err := runDirs(ctx, bucket, j)
+		if err != nil {
+			// Clean up the runs that could be listed anyway.
+			log.WithError(err).Warn("Failed to list all runs.")
+		}
+		for _, dir := range dirs {
+			if ctx.Err() != nil {
+				return
+			}
+			if inScope, err := runInScope(ctx, bucket, dir, j); err != nil {
+				log.WithError(err).WithField("dir", dir).Warn("Failed to find the repo of run.")
+				continue
+			} else if !inScope {
+				log.WithField("dir", dir).Debug("Skipping run of another job with the same name.")
+				continue
+			}
+			result, err := retention.Clean(ctx, bucket, dir, j.policy, now, c.dryRun)
+			if err != nil {
+				log.WithError(err).WithField("dir", dir).Warn("Failed to clean up run.")
+				continue
+			}
+			if result == retention.Deleted {
+				log.WithFields(logrus.Fields{"dir": dir, "dry-run": c.dryRun}).Info("Deleted artifacts.")
+			}
+			results[result]++
+			runsCleaned.WithLabelValues(string(j.jobType), string(result)).Inc()
+		}
+	}
+	logrus.WithFields(logrus.Fields{
+		"duration": time.Since(start).String(),
+		"deleted":  results[retention.Deleted],
+		"retained": results[retention.Retained],
+		"pinned":   results[retention.Pinned],
+		"expired":  results[retention.AlreadyExpired],
+	}).Info("Finished cleaning up artifacts.")
+}
+
+// jobsWithPolicies lists the decorated jobs whose artifacts are not kept
+// forever. Presubmits are listed a second time as batch jobs, as their
+// batch runs are stored separately.
+func jobsWithPolicies(cfg *config.Config) []job {
+	var jobs []job
+	add := func(base config.JobBase, jobType prowapi.ProwJobType, repo string) {
+		dc := base.DecorationConfig
+		if dc == nil || dc.GCSConfiguration == nil || dc.GCSConfiguration.Bucket == "" {
+			return
+		}
+		policy := cfg.ArtifactRetention.PolicyFor(jobType, repo, base.Name)
+		if policy == nil {
+			return
+		}
+		jobs = append(jobs, job{name: base.Name, jobType: jobType, repo: repo, gcs: dc.GCSConfiguration, policy: policy})
+	}
+	for repo, presubmits := range cfg.PresubmitsStatic {
+		for _, presubmit := range presubmits {
+			add(presubmit.JobBase, prowapi.PresubmitJob, repo)
+			add(presubmit.JobBase, prowapi.BatchJob, repo)
+		}
+	}
+	for repo, postsubmits := range cfg.PostsubmitsStatic {
+		for _, postsubmit := range postsubmits {
+			add(postsubmit.JobBase, prowapi.PostsubmitJob, repo)
+		}
+	}
+	for _, periodic := range cfg.Periodics {
+		var repo string
+		if len(periodic.ExtraRefs) > 0 {
+			repo = periodic.ExtraRefs[0].Org + "/" + periodic.ExtraRefs[0].Repo
+		}
+		add(periodic.JobBase, prowapi.PeriodicJob, repo)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if jobs[i].name != jobs[k].name {
+			return jobs[i].name < jobs[k].name
+		}
+		return jobs[i].jobType < jobs[k].jobType
+	})
+	return jobs
+}
+
+// runDirs lists the directories of the runs of the job. Presubmit runs are
+// found through the symlinks in pr-logs/directory, the runs of all other
+// jobs are directories named after their build id.
+func runDirs(ctx context.Context, bucket jobhistory.Bucket, j job) ([]string, error) {
+	switch j.jobType {
+	case prowapi.PresubmitJob:
+		root := path.Join(j.gcs.PathPrefix, jobhistory.PRLogsDirectory, j.name)
+		ids, listErr := bucket.ListBuildIDs(ctx, root)
+		var dirs []string
+		for _, id := range ids {
+			dir, err := bucket.GetPath(ctx, root, strconv.FormatInt(id, 10), "")
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{"job": j.name, "id": id}).Warn("Failed to resolve run.")
+				continue
+			}
+			// Batch runs are linked here as well, but cleaned up as batch jobs.
+			if path.Base(path.Dir(path.Dir(dir))) == "batch" {
+				continue
+			}
+			dirs = append(dirs, dir)
+		}
+		return dirs, listErr
+	case prowapi.BatchJob:
+		return buildDirs(ctx, bucket, path.Join(j.gcs.PathPrefix, gcs.PRLogs, "pull", "batch", j.name))
+	default:
+		return buildDirs(ctx, bucket, path.Join(j.gcs.PathPrefix, gcs.NonPRLogs, j.name))
+	}
+}
+
+func buildDirs(ctx context.Context, bucket jobhistory.Bucket, root string) ([]string, error) {
+	subDirs, err := bucket.ListSubDirs(ctx, root)
+	var dirs []string
+	for _, dir := range subDirs {
+		if _, err := strconv.ParseInt(path.Base(dir), 10, 64); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, err
+}
+
+// runInScope checks whether the run in dir tested the repo of the job, as
+// jobs of different repos may share a name and thus their directories, but
+// not their retention policy. The repo is read from the prowjob.json of the
+// run, or from its started.json if crier did not upload the former. Runs
+// whose repo cannot be told are not in scope.
+func runInScope(ctx context.Context, bucket jobhistory.Bucket, dir string, j job) (bool, error) {
+	raw, err := bucket.ReadObject(ctx, path.Join(dir, "prowjob.json"))
+	if err == nil {
+		var pj prowapi.ProwJob
+		if err := json.Unmarshal(raw, &pj); err != nil {
+			return false, fmt.Errorf("failed to unmarshal prowjob.json: %w", err)
+		}
+		refs := pj.Spec.Refs
+		if refs == nil && len(pj.Spec.ExtraRefs) > 0 {
+			refs = &pj.Spec.ExtraRefs[0]
+		}
+		if refs == nil {
+			return j.repo == "", nil
+		}
+		return refs.Org+"/"+refs.Repo == j.repo, nil
+	} else if !pkgio.IsNotExist(err) {
+		return false, err
+	}
+
+	raw, err = bucket.ReadObject(ctx, path.Join(dir, prowapi.StartedStatusFile))
+	if pkgio.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var started gcs.Started
+	if err := json.Unmarshal(raw, &started); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %w", prowapi.StartedStatusFile, err)
+	}
+	if j.repo == "" {
+		return len(started.Repos) == 0, nil
+	}
+	_, ok := started.Repos[j.repo]
+	return ok, nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+)
+
+// fakeOpener serves the objects of a single bucket from memory.
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string]string
+}
+
+func (fo fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	content, ok := fo.objects[strings.TrimPrefix(p, "gs://bucket/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo fakeOpener) Iterator(_ context.Context, prefix, delimiter string) (pkgio.ObjectIterator, error) {
+	prefix = strings.TrimPrefix(prefix, "gs://bucket/")
+	names := sets.NewString()
+	for key := range fo.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, "/"); delimiter != "" && i >= 0 {
+			names.Insert(prefix + rest[:i+1])
+		} else {
+			names.Insert(key)
+		}
+	}
+	var attrs []pkgio.ObjectAttributes
+	for _, name := range names.List() {
+		attrs = append(attrs, pkgio.ObjectAttributes{Name: name, IsDir: strings.HasSuffix(name, "/")})
+	}
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+func TestJobsWithPolicies(t *testing.T) {
+	decoration := &prowapi.DecorationConfig{GCSConfiguration: &prowapi.GCSConfiguration{Bucket: "bucket"}}
+	cfg := &config.Config{
+		JobConfig: config.JobConfig{
+			PresubmitsStatic: map[string][]config.Presubmit{
+				"org/repo": {
+					{JobBase: config.JobBase{Name: "pull-unit", UtilityConfig: config.UtilityConfig{DecorationConfig: decoration}}},
+					{JobBase: config.JobBase{Name: "pull-undecorated"}},
+				},
+			},
+			PostsubmitsStatic: map[string][]config.Postsubmit{
+				"org/repo": {
+					{JobBase: config.JobBase{Name: "post-push", UtilityConfig: config.UtilityConfig{DecorationConfig: decoration}}},
+				},
+			},
+			Periodics: []config.Periodic{
+				{JobBase: config.JobBase{Name: "ci-e2e", UtilityConfig: config.UtilityConfig{DecorationConfig: decoration, ExtraRefs: []prowapi.Refs{{Org: "org", Repo: "repo"}}}}},
+				{JobBase: config.JobBase{Name: "ci-forever", UtilityConfig: config.UtilityConfig{DecorationConfig: decoration}}},
+			},
+		},
+		ProwConfig: config.ProwConfig{
+			ArtifactRetention: config.ArtifactRetention{
+				Policies: []config.RetentionPolicy{
+					{
+						JobTypes: []prowapi.ProwJobType{prowapi.PresubmitJob, prowapi.PostsubmitJob},
+						MaxAge:   metav1.Duration{Duration: 30 * 24 * time.Hour},
+					},
+					{
+						Repos:  []string{"org"},
+						MaxAge: metav1.Duration{Duration: 90 * 24 * time.Hour},
+					},
+				},
+			},
+		},
+	}
+	if err := cfg.ArtifactRetention.DefaultAndValidate(); err != nil {
+		t.Fatalf("invalid retention config: %v", err)
+	}
+
+	type summary struct {
+		Name   string
+		Type   prowapi.ProwJobType
+		MaxAge time.Duration
+	}
+	var actual []summary
+	for _, j := range jobsWithPolicies(cfg) {
+		actual = append(actual, summary{Name: j.name, Type: j.jobType, MaxAge: j.policy.MaxAge.Duration})
+	}
+	expected := []summary{
+		{Name: "ci-e2e", Type: prowapi.PeriodicJob, MaxAge: 90 * 24 * time.Hour},
+		{Name: "post-push", Type: prowapi.PostsubmitJob, MaxAge: 30 * 24 * time.Hour},
+		{Name: "pull-unit", Type: prowapi.BatchJob, MaxAge: 90 * 24 * time.Hour},
+		{Name: "pull-unit", Type: prowapi.PresubmitJob, MaxAge: 30 * 24 * time.Hour},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("jobs differ from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunDirs(t *testing.T) {
+	bucket := jobhistory.NewBucket("bucket", "gs", fakeOpener{objects: map[string]string{
+		"pr-logs/directory/pull-unit/1.txt":                           "gs://bucket/pr-logs/pull/org_repo/5/pull-unit/1",
+		"pr-logs/directory/pull-unit/2.txt":                           "gs://bucket/pr-logs/pull/batch/pull-unit/2",
+		"pr-logs/directory/pull-unit/latest-build.txt":                "2",
+		"pr-logs/pull/org_repo/5/pull-unit/1/started.json":            "{}",
+		"pr-logs/pull/batch/pull-unit/2/started.json":                 "{}",
+		"logs/ci-e2e/10/started.json":                                 "{}",
+		"logs/ci-e2e/11/build-log.txt":                                "log",
+		"logs/ci-e2e/latest-build.txt":                                "11",
+		"prefix/logs/ci-prefixed/20/started.json":                     "{}",
+		"prefix/pr-logs/directory/pull-prefixed/3.txt":                "gs://bucket/prefix/pr-logs/pull/org_repo/5/pull-prefixed/3",
+		"prefix/pr-logs/pull/org_repo/5/pull-prefixed/3/started.json": "{}",
+	}})
+
+	testCases := []struct {
+		name     string
+		job      job
+		expected []string
+	}{
+		{
+			name:     "presubmit runs are resolved through their symlinks",
+			job:      job{name: "pull-unit", jobType: prowapi.PresubmitJob, gcs: &prowapi.GCSConfiguration{}},
+			expected: []string{"pr-logs/pull/org_repo/5/pull-unit/1"},
+		},
+		{
+			name:     "batch runs are listed",
+			job:      job{name: "pull-unit", jobType: prowapi.BatchJob, gcs: &prowapi.GCSConfiguration{}},
+			expected: []string{"pr-logs/pull/batch/pull-unit/2/"},
+		},
+		{
+			name:     "periodic runs are listed",
+			job:      job{name: "ci-e2e", jobType: prowapi.PeriodicJob, gcs: &prowapi.GCSConfiguration{}},
+			expected: []string{"logs/ci-e2e/10/", "logs/ci-e2e/11/"},
+		},
+		{
+			name:     "path prefix is honored",
+			job:      job{name: "ci-prefixed", jobType: prowapi.PeriodicJob, gcs: &prowapi.GCSConfiguration{PathPrefix: "prefix"}},
+			expected: []string{"prefix/logs/ci-prefixed/20/"},
+		},
+		{
+			name:     "path prefix is honored for presubmits",
+			job:      job{name: "pull-prefixed", jobType: prowapi.PresubmitJob, gcs: &prowapi.GCSConfiguration{PathPrefix: "prefix"}},
+			expected: []string{"prefix/pr-logs/pull/org_repo/5/pull-prefixed/3"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dirs, err := runDirs(context.Background(), bucket, tc.job)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sort.Strings(dirs)
+			if diff := cmp.Diff(tc.expected, dirs); diff != "" {
+				t.Errorf("run directories differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunInScope(t *testing.T) {
+	bucket := jobhistory.NewBucket("bucket", "gs", fakeOpener{objects: map[string]string{
+		"logs/post-push/1/prowjob.json":  `{"spec":{"job":"post-push","refs":{"org":"org","repo":"repo"}}}`,
+		"logs/post-push/2/prowjob.json":  `{"spec":{"job":"post-push","refs":{"org":"org","repo":"other"}}}`,
+		"logs/post-push/3/started.json":  `{"timestamp":1,"repos":{"org/repo":"master"}}`,
+		"logs/post-push/4/started.json":  `{"timestamp":1,"repos":{"org/other":"master"}}`,
+		"logs/ci-e2e/1/prowjob.json":     `{"spec":{"job":"ci-e2e","extra_refs":[{"org":"org","repo":"repo"}]}}`,
+		"logs/ci-forever/1/prowjob.json": `{"spec":{"job":"ci-forever"}}`,
+		"logs/ci-forever/2/started.json": `{"timestamp":1}`,
+	}})
+
+	testCases := []struct {
+		name     string
+		dir      string
+		job      job
+		expected bool
+	}{
+		{
+			name:     "prowjob of the repo",
+			dir:      "logs/post-push/1",
+			job:      job{name: "post-push", repo: "org/repo"},
+			expected: true,
+		},
+		{
+			name: "prowjob of another repo",
+			dir:  "logs/post-push/2",
+			job:  job{name: "post-push", repo: "org/repo"},
+		},
+		{
+			name:     "started of the repo",
+			dir:      "logs/post-push/3",
+			job:      job{name: "post-push", repo: "org/repo"},
+			expected: true,
+		},
+		{
+			name: "started of another repo",
+			dir:  "logs/post-push/4",
+			job:  job{name: "post-push", repo: "org/repo"},
+		},
+		{
+			name:     "periodic with extra refs",
+			dir:      "logs/ci-e2e/1",
+			job:      job{name: "ci-e2e", repo: "org/repo"},
+			expected: true,
+		},
+		{
+			name:     "prowjob without refs",
+			dir:      "logs/ci-forever/1",
+			job:      job{name: "ci-forever"},
+			expected: true,
+		},
+		{
+			name:     "started without repos",
+			dir:      "logs/ci-forever/2",
+			job:      job{name: "ci-forever"},
+			expected: true,
+		},
+		{
+			name: "run without metadata",
+			dir:  "logs/ci-forever/3",
+			job:  job{name: "ci-forever"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := runInScope(context.Background(), bucket, tc.dir, tc.job)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected run in scope: %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
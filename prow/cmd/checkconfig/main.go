@@ -35,6 +35,7 @@ import (
 
 	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/lint"
 	"k8s.io/test-infra/prow/config/secret"
 	needsrebase "k8s.io/test-infra/prow/external-plugins/needs-rebase/plugin"
 	"k8s.io/test-infra/prow/flagutil"
@@ -66,6 +67,9 @@ type options struct {
 	prowYAMLRepoName string
 	prowYAMLPath     string
 
+	jobRulesPath string
+	sarifOutput  string
+
 	warnings        flagutil.Strings
 	excludeWarnings flagutil.Strings
 	strict          bool
@@ -104,8 +108,14 @@ const (
 	unknownFieldsWarning         = "unknown-fields"
 	verifyOwnersFilePresence     = "verify-owners-presence"
 	validateClusterFieldWarning  = "validate-cluster-field"
+	jobRulesWarning              = "job-rules"
+	periodicTriggersWarning      = "periodic-triggers"
 )
 
+// maxPeriodicTriggerFanOut is the number of periodics a single event may
+// trigger, directly or through chained periodics, before checkconfig warns.
+const maxPeriodicTriggerFanOut = 10
+
 var defaultWarnings = []string{
 	mismatchedTideWarning,
 	tideStrictBranchWarning,
@@ -121,6 +131,8 @@ var defaultWarnings = []string{
 	validateURLsWarning,
 	unknownFieldsWarning,
 	validateClusterFieldWarning,
+	jobRulesWarning,
+	periodicTriggersWarning,
 }
 
 var expensiveWarnings = []string{
@@ -149,6 +161,9 @@ func (o *options) DefaultAndValidate() error {
 			o.prowYAMLPath = fmt.Sprintf("/home/prow/go/src/github.com/%s/.prow.yaml", o.prowYAMLRepoName)
 		}
 	}
+	if o.sarifOutput != "" && o.jobRulesPath == "" {
+		return errors.New("--sarif-output requires --job-rules to be set")
+	}
 	for _, warning := range o.warnings.Strings() {
 		found := false
 		for _, registeredWarning := range allWarnings {
@@ -179,6 +194,8 @@ func (o *options) gatherOptions(flag *flag.FlagSet, args []string) error {
 	flag.StringVar(&o.pluginConfig, "plugin-config", "", "Path to plugin config file.")
 	flag.StringVar(&o.prowYAMLRepoName, "prow-yaml-repo-name", "", "Name of the repo whose .prow.yaml should be checked.")
 	flag.StringVar(&o.prowYAMLPath, "prow-yaml-path", "", "Path to the .prow.yaml file to check. Requires --prow-yaml-repo-name to be set. Defaults to `/home/prow/go/src/github.com/<< prow-yaml-repo-name >>/.prow.yaml`")
+	flag.StringVar(&o.jobRulesPath, "job-rules", "", "Path to a YAML file with rules to check every job against.")
+	flag.StringVar(&o.sarifOutput, "sarif-output", "", "Path to write the findings of --job-rules to in SARIF format.")
 	flag.Var(&o.warnings, "warnings", "Warnings to validate. Use repeatedly to provide a list of warnings")
 	flag.Var(&o.excludeWarnings, "exclude-warning", "Warnings to exclude. Use repeatedly to provide a list of warnings to exclude")
 	flag.BoolVar(&o.expensive, "expensive-checks", false, "If set, additional expensive warnings will be enabled")
@@ -362,9 +379,57 @@ func validate(o options) error {
 			errs = append(errs, err)
 		}
 	}
+	if o.warningEnabled(periodicTriggersWarning) {
+		if err := validatePeriodicTriggers(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if o.jobRulesPath != "" && o.warningEnabled(jobRulesWarning) {
+		findings, err := validateJobRules(cfg.JobConfig, o.jobRulesPath, o.sarifOutput)
+		if err != nil {
+			return fmt.Errorf("error checking job rules: %w", err)
+		}
+		// Findings with error severity fail the check even if --strict is unset.
+		var ruleErrs []error
+		for _, finding := range findings {
+			if finding.Severity == lint.SeverityError {
+				ruleErrs = append(ruleErrs, errors.New(finding.String()))
+			} else {
+				errs = append(errs, errors.New(finding.String()))
+			}
+		}
+		if len(ruleErrs) > 0 {
+			return fmt.Errorf("%d jobs violate rules with error severity: %w", len(ruleErrs), utilerrors.NewAggregate(append(ruleErrs, errs...)))
+		}
+	}
 
 	return utilerrors.NewAggregate(errs)
 }
+
+// validateJobRules checks all jobs against the rules in rulesPath and writes
+// the findings to sarifOutput, if set.
+func validateJobRules(cfg config.JobConfig, rulesPath, sarifOutput string) ([]lint.Finding, error) {
+	rulesConfig, err := lint.LoadConfig(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+	linter, err := lint.NewLinter(*rulesConfig)
+	if err != nil {
+		return nil, err
+	}
+	findings := linter.Lint(lint.JobsFromConfig(cfg))
+	if sarifOutput != "" {
+		f, err := os.Create(sarifOutput)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := linter.WriteSARIF(f, findings); err != nil {
+			return nil, fmt.Errorf("failed to write SARIF to %s: %w", sarifOutput, err)
+		}
+	}
+	return findings, nil
+}
 func policyIsStrict(p config.Policy) bool {
 	if p.Protect == nil || !*p.Protect {
 		return false
@@ -529,6 +594,81 @@ func validatePeriodicJob(job config.Periodic) error {
 	return utilerrors.NewAggregate(validationErrs)
 }
 
+// validatePeriodicTriggers warns about periodics triggered by jobs that are
+// not configured and about events that trigger too many periodics. Loops are
+// already rejected when the config is loaded.
+func validatePeriodicTriggers(cfg *config.Config) error {
+	var errs []error
+	knownJobs := sets.NewString()
+	postsubmitRepos := sets.NewString()
+	for _, jobs := range cfg.PresubmitsStatic {
+		for _, job := range jobs {
+			knownJobs.Insert(job.Name)
+		}
+	}
+	for repo, jobs := range cfg.PostsubmitsStatic {
+		for _, job := range jobs {
+			knownJobs.Insert(job.Name)
+		}
+		postsubmitRepos.Insert(repo)
+	}
+	for _, job := range cfg.Periodics {
+		knownJobs.Insert(job.Name)
+	}
+	// Jobs configured in repos are not known here.
+	var inRepoConfigUsed bool
+	for _, enabled := range cfg.InRepoConfig.Enabled {
+		if enabled != nil && *enabled {
+			inRepoConfigUsed = true
+		}
+	}
+
+	// downstream maps events to the periodics they trigger directly.
+	downstream := map[string]sets.String{}
+	for _, p := range cfg.Periodics {
+		for _, trigger := range p.TriggeredBy {
+			var event string
+			switch {
+			case trigger.JobSucceeded != "":
+				if !knownJobs.Has(trigger.JobSucceeded) && !inRepoConfigUsed {
+					errs = append(errs, fmt.Errorf("periodic %s is triggered by job %s, which is not configured", p.Name, trigger.JobSucceeded))
+				}
+				event = trigger.JobSucceeded
+			case trigger.Postsubmit != nil:
+				if !postsubmitRepos.Has(trigger.Postsubmit.Repo) && !cfg.InRepoConfigEnabled(trigger.Postsubmit.Repo) {
+					errs = append(errs, fmt.Errorf("periodic %s is triggered by postsubmits of %s, which has none configured", p.Name, trigger.Postsubmit.Repo))
+				}
+				event = "postsubmits of " + trigger.Postsubmit.Repo
+			case trigger.GCSObject != "":
+				event = trigger.GCSObject
+			}
+			if downstream[event] == nil {
+				downstream[event] = sets.NewString()
+			}
+			downstream[event].Insert(p.Name)
+		}
+	}
+
+	for _, event := range sets.StringKeySet(downstream).List() {
+		// Periodics triggered by the event trigger their own downstream periodics.
+		triggered := sets.NewString()
+		queue := downstream[event].List()
+		for len(queue) > 0 {
+			job := queue[0]
+			queue = queue[1:]
+			if triggered.Has(job) {
+				continue
+			}
+			triggered.Insert(job)
+			queue = append(queue, downstream[job].List()...)
+		}
+		if triggered.Len() > maxPeriodicTriggerFanOut {
+			errs = append(errs, fmt.Errorf("%s triggers %d periodics, more than the maximum of %d: %s", event, triggered.Len(), maxPeriodicTriggerFanOut, strings.Join(triggered.List(), ", ")))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func validateTideRequirements(cfg *config.Config, pcfg *plugins.Configuration, includeForbidden bool) error {
 	type matcher struct {
 		// matches determines if the tide query appropriately honors the
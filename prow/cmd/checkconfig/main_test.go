@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
@@ -36,6 +37,7 @@ import (
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/lint"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/plugins"
@@ -1304,6 +1306,14 @@ func TestOptions(t *testing.T) {
 			},
 			expectedError: false,
 		},
+		{
+			name: "sarif-output without job-rules is invalid",
+			args: []string{
+				"--config-path=prow/config.yaml",
+				"--sarif-output=findings.sarif",
+			},
+			expectedError: true,
+		},
 		{
 			name: "prow-yaml-path without prow-yaml-repo-name is invalid",
 			args: []string{
@@ -1652,3 +1662,108 @@ func TestValidateClusterField(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJobRules(t *testing.T) {
+	jobConfig := config.JobConfig{
+		Periodics: []config.Periodic{
+			{JobBase: config.JobBase{Name: "owned", Annotations: map[string]string{"owner": "me"}}},
+			{JobBase: config.JobBase{Name: "unowned"}},
+			{JobBase: config.JobBase{Name: "suppressed", Annotations: map[string]string{lint.SuppressAnnotation: "periodic-owner"}}},
+		},
+	}
+	dir, err := ioutil.TempDir("", "checkconfig")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	rulesPath := filepath.Join(dir, "rules.yaml")
+	rules := `rules:
+- id: periodic-owner
+  job_types: [periodic]
+  expression: '"annotations" in job && "owner" in job.annotations'
+  message: periodics must have an owner annotation
+`
+	if err := ioutil.WriteFile(rulesPath, []byte(rules), 0644); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+	sarifOutput := filepath.Join(dir, "findings.sarif")
+
+	findings, err := validateJobRules(jobConfig, rulesPath, sarifOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Job.Name != "unowned" {
+		t.Errorf("expected a single finding for job unowned, got %v", findings)
+	}
+	if _, err := os.Stat(sarifOutput); err != nil {
+		t.Errorf("expected SARIF output to be written: %v", err)
+	}
+}
+
+func TestValidatePeriodicTriggers(t *testing.T) {
+	periodic := func(name string, triggers ...config.PeriodicTrigger) config.Periodic {
+		return config.Periodic{JobBase: config.JobBase{Name: name}, TriggeredBy: triggers}
+	}
+	fanOut := []config.Periodic{periodic("build")}
+	for i := 0; i < maxPeriodicTriggerFanOut; i++ {
+		fanOut = append(fanOut, periodic(fmt.Sprintf("test-%d", i), config.PeriodicTrigger{JobSucceeded: "build"}))
+	}
+	testCases := []struct {
+		name          string
+		periodics     []config.Periodic
+		inRepoConfig  bool
+		expectedError string
+	}{
+		{
+			name: "valid triggers",
+			periodics: []config.Periodic{
+				periodic("build"),
+				periodic("test", config.PeriodicTrigger{JobSucceeded: "build"}),
+				periodic("publish", config.PeriodicTrigger{JobSucceeded: "post"}, config.PeriodicTrigger{Postsubmit: &config.PostsubmitTrigger{Repo: "org/repo"}}),
+			},
+		},
+		{
+			name:          "unknown upstream job",
+			periodics:     []config.Periodic{periodic("test", config.PeriodicTrigger{JobSucceeded: "build"})},
+			expectedError: "periodic test is triggered by job build, which is not configured",
+		},
+		{
+			name:         "unknown upstream job may be configured in a repo",
+			periodics:    []config.Periodic{periodic("test", config.PeriodicTrigger{JobSucceeded: "build"})},
+			inRepoConfig: true,
+		},
+		{
+			name:          "repo without postsubmits",
+			periodics:     []config.Periodic{periodic("test", config.PeriodicTrigger{Postsubmit: &config.PostsubmitTrigger{Repo: "org/other"}})},
+			expectedError: "periodic test is triggered by postsubmits of org/other, which has none configured",
+		},
+		{
+			name:      "fan-out at the limit",
+			periodics: fanOut,
+		},
+		{
+			name:          "fan-out through chain exceeds the limit",
+			periodics:     append(fanOut, periodic("publish", config.PeriodicTrigger{JobSucceeded: "test-0"})),
+			expectedError: "build triggers 11 periodics, more than the maximum of 10: publish, test-0, test-1, test-2, test-3, test-4, test-5, test-6, test-7, test-8, test-9",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{JobConfig: config.JobConfig{
+				Periodics:         tc.periodics,
+				PostsubmitsStatic: map[string][]config.Postsubmit{"org/repo": {{JobBase: config.JobBase{Name: "post"}}}},
+			}}
+			if tc.inRepoConfig {
+				enabled := true
+				cfg.InRepoConfig.Enabled = map[string]*bool{"org/repo": &enabled}
+			}
+			errMsg := ""
+			if err := validatePeriodicTriggers(cfg); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.expectedError {
+				t.Errorf("expected error %q, got error %q", tc.expectedError, errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pjarchive"
+)
+
+// archivePageDays is the most days of the archive a single response covers,
+// as every day is a separate directory to read.
+const archivePageDays = 31
+
+// handleArchivedProwJobs searches the ProwJobs that sinker archived before
+// garbage-collecting them. It responds like /prowjobs.js, hides the same jobs
+// and accepts these query parameters:
+//
+//	from, to: the first and the last day to search, e.g. 2021-03-14. Both default to today.
+//	  The range may be of any length. A response covers at most archivePageDays days of it,
+//	  starting with the most recent ones, and sets "next" to the 'to' day of the following
+//	  page if days of the range are left.
+//	repo: an org or an org/repo.
+//	pr: a pull request number.
+//	author: the author of a pull request.
+//	state: the state of the ProwJobs, e.g. failure.
+//	omit: the fields to omit, like for /prowjobs.js.
+func handleArchivedProwJobs(cfg config.Getter, opener io.Opener, authz *authorizer, hiddenOnly, showHidden bool, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		location := cfg().Sinker.ArchiveLocation
+		if location == "" {
+			http.Error(w, "ProwJobs are not archived. Enable archiving with 'sinker.archive_location'.", http.StatusNotFound)
+			return
+		}
+		query, err := parseArchiveQuery(r.URL.Query(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, next := archivePage(query)
+		jobs, err := pjarchive.New(opener, location).List(r.Context(), page)
+		if err != nil {
+			log.WithError(err).Error("Error searching archived ProwJobs.")
+			http.Error(w, "Error searching archived ProwJobs.", http.StatusInternalServerError)
+			return
+		}
+		jobs = authz.filterPrivateJobs(r, filterHiddenJobs(jobs, cfg().Deck.HiddenRepos, hiddenOnly, showHidden))
+		omitProwJobFields(jobs, r.URL.Query().Get("omit"))
+
+		resp := struct {
+			Items []prowapi.ProwJob `json:"items"`
+			Next  string            `json:"next,omitempty"`
+		}{Items: jobs}
+		if !next.IsZero() {
+			resp.Next = pjarchive.FormatDay(next)
+		}
+		jd, err := json.Marshal(resp)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling jobs.")
+			jd = []byte("{}")
+		}
+		writeJSONResponse(w, r, jd)
+	}
+}
+
+func parseArchiveQuery(values url.Values, now time.Time) (pjarchive.Query, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	query := pjarchive.Query{
+		From:   today,
+		To:     today,
+		Repo:   values.Get("repo"),
+		Author: values.Get("author"),
+		State:  prowapi.ProwJobState(values.Get("state")),
+	}
+	for param, day := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := values.Get(param); value != "" {
+			parsed, err := pjarchive.ParseDay(value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s day %q, expected a day like 2021-03-14", param, value)
+			}
+			*day = parsed
+		}
+	}
+	if query.To.Before(query.From) {
+		return query, fmt.Errorf("the 'to' day must not be before the 'from' day")
+	}
+	if pr := values.Get("pr"); pr != "" {
+		n, err := strconv.Atoi(pr)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf("invalid pull request number %q", pr)
+		}
+		query.PR = n
+	}
+	return query, nil
+}
+
+// archivePage narrows the query to its most recent archivePageDays days. It
+// returns the 'to' day of the following page, or the zero time if the page
+// covers the whole query.
+func archivePage(query pjarchive.Query) (pjarchive.Query, time.Time) {
+	first := query.To.Add(-(archivePageDays - 1) * 24 * time.Hour)
+	if !first.After(query.From) {
+		return query, time.Time{}
+	}
+	query.From = first
+	return query, first.Add(-24 * time.Hour)
+}
+
+// filterHiddenJobs hides the jobs that the job agent hides from /prowjobs.js.
+func filterHiddenJobs(pjs []prowapi.ProwJob, hiddenRepos []string, hiddenOnly, showHidden bool) []prowapi.ProwJob {
+	var filtered []prowapi.ProwJob
+	for i := range pjs {
+		hidden := isHidden(&pjs[i], hiddenRepos)
+		if (hidden && showHidden) || hidden == hiddenOnly {
+			filtered = append(filtered, pjs[i])
+		}
+	}
+	return filtered
+}
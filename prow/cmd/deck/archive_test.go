@@ -0,0 +1,200 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pjarchive"
+)
+
+func TestHandleArchivedProwJobs(t *testing.T) {
+	day := time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)
+	prowJob := func(name string, start time.Time, state prowapi.ProwJobState, pr int) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: prowapi.ProwJobSpec{
+				Job:  "job",
+				Refs: &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: pr, Author: "alice"}}},
+			},
+			Status: prowapi.ProwJobStatus{State: state, StartTime: metav1.NewTime(start)},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		archiveLocation string
+		hiddenRepos     []string
+		showHidden      bool
+		query           string
+		expectedStatus  int
+		expected        []string
+		expectedNext    string
+	}{
+		{
+			name:           "archiving is disabled",
+			query:          "from=2021-03-14",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:            "bad day",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=yesterday",
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "bad pull request",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-03-14&pr=abc",
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "end before start",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-03-15&to=2021-03-14",
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "long range is paginated",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-01-01&to=2021-04-14",
+			expectedStatus:  http.StatusOK,
+			expected:        []string{"c"},
+			expectedNext:    "2021-03-14",
+		},
+		{
+			name:            "following page",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-01-01&to=2021-03-14",
+			expectedStatus:  http.StatusOK,
+			expected:        []string{"b", "a"},
+			expectedNext:    "2021-02-11",
+		},
+		{
+			name:            "last page",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-01-01&to=2021-01-31",
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:            "date range",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-03-14&to=2021-03-15",
+			expectedStatus:  http.StatusOK,
+			expected:        []string{"c", "b", "a"},
+		},
+		{
+			name:            "search",
+			archiveLocation: "gs://bucket/prowjobs",
+			query:           "from=2021-03-14&to=2021-03-15&repo=org/repo&pr=1&author=alice&state=failure",
+			expectedStatus:  http.StatusOK,
+			expected:        []string{"c"},
+		},
+		{
+			name:            "jobs of hidden repos are hidden",
+			archiveLocation: "gs://bucket/prowjobs",
+			hiddenRepos:     []string{"org/repo"},
+			query:           "from=2021-03-14&to=2021-03-15",
+			expectedStatus:  http.StatusOK,
+		},
+		{
+			name:            "jobs of hidden repos are shown with --show-hidden",
+			archiveLocation: "gs://bucket/prowjobs",
+			hiddenRepos:     []string{"org"},
+			showHidden:      true,
+			query:           "from=2021-03-14&to=2021-03-15",
+			expectedStatus:  http.StatusOK,
+			expected:        []string{"c", "b", "a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcsServer := fakestorage.NewServer(nil)
+			defer gcsServer.Stop()
+			gcsServer.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "bucket"})
+			opener := io.NewGCSOpener(gcsServer.Client())
+			if err := pjarchive.New(opener, "gs://bucket/prowjobs").Write(context.Background(), []prowapi.ProwJob{
+				prowJob("a", day, prowapi.SuccessState, 1),
+				prowJob("b", day.Add(time.Hour), prowapi.FailureState, 2),
+				prowJob("c", day.Add(24*time.Hour), prowapi.FailureState, 1),
+			}, day.Add(48*time.Hour)); err != nil {
+				t.Fatalf("failed to archive ProwJobs: %v", err)
+			}
+
+			cfg := func() *config.Config {
+				return &config.Config{ProwConfig: config.ProwConfig{
+					Sinker: config.Sinker{ArchiveLocation: tc.archiveLocation},
+					Deck:   config.Deck{HiddenRepos: tc.hiddenRepos},
+				}}
+			}
+			handler := handleArchivedProwJobs(cfg, opener, nil, false, tc.showHidden, logrus.WithField("handler", "/archived-prowjobs.js"))
+			req := httptest.NewRequest(http.MethodGet, "/archived-prowjobs.js?"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Items []prowapi.ProwJob `json:"items"`
+				Next  string            `json:"next"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			var names []string
+			for _, pj := range resp.Items {
+				names = append(names, pj.Name)
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("unexpected ProwJobs (-want +got):\n%s", diff)
+			}
+			if resp.Next != tc.expectedNext {
+				t.Errorf("expected the next page to end on %q, got %q", tc.expectedNext, resp.Next)
+			}
+		})
+	}
+}
+
+func TestParseArchiveQueryDefaultsToToday(t *testing.T) {
+	now := time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)
+	query, err := parseArchiveQuery(nil, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	today := time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)
+	if !query.From.Equal(today) || !query.To.Equal(today) {
+		t.Errorf("expected the query to cover %s, got %s to %s", today, query.From, query.To)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/rbac"
+	prowgithub "k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/githuboauth"
+	"k8s.io/test-infra/prow/oidcauth"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// errLoginRequired means the requester has to log in before trying again.
+var errLoginRequired = errors.New("login required")
+
+// authorizer decides who may perform privileged actions in deck, like
+// rerunning and aborting jobs, and records every decision in the audit log.
+type authorizer struct {
+	// policy grants permissions to users and groups. Without a policy,
+	// jobs are rerun and aborted according to the rerun auth configs.
+	policy *rbac.Policy
+	audit  *rbac.AuditLog
+
+	oidc *oidcauth.Agent
+	goa  *githuboauth.Agent
+	ghc  githuboauth.AuthenticatedUserIdentifier
+
+	authCfg     authCfgGetter
+	cli         prowgithub.RerunClient
+	pluginsCfg  pluginsCfg
+	hiddenRepos func() []string
+}
+
+// newAuthorizer loads the RBAC policy, opens the audit log and sets up the
+// OIDC login as configured by the options.
+func newAuthorizer(o options, cfg config.Getter, authCfg authCfgGetter, cli prowgithub.RerunClient, pluginAgent *plugins.ConfigAgent) (*authorizer, error) {
+	a := &authorizer{
+		authCfg:     authCfg,
+		cli:         cli,
+		hiddenRepos: func() []string { return cfg().Deck.HiddenRepos },
+	}
+	if pluginAgent != nil {
+		a.pluginsCfg = pluginAgent.Config
+	}
+	if o.rbacPolicyFile != "" {
+		policy, err := rbac.LoadPolicy(o.rbacPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		a.policy = policy
+	}
+	a.audit = rbac.NewAuditLog(nil)
+	if o.auditLogFile != "" {
+		out, err := os.OpenFile(o.auditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		a.audit = rbac.NewAuditLog(out)
+	}
+	if o.oidcConfigFile != "" {
+		oidcConfigRaw, err := loadToken(o.oidcConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read OIDC config file: %w", err)
+		}
+		var oidcConfig oidcauth.Config
+		if err := yaml.Unmarshal(oidcConfigRaw, &oidcConfig); err != nil {
+			return nil, fmt.Errorf("error unmarshalling OIDC config: %w", err)
+		}
+		if err := oidcConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid OIDC config: %w", err)
+		}
+		cookie, err := loadCookieStore(o.cookieSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		oidcConfig.InitOIDCConfig(cookie)
+		// Only the groups the policy refers to matter, and users may be
+		// members of too many groups to fit in a cookie.
+		groups := sets.NewString()
+		if a.policy != nil {
+			groups = a.policy.Groups()
+		}
+		oidcConfig.KeepGroup = groups.Has
+		provider, err := oidcauth.Discover(&http.Client{Timeout: 30 * time.Second}, oidcConfig.IssuerURL)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = oidcauth.NewAgent(&oidcConfig, provider, logrus.WithField("client", "oidc"))
+	}
+	return a, nil
+}
+
+// loadCookieStore creates a cookie store from the base64 encoded secret in
+// the given file.
+func loadCookieStore(cookieSecretFile string) (*sessions.CookieStore, error) {
+	cookieSecretRaw, err := loadToken(cookieSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read cookie secret file: %w", err)
+	}
+	decodedSecret, err := base64.StdEncoding.DecodeString(string(cookieSecretRaw))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding cookie secret: %w", err)
+	}
+	if len(decodedSecret) == 0 {
+		return nil, errors.New("cookie secret should not be empty")
+	}
+	return sessions.NewCookieStore(decodedSecret), nil
+}
+
+// loginPath is where the frontend sends users to log in.
+func (a *authorizer) loginPath() string {
+	if a != nil && a.oidc != nil {
+		return "/oidc-login"
+	}
+	return "/github-login"
+}
+
+// identify returns who sent the request, preferring the OIDC login over
+// the GitHub one.
+func (a *authorizer) identify(r *http.Request, l *logrus.Entry) (rbac.Identity, error) {
+	if a.oidc != nil {
+		identity, err := a.oidc.GetIdentity(r)
+		if err == nil {
+			return rbac.Identity{User: identity.User, Groups: identity.Groups}, nil
+		}
+		l.WithError(err).Debug("No OIDC identity.")
+	}
+	if a.goa != nil {
+		login, err := a.goa.GetLogin(r, a.ghc)
+		if err == nil {
+			return rbac.Identity{User: login}, nil
+		}
+		l.WithError(err).Debug("Error retrieving GitHub login.")
+	}
+	return rbac.Identity{}, errLoginRequired
+}
+
+// authorizeJob decides whether the requester may perform the action on the
+// job and records the decision in the audit log.
+func (a *authorizer) authorizeJob(r *http.Request, permission rbac.Permission, pj *prowapi.ProwJob, l *logrus.Entry) (rbac.Identity, bool, error) {
+	identity, allowed, err := a.decide(r, permission, pj, l)
+	event := rbac.AuditEvent{
+		User:    identity.User,
+		Groups:  identity.Groups,
+		Action:  string(permission),
+		Target:  pj.Name,
+		Scope:   rbac.ScopeForJob(pj),
+		Allowed: allowed,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	a.audit.Record(event)
+	return identity, allowed, err
+}
+
+func (a *authorizer) decide(r *http.Request, permission rbac.Permission, pj *prowapi.ProwJob, l *logrus.Entry) (rbac.Identity, bool, error) {
+	if a.policy != nil {
+		return a.authorize(r, permission, rbac.ScopeForJob(pj), l)
+	}
+
+	authConfig := a.authCfg(pj.Spec.Refs)
+	if pj.Spec.RerunAuthConfig.IsAllowAnyone() || authConfig.IsAllowAnyone() {
+		// Skip getting the users login via GH oauth if anyone is allowed to rerun
+		// jobs so that GH oauth doesn't need to be set up for private Prows.
+		return rbac.Identity{}, true, nil
+	}
+	if a.goa == nil {
+		return rbac.Identity{}, false, fmt.Errorf("GitHub oauth must be configured to %s jobs unless 'allow_anyone: true' is specified", permission)
+	}
+	login, err := a.goa.GetLogin(r, a.ghc)
+	if err != nil {
+		l.WithError(err).Errorf("Error retrieving GitHub login")
+		return rbac.Identity{}, false, errLoginRequired
+	}
+	identity := rbac.Identity{User: login}
+	allowed, err := canTriggerJob(login, *pj, authConfig, a.cli, a.pluginsCfg, l.WithField("user", login))
+	if err != nil {
+		return identity, false, fmt.Errorf("error checking if user can %s job: %w", permission, err)
+	}
+	return identity, allowed, nil
+}
+
+// authorize decides with the policy whether the requester has the
+// permission in the scope.
+func (a *authorizer) authorize(r *http.Request, permission rbac.Permission, scope rbac.Scope, l *logrus.Entry) (rbac.Identity, bool, error) {
+	identity, err := a.identify(r, l)
+	if err != nil {
+		return identity, false, err
+	}
+	return identity, a.policy.Allowed(identity, permission, scope), nil
+}
+
+// httpStatusForAuthError returns the status to answer a request with when
+// it could not be authorized.
+func httpStatusForAuthError(err error) int {
+	if errors.Is(err, errLoginRequired) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// isPrivate determines whether the job is hidden from deck.
+func (a *authorizer) isPrivate(pj *prowapi.ProwJob) bool {
+	return isHidden(pj, a.hiddenRepos())
+}
+
+// isHidden determines whether the job is hidden or tests one of the hidden repos.
+func isHidden(pj *prowapi.ProwJob, hiddenRepos []string) bool {
+	if pj.Spec.Hidden {
+		return true
+	}
+	hidden := sets.NewString(hiddenRepos...)
+	refs := append([]prowapi.Refs{}, pj.Spec.ExtraRefs...)
+	if pj.Spec.Refs != nil {
+		refs = append(refs, *pj.Spec.Refs)
+	}
+	for _, ref := range refs {
+		if hidden.HasAny(fmt.Sprintf("%s/%s", ref.Org, ref.Repo), ref.Org) {
+			return true
+		}
+	}
+	return false
+}
+
+// canView determines whether the requester may see the job. With a policy,
+// private jobs are only shown to users logged in with OIDC who may view
+// them, as identifying GitHub users costs an API call per request.
+func (a *authorizer) canView(r *http.Request, pj *prowapi.ProwJob) bool {
+	if a == nil || a.policy == nil || !a.isPrivate(pj) {
+		return true
+	}
+	return a.canViewPrivate(r, rbac.ScopeForJob(pj))
+}
+
+// canViewPrivate determines whether the requester may see private jobs in
+// the scope.
+func (a *authorizer) canViewPrivate(r *http.Request, scope rbac.Scope) bool {
+	if a.oidc == nil {
+		return false
+	}
+	identity, err := a.oidc.GetIdentity(r)
+	if err != nil {
+		return false
+	}
+	return a.policy.Allowed(rbac.Identity{User: identity.User, Groups: identity.Groups}, rbac.PermissionViewPrivateJobs, scope)
+}
+
+// runFinder finds the ProwJob of a run given by a Spyglass src.
+type runFinder interface {
+	ResolveSymlink(src string) (string, error)
+	ProwJob(ctx context.Context, src string) (*prowapi.ProwJob, error)
+}
+
+// canViewRun determines whether the requester may see the run given by the
+// Spyglass src. Runs without a known ProwJob cannot be told to be public, so
+// only those who may see the private runs of the job named in src see them.
+func (a *authorizer) canViewRun(r *http.Request, runs runFinder, src string) (bool, error) {
+	if a == nil || a.policy == nil {
+		return true, nil
+	}
+	src, err := runs.ResolveSymlink(src)
+	if err != nil {
+		return false, err
+	}
+	pj, err := runs.ProwJob(r.Context(), src)
+	if err != nil {
+		return false, err
+	}
+	if pj == nil {
+		return a.canViewPrivate(r, rbac.Scope{Job: path.Base(path.Dir(strings.TrimSuffix(src, "/")))}), nil
+	}
+	return a.canView(r, pj), nil
+}
+
+// filterPrivateJobs drops the private jobs the requester may not see.
+func (a *authorizer) filterPrivateJobs(r *http.Request, pjs []prowapi.ProwJob) []prowapi.ProwJob {
+	if a == nil || a.policy == nil {
+		return pjs
+	}
+	visible := make([]prowapi.ProwJob, 0, len(pjs))
+	for i := range pjs {
+		if a.canView(r, &pjs[i]) {
+			visible = append(visible, pjs[i])
+		}
+	}
+	return visible
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/deck/rbac"
+	"k8s.io/test-infra/prow/githuboauth"
+)
+
+var testPolicy = &rbac.Policy{
+	Roles: []rbac.Role{
+		{Name: "developer", Permissions: []rbac.Permission{rbac.PermissionRerun, rbac.PermissionAbort}},
+	},
+	Bindings: []rbac.Binding{
+		{Role: "developer", Users: []string{"alice"}, Scopes: []rbac.Scope{{Org: "org", Repo: "repo"}}},
+	},
+}
+
+// loggedInRequest returns a request of the given GitHub user, or of an
+// anonymous user if login is empty.
+func loggedInRequest(t *testing.T, method, url string, store *sessions.CookieStore, login string) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("Error making request: %v", err)
+	}
+	if login != "" {
+		session, err := sessions.GetRegistry(req).Get(store, "access-token-session")
+		if err != nil {
+			t.Fatalf("Error making access token session: %v", err)
+		}
+		session.Values["access-token"] = &oauth2.Token{AccessToken: "validtoken"}
+	}
+	return req
+}
+
+func TestHandleAbort(t *testing.T) {
+	testCases := []struct {
+		name                string
+		method              string
+		disabled            bool
+		state               prowapi.ProwJobState
+		policy              *rbac.Policy
+		allowAnyone         bool
+		login               string
+		expectedCode        int
+		expectedDescription string
+		expectedAudit       string
+	}{
+		{
+			name:          "policy allows user to abort",
+			method:        http.MethodPost,
+			state:         prowapi.PendingState,
+			policy:        testPolicy,
+			login:         "alice",
+			expectedCode:  http.StatusOK,
+			expectedAudit: `"user":"alice","action":"abort","target":"wowsuch","scope":{"org":"org","repo":"repo","job":"whoa"},"allowed":true}`,
+
+			expectedDescription: "Aborted by alice in deck.",
+		},
+		{
+			name:          "policy does not allow user to abort",
+			method:        http.MethodPost,
+			state:         prowapi.PendingState,
+			policy:        testPolicy,
+			login:         "mallory",
+			expectedCode:  http.StatusForbidden,
+			expectedAudit: `"user":"mallory","action":"abort","target":"wowsuch","scope":{"org":"org","repo":"repo","job":"whoa"},"allowed":false}`,
+		},
+		{
+			name:          "policy requires login",
+			method:        http.MethodPost,
+			state:         prowapi.PendingState,
+			policy:        testPolicy,
+			expectedCode:  http.StatusUnauthorized,
+			expectedAudit: `"action":"abort","target":"wowsuch","scope":{"org":"org","repo":"repo","job":"whoa"},"allowed":false,"error":"login required"}`,
+		},
+		{
+			name:         "rerun auth config allows anyone to abort",
+			method:       http.MethodPost,
+			state:        prowapi.TriggeredState,
+			allowAnyone:  true,
+			expectedCode: http.StatusOK,
+
+			expectedDescription: "Aborted in deck.",
+		},
+		{
+			name:         "completed job",
+			method:       http.MethodPost,
+			state:        prowapi.SuccessState,
+			allowAnyone:  true,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "aborting is disabled",
+			method:       http.MethodPost,
+			disabled:     true,
+			state:        prowapi.PendingState,
+			allowAnyone:  true,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "bad verb",
+			method:       http.MethodGet,
+			state:        prowapi.PendingState,
+			allowAnyone:  true,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pj := &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "wowsuch", Namespace: "prowjobs"},
+				Spec: prowapi.ProwJobSpec{
+					Job:  "whoa",
+					Type: prowapi.PresubmitJob,
+					Refs: &prowapi.Refs{Org: "org", Repo: "repo"},
+				},
+				Status: prowapi.ProwJobStatus{State: tc.state},
+			}
+			if tc.state == prowapi.SuccessState {
+				pj.SetComplete()
+			}
+			fakeProwJobClient := fake.NewSimpleClientset(pj)
+			store := sessions.NewCookieStore([]byte("secret-key"))
+			var audit bytes.Buffer
+			authz := &authorizer{
+				policy: tc.policy,
+				audit:  rbac.NewAuditLog(&audit),
+				goa:    githuboauth.NewAgent(&githuboauth.Config{CookieStore: store}, logrus.WithField("client", "githuboauth")),
+				ghc:    &fakeAuthenticatedUserIdentifier{login: tc.login},
+				authCfg: func(refs *prowapi.Refs) *prowapi.RerunAuthConfig {
+					return &prowapi.RerunAuthConfig{AllowAnyone: tc.allowAnyone}
+				},
+			}
+
+			rr := httptest.NewRecorder()
+			req := loggedInRequest(t, tc.method, "/abort?prowjob=wowsuch", store, tc.login)
+			handleAbort(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), !tc.disabled, authz, logrus.WithField("handler", "/abort")).ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected code %d, got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			updated, err := fakeProwJobClient.ProwV1().ProwJobs("prowjobs").Get(context.Background(), "wowsuch", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get prowjob: %v", err)
+			}
+			if aborted := updated.Status.State == prowapi.AbortedState; aborted != (tc.expectedDescription != "") {
+				t.Errorf("unexpected state %s", updated.Status.State)
+			}
+			if updated.Status.Description != tc.expectedDescription {
+				t.Errorf("expected description %q, got %q", tc.expectedDescription, updated.Status.Description)
+			}
+			if tc.expectedAudit != "" && !strings.Contains(audit.String(), tc.expectedAudit) {
+				t.Errorf("expected audit log to contain %s, got %s", tc.expectedAudit, audit.String())
+			}
+		})
+	}
+}
+
+func TestRerunWithPolicy(t *testing.T) {
+	testCases := []struct {
+		name             string
+		login            string
+		org              string
+		expectedCode     int
+		expectedCreation bool
+	}{
+		{
+			name:             "user bound to the repo reruns",
+			login:            "alice",
+			org:              "org",
+			expectedCode:     http.StatusOK,
+			expectedCreation: true,
+		},
+		{
+			name:         "user bound to another repo",
+			login:        "alice",
+			org:          "other-org",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "anonymous user",
+			org:          "org",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeProwJobClient := fake.NewSimpleClientset(&prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "wowsuch", Namespace: "prowjobs"},
+				Spec: prowapi.ProwJobSpec{
+					Job:  "whoa",
+					Type: prowapi.PostsubmitJob,
+					Refs: &prowapi.Refs{Org: tc.org, Repo: "repo"},
+					// The policy replaces the rerun auth configs.
+					RerunAuthConfig: &prowapi.RerunAuthConfig{AllowAnyone: true},
+				},
+				Status: prowapi.ProwJobStatus{State: prowapi.SuccessState},
+			})
+			store := sessions.NewCookieStore([]byte("secret-key"))
+			authz := &authorizer{
+				policy: testPolicy,
+				audit:  rbac.NewAuditLog(&bytes.Buffer{}),
+				goa:    githuboauth.NewAgent(&githuboauth.Config{CookieStore: store}, logrus.WithField("client", "githuboauth")),
+				ghc:    &fakeAuthenticatedUserIdentifier{login: tc.login},
+			}
+
+			rr := httptest.NewRecorder()
+			req := loggedInRequest(t, http.MethodPost, "/rerun?prowjob=wowsuch", store, tc.login)
+			handleRerun(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), true, authz, logrus.WithField("handler", "/rerun")).ServeHTTP(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("expected code %d, got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+			}
+			pjs, err := fakeProwJobClient.ProwV1().ProwJobs("prowjobs").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list prowjobs: %v", err)
+			}
+			if created := len(pjs.Items) == 2; created != tc.expectedCreation {
+				t.Errorf("expected rerun to be created: %t, got %d prowjobs", tc.expectedCreation, len(pjs.Items))
+			}
+		})
+	}
+}
+
+func TestFilterPrivateJobs(t *testing.T) {
+	pjs := []prowapi.ProwJob{
+		{ObjectMeta: metav1.ObjectMeta{Name: "public"}, Spec: prowapi.ProwJobSpec{Job: "public", Refs: &prowapi.Refs{Org: "org", Repo: "repo"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hidden"}, Spec: prowapi.ProwJobSpec{Job: "hidden", Hidden: true}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hidden-repo"}, Spec: prowapi.ProwJobSpec{Job: "hidden-repo", ExtraRefs: []prowapi.Refs{{Org: "secret", Repo: "repo"}}}},
+	}
+	testCases := []struct {
+		name     string
+		authz    *authorizer
+		expected []string
+	}{
+		{
+			name:     "no authorizer",
+			expected: []string{"public", "hidden", "hidden-repo"},
+		},
+		{
+			name:     "no policy",
+			authz:    &authorizer{hiddenRepos: func() []string { return []string{"secret"} }},
+			expected: []string{"public", "hidden", "hidden-repo"},
+		},
+		{
+			name:     "policy hides private jobs from users not logged in with OIDC",
+			authz:    &authorizer{policy: testPolicy, hiddenRepos: func() []string { return []string{"secret"} }},
+			expected: []string{"public"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/prowjobs.js", nil)
+			var actual []string
+			for _, pj := range tc.authz.filterPrivateJobs(req, pjs) {
+				actual = append(actual, pj.Name)
+			}
+			if strings.Join(actual, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected jobs %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+type fakeRunFinder map[string]*prowapi.ProwJob
+
+func (f fakeRunFinder) ResolveSymlink(src string) (string, error) {
+	return strings.TrimSuffix(src, ".txt"), nil
+}
+
+func (f fakeRunFinder) ProwJob(_ context.Context, src string) (*prowapi.ProwJob, error) {
+	if src == "gs/bucket/broken" {
+		return nil, errors.New("malformed prowjob.json")
+	}
+	return f[src], nil
+}
+
+func TestCanViewRun(t *testing.T) {
+	runs := fakeRunFinder{
+		"gs/bucket/logs/public/1": {Spec: prowapi.ProwJobSpec{Job: "public"}},
+		"gs/bucket/logs/hidden/1": {Spec: prowapi.ProwJobSpec{Job: "hidden", Hidden: true}},
+	}
+	testCases := []struct {
+		name        string
+		authz       *authorizer
+		src         string
+		expected    bool
+		expectedErr bool
+	}{
+		{
+			name:     "no policy",
+			authz:    &authorizer{hiddenRepos: func() []string { return nil }},
+			src:      "gs/bucket/logs/hidden/1",
+			expected: true,
+		},
+		{
+			name:     "public run",
+			authz:    &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }},
+			src:      "gs/bucket/logs/public/1",
+			expected: true,
+		},
+		{
+			name:  "private run",
+			authz: &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }},
+			src:   "gs/bucket/logs/hidden/1",
+		},
+		{
+			name:  "symlink to a private run",
+			authz: &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }},
+			src:   "gs/bucket/logs/hidden/1.txt",
+		},
+		{
+			name:  "run without a ProwJob cannot be told to be public",
+			authz: &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }},
+			src:   "gs/bucket/logs/old/1",
+		},
+		{
+			name:     "run without a ProwJob and without a policy",
+			authz:    &authorizer{hiddenRepos: func() []string { return nil }},
+			src:      "gs/bucket/logs/old/1",
+			expected: true,
+		},
+		{
+			name:        "broken ProwJob",
+			authz:       &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }},
+			src:         "gs/bucket/broken",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/view/"+tc.src, nil)
+			actual, err := tc.authz.canViewRun(req, runs, tc.src)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected visible to be %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
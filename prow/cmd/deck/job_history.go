@@ -21,11 +21,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/url"
 	"path"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -37,7 +34,9 @@ import (
 	"k8s.io/test-infra/prow/config"
 	pkgio "k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/jobhistory"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/retention"
 )
 
 const (
@@ -47,15 +46,11 @@ const (
 
 	// ** Job history assumes the GCS layout specified here:
 	// https://github.com/kubernetes/test-infra/tree/master/gubernator#gcs-bucket-layout
-	logsPrefix     = gcs.NonPRLogs
+	logsPrefix     = jobhistory.LogsPrefix
 	spyglassPrefix = "/view"
 	emptyID        = int64(-1) // indicates no build id was specified
 )
 
-var (
-	linkRe = regexp.MustCompile("/([0-9]+)\\.txt$")
-)
-
 type buildData struct {
 	index        int
 	jobName      string
@@ -103,13 +98,12 @@ type jobHistoryTemplate struct {
 	Builds       []buildData
 }
 
+func (bucket blobStorageBucket) history() jobhistory.Bucket {
+	return jobhistory.NewBucket(bucket.name, bucket.storageProvider, bucket.Opener)
+}
+
 func (bucket blobStorageBucket) readObject(ctx context.Context, key string) ([]byte, error) {
-	rc, err := bucket.Opener.Reader(ctx, fmt.Sprintf("%s://%s/%s", bucket.storageProvider, bucket.name, key))
-	if err != nil {
-		return nil, fmt.Errorf("creating reader for object %s: %w", key, err)
-	}
-	defer rc.Close()
-	return ioutil.ReadAll(rc)
+	return bucket.history().ReadObject(ctx, key)
 }
 
 func (bucket blobStorageBucket) getName() string {
@@ -133,23 +127,6 @@ func readLatestBuild(ctx context.Context, bucket storageBucket, root string) (in
 	return n, nil
 }
 
-// resolve symlinks into the actual log directory for a particular test run, e.g.:
-// * input:  gs://prow-artifacts/pr-logs/pull/cluster-api-provider-openstack/1687/bazel-build/1248207834168954881
-// * output: pr-logs/pull/cluster-api-provider-openstack/1687/bazel-build/1248207834168954881
-func (bucket blobStorageBucket) resolveSymLink(ctx context.Context, symLink string) (string, error) {
-	data, err := bucket.readObject(ctx, symLink)
-	if err != nil {
-		return "", fmt.Errorf("failed to read %s: %w", symLink, err)
-	}
-	// strip gs://<bucket-name> from global address `u`
-	u := strings.TrimSpace(string(data))
-	parsedURL, err := url.Parse(u)
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimPrefix(parsedURL.Path, "/"), nil
-}
-
 func (bucket blobStorageBucket) spyglassLink(ctx context.Context, root, id string) (string, error) {
 	p, err := bucket.getPath(ctx, root, id, "")
 	if err != nil {
@@ -159,15 +136,7 @@ func (bucket blobStorageBucket) spyglassLink(ctx context.Context, root, id strin
 }
 
 func (bucket blobStorageBucket) getPath(ctx context.Context, root, id, fname string) (string, error) {
-	if strings.HasPrefix(root, logsPrefix) {
-		return path.Join(root, id, fname), nil
-	}
-	symLink := path.Join(root, id+".txt")
-	dir, err := bucket.resolveSymLink(ctx, symLink)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve sym link: %w", err)
-	}
-	return path.Join(dir, fname), nil
+	return bucket.history().GetPath(ctx, root, id, fname)
 }
 
 // reads specified JSON file in to `data`
@@ -185,89 +154,17 @@ func readJSON(ctx context.Context, bucket storageBucket, key string, data interf
 
 // Lists the "directory paths" immediately under prefix.
 func (bucket blobStorageBucket) listSubDirs(ctx context.Context, prefix string) ([]string, error) {
-	if !strings.HasSuffix(prefix, "/") {
-		prefix += "/"
-	}
-	it, err := bucket.Opener.Iterator(ctx, fmt.Sprintf("%s://%s/%s", bucket.storageProvider, bucket.name, prefix), "/")
-	if err != nil {
-		return nil, err
-	}
-
-	dirs := []string{}
-	for {
-		attrs, err := it.Next(ctx)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return dirs, err
-		}
-		if attrs.IsDir {
-			dirs = append(dirs, attrs.Name)
-		}
-	}
-	return dirs, nil
+	return bucket.history().ListSubDirs(ctx, prefix)
 }
 
 // Lists all keys with given prefix.
 func (bucket blobStorageBucket) listAll(ctx context.Context, prefix string) ([]string, error) {
-	if !strings.HasSuffix(prefix, "/") {
-		prefix = prefix + "/"
-	}
-	it, err := bucket.Opener.Iterator(ctx, fmt.Sprintf("%s://%s/%s", bucket.storageProvider, bucket.name, prefix), "")
-	if err != nil {
-		return nil, err
-	}
-
-	keys := []string{}
-	for {
-		attrs, err := it.Next(ctx)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return keys, err
-		}
-		keys = append(keys, attrs.Name)
-	}
-	return keys, nil
+	return bucket.history().ListAll(ctx, prefix)
 }
 
 // Gets all build ids for a job.
 func (bucket blobStorageBucket) listBuildIDs(ctx context.Context, root string) ([]int64, error) {
-	var ids []int64
-	if strings.HasPrefix(root, logsPrefix) {
-		dirs, listErr := bucket.listSubDirs(ctx, root)
-		for _, dir := range dirs {
-			leaf := path.Base(dir)
-			i, err := strconv.ParseInt(leaf, 10, 64)
-			if err == nil {
-				ids = append(ids, i)
-			} else {
-				logrus.WithField("gcs-path", dir).Warningf("unrecognized directory name (expected int64): %s", leaf)
-			}
-		}
-		if listErr != nil {
-			return ids, fmt.Errorf("failed to list directories: %w", listErr)
-		}
-	} else {
-		keys, listErr := bucket.listAll(ctx, root)
-		for _, key := range keys {
-			matches := linkRe.FindStringSubmatch(key)
-			if len(matches) == 2 {
-				i, err := strconv.ParseInt(matches[1], 10, 64)
-				if err == nil {
-					ids = append(ids, i)
-				} else {
-					logrus.Warningf("unrecognized file name (expected <int64>.txt): %s", key)
-				}
-			}
-		}
-		if listErr != nil {
-			return ids, fmt.Errorf("failed to list keys: %w", listErr)
-		}
-	}
-	return ids, nil
+	return bucket.history().ListBuildIDs(ctx, root)
 }
 
 // parseJobHistURL parses the job History URL
@@ -347,6 +244,13 @@ func getBuildData(ctx context.Context, bucket storageBucket, dir string) (buildD
 	started := gcs.Started{}
 	err := readJSON(ctx, bucket, path.Join(dir, prowv1.StartedStatusFile), &started)
 	if err != nil {
+		// started.json may have been deleted along with the other artifacts of the run.
+		expired := retention.Expired{}
+		if readJSON(ctx, bucket, path.Join(dir, retention.ExpiredFile), &expired) == nil {
+			b.Result = "Expired"
+			b.Started = expired.Started
+			return b, nil
+		}
 		return b, fmt.Errorf("failed to read started.json: %v", err)
 	}
 	b.Started = time.Unix(started.Timestamp, 0)
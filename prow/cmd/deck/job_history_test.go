@@ -18,7 +18,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"net/url"
 	"reflect"
 	"testing"
@@ -298,6 +297,11 @@ func Test_getJobHistory(t *testing.T) {
 			Name:       "logs/post-cluster-api-provider-openstack-push-images/1253687771944456193/finished.json",
 			Content:    []byte("{\"timestamp\": 1587738205,\"passed\": true,\"result\": \"SUCCESS\",\"revision\": \"b62656cde943aef3bcd1a18064aecff8b0f30a0c\"}"),
 		},
+		{
+			BucketName: "kubernetes-jenkins",
+			Name:       "logs/post-cluster-api-provider-openstack-push-images/1221704015146913792/artifacts-expired.json",
+			Content:    []byte("{\"time\": \"2020-04-26T00:00:00Z\",\"started\": \"2020-01-27T07:58:59Z\",\"max_age\": \"2160h0m0s\"}"),
+		},
 	}
 	wantedPRLogsJobHistoryTemplate := jobHistoryTemplate{
 		Name:         "pr-logs/directory/pull-test-infra-bazel",
@@ -326,8 +330,8 @@ func Test_getJobHistory(t *testing.T) {
 	}
 	wantedLogsJobHistoryTemplate := jobHistoryTemplate{
 		Name:         "logs/post-cluster-api-provider-openstack-push-images",
-		ResultsShown: 1,
-		ResultsTotal: 1,
+		ResultsShown: 2,
+		ResultsTotal: 2,
 		Builds: []buildData{
 			{
 				index:        0,
@@ -338,6 +342,14 @@ func Test_getJobHistory(t *testing.T) {
 				Result:       "SUCCESS",
 				commitHash:   "b62656cde943aef3bcd1a18064aecff8b0f30a0c",
 			},
+			{
+				index:        1,
+				SpyglassLink: "/view/gs/kubernetes-jenkins/logs/post-cluster-api-provider-openstack-push-images/1221704015146913792",
+				ID:           "1221704015146913792",
+				Started:      time.Unix(1580111939, 0).UTC(),
+				Result:       "Expired",
+				commitHash:   "Unknown",
+			},
 		},
 	}
 	gcsServer := fakestorage.NewServer(objects)
@@ -401,57 +413,3 @@ func Test_getJobHistory(t *testing.T) {
 		})
 	}
 }
-
-// TestListBuildIDsReturnsResultsOnError verifies that we get results even when there was an error,
-// mostly important so we can timeout it and still get some results.
-func TestListBuildIDsReturnsResultsOnError(t *testing.T) {
-	t.Run("logs-prefix", func(t *testing.T) {
-		bucket := blobStorageBucket{Opener: fakeOpener{iterator: fakeIterator{
-			result: io.ObjectAttributes{Name: "1327350934719696896", IsDir: true},
-			err:    errors.New("some-err"),
-		}}}
-		ids, err := bucket.listBuildIDs(context.Background(), logsPrefix)
-		if err == nil || err.Error() != "failed to list directories: some-err" {
-			t.Fatalf("didn't get expected error message 'failed to list directories: some-err' but got err %v", err)
-		}
-		if n := len(ids); n != 1 {
-			t.Errorf("didn't get result back, ids were %v", ids)
-		}
-	})
-	t.Run("no-prefix", func(t *testing.T) {
-		bucket := blobStorageBucket{Opener: fakeOpener{iterator: fakeIterator{
-			result: io.ObjectAttributes{Name: "/1327350934719696896.txt", IsDir: false},
-			err:    errors.New("some-err"),
-		}}}
-		ids, err := bucket.listBuildIDs(context.Background(), "")
-		if err == nil || err.Error() != "failed to list keys: some-err" {
-			t.Fatalf("didn't get expected error message 'failed to list keys: some-err' but got err %v", err)
-		}
-		if n := len(ids); n != 1 {
-			t.Errorf("didn't get result back, ids were %v", ids)
-		}
-	})
-}
-
-type fakeIterator struct {
-	ranOnce bool
-	result  io.ObjectAttributes
-	err     error
-}
-
-func (fi *fakeIterator) Next(_ context.Context) (io.ObjectAttributes, error) {
-	if !fi.ranOnce {
-		fi.ranOnce = true
-		return fi.result, nil
-	}
-	return io.ObjectAttributes{}, fi.err
-}
-
-type fakeOpener struct {
-	io.Opener
-	iterator fakeIterator
-}
-
-func (fo fakeOpener) Iterator(_ context.Context, _, _ string) (io.ObjectIterator, error) {
-	return &fo.iterator, nil
-}
@@ -38,7 +38,6 @@ import (
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/gorilla/csrf"
-	"github.com/gorilla/sessions"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -56,6 +55,7 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/deck/jobs"
+	"k8s.io/test-infra/prow/deck/rbac"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/git/v2"
 	prowgithub "k8s.io/test-infra/prow/github"
@@ -70,6 +70,7 @@ import (
 	"k8s.io/test-infra/prow/plugins"
 	"k8s.io/test-infra/prow/plugins/trigger"
 	"k8s.io/test-infra/prow/prstatus"
+	"k8s.io/test-infra/prow/retention"
 	"k8s.io/test-infra/prow/simplifypath"
 	"k8s.io/test-infra/prow/spyglass"
 	spyglassapi "k8s.io/test-infra/prow/spyglass/api"
@@ -84,6 +85,7 @@ import (
 	_ "k8s.io/test-infra/prow/spyglass/lenses/links"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/metadata"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/podinfo"
+	_ "k8s.io/test-infra/prow/spyglass/lenses/resourceusage"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/restcoverage"
 )
 
@@ -109,7 +111,10 @@ type options struct {
 	hookURL               string
 	oauthURL              string
 	githubOAuthConfigFile string
+	oidcConfigFile        string
 	cookieSecretFile      string
+	rbacPolicyFile        string
+	auditLogFile          string
 	redirectHTTPTo        string
 	hiddenOnly            bool
 	pregeneratedData      string
@@ -158,6 +163,9 @@ func (o *options) Validate() error {
 			return errors.New("an OAuth URL was provided but required flag --cookie-secret was unset")
 		}
 	}
+	if o.oidcConfigFile != "" && o.cookieSecretFile == "" {
+		return errors.New("an OIDC config file was provided but required flag --cookie-secret was unset")
+	}
 
 	if o.hiddenOnly && o.showHidden {
 		return errors.New("'--hidden-only' and '--show-hidden' are mutually exclusive, the first one shows only hidden job, the second one shows both hidden and non-hidden jobs")
@@ -179,7 +187,10 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.hookURL, "hook-url", "", "Path to hook plugin help endpoint.")
 	fs.StringVar(&o.oauthURL, "oauth-url", "", "Path to deck user dashboard endpoint.")
 	fs.StringVar(&o.githubOAuthConfigFile, "github-oauth-config-file", "/etc/github/secret", "Path to the file containing the GitHub App Client secret.")
+	fs.StringVar(&o.oidcConfigFile, "oidc-config-file", "", "Path to the file containing the OIDC client configuration. Enables logging in with an OIDC provider.")
 	fs.StringVar(&o.cookieSecretFile, "cookie-secret", "", "Path to the file containing the cookie secret key.")
+	fs.StringVar(&o.rbacPolicyFile, "rbac-policy-file", "", "Path to the policy that grants users and groups permissions like rerunning and aborting jobs. Replaces the rerun auth configs when set.")
+	fs.StringVar(&o.auditLogFile, "audit-log-file", "", "Path to the file privileged actions are recorded in. Defaults to the log of deck.")
 	// use when behind a load balancer
 	fs.StringVar(&o.redirectHTTPTo, "redirect-http-to", "", "Host to redirect http->https to based on x-forwarded-proto == http.")
 	// use when behind an oauth proxy
@@ -225,6 +236,7 @@ func init() {
 
 var simplifier = simplifypath.NewSimplifier(l("", // shadow element mimicing the root
 	l(""),
+	l("archived-prowjobs.js"),
 	l("badge.svg"),
 	l("command-help"),
 	l("config"),
@@ -236,6 +248,7 @@ var simplifier = simplifypath.NewSimplifier(l("", // shadow element mimicing the
 	l("job-history",
 		v("job")),
 	l("log"),
+	l("pin"),
 	l("plugin-config"),
 	l("plugin-help"),
 	l("plugins"),
@@ -317,7 +330,6 @@ func main() {
 	mux.Handle("/pr", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "pr.html", nil)))
 	mux.Handle("/command-help", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "command-help.html", nil)))
 	mux.Handle("/plugin-help", http.RedirectHandler("/command-help", http.StatusMovedPermanently))
-	mux.Handle("/tide", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "tide.html", nil)))
 	mux.Handle("/tide-history", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "tide-history.html", nil)))
 	mux.Handle("/plugins", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "plugins.html", nil)))
 
@@ -425,6 +437,10 @@ func main() {
 		rac := cfg().Deck.RerunAuthConfigs.GetRerunAuthConfig(refs)
 		return &rac
 	}
+	authz, err := newAuthorizer(o, cfg, authCfgGetter, githubClient, pluginAgent)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error setting up authorization.")
+	}
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -434,9 +450,11 @@ func main() {
 		indexHandler := handleSimpleTemplate(o, cfg, "index.html", struct {
 			SpyglassEnabled bool
 			ReRunCreatesJob bool
+			LoginPath       string
 		}{
 			SpyglassEnabled: o.spyglass,
-			ReRunCreatesJob: o.rerunCreatesJob})
+			ReRunCreatesJob: o.rerunCreatesJob,
+			LoginPath:       authz.loginPath()})
 		indexHandler(w, r)
 	})
 
@@ -446,23 +464,16 @@ func main() {
 	// setup prod only handlers. These handlers can work with runlocal as long
 	// as ja is properly mocked, more specifically pjListingClient inside ja
 	mux.Handle("/data.js", gziphandler.GzipHandler(handleData(ja, logrus.WithField("handler", "/data.js"))))
-	mux.Handle("/prowjobs.js", gziphandler.GzipHandler(handleProwJobs(ja, logrus.WithField("handler", "/prowjobs.js"))))
+	mux.Handle("/prowjobs.js", gziphandler.GzipHandler(handleProwJobs(ja, authz, logrus.WithField("handler", "/prowjobs.js"))))
 	mux.Handle("/badge.svg", gziphandler.GzipHandler(handleBadge(ja)))
-	mux.Handle("/log", gziphandler.GzipHandler(handleLog(ja, logrus.WithField("handler", "/log"))))
+	mux.Handle("/log", gziphandler.GzipHandler(handleLog(ja, authz, logrus.WithField("handler", "/log"))))
+	mux.Handle("/pipeline", gziphandler.GzipHandler(handlePipeline(o, cfg, ja, logrus.WithField("handler", "/pipeline"))))
+	mux.Handle("/matrix", gziphandler.GzipHandler(handleMatrix(o, cfg, ja, logrus.WithField("handler", "/matrix"))))
 
 	if o.spyglass {
-		initSpyglass(cfg, o, mux, ja, githubClient, gitClient)
-	}
-
-	if runLocal {
-		mux = localOnlyMain(cfg, o, mux)
-	} else {
-		mux = prodOnlyMain(cfg, pluginAgent, authCfgGetter, githubClient, o, mux)
+		initSpyglass(cfg, o, mux, ja, authz, githubClient, gitClient)
 	}
 
-	// signal to the world that we're ready
-	health.ServeReady()
-
 	// cookie secret will be used for CSRF protection and should be exactly 32 bytes
 	// we sometimes accept different lengths to stay backwards compatible
 	var csrfToken []byte
@@ -494,12 +505,27 @@ func main() {
 
 	// if we allow direct reruns, we must protect against CSRF in all post requests using the cookie secret as a token
 	// for more information about CSRF, see https://github.com/kubernetes/test-infra/blob/master/prow/cmd/deck/csrf.md
-	empty := prowapi.Refs{}
-	if o.rerunCreatesJob && csrfToken == nil && !authCfgGetter(&empty).IsAllowAnyone() {
+	if o.rerunCreatesJob && csrfToken == nil {
 		logrus.Fatal("Rerun creates job cannot be enabled without CSRF protection, which requires --cookie-secret to be exactly 32 bytes")
 		return
 	}
 
+	if runLocal {
+		mux = localOnlyMain(cfg, o, mux)
+	} else {
+		mux = prodOnlyMain(cfg, authz, githubClient, o, csrfToken != nil, mux)
+	}
+
+	// Blocking merges changes state and is only granted by an RBAC policy.
+	mergeBlockers := csrfToken != nil && authz.policy != nil && githubClient != nil
+	if mergeBlockers {
+		mux.Handle("/tide-merge-blocker", handleMergeBlocker(cfg, githubClient, authz, logrus.WithField("handler", "/tide-merge-blocker")))
+	}
+	mux.Handle("/tide", gziphandler.GzipHandler(handleSimpleTemplate(o, cfg, "tide.html", tidePage{cfg: cfg, mergeBlockers: mergeBlockers})))
+
+	// signal to the world that we're ready
+	health.ServeReady()
+
 	if csrfToken != nil {
 		CSRF := csrf.Protect(csrfToken, csrf.Path("/"), csrf.Secure(!o.allowInsecure))
 		logrus.WithError(http.ListenAndServe(":8080", CSRF(traceHandler(mux)))).Fatal("ListenAndServe returned.")
@@ -553,14 +579,14 @@ func (fjc *fakePjListingClientWrapper) List(ctx context.Context, pjl *prowapi.Pr
 }
 
 // prodOnlyMain contains logic only used when running deployed, not locally
-func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGetter authCfgGetter, githubClient deckGitHubClient, o options, mux *http.ServeMux) *http.ServeMux {
+func prodOnlyMain(cfg config.Getter, authz *authorizer, githubClient deckGitHubClient, o options, csrfProtected bool, mux *http.ServeMux) *http.ServeMux {
 	prowJobClient, err := o.kubernetes.ProwJobClient(cfg().ProwJobNamespace, false)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting ProwJob client for infrastructure cluster.")
 	}
 
 	// prowjob still needs prowJobClient for retrieving log
-	mux.Handle("/prowjob", gziphandler.GzipHandler(handleProwJob(prowJobClient, logrus.WithField("handler", "/prowjob"))))
+	mux.Handle("/prowjob", gziphandler.GzipHandler(handleProwJob(prowJobClient, authz, logrus.WithField("handler", "/prowjob"))))
 
 	if o.hookURL != "" {
 		mux.Handle("/plugin-help.js",
@@ -584,6 +610,7 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGe
 		ta.start()
 		mux.Handle("/tide.js", gziphandler.GzipHandler(handleTidePools(cfg, ta, logrus.WithField("handler", "/tide.js"))))
 		mux.Handle("/tide-history.js", gziphandler.GzipHandler(handleTideHistory(ta, logrus.WithField("handler", "/tide-history.js"))))
+		mux.Handle("/tide-stats.js", gziphandler.GzipHandler(handleTideStats(ta, logrus.WithField("handler", "/tide-stats.js"))))
 	}
 
 	secure := !o.allowInsecure
@@ -599,11 +626,6 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGe
 			logrus.WithError(err).Fatal("Could not read github oauth config file.")
 		}
 
-		cookieSecretRaw, err := loadToken(o.cookieSecretFile)
-		if err != nil {
-			logrus.WithError(err).Fatal("Could not read cookie secret file.")
-		}
-
 		var githubOAuthConfig githuboauth.Config
 		if err := yaml.Unmarshal(githubOAuthConfigRaw, &githubOAuthConfig); err != nil {
 			logrus.WithError(err).Fatal("Error unmarshalling github oauth config")
@@ -612,14 +634,10 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGe
 			logrus.Fatal("Error invalid github oauth config")
 		}
 
-		decodedSecret, err := base64.StdEncoding.DecodeString(string(cookieSecretRaw))
+		cookie, err := loadCookieStore(o.cookieSecretFile)
 		if err != nil {
-			logrus.WithError(err).Fatal("Error decoding cookie secret")
-		}
-		if len(decodedSecret) == 0 {
-			logrus.Fatal("Cookie secret should not be empty")
+			logrus.WithError(err).Fatal("Error loading cookie secret")
 		}
-		cookie := sessions.NewCookieStore(decodedSecret)
 		githubOAuthConfig.InitGitHubOAuthConfig(cookie)
 
 		goa = githuboauth.NewAgent(&githubOAuthConfig, logrus.WithField("client", "githuboauth"))
@@ -651,9 +669,28 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGe
 		mux.Handle("/github-login", goa.HandleLogin(oauthClient, secure))
 		// Handles redirect from GitHub OAuth server.
 		mux.Handle("/github-login/redirect", goa.HandleRedirect(oauthClient, githuboauth.NewAuthenticatedUserIdentifier(&o.github), secure))
+		authz.goa = goa
+		authz.ghc = githuboauth.NewAuthenticatedUserIdentifier(&o.github)
+	}
+	if authz.oidc != nil {
+		mux.Handle("/oidc-login", authz.oidc.HandleLogin(secure))
+		mux.Handle("/oidc-login/redirect", authz.oidc.HandleRedirect(secure))
+		mux.Handle("/oidc-logout", authz.oidc.HandleLogout(secure))
 	}
 
-	mux.Handle("/rerun", gziphandler.GzipHandler(handleRerun(prowJobClient, o.rerunCreatesJob, authCfgGetter, goa, githuboauth.NewAuthenticatedUserIdentifier(&o.github), githubClient, pluginAgent, logrus.WithField("handler", "/rerun"))))
+	mux.Handle("/rerun", gziphandler.GzipHandler(handleRerun(prowJobClient, o.rerunCreatesJob, authz, logrus.WithField("handler", "/rerun"))))
+	mux.Handle("/abort", gziphandler.GzipHandler(handleAbort(prowJobClient, o.rerunCreatesJob, authz, logrus.WithField("handler", "/abort"))))
+
+	if o.spyglass && !csrfProtected {
+		logrus.Warning("Pinning runs is disabled as it requires CSRF protection, which requires --cookie-secret to be at least 32 bytes")
+	} else if o.spyglass {
+		// Pins are written next to the artifacts, so this opener needs write access.
+		opener, err := io.NewOpener(context.Background(), o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating opener")
+		}
+		mux.Handle("/pin", handlePin(cfg, opener, authz, logrus.WithField("handler", "/pin")))
+	}
 
 	// optionally inject http->https redirect handler when behind loadbalancer
 	if o.redirectHTTPTo != "" {
@@ -681,7 +718,7 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, authCfgGe
 	return mux
 }
 
-func initSpyglass(cfg config.Getter, o options, mux *http.ServeMux, ja *jobs.JobAgent, gitHubClient deckGitHubClient, gitClient git.ClientFactory) {
+func initSpyglass(cfg config.Getter, o options, mux *http.ServeMux, ja *jobs.JobAgent, authz *authorizer, gitHubClient deckGitHubClient, gitClient git.ClientFactory) {
 	ctx := context.TODO()
 	opener, err := io.NewOpener(ctx, o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
 	if err != nil {
@@ -691,16 +728,17 @@ func initSpyglass(cfg config.Getter, o options, mux *http.ServeMux, ja *jobs.Job
 	sg.Start()
 
 	mux.Handle("/spyglass/static/", http.StripPrefix("/spyglass/static", staticHandlerFromDir(o.spyglassFilesLocation)))
-	mux.Handle("/spyglass/lens/", gziphandler.GzipHandler(http.StripPrefix("/spyglass/lens/", handleArtifactView(o, sg, cfg))))
-	mux.Handle("/view/", gziphandler.GzipHandler(handleRequestJobViews(sg, cfg, o, logrus.WithField("handler", "/view"))))
+	mux.Handle("/spyglass/lens/", gziphandler.GzipHandler(http.StripPrefix("/spyglass/lens/", handleArtifactView(o, sg, cfg, authz))))
+	mux.Handle("/view/", gziphandler.GzipHandler(handleRequestJobViews(sg, cfg, o, authz, logrus.WithField("handler", "/view"))))
 	mux.Handle("/job-history/", gziphandler.GzipHandler(handleJobHistory(o, cfg, opener, logrus.WithField("handler", "/job-history"))))
 	mux.Handle("/pr-history/", gziphandler.GzipHandler(handlePRHistory(o, cfg, opener, gitHubClient, gitClient, logrus.WithField("handler", "/pr-history"))))
-	if err := initLocalLensHandler(cfg, o, sg); err != nil {
+	mux.Handle("/archived-prowjobs.js", gziphandler.GzipHandler(handleArchivedProwJobs(cfg, opener, authz, o.hiddenOnly, o.showHidden, logrus.WithField("handler", "/archived-prowjobs.js"))))
+	if err := initLocalLensHandler(cfg, o, sg, opener); err != nil {
 		logrus.WithError(err).Fatal("Failed to initialize local lens handler")
 	}
 }
 
-func initLocalLensHandler(cfg config.Getter, o options, sg *spyglass.Spyglass) error {
+func initLocalLensHandler(cfg config.Getter, o options, sg *spyglass.Spyglass, opener io.Opener) error {
 	var localLenses []common.LensWithConfiguration
 	for _, lfc := range cfg().Deck.Spyglass.Lenses {
 		if !strings.HasPrefix(strings.TrimLeft(lfc.RemoteConfig.Endpoint, "http://"), spyglassLocalLensListenerAddr) {
@@ -721,7 +759,7 @@ func initLocalLensHandler(cfg config.Getter, o options, sg *spyglass.Spyglass) e
 		})
 	}
 
-	lensServer, err := common.NewLensServer(spyglassLocalLensListenerAddr, sg.JobAgent, sg.StorageArtifactFetcher, sg.PodLogArtifactFetcher, cfg, localLenses)
+	lensServer, err := common.NewLensServer(spyglassLocalLensListenerAddr, sg.JobAgent, sg.StorageArtifactFetcher, sg.PodLogArtifactFetcher, cfg, opener, localLenses)
 	if err != nil {
 		return fmt.Errorf("constructing local lens server: %w", err)
 	}
@@ -791,41 +829,11 @@ func handleNotCached(next http.Handler) http.HandlerFunc {
 	}
 }
 
-func handleProwJobs(ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
+func handleProwJobs(ja *jobs.JobAgent, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
-		jobs := ja.ProwJobs()
-		omit := r.URL.Query().Get("omit")
-
-		if set := sets.NewString(strings.Split(omit, ",")...); set.Len() > 0 {
-			for i := range jobs {
-				jobs[i].ManagedFields = nil
-				if set.Has(Annotations) {
-					jobs[i].Annotations = nil
-				}
-				if set.Has(Labels) {
-					jobs[i].Labels = nil
-				}
-				if set.Has(DecorationConfig) {
-					jobs[i].Spec.DecorationConfig = nil
-				}
-				if set.Has(PodSpec) {
-					// when we omit the podspec, we don't set it completely to nil
-					// instead, we set it to a new podspec that just has an empty container for each container that exists in the actual podspec
-					// this is so we can determine how many containers there are for a given prowjob without fetching all of the podspec details
-					// this is necessary for prow/cmd/deck/static/prow/prow.ts to determine whether the logIcon should link to a log endpoint or to spyglass
-					if jobs[i].Spec.PodSpec != nil {
-						emptyContainers := []coreapi.Container{}
-						for range jobs[i].Spec.PodSpec.Containers {
-							emptyContainers = append(emptyContainers, coreapi.Container{})
-						}
-						jobs[i].Spec.PodSpec = &coreapi.PodSpec{
-							Containers: emptyContainers,
-						}
-					}
-				}
-			}
-		}
+		jobs := authz.filterPrivateJobs(r, ja.ProwJobs())
+		omitProwJobFields(jobs, r.URL.Query().Get("omit"))
 
 		jd, err := json.Marshal(struct {
 			Items []prowapi.ProwJob `json:"items"`
@@ -838,6 +846,40 @@ func handleProwJobs(ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
 	}
 }
 
+// omitProwJobFields drops the fields listed in omit, a comma-separated list,
+// from the given ProwJobs to make responses smaller.
+func omitProwJobFields(jobs []prowapi.ProwJob, omit string) {
+	if set := sets.NewString(strings.Split(omit, ",")...); set.Len() > 0 {
+		for i := range jobs {
+			jobs[i].ManagedFields = nil
+			if set.Has(Annotations) {
+				jobs[i].Annotations = nil
+			}
+			if set.Has(Labels) {
+				jobs[i].Labels = nil
+			}
+			if set.Has(DecorationConfig) {
+				jobs[i].Spec.DecorationConfig = nil
+			}
+			if set.Has(PodSpec) {
+				// when we omit the podspec, we don't set it completely to nil
+				// instead, we set it to a new podspec that just has an empty container for each container that exists in the actual podspec
+				// this is so we can determine how many containers there are for a given prowjob without fetching all of the podspec details
+				// this is necessary for prow/cmd/deck/static/prow/prow.ts to determine whether the logIcon should link to a log endpoint or to spyglass
+				if jobs[i].Spec.PodSpec != nil {
+					emptyContainers := []coreapi.Container{}
+					for range jobs[i].Spec.PodSpec.Containers {
+						emptyContainers = append(emptyContainers, coreapi.Container{})
+					}
+					jobs[i].Spec.PodSpec = &coreapi.PodSpec{
+						Containers: emptyContainers,
+					}
+				}
+			}
+		}
+	}
+}
+
 func handleData(ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
@@ -939,14 +981,18 @@ func handlePRHistory(o options, cfg config.Getter, opener io.Opener, gitHubClien
 // Examples:
 // - /view/gcs/kubernetes-jenkins/pr-logs/pull/test-infra/9557/pull-test-infra-verify-gofmt/15688/
 // - /view/prowjob/echo-test/1046875594609922048
-func handleRequestJobViews(sg *spyglass.Spyglass, cfg config.Getter, o options, log *logrus.Entry) http.HandlerFunc {
+func handleRequestJobViews(sg *spyglass.Spyglass, cfg config.Getter, o options, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		setHeadersNoCaching(w)
 		src := strings.TrimPrefix(r.URL.Path, "/view/")
+		if !checkRunVisible(w, r, sg, authz, src, log) {
+			return
+		}
 
 		csrfToken := csrf.Token(r)
-		page, err := renderSpyglass(r.Context(), sg, cfg, src, o, csrfToken, log)
+		// Runs can only be pinned with CSRF protection.
+		page, err := renderSpyglass(r.Context(), sg, cfg, src, o, csrfToken, csrfToken != "" && authz.pinEnabled(cfg), log)
 		if err != nil {
 			msg := fmt.Sprintf("error rendering spyglass page: %v", err)
 			if shouldLogHTTPErrors(err) {
@@ -966,8 +1012,28 @@ func handleRequestJobViews(sg *spyglass.Spyglass, cfg config.Getter, o options,
 	}
 }
 
+// checkRunVisible answers the request with an error unless the requester
+// may see the run given by src.
+func checkRunVisible(w http.ResponseWriter, r *http.Request, sg *spyglass.Spyglass, authz *authorizer, src string, log *logrus.Entry) bool {
+	visible, err := authz.canViewRun(r, sg, src)
+	if err != nil {
+		msg := fmt.Sprintf("error finding the ProwJob of the run: %v", err)
+		if shouldLogHTTPErrors(err) {
+			log.WithError(err).Error(msg)
+		}
+		http.Error(w, msg, httpStatusForError(err))
+		return false
+	}
+	if !visible {
+		// Don't reveal that the run exists.
+		http.Error(w, fmt.Sprintf("Run not found: %s", src), http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
 // renderSpyglass returns a pre-rendered Spyglass page from the given source string
-func renderSpyglass(ctx context.Context, sg *spyglass.Spyglass, cfg config.Getter, src string, o options, csrfToken string, log *logrus.Entry) (string, error) {
+func renderSpyglass(ctx context.Context, sg *spyglass.Spyglass, cfg config.Getter, src string, o options, csrfToken string, pinEnabled bool, log *logrus.Entry) (string, error) {
 	renderStart := time.Now()
 
 	src = strings.TrimSuffix(src, "/")
@@ -1103,6 +1169,13 @@ lensesLoop:
 		extraLinks = nil
 	}
 
+	expired, pin, err := sg.Retention(ctx, src)
+	if err != nil {
+		log.WithError(err).WithField("page", src).Warn("Failed to fetch retention state")
+	}
+	// Only runs in storage can be pinned, not the ProwJobs they were started from.
+	canPin := pinEnabled && !strings.HasPrefix(src, spyglassapi.ProwKeyType+"/")
+
 	var viewBuf bytes.Buffer
 	type lensesTemplate struct {
 		Lenses        map[int]spyglass.LensConfig
@@ -1119,6 +1192,9 @@ lensesLoop:
 		BuildID       string
 		PRLink        string
 		ExtraLinks    []spyglass.ExtraLink
+		Expired       *retention.Expired
+		Pin           *retention.Pin
+		CanPin        bool
 	}
 	lTmpl := lensesTemplate{
 		Lenses:        ls,
@@ -1135,6 +1211,9 @@ lensesLoop:
 		BuildID:       buildID,
 		PRLink:        prLink,
 		ExtraLinks:    extraLinks,
+		Expired:       expired,
+		Pin:           pin,
+		CanPin:        canPin,
 	}
 	t := template.New("spyglass.html")
 
@@ -1162,7 +1241,7 @@ lensesLoop:
 // Query params:
 // - name: required, specifies the name of the viewer to load
 // - src: required, specifies the job source from which to fetch artifacts
-func handleArtifactView(o options, sg *spyglass.Spyglass, cfg config.Getter) http.HandlerFunc {
+func handleArtifactView(o options, sg *spyglass.Spyglass, cfg config.Getter, authz *authorizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
 		pathSegments := strings.Split(r.URL.Path, "/")
@@ -1195,6 +1274,9 @@ func handleArtifactView(o options, sg *spyglass.Spyglass, cfg config.Getter) htt
 			http.Error(w, fmt.Sprintf("Failed to process request: %v", err), httpStatusForError(err))
 			return
 		}
+		if !checkRunVisible(w, r, sg, authz, request.Source, logrus.WithField("handler", "/spyglass/lens")) {
+			return
+		}
 
 		handleRemoteLens(*lens, w, r, resource, request)
 	}
@@ -1313,11 +1395,12 @@ func handlePluginHelp(ha *helpAgent, log *logrus.Entry) http.HandlerFunc {
 }
 
 type logClient interface {
+	GetProwJob(job, id string) (prowapi.ProwJob, error)
 	GetJobLog(job, id, container string) ([]byte, error)
 }
 
 // TODO(spxtr): Cache, rate limit.
-func handleLog(lc logClient, log *logrus.Entry) http.HandlerFunc {
+func handleLog(lc logClient, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -1332,6 +1415,14 @@ func handleLog(lc logClient, log *logrus.Entry) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if authz != nil && authz.policy != nil {
+			pj, err := lc.GetProwJob(job, id)
+			if err != nil || !authz.canView(r, &pj) {
+				// Don't reveal that the job exists.
+				http.Error(w, "Log not found.", http.StatusNotFound)
+				return
+			}
+		}
 		jobLog, err := lc.GetJobLog(job, id, container)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Log not found: %v", err), http.StatusNotFound)
@@ -1370,7 +1461,7 @@ func validateLogRequest(r *http.Request) error {
 	return nil
 }
 
-func handleProwJob(prowJobClient prowv1.ProwJobInterface, log *logrus.Entry) http.HandlerFunc {
+func handleProwJob(prowJobClient prowv1.ProwJobInterface, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := r.URL.Query().Get("prowjob")
 		l := log.WithField("prowjob", name)
@@ -1388,6 +1479,11 @@ func handleProwJob(prowJobClient prowv1.ProwJobInterface, log *logrus.Entry) htt
 			}
 			return
 		}
+		if !authz.canView(r, pj) {
+			// Don't reveal that the job exists.
+			http.Error(w, fmt.Sprintf("ProwJob not found: %s", name), http.StatusNotFound)
+			return
+		}
 		handleSerialize(w, "prowjob", pj, l)
 	}
 }
@@ -1442,7 +1538,7 @@ func canTriggerJob(user string, pj prowapi.ProwJob, cfg *prowapi.RerunAuthConfig
 // handleRerun triggers a rerun of the given job if that features is enabled, it receives a
 // POST request, and the user has the necessary permissions. Otherwise, it writes the config
 // for a new job but does not trigger it.
-func handleRerun(prowJobClient prowv1.ProwJobInterface, createProwJob bool, cfg authCfgGetter, goa *githuboauth.Agent, ghc githuboauth.AuthenticatedUserIdentifier, cli prowgithub.RerunClient, pluginAgent *plugins.ConfigAgent, log *logrus.Entry) http.HandlerFunc {
+func handleRerun(prowJobClient prowv1.ProwJobInterface, createProwJob bool, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := r.URL.Query().Get("prowjob")
 		l := log.WithField("prowjob", name)
@@ -1463,41 +1559,25 @@ func handleRerun(prowJobClient prowv1.ProwJobInterface, createProwJob bool, cfg
 		l = l.WithField("job", newPJ.Spec.Job)
 		switch r.Method {
 		case http.MethodGet:
+			if !authz.canView(r, pj) {
+				// Don't reveal that the job exists.
+				http.Error(w, fmt.Sprintf("ProwJob not found: %s", name), http.StatusNotFound)
+				return
+			}
 			handleSerialize(w, "prowjob", newPJ, l)
 		case http.MethodPost:
 			if !createProwJob {
 				http.Error(w, "Direct rerun feature is not enabled. Enable with the '--rerun-creates-job' flag.", http.StatusMethodNotAllowed)
 				return
 			}
-			authConfig := cfg(pj.Spec.Refs)
-			var allowed bool
-			if pj.Spec.RerunAuthConfig.IsAllowAnyone() || authConfig.IsAllowAnyone() {
-				// Skip getting the users login via GH oauth if anyone is allowed to rerun
-				// jobs so that GH oauth doesn't need to be set up for private Prows.
-				allowed = true
-			} else {
-				if goa == nil {
-					msg := "GitHub oauth must be configured to rerun jobs unless 'allow_anyone: true' is specified."
-					http.Error(w, msg, http.StatusInternalServerError)
-					l.Error(msg)
-					return
-				}
-				login, err := goa.GetLogin(r, ghc)
-				if err != nil {
-					l.WithError(err).Errorf("Error retrieving GitHub login")
-					http.Error(w, "Error retrieving GitHub login", http.StatusUnauthorized)
-					return
-				}
-				l = l.WithField("user", login)
-				allowed, err = canTriggerJob(login, newPJ, authConfig, cli, pluginAgent.Config, l)
-				if err != nil {
-					http.Error(w, fmt.Sprintf("Error checking if user can trigger job: %v", err), http.StatusInternalServerError)
-					l.WithError(err).Errorf("Error checking if user can trigger job")
-					return
-				}
+			identity, allowed, err := authz.authorizeJob(r, rbac.PermissionRerun, pj, l)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error checking if user can rerun job: %v", err), httpStatusForAuthError(err))
+				l.WithError(err).Errorf("Error checking if user can rerun job")
+				return
 			}
 
-			l = l.WithField("allowed", allowed)
+			l = l.WithFields(logrus.Fields{"user": identity.User, "allowed": allowed})
 			l.Info("Attempted rerun")
 			if !allowed {
 				if _, err = w.Write([]byte("You don't have permission to rerun that job")); err != nil {
@@ -1524,6 +1604,68 @@ func handleRerun(prowJobClient prowv1.ProwJobInterface, createProwJob bool, cfg
 	}
 }
 
+// handleAbort aborts the given job if that feature is enabled, it receives a POST request,
+// the job is still running and the user has the necessary permissions.
+func handleAbort(prowJobClient prowv1.ProwJobInterface, enabled bool, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("prowjob")
+		l := log.WithField("prowjob", name)
+		if r.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("bad verb %v", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		if !enabled {
+			http.Error(w, "Aborting jobs is not enabled. Enable with the '--rerun-creates-job' flag.", http.StatusMethodNotAllowed)
+			return
+		}
+		if name == "" {
+			http.Error(w, "request did not provide the 'prowjob' query parameter", http.StatusBadRequest)
+			return
+		}
+		pj, err := prowJobClient.Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("ProwJob not found: %v", err), http.StatusNotFound)
+			if !kerrors.IsNotFound(err) {
+				// admins only care about errors other than not found
+				l.WithError(err).Warning("ProwJob not found.")
+			}
+			return
+		}
+		l = l.WithField("job", pj.Spec.Job)
+		if pj.Complete() {
+			http.Error(w, fmt.Sprintf("Job already completed with state %s", pj.Status.State), http.StatusBadRequest)
+			return
+		}
+		identity, allowed, err := authz.authorizeJob(r, rbac.PermissionAbort, pj, l)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error checking if user can abort job: %v", err), httpStatusForAuthError(err))
+			l.WithError(err).Errorf("Error checking if user can abort job")
+			return
+		}
+		l = l.WithFields(logrus.Fields{"user": identity.User, "allowed": allowed})
+		l.Info("Attempted abort")
+		if !allowed {
+			http.Error(w, "You don't have permission to abort that job", http.StatusForbidden)
+			return
+		}
+
+		pj.Status.State = prowapi.AbortedState
+		pj.Status.Description = "Aborted in deck."
+		if identity.User != "" {
+			pj.Status.Description = fmt.Sprintf("Aborted by %s in deck.", identity.User)
+		}
+		if _, err := prowJobClient.Update(context.TODO(), pj, metav1.UpdateOptions{}); err != nil {
+			l.WithError(err).Error("Error aborting job")
+			http.Error(w, fmt.Sprintf("Error aborting job: %v", err), http.StatusInternalServerError)
+			return
+		}
+		l.Info("Successfully aborted PJ.")
+		if _, err = w.Write([]byte("Job successfully aborted.")); err != nil {
+			l.WithError(err).Error("Error writing to abort response.")
+		}
+	}
+}
+
 func handleSerialize(w http.ResponseWriter, name string, data interface{}, l *logrus.Entry) {
 	setHeadersNoCaching(w)
 	b, err := yaml.Marshal(data)
@@ -1593,6 +1735,7 @@ type deckGitHubClient interface {
 	prowgithub.RerunClient
 	GetPullRequest(org, repo string, number int) (*prowgithub.PullRequest, error)
 	GetRef(org, repo, ref string) (string, error)
+	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
 }
 
 func spglassConfigDefaulting(c *config.Config) error {
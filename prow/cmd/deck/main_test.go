@@ -49,6 +49,7 @@ import (
 	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/deck/jobs"
+	"k8s.io/test-infra/prow/deck/rbac"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/github/fakegithub"
 	"k8s.io/test-infra/prow/githuboauth"
@@ -145,6 +146,16 @@ func (f flc) GetJobLog(job, id, container string) ([]byte, error) {
 	return nil, errors.New("muahaha")
 }
 
+func (f flc) GetProwJob(job, id string) (prowapi.ProwJob, error) {
+	switch {
+	case job == "job" && id == "123":
+		return prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Job: "job"}}, nil
+	case job == "hidden" && id == "123":
+		return prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Job: "hidden", Hidden: true}}, nil
+	}
+	return prowapi.ProwJob{}, errors.New("muahaha")
+}
+
 func TestHandleLog(t *testing.T) {
 	var testcases = []struct {
 		name string
@@ -176,8 +187,14 @@ func TestHandleLog(t *testing.T) {
 			path: "?job=ohno&id=123",
 			code: http.StatusNotFound,
 		},
+		{
+			name: "private job",
+			path: "?job=hidden&id=123",
+			code: http.StatusNotFound,
+		},
 	}
-	handler := handleLog(flc(0), logrus.WithField("handler", "/log"))
+	authz := &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }}
+	handler := handleLog(flc(0), authz, logrus.WithField("handler", "/log"))
 	for _, tc := range testcases {
 		req, err := http.NewRequest(http.MethodGet, "", nil)
 		if err != nil {
@@ -277,7 +294,7 @@ func TestHandleProwJobs(t *testing.T) {
 	fakeJa := jobs.NewJobAgent(context.Background(), kc, false, true, map[string]jobs.PodLogClient{}, fca{}.Config)
 	fakeJa.Start()
 
-	handler := handleProwJobs(fakeJa, logrus.WithField("handler", "/prowjobs.js"))
+	handler := handleProwJobs(fakeJa, nil, logrus.WithField("handler", "/prowjobs.js"))
 	req, err := http.NewRequest(http.MethodGet, "/prowjobs.js?omit=annotations,labels,decoration_config,pod_spec", nil)
 	if err != nil {
 		t.Fatalf("Error making request: %v", err)
@@ -346,7 +363,7 @@ func TestProwJob(t *testing.T) {
 			State: prowapi.PendingState,
 		},
 	})
-	handler := handleProwJob(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), logrus.WithField("handler", "/prowjob"))
+	handler := handleProwJob(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), nil, logrus.WithField("handler", "/prowjob"))
 	req, err := http.NewRequest(http.MethodGet, "/prowjob?prowjob=wowsuch", nil)
 	if err != nil {
 		t.Fatalf("Error making request: %v", err)
@@ -541,7 +558,15 @@ func TestRerun(t *testing.T) {
 			rc := fakegithub.NewFakeClient()
 			rc.OrgMembers = map[string][]string{"org": {"org-member"}}
 			pca := plugins.NewFakeConfigAgent()
-			handler := handleRerun(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), tc.rerunCreatesJob, authCfgGetter, goa, ghc, rc, &pca, logrus.WithField("handler", "/rerun"))
+			authz := &authorizer{
+				audit:      rbac.NewAuditLog(ioutil.Discard),
+				goa:        goa,
+				ghc:        ghc,
+				authCfg:    authCfgGetter,
+				cli:        rc,
+				pluginsCfg: pca.Config,
+			}
+			handler := handleRerun(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), tc.rerunCreatesJob, authz, logrus.WithField("handler", "/rerun"))
 			handler.ServeHTTP(rr, req)
 			if rr.Code != tc.httpCode {
 				t.Fatalf("Bad error code: %d", rr.Code)
@@ -578,6 +603,20 @@ func TestRerun(t *testing.T) {
 	}
 }
 
+func TestRerunHidesPrivateJobs(t *testing.T) {
+	fakeProwJobClient := fake.NewSimpleClientset(&prowapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "prowjobs"},
+		Spec:       prowapi.ProwJobSpec{Job: "hidden", Type: prowapi.PeriodicJob, Hidden: true},
+	})
+	authz := &authorizer{policy: testPolicy, hiddenRepos: func() []string { return nil }}
+	handler := handleRerun(fakeProwJobClient.ProwV1().ProwJobs("prowjobs"), false, authz, logrus.WithField("handler", "/rerun"))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/rerun?prowjob=secret", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a private job, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+	}
+}
+
 func TestTide(t *testing.T) {
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pools := []tide.Pool{
@@ -784,6 +823,13 @@ func Test_gatherOptions(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "OIDC login without cookie secret",
+			args: map[string]string{
+				"--oidc-config-file": "/etc/oidc/secret",
+			},
+			err: true,
+		},
 		{
 			name: "explicitly set --plugin-config",
 			args: map[string]string{
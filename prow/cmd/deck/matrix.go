@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/jobs"
+	"k8s.io/test-infra/prow/kube"
+)
+
+// matrixJob is a job expanded from a matrix with the latest run of it.
+type matrixJob struct {
+	Name string
+	// Values holds the value of each axis, in the order of the axes.
+	Values  []string
+	State   prowapi.ProwJobState
+	URL     string
+	Started string
+}
+
+type matrixTemplate struct {
+	Matrix string
+	Axes   []string
+	Jobs   []matrixJob
+}
+
+// getMatrix groups the jobs expanded from the matrix with the given name.
+// Jobs are taken from the static job config and from the ProwJobs, so that
+// jobs that never ran and jobs from inrepoconfig are both listed.
+func getMatrix(cfg *config.Config, pjs []prowapi.ProwJob, name string) (matrixTemplate, error) {
+	values := map[string]map[string]string{}
+	addJob := func(job string, annotations map[string]string) {
+		if annotations[kube.MatrixAnnotation] != name {
+			return
+		}
+		if _, ok := values[job]; !ok {
+			values[job] = config.ParseMatrixValues(annotations[kube.MatrixValuesAnnotation])
+		}
+	}
+	for _, ps := range cfg.AllStaticPresubmits(nil) {
+		addJob(ps.Name, ps.Annotations)
+	}
+	for _, ps := range cfg.AllStaticPostsubmits(nil) {
+		addJob(ps.Name, ps.Annotations)
+	}
+	for _, p := range cfg.AllPeriodics() {
+		addJob(p.Name, p.Annotations)
+	}
+	latest := map[string]prowapi.ProwJob{}
+	for _, pj := range pjs {
+		addJob(pj.Spec.Job, pj.Annotations)
+		if _, ok := values[pj.Spec.Job]; !ok {
+			continue
+		}
+		if previous, ok := latest[pj.Spec.Job]; !ok || previous.Status.StartTime.Before(&pj.Status.StartTime) {
+			latest[pj.Spec.Job] = pj
+		}
+	}
+	if len(values) == 0 {
+		return matrixTemplate{}, fmt.Errorf("no jobs expanded from matrix %s", name)
+	}
+
+	axes := map[string]bool{}
+	for _, v := range values {
+		for axis := range v {
+			axes[axis] = true
+		}
+	}
+	tmpl := matrixTemplate{Matrix: name}
+	for axis := range axes {
+		tmpl.Axes = append(tmpl.Axes, axis)
+	}
+	sort.Strings(tmpl.Axes)
+
+	for job, v := range values {
+		row := matrixJob{Name: job}
+		for _, axis := range tmpl.Axes {
+			row.Values = append(row.Values, v[axis])
+		}
+		if pj, ok := latest[job]; ok {
+			row.State = pj.Status.State
+			row.URL = pj.Status.URL
+			row.Started = pj.Status.StartTime.Format(time.RFC3339)
+		}
+		tmpl.Jobs = append(tmpl.Jobs, row)
+	}
+	sort.Slice(tmpl.Jobs, func(i, j int) bool {
+		return tmpl.Jobs[i].Name < tmpl.Jobs[j].Name
+	})
+	return tmpl, nil
+}
+
+// handleMatrix shows the latest run of every job expanded from a matrix.
+// The url must look like this:
+//
+// /matrix?job=<name>
+func handleMatrix(o options, cfg config.Getter, ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		name := r.URL.Query().Get("job")
+		if name == "" {
+			http.Error(w, "request did not provide the 'job' query parameter", http.StatusBadRequest)
+			return
+		}
+		tmpl, err := getMatrix(cfg(), ja.ProwJobs(), name)
+		if err != nil {
+			log.WithError(err).WithField("matrix", name).Debug("Failed to get matrix.")
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		handleSimpleTemplate(o, cfg, "matrix.html", tmpl)(w, r)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestGetMatrix(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	annotations := func(values string) map[string]string {
+		if values == "" {
+			return nil
+		}
+		return map[string]string{kube.MatrixAnnotation: "ci-e2e", kube.MatrixValuesAnnotation: values}
+	}
+	periodic := func(name, values string) config.Periodic {
+		return config.Periodic{JobBase: config.JobBase{Name: name, Annotations: annotations(values)}}
+	}
+	pj := func(name, job, values string, state prowapi.ProwJobState, started time.Duration) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations(values)},
+			Spec:       prowapi.ProwJobSpec{Job: job},
+			Status: prowapi.ProwJobStatus{
+				State:     state,
+				StartTime: metav1.NewTime(start.Add(started)),
+				URL:       "https://prow.example.com/view/" + name,
+			},
+		}
+	}
+	cfg := &config.Config{JobConfig: config.JobConfig{Periodics: []config.Periodic{
+		periodic("ci-e2e-gce-1.20", "cloud=gce,k8s=1.20"),
+		periodic("ci-e2e-gce-1.21", "cloud=gce,k8s=1.21"),
+		{JobBase: config.JobBase{Name: "unrelated"}},
+	}}}
+	pjs := []prowapi.ProwJob{
+		pj("old", "ci-e2e-gce-1.20", "cloud=gce,k8s=1.20", prowapi.FailureState, 0),
+		pj("new", "ci-e2e-gce-1.20", "cloud=gce,k8s=1.20", prowapi.SuccessState, time.Hour),
+		pj("removed", "ci-e2e-aws-1.20", "cloud=aws,k8s=1.20", prowapi.ErrorState, 0),
+		pj("unrelated", "unrelated", "", prowapi.SuccessState, 0),
+	}
+
+	actual, err := getMatrix(cfg, pjs, "ci-e2e")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := matrixTemplate{
+		Matrix: "ci-e2e",
+		Axes:   []string{"cloud", "k8s"},
+		Jobs: []matrixJob{
+			{
+				Name:    "ci-e2e-aws-1.20",
+				Values:  []string{"aws", "1.20"},
+				State:   prowapi.ErrorState,
+				URL:     "https://prow.example.com/view/removed",
+				Started: start.Format(time.RFC3339),
+			},
+			{
+				Name:    "ci-e2e-gce-1.20",
+				Values:  []string{"gce", "1.20"},
+				State:   prowapi.SuccessState,
+				URL:     "https://prow.example.com/view/new",
+				Started: start.Add(time.Hour).Format(time.RFC3339),
+			},
+			{
+				Name:   "ci-e2e-gce-1.21",
+				Values: []string{"gce", "1.21"},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected matrix: %s", diff)
+	}
+
+	if _, err := getMatrix(cfg, pjs, "unknown"); err == nil {
+		t.Error("expected an error for an unknown matrix")
+	}
+}
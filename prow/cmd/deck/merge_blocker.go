@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/rbac"
+)
+
+// mergeBlockerBranchRE matches the branch names Tide finds in the titles of
+// merge blockers.
+var mergeBlockerBranchRE = regexp.MustCompile(`^[\w-./]+$`)
+
+type mergeBlockerClient interface {
+	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
+}
+
+// tidePage is the argument of the Tide page template.
+type tidePage struct {
+	cfg           config.Getter
+	mergeBlockers bool
+}
+
+// MergeBlockers determines whether the Tide page offers to block merges.
+func (p tidePage) MergeBlockers() bool {
+	return p.mergeBlockers && p.cfg().Tide.BlockerLabel != ""
+}
+
+// handleMergeBlocker blocks Tide from merging into the repo given by the org
+// and repo query parameters, or only into its branch if the branch parameter
+// is set, by opening an issue with the blocker label of Tide. Merges resume
+// once the issue is closed. It only answers POST requests from users with the
+// administer_tide permission, and every attempt is recorded in the audit log.
+func handleMergeBlocker(cfg config.Getter, ghc mergeBlockerClient, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		org, repo, branch := query.Get("org"), query.Get("repo"), query.Get("branch")
+		l := log.WithFields(logrus.Fields{"org": org, "repo": repo, "branch": branch})
+		if r.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("bad verb %v", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		label := cfg().Tide.BlockerLabel
+		if label == "" {
+			http.Error(w, "Merge blockers are not enabled. Enable them with 'tide.blocker_label'.", http.StatusMethodNotAllowed)
+			return
+		}
+		if org == "" || repo == "" {
+			http.Error(w, "request did not provide the 'org' and 'repo' query parameters", http.StatusBadRequest)
+			return
+		}
+		if branch != "" && !mergeBlockerBranchRE.MatchString(branch) {
+			http.Error(w, fmt.Sprintf("invalid branch %q", branch), http.StatusBadRequest)
+			return
+		}
+
+		target := fmt.Sprintf("%s/%s", org, repo)
+		if branch != "" {
+			target += ":" + branch
+		}
+		event := rbac.AuditEvent{Action: "block-merges", Target: target, Scope: rbac.Scope{Org: org, Repo: repo}}
+		identity, allowed, err := authz.authorize(r, rbac.PermissionAdministerTide, event.Scope, l)
+		event.User, event.Groups, event.Allowed = identity.User, identity.Groups, allowed
+		if err != nil {
+			event.Error = err.Error()
+		}
+		authz.audit.Record(event)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error checking if user can administer Tide: %v", err), httpStatusForAuthError(err))
+			l.WithError(err).Errorf("Error checking if user can administer Tide")
+			return
+		}
+		if !allowed {
+			http.Error(w, "You don't have permission to block merges", http.StatusForbidden)
+			return
+		}
+		l = l.WithField("user", identity.User)
+
+		title := fmt.Sprintf("Merges blocked by %s", identity.User)
+		if branch != "" {
+			title += " branch:" + branch
+		}
+		body := fmt.Sprintf("%s blocked Tide from merging pull requests in deck.\n\nReason: %s\n\nClose this issue to resume merging.", identity.User, query.Get("reason"))
+		number, err := ghc.CreateIssue(org, repo, title, body, 0, []string{label}, nil)
+		if err != nil {
+			l.WithError(err).Error("Error creating merge blocker")
+			http.Error(w, fmt.Sprintf("Error creating merge blocker: %v", err), http.StatusInternalServerError)
+			return
+		}
+		l.WithField("issue", number).Info("Blocked merges")
+		if _, err := fmt.Fprintf(w, "Merges are blocked by issue #%d until it is closed.", number); err != nil {
+			l.WithError(err).Error("Error writing to merge blocker response.")
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/rbac"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/githuboauth"
+)
+
+var tideAdminPolicy = &rbac.Policy{
+	Roles: []rbac.Role{
+		{Name: "tide-admin", Permissions: []rbac.Permission{rbac.PermissionAdministerTide}},
+	},
+	Bindings: []rbac.Binding{
+		{Role: "tide-admin", Users: []string{"alice"}, Scopes: []rbac.Scope{{Org: "org", Repo: "repo"}}},
+	},
+}
+
+func TestHandleMergeBlocker(t *testing.T) {
+	testCases := []struct {
+		name           string
+		blockerLabel   string
+		login          string
+		method         string
+		query          string
+		expectedStatus int
+		expectedTitle  string
+		expectedAudit  string
+	}{
+		{
+			name:           "merge blockers are disabled",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "bad verb",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodGet,
+			query:          "org=org&repo=repo",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "missing repo",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid branch",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo&branch=a%20b",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "login required",
+			blockerLabel:   "tide/merge-blocker",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "policy allows user to block merges into the repo",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo&reason=outage",
+			expectedStatus: http.StatusOK,
+			expectedTitle:  "Merges blocked by alice",
+			expectedAudit:  `"user":"alice","action":"block-merges","target":"org/repo","scope":{"org":"org","repo":"repo"},"allowed":true}`,
+		},
+		{
+			name:           "policy allows user to block merges into a branch",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo&branch=release-1.0",
+			expectedStatus: http.StatusOK,
+			expectedTitle:  "Merges blocked by alice branch:release-1.0",
+			expectedAudit:  `"target":"org/repo:release-1.0","scope":{"org":"org","repo":"repo"},"allowed":true}`,
+		},
+		{
+			name:           "policy does not allow user to block merges",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "mallory",
+			method:         http.MethodPost,
+			query:          "org=org&repo=repo",
+			expectedStatus: http.StatusForbidden,
+			expectedAudit:  `"user":"mallory","action":"block-merges","target":"org/repo","scope":{"org":"org","repo":"repo"},"allowed":false}`,
+		},
+		{
+			name:           "policy does not allow user to block merges into other repos",
+			blockerLabel:   "tide/merge-blocker",
+			login:          "alice",
+			method:         http.MethodPost,
+			query:          "org=org&repo=other",
+			expectedStatus: http.StatusForbidden,
+			expectedAudit:  `"scope":{"org":"org","repo":"other"},"allowed":false}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := func() *config.Config {
+				return &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{BlockerLabel: tc.blockerLabel}}}
+			}
+			store := sessions.NewCookieStore([]byte("secret-key"))
+			var audit bytes.Buffer
+			authz := &authorizer{
+				policy: tideAdminPolicy,
+				audit:  rbac.NewAuditLog(&audit),
+				goa:    githuboauth.NewAgent(&githuboauth.Config{CookieStore: store}, logrus.WithField("client", "githuboauth")),
+				ghc:    &fakeAuthenticatedUserIdentifier{login: tc.login},
+			}
+			ghc := fakegithub.NewFakeClient()
+			handler := handleMergeBlocker(cfg, ghc, authz, logrus.WithField("handler", "/tide-merge-blocker"))
+			req := loggedInRequest(t, tc.method, "/tide-merge-blocker?"+tc.query, store, tc.login)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(audit.String(), tc.expectedAudit) {
+				t.Errorf("expected audit log to contain %s, got %s", tc.expectedAudit, audit.String())
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				if len(ghc.Issues) != 0 {
+					t.Errorf("expected no merge blocker, got %v", ghc.Issues)
+				}
+				return
+			}
+			if len(ghc.Issues) != 1 {
+				t.Fatalf("expected a merge blocker, got %v", ghc.Issues)
+			}
+			for _, issue := range ghc.Issues {
+				if issue.Title != tc.expectedTitle {
+					t.Errorf("expected title %q, got %q", tc.expectedTitle, issue.Title)
+				}
+				if len(issue.Labels) != 1 || issue.Labels[0].Name != tc.blockerLabel {
+					t.Errorf("expected the blocker label, got %v", issue.Labels)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/rbac"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/retention"
+	spyglassapi "k8s.io/test-infra/prow/spyglass/api"
+)
+
+// handlePin pins the run given by the src query parameter, e.g.
+// gs/kubernetes-jenkins/logs/ci-kubernetes-e2e/1234, on POST and unpins it
+// on DELETE. The artifact retention controller keeps the artifacts of
+// pinned runs. Every attempt is recorded in the audit log.
+func handlePin(cfg config.Getter, opener io.Opener, authz *authorizer, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		src := strings.TrimSuffix(r.URL.Query().Get("src"), "/")
+		l := log.WithField("src", src)
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			http.Error(w, fmt.Sprintf("bad verb %v", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		if !authz.pinEnabled(cfg) {
+			http.Error(w, "Pinning runs is not enabled. Enable it with 'artifact_retention.pin_auth_config' or an RBAC policy.", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := validateStoragePath(cfg, src); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid run: %v", err), httpStatusForError(err))
+			return
+		}
+		bucket, dir := runForSrc(src, opener)
+
+		event := rbac.AuditEvent{Action: "pin", Target: src}
+		if r.Method == http.MethodDelete {
+			event.Action = "unpin"
+		}
+		var identity rbac.Identity
+		var allowed bool
+		var err error
+		if authz.policy != nil {
+			event.Scope, err = runScope(r.Context(), bucket, dir)
+			if err == nil {
+				identity, allowed, err = authz.authorize(r, rbac.PermissionPin, event.Scope, l)
+			}
+		} else {
+			identity, allowed, err = authorizePinByConfig(r, authz, cfg().ArtifactRetention.PinAuthConfig, l)
+		}
+		event.User, event.Groups, event.Allowed = identity.User, identity.Groups, allowed
+		if err != nil {
+			event.Error = err.Error()
+		}
+		authz.audit.Record(event)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error checking if user can pin runs: %v", err), httpStatusForAuthError(err))
+			l.WithError(err).Errorf("Error checking if user can pin runs")
+			return
+		}
+		if !allowed {
+			http.Error(w, "You don't have permission to pin runs", http.StatusForbidden)
+			return
+		}
+		l = l.WithField("user", identity.User)
+
+		if r.Method == http.MethodDelete {
+			err = retention.DeletePin(r.Context(), bucket, dir)
+		} else {
+			err = retention.WritePin(r.Context(), bucket, dir, retention.Pin{
+				User:   identity.User,
+				Reason: r.URL.Query().Get("reason"),
+				Time:   time.Now(),
+			})
+		}
+		if err != nil {
+			l.WithError(err).Error("Error updating pin")
+			http.Error(w, fmt.Sprintf("Error updating pin: %v", err), http.StatusInternalServerError)
+			return
+		}
+		l.WithField("pinned", r.Method == http.MethodPost).Info("Updated pin")
+	}
+}
+
+// pinEnabled determines whether runs may be pinned, which either the RBAC
+// policy or the pin auth config decides.
+func (a *authorizer) pinEnabled(cfg config.Getter) bool {
+	return a.policy != nil || cfg().ArtifactRetention.PinAuthConfig != nil
+}
+
+// authorizePinByConfig decides whether the requester may pin runs according
+// to the pin auth config, which is used when there is no RBAC policy.
+func authorizePinByConfig(r *http.Request, authz *authorizer, authConfig *prowapi.RerunAuthConfig, l *logrus.Entry) (rbac.Identity, bool, error) {
+	var login string
+	if authz.goa != nil {
+		var err error
+		login, err = authz.goa.GetLogin(r, authz.ghc)
+		if err != nil && !authConfig.IsAllowAnyone() {
+			l.WithError(err).Errorf("Error retrieving GitHub login")
+			return rbac.Identity{}, false, errLoginRequired
+		}
+	} else if !authConfig.IsAllowAnyone() {
+		return rbac.Identity{}, false, errors.New("GitHub oauth must be configured to pin runs unless 'allow_anyone: true' is specified")
+	}
+	allowed, err := authConfig.IsAuthorized("", login, authz.cli)
+	return rbac.Identity{User: login}, allowed, err
+}
+
+// runScope returns the scope of the job of the run in the directory. It
+// falls back to the job name of the directory for runs without a
+// prowjob.json.
+func runScope(ctx context.Context, bucket jobhistory.Bucket, dir string) (rbac.Scope, error) {
+	raw, err := bucket.ReadObject(ctx, path.Join(dir, "prowjob.json"))
+	if err != nil {
+		if io.IsNotExist(err) {
+			return rbac.Scope{Job: path.Base(path.Dir(dir))}, nil
+		}
+		return rbac.Scope{}, fmt.Errorf("failed to read prowjob.json: %w", err)
+	}
+	var pj prowapi.ProwJob
+	if err := json.Unmarshal(raw, &pj); err != nil {
+		return rbac.Scope{}, fmt.Errorf("failed to parse prowjob.json: %w", err)
+	}
+	return rbac.ScopeForJob(&pj), nil
+}
+
+// runForSrc returns the bucket and directory of a run given by a validated
+// storage src.
+func runForSrc(src string, opener io.Opener) (jobhistory.Bucket, string) {
+	parts := strings.SplitN(src, "/", 3)
+	storageProvider := parts[0]
+	if storageProvider == spyglassapi.GCSKeyType {
+		storageProvider = providers.GS
+	}
+	return jobhistory.NewBucket(parts[1], storageProvider, opener), parts[2]
+}
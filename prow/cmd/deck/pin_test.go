@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/rbac"
+	"k8s.io/test-infra/prow/githuboauth"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/retention"
+)
+
+var pinPolicy = &rbac.Policy{
+	Roles: []rbac.Role{
+		{Name: "investigator", Permissions: []rbac.Permission{rbac.PermissionPin}},
+	},
+	Bindings: []rbac.Binding{
+		{Role: "investigator", Users: []string{"alice"}, Scopes: []rbac.Scope{{Org: "org", Repo: "repo"}}},
+	},
+}
+
+func TestHandlePin(t *testing.T) {
+	const dir = "logs/ci-job/1234"
+	boolTrue := true
+	testCases := []struct {
+		name           string
+		authConfig     *prowapi.RerunAuthConfig
+		policy         *rbac.Policy
+		login          string
+		prowJob        string
+		method         string
+		src            string
+		pinned         bool
+		expectedStatus int
+		expectedPinned bool
+		expectedAudit  string
+	}{
+		{
+			name:           "pinning is disabled",
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "bad verb",
+			authConfig:     &prowapi.RerunAuthConfig{AllowAnyone: true},
+			method:         http.MethodGet,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "not a storage path",
+			authConfig:     &prowapi.RerunAuthConfig{AllowAnyone: true},
+			method:         http.MethodPost,
+			src:            "prowjob",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "no oauth to identify users",
+			authConfig:     &prowapi.RerunAuthConfig{GitHubUsers: []string{"investigator"}},
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "pin",
+			authConfig:     &prowapi.RerunAuthConfig{AllowAnyone: true},
+			method:         http.MethodPost,
+			src:            "gcs/bucket/" + dir,
+			expectedStatus: http.StatusOK,
+			expectedPinned: true,
+		},
+		{
+			name:           "policy allows user to pin",
+			policy:         pinPolicy,
+			login:          "alice",
+			prowJob:        `{"spec":{"job":"ci-job","refs":{"org":"org","repo":"repo"}}}`,
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusOK,
+			expectedPinned: true,
+			expectedAudit:  `"user":"alice","action":"pin","target":"gs/bucket/logs/ci-job/1234","scope":{"org":"org","repo":"repo","job":"ci-job"},"allowed":true}`,
+		},
+		{
+			name:           "policy does not allow user to pin",
+			policy:         pinPolicy,
+			login:          "mallory",
+			prowJob:        `{"spec":{"job":"ci-job","refs":{"org":"org","repo":"repo"}}}`,
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusForbidden,
+			expectedAudit:  `"user":"mallory","action":"pin","target":"gs/bucket/logs/ci-job/1234","scope":{"org":"org","repo":"repo","job":"ci-job"},"allowed":false}`,
+		},
+		{
+			name:           "policy scopes runs without a prowjob.json to their job",
+			policy:         pinPolicy,
+			login:          "alice",
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusForbidden,
+			expectedAudit:  `"scope":{"job":"ci-job"},"allowed":false}`,
+		},
+		{
+			name:           "policy requires login",
+			policy:         pinPolicy,
+			method:         http.MethodPost,
+			src:            "gs/bucket/" + dir,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unpin",
+			authConfig:     &prowapi.RerunAuthConfig{AllowAnyone: true},
+			method:         http.MethodDelete,
+			src:            "gs/bucket/" + dir + "/",
+			pinned:         true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := []fakestorage.Object{{BucketName: "bucket", Name: dir + "/started.json", Content: []byte("{}")}}
+			if tc.prowJob != "" {
+				objects = append(objects, fakestorage.Object{BucketName: "bucket", Name: dir + "/prowjob.json", Content: []byte(tc.prowJob)})
+			}
+			if tc.pinned {
+				objects = append(objects, fakestorage.Object{BucketName: "bucket", Name: dir + "/" + retention.PinFile, Content: []byte("{}")})
+			}
+			gcsServer := fakestorage.NewServer(objects)
+			defer gcsServer.Stop()
+			opener := io.NewGCSOpener(gcsServer.Client())
+
+			cfg := func() *config.Config {
+				return &config.Config{ProwConfig: config.ProwConfig{
+					ArtifactRetention: config.ArtifactRetention{PinAuthConfig: tc.authConfig},
+					Deck:              config.Deck{SkipStoragePathValidation: &boolTrue},
+				}}
+			}
+			store := sessions.NewCookieStore([]byte("secret-key"))
+			var audit bytes.Buffer
+			authz := &authorizer{policy: tc.policy, audit: rbac.NewAuditLog(&audit)}
+			if tc.policy != nil {
+				authz.goa = githuboauth.NewAgent(&githuboauth.Config{CookieStore: store}, logrus.WithField("client", "githuboauth"))
+				authz.ghc = &fakeAuthenticatedUserIdentifier{login: tc.login}
+			}
+			handler := handlePin(cfg, opener, authz, logrus.WithField("handler", "/pin"))
+			req := loggedInRequest(t, tc.method, "/pin?src="+tc.src+"&reason=flake", store, tc.login)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(audit.String(), tc.expectedAudit) {
+				t.Errorf("expected audit log to contain %s, got %s", tc.expectedAudit, audit.String())
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			pin, err := retention.ReadPin(context.Background(), jobhistory.NewBucket("bucket", providers.GS, opener), dir)
+			if pinned := err == nil; pinned != tc.expectedPinned {
+				t.Fatalf("expected pinned: %t, got: %v", tc.expectedPinned, err)
+			}
+			if tc.expectedPinned && pin.Reason != "flake" {
+				t.Errorf("expected the reason to be recorded, got %q", pin.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/jobs"
+)
+
+// pipelineStage is a run of a job in a chain of periodics that trigger
+// each other.
+type pipelineStage struct {
+	Name    string
+	Job     string
+	State   prowapi.ProwJobState
+	URL     string
+	Started string
+	// Depth is the number of upstream runs in the chain before this one.
+	Depth int
+	// Current is set on the stage the pipeline was requested for.
+	Current bool
+}
+
+type pipelineTemplate struct {
+	ProwJob string
+	// Trigger describes what triggered the first stage, if it was not
+	// triggered by a ProwJob.
+	Trigger string
+	Stages  []pipelineStage
+}
+
+// getPipeline returns the chain the ProwJob is part of: its upstream runs
+// followed by all runs triggered by them, depth first.
+func getPipeline(pjs []prowapi.ProwJob, name string) (pipelineTemplate, error) {
+	byName := map[string]prowapi.ProwJob{}
+	children := map[string][]prowapi.ProwJob{}
+	for _, pj := range pjs {
+		byName[pj.Name] = pj
+		if pj.Spec.Upstream != nil && pj.Spec.Upstream.ProwJob != "" {
+			children[pj.Spec.Upstream.ProwJob] = append(children[pj.Spec.Upstream.ProwJob], pj)
+		}
+	}
+	current, ok := byName[name]
+	if !ok {
+		return pipelineTemplate{}, fmt.Errorf("ProwJob %s not found", name)
+	}
+
+	root := current
+	seen := map[string]bool{root.Name: true}
+	for root.Spec.Upstream != nil && root.Spec.Upstream.ProwJob != "" {
+		upstream, ok := byName[root.Spec.Upstream.ProwJob]
+		if !ok || seen[upstream.Name] {
+			break
+		}
+		seen[upstream.Name] = true
+		root = upstream
+	}
+
+	tmpl := pipelineTemplate{ProwJob: name}
+	if upstream := root.Spec.Upstream; upstream != nil {
+		if upstream.GCSObject != "" {
+			tmpl.Trigger = fmt.Sprintf("Triggered by generation %d of %s", upstream.Generation, upstream.GCSObject)
+		} else {
+			tmpl.Trigger = fmt.Sprintf("Triggered by %s of %s, which is no longer known", upstream.ProwJob, upstream.Job)
+		}
+	}
+
+	visited := map[string]bool{}
+	var visit func(pj prowapi.ProwJob, depth int)
+	visit = func(pj prowapi.ProwJob, depth int) {
+		if visited[pj.Name] {
+			return
+		}
+		visited[pj.Name] = true
+		tmpl.Stages = append(tmpl.Stages, pipelineStage{
+			Name:    pj.Name,
+			Job:     pj.Spec.Job,
+			State:   pj.Status.State,
+			URL:     pj.Status.URL,
+			Started: pj.Status.StartTime.Format(time.RFC3339),
+			Depth:   depth,
+			Current: pj.Name == name,
+		})
+		downstream := children[pj.Name]
+		sort.Slice(downstream, func(i, j int) bool {
+			return downstream[i].Status.StartTime.Before(&downstream[j].Status.StartTime)
+		})
+		for _, child := range downstream {
+			visit(child, depth+1)
+		}
+	}
+	visit(root, 0)
+	return tmpl, nil
+}
+
+// handlePipeline shows the chain of triggered periodics a ProwJob is part of.
+// The url must look like this:
+//
+// /pipeline?prowjob=<name>
+func handlePipeline(o options, cfg config.Getter, ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		name := r.URL.Query().Get("prowjob")
+		if name == "" {
+			http.Error(w, "request did not provide the 'prowjob' query parameter", http.StatusBadRequest)
+			return
+		}
+		tmpl, err := getPipeline(ja.ProwJobs(), name)
+		if err != nil {
+			log.WithError(err).WithField("prowjob", name).Debug("Failed to get pipeline.")
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		handleSimpleTemplate(o, cfg, "pipeline.html", tmpl)(w, r)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestGetPipeline(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pj := func(name, job string, started time.Duration, upstream *prowapi.Upstream) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       prowapi.ProwJobSpec{Job: job, Upstream: upstream},
+			Status: prowapi.ProwJobStatus{
+				State:     prowapi.SuccessState,
+				StartTime: metav1.NewTime(start.Add(started)),
+				URL:       "https://prow.example.com/view/" + name,
+			},
+		}
+	}
+	stage := func(name, job string, started time.Duration, depth int, current bool) pipelineStage {
+		return pipelineStage{
+			Name:    name,
+			Job:     job,
+			State:   prowapi.SuccessState,
+			URL:     "https://prow.example.com/view/" + name,
+			Started: start.Add(started).Format(time.RFC3339),
+			Depth:   depth,
+			Current: current,
+		}
+	}
+	pjs := []prowapi.ProwJob{
+		pj("build-1", "build", 0, &prowapi.Upstream{GCSObject: "gs://bucket/latest.txt", Generation: 3}),
+		pj("publish-1", "publish", 2*time.Hour, &prowapi.Upstream{ProwJob: "test-1", Job: "test"}),
+		pj("lint-1", "lint", 90*time.Minute, &prowapi.Upstream{ProwJob: "build-1", Job: "build"}),
+		pj("test-1", "test", time.Hour, &prowapi.Upstream{ProwJob: "build-1", Job: "build"}),
+		pj("orphan-1", "orphan", 0, &prowapi.Upstream{ProwJob: "gone", Job: "build"}),
+		pj("unrelated", "unrelated", 0, nil),
+	}
+
+	testCases := []struct {
+		name        string
+		prowJob     string
+		expected    pipelineTemplate
+		expectedErr bool
+	}{
+		{
+			name:    "pipeline of a job in the middle of the chain",
+			prowJob: "test-1",
+			expected: pipelineTemplate{
+				ProwJob: "test-1",
+				Trigger: "Triggered by generation 3 of gs://bucket/latest.txt",
+				Stages: []pipelineStage{
+					stage("build-1", "build", 0, 0, false),
+					stage("test-1", "test", time.Hour, 1, true),
+					stage("publish-1", "publish", 2*time.Hour, 2, false),
+					stage("lint-1", "lint", 90*time.Minute, 1, false),
+				},
+			},
+		},
+		{
+			name:    "upstream that is no longer known",
+			prowJob: "orphan-1",
+			expected: pipelineTemplate{
+				ProwJob: "orphan-1",
+				Trigger: "Triggered by gone of build, which is no longer known",
+				Stages:  []pipelineStage{stage("orphan-1", "orphan", 0, 0, true)},
+			},
+		},
+		{
+			name:     "job without upstream",
+			prowJob:  "unrelated",
+			expected: pipelineTemplate{ProwJob: "unrelated", Stages: []pipelineStage{stage("unrelated", "unrelated", 0, 0, true)}},
+		},
+		{
+			name:        "unknown job",
+			prowJob:     "unknown",
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := getPipeline(pjs, tc.prowJob)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("pipeline differs from expected: %s", diff)
+			}
+		})
+	}
+}
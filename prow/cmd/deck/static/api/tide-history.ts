@@ -11,3 +11,20 @@ export interface Record {
   target?: Pull[];
   err?: string;
 }
+
+export interface StatsData {
+  Period: string;
+  Total: StatsPeriod[];
+  Repos: {[key: string]: StatsPeriod[]};
+}
+
+export interface StatsPeriod {
+  Start: string;
+  Merged: number;
+  BatchesTriggered: number;
+  BatchesMerged: number;
+  MeanApprovalToMerge: number;
+  RetestsPerPR: number;
+  BatchSuccessRate: number;
+  BlockedShare: {[key: string]: number};
+}
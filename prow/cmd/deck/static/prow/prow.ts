@@ -9,6 +9,7 @@ declare const allBuilds: ProwJobList;
 declare const spyglass: boolean;
 declare const rerunCreatesJob: boolean;
 declare const csrfToken: string;
+declare const loginPath: string;
 
 function genShortRefKey(baseRef: string, pulls: Pull[] = []) {
     return [baseRef, ...pulls.map((p) => p.number)].filter((n) => n).join(",");
@@ -624,7 +625,7 @@ function redraw(fz: FuzzySearch, pushState: boolean = true): void {
         } else {
             r.appendChild(cell.text(""));
         }
-        r.appendChild(createRerunCell(modal, rerunCommand, prowJobName));
+        r.appendChild(createRerunCell(modal, rerunCommand, prowJobName, state));
         r.appendChild(createViewJobCell(prowJobName));
         const key = groupKey(build);
         if (key !== lastKey) {
@@ -725,15 +726,32 @@ function redraw(fz: FuzzySearch, pushState: boolean = true): void {
     drawJobHistogram(totalJob, jobHistogram, now - (12 * 3600), now, max);
     if (rerunStatus === "gh_redirect") {
         modal.style.display = "block";
-        rerunCommand.innerHTML = "Rerunning that job requires GitHub login. Now that you're logged in, try again";
+        rerunCommand.innerHTML = "Rerunning or aborting that job requires login. Now that you're logged in, try again";
     }
 }
 
-function createRerunCell(modal: HTMLElement, rerunElement: HTMLElement, prowjob: string): HTMLTableDataCellElement {
+function createRerunCell(modal: HTMLElement, rerunElement: HTMLElement, prowjob: string, state: ProwJobState): HTMLTableDataCellElement {
     const url = `${location.protocol}//${location.host}/rerun?prowjob=${prowjob}`;
     const c = document.createElement("td");
     const i = icon.create("refresh", "Show instructions for rerunning this job");
 
+    // postAction sends the user to log in if deck does not know who they are.
+    const postAction = async (actionURL: string) => {
+        const result = await fetch(actionURL, {
+            headers: {
+                "Content-type": "application/x-www-form-urlencoded; charset=UTF-8",
+                "X-CSRF-Token": csrfToken,
+            },
+            method: 'post',
+        });
+        const data = await result.text();
+        if (result.status === 401) {
+            window.location.href = window.location.origin + `${loginPath}?dest=${relativeURL({rerun: "gh_redirect"})}`;
+        } else {
+            rerunElement.innerHTML = data;
+        }
+    };
+
     // we actually want to know whether the "access-token-session" cookie exists, but we can't always
     // access it from the frontend. "github_login" should be set whenever "access-token-session" is
     i.onclick = () => {
@@ -752,21 +770,21 @@ function createRerunCell(modal: HTMLElement, rerunElement: HTMLElement, prowjob:
                     event_category: "engagement",
                     transport_type: "beacon",
                 });
-                const result = await fetch(url, {
-                    headers: {
-                        "Content-type": "application/x-www-form-urlencoded; charset=UTF-8",
-                        "X-CSRF-Token": csrfToken,
-                    },
-                    method: 'post',
-                });
-                const data = await result.text();
-                if (result.status === 401) {
-                    window.location.href = window.location.origin + `/github-login?dest=${relativeURL({rerun: "gh_redirect"})}`;
-                } else {
-                    rerunElement.innerHTML = data;
-                }
+                await postAction(url);
             };
             rerunElement.appendChild(runButton);
+            if (state === "triggered" || state === "pending") {
+                const abortButton = document.createElement('a');
+                abortButton.innerHTML = "<button class='mdl-button mdl-js-button'>Abort</button>";
+                abortButton.onclick = async () => {
+                    gtag("event", "abort", {
+                        event_category: "engagement",
+                        transport_type: "beacon",
+                    });
+                    await postAction(`${location.protocol}//${location.host}/abort?prowjob=${prowjob}`);
+                };
+                rerunElement.appendChild(abortButton);
+            }
         }
     };
     c.appendChild(i);
@@ -157,7 +157,37 @@ window.addEventListener('hashchange', (e) => {
   }
 });
 
+async function updatePin(method: 'POST' | 'DELETE', reason?: string): Promise<void> {
+  let url = `/pin?src=${encodeURIComponent(src)}`;
+  if (reason) {
+    url += `&reason=${encodeURIComponent(reason)}`;
+  }
+  const resp = await fetch(url, {method, headers: {'X-CSRF-Token': csrfToken}, credentials: 'same-origin'});
+  if (!resp.ok) {
+    alert(await resp.text());
+    return;
+  }
+  location.reload();
+}
+
+function setUpPinning(): void {
+  const pinButton = document.querySelector<HTMLButtonElement>('#pin-button');
+  if (pinButton) {
+    pinButton.addEventListener('click', () => {
+      const reason = prompt('Why should the artifacts of this run be kept?');
+      if (reason !== null) {
+        updatePin('POST', reason);
+      }
+    });
+  }
+  const unpinButton = document.querySelector<HTMLButtonElement>('#unpin-button');
+  if (unpinButton) {
+    unpinButton.addEventListener('click', () => updatePin('DELETE'));
+  }
+}
+
 // We can't use DOMContentLoaded here or we end up with a bunch of flickering. This appears to be MDL's fault.
 window.addEventListener('load', () => {
     loadLenses();
+    setUpPinning();
 });
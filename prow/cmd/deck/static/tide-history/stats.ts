@@ -0,0 +1,201 @@
+import {StatsData, StatsPeriod} from "../api/tide-history";
+
+const svgNS = "http://www.w3.org/2000/svg";
+const chartWidth = 320;
+const chartHeight = 120;
+const chartPadding = 20;
+// blockedContextLimit is the number of contexts listed with their share of
+// the blocked time, the remaining contexts are summed up.
+const blockedContextLimit = 10;
+
+interface Chart {
+  title: string;
+  value: (p: StatsPeriod) => number | undefined;
+  format: (v: number) => string;
+}
+
+const charts: Chart[] = [
+  {
+    format: formatDuration,
+    title: "Mean time from approval to merge",
+    value: (p) => p.Merged > 0 ? p.MeanApprovalToMerge : undefined,
+  },
+  {
+    format: (v) => v.toFixed(2),
+    title: "Retests per merged PR",
+    value: (p) => p.Merged > 0 ? p.RetestsPerPR : undefined,
+  },
+  {
+    format: (v) => `${Math.round(v * 100)}%`,
+    title: "Batch success rate",
+    value: (p) => p.BatchesTriggered > 0 ? p.BatchSuccessRate : undefined,
+  },
+  {
+    format: (v) => String(v),
+    title: "Merged PRs",
+    value: (p) => p.Merged,
+  },
+];
+
+function formatDuration(seconds: number): string {
+  if (seconds < 60 * 60) {
+    return `${Math.round(seconds / 60)}m`;
+  }
+  if (seconds < 24 * 60 * 60) {
+    return `${(seconds / 60 / 60).toFixed(1)}h`;
+  }
+  return `${(seconds / 24 / 60 / 60).toFixed(1)}d`;
+}
+
+function svgElement(name: string, attrs: {[key: string]: string | number}): SVGElement {
+  const el = document.createElementNS(svgNS, name) as SVGElement;
+  for (const key of Object.keys(attrs)) {
+    el.setAttribute(key, String(attrs[key]));
+  }
+  return el;
+}
+
+// drawChart draws the values of the periods as a line chart. Periods without
+// a value, e.g. without merged PRs, are skipped.
+function drawChart(chart: Chart, periods: StatsPeriod[]): HTMLElement {
+  const container = document.createElement("div");
+  container.className = "stats-chart";
+  const title = document.createElement("h4");
+  title.textContent = chart.title;
+  container.appendChild(title);
+
+  const svg = svgElement("svg", {height: chartHeight, viewBox: `0 0 ${chartWidth} ${chartHeight}`, width: chartWidth});
+  const points: Array<[number, number]> = [];
+  let maxValue = 0;
+  periods.forEach((p, i) => {
+    const v = chart.value(p);
+    if (v !== undefined) {
+      points.push([i, v]);
+      maxValue = Math.max(maxValue, v);
+    }
+  });
+  const x = (i: number) => chartPadding + i * (chartWidth - 2 * chartPadding) / Math.max(periods.length - 1, 1);
+  const y = (v: number) => chartHeight - chartPadding - (maxValue > 0 ? v / maxValue : 0) * (chartHeight - 2 * chartPadding);
+
+  svg.appendChild(svgElement("line", {
+    class: "axis",
+    x1: chartPadding,
+    x2: chartWidth - chartPadding,
+    y1: chartHeight - chartPadding,
+    y2: chartHeight - chartPadding,
+  }));
+  if (points.length > 0) {
+    svg.appendChild(svgElement("polyline", {
+      class: "series",
+      points: points.map(([i, v]) => `${x(i)},${y(v)}`).join(" "),
+    }));
+    for (const [i, v] of points) {
+      const dot = svgElement("circle", {class: "series", cx: x(i), cy: y(v), r: 2});
+      const tooltip = svgElement("title", {});
+      tooltip.textContent = `${periods[i].Start}: ${chart.format(v)}`;
+      dot.appendChild(tooltip);
+      svg.appendChild(dot);
+    }
+    const max = svgElement("text", {x: 0, y: chartPadding - 6});
+    max.textContent = chart.format(maxValue);
+    svg.appendChild(max);
+  }
+  if (periods.length > 0) {
+    const first = svgElement("text", {x: chartPadding, y: chartHeight - 4});
+    first.textContent = periods[0].Start;
+    svg.appendChild(first);
+    const last = svgElement("text", {"text-anchor": "end", "x": chartWidth - chartPadding, "y": chartHeight - 4});
+    last.textContent = periods[periods.length - 1].Start;
+    svg.appendChild(last);
+  }
+  container.appendChild(svg);
+  return container;
+}
+
+// drawBlockedShare lists the share of the blocked time per failing context
+// over all periods.
+function drawBlockedShare(periods: StatsPeriod[]): HTMLElement {
+  const container = document.createElement("div");
+  container.className = "stats-chart";
+  const title = document.createElement("h4");
+  title.textContent = "Share of blocked time per failing context";
+  container.appendChild(title);
+
+  // Shares are relative to each period, so weigh them by the number of
+  // periods that had blocked time at all.
+  const totals: {[key: string]: number} = {};
+  let blockedPeriods = 0;
+  for (const p of periods) {
+    const contexts = Object.keys(p.BlockedShare || {});
+    if (contexts.length > 0) {
+      blockedPeriods++;
+    }
+    for (const context of contexts) {
+      totals[context] = (totals[context] || 0) + p.BlockedShare[context];
+    }
+  }
+  const contexts = Object.keys(totals).sort((a, b) => totals[b] - totals[a]);
+  if (contexts.length === 0) {
+    const empty = document.createElement("p");
+    empty.textContent = "No PRs were blocked by failing contexts.";
+    container.appendChild(empty);
+    return container;
+  }
+
+  const list = document.createElement("table");
+  let other = 0;
+  contexts.forEach((context, i) => {
+    const share = totals[context] / blockedPeriods;
+    if (i >= blockedContextLimit) {
+      other += share;
+      return;
+    }
+    list.appendChild(shareRow(context, share));
+  });
+  if (other > 0) {
+    list.appendChild(shareRow("other", other));
+  }
+  container.appendChild(list);
+  return container;
+}
+
+function shareRow(label: string, share: number): HTMLTableRowElement {
+  const row = document.createElement("tr");
+  const name = document.createElement("td");
+  name.textContent = label;
+  row.appendChild(name);
+  const bar = document.createElement("td");
+  const fill = document.createElement("div");
+  fill.className = "stats-bar";
+  fill.style.width = `${Math.round(share * 100)}%`;
+  bar.appendChild(fill);
+  row.appendChild(bar);
+  const value = document.createElement("td");
+  value.textContent = `${Math.round(share * 100)}%`;
+  row.appendChild(value);
+  return row;
+}
+
+// redrawStats draws the charts of the stats of the repo, or of all repos if
+// repo is empty.
+export function redrawStats(stats: StatsData | undefined, repo: string): void {
+  const container = document.getElementById("stats");
+  if (!container) {
+    return;
+  }
+  while (container.firstChild) {
+    container.removeChild(container.firstChild);
+  }
+  if (!stats) {
+    return;
+  }
+  const periods = (repo ? stats.Repos[repo] : stats.Total) || [];
+
+  const heading = document.createElement("h3");
+  heading.textContent = `Statistics for ${repo || "all repositories"} per ${stats.Period}`;
+  container.appendChild(heading);
+  for (const chart of charts) {
+    container.appendChild(drawChart(chart, periods));
+  }
+  container.appendChild(drawBlockedShare(periods));
+}
@@ -1,10 +1,12 @@
 import moment from "moment";
 import {ProwJobState} from "../api/prow";
-import {HistoryData, Record} from "../api/tide-history";
+import {HistoryData, Record, StatsData} from "../api/tide-history";
 import {cell} from "../common/common";
 import {getParameterByName} from "../common/urls";
+import {redrawStats} from "./stats";
 
 declare const tideHistory: HistoryData;
+declare const tideStats: StatsData;
 
 const recordDisplayLimit = 500;
 
@@ -226,6 +228,7 @@ function redraw(): void {
   // Sort by descending time.
   filteredRecs = filteredRecs.sort((a, b) => a.time > b.time ? -1 : (a.time < b.time ? 1 : 0));
   redrawRecords(filteredRecs);
+  redrawStats(typeof tideStats !== 'undefined' ? tideStats : undefined, repoSel);
 }
 
 function redrawRecords(recs: FilteredRecord[]): void {
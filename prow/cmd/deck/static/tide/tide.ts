@@ -2,6 +2,8 @@ import {PullRequest, TideData, TidePool} from '../api/tide';
 import {tidehistory, tooltip} from '../common/common';
 
 declare const tideData: TideData;
+declare const mergeBlockers: boolean;
+declare const csrfToken: string;
 
 window.onload = (): void => {
     const infoDiv = document.getElementById("info-div")!;
@@ -242,9 +244,34 @@ function createRepoCell(pool: TidePool): HTMLTableDataCellElement {
     linksTD.appendChild(createLink(deckLink, `${pool.Org}/${pool.Repo}`));
     linksTD.appendChild(document.createTextNode(" "));
     linksTD.appendChild(createLink(branchLink, pool.Branch));
+    if (mergeBlockers) {
+        linksTD.appendChild(document.createTextNode(" "));
+        linksTD.appendChild(createBlockButton(pool));
+    }
     return linksTD;
 }
 
+// createBlockButton creates a button that blocks Tide from merging into the branch of the pool.
+function createBlockButton(pool: TidePool): HTMLButtonElement {
+    const button = document.createElement("button");
+    button.appendChild(document.createTextNode("block merges"));
+    button.addEventListener("click", async () => {
+        const reason = prompt(`Why should merges into ${pool.Org}/${pool.Repo} ${pool.Branch} be blocked?`);
+        if (reason === null) {
+            return;
+        }
+        const url = `/tide-merge-blocker?org=${encodeURIComponent(pool.Org)}&repo=${encodeURIComponent(pool.Repo)}` +
+            `&branch=${encodeURIComponent(pool.Branch)}&reason=${encodeURIComponent(reason)}`;
+        const resp = await fetch(url, {
+            credentials: 'same-origin',
+            headers: {'X-CSRF-Token': csrfToken},
+            method: 'POST',
+        });
+        alert(await resp.text());
+    });
+    return button;
+}
+
 function createActionCell(pool: TidePool): HTMLTableDataCellElement {
     const targeted = pool.Target && pool.Target.length;
     const blocked = pool.Blockers && pool.Blockers.length;
@@ -55,6 +55,8 @@ type tideAgent struct {
 	sync.Mutex
 	pools   []tide.Pool
 	history map[string][]history.Record
+	// stats maps org/repo -> day -> stats of the day.
+	stats map[string]map[string]history.DayStats
 }
 
 func (ta *tideAgent) start() {
@@ -66,6 +68,9 @@ func (ta *tideAgent) start() {
 	if err := ta.updateHistory(); err != nil {
 		ta.log.WithError(err).Error("Updating history the first time.")
 	}
+	if err := ta.updateStats(); err != nil {
+		ta.log.WithError(err).Error("Updating history stats the first time.")
+	}
 
 	go func() {
 		for {
@@ -83,6 +88,9 @@ func (ta *tideAgent) start() {
 			if err := ta.updateHistory(); err != nil {
 				ta.log.WithError(err).Error("Updating history.")
 			}
+			if err := ta.updateStats(); err != nil {
+				ta.log.WithError(err).Error("Updating history stats.")
+			}
 		}
 	}()
 }
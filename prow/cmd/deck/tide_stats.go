@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+const (
+	tideStatsDayFormat   = "2006-01-02"
+	tideStatsDefaultDays = 30
+	// tideStatsMaxDays matches the retention of the stats in Tide.
+	tideStatsMaxDays = 90
+)
+
+// tideStatsPeriod holds the Tide stats aggregated over a day or a week.
+type tideStatsPeriod struct {
+	// Start is the first day of the period.
+	Start            string
+	Merged           int
+	BatchesTriggered int
+	BatchesMerged    int
+	// MeanApprovalToMerge is the mean time in seconds that PRs merged in the
+	// period spent in the pool before being merged.
+	MeanApprovalToMerge float64
+	// RetestsPerPR is the mean number of times Tide retested a merged PR.
+	RetestsPerPR float64
+	// BatchSuccessRate is the ratio of merged to triggered batches.
+	BatchSuccessRate float64
+	// BlockedShare maps status contexts to their share of the time PRs were
+	// blocked by failing contexts.
+	BlockedShare map[string]float64
+
+	approvalToMergeSeconds float64
+	retests                int
+	blockedSeconds         map[string]float64
+}
+
+func (p *tideStatsPeriod) add(day history.DayStats) {
+	p.Merged += day.Merged
+	p.BatchesTriggered += day.BatchesTriggered
+	p.BatchesMerged += day.BatchesMerged
+	p.approvalToMergeSeconds += day.ApprovalToMergeSeconds
+	p.retests += day.Retests
+	for context, seconds := range day.BlockedSeconds {
+		p.blockedSeconds[context] += seconds
+	}
+}
+
+func (p *tideStatsPeriod) finish() {
+	if p.Merged > 0 {
+		p.MeanApprovalToMerge = p.approvalToMergeSeconds / float64(p.Merged)
+		p.RetestsPerPR = float64(p.retests) / float64(p.Merged)
+	}
+	if p.BatchesTriggered > 0 {
+		p.BatchSuccessRate = float64(p.BatchesMerged) / float64(p.BatchesTriggered)
+	}
+	var blocked float64
+	for _, seconds := range p.blockedSeconds {
+		blocked += seconds
+	}
+	p.BlockedShare = make(map[string]float64, len(p.blockedSeconds))
+	for context, seconds := range p.blockedSeconds {
+		p.BlockedShare[context] = seconds / blocked
+	}
+}
+
+// tideStats is the response of the Tide stats API.
+type tideStats struct {
+	Period string
+	// Total holds the stats of all repos, Repos those of each org/repo.
+	Total []tideStatsPeriod
+	Repos map[string][]tideStatsPeriod
+}
+
+// periodStart returns the first day of the period containing t.
+func periodStart(t time.Time, period string) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if period == "week" {
+		// Weeks start on Monday.
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t
+}
+
+// computeTideStats aggregates the daily stats reported by Tide by period for
+// the days from start to end. All series contain every period in that range.
+func computeTideStats(stats map[string]map[string]history.DayStats, period string, start, end time.Time) tideStats {
+	start = periodStart(start, "day")
+	var periods []string
+	index := map[string]int{}
+	step := 1
+	if period == "week" {
+		step = 7
+	}
+	for t := periodStart(start, period); !t.After(end); t = t.AddDate(0, 0, step) {
+		index[t.Format(tideStatsDayFormat)] = len(periods)
+		periods = append(periods, t.Format(tideStatsDayFormat))
+	}
+	newSeries := func() []tideStatsPeriod {
+		series := make([]tideStatsPeriod, len(periods))
+		for i, p := range periods {
+			series[i] = tideStatsPeriod{Start: p, blockedSeconds: map[string]float64{}}
+		}
+		return series
+	}
+
+	res := tideStats{Period: period, Total: newSeries(), Repos: map[string][]tideStatsPeriod{}}
+	for repo, days := range stats {
+		series := newSeries()
+		for day, dayStats := range days {
+			t, err := time.Parse(tideStatsDayFormat, day)
+			if err != nil || t.Before(start) || t.After(end) {
+				continue
+			}
+			i := index[periodStart(t, period).Format(tideStatsDayFormat)]
+			series[i].add(dayStats)
+			res.Total[i].add(dayStats)
+		}
+		for i := range series {
+			series[i].finish()
+		}
+		res.Repos[repo] = series
+	}
+	for i := range res.Total {
+		res.Total[i].finish()
+	}
+	return res
+}
+
+func (ta *tideAgent) updateStats() error {
+	path := strings.TrimSuffix(ta.path, "/") + "/history/stats"
+	var stats map[string]map[string]history.DayStats
+	if err := fetchTideData(ta.log, path, &stats); err != nil {
+		return err
+	}
+	stats = ta.filterHiddenStats(stats)
+
+	ta.Lock()
+	defer ta.Unlock()
+	ta.stats = stats
+	return nil
+}
+
+func (ta *tideAgent) filterHiddenStats(stats map[string]map[string]history.DayStats) map[string]map[string]history.DayStats {
+	if len(ta.hiddenRepos()) == 0 {
+		return stats
+	}
+
+	filtered := make(map[string]map[string]history.DayStats, len(stats))
+	for repo, days := range stats {
+		needsHide := matches(repo, ta.hiddenRepos())
+		if needsHide && ta.showHidden {
+			filtered[repo] = days
+		} else if needsHide == ta.hiddenOnly {
+			filtered[repo] = days
+		}
+	}
+	return filtered
+}
+
+// handleTideStats serves the Tide stats aggregated by period. The query
+// parameters are `period` (day or week), `days` to look back and `repo` to
+// limit the repos to a single org/repo.
+func handleTideStats(ta *tideAgent, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = "day"
+		}
+		if period != "day" && period != "week" {
+			http.Error(w, fmt.Sprintf("invalid period %q, must be day or week", period), http.StatusBadRequest)
+			return
+		}
+		days := tideStatsDefaultDays
+		if raw := r.URL.Query().Get("days"); raw != "" {
+			var err error
+			if days, err = strconv.Atoi(raw); err != nil || days < 1 || days > tideStatsMaxDays {
+				http.Error(w, fmt.Sprintf("invalid days %q, must be between 1 and %d", raw, tideStatsMaxDays), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ta.Lock()
+		stats := ta.stats
+		ta.Unlock()
+		if repo := r.URL.Query().Get("repo"); repo != "" {
+			stats = map[string]map[string]history.DayStats{repo: stats[repo]}
+		}
+
+		end := time.Now().UTC()
+		payload := computeTideStats(stats, period, end.AddDate(0, 0, 1-days), end)
+		pd, err := json.Marshal(payload)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling payload.")
+			pd = []byte("{}")
+		}
+		writeJSONResponse(w, r, pd)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+func TestComputeTideStats(t *testing.T) {
+	stats := map[string]map[string]history.DayStats{
+		"org/a": {
+			// Monday
+			"2021-03-01": {Merged: 2, ApprovalToMergeSeconds: 600, Retests: 1, BatchesTriggered: 2, BatchesMerged: 1, BlockedSeconds: map[string]float64{"unit": 30, "e2e": 90}},
+			"2021-03-03": {Merged: 1, ApprovalToMergeSeconds: 300},
+			// Out of range
+			"2021-02-01": {Merged: 10},
+		},
+		"org/b": {
+			"2021-03-08": {Merged: 1, ApprovalToMergeSeconds: 100, Retests: 2, BlockedSeconds: map[string]float64{"unit": 60}},
+		},
+	}
+	start := time.Date(2021, 3, 1, 15, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 3, 9, 15, 0, 0, 0, time.UTC)
+
+	weekly := computeTideStats(stats, "week", start, end)
+	expectedTotal := []tideStatsPeriod{
+		{
+			Start:               "2021-03-01",
+			Merged:              3,
+			BatchesTriggered:    2,
+			BatchesMerged:       1,
+			MeanApprovalToMerge: 300,
+			RetestsPerPR:        1.0 / 3,
+			BatchSuccessRate:    0.5,
+			BlockedShare:        map[string]float64{"unit": 0.25, "e2e": 0.75},
+		},
+		{
+			Start:               "2021-03-08",
+			Merged:              1,
+			MeanApprovalToMerge: 100,
+			RetestsPerPR:        2,
+			BlockedShare:        map[string]float64{"unit": 1},
+		},
+	}
+	if diff := cmp.Diff(expectedTotal, weekly.Total, cmpopts.IgnoreUnexported(tideStatsPeriod{})); diff != "" {
+		t.Errorf("unexpected weekly totals (-want +got):\n%s", diff)
+	}
+	if len(weekly.Repos) != 2 || len(weekly.Repos["org/b"]) != 2 || weekly.Repos["org/b"][0].Merged != 0 || weekly.Repos["org/b"][1].Merged != 1 {
+		t.Errorf("unexpected weekly repo stats: %+v", weekly.Repos)
+	}
+
+	daily := computeTideStats(stats, "day", start, end)
+	if len(daily.Total) != 9 {
+		t.Fatalf("expected 9 days, got %d", len(daily.Total))
+	}
+	if daily.Total[0].Start != "2021-03-01" || daily.Total[0].Merged != 2 || daily.Total[2].Merged != 1 || daily.Total[1].Merged != 0 {
+		t.Errorf("unexpected daily totals: %+v", daily.Total)
+	}
+}
+
+func TestHandleTideStats(t *testing.T) {
+	today := time.Now().UTC().Format(tideStatsDayFormat)
+	ta := &tideAgent{
+		stats: map[string]map[string]history.DayStats{
+			"org/a": {today: {Merged: 1}},
+			"org/b": {today: {Merged: 2}},
+		},
+	}
+	handler := handleTideStats(ta, logrus.WithField("handler", "/tide-stats.js"))
+
+	var testCases = []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedRepos  int
+		expectedDays   int
+		expectedMerged int
+	}{
+		{
+			name:           "defaults",
+			expectedStatus: http.StatusOK,
+			expectedRepos:  2,
+			expectedDays:   tideStatsDefaultDays,
+			expectedMerged: 3,
+		},
+		{
+			name:           "single repo",
+			query:          "?repo=org/b&days=7",
+			expectedStatus: http.StatusOK,
+			expectedRepos:  1,
+			expectedDays:   7,
+			expectedMerged: 2,
+		},
+		{
+			name:           "invalid period",
+			query:          "?period=month",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "too many days",
+			query:          "?days=365",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tide-stats.js"+tc.query, nil))
+			if rr.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var res tideStats
+			if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if len(res.Repos) != tc.expectedRepos {
+				t.Errorf("expected %d repos, got %d", tc.expectedRepos, len(res.Repos))
+			}
+			if len(res.Total) != tc.expectedDays {
+				t.Fatalf("expected %d days, got %d", tc.expectedDays, len(res.Total))
+			}
+			if merged := res.Total[len(res.Total)-1].Merged; merged != tc.expectedMerged {
+				t.Errorf("expected %d merged PRs today, got %d", tc.expectedMerged, merged)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -27,6 +28,7 @@ import (
 	"k8s.io/test-infra/prow/config"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/interrupts"
+	pkgio "k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/metrics/prowjobs"
@@ -37,15 +39,21 @@ type options struct {
 	configPath             string
 	kubernetes             prowflagutil.KubernetesOptions
 	instrumentationOptions prowflagutil.InstrumentationOptions
+	storage                prowflagutil.StorageClientOptions
+	resourceUsage          bool
+	stepDurations          bool
 }
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	var o options
 
 	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.BoolVar(&o.resourceUsage, "resource-usage", false, "Export the resource usage recorded by jobs with a resource sample interval. Requires read access to their storage buckets.")
+	fs.BoolVar(&o.stepDurations, "step-durations", false, "Export how long the steps of multi-step jobs ran. Requires read access to their storage buckets.")
 
 	o.kubernetes.AddFlags(fs)
 	o.instrumentationOptions.AddFlags(fs)
+	o.storage.AddFlags(fs)
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		logrus.WithError(err).Fatalf("cannot parse args: '%s'", os.Args[1:])
 	}
@@ -53,6 +61,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 }
 
 func (o *options) Validate() error {
+	if err := o.storage.Validate(false); err != nil {
+		return err
+	}
 	return o.kubernetes.Validate(false)
 }
 
@@ -97,6 +108,18 @@ func main() {
 
 	registry := mustRegister("exporter", pjLister)
 	registry.MustRegister(prowjobs.NewProwJobLifecycleHistogramVec(informerFactory.Prow().V1().ProwJobs().Informer()))
+	if o.resourceUsage || o.stepDurations {
+		opener, err := pkgio.NewOpener(context.Background(), o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating opener")
+		}
+		if o.resourceUsage {
+			registry.MustRegister(newResourceUsageCollector(pjLister, opener))
+		}
+		if o.stepDurations {
+			registry.MustRegister(newStepDurationCollector(pjLister, opener))
+		}
+	}
 
 	// Expose prometheus metrics
 	metrics.ExposeMetricsWithRegistry("exporter", cfg().PushGateway, o.instrumentationOptions.MetricsPort, registry, nil)
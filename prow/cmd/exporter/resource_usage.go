@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/gcsupload"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/resourceusage"
+)
+
+var resourceUsageDesc = prometheus.NewDesc(
+	"prow_job_resource_usage_p95",
+	"95th percentile of the resource usage of the test containers in the latest completed run of a job, in cores for cpu and bytes for memory.",
+	[]string{"job_name", "job_namespace", "container", "resource"}, nil,
+)
+
+// resourceUsageCollector exports the resource usage recorded by the
+// entrypoint of jobs that set a resource sample interval.
+type resourceUsageCollector struct {
+	lister lister
+	opener pkgio.Opener
+
+	lock sync.Mutex
+	// profiles caches the profiles of completed ProwJobs by name, as they
+	// never change once uploaded. Jobs without profiles are cached as nil.
+	profiles map[string][]resourceusage.Profile
+}
+
+func newResourceUsageCollector(lister lister, opener pkgio.Opener) *resourceUsageCollector {
+	return &resourceUsageCollector{
+		lister:   lister,
+		opener:   opener,
+		profiles: map[string][]resourceusage.Profile{},
+	}
+}
+
+func (c *resourceUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourceUsageDesc
+}
+
+func (c *resourceUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	prowJobs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list prow jobs")
+		return
+	}
+	var profiled []*prowapi.ProwJob
+	for _, pj := range prowJobs {
+		if pj.Complete() && profilesResourceUsage(pj) {
+			profiled = append(profiled, pj)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	profiles := map[string][]resourceusage.Profile{}
+	for _, pj := range getLatest(profiled) {
+		jobProfiles, cached := c.profiles[pj.Name]
+		if !cached {
+			jobProfiles = c.readProfiles(context.Background(), pj)
+		}
+		profiles[pj.Name] = jobProfiles
+		for _, profile := range jobProfiles {
+			p95 := profile.Percentile(95)
+			ch <- prometheus.MustNewConstMetric(resourceUsageDesc, prometheus.GaugeValue, p95.CPU, pj.Spec.Job, pj.Namespace, profile.Container, "cpu")
+			ch <- prometheus.MustNewConstMetric(resourceUsageDesc, prometheus.GaugeValue, float64(p95.Memory), pj.Spec.Job, pj.Namespace, profile.Container, "memory")
+		}
+	}
+	// Only keep the runs that are still the latest ones.
+	c.profiles = profiles
+}
+
+func profilesResourceUsage(pj *prowapi.ProwJob) bool {
+	dc := pj.Spec.DecorationConfig
+	return dc != nil && dc.ResourceSampleInterval != nil && dc.ResourceSampleInterval.Duration > 0 &&
+		dc.GCSConfiguration != nil && pj.Spec.PodSpec != nil && pj.Status.BuildID != ""
+}
+
+// readProfiles reads the profiles of all test containers of the job,
+// skipping the ones that cannot be read.
+func (c *resourceUsageCollector) readProfiles(ctx context.Context, pj *prowapi.ProwJob) []resourceusage.Profile {
+	gcsConfig := pj.Spec.DecorationConfig.GCSConfiguration
+	log := logrus.WithField("prowjob", pj.Name)
+	bucket, err := jobhistory.ParseBucket(gcsConfig.Bucket, c.opener)
+	if err != nil {
+		log.WithError(err).Warn("Failed to parse bucket")
+		return nil
+	}
+	spec := downwardapi.NewJobSpec(pj.Spec, pj.Status.BuildID, pj.Name)
+	_, dir, _ := gcsupload.PathsForJob(gcsConfig, &spec, "")
+
+	var profiles []resourceusage.Profile
+	containers := pj.Spec.PodSpec.Containers
+	for _, container := range containers {
+		key := path.Join(dir, resourceusage.ArtifactName(container.Name, len(containers) > 1))
+		raw, err := bucket.ReadObject(ctx, key)
+		if err != nil {
+			if !pkgio.IsNotExist(err) {
+				log.WithError(err).WithField("container", container.Name).Warn("Failed to read resource usage")
+			}
+			continue
+		}
+		var profile resourceusage.Profile
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			log.WithError(err).WithField("container", container.Name).Warn("Failed to parse resource usage")
+			continue
+		}
+		if profile.Container == "" {
+			profile.Container = container.Name
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
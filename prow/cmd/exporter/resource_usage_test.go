@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string]string
+	reads   int
+}
+
+func (fo *fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	fo.reads++
+	content, ok := fo.objects[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+type staticLister []*prowapi.ProwJob
+
+func (l staticLister) List(selector labels.Selector) ([]*prowapi.ProwJob, error) {
+	return l, nil
+}
+
+func TestResourceUsageCollector(t *testing.T) {
+	profiledJob := func(name, buildID string, start time.Time, containers ...string) *prowapi.ProwJob {
+		pj := &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prowjobs"},
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "ci-job",
+				DecorationConfig: &prowapi.DecorationConfig{
+					ResourceSampleInterval: &prowapi.Duration{Duration: time.Second},
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket:       "bucket",
+						PathStrategy: prowapi.PathStrategyExplicit,
+					},
+				},
+				PodSpec: &coreapi.PodSpec{},
+			},
+			Status: prowapi.ProwJobStatus{
+				State:          prowapi.SuccessState,
+				StartTime:      metav1.NewTime(start),
+				CompletionTime: &metav1.Time{Time: start.Add(time.Minute)},
+				BuildID:        buildID,
+			},
+		}
+		for _, container := range containers {
+			pj.Spec.PodSpec.Containers = append(pj.Spec.PodSpec.Containers, coreapi.Container{Name: container})
+		}
+		return pj
+	}
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	running := profiledJob("running", "3", start.Add(2*time.Hour), "test")
+	running.Status.CompletionTime = nil
+	running.Status.State = prowapi.PendingState
+	unprofiled := profiledJob("unprofiled", "4", start)
+	unprofiled.Spec.Job = "other-job"
+	unprofiled.Spec.DecorationConfig.ResourceSampleInterval = nil
+	lister := staticLister{
+		profiledJob("old", "1", start, "test"),
+		profiledJob("latest", "2", start.Add(time.Hour), "test", "setup"),
+		running,
+		unprofiled,
+	}
+	opener := &fakeOpener{objects: map[string]string{
+		"gs://bucket/logs/ci-job/1/resource-usage.json":      `{"samples":[{"cpu":8,"memory":1}]}`,
+		"gs://bucket/logs/ci-job/2/test-resource-usage.json": `{"container":"test","samples":[{"cpu":1,"memory":100},{"cpu":2,"memory":200}]}`,
+	}}
+	collector := newResourceUsageCollector(lister, opener)
+
+	collect := func() []string {
+		ch := make(chan prometheus.Metric, 10)
+		collector.Collect(ch)
+		close(ch)
+		var metrics []string
+		for metric := range ch {
+			out := &dto.Metric{}
+			if err := metric.Write(out); err != nil {
+				t.Fatalf("failed to write metric: %v", err)
+			}
+			var labelValues []string
+			for _, label := range out.GetLabel() {
+				labelValues = append(labelValues, label.GetName()+"="+label.GetValue())
+			}
+			metrics = append(metrics, fmt.Sprintf("%s %v", strings.Join(labelValues, ","), out.GetGauge().GetValue()))
+		}
+		sort.Strings(metrics)
+		return metrics
+	}
+
+	expected := []string{
+		"container=test,job_name=ci-job,job_namespace=prowjobs,resource=cpu 2",
+		"container=test,job_name=ci-job,job_namespace=prowjobs,resource=memory 200",
+	}
+	if diff := cmp.Diff(expected, collect()); diff != "" {
+		t.Errorf("metrics differ from expected (-want +got):\n%s", diff)
+	}
+	if opener.reads != 2 {
+		t.Errorf("expected the profile of each container of the latest run to be read once, got %d reads", opener.reads)
+	}
+	if diff := cmp.Diff(expected, collect()); diff != "" {
+		t.Errorf("metrics of second collection differ from expected (-want +got):\n%s", diff)
+	}
+	if opener.reads != 2 {
+		t.Errorf("expected profiles to be cached, got %d reads", opener.reads)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/gcsupload"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/pod-utils/wrapper"
+)
+
+var stepDurationDesc = prometheus.NewDesc(
+	"prow_job_step_duration_seconds",
+	"How long each step of the latest completed run of a multi-step job ran, in seconds.",
+	[]string{"job_name", "job_namespace", "step", "result"}, nil,
+)
+
+// stepDurationCollector exports the step timings that sidecar records
+// in finished.json for jobs made of steps.
+type stepDurationCollector struct {
+	lister lister
+	opener pkgio.Opener
+
+	lock sync.Mutex
+	// steps caches the step results of completed ProwJobs by name, as they
+	// never change once uploaded. Jobs without results are cached as nil.
+	steps map[string][]wrapper.StepResult
+}
+
+func newStepDurationCollector(lister lister, opener pkgio.Opener) *stepDurationCollector {
+	return &stepDurationCollector{
+		lister: lister,
+		opener: opener,
+		steps:  map[string][]wrapper.StepResult{},
+	}
+}
+
+func (c *stepDurationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stepDurationDesc
+}
+
+func (c *stepDurationCollector) Collect(ch chan<- prometheus.Metric) {
+	prowJobs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list prow jobs")
+		return
+	}
+	var multiStep []*prowapi.ProwJob
+	for _, pj := range prowJobs {
+		if pj.Complete() && hasSteps(pj) {
+			multiStep = append(multiStep, pj)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	steps := map[string][]wrapper.StepResult{}
+	for _, pj := range getLatest(multiStep) {
+		results, cached := c.steps[pj.Name]
+		if !cached {
+			results = c.readSteps(context.Background(), pj)
+		}
+		steps[pj.Name] = results
+		for _, result := range results {
+			ch <- prometheus.MustNewConstMetric(stepDurationDesc, prometheus.GaugeValue, result.Duration, pj.Spec.Job, pj.Namespace, result.Name, result.Result)
+		}
+	}
+	// Only keep the runs that are still the latest ones.
+	c.steps = steps
+}
+
+func hasSteps(pj *prowapi.ProwJob) bool {
+	dc := pj.Spec.DecorationConfig
+	return dc != nil && len(dc.Steps) > 0 && dc.GCSConfiguration != nil && pj.Status.BuildID != ""
+}
+
+// readSteps reads the step results from the finished.json of the job.
+func (c *stepDurationCollector) readSteps(ctx context.Context, pj *prowapi.ProwJob) []wrapper.StepResult {
+	gcsConfig := pj.Spec.DecorationConfig.GCSConfiguration
+	log := logrus.WithField("prowjob", pj.Name)
+	bucket, err := jobhistory.ParseBucket(gcsConfig.Bucket, c.opener)
+	if err != nil {
+		log.WithError(err).Warn("Failed to parse bucket")
+		return nil
+	}
+	spec := downwardapi.NewJobSpec(pj.Spec, pj.Status.BuildID, pj.Name)
+	_, dir, _ := gcsupload.PathsForJob(gcsConfig, &spec, "")
+
+	raw, err := bucket.ReadObject(ctx, path.Join(dir, prowapi.FinishedStatusFile))
+	if err != nil {
+		if !pkgio.IsNotExist(err) {
+			log.WithError(err).Warn("Failed to read finished.json")
+		}
+		return nil
+	}
+	var finished gcs.Finished
+	if err := json.Unmarshal(raw, &finished); err != nil {
+		log.WithError(err).Warn("Failed to parse finished.json")
+		return nil
+	}
+	results, err := wrapper.StepResultsFromMetadata(finished.Metadata)
+	if err != nil {
+		log.WithError(err).Warn("Failed to parse step results")
+		return nil
+	}
+	return results
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestStepDurationCollector(t *testing.T) {
+	multiStepJob := func(name, buildID string, start time.Time) *prowapi.ProwJob {
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prowjobs"},
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "ci-job",
+				DecorationConfig: &prowapi.DecorationConfig{
+					Steps: []prowapi.Step{
+						{Name: "build", Command: []string{"make"}},
+						{Name: "test", Command: []string{"make", "test"}},
+						{Name: "cleanup", Command: []string{"make", "clean"}, AlwaysRun: true},
+					},
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket:       "bucket",
+						PathStrategy: prowapi.PathStrategyExplicit,
+					},
+				},
+			},
+			Status: prowapi.ProwJobStatus{
+				State:          prowapi.FailureState,
+				StartTime:      metav1.NewTime(start),
+				CompletionTime: &metav1.Time{Time: start.Add(time.Minute)},
+				BuildID:        buildID,
+			},
+		}
+	}
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	running := multiStepJob("running", "3", start.Add(2*time.Hour))
+	running.Status.CompletionTime = nil
+	running.Status.State = prowapi.PendingState
+	singleStep := multiStepJob("single-step", "4", start)
+	singleStep.Spec.Job = "other-job"
+	singleStep.Spec.DecorationConfig.Steps = nil
+	lister := staticLister{
+		multiStepJob("old", "1", start),
+		multiStepJob("latest", "2", start.Add(time.Hour)),
+		running,
+		singleStep,
+	}
+	opener := &fakeOpener{objects: map[string]string{
+		"gs://bucket/logs/ci-job/1/finished.json":    `{"metadata":{"steps":[{"name":"build","result":"SUCCESS","duration":100}]}}`,
+		"gs://bucket/logs/ci-job/2/finished.json":    `{"passed":false,"metadata":{"steps":[{"name":"build","result":"SUCCESS","duration":12.5},{"name":"test","result":"FAILURE","duration":40,"exit_code":2},{"name":"cleanup","result":"SUCCESS","duration":3}]}}`,
+		"gs://bucket/logs/other-job/4/finished.json": `{"passed":true}`,
+	}}
+	collector := newStepDurationCollector(lister, opener)
+
+	collect := func() []string {
+		ch := make(chan prometheus.Metric, 10)
+		collector.Collect(ch)
+		close(ch)
+		var metrics []string
+		for metric := range ch {
+			out := &dto.Metric{}
+			if err := metric.Write(out); err != nil {
+				t.Fatalf("failed to write metric: %v", err)
+			}
+			var labelValues []string
+			for _, label := range out.GetLabel() {
+				labelValues = append(labelValues, label.GetName()+"="+label.GetValue())
+			}
+			metrics = append(metrics, fmt.Sprintf("%s %v", strings.Join(labelValues, ","), out.GetGauge().GetValue()))
+		}
+		sort.Strings(metrics)
+		return metrics
+	}
+
+	expected := []string{
+		"job_name=ci-job,job_namespace=prowjobs,result=FAILURE,step=test 40",
+		"job_name=ci-job,job_namespace=prowjobs,result=SUCCESS,step=build 12.5",
+		"job_name=ci-job,job_namespace=prowjobs,result=SUCCESS,step=cleanup 3",
+	}
+	if diff := cmp.Diff(expected, collect()); diff != "" {
+		t.Errorf("metrics differ from expected (-want +got):\n%s", diff)
+	}
+	if opener.reads != 1 {
+		t.Errorf("expected finished.json of the latest run to be read once, got %d reads", opener.reads)
+	}
+	if diff := cmp.Diff(expected, collect()); diff != "" {
+		t.Errorf("metrics of second collection differ from expected (-want +got):\n%s", diff)
+	}
+	if opener.reads != 1 {
+		t.Errorf("expected step results to be cached, got %d reads", opener.reads)
+	}
+}
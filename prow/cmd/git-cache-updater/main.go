@@ -0,0 +1,298 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// git-cache-updater keeps a node-local cache of bare git mirrors fresh, so
+// that clonerefs can borrow objects from it instead of fetching every repo
+// from scratch. It is meant to run as a DaemonSet that mounts the cache
+// directory from the host.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pod-utils/clone"
+)
+
+type options struct {
+	configPath    string
+	jobConfigPath string
+
+	cacheDir     string
+	repos        prowflagutil.Strings
+	interval     time.Duration
+	resyncPeriod time.Duration
+
+	kubernetes             prowflagutil.KubernetesOptions
+	instrumentationOptions prowflagutil.InstrumentationOptions
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.StringVar(&o.cacheDir, "cache-dir", "", "Directory holding the bare mirrors, mounted into decorated pods as their git cache.")
+	fs.Var(&o.repos, "repo", "Repository in org/repo form to always keep mirrored, can be provided more than once.")
+	fs.DurationVar(&o.interval, "interval", time.Minute, "How often to look for new postsubmits.")
+	fs.DurationVar(&o.resyncPeriod, "resync-period", time.Hour, "How often to refresh mirrors of repos without postsubmits.")
+	o.kubernetes.AddFlags(fs)
+	o.instrumentationOptions.AddFlags(fs)
+
+	fs.Parse(args)
+	return o
+}
+
+func (o *options) Validate() error {
+	if err := o.kubernetes.Validate(false); err != nil {
+		return err
+	}
+	if o.configPath == "" {
+		return errors.New("--config-path is required")
+	}
+	if o.cacheDir == "" {
+		return errors.New("--cache-dir is required")
+	}
+	for _, repo := range o.repos.Strings() {
+		if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("--repo %q is not in org/repo form", repo)
+		}
+	}
+	if o.interval <= 0 {
+		return fmt.Errorf("--interval must be positive: %s", o.interval)
+	}
+	if o.resyncPeriod < o.interval {
+		return fmt.Errorf("--resync-period %s must not be shorter than --interval %s", o.resyncPeriod, o.interval)
+	}
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	pjutil.ServePProf(o.instrumentationOptions.PProfPort)
+
+	configAgent := config.Agent{}
+	if err := configAgent.Start(o.configPath, o.jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+
+	prowJobClient, err := o.kubernetes.ProwJobClient(configAgent.Config().ProwJobNamespace, false)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting Kubernetes client.")
+	}
+
+	u := newUpdater(o.cacheDir, o.resyncPeriod, o.repos.Strings())
+	interrupts.TickLiteral(func() {
+		start := time.Now()
+		jobs, err := prowJobClient.List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			logrus.WithError(err).Error("Error listing prow jobs.")
+			return
+		}
+		if err := u.sync(jobs.Items, start); err != nil {
+			logrus.WithError(err).Error("Error updating mirrors.")
+		}
+		logrus.WithField("duration", time.Since(start)).Info("Synced git cache")
+	}, o.interval)
+}
+
+// updater refreshes the mirrors of repos that saw postsubmits since its
+// previous sync, and every other known mirror once per resync period.
+type updater struct {
+	cacheDir     string
+	resyncPeriod time.Duration
+
+	// repos maps mirror paths to the refs used to fetch them.
+	repos map[string]prowapi.Refs
+	// refreshed records when each mirror was last refreshed.
+	refreshed map[string]time.Time
+	// lastSync is when the previous sync started.
+	lastSync time.Time
+}
+
+func newUpdater(cacheDir string, resyncPeriod time.Duration, repos []string) *updater {
+	u := &updater{
+		cacheDir:     cacheDir,
+		resyncPeriod: resyncPeriod,
+		repos:        map[string]prowapi.Refs{},
+		refreshed:    map[string]time.Time{},
+	}
+	for _, repo := range repos {
+		parts := strings.SplitN(repo, "/", 2)
+		refs := prowapi.Refs{Org: parts[0], Repo: parts[1]}
+		u.repos[clone.MirrorPath(cacheDir, refs)] = refs
+	}
+	return u
+}
+
+func (u *updater) sync(jobs []prowapi.ProwJob, now time.Time) error {
+	dirty := sets.NewString()
+	for _, pj := range jobs {
+		var refs []prowapi.Refs
+		if pj.Spec.Refs != nil {
+			refs = append(refs, *pj.Spec.Refs)
+		}
+		refs = append(refs, pj.Spec.ExtraRefs...)
+		for _, r := range refs {
+			mirror := clone.MirrorPath(u.cacheDir, r)
+			if _, known := u.repos[mirror]; !known {
+				u.repos[mirror] = r
+			}
+		}
+		// A postsubmit means the base branch of its repo moved.
+		if pj.Spec.Type == prowapi.PostsubmitJob && pj.Spec.Refs != nil && pj.Status.StartTime.Time.After(u.lastSync) {
+			dirty.Insert(clone.MirrorPath(u.cacheDir, *pj.Spec.Refs))
+		}
+	}
+
+	mirrors := make([]string, 0, len(u.repos))
+	for mirror := range u.repos {
+		mirrors = append(mirrors, mirror)
+	}
+	sort.Strings(mirrors)
+
+	var errs []error
+	for _, mirror := range mirrors {
+		last, refreshed := u.refreshed[mirror]
+		if refreshed && !dirty.Has(mirror) && now.Sub(last) < u.resyncPeriod {
+			continue
+		}
+		log := logrus.WithField("mirror", mirror)
+		if err := refreshMirror(mirror, remoteURI(u.repos[mirror])); err != nil {
+			log.WithError(err).Warn("Failed to refresh mirror.")
+			errs = append(errs, fmt.Errorf("refresh %s: %v", mirror, err))
+			continue
+		}
+		log.Debug("Refreshed mirror.")
+		u.refreshed[mirror] = now
+	}
+	u.lastSync = now
+	return utilerrors.NewAggregate(errs)
+}
+
+// remoteURI determines where clonerefs fetches the refs from.
+func remoteURI(refs prowapi.Refs) string {
+	if refs.CloneURI != "" {
+		return refs.CloneURI
+	}
+	if refs.RepoLink != "" {
+		return fmt.Sprintf("%s.git", refs.RepoLink)
+	}
+	return fmt.Sprintf("https://github.com/%s/%s.git", refs.Org, refs.Repo)
+}
+
+// mirrorConfig disables garbage collection in mirrors. Running clones
+// borrow objects from the mirror through alternates without holding any ref
+// to them, so objects the mirror stops referencing must never be pruned.
+var mirrorConfig = [][]string{
+	{"config", "gc.auto", "0"},
+	{"config", "gc.pruneExpire", "never"},
+}
+
+// refreshMirror fetches the branches and tags of remote into the bare mirror,
+// creating it first if it is missing or corrupt. New mirrors are created next
+// to their final location and renamed into place, so clonerefs never sees a
+// partially created mirror.
+func refreshMirror(mirror, remote string) error {
+	if err := git(mirror, "rev-parse", "--is-bare-repository"); err == nil {
+		// Mirrors created before garbage collection was disabled pick it up here.
+		for _, args := range mirrorConfig {
+			if err := git(mirror, args...); err != nil {
+				return err
+			}
+		}
+		return git(mirror, "fetch", "--prune", "--tags", "origin")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+		return fmt.Errorf("create parent directory: %v", err)
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(mirror), filepath.Base(mirror)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary mirror: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	steps := [][]string{
+		{"init", "--bare"},
+		{"remote", "add", "origin", remote},
+		// Pull request heads are fetched by clonerefs directly, only mirror branches and tags.
+		{"config", "remote.origin.fetch", "+refs/heads/*:refs/heads/*"},
+	}
+	steps = append(steps, mirrorConfig...)
+	steps = append(steps, []string{"fetch", "--prune", "--tags", "origin"})
+	for _, args := range steps {
+		if err := git(tmp, args...); err != nil {
+			return err
+		}
+	}
+	return replaceDir(tmp, mirror)
+}
+
+// replaceDir renames src to dst. An existing dst is renamed out of the way
+// rather than deleted in place, so dst is never seen half removed, and is
+// only deleted once src took its place.
+func replaceDir(src, dst string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+	trash, err := ioutil.TempDir(filepath.Dir(dst), filepath.Base(dst)+".old")
+	if err != nil {
+		return fmt.Errorf("create directory for corrupt mirror: %v", err)
+	}
+	defer os.RemoveAll(trash)
+	if err := os.Rename(dst, filepath.Join(trash, filepath.Base(dst))); err != nil {
+		return fmt.Errorf("move corrupt mirror aside: %v", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("move new mirror into place: %v", err)
+	}
+	return nil
+}
+
+func git(gitDir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"--git-dir", gitDir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pod-utils/clone"
+)
+
+func TestOptions(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		args        []string
+		expectedErr bool
+	}{
+		{
+			name: "minimal options",
+			args: []string{"--config-path=/etc/config/config.yaml", "--cache-dir=/git-cache"},
+		},
+		{
+			name:        "missing cache dir",
+			args:        []string{"--config-path=/etc/config/config.yaml"},
+			expectedErr: true,
+		},
+		{
+			name:        "malformed repo",
+			args:        []string{"--config-path=/etc/config/config.yaml", "--cache-dir=/git-cache", "--repo=kubernetes"},
+			expectedErr: true,
+		},
+		{
+			name:        "resync period shorter than interval",
+			args:        []string{"--config-path=/etc/config/config.yaml", "--cache-dir=/git-cache", "--interval=1h", "--resync-period=1m"},
+			expectedErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			o := gatherOptions(flag.NewFlagSet(testCase.name, flag.ContinueOnError), testCase.args...)
+			if err := o.Validate(); (err != nil) != testCase.expectedErr {
+				t.Errorf("expected error %t, got %v", testCase.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	upstream, err := ioutil.TempDir("", "upstream")
+	if err != nil {
+		t.Fatalf("error creating upstream dir: %v", err)
+	}
+	defer os.RemoveAll(upstream)
+	cacheDir, err := ioutil.TempDir("", "git-cache")
+	if err != nil {
+		t.Fatalf("error creating cache dir: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func() string {
+		run(upstream, "git", "-c", "user.name=test", "-c", "user.email=test@test.test", "commit", "--allow-empty", "-m", "change")
+		return run(upstream, "git", "rev-parse", "HEAD")
+	}
+	mirrored := func(sha string) bool {
+		return exec.Command("git", "--git-dir", clone.MirrorPath(cacheDir, prowapi.Refs{Org: "org", Repo: "repo"}), "cat-file", "-e", sha).Run() == nil
+	}
+	run(upstream, "git", "init")
+	first := commit()
+
+	refs := prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", CloneURI: upstream}
+	postsubmit := func(started time.Time) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			Spec:   prowapi.ProwJobSpec{Type: prowapi.PostsubmitJob, Refs: &refs},
+			Status: prowapi.ProwJobStatus{StartTime: metav1.NewTime(started)},
+		}
+	}
+	presubmit := prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Type: prowapi.PresubmitJob, Refs: &refs}}
+
+	now := time.Now()
+	u := newUpdater(cacheDir, time.Hour, nil)
+	if err := u.sync([]prowapi.ProwJob{presubmit}, now); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	if !mirrored(first) {
+		t.Fatal("expected the initial sync to create the mirror")
+	}
+
+	second := commit()
+	now = now.Add(time.Minute)
+	if err := u.sync([]prowapi.ProwJob{presubmit}, now); err != nil {
+		t.Fatalf("sync without postsubmits failed: %v", err)
+	}
+	if mirrored(second) {
+		t.Error("expected the mirror to be left alone without postsubmits")
+	}
+
+	now = now.Add(time.Minute)
+	if err := u.sync([]prowapi.ProwJob{presubmit, postsubmit(now.Add(-time.Second))}, now); err != nil {
+		t.Fatalf("sync with a postsubmit failed: %v", err)
+	}
+	if !mirrored(second) {
+		t.Error("expected a postsubmit to refresh the mirror")
+	}
+
+	third := commit()
+	now = now.Add(2 * time.Hour)
+	if err := u.sync([]prowapi.ProwJob{presubmit}, now); err != nil {
+		t.Fatalf("resync failed: %v", err)
+	}
+	if !mirrored(third) {
+		t.Error("expected the resync period to refresh the mirror")
+	}
+
+	if err := os.RemoveAll(clone.MirrorPath(cacheDir, refs) + "/objects"); err != nil {
+		t.Fatalf("error corrupting mirror: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if err := u.sync(nil, now); err != nil {
+		t.Fatalf("sync of a corrupt mirror failed: %v", err)
+	}
+	if !mirrored(third) {
+		t.Error("expected a corrupt mirror to be recreated")
+	}
+	mirror := clone.MirrorPath(cacheDir, refs)
+	for key, expected := range map[string]string{"gc.auto": "0", "gc.pruneExpire": "never"} {
+		if actual := run(cacheDir, "git", "--git-dir", mirror, "config", key); actual != expected {
+			t.Errorf("expected %s to be %q in the mirror, got %q", key, expected, actual)
+		}
+	}
+	leftovers, err := filepath.Glob(mirror + ".*")
+	if err != nil {
+		t.Fatalf("error listing cache dir: %v", err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("expected no temporary directories to be left behind, got %v", leftovers)
+	}
+}
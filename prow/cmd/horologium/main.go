@@ -35,6 +35,7 @@ import (
 	"k8s.io/test-infra/prow/flagutil"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
@@ -45,6 +46,7 @@ type options struct {
 	jobConfigPath string
 
 	kubernetes             flagutil.KubernetesOptions
+	storage                prowflagutil.StorageClientOptions
 	instrumentationOptions prowflagutil.InstrumentationOptions
 	dryRun                 bool
 }
@@ -56,6 +58,7 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether or not to make mutating API calls to Kubernetes.")
 	o.kubernetes.AddFlags(fs)
+	o.storage.AddFlags(fs)
 	o.instrumentationOptions.AddFlags(fs)
 
 	fs.Parse(args)
@@ -66,6 +69,9 @@ func (o *options) Validate() error {
 	if err := o.kubernetes.Validate(o.dryRun); err != nil {
 		return err
 	}
+	if err := o.storage.Validate(o.dryRun); err != nil {
+		return err
+	}
 
 	if o.configPath == "" {
 		return errors.New("--config-path is required")
@@ -96,6 +102,12 @@ func main() {
 		logrus.WithError(err).Fatal("Error getting Kubernetes client.")
 	}
 
+	// The storage client is used to watch the objects of periodics with gcs_object triggers.
+	opener, err := io.NewOpener(context.Background(), o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating opener")
+	}
+
 	// start a cron
 	cr := cron.New()
 	cr.Start()
@@ -104,7 +116,7 @@ func main() {
 
 	interrupts.TickLiteral(func() {
 		start := time.Now()
-		if err := sync(prowJobClient, configAgent.Config(), cr, start); err != nil {
+		if err := sync(prowJobClient, configAgent.Config(), cr, opener, start); err != nil {
 			logrus.WithError(err).Error("Error syncing periodic jobs.")
 		}
 		logrus.WithField("duration", time.Since(start)).Info("Synced periodic jobs")
@@ -121,7 +133,7 @@ type cronClient interface {
 	QueuedJobs() []string
 }
 
-func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now time.Time) error {
+func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, attributes attributesClient, now time.Time) error {
 	jobs, err := prowJobClient.List(context.TODO(), metav1.ListOptions{LabelSelector: labels.Everything().String()})
 	if err != nil {
 		return fmt.Errorf("error listing prow jobs: %v", err)
@@ -145,6 +157,11 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 			"previous-found": previousFound,
 		})
 
+		if p.Cron == "" && p.GetInterval() == 0 && len(p.TriggeredBy) > 0 {
+			// The periodic is only triggered by events, see syncTriggers.
+			continue
+		}
+
 		if p.Cron == "" {
 			shouldTrigger := j.Complete() && now.Sub(j.Status.StartTime.Time) > p.GetInterval()
 			logger = logger.WithField("should-trigger", shouldTrigger)
@@ -175,6 +192,8 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 		}
 	}
 
+	errs = append(errs, syncTriggers(prowJobClient, cfg, jobs.Items, latestJobs, attributes)...)
+
 	if len(errs) > 0 {
 		return fmt.Errorf("failed to create %d prowjobs: %v", len(errs), errs)
 	}
@@ -142,7 +142,7 @@ func TestSync(t *testing.T) {
 		}
 		fakeProwJobClient := fake.NewSimpleClientset(jobs...)
 		fc := &fakeCron{}
-		if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, fc, now); err != nil {
+		if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, fc, nil, now); err != nil {
 			t.Fatalf("For case %s, didn't expect error: %v", tc.testName, err)
 		}
 
@@ -224,7 +224,7 @@ func TestSyncCron(t *testing.T) {
 		}
 		fakeProwJobClient := fake.NewSimpleClientset(jobs...)
 		fc := &fakeCron{}
-		if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, fc, now); err != nil {
+		if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, fc, nil, now); err != nil {
 			t.Fatalf("For case %s, didn't expect error: %v", tc.testName, err)
 		}
 
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+type attributesClient interface {
+	Attributes(ctx context.Context, path string) (io.Attributes, error)
+}
+
+// event is something a periodic can be triggered by.
+type event struct {
+	upstream prowapi.Upstream
+	// time the event happened at
+	time time.Time
+}
+
+// syncTriggers triggers the periodics with triggers that saw a new event.
+// Only the latest event of each trigger is considered, so events that happen
+// in between two syncs or while the periodic is still running are coalesced.
+// An event is new if no run of the periodic recorded it as its upstream and,
+// if the periodic ran before, if it happened after the latest run started.
+func syncTriggers(prowJobClient prowJobClient, cfg *config.Config, jobs []prowapi.ProwJob, latestJobs map[string]prowapi.ProwJob, attributes attributesClient) []error {
+	triggered := map[string][]prowapi.Upstream{}
+	for _, job := range jobs {
+		if job.Spec.Type == prowapi.PeriodicJob && job.Spec.Upstream != nil {
+			triggered[job.Spec.Job] = append(triggered[job.Spec.Job], *job.Spec.Upstream)
+		}
+	}
+
+	var errs []error
+	for _, p := range cfg.Periodics {
+		if len(p.TriggeredBy) == 0 {
+			continue
+		}
+		latest, previousFound := latestJobs[p.Name]
+		logger := logrus.WithFields(logrus.Fields{"job": p.Name, "previous-found": previousFound})
+		if previousFound && !latest.Complete() {
+			continue
+		}
+		for _, trigger := range p.TriggeredBy {
+			e, found, err := latestEvent(trigger, jobs, attributes)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get events of periodic %s: %v", p.Name, err))
+				continue
+			}
+			if !found || recorded(triggered[p.Name], e.upstream) || (previousFound && !e.time.After(latest.Status.StartTime.Time)) {
+				continue
+			}
+			spec := pjutil.PeriodicSpec(p)
+			upstream := e.upstream
+			spec.Upstream = &upstream
+			prowJob := pjutil.NewProwJob(spec, p.Labels, p.Annotations)
+			logger.WithFields(pjutil.ProwJobFields(&prowJob)).WithFields(logrus.Fields{
+				"upstream-prowjob": upstream.ProwJob,
+				"upstream-object":  upstream.GCSObject,
+			}).Info("Triggering new run of periodic for upstream event.")
+			if _, err := prowJobClient.Create(context.TODO(), &prowJob, metav1.CreateOptions{}); err != nil {
+				errs = append(errs, err)
+			}
+			// Events of the other triggers are handled once this run completes.
+			break
+		}
+	}
+	return errs
+}
+
+func recorded(upstreams []prowapi.Upstream, upstream prowapi.Upstream) bool {
+	for _, u := range upstreams {
+		if u == upstream {
+			return true
+		}
+	}
+	return false
+}
+
+// latestEvent returns the latest event of the trigger, if there is any.
+func latestEvent(trigger config.PeriodicTrigger, jobs []prowapi.ProwJob, attributes attributesClient) (event, bool, error) {
+	switch {
+	case trigger.JobSucceeded != "":
+		return latestCompletion(jobs, func(pj prowapi.ProwJob) bool {
+			return pj.Spec.Job == trigger.JobSucceeded && pj.Status.State == prowapi.SuccessState
+		})
+	case trigger.Postsubmit != nil:
+		return latestCompletion(jobs, func(pj prowapi.ProwJob) bool {
+			return matchesPostsubmitTrigger(pj, trigger.Postsubmit)
+		})
+	case trigger.GCSObject != "":
+		if attributes == nil {
+			return event{}, false, fmt.Errorf("no storage client is configured to watch %s", trigger.GCSObject)
+		}
+		attrs, err := attributes.Attributes(context.TODO(), trigger.GCSObject)
+		if io.IsNotExist(err) {
+			return event{}, false, nil
+		}
+		if err != nil {
+			return event{}, false, fmt.Errorf("failed to get attributes of %s: %v", trigger.GCSObject, err)
+		}
+		return event{
+			upstream: prowapi.Upstream{GCSObject: trigger.GCSObject, Generation: attrs.Generation},
+			time:     attrs.Updated,
+		}, true, nil
+	}
+	return event{}, false, nil
+}
+
+func latestCompletion(jobs []prowapi.ProwJob, matches func(prowapi.ProwJob) bool) (event, bool, error) {
+	var latest *prowapi.ProwJob
+	for i, pj := range jobs {
+		if !pj.Complete() || !matches(pj) {
+			continue
+		}
+		if latest == nil || latest.Status.CompletionTime.Before(pj.Status.CompletionTime) {
+			latest = &jobs[i]
+		}
+	}
+	if latest == nil {
+		return event{}, false, nil
+	}
+	return event{
+		upstream: prowapi.Upstream{ProwJob: latest.Name, Job: latest.Spec.Job},
+		time:     latest.Status.CompletionTime.Time,
+	}, true, nil
+}
+
+func matchesPostsubmitTrigger(pj prowapi.ProwJob, trigger *config.PostsubmitTrigger) bool {
+	if pj.Spec.Type != prowapi.PostsubmitJob || pj.Spec.Refs == nil {
+		return false
+	}
+	if fmt.Sprintf("%s/%s", pj.Spec.Refs.Org, pj.Spec.Refs.Repo) != trigger.Repo {
+		return false
+	}
+	if trigger.Job != "" && pj.Spec.Job != trigger.Job {
+		return false
+	}
+	if len(trigger.Branches) > 0 {
+		var branchMatches bool
+		for _, branch := range trigger.Branches {
+			if branch == pj.Spec.Refs.BaseRef {
+				branchMatches = true
+			}
+		}
+		if !branchMatches {
+			return false
+		}
+	}
+	for _, state := range trigger.GetStates() {
+		if pj.Status.State == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/io"
+)
+
+type fakeAttributes map[string]io.Attributes
+
+func (f fakeAttributes) Attributes(_ context.Context, path string) (io.Attributes, error) {
+	attrs, ok := f[path]
+	if !ok {
+		return io.Attributes{}, io.ErrNotFoundTest
+	}
+	return attrs, nil
+}
+
+func TestSyncTriggers(t *testing.T) {
+	now := time.Now()
+	job := func(name, job string, jobType prowapi.ProwJobType, state prowapi.ProwJobState, started, completed time.Duration, upstream *prowapi.Upstream) *prowapi.ProwJob {
+		pj := &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prowjobs"},
+			Spec:       prowapi.ProwJobSpec{Type: jobType, Job: job, Upstream: upstream},
+			Status: prowapi.ProwJobStatus{
+				State:     state,
+				StartTime: metav1.NewTime(now.Add(-started)),
+			},
+		}
+		if completed > 0 {
+			completion := metav1.NewTime(now.Add(-completed))
+			pj.Status.CompletionTime = &completion
+		}
+		if jobType == prowapi.PostsubmitJob {
+			pj.Spec.Refs = &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "main"}
+		}
+		return pj
+	}
+
+	testCases := []struct {
+		name             string
+		trigger          config.PeriodicTrigger
+		interval         time.Duration
+		jobs             []runtime.Object
+		attributes       fakeAttributes
+		expectedUpstream *prowapi.Upstream
+	}{
+		{
+			name:    "successful upstream job triggers periodic",
+			trigger: config.PeriodicTrigger{JobSucceeded: "build"},
+			jobs: []runtime.Object{
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.SuccessState, 3*time.Hour, 2*time.Hour, nil),
+				job("build-2", "build", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+			},
+			expectedUpstream: &prowapi.Upstream{ProwJob: "build-2", Job: "build"},
+		},
+		{
+			name:    "failed upstream job does not trigger periodic",
+			trigger: config.PeriodicTrigger{JobSucceeded: "build"},
+			jobs: []runtime.Object{
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.FailureState, 2*time.Hour, time.Hour, nil),
+			},
+		},
+		{
+			name:    "upstream job that already triggered a run does not trigger again",
+			trigger: config.PeriodicTrigger{JobSucceeded: "build"},
+			jobs: []runtime.Object{
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.SuccessState, 50*time.Minute, 40*time.Minute, &prowapi.Upstream{ProwJob: "build-1", Job: "build"}),
+			},
+		},
+		{
+			name:    "upstream job that completed before the latest run started does not trigger",
+			trigger: config.PeriodicTrigger{JobSucceeded: "build"},
+			jobs: []runtime.Object{
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.SuccessState, 50*time.Minute, 40*time.Minute, nil),
+			},
+		},
+		{
+			name:    "running periodic is not triggered again",
+			trigger: config.PeriodicTrigger{JobSucceeded: "build"},
+			jobs: []runtime.Object{
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.PendingState, 3*time.Hour, 0, nil),
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+			},
+		},
+		{
+			name:    "matching postsubmit triggers periodic",
+			trigger: config.PeriodicTrigger{Postsubmit: &config.PostsubmitTrigger{Repo: "org/repo", Branches: []string{"main"}}},
+			jobs: []runtime.Object{
+				job("post-1", "post", prowapi.PostsubmitJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+			},
+			expectedUpstream: &prowapi.Upstream{ProwJob: "post-1", Job: "post"},
+		},
+		{
+			name:    "postsubmit on other branch does not trigger periodic",
+			trigger: config.PeriodicTrigger{Postsubmit: &config.PostsubmitTrigger{Repo: "org/repo", Branches: []string{"release"}}},
+			jobs: []runtime.Object{
+				job("post-1", "post", prowapi.PostsubmitJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+			},
+		},
+		{
+			name:    "postsubmit with configured state triggers periodic",
+			trigger: config.PeriodicTrigger{Postsubmit: &config.PostsubmitTrigger{Repo: "org/repo", States: []prowapi.ProwJobState{prowapi.FailureState}}},
+			jobs: []runtime.Object{
+				job("post-1", "post", prowapi.PostsubmitJob, prowapi.FailureState, 2*time.Hour, time.Hour, nil),
+			},
+			expectedUpstream: &prowapi.Upstream{ProwJob: "post-1", Job: "post"},
+		},
+		{
+			name:    "changed GCS object triggers periodic",
+			trigger: config.PeriodicTrigger{GCSObject: "gs://bucket/latest.txt"},
+			jobs: []runtime.Object{
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, &prowapi.Upstream{GCSObject: "gs://bucket/latest.txt", Generation: 1}),
+			},
+			attributes:       fakeAttributes{"gs://bucket/latest.txt": {Generation: 2, Updated: now.Add(-time.Minute)}},
+			expectedUpstream: &prowapi.Upstream{GCSObject: "gs://bucket/latest.txt", Generation: 2},
+		},
+		{
+			name:    "unchanged GCS object does not trigger periodic",
+			trigger: config.PeriodicTrigger{GCSObject: "gs://bucket/latest.txt"},
+			jobs: []runtime.Object{
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, &prowapi.Upstream{GCSObject: "gs://bucket/latest.txt", Generation: 2}),
+			},
+			attributes: fakeAttributes{"gs://bucket/latest.txt": {Generation: 2, Updated: now.Add(-3 * time.Hour)}},
+		},
+		{
+			name:       "missing GCS object does not trigger periodic",
+			trigger:    config.PeriodicTrigger{GCSObject: "gs://bucket/latest.txt"},
+			attributes: fakeAttributes{},
+		},
+		{
+			name:     "periodic with interval is triggered by events too",
+			trigger:  config.PeriodicTrigger{JobSucceeded: "build"},
+			interval: 24 * time.Hour,
+			jobs: []runtime.Object{
+				job("test-1", "test", prowapi.PeriodicJob, prowapi.SuccessState, 3*time.Hour, 2*time.Hour, nil),
+				job("build-1", "build", prowapi.PeriodicJob, prowapi.SuccessState, 2*time.Hour, time.Hour, nil),
+			},
+			expectedUpstream: &prowapi.Upstream{ProwJob: "build-1", Job: "build"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Config{
+				ProwConfig: config.ProwConfig{ProwJobNamespace: "prowjobs"},
+				JobConfig: config.JobConfig{
+					Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}, TriggeredBy: []config.PeriodicTrigger{tc.trigger}}},
+				},
+			}
+			cfg.Periodics[0].SetInterval(tc.interval)
+			client := fake.NewSimpleClientset(tc.jobs...)
+			pjClient := client.ProwV1().ProwJobs(cfg.ProwJobNamespace)
+			if err := sync(pjClient, &cfg, &fakeCron{}, tc.attributes, now); err != nil {
+				t.Fatalf("sync failed: %v", err)
+			}
+
+			list, err := pjClient.List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list ProwJobs: %v", err)
+			}
+			var created []*prowapi.Upstream
+			for _, pj := range list.Items {
+				if pj.Spec.Job == "test" && pj.Status.State == prowapi.TriggeredState {
+					created = append(created, pj.Spec.Upstream)
+				}
+			}
+			var expected []*prowapi.Upstream
+			if tc.expectedUpstream != nil {
+				expected = append(expected, tc.expectedUpstream)
+			}
+			if diff := cmp.Diff(expected, created); diff != "" {
+				t.Errorf("triggered runs differ from expected: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// jobdiff prints the changes to the effective job configs between two
+// revisions of a repository.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config/jobdiff"
+	"k8s.io/test-infra/prow/logrusutil"
+)
+
+type options struct {
+	repoDir       string
+	configPath    string
+	jobConfigPath string
+	base          string
+	head          string
+	maxLength     int
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+	if filepath.IsAbs(o.configPath) || filepath.IsAbs(o.jobConfigPath) {
+		return errors.New("--config-path and --job-config-path must be relative to --repo-dir")
+	}
+	if o.base == "" || o.head == "" {
+		return errors.New("--base and --head must be set")
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.repoDir, "repo-dir", ".", "Path to the git repository holding the configs.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml, relative to --repo-dir.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs, relative to --repo-dir.")
+	fs.StringVar(&o.base, "base", "origin/master", "Revision to compare against.")
+	fs.StringVar(&o.head, "head", "HEAD", "Revision with the changes.")
+	fs.IntVar(&o.maxLength, "max-length", 0, "Leave out job diffs once the output grows beyond this many bytes (0 for unlimited).")
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	before, err := jobdiff.LoadAtRevision(o.repoDir, o.base, o.configPath, o.jobConfigPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load base config.")
+	}
+	after, err := jobdiff.LoadAtRevision(o.repoDir, o.head, o.configPath, o.jobConfigPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load head config.")
+	}
+	changes, err := jobdiff.Diff(before, after)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to diff configs.")
+	}
+	fmt.Print(jobdiff.Markdown(changes, o.maxLength))
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		input       options
+		expectedErr bool
+	}{
+		{
+			name:  "all ok",
+			input: options{configPath: "config.yaml", jobConfigPath: "jobs", base: "origin/master", head: "HEAD"},
+		},
+		{
+			name:        "missing config",
+			input:       options{base: "origin/master", head: "HEAD"},
+			expectedErr: true,
+		},
+		{
+			name:        "absolute job config",
+			input:       options{configPath: "config.yaml", jobConfigPath: "/jobs", base: "origin/master", head: "HEAD"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing base",
+			input:       options{configPath: "config.yaml", head: "HEAD"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.input.Validate()
+		if testCase.expectedErr && err == nil {
+			t.Errorf("%s: expected an error but got none", testCase.name)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+		}
+	}
+}
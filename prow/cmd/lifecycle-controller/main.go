@@ -0,0 +1,157 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// lifecycle-controller applies the lifecycle policies of the plugin config:
+// it marks inactive issues and PRs as stale and rotten and closes them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/pkg/flagutil"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/plugins/lifecycle"
+)
+
+const (
+	defaultTokens = 300
+	defaultBurst  = 100
+)
+
+type options struct {
+	pluginConfig string
+	dryRun       bool
+	interval     time.Duration
+	github       prowflagutil.GitHubOptions
+
+	tokenBurst    int
+	tokensPerHour int
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{}
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Only print the report of what would be done instead of making mutating API calls to GitHub.")
+	fs.DurationVar(&o.interval, "interval", 0, "How often to apply the policies. Applies them once and exits if unset.")
+	fs.IntVar(&o.tokensPerHour, "tokens", defaultTokens, "Throttle hourly token consumption (0 to disable)")
+	fs.IntVar(&o.tokenBurst, "token-burst", defaultBurst, "Allow consuming a subset of hourly tokens in a short burst")
+	for _, group := range []flagutil.OptionGroup{&o.github} {
+		group.AddFlags(fs)
+	}
+	fs.Parse(args)
+	return o
+}
+
+func (o *options) Validate() error {
+	for _, group := range []flagutil.OptionGroup{&o.github} {
+		if err := group.Validate(o.dryRun); err != nil {
+			return err
+		}
+	}
+	if o.interval < 0 {
+		return fmt.Errorf("--interval must not be negative: %s", o.interval)
+	}
+	return nil
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	pluginAgent := &plugins.ConfigAgent{}
+	if err := pluginAgent.Start(o.pluginConfig, false); err != nil {
+		logrus.WithError(err).Fatal("Error starting plugin configuration agent.")
+	}
+
+	secretAgent := &secret.Agent{}
+	if o.github.TokenPath != "" {
+		if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+			logrus.WithError(err).Fatal("Error starting secrets agent.")
+		}
+	}
+
+	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitHub client.")
+	}
+	if o.tokensPerHour > 0 {
+		githubClient.Throttle(o.tokensPerHour, o.tokenBurst)
+	}
+
+	log := logrus.NewEntry(logrus.StandardLogger())
+	if o.interval == 0 {
+		if err := applyPolicies(githubClient, log, pluginAgent.Config(), o.dryRun, os.Stdout); err != nil {
+			log.WithError(err).Fatal("Failed to apply the lifecycle policies.")
+		}
+		return
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+	interrupts.TickLiteral(func() {
+		if err := applyPolicies(githubClient, log, pluginAgent.Config(), o.dryRun, os.Stdout); err != nil {
+			log.WithError(err).Error("Failed to apply the lifecycle policies.")
+		}
+	}, o.interval)
+}
+
+// applyPolicies plans the actions of the lifecycle policies, prints them and applies
+// them unless this is a dry run.
+func applyPolicies(client lifecycle.PolicyClient, log *logrus.Entry, config *plugins.Configuration, dryRun bool, report io.Writer) error {
+	start := time.Now()
+	actions, err := lifecycle.PlanActions(client, log, config, start)
+	if err != nil {
+		return err
+	}
+
+	verb := "Applying"
+	if dryRun {
+		verb = "Would apply"
+	}
+	fmt.Fprintf(report, "%s %d lifecycle actions:\n", verb, len(actions))
+	for _, action := range actions {
+		fmt.Fprintf(report, "- %s\n", action)
+	}
+	if dryRun {
+		return nil
+	}
+
+	var failed int
+	for _, action := range actions {
+		if err := lifecycle.ApplyAction(client, log, action); err != nil {
+			log.WithError(err).Errorf("Failed to apply %q.", action)
+			failed++
+		}
+	}
+	log.WithField("duration", time.Since(start).String()).Infof("Applied %d of %d lifecycle actions.", len(actions)-failed, len(actions))
+	if failed > 0 {
+		return fmt.Errorf("failed to apply %d lifecycle actions", failed)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestOptions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		args        []string
+		expectedErr bool
+	}{
+		{
+			name: "dry run by default",
+			args: []string{},
+		},
+		{
+			name: "interval",
+			args: []string{"--interval=1h"},
+		},
+		{
+			name:        "negative interval",
+			args:        []string{"--interval=-1h"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := gatherOptions(flag.NewFlagSet("lifecycle-controller", flag.ContinueOnError), tc.args...)
+			if !o.dryRun {
+				t.Error("expected a dry run by default")
+			}
+			if err := o.Validate(); (err != nil) != tc.expectedErr {
+				t.Errorf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesDryRun(t *testing.T) {
+	client := fakegithub.NewFakeClient()
+	client.Issues = map[int]*github.Issue{
+		1: {
+			Number:    1,
+			Title:     "Old issue",
+			HTMLURL:   "https://github.com/org/repo/issues/1",
+			UpdatedAt: time.Now().Add(-48 * time.Hour),
+		},
+	}
+	config := &plugins.Configuration{
+		Lifecycle: []plugins.Lifecycle{{
+			Repos:              []string{"org/repo"},
+			StaleAfterDuration: 24 * time.Hour,
+		}},
+	}
+	report := &bytes.Buffer{}
+	if err := applyPolicies(client, logrus.WithField("component", "lifecycle-controller"), config, true, report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "Would apply 1 lifecycle actions:\n- org/repo#1 (issue \"Old issue\"): mark as lifecycle/stale after 48h0m0s\n"
+	if diff := cmp.Diff(expected, report.String()); diff != "" {
+		t.Errorf("report differs from expected: %s", diff)
+	}
+	if len(client.IssueLabelsAdded) != 0 || len(client.IssueComments) != 0 {
+		t.Errorf("expected no changes in a dry run, got labels %v and comments %v", client.IssueLabelsAdded, client.IssueComments)
+	}
+}
@@ -156,7 +156,7 @@ func newController(opts controllerOptions) (*controller, error) {
 				logrus.Warnf("Ignoring bad prowjob add: %v", obj)
 				return
 			}
-			c.enqueueKey(pjutil.ClusterToCtx(pj.Spec.Cluster), pj)
+			c.enqueueKey(pjutil.ClusterToCtx(pj.ClusterAlias()), pj)
 		},
 		UpdateFunc: func(old, new interface{}) {
 			pj, ok := new.(*prowjobv1.ProwJob)
@@ -164,7 +164,7 @@ func newController(opts controllerOptions) (*controller, error) {
 				logrus.Warnf("Ignoring bad prowjob update: %v", new)
 				return
 			}
-			c.enqueueKey(pjutil.ClusterToCtx(pj.Spec.Cluster), pj)
+			c.enqueueKey(pjutil.ClusterToCtx(pj.ClusterAlias()), pj)
 		},
 		DeleteFunc: func(obj interface{}) {
 			pj, ok := obj.(*prowjobv1.ProwJob)
@@ -172,7 +172,7 @@ func newController(opts controllerOptions) (*controller, error) {
 				logrus.Warnf("Ignoring bad prowjob delete: %v", obj)
 				return
 			}
-			c.enqueueKey(pjutil.ClusterToCtx(pj.Spec.Cluster), pj)
+			c.enqueueKey(pjutil.ClusterToCtx(pj.ClusterAlias()), pj)
 		},
 	})
 
@@ -374,9 +374,9 @@ func reconcile(c reconciler, key string) error {
 		// We could look for a pipeline to remove, but it is more efficient to
 		// assume this field is immutable.
 		return nil
-	case pjutil.ClusterToCtx(pj.Spec.Cluster) != ctx:
+	case pjutil.ClusterToCtx(pj.ClusterAlias()) != ctx:
 		// Build is in wrong cluster, we do not want this build
-		logrus.Warnf("%s found in context %s not %s", key, ctx, pjutil.ClusterToCtx(pj.Spec.Cluster))
+		logrus.Warnf("%s found in context %s not %s", key, ctx, pjutil.ClusterToCtx(pj.ClusterAlias()))
 	case pj.DeletionTimestamp == nil:
 		wantPipelineRun = true
 	}
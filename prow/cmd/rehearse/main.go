@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// rehearse runs as a presubmit of the repository holding the job configs. It
+// creates ProwJobs for the presubmits and periodics that the pull request
+// adds or changes, so that they are tested before they merge.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config/jobdiff"
+	"k8s.io/test-infra/prow/config/secret"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
+)
+
+type options struct {
+	repoDir       string
+	configPath    string
+	jobConfigPath string
+	base          string
+
+	samples           int
+	maxRehearsals     int
+	protectedClusters prowflagutil.Strings
+	wait              time.Duration
+	dryRun            bool
+
+	github     prowflagutil.GitHubOptions
+	kubernetes prowflagutil.KubernetesOptions
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+	if filepath.IsAbs(o.configPath) || filepath.IsAbs(o.jobConfigPath) {
+		return errors.New("--config-path and --job-config-path must be relative to --repo-dir")
+	}
+	if o.samples < 1 {
+		return errors.New("--samples must be at least 1")
+	}
+	if o.maxRehearsals < 1 {
+		return errors.New("--max-rehearsals must be at least 1")
+	}
+	if err := o.github.Validate(o.dryRun); err != nil {
+		return err
+	}
+	if err := o.kubernetes.Validate(o.dryRun); err != nil {
+		return err
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{protectedClusters: prowflagutil.NewStrings()}
+	fs.StringVar(&o.repoDir, "repo-dir", ".", "Path to the checkout of the pull request changing the configs.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml, relative to --repo-dir.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs, relative to --repo-dir.")
+	fs.StringVar(&o.base, "base", "", "Revision to compare the checkout against. Defaults to the base SHA of the job.")
+	fs.IntVar(&o.samples, "samples", 1, "Number of recent pull requests to rehearse each presubmit on.")
+	fs.IntVar(&o.maxRehearsals, "max-rehearsals", 20, "Maximum number of ProwJobs to create.")
+	fs.Var(&o.protectedClusters, "protected-cluster", "Build cluster whose jobs are never rehearsed. Can be passed repeatedly.")
+	fs.DurationVar(&o.wait, "wait", 0, "Wait this long for the rehearsals to finish and fail if any of them fails (0 to not wait).")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Print the rehearsal ProwJobs instead of creating them.")
+	o.github.AddFlags(fs)
+	o.kubernetes.AddFlags(fs)
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	spec, err := downwardapi.ResolveSpecFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to resolve the job spec, rehearse must run as a presubmit.")
+	}
+	if spec.Type != prowapi.PresubmitJob || spec.Refs == nil || len(spec.Refs.Pulls) != 1 {
+		logrus.Fatalf("rehearse must run as a presubmit for a single pull request, got a %s job", spec.Type)
+	}
+	base := o.base
+	if base == "" {
+		base = spec.Refs.BaseSHA
+	}
+	log := logrus.WithFields(logrus.Fields{"org": spec.Refs.Org, "repo": spec.Refs.Repo, "pr": spec.Refs.Pulls[0].Number})
+
+	before, err := jobdiff.LoadAtRevision(o.repoDir, base, o.configPath, o.jobConfigPath)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load base config.")
+	}
+	after, err := jobdiff.Load(o.repoDir, o.configPath, o.jobConfigPath)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load config.")
+	}
+	changes, err := jobdiff.Diff(before, after)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to diff configs.")
+	}
+
+	secretAgent := &secret.Agent{}
+	if o.github.TokenPath != "" {
+		if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+			log.WithError(err).Fatal("Error starting secrets agent.")
+		}
+	}
+	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
+	if err != nil {
+		log.WithError(err).Fatal("Error getting GitHub client.")
+	}
+
+	r := &rehearser{
+		ghc:               githubClient,
+		configPR:          *spec.Refs,
+		samples:           o.samples,
+		maxRehearsals:     o.maxRehearsals,
+		protectedClusters: o.protectedClusters.StringSet(),
+		pullRequests:      map[string][]github.PullRequest{},
+	}
+	rehearsals, err := r.rehearsals(changes)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to determine rehearsals.")
+	}
+
+	if o.dryRun {
+		for _, reh := range rehearsals {
+			if reh.prowJob == nil {
+				log.WithField("job", reh.job).Infof("Skipping rehearsal: %s", reh.skipReason)
+				continue
+			}
+			raw, err := yaml.Marshal(reh.prowJob)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to marshal ProwJob.")
+			}
+			fmt.Printf("---\n%s", raw)
+		}
+		return
+	}
+
+	prowJobClient, err := o.kubernetes.ProwJobClient(after.ProwJobNamespace, o.dryRun)
+	if err != nil {
+		log.WithError(err).Fatal("Error getting ProwJob client.")
+	}
+	ctx := context.Background()
+	for i, reh := range rehearsals {
+		if reh.prowJob == nil {
+			continue
+		}
+		created, err := prowJobClient.Create(ctx, reh.prowJob, metav1.CreateOptions{})
+		if err != nil {
+			log.WithError(err).WithField("job", reh.job).Fatal("Failed to create ProwJob.")
+		}
+		log.WithFields(logrus.Fields{"job": reh.job, "prowjob": created.Name}).Info("Created rehearsal.")
+		rehearsals[i].prowJob = created
+	}
+	if err := report(githubClient, *spec.Refs, rehearsals); err != nil {
+		log.WithError(err).Fatal("Failed to report rehearsals.")
+	}
+	if o.wait == 0 {
+		return
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, o.wait)
+	defer cancel()
+	waitForRehearsals(waitCtx, log, prowJobClient, rehearsals)
+	if err := report(githubClient, *spec.Refs, rehearsals); err != nil {
+		log.WithError(err).Fatal("Failed to report rehearsals.")
+	}
+	if failed(rehearsals) {
+		log.Fatal("Some rehearsals did not succeed.")
+	}
+}
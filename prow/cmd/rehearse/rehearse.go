@@ -0,0 +1,206 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/jobdiff"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+const (
+	// rehearsalLabel marks ProwJobs as rehearsals. Its value is the
+	// number of the pull request that changes the job.
+	rehearsalLabel = "rehearsal.prow.k8s.io/pull"
+	// rehearsalRepoLabel holds the org_repo of the pull request that
+	// changes the job.
+	rehearsalRepoLabel = "rehearsal.prow.k8s.io/repo"
+	// rehearsalJobAnnotation holds the name of the rehearsed job.
+	rehearsalJobAnnotation = "rehearsal.prow.k8s.io/job"
+)
+
+// rehearsal is a ProwJob to create, or the reason why a changed job is not
+// rehearsed.
+type rehearsal struct {
+	job     string
+	jobType prowapi.ProwJobType
+	// ref describes the refs the job is rehearsed on.
+	ref        string
+	prowJob    *prowapi.ProwJob
+	skipReason string
+}
+
+type pullRequestLister interface {
+	GetPullRequests(org, repo string) ([]github.PullRequest, error)
+}
+
+type rehearser struct {
+	ghc pullRequestLister
+	// configPR are the refs of the pull request that changes the jobs.
+	configPR          prowapi.Refs
+	samples           int
+	maxRehearsals     int
+	protectedClusters sets.String
+
+	// pullRequests caches the open pull requests per repo.
+	pullRequests map[string][]github.PullRequest
+}
+
+// rehearsals determines the ProwJobs for the presubmits and periodics that
+// the changes add or modify.
+func (r *rehearser) rehearsals(changes []jobdiff.Change) ([]rehearsal, error) {
+	var all []rehearsal
+	var count int
+	add := func(reh rehearsal) {
+		if reh.prowJob != nil {
+			if count >= r.maxRehearsals {
+				reh.prowJob = nil
+				reh.skipReason = fmt.Sprintf("at most %d jobs are rehearsed", r.maxRehearsals)
+			} else {
+				count++
+			}
+		}
+		all = append(all, reh)
+	}
+
+	for _, change := range changes {
+		if change.Kind == jobdiff.Removed {
+			continue
+		}
+		for _, job := range change.After {
+			switch j := job.(type) {
+			case config.Presubmit:
+				if reason := r.skipReason(j.JobBase); reason != "" {
+					add(rehearsal{job: j.Name, jobType: prowapi.PresubmitJob, skipReason: reason})
+					continue
+				}
+				refs, err := r.sampleRefs(change.Repo, j)
+				if err != nil {
+					return nil, err
+				}
+				if len(refs) == 0 {
+					add(rehearsal{job: j.Name, jobType: prowapi.PresubmitJob, skipReason: "no open pull requests to rehearse on"})
+					continue
+				}
+				for _, ref := range refs {
+					pj := r.prowJob(pjutil.PresubmitSpec(j, ref), j.JobBase)
+					add(rehearsal{job: j.Name, jobType: prowapi.PresubmitJob, ref: fmt.Sprintf("%s/%s#%d", ref.Org, ref.Repo, ref.Pulls[0].Number), prowJob: &pj})
+				}
+			case config.Periodic:
+				if reason := r.skipReason(j.JobBase); reason != "" {
+					add(rehearsal{job: j.Name, jobType: prowapi.PeriodicJob, skipReason: reason})
+					continue
+				}
+				pj := r.prowJob(pjutil.PeriodicSpec(j), j.JobBase)
+				add(rehearsal{job: j.Name, jobType: prowapi.PeriodicJob, prowJob: &pj})
+			}
+		}
+	}
+	return all, nil
+}
+
+func (r *rehearser) skipReason(job config.JobBase) string {
+	if job.Agent != string(prowapi.KubernetesAgent) {
+		return fmt.Sprintf("jobs with agent %q are not rehearsed", job.Agent)
+	}
+	cluster := job.Cluster
+	if cluster == "" {
+		cluster = prowapi.DefaultClusterAlias
+	}
+	if r.protectedClusters.Has(cluster) {
+		return fmt.Sprintf("cluster %q is protected", cluster)
+	}
+	return ""
+}
+
+// sampleRefs returns the refs of up to r.samples of the most recently
+// opened pull requests the presubmit would run on.
+func (r *rehearser) sampleRefs(orgRepo string, p config.Presubmit) ([]prowapi.Refs, error) {
+	repo := config.NewOrgRepo(orgRepo)
+	if repo == nil {
+		return nil, fmt.Errorf("invalid repo %q", orgRepo)
+	}
+	prs, ok := r.pullRequests[orgRepo]
+	if !ok {
+		var err error
+		prs, err = r.ghc.GetPullRequests(repo.Org, repo.Repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests of %s: %w", orgRepo, err)
+		}
+		sort.Slice(prs, func(i, j int) bool { return prs[i].Number > prs[j].Number })
+		r.pullRequests[orgRepo] = prs
+	}
+
+	var refs []prowapi.Refs
+	for _, pr := range prs {
+		if len(refs) == r.samples {
+			break
+		}
+		if !p.CouldRun(pr.Base.Ref) {
+			continue
+		}
+		refs = append(refs, prowapi.Refs{
+			Org:      repo.Org,
+			Repo:     repo.Repo,
+			RepoLink: pr.Base.Repo.HTMLURL,
+			BaseRef:  pr.Base.Ref,
+			// The base as of the last push to the pull request, which is
+			// good enough for a rehearsal.
+			BaseSHA: pr.Base.SHA,
+			Pulls: []prowapi.Pull{{
+				Number: pr.Number,
+				Author: pr.User.Login,
+				SHA:    pr.Head.SHA,
+				Link:   pr.HTMLURL,
+			}},
+		})
+	}
+	return refs, nil
+}
+
+// prowJob turns the spec into a rehearsal: the job gets a name that points
+// at the config pull request and does not report to the pull request it
+// runs on.
+func (r *rehearser) prowJob(spec prowapi.ProwJobSpec, job config.JobBase) prowapi.ProwJob {
+	spec.Job = fmt.Sprintf("rehearse-%d-%s", r.configPR.Pulls[0].Number, job.Name)
+	if spec.Context != "" {
+		spec.Context = "ci/rehearse/" + spec.Context
+	}
+	spec.Report = false
+	spec.RerunCommand = ""
+
+	labels := map[string]string{}
+	for k, v := range job.Labels {
+		labels[k] = v
+	}
+	labels[rehearsalLabel] = strconv.Itoa(r.configPR.Pulls[0].Number)
+	labels[rehearsalRepoLabel] = r.configPR.Org + "_" + r.configPR.Repo
+	annotations := map[string]string{}
+	for k, v := range job.Annotations {
+		annotations[k] = v
+	}
+	annotations[rehearsalJobAnnotation] = job.Name
+	return pjutil.NewProwJob(spec, labels, annotations)
+}
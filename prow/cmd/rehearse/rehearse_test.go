@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/jobdiff"
+	"k8s.io/test-infra/prow/github"
+)
+
+type fakePullRequestLister map[string][]github.PullRequest
+
+func (f fakePullRequestLister) GetPullRequests(org, repo string) ([]github.PullRequest, error) {
+	return f[org+"/"+repo], nil
+}
+
+func pullRequest(number int, baseRef string) github.PullRequest {
+	pr := github.PullRequest{Number: number, User: github.User{Login: "author"}}
+	pr.Base.Ref = baseRef
+	pr.Base.SHA = "base"
+	pr.Head.SHA = "head"
+	return pr
+}
+
+func TestRehearsals(t *testing.T) {
+	presubmit := func(name, cluster string, branches ...string) config.Presubmit {
+		ps := []config.Presubmit{{
+			JobBase:  config.JobBase{Name: name, Agent: string(prowapi.KubernetesAgent), Cluster: cluster},
+			Reporter: config.Reporter{Context: name},
+			Brancher: config.Brancher{Branches: branches},
+		}}
+		if err := config.SetPresubmitRegexes(ps); err != nil {
+			t.Fatalf("failed to set regexes: %v", err)
+		}
+		return ps[0]
+	}
+	periodic := config.Periodic{JobBase: config.JobBase{Name: "periodic", Agent: string(prowapi.KubernetesAgent)}}
+	jenkins := config.Periodic{JobBase: config.JobBase{Name: "jenkins", Agent: string(prowapi.JenkinsAgent)}}
+
+	changes := []jobdiff.Change{
+		{Kind: jobdiff.Removed, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-removed"},
+		{Kind: jobdiff.Added, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-added", After: []interface{}{presubmit("pull-added", "")}},
+		{Kind: jobdiff.Changed, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-release", After: []interface{}{presubmit("pull-release", "", "release")}},
+		{Kind: jobdiff.Changed, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-trusted", After: []interface{}{presubmit("pull-trusted", "trusted")}},
+		{Kind: jobdiff.Changed, Type: prowapi.PresubmitJob, Repo: "org/other", Name: "pull-other", After: []interface{}{presubmit("pull-other", "")}},
+		{Kind: jobdiff.Changed, Type: prowapi.PostsubmitJob, Repo: "org/repo", Name: "post", After: []interface{}{config.Postsubmit{JobBase: config.JobBase{Name: "post"}}}},
+		{Kind: jobdiff.Changed, Type: prowapi.PeriodicJob, Name: "jenkins", After: []interface{}{jenkins}},
+		{Kind: jobdiff.Added, Type: prowapi.PeriodicJob, Name: "periodic", After: []interface{}{periodic}},
+	}
+
+	type result struct {
+		Job, Ref, SkipReason string
+		ProwJob              string
+	}
+	var testCases = []struct {
+		name          string
+		samples       int
+		maxRehearsals int
+		expected      []result
+	}{
+		{
+			name:          "one sample",
+			samples:       1,
+			maxRehearsals: 10,
+			expected: []result{
+				{Job: "pull-added", Ref: "org/repo#3", ProwJob: "rehearse-10-pull-added"},
+				{Job: "pull-release", Ref: "org/repo#2", ProwJob: "rehearse-10-pull-release"},
+				{Job: "pull-trusted", SkipReason: `cluster "trusted" is protected`},
+				{Job: "pull-other", SkipReason: "no open pull requests to rehearse on"},
+				{Job: "jenkins", SkipReason: `jobs with agent "jenkins" are not rehearsed`},
+				{Job: "periodic", ProwJob: "rehearse-10-periodic"},
+			},
+		},
+		{
+			name:          "more samples than pull requests, limited rehearsals",
+			samples:       3,
+			maxRehearsals: 2,
+			expected: []result{
+				{Job: "pull-added", Ref: "org/repo#3", ProwJob: "rehearse-10-pull-added"},
+				{Job: "pull-added", Ref: "org/repo#2", ProwJob: "rehearse-10-pull-added"},
+				{Job: "pull-added", Ref: "org/repo#1", SkipReason: "at most 2 jobs are rehearsed"},
+				{Job: "pull-release", Ref: "org/repo#2", SkipReason: "at most 2 jobs are rehearsed"},
+				{Job: "pull-trusted", SkipReason: `cluster "trusted" is protected`},
+				{Job: "pull-other", SkipReason: "no open pull requests to rehearse on"},
+				{Job: "jenkins", SkipReason: `jobs with agent "jenkins" are not rehearsed`},
+				{Job: "periodic", SkipReason: "at most 2 jobs are rehearsed"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &rehearser{
+				ghc: fakePullRequestLister{
+					"org/repo": {pullRequest(1, "master"), pullRequest(3, "master"), pullRequest(2, "release")},
+				},
+				configPR:          prowapi.Refs{Org: "org", Repo: "config", Pulls: []prowapi.Pull{{Number: 10}}},
+				samples:           tc.samples,
+				maxRehearsals:     tc.maxRehearsals,
+				protectedClusters: sets.NewString("trusted"),
+				pullRequests:      map[string][]github.PullRequest{},
+			}
+			rehearsals, err := r.rehearsals(changes)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []result
+			for _, reh := range rehearsals {
+				res := result{Job: reh.job, Ref: reh.ref, SkipReason: reh.skipReason}
+				if reh.prowJob != nil {
+					res.ProwJob = reh.prowJob.Spec.Job
+				}
+				actual = append(actual, res)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected rehearsals (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProwJob(t *testing.T) {
+	r := &rehearser{configPR: prowapi.Refs{Org: "org", Repo: "config", Pulls: []prowapi.Pull{{Number: 10}}}}
+	job := config.JobBase{Name: "pull-job", Labels: map[string]string{"preset": "true"}}
+	refs := &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: 1}}}
+	pj := r.prowJob(prowapi.ProwJobSpec{Job: "pull-job", Type: prowapi.PresubmitJob, Context: "pull-job", Report: true, RerunCommand: "/test pull-job", Refs: refs}, job)
+
+	if pj.Spec.Job != "rehearse-10-pull-job" || pj.Spec.Context != "ci/rehearse/pull-job" {
+		t.Errorf("expected the job to be renamed, got job %q and context %q", pj.Spec.Job, pj.Spec.Context)
+	}
+	if pj.Spec.Report || pj.Spec.RerunCommand != "" {
+		t.Error("expected the rehearsal not to report to the pull request it runs on")
+	}
+	for k, v := range map[string]string{rehearsalLabel: "10", rehearsalRepoLabel: "org_config", "preset": "true"} {
+		if pj.Labels[k] != v {
+			t.Errorf("expected label %s=%s, got %v", k, v, pj.Labels)
+		}
+	}
+	if pj.Annotations[rehearsalJobAnnotation] != "pull-job" {
+		t.Errorf("expected the rehearsed job annotation, got %v", pj.Annotations)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	prowv1 "k8s.io/test-infra/prow/client/clientset/versioned/typed/prowjobs/v1"
+	"k8s.io/test-infra/prow/github"
+)
+
+// commentMarker identifies the rehearsal comment on the config pull request.
+const commentMarker = "<!-- rehearse -->"
+
+var pollInterval = 30 * time.Second
+
+// waitForRehearsals polls the ProwJobs until all of them are complete or the
+// context is done and updates the rehearsals with their latest state.
+func waitForRehearsals(ctx context.Context, log *logrus.Entry, client prowv1.ProwJobInterface, rehearsals []rehearsal) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		done := true
+		for i, reh := range rehearsals {
+			if reh.prowJob == nil || reh.prowJob.Complete() {
+				continue
+			}
+			pj, err := client.Get(ctx, reh.prowJob.Name, metav1.GetOptions{})
+			if err != nil {
+				log.WithError(err).WithField("prowjob", reh.prowJob.Name).Warn("Failed to get ProwJob.")
+				done = false
+				continue
+			}
+			rehearsals[i].prowJob = pj
+			if !pj.Complete() {
+				done = false
+			}
+		}
+		if done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// failed determines if any of the rehearsals did not succeed.
+func failed(rehearsals []rehearsal) bool {
+	for _, reh := range rehearsals {
+		if reh.prowJob != nil && reh.prowJob.Status.State != prowapi.SuccessState {
+			return true
+		}
+	}
+	return false
+}
+
+func reportBody(rehearsals []rehearsal) string {
+	if len(rehearsals) == 0 {
+		return "This pull request does not add or change any presubmits or periodics to rehearse.\n"
+	}
+	body := &strings.Builder{}
+	body.WriteString("Rehearsals of the presubmits and periodics changed by this pull request:\n\n")
+	body.WriteString("| Job | Type | Rehearsed on | State |\n| --- | --- | --- | --- |\n")
+	for _, reh := range rehearsals {
+		state := "skipped: " + reh.skipReason
+		if reh.prowJob != nil {
+			state = string(reh.prowJob.Status.State)
+			if reh.prowJob.Status.URL != "" {
+				state = fmt.Sprintf("[%s](%s)", state, reh.prowJob.Status.URL)
+			}
+		}
+		fmt.Fprintf(body, "| `%s` | %s | %s | %s |\n", reh.job, reh.jobType, reh.ref, state)
+	}
+	body.WriteString("\nRehearsals run the changed job config on existing refs and do not report to the pull requests they run on.\n")
+	return body.String()
+}
+
+type commentClient interface {
+	BotUserChecker() (func(candidate string) bool, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	CreateComment(org, repo string, number int, comment string) error
+	EditComment(org, repo string, id int, comment string) error
+}
+
+// report creates or updates the rehearsal comment on the config pull request.
+func report(ghc commentClient, configPR prowapi.Refs, rehearsals []rehearsal) error {
+	org, repo, number := configPR.Org, configPR.Repo, configPR.Pulls[0].Number
+	body := fmt.Sprintf("%s\n%s", commentMarker, reportBody(rehearsals))
+	isBot, err := ghc.BotUserChecker()
+	if err != nil {
+		return fmt.Errorf("failed to get bot user: %w", err)
+	}
+	comments, err := ghc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to list comments: %w", err)
+	}
+	for _, comment := range comments {
+		if isBot(comment.User.Login) && strings.Contains(comment.Body, commentMarker) {
+			return ghc.EditComment(org, repo, comment.ID, body)
+		}
+	}
+	return ghc.CreateComment(org, repo, number, body)
+}
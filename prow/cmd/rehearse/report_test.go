@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+type fakeCommentClient struct {
+	*fakegithub.FakeClient
+	edited map[int]string
+}
+
+func (f *fakeCommentClient) EditComment(org, repo string, id int, comment string) error {
+	f.edited[id] = comment
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	ghc := &fakeCommentClient{FakeClient: fakegithub.NewFakeClient(), edited: map[int]string{}}
+	configPR := prowapi.Refs{Org: "org", Repo: "config", Pulls: []prowapi.Pull{{Number: 10}}}
+	pj := &prowapi.ProwJob{Status: prowapi.ProwJobStatus{State: prowapi.PendingState, URL: "https://prow/view/1"}}
+	rehearsals := []rehearsal{
+		{job: "pull-job", jobType: prowapi.PresubmitJob, ref: "org/repo#1", prowJob: pj},
+		{job: "periodic", jobType: prowapi.PeriodicJob, skipReason: `cluster "trusted" is protected`},
+	}
+
+	if err := report(ghc, configPR, rehearsals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	comments := ghc.IssueComments[10]
+	if len(comments) != 1 {
+		t.Fatalf("expected one comment, got %v", comments)
+	}
+	for _, expected := range []string{
+		commentMarker,
+		"| `pull-job` | presubmit | org/repo#1 | [pending](https://prow/view/1) |",
+		"| `periodic` | periodic |  | skipped: cluster \"trusted\" is protected |",
+	} {
+		if !strings.Contains(comments[0].Body, expected) {
+			t.Errorf("expected comment to contain %q, got:\n%s", expected, comments[0].Body)
+		}
+	}
+
+	pj.Status.State = prowapi.SuccessState
+	if err := report(ghc, configPR, rehearsals); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.IssueComments[10]) != 1 {
+		t.Errorf("expected the comment to be edited instead of a new one, got %v", ghc.IssueComments[10])
+	}
+	if !strings.Contains(ghc.edited[comments[0].ID], "[success](https://prow/view/1)") {
+		t.Errorf("expected the edited comment to contain the new state, got %v", ghc.edited)
+	}
+}
+
+func TestWaitForRehearsals(t *testing.T) {
+	pollInterval = time.Millisecond
+	complete := &prowapi.ProwJob{ObjectMeta: metav1.ObjectMeta{Name: "complete"}}
+	complete.SetComplete()
+	complete.Status.State = prowapi.FailureState
+	client := fake.NewSimpleClientset(complete).ProwV1().ProwJobs("")
+
+	rehearsals := []rehearsal{
+		{job: "complete", prowJob: &prowapi.ProwJob{ObjectMeta: metav1.ObjectMeta{Name: "complete"}}},
+		{job: "skipped", skipReason: "skipped"},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	waitForRehearsals(ctx, logrus.WithField("test", t.Name()), client, rehearsals)
+	if !rehearsals[0].prowJob.Complete() {
+		t.Error("expected the rehearsal to be updated to the complete ProwJob")
+	}
+	if !failed(rehearsals) {
+		t.Error("expected the rehearsals to have failed")
+	}
+}
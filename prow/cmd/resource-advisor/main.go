@@ -0,0 +1,299 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// resource-advisor suggests resource requests and limits for the test
+// containers of jobs from the resource usage recorded by their recent runs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"k8s.io/test-infra/prow/config"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/resourceusage"
+)
+
+const (
+	// cpuStep and memoryStep are the granularity of the suggestions.
+	cpuStep    = 0.1
+	memoryStep = 64 << 20
+)
+
+type options struct {
+	configPath    string
+	jobConfigPath string
+	jobs          string
+	runs          int
+	headroom      float64
+	storage       prowflagutil.StorageClientOptions
+}
+
+func (o *options) Validate() error {
+	if o.configPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+	if o.runs < 1 {
+		return errors.New("--runs must be positive")
+	}
+	if o.headroom < 0 {
+		return errors.New("--headroom must not be negative")
+	}
+	if _, err := regexp.Compile(o.jobs); err != nil {
+		return fmt.Errorf("invalid --jobs: %v", err)
+	}
+	return o.storage.Validate(false)
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.StringVar(&o.jobs, "jobs", "", "Only advise on jobs whose name matches this regular expression.")
+	fs.IntVar(&o.runs, "runs", 10, "Number of recent runs of each job to base the suggestions on.")
+	fs.Float64Var(&o.headroom, "headroom", 0.2, "Fraction added on top of the observed usage.")
+	o.storage.AddFlags(fs)
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	cfg, err := config.Load(o.configPath, o.jobConfigPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to load config.")
+	}
+	opener, err := pkgio.NewOpener(context.Background(), o.storage.GCSCredentialsFile, o.storage.S3CredentialsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating opener.")
+	}
+
+	var suggestions []suggestion
+	for _, j := range profiledJobs(cfg, regexp.MustCompile(o.jobs)) {
+		log := logrus.WithField("job", j.name)
+		bucket, err := jobhistory.ParseBucket(j.bucket, opener)
+		if err != nil {
+			log.WithError(err).Warn("Failed to parse bucket.")
+			continue
+		}
+		runs, err := recentProfiles(context.Background(), bucket, j, o.runs)
+		if err != nil {
+			log.WithError(err).Warn("Failed to read resource usage.")
+			continue
+		}
+		for _, container := range j.containers {
+			if s, ok := suggest(j.name, container, runs, o.headroom); ok {
+				suggestions = append(suggestions, s)
+			}
+		}
+	}
+	if err := report(os.Stdout, suggestions); err != nil {
+		logrus.WithError(err).Fatal("Failed to print suggestions.")
+	}
+}
+
+// job is a decorated job that records the resource usage of its containers.
+type job struct {
+	name       string
+	bucket     string
+	root       string
+	containers []coreapi.Container
+}
+
+func profiledJobs(cfg *config.Config, filter *regexp.Regexp) []job {
+	var jobs []job
+	add := func(base config.JobBase, root string) {
+		dc := base.DecorationConfig
+		if !filter.MatchString(base.Name) || base.Spec == nil || dc == nil || dc.GCSConfiguration == nil ||
+			dc.ResourceSampleInterval == nil || dc.ResourceSampleInterval.Duration <= 0 {
+			return
+		}
+		jobs = append(jobs, job{
+			name:       base.Name,
+			bucket:     dc.GCSConfiguration.Bucket,
+			root:       path.Join(root, base.Name),
+			containers: base.Spec.Containers,
+		})
+	}
+	for _, presubmit := range cfg.AllStaticPresubmits(nil) {
+		add(presubmit.JobBase, jobhistory.PRLogsDirectory)
+	}
+	for _, postsubmit := range cfg.AllStaticPostsubmits(nil) {
+		add(postsubmit.JobBase, jobhistory.LogsPrefix)
+	}
+	for _, periodic := range cfg.AllPeriodics() {
+		add(periodic.JobBase, jobhistory.LogsPrefix)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].name < jobs[j].name })
+	return jobs
+}
+
+// recentProfiles reads the profiles of the most recent runs of the job that
+// recorded any, by container name.
+func recentProfiles(ctx context.Context, bucket jobhistory.Bucket, j job, runs int) (map[string][]resourceusage.Profile, error) {
+	ids, err := bucket.ListBuildIDs(ctx, j.root)
+	if err != nil && len(ids) == 0 {
+		return nil, err
+	}
+	sort.Slice(ids, func(i, k int) bool { return ids[i] > ids[k] })
+
+	profiles := map[string][]resourceusage.Profile{}
+	for _, id := range ids {
+		if runs == 0 {
+			break
+		}
+		var found bool
+		for _, container := range j.containers {
+			profile, err := readProfile(ctx, bucket, j.root, strconv.FormatInt(id, 10), resourceusage.ArtifactName(container.Name, len(j.containers) > 1))
+			if err != nil {
+				if !pkgio.IsNotExist(err) {
+					logrus.WithError(err).WithFields(logrus.Fields{"job": j.name, "id": id}).Warn("Skipping unreadable profile.")
+				}
+				continue
+			}
+			profiles[container.Name] = append(profiles[container.Name], *profile)
+			found = true
+		}
+		if found {
+			runs--
+		}
+	}
+	return profiles, nil
+}
+
+func readProfile(ctx context.Context, bucket jobhistory.Bucket, root, id, name string) (*resourceusage.Profile, error) {
+	key, err := bucket.GetPath(ctx, root, id, name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := bucket.ReadObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var profile resourceusage.Profile
+	if err := json.Unmarshal(raw, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return &profile, nil
+}
+
+// suggestion holds the usage of a container and the resources it should ask for.
+type suggestion struct {
+	job       string
+	container string
+	runs      int
+	// cpu is the 95th percentile of the CPU samples of all runs.
+	cpu float64
+	// memory is the peak memory usage of all runs.
+	memory    int64
+	current   resourceusage.Resources
+	suggested resourceusage.Resources
+}
+
+// suggest requests enough CPU for the 95th percentile of the samples and
+// enough memory for the peak usage, plus headroom. Memory is limited to
+// the request so that nodes are not overcommitted, while CPU is left
+// unlimited to avoid throttling.
+func suggest(jobName string, container coreapi.Container, runs map[string][]resourceusage.Profile, headroom float64) (suggestion, bool) {
+	profiles := runs[container.Name]
+	if len(profiles) == 0 {
+		return suggestion{}, false
+	}
+	var cpu []float64
+	var memory int64
+	for _, profile := range profiles {
+		for _, sample := range profile.Samples {
+			cpu = append(cpu, sample.CPU)
+			if sample.Memory > memory {
+				memory = sample.Memory
+			}
+		}
+	}
+	s := suggestion{
+		job:       jobName,
+		container: container.Name,
+		runs:      len(profiles),
+		cpu:       resourceusage.Percentile(cpu, 95),
+		memory:    memory,
+		current:   resourceusage.ResourcesFor(container.Resources),
+	}
+	s.suggested.Requests.CPU = math.Max(cpuStep, math.Ceil(s.cpu*(1+headroom)/cpuStep)*cpuStep)
+	s.suggested.Requests.Memory = int64(math.Max(memoryStep, math.Ceil(float64(s.memory)*(1+headroom)/memoryStep)*memoryStep))
+	s.suggested.Limits.Memory = s.suggested.Requests.Memory
+	return s, true
+}
+
+func report(w io.Writer, suggestions []suggestion) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tCONTAINER\tRUNS\tCPU P95\tCPU REQUEST\tMEMORY PEAK\tMEMORY REQUEST\tMEMORY LIMIT")
+	for _, s := range suggestions {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", s.job, s.container, s.runs,
+			formatCPU(s.cpu),
+			change(formatCPU(s.current.Requests.CPU), formatCPU(s.suggested.Requests.CPU)),
+			formatMemory(s.memory),
+			change(formatMemory(s.current.Requests.Memory), formatMemory(s.suggested.Requests.Memory)),
+			change(formatMemory(s.current.Limits.Memory), formatMemory(s.suggested.Limits.Memory)),
+		)
+	}
+	return tw.Flush()
+}
+
+func change(current, suggested string) string {
+	if current == suggested {
+		return current
+	}
+	return current + " -> " + suggested
+}
+
+func formatCPU(cores float64) string {
+	if cores == 0 {
+		return "-"
+	}
+	return resource.NewMilliQuantity(int64(math.Round(cores*1000)), resource.DecimalSI).String()
+}
+
+// formatMemory rounds up to whole mebibytes to keep the output readable.
+func formatMemory(bytes int64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	mebibytes := int64(math.Ceil(float64(bytes) / (1 << 20)))
+	return resource.NewQuantity(mebibytes<<20, resource.BinarySI).String()
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/resourceusage"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		input       options
+		expectedErr bool
+	}{
+		{
+			name:  "all ok",
+			input: options{configPath: "config.yaml", runs: 10, headroom: 0.2},
+		},
+		{
+			name:        "missing config",
+			input:       options{runs: 10},
+			expectedErr: true,
+		},
+		{
+			name:        "no runs",
+			input:       options{configPath: "config.yaml"},
+			expectedErr: true,
+		},
+		{
+			name:        "negative headroom",
+			input:       options{configPath: "config.yaml", runs: 10, headroom: -1},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid job regexp",
+			input:       options{configPath: "config.yaml", runs: 10, jobs: "("},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.input.Validate()
+		if testCase.expectedErr && err == nil {
+			t.Errorf("%s: expected an error but got none", testCase.name)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+		}
+	}
+}
+
+// fakeOpener serves the objects of a single bucket from memory.
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string]string
+}
+
+func (fo fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	content, ok := fo.objects[strings.TrimPrefix(p, "gs://bucket/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo fakeOpener) Iterator(_ context.Context, prefix, _ string) (pkgio.ObjectIterator, error) {
+	prefix = strings.TrimPrefix(prefix, "gs://bucket/")
+	dirs := map[string]bool{}
+	for key := range fo.objects {
+		if strings.HasPrefix(key, prefix) {
+			rest := strings.TrimPrefix(key, prefix)
+			dirs[prefix+rest[:strings.Index(rest, "/")+1]] = true
+		}
+	}
+	var attrs []pkgio.ObjectAttributes
+	for dir := range dirs {
+		attrs = append(attrs, pkgio.ObjectAttributes{Name: dir, IsDir: true})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+func TestRecentProfiles(t *testing.T) {
+	bucket := jobhistory.NewBucket("bucket", "gs", fakeOpener{objects: map[string]string{
+		"logs/ci-job/1/test-resource-usage.json":  `{"samples":[{"cpu":1}]}`,
+		"logs/ci-job/2/build-log.txt":             "no profile",
+		"logs/ci-job/3/test-resource-usage.json":  `{"samples":[{"cpu":3}]}`,
+		"logs/ci-job/3/setup-resource-usage.json": `{"samples":[{"cpu":0.5}]}`,
+		"logs/ci-job/4/test-resource-usage.json":  `{"samples":[{"cpu":4}]}`,
+	}})
+	j := job{
+		name:       "ci-job",
+		root:       "logs/ci-job",
+		containers: []coreapi.Container{{Name: "setup"}, {Name: "test"}},
+	}
+	profiles, err := recentProfiles(context.Background(), bucket, j, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string][]resourceusage.Profile{
+		"setup": {{Samples: []resourceusage.Sample{{CPU: 0.5}}}},
+		"test":  {{Samples: []resourceusage.Sample{{CPU: 4}}}, {Samples: []resourceusage.Sample{{CPU: 3}}}},
+	}
+	if diff := cmp.Diff(expected, profiles); diff != "" {
+		t.Errorf("profiles differ from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	var samples []resourceusage.Sample
+	for i := 1; i <= 20; i++ {
+		samples = append(samples, resourceusage.Sample{Time: time.Unix(int64(i), 0), CPU: float64(i) / 10, Memory: int64(i) << 20})
+	}
+	container := coreapi.Container{
+		Name: "test",
+		Resources: coreapi.ResourceRequirements{
+			Requests: coreapi.ResourceList{
+				coreapi.ResourceCPU:    resource.MustParse("4"),
+				coreapi.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		},
+	}
+	runs := map[string][]resourceusage.Profile{
+		"test": {
+			{Samples: samples[:10]},
+			{Samples: samples[10:]},
+		},
+	}
+
+	if _, ok := suggest("ci-job", coreapi.Container{Name: "other"}, runs, 0.2); ok {
+		t.Error("expected no suggestion for a container without profiles")
+	}
+	s, ok := suggest("ci-job", container, runs, 0.2)
+	if !ok {
+		t.Fatal("expected a suggestion")
+	}
+	if s.runs != 2 || s.cpu != 1.9 || s.memory != 20<<20 {
+		t.Errorf("expected 2 runs with a p95 of 1.9 cores and a peak of 20Mi, got %d runs, %v cores and %d bytes", s.runs, s.cpu, s.memory)
+	}
+	// 1.9 * 1.2 = 2.28 rounds up to 2.3 cores, 24Mi rounds up to 64Mi.
+	if s.suggested.Requests.CPU < 2.29 || s.suggested.Requests.CPU > 2.31 {
+		t.Errorf("expected a CPU request of 2.3 cores, got %v", s.suggested.Requests.CPU)
+	}
+	if s.suggested.Requests.Memory != 64<<20 || s.suggested.Limits.Memory != 64<<20 {
+		t.Errorf("expected a memory request and limit of 64Mi, got %d and %d", s.suggested.Requests.Memory, s.suggested.Limits.Memory)
+	}
+
+	var buf bytes.Buffer
+	if err := report(&buf, []suggestion{s}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `JOB     CONTAINER  RUNS  CPU P95  CPU REQUEST  MEMORY PEAK  MEMORY REQUEST  MEMORY LIMIT
+ci-job  test       2     1900m    4 -> 2300m   20Mi         1Gi -> 64Mi     - -> 64Mi
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("report differs from expected (-want +got):\n%s", diff)
+	}
+}
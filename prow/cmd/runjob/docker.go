@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// dockerVolumes maps the volumes of the pod to the source argument of a
+// docker mount. EmptyDirs become docker volumes that are shared between the
+// containers of the run, hostPaths are bind mounted and all other volumes
+// must be given a local path.
+func dockerVolumes(prefix string, spec coreapi.PodSpec, volumePaths map[string]string) (map[string]string, []string, error) {
+	sources := map[string]string{}
+	var emptyDirs []string
+	var missing []string
+	for _, vol := range spec.Volumes {
+		switch {
+		case volumePaths[vol.Name] != "":
+			sources[vol.Name] = volumePaths[vol.Name]
+		case vol.EmptyDir != nil:
+			name := prefix + "-" + vol.Name
+			sources[vol.Name] = name
+			emptyDirs = append(emptyDirs, name)
+		case vol.HostPath != nil:
+			sources[vol.Name] = vol.HostPath.Path
+		default:
+			missing = append(missing, vol.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("volumes %s need a local path, set them with --volume=name=/path", strings.Join(missing, ", "))
+	}
+	return sources, emptyDirs, nil
+}
+
+// dockerRunArgs converts a container of the decorated pod into the arguments
+// for `docker run`.
+func dockerRunArgs(name string, c coreapi.Container, volumes map[string]string, labels map[string]string) ([]string, error) {
+	args := []string{"run", "--name=" + name, "--label=runjob=true"}
+	var keys []string
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--label="+k+"="+labels[k])
+	}
+	for _, env := range c.Env {
+		if env.ValueFrom != nil {
+			return nil, fmt.Errorf("container %q: env %q uses valueFrom, which is not supported in docker mode", c.Name, env.Name)
+		}
+		args = append(args, "-e", env.Name+"="+env.Value)
+	}
+	for _, mount := range c.VolumeMounts {
+		source, ok := volumes[mount.Name]
+		if !ok {
+			return nil, fmt.Errorf("container %q: mount %q missing associated volume", c.Name, mount.Name)
+		}
+		arg := source + ":" + mount.MountPath
+		if mount.ReadOnly {
+			arg += ":ro"
+		}
+		args = append(args, "-v", arg)
+	}
+	if c.WorkingDir != "" {
+		args = append(args, "-w", c.WorkingDir)
+	}
+	if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+		args = append(args, "--privileged")
+	}
+	command := c.Command
+	if len(command) > 0 {
+		args = append(args, "--entrypoint="+command[0])
+		command = command[1:]
+	}
+	args = append(args, c.Image)
+	args = append(args, command...)
+	return append(args, c.Args...), nil
+}
+
+func docker(ctx context.Context, attach bool, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if attach {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	return cmd.Run()
+}
+
+// runInDocker runs the decorated pod with docker: the init containers run one
+// after the other and the test container then runs attached next to the other
+// containers, which are detached. The run fails if any container fails.
+func runInDocker(ctx context.Context, log *logrus.Entry, pod *coreapi.Pod, volumePaths map[string]string, grace time.Duration) error {
+	prefix := fmt.Sprintf("runjob-%s", pod.Name)
+	volumes, emptyDirs, err := dockerVolumes(prefix, pod.Spec, volumePaths)
+	if err != nil {
+		return err
+	}
+
+	// Cleanup must still happen after the context is cancelled.
+	cleanupCtx := context.Background()
+	var containers []string
+	defer func() {
+		for _, name := range containers {
+			if err := docker(cleanupCtx, false, "rm", "--force", name); err != nil {
+				log.WithError(err).WithField("container", name).Warn("Failed to remove container.")
+			}
+		}
+		for _, name := range emptyDirs {
+			if err := docker(cleanupCtx, false, "volume", "rm", "--force", name); err != nil {
+				log.WithError(err).WithField("volume", name).Warn("Failed to remove volume.")
+			}
+		}
+	}()
+	for _, name := range emptyDirs {
+		if err := docker(ctx, false, "volume", "create", name); err != nil {
+			return fmt.Errorf("create volume %s: %v", name, err)
+		}
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		name := prefix + "-" + c.Name
+		args, err := dockerRunArgs(name, c, volumes, pod.Labels)
+		if err != nil {
+			return err
+		}
+		containers = append(containers, name)
+		log.WithField("container", c.Name).Info("Running init container...")
+		if err := docker(ctx, true, args...); err != nil {
+			return fmt.Errorf("init container %s: %v", c.Name, err)
+		}
+	}
+
+	var test []string
+	for _, c := range pod.Spec.Containers {
+		name := prefix + "-" + c.Name
+		args, err := dockerRunArgs(name, c, volumes, pod.Labels)
+		if err != nil {
+			return err
+		}
+		containers = append(containers, name)
+		if c.Name == kube.TestContainerName {
+			test = args
+			continue
+		}
+		// Insert --detach right after "run".
+		args = append([]string{args[0], "--detach"}, args[1:]...)
+		if err := docker(ctx, false, args...); err != nil {
+			return fmt.Errorf("start container %s: %v", c.Name, err)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		var runErrs []error
+		if test != nil {
+			log.Info("Running test container...")
+			if err := docker(cleanupCtx, true, test...); err != nil {
+				runErrs = append(runErrs, fmt.Errorf("test container: %v", err))
+			}
+		}
+		for _, c := range pod.Spec.Containers {
+			if c.Name == kube.TestContainerName {
+				continue
+			}
+			if err := waitForContainer(cleanupCtx, prefix+"-"+c.Name); err != nil {
+				runErrs = append(runErrs, fmt.Errorf("container %s: %v", c.Name, err))
+			}
+		}
+		errs <- utilerrors.NewAggregate(runErrs)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.WithField("grace", grace).Warn("Interrupting containers...")
+	for _, c := range pod.Spec.Containers {
+		if err := docker(cleanupCtx, false, "kill", "--signal=SIGTERM", prefix+"-"+c.Name); err != nil {
+			log.WithError(err).WithField("container", c.Name).Warn("Failed to interrupt container.")
+		}
+	}
+	select {
+	case err := <-errs:
+		log.WithError(err).Info("Graceful exit after interrupt.")
+	case <-time.After(grace):
+	}
+	return fmt.Errorf("aborted: %v", ctx.Err())
+}
+
+// waitForContainer blocks until the container exits and returns an error when
+// its exit code is not zero.
+func waitForContainer(ctx context.Context, name string) error {
+	out, err := exec.CommandContext(ctx, "docker", "wait", name).Output()
+	if err != nil {
+		return err
+	}
+	if code := strings.TrimSpace(string(out)); code != "0" {
+		return fmt.Errorf("exit code %s", code)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	coreapi "k8s.io/api/core/v1"
+)
+
+func TestDockerVolumes(t *testing.T) {
+	var testCases = []struct {
+		name              string
+		volumes           []coreapi.Volume
+		volumePaths       map[string]string
+		expectedSources   map[string]string
+		expectedEmptyDirs []string
+		expectedErr       bool
+	}{
+		{
+			name: "emptyDirs and hostPaths",
+			volumes: []coreapi.Volume{
+				{Name: "logs", VolumeSource: coreapi.VolumeSource{EmptyDir: &coreapi.EmptyDirVolumeSource{}}},
+				{Name: "output", VolumeSource: coreapi.VolumeSource{HostPath: &coreapi.HostPathVolumeSource{Path: "/tmp/out"}}},
+			},
+			expectedSources:   map[string]string{"logs": "prefix-logs", "output": "/tmp/out"},
+			expectedEmptyDirs: []string{"prefix-logs"},
+		},
+		{
+			name: "secret with local path",
+			volumes: []coreapi.Volume{
+				{Name: "creds", VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: "creds"}}},
+			},
+			volumePaths:     map[string]string{"creds": "/home/me/creds"},
+			expectedSources: map[string]string{"creds": "/home/me/creds"},
+		},
+		{
+			name: "secret without local path",
+			volumes: []coreapi.Volume{
+				{Name: "creds", VolumeSource: coreapi.VolumeSource{Secret: &coreapi.SecretVolumeSource{SecretName: "creds"}}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sources, emptyDirs, err := dockerVolumes("prefix", coreapi.PodSpec{Volumes: tc.volumes}, tc.volumePaths)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if diff := cmp.Diff(tc.expectedSources, sources); diff != "" {
+				t.Errorf("unexpected sources (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expectedEmptyDirs, emptyDirs); diff != "" {
+				t.Errorf("unexpected emptyDirs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDockerRunArgs(t *testing.T) {
+	privileged := true
+	var testCases = []struct {
+		name        string
+		container   coreapi.Container
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name: "full container",
+			container: coreapi.Container{
+				Name:            "test",
+				Image:           "image",
+				Command:         []string{"/tools/entrypoint", "--flag"},
+				Args:            []string{"arg"},
+				WorkingDir:      "/home/prow/go/src/k8s.io/test-infra",
+				Env:             []coreapi.EnvVar{{Name: "KEY", Value: "value"}},
+				VolumeMounts:    []coreapi.VolumeMount{{Name: "logs", MountPath: "/logs"}, {Name: "creds", MountPath: "/creds", ReadOnly: true}},
+				SecurityContext: &coreapi.SecurityContext{Privileged: &privileged},
+			},
+			expected: []string{
+				"run", "--name=name", "--label=runjob=true", "--label=a=b", "--label=c=d",
+				"-e", "KEY=value",
+				"-v", "prefix-logs:/logs", "-v", "/home/me/creds:/creds:ro",
+				"-w", "/home/prow/go/src/k8s.io/test-infra",
+				"--privileged",
+				"--entrypoint=/tools/entrypoint", "image", "--flag", "arg",
+			},
+		},
+		{
+			name:      "image only",
+			container: coreapi.Container{Name: "test", Image: "image", Args: []string{"arg"}},
+			expected:  []string{"run", "--name=name", "--label=runjob=true", "--label=a=b", "--label=c=d", "image", "arg"},
+		},
+		{
+			name: "env from secret",
+			container: coreapi.Container{
+				Name:  "test",
+				Image: "image",
+				Env:   []coreapi.EnvVar{{Name: "KEY", ValueFrom: &coreapi.EnvVarSource{}}},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "mount without volume",
+			container: coreapi.Container{
+				Name:         "test",
+				Image:        "image",
+				VolumeMounts: []coreapi.VolumeMount{{Name: "missing", MountPath: "/missing"}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	volumes := map[string]string{"logs": "prefix-logs", "creds": "/home/me/creds"}
+	labels := map[string]string{"c": "d", "a": "b"}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := dockerRunArgs("name", tc.container, volumes, labels)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if diff := cmp.Diff(tc.expected, args); diff != "" {
+				t.Errorf("unexpected args (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
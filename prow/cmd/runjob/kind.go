@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+var pollInterval = 5 * time.Second
+
+// runInCluster creates the decorated pod in the cluster and waits for it to
+// finish. The output directory of the pod is a hostPath, so the kind node must
+// mount it from the host for the artifacts to show up locally, see
+// prow/pj-on-kind.sh for an example kind config.
+func runInCluster(ctx context.Context, log *logrus.Entry, client corev1.PodInterface, pod *coreapi.Pod, grace time.Duration) error {
+	log = log.WithField("pod", pod.Name)
+	if _, err := client.Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("create pod: %v", err)
+	}
+	log.Info("Created pod, waiting for it to finish...")
+
+	var phase coreapi.PodPhase
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := client.Get(ctx, pod.Name, metav1.GetOptions{})
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.WithError(err).Warn("Failed to get pod.")
+		case err == nil:
+			if current.Status.Phase != phase {
+				phase = current.Status.Phase
+				log.WithField("phase", phase).Info("Pod changed phase.")
+			}
+			switch phase {
+			case coreapi.PodSucceeded:
+				return nil
+			case coreapi.PodFailed:
+				return fmt.Errorf("pod failed: %s %s", current.Status.Reason, current.Status.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			gracePeriod := int64(grace.Seconds())
+			if err := client.Delete(context.Background(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
+				log.WithError(err).Warn("Failed to delete pod.")
+			}
+			return fmt.Errorf("aborted: %v", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
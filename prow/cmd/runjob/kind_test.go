@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestRunInCluster(t *testing.T) {
+	pollInterval = time.Millisecond
+	var testCases = []struct {
+		name        string
+		phases      []coreapi.PodPhase
+		expectedErr bool
+	}{
+		{
+			name:   "pod succeeds",
+			phases: []coreapi.PodPhase{coreapi.PodPending, coreapi.PodRunning, coreapi.PodSucceeded},
+		},
+		{
+			name:        "pod fails",
+			phases:      []coreapi.PodPhase{coreapi.PodRunning, coreapi.PodFailed},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			gets := 0
+			client.PrependReactor("get", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				phase := tc.phases[len(tc.phases)-1]
+				if gets < len(tc.phases) {
+					phase = tc.phases[gets]
+				}
+				gets++
+				return true, &coreapi.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"},
+					Status:     coreapi.PodStatus{Phase: phase},
+				}, nil
+			})
+			pod := &coreapi.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}}
+			err := runInCluster(context.Background(), logrus.WithField("test", tc.name), client.CoreV1().Pods("default"), pod, time.Second)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if gets != len(tc.phases) {
+				t.Errorf("expected %d polls, got %d", len(tc.phases), gets)
+			}
+		})
+	}
+}
+
+func TestRunInClusterAbort(t *testing.T) {
+	pollInterval = time.Millisecond
+	client := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pod := &coreapi.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"}}
+	if err := runInCluster(ctx, logrus.WithField("test", "abort"), client.CoreV1().Pods("default"), pod, time.Second); err == nil {
+		t.Fatal("expected an error for an aborted run")
+	}
+	if _, err := client.CoreV1().Pods("default").Get(context.Background(), "pod", metav1.GetOptions{}); err == nil {
+		t.Error("expected the pod to be deleted after the run was aborted")
+	}
+}
@@ -0,0 +1,378 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pod-utils/decorate"
+)
+
+const (
+	dockerMode = "docker"
+	kindMode   = "kind"
+)
+
+type options struct {
+	configPath    string
+	jobConfigPath string
+	jobName       string
+
+	repo       string
+	baseRef    string
+	baseSHA    string
+	pullNumber int
+	pullSHA    string
+	pullAuthor string
+	gitHost    string
+
+	buildID     string
+	outputDir   string
+	mode        string
+	printPod    bool
+	timeout     time.Duration
+	grace       time.Duration
+	volumePaths map[string]string
+
+	kubeContext string
+	namespace   string
+	kubeOptions prowflagutil.KubernetesOptions
+}
+
+func (o *options) Validate() error {
+	if o.jobName == "" {
+		return errors.New("required flag --job was unset")
+	}
+	if o.configPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+	if o.mode != dockerMode && o.mode != kindMode {
+		return fmt.Errorf("--mode must be one of %q or %q, not %q", dockerMode, kindMode, o.mode)
+	}
+	if o.repo != "" && config.NewOrgRepo(o.repo) == nil {
+		return fmt.Errorf("--repo must be in org/repo format, not %q", o.repo)
+	}
+	if o.pullSHA != "" && o.pullNumber == 0 {
+		return errors.New("--pull-sha requires --pull-number")
+	}
+	if o.mode == kindMode {
+		if err := o.kubeOptions.Validate(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	o := options{volumePaths: map[string]string{}}
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.StringVar(&o.jobName, "job", "", "Job to run.")
+	fs.StringVar(&o.repo, "repo", "", "Repository (org/repo) the job belongs to. Required for jobs defined in the repository's .prow.yaml.")
+	fs.StringVar(&o.baseRef, "base-ref", "", "Git base ref under test.")
+	fs.StringVar(&o.baseSHA, "base-sha", "", "Git base SHA under test. Required for jobs defined in the repository's .prow.yaml.")
+	fs.IntVar(&o.pullNumber, "pull-number", 0, "Git pull number under test.")
+	fs.StringVar(&o.pullSHA, "pull-sha", "", "Git pull SHA under test. Required for presubmits defined in the repository's .prow.yaml.")
+	fs.StringVar(&o.pullAuthor, "pull-author", "", "Git pull author under test.")
+	fs.StringVar(&o.gitHost, "git-host", "github.com", "Host to clone from when loading jobs from the repository's .prow.yaml.")
+	fs.StringVar(&o.buildID, "build-id", "", "Build ID for the job run. Generated if unset.")
+	fs.StringVar(&o.outputDir, "out-dir", "", "Directory to copy job artifacts to instead of uploading them to blob storage. A temp dir is created if unset.")
+	fs.StringVar(&o.mode, "mode", dockerMode, fmt.Sprintf("Where to run the decorated pod, either %q or %q.", dockerMode, kindMode))
+	fs.BoolVar(&o.printPod, "print", false, "Just print the decorated pod instead of running it.")
+	fs.DurationVar(&o.timeout, "timeout", 2*time.Hour, "Maximum duration for the job (0 for unlimited).")
+	fs.DurationVar(&o.grace, "grace", 15*time.Second, "Terminate a timed out job after this grace period.")
+	fs.Var(&volumePathsFlag{paths: o.volumePaths}, "volume", "Local path to use for a pod volume that is not an emptyDir or hostPath, as name=/path. Can be passed repeatedly.")
+	fs.StringVar(&o.kubeContext, "context", "kind-mkpod", "Kubeconfig context of the kind cluster to run the pod in, used with --mode=kind.")
+	fs.StringVar(&o.namespace, "namespace", "default", "Namespace to create the pod in, used with --mode=kind.")
+	o.kubeOptions.AddFlags(fs)
+	fs.Parse(args)
+	return o
+}
+
+// volumePathsFlag collects name=path pairs into a map.
+type volumePathsFlag struct {
+	paths map[string]string
+}
+
+func (v *volumePathsFlag) String() string {
+	var pairs []string
+	for name, path := range v.paths {
+		pairs = append(pairs, name+"="+path)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (v *volumePathsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=path, got %q", value)
+	}
+	path, err := filepath.Abs(os.ExpandEnv(parts[1]))
+	if err != nil {
+		return err
+	}
+	v.paths[parts[0]] = path
+	return nil
+}
+
+func main() {
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	cfg, err := config.Load(o.configPath, o.jobConfigPath)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error loading config.")
+	}
+
+	var gc git.ClientFactory
+	if o.repo != "" && cfg.InRepoConfigEnabled(o.repo) {
+		gc, err = git.NewClientFactory(func(opts *git.ClientFactoryOpts) { opts.Host = o.gitHost })
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating git client.")
+		}
+	}
+
+	spec, labels, annotations, err := o.resolveJob(cfg, gc)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not resolve job.")
+	}
+	pj := pjutil.NewProwJob(spec, labels, annotations)
+	if o.buildID == "" {
+		// No error possible since this won't use tot.
+		o.buildID, _ = pjutil.GetBuildID(pj.Spec.Job, "")
+	}
+	pj.Status.BuildID = o.buildID
+
+	outDir, err := o.jobOutputDir(pj.Spec.Job)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not create job output directory.")
+	}
+	pod, err := localPod(pj, outDir)
+	if err != nil {
+		logrus.WithError(err).Fatal("Could not decorate PodSpec.")
+	}
+
+	if o.printPod {
+		pod.GetObjectKind().SetGroupVersionKind(coreapi.SchemeGroupVersion.WithKind("Pod"))
+		podYAML, err := yaml.Marshal(pod)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not marshal Pod YAML.")
+		}
+		fmt.Println(string(podYAML))
+		return
+	}
+
+	log := logrus.WithFields(logrus.Fields{"job": pj.Spec.Job, "build-id": o.buildID, "out-dir": outDir})
+	ctx := interrupts.Context()
+	if o.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	switch o.mode {
+	case kindMode:
+		client, clientErr := o.kubeOptions.ClusterClientForContext(o.kubeContext, false)
+		if clientErr != nil {
+			log.WithError(clientErr).Fatal("Could not create client for kind cluster.")
+		}
+		err = runInCluster(ctx, log, client.CoreV1().Pods(o.namespace), pod, o.grace)
+	default:
+		err = runInDocker(ctx, log, pod, o.volumePaths, o.grace)
+	}
+	log = log.WithField("duration", time.Since(start).Round(time.Second))
+	log.Infof("Artifacts are in %s", artifactURL(outDir))
+	log.Infof("View them with a local deck at /view/%s/%s", providers.File, strings.TrimPrefix(filepath.ToSlash(outDir), "/"))
+	if err != nil {
+		log.WithError(err).Fatal("FAIL")
+	}
+	log.Info("PASS")
+}
+
+// resolveJob finds the job in the static config or, when the repository has
+// inrepoconfig enabled, in the .prow.yaml at the requested refs and returns the
+// spec for it along with the labels and annotations of the job. Without --repo,
+// the job must be defined for a single repository.
+func (o *options) resolveJob(cfg *config.Config, gc git.ClientFactory) (prowapi.ProwJobSpec, map[string]string, map[string]string, error) {
+	if o.repo != "" {
+		spec, labels, annotations, found, err := o.resolveRepoJob(cfg, gc, o.repo)
+		if err != nil || found {
+			return spec, labels, annotations, err
+		}
+	} else {
+		identifiers := sets.NewString()
+		for identifier := range cfg.PresubmitsStatic {
+			identifiers.Insert(identifier)
+		}
+		for identifier := range cfg.PostsubmitsStatic {
+			identifiers.Insert(identifier)
+		}
+		var matches []string
+		var spec prowapi.ProwJobSpec
+		var labels, annotations map[string]string
+		for _, identifier := range identifiers.List() {
+			s, l, a, found, err := o.resolveRepoJob(cfg, gc, identifier)
+			if err != nil {
+				return prowapi.ProwJobSpec{}, nil, nil, err
+			}
+			if found {
+				matches = append(matches, identifier)
+				spec, labels, annotations = s, l, a
+			}
+		}
+		switch len(matches) {
+		case 0:
+		case 1:
+			return spec, labels, annotations, nil
+		default:
+			return prowapi.ProwJobSpec{}, nil, nil, fmt.Errorf("job %s is defined for several repositories (%s), pass --repo to pick one", o.jobName, strings.Join(matches, ", "))
+		}
+	}
+
+	for _, p := range cfg.AllPeriodics() {
+		if p.Name == o.jobName {
+			return pjutil.PeriodicSpec(p), p.Labels, p.Annotations, nil
+		}
+	}
+	return prowapi.ProwJobSpec{}, nil, nil, fmt.Errorf("job %s not found", o.jobName)
+}
+
+// resolveRepoJob looks for the job among the presubmits and postsubmits of the
+// repository and reports whether it was found.
+func (o *options) resolveRepoJob(cfg *config.Config, gc git.ClientFactory, identifier string) (prowapi.ProwJobSpec, map[string]string, map[string]string, bool, error) {
+	org, repo, err := splitRepoName(identifier)
+	if err != nil {
+		logrus.WithError(err).Warnf("Invalid repo name %s.", identifier)
+		return prowapi.ProwJobSpec{}, nil, nil, false, nil
+	}
+	refs := prowapi.Refs{Org: org, Repo: repo, BaseRef: o.baseRef, BaseSHA: o.baseSHA}
+	baseSHAGetter := func() (string, error) {
+		if o.baseSHA == "" {
+			return "", errors.New("--base-sha is required to load jobs from .prow.yaml")
+		}
+		return o.baseSHA, nil
+	}
+
+	presubmits := cfg.PresubmitsStatic[identifier]
+	if gc != nil && o.pullNumber != 0 {
+		pullSHAGetter := func() (string, error) {
+			if o.pullSHA == "" {
+				return "", errors.New("--pull-sha is required to load presubmits from .prow.yaml")
+			}
+			return o.pullSHA, nil
+		}
+		if presubmits, err = cfg.GetPresubmits(gc, identifier, baseSHAGetter, pullSHAGetter); err != nil {
+			return prowapi.ProwJobSpec{}, nil, nil, false, fmt.Errorf("failed to get presubmits for %s: %v", identifier, err)
+		}
+	}
+	for _, p := range presubmits {
+		if p.Name != o.jobName {
+			continue
+		}
+		if o.pullNumber == 0 {
+			return prowapi.ProwJobSpec{}, nil, nil, false, fmt.Errorf("job %s is a presubmit, --pull-number is required", o.jobName)
+		}
+		refs.Pulls = []prowapi.Pull{{Number: o.pullNumber, SHA: o.pullSHA, Author: o.pullAuthor}}
+		return pjutil.PresubmitSpec(p, refs), p.Labels, p.Annotations, true, nil
+	}
+
+	postsubmits := cfg.PostsubmitsStatic[identifier]
+	if gc != nil && o.pullNumber == 0 {
+		if postsubmits, err = cfg.GetPostsubmits(gc, identifier, baseSHAGetter); err != nil {
+			return prowapi.ProwJobSpec{}, nil, nil, false, fmt.Errorf("failed to get postsubmits for %s: %v", identifier, err)
+		}
+	}
+	for _, p := range postsubmits {
+		if p.Name == o.jobName {
+			return pjutil.PostsubmitSpec(p, refs), p.Labels, p.Annotations, true, nil
+		}
+	}
+	return prowapi.ProwJobSpec{}, nil, nil, false, nil
+}
+
+// jobOutputDir returns the directory the pod utilities copy the artifacts of
+// this run to, creating it if necessary.
+func (o *options) jobOutputDir(job string) (string, error) {
+	if o.outputDir == "" {
+		prefix := strings.Join([]string{"prowjob-out", job, o.buildID}, "-")
+		return ioutil.TempDir("", prefix)
+	}
+	outDir, err := filepath.Abs(filepath.Join(o.outputDir, job, o.buildID))
+	if err != nil {
+		return "", err
+	}
+	return outDir, os.MkdirAll(outDir, 0755)
+}
+
+// localPod decorates the job for local mode and strips the created-by-prow
+// label so that a sinker watching the cluster does not clean up the pod.
+func localPod(pj prowapi.ProwJob, outDir string) (*coreapi.Pod, error) {
+	pod, err := decorate.ProwJobToPodLocal(pj, outDir)
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{}
+	for k, v := range pod.Labels {
+		if k == kube.CreatedByProw {
+			continue
+		}
+		labels[k] = v
+	}
+	pod.Labels = labels
+	return pod, nil
+}
+
+// artifactURL returns the file:// URL of a local output directory.
+func artifactURL(outDir string) string {
+	return fmt.Sprintf("%s://%s", providers.File, filepath.ToSlash(outDir))
+}
+
+func splitRepoName(repo string) (string, string, error) {
+	// Normalize repo name to remove http:// or https://, this is the case for some
+	// of the gerrit instances.
+	repo = strings.TrimPrefix(repo, "http://")
+	repo = strings.TrimPrefix(repo, "https://")
+	s := strings.SplitN(repo, "/", 2)
+	if len(s) != 2 {
+		return "", "", fmt.Errorf("repo %s cannot be split into org/repo", repo)
+	}
+	return s[0], s[1], nil
+}
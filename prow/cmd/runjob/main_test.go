@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	coreapi "k8s.io/api/core/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		input       options
+		expectedErr bool
+	}{
+		{
+			name:  "all ok",
+			input: options{jobName: "job", configPath: "config.yaml", mode: dockerMode},
+		},
+		{
+			name:        "missing job",
+			input:       options{configPath: "config.yaml", mode: dockerMode},
+			expectedErr: true,
+		},
+		{
+			name:        "missing config",
+			input:       options{jobName: "job", mode: dockerMode},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown mode",
+			input:       options{jobName: "job", configPath: "config.yaml", mode: "podman"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid repo",
+			input:       options{jobName: "job", configPath: "config.yaml", mode: dockerMode, repo: "a/b/c"},
+			expectedErr: true,
+		},
+		{
+			name:        "pull sha without pull number",
+			input:       options{jobName: "job", configPath: "config.yaml", mode: dockerMode, pullSHA: "abc"},
+			expectedErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.input.Validate()
+		if testCase.expectedErr && err == nil {
+			t.Errorf("%s: expected an error but got none", testCase.name)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Errorf("%s: expected no error but got one: %v", testCase.name, err)
+		}
+	}
+}
+
+func TestVolumeFlag(t *testing.T) {
+	o := gatherOptions(flag.NewFlagSet("runjob", flag.ContinueOnError), "--volume=creds=/tmp/creds", "--volume=cache=/var/cache")
+	expected := map[string]string{"creds": "/tmp/creds", "cache": "/var/cache"}
+	if diff := cmp.Diff(expected, o.volumePaths); diff != "" {
+		t.Errorf("unexpected volume paths (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolveJob(t *testing.T) {
+	jobBase := func(name string) config.JobBase {
+		return config.JobBase{Name: name, Labels: map[string]string{"job": name}}
+	}
+	cfg := &config.Config{
+		JobConfig: config.JobConfig{
+			PresubmitsStatic: map[string][]config.Presubmit{
+				"org/repo": {{JobBase: jobBase("pull-static")}},
+			},
+			PostsubmitsStatic: map[string][]config.Postsubmit{
+				"org/repo":  {{JobBase: jobBase("post-static")}, {JobBase: jobBase("post-shared")}},
+				"org/other": {{JobBase: jobBase("post-shared")}},
+			},
+			Periodics: []config.Periodic{{JobBase: jobBase("periodic")}},
+		},
+		ProwConfig: config.ProwConfig{
+			InRepoConfig: config.InRepoConfig{Enabled: map[string]*bool{"org/inrepo": &[]bool{true}[0]}},
+		},
+	}
+	cfg.ProwYAMLGetter = func(_ *config.Config, _ git.ClientFactory, identifier, baseSHA string, headSHAs ...string) (*config.ProwYAML, error) {
+		if baseSHA != "base" {
+			t.Errorf("expected base SHA %q, got %q", "base", baseSHA)
+		}
+		return &config.ProwYAML{
+			Presubmits:  []config.Presubmit{{JobBase: jobBase("pull-inrepo")}},
+			Postsubmits: []config.Postsubmit{{JobBase: jobBase("post-inrepo")}},
+		}, nil
+	}
+
+	var testCases = []struct {
+		name         string
+		options      options
+		inRepo       bool
+		expectedType prowapi.ProwJobType
+		expectedRefs *prowapi.Refs
+		expectedErr  bool
+	}{
+		{
+			name:         "static presubmit",
+			options:      options{jobName: "pull-static", baseRef: "master", pullNumber: 1, pullSHA: "pull"},
+			expectedType: prowapi.PresubmitJob,
+			expectedRefs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", Pulls: []prowapi.Pull{{Number: 1, SHA: "pull"}}},
+		},
+		{
+			name:        "static presubmit without pull",
+			options:     options{jobName: "pull-static", baseRef: "master"},
+			expectedErr: true,
+		},
+		{
+			name:         "static postsubmit",
+			options:      options{jobName: "post-static", baseRef: "master", baseSHA: "base"},
+			expectedType: prowapi.PostsubmitJob,
+			expectedRefs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"},
+		},
+		{
+			name:        "postsubmit of several repos",
+			options:     options{jobName: "post-shared", baseRef: "master"},
+			expectedErr: true,
+		},
+		{
+			name:         "postsubmit of several repos with repo",
+			options:      options{jobName: "post-shared", repo: "org/other", baseRef: "master"},
+			expectedType: prowapi.PostsubmitJob,
+			expectedRefs: &prowapi.Refs{Org: "org", Repo: "other", BaseRef: "master"},
+		},
+		{
+			name:         "periodic",
+			options:      options{jobName: "periodic"},
+			expectedType: prowapi.PeriodicJob,
+		},
+		{
+			name:         "inrepoconfig presubmit",
+			options:      options{jobName: "pull-inrepo", repo: "org/inrepo", baseRef: "master", baseSHA: "base", pullNumber: 2, pullSHA: "pull"},
+			inRepo:       true,
+			expectedType: prowapi.PresubmitJob,
+			expectedRefs: &prowapi.Refs{Org: "org", Repo: "inrepo", BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 2, SHA: "pull"}}},
+		},
+		{
+			name:         "inrepoconfig postsubmit",
+			options:      options{jobName: "post-inrepo", repo: "org/inrepo", baseRef: "master", baseSHA: "base"},
+			inRepo:       true,
+			expectedType: prowapi.PostsubmitJob,
+			expectedRefs: &prowapi.Refs{Org: "org", Repo: "inrepo", BaseRef: "master", BaseSHA: "base"},
+		},
+		{
+			name:        "inrepoconfig presubmit without pull sha",
+			options:     options{jobName: "pull-inrepo", repo: "org/inrepo", baseRef: "master", baseSHA: "base", pullNumber: 2},
+			inRepo:      true,
+			expectedErr: true,
+		},
+		{
+			name:        "unknown job",
+			options:     options{jobName: "missing"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gc git.ClientFactory
+			if tc.inRepo {
+				gc = git.ClientFactoryFrom(nil)
+			}
+			spec, labels, _, err := tc.options.resolveJob(cfg, gc)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if spec.Type != tc.expectedType {
+				t.Errorf("expected type %s, got %s", tc.expectedType, spec.Type)
+			}
+			if diff := cmp.Diff(tc.expectedRefs, spec.Refs); diff != "" {
+				t.Errorf("unexpected refs (-want +got):\n%s", diff)
+			}
+			if labels["job"] != tc.options.jobName {
+				t.Errorf("expected the job labels, got %v", labels)
+			}
+		})
+	}
+}
+
+func TestLocalPod(t *testing.T) {
+	pj := prowapi.ProwJob{
+		Spec: prowapi.ProwJobSpec{
+			Job:     "job",
+			Type:    prowapi.PeriodicJob,
+			PodSpec: &coreapi.PodSpec{Containers: []coreapi.Container{{Image: "image"}}},
+		},
+	}
+	pj.Name = "name"
+	pj.Labels = map[string]string{kube.CreatedByProw: "true", "other": "label"}
+	pod, err := localPod(pj, "/tmp/out")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := pod.Labels[kube.CreatedByProw]; ok {
+		t.Errorf("expected %s label to be removed, got %v", kube.CreatedByProw, pod.Labels)
+	}
+}
+
+func TestArtifactURL(t *testing.T) {
+	if got, expected := artifactURL("/tmp/out/job/1"), "file:///tmp/out/job/1"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
@@ -41,9 +41,11 @@ import (
 	kubernetesreporterapi "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes/api"
 	"k8s.io/test-infra/prow/flagutil"
 	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjarchive"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/version"
 )
@@ -54,6 +56,7 @@ type options struct {
 	jobConfigPath          string
 	dryRun                 flagutil.Bool
 	kubernetes             flagutil.KubernetesOptions
+	storage                flagutil.StorageClientOptions
 	instrumentationOptions flagutil.InstrumentationOptions
 }
 
@@ -64,6 +67,8 @@ const (
 
 	reasonProwJobAged         = "aged"
 	reasonProwJobAgedPeriodic = "aged-periodic"
+
+	reasonProwJobArchiveFailed = "archive-failed"
 )
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
@@ -76,6 +81,7 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.Var(&o.dryRun, "dry-run", "Whether or not to make mutating API calls to Kubernetes.")
 
 	o.kubernetes.AddFlags(fs)
+	o.storage.AddFlags(fs)
 	o.instrumentationOptions.AddFlags(fs)
 	fs.Parse(args)
 	return o
@@ -86,6 +92,10 @@ func (o *options) Validate() error {
 		return err
 	}
 
+	if err := o.storage.Validate(o.dryRun.Value); err != nil {
+		return err
+	}
+
 	if o.configPath == "" {
 		return errors.New("--config-path is required")
 	}
@@ -164,6 +174,12 @@ func main() {
 		buildClusterClients[clusterName] = buildManager.GetClient()
 	}
 
+	// ProwJobs are archived if the config asks for it, which may change later on.
+	opener, err := o.storage.StorageClient(context.Background())
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating opener")
+	}
+
 	c := controller{
 		ctx:           context.Background(),
 		logger:        logrus.NewEntry(logrus.StandardLogger()),
@@ -171,6 +187,8 @@ func main() {
 		podClients:    buildClusterClients,
 		config:        cfg,
 		runOnce:       o.runOnce,
+		dryRun:        o.dryRun.Value,
+		opener:        opener,
 	}
 	if err := mgr.Add(&c); err != nil {
 		logrus.WithError(err).Fatal("failed to add controller to manager")
@@ -189,6 +207,9 @@ type controller struct {
 	podClients    map[string]ctrlruntimeclient.Client
 	config        config.Getter
 	runOnce       bool
+	dryRun        bool
+	// opener is used to archive ProwJobs before they are deleted.
+	opener io.Opener
 }
 
 func (c *controller) Start(ctx context.Context) error {
@@ -227,6 +248,7 @@ type sinkerReconciliationMetrics struct {
 	prowJobsCreated        int
 	prowJobsCleaned        map[string]int
 	prowJobsCleaningErrors map[string]int
+	prowJobsArchived       int
 }
 
 // Prometheus Metrics
@@ -239,6 +261,7 @@ var (
 		prowJobsCreated        prometheus.Gauge
 		prowJobsCleaned        *prometheus.GaugeVec
 		prowJobsCleaningErrors *prometheus.GaugeVec
+		prowJobsArchived       prometheus.Gauge
 	}{
 		podsCreated: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "sinker_pods_existing",
@@ -276,6 +299,10 @@ var (
 		}, []string{
 			"reason",
 		}),
+		prowJobsArchived: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "sinker_prow_jobs_archived",
+			Help: "Number of prow jobs archived in each sinker cleaning.",
+		}),
 	}
 )
 
@@ -287,6 +314,7 @@ func init() {
 	prometheus.MustRegister(sinkerMetrics.prowJobsCreated)
 	prometheus.MustRegister(sinkerMetrics.prowJobsCleaned)
 	prometheus.MustRegister(sinkerMetrics.prowJobsCleaningErrors)
+	prometheus.MustRegister(sinkerMetrics.prowJobsArchived)
 }
 
 func (m *sinkerReconciliationMetrics) getTimeUsed() time.Duration {
@@ -314,6 +342,10 @@ func (c *controller) clean() {
 	pjMap := map[string]*prowapi.ProwJob{}
 	isFinished := sets.NewString()
 
+	// ProwJobs are deleted once they have been archived.
+	var agedProwJobs []prowapi.ProwJob
+	var reasons []string
+
 	maxProwJobAge := c.config().Sinker.MaxProwJobAge.Duration
 	for i, prowJob := range prowJobs.Items {
 		pjMap[prowJob.ObjectMeta.Name] = &prowJobs.Items[i]
@@ -328,13 +360,8 @@ func (c *controller) clean() {
 		if time.Since(prowJob.Status.StartTime.Time) <= maxProwJobAge {
 			continue
 		}
-		if err := c.prowJobClient.Delete(c.ctx, &prowJob); err == nil {
-			c.logger.WithFields(pjutil.ProwJobFields(&prowJob)).Info("Deleted prowjob.")
-			metrics.prowJobsCleaned[reasonProwJobAged]++
-		} else {
-			c.logger.WithFields(pjutil.ProwJobFields(&prowJob)).WithError(err).Error("Error deleting prowjob.")
-			metrics.prowJobsCleaningErrors[string(k8serrors.ReasonForError(err))]++
-		}
+		agedProwJobs = append(agedProwJobs, prowJob)
+		reasons = append(reasons, reasonProwJobAged)
 	}
 
 	// Keep track of what periodic jobs are in the config so we will
@@ -364,15 +391,12 @@ func (c *controller) clean() {
 		if time.Since(prowJob.Status.StartTime.Time) <= maxProwJobAge {
 			continue
 		}
-		if err := c.prowJobClient.Delete(c.ctx, &prowJob); err == nil {
-			c.logger.WithFields(pjutil.ProwJobFields(&prowJob)).Info("Deleted prowjob.")
-			metrics.prowJobsCleaned[reasonProwJobAgedPeriodic]++
-		} else {
-			c.logger.WithFields(pjutil.ProwJobFields(&prowJob)).WithError(err).Error("Error deleting prowjob.")
-			metrics.prowJobsCleaningErrors[string(k8serrors.ReasonForError(err))]++
-		}
+		agedProwJobs = append(agedProwJobs, prowJob)
+		reasons = append(reasons, reasonProwJobAgedPeriodic)
 	}
 
+	c.deleteProwJobs(agedProwJobs, reasons, &metrics)
+
 	// Now clean up old pods.
 	for cluster, client := range c.podClients {
 		log := c.logger.WithField("cluster", cluster)
@@ -464,10 +488,46 @@ func (c *controller) clean() {
 	for k, v := range metrics.prowJobsCleaningErrors {
 		sinkerMetrics.prowJobsCleaningErrors.WithLabelValues(k).Set(float64(v))
 	}
+	sinkerMetrics.prowJobsArchived.Set(float64(metrics.prowJobsArchived))
 	version.GatherProwVersion(c.logger)
 	c.logger.Info("Sinker reconciliation complete.")
 }
 
+// deleteProwJobs deletes the given ProwJobs after archiving them, if an archive
+// location is configured. Nothing is deleted if archiving fails so that no job
+// history is lost. Nothing is archived in dry-run mode.
+func (c *controller) deleteProwJobs(prowJobs []prowapi.ProwJob, reasons []string, m *sinkerReconciliationMetrics) {
+	if len(prowJobs) == 0 {
+		return
+	}
+	if location := c.config().Sinker.ArchiveLocation; location != "" && c.dryRun {
+		c.logger.WithField("count", len(prowJobs)).Info("Not archiving prowjobs in dry-run mode.")
+	} else if location != "" {
+		if c.opener == nil {
+			c.logger.Error("Cannot archive prowjobs without a storage client, not deleting them.")
+			m.prowJobsCleaningErrors[reasonProwJobArchiveFailed] += len(prowJobs)
+			return
+		}
+		if err := pjarchive.New(c.opener, location).Write(c.ctx, prowJobs, time.Now()); err != nil {
+			c.logger.WithError(err).Error("Error archiving prowjobs, not deleting them.")
+			m.prowJobsCleaningErrors[reasonProwJobArchiveFailed] += len(prowJobs)
+			return
+		}
+		c.logger.WithField("count", len(prowJobs)).Info("Archived prowjobs.")
+		m.prowJobsArchived += len(prowJobs)
+	}
+	for i := range prowJobs {
+		prowJob := &prowJobs[i]
+		if err := c.prowJobClient.Delete(c.ctx, prowJob); err == nil {
+			c.logger.WithFields(pjutil.ProwJobFields(prowJob)).Info("Deleted prowjob.")
+			m.prowJobsCleaned[reasons[i]]++
+		} else {
+			c.logger.WithFields(pjutil.ProwJobFields(prowJob)).WithError(err).Error("Error deleting prowjob.")
+			m.prowJobsCleaningErrors[string(k8serrors.ReasonForError(err))]++
+		}
+	}
+}
+
 func (c *controller) cleanupKubernetesFinalizer(pod *corev1api.Pod, client ctrlruntimeclient.Client) error {
 
 	oldPod := pod.DeepCopy()
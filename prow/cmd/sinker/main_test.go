@@ -21,6 +21,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"path"
 	"reflect"
 	"strconv"
 	"testing"
@@ -38,6 +39,7 @@ import (
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/kube"
 )
 
@@ -1028,3 +1030,109 @@ func (c *clientWrapper) Get(ctx context.Context, key ctrlruntimeclient.ObjectKey
 	}
 	return c.Client.Get(ctx, key, obj)
 }
+
+type fakeOpener struct {
+	io.Opener
+	fail    bool
+	written sets.String
+}
+
+func (fo *fakeOpener) Writer(_ context.Context, path string, _ ...io.WriterOptions) (io.WriteCloser, error) {
+	if fo.fail {
+		return nil, errors.New("injected error")
+	}
+	fo.written.Insert(path)
+	return nopWriteCloser{}, nil
+}
+
+type nopWriteCloser struct{}
+
+func (nopWriteCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopWriteCloser) Close() error                { return nil }
+
+func TestDeleteProwJobsArchives(t *testing.T) {
+	start := time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name            string
+		archiveLocation string
+		fail            bool
+		dryRun          bool
+		expectedDeleted sets.String
+		expectedWritten sets.String
+	}{
+		{
+			name:            "prowjobs are deleted without archiving them by default",
+			expectedDeleted: sets.NewString("old", "old-periodic"),
+			expectedWritten: sets.NewString(),
+		},
+		{
+			name:            "prowjobs are archived per day before deleting them",
+			archiveLocation: "gs://bucket/prowjobs",
+			expectedDeleted: sets.NewString("old", "old-periodic"),
+			expectedWritten: sets.NewString("2021-03-14", "2021-03-15"),
+		},
+		{
+			name:            "prowjobs are not deleted if archiving fails",
+			archiveLocation: "gs://bucket/prowjobs",
+			fail:            true,
+			expectedDeleted: sets.NewString(),
+			expectedWritten: sets.NewString(),
+		},
+		{
+			name:            "prowjobs are not archived in dry-run mode",
+			archiveLocation: "gs://bucket/prowjobs",
+			dryRun:          true,
+			expectedDeleted: sets.NewString("old", "old-periodic"),
+			expectedWritten: sets.NewString(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prowJobs := []prowv1.ProwJob{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "ns"},
+					Status:     prowv1.ProwJobStatus{StartTime: metav1.NewTime(start)},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "old-periodic", Namespace: "ns"},
+					Spec:       prowv1.ProwJobSpec{Type: prowv1.PeriodicJob},
+					Status:     prowv1.ProwJobStatus{StartTime: metav1.NewTime(start.Add(24 * time.Hour))},
+				},
+			}
+			fpjc := fakectrlruntimeclient.NewFakeClient(&prowJobs[0], &prowJobs[1])
+			sinkerConfig := newDefaultFakeSinkerConfig()
+			sinkerConfig.ArchiveLocation = tc.archiveLocation
+			opener := &fakeOpener{fail: tc.fail, written: sets.NewString()}
+			c := controller{
+				ctx:           context.Background(),
+				logger:        logrus.WithField("component", "sinker"),
+				prowJobClient: fpjc,
+				config:        newFakeConfigAgent(sinkerConfig).Config,
+				dryRun:        tc.dryRun,
+				opener:        opener,
+			}
+			m := &sinkerReconciliationMetrics{
+				prowJobsCleaned:        map[string]int{},
+				prowJobsCleaningErrors: map[string]int{},
+			}
+			c.deleteProwJobs(prowJobs, []string{reasonProwJobAged, reasonProwJobAgedPeriodic}, m)
+
+			remaining := &prowv1.ProwJobList{}
+			if err := fpjc.List(context.Background(), remaining); err != nil {
+				t.Fatalf("failed to list prowjobs: %v", err)
+			}
+			deleted := sets.NewString("old", "old-periodic")
+			for _, pj := range remaining.Items {
+				deleted.Delete(pj.Name)
+			}
+			assertSetsEqual(tc.expectedDeleted, deleted, t, "did not delete correct ProwJobs")
+
+			days := sets.NewString()
+			for written := range opener.written {
+				days.Insert(path.Base(path.Dir(written)))
+			}
+			assertSetsEqual(tc.expectedWritten, days, t, "did not archive correct days")
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/secret"
+	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/interrupts"
+	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/metrics"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/slack"
+	"k8s.io/test-infra/prow/slack/bot"
+)
+
+type options struct {
+	port              int
+	configPath        string
+	jobConfigPath     string
+	signingSecretFile string
+	tideURL           string
+
+	dryRun                 bool
+	gracePeriod            time.Duration
+	kubernetes             flagutil.KubernetesOptions
+	github                 flagutil.GitHubOptions
+	instrumentationOptions flagutil.InstrumentationOptions
+}
+
+func (o *options) Validate() error {
+	if err := o.kubernetes.Validate(o.dryRun); err != nil {
+		return err
+	}
+	if err := o.github.Validate(o.dryRun); err != nil {
+		return err
+	}
+	if o.configPath == "" {
+		return errors.New("--config-path is required")
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.IntVar(&o.port, "port", 8888, "Port to listen on.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
+	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
+	fs.StringVar(&o.signingSecretFile, "signing-secret-file", "/etc/slack/signing-secret", "Path to the file containing the signing secret of the Slack app.")
+	fs.StringVar(&o.tideURL, "tide-url", "", "URL at which Tide serves its pools. If empty, the tide command is disabled.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining requests for the specified duration.")
+	o.kubernetes.AddFlags(fs)
+	o.github.AddFlags(fs)
+	o.instrumentationOptions.AddFlags(fs)
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	pjutil.ServePProf(o.instrumentationOptions.PProfPort)
+
+	configAgent := &config.Agent{}
+	if err := configAgent.Start(o.configPath, o.jobConfigPath); err != nil {
+		logrus.WithError(err).Fatal("Error starting config agent.")
+	}
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.github.TokenPath, o.signingSecretFile}); err != nil {
+		logrus.WithError(err).Fatal("Error starting secrets agent.")
+	}
+
+	githubClient, err := o.github.GitHubClient(secretAgent, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting GitHub client.")
+	}
+
+	prowJobClient, err := o.kubernetes.ProwJobClient(configAgent.Config().ProwJobNamespace, o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error getting ProwJob client.")
+	}
+
+	metrics.ExposeMetrics("slack-bot", configAgent.Config().PushGateway, o.instrumentationOptions.MetricsPort)
+
+	server := &bot.Server{
+		ConfigAgent:   configAgent.Config,
+		ProwJobClient: prowJobClient,
+		GitHubClient:  githubClient,
+		// Responses are posted to the response URLs Slack sends along
+		// with each request, which need no API token.
+		SlackClient:    slack.NewClient(nil),
+		TokenGenerator: secretAgent.GetTokenGenerator(o.signingSecretFile),
+		TideURL:        o.tideURL,
+	}
+
+	health := pjutil.NewHealth()
+	mux := http.NewServeMux()
+	mux.Handle("/slack", server)
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	health.ServeReady()
+	interrupts.ListenAndServe(httpServer, o.gracePeriod)
+}
@@ -33,6 +33,7 @@ import (
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/crier/reporters/pubsub"
 	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/interrupts"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
@@ -45,6 +46,7 @@ var (
 
 type options struct {
 	client         flagutil.KubernetesOptions
+	github         flagutil.GitHubOptions
 	port           int
 	pushSecretFile string
 
@@ -83,6 +85,8 @@ func init() {
 	fs.DurationVar(&flagOptions.gracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining events for the specified duration. ")
 
 	flagOptions.client.AddFlags(fs)
+	flagOptions.github.AddFlags(fs)
+	flagOptions.github.AllowAnonymous = true
 	flagOptions.instrumentationOptions.AddFlags(fs)
 
 	fs.Parse(os.Args[1:])
@@ -90,6 +94,9 @@ func init() {
 
 func main() {
 	logrusutil.ComponentInit()
+	if err := flagOptions.github.Validate(flagOptions.dryRun); err != nil {
+		logrus.WithError(err).Fatal("Invalid GitHub options.")
+	}
 
 	configAgent := &config.Agent{}
 	if err := configAgent.Start(flagOptions.configPath, flagOptions.jobConfigPath); err != nil {
@@ -108,6 +115,22 @@ func main() {
 		tokenGenerator = secretAgent.GetTokenGenerator(flagOptions.pushSecretFile)
 	}
 
+	// The git client is used to resolve presubmits and postsubmits from in-repo config.
+	var gitClient git.ClientFactory
+	if flagOptions.github.TokenPath != "" {
+		secretAgent := &secret.Agent{}
+		if err := secretAgent.Start([]string{flagOptions.github.TokenPath}); err != nil {
+			logrus.WithError(err).Fatal("Error starting secrets agent.")
+		}
+		g, err := flagOptions.github.GitClient(secretAgent, flagOptions.dryRun)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error getting Git client.")
+		}
+		gitClient = git.ClientFactoryFrom(g)
+	} else if len(configAgent.Config().InRepoConfig.Enabled) > 0 {
+		logrus.Warn("--github-token-path is not configured, presubmits and postsubmits of repos with in-repo config enabled can not be triggered")
+	}
+
 	prowjobClient, err := flagOptions.client.ProwJobClient(configAgent.Config().ProwJobNamespace, flagOptions.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("unable to create prow job client")
@@ -129,6 +152,7 @@ func main() {
 		Metrics:       promMetrics,
 		ProwJobClient: kubeClient,
 		Reporter:      pubsub.NewReporter(configAgent.Config),
+		GitClient:     gitClient,
 	}
 
 	// Return 200 on / for health checks.
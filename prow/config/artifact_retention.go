@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// ArtifactRetention is config for the artifact-retention controller, which
+// deletes the artifacts of old runs from the buckets jobs upload to.
+type ArtifactRetention struct {
+	// ResyncPeriod is how often the controller cleans up the buckets.
+	// Defaults to one day.
+	ResyncPeriod *metav1.Duration `json:"resync_period,omitempty"`
+	// Policies decide how long the artifacts of jobs are kept. The first
+	// policy that matches a job applies to it. The artifacts of jobs that
+	// match no policy are kept forever.
+	Policies []RetentionPolicy `json:"policies,omitempty"`
+	// PinAuthConfig specifies who can pin runs in Deck. The artifacts of
+	// pinned runs are kept until they are unpinned, e.g. while a failure
+	// is investigated. Nobody can pin runs if it is unset.
+	PinAuthConfig *prowapi.RerunAuthConfig `json:"pin_auth_config,omitempty"`
+}
+
+// RetentionPolicy specifies how long the artifacts of a set of jobs are kept.
+// A policy without any of job_types, repos and jobs matches all jobs.
+type RetentionPolicy struct {
+	// JobTypes restricts the policy to jobs of the given types.
+	JobTypes []prowapi.ProwJobType `json:"job_types,omitempty"`
+	// Repos restricts the policy to jobs of the given orgs or repos, in
+	// "org" or "org/repo" form. Periodics belong to the repo of their
+	// first extra ref.
+	Repos []string `json:"repos,omitempty"`
+	// Jobs restricts the policy to jobs whose name matches one of these
+	// regular expressions.
+	Jobs []string `json:"jobs,omitempty"`
+	// MaxAge is how long the artifacts of a run are kept after it started.
+	MaxAge metav1.Duration `json:"max_age"`
+	// KeepFiles are regular expressions matching the paths, relative to
+	// the run directory, of the artifacts that are kept forever, e.g.
+	// ^finished\.json$. Job history only lists runs that kept their
+	// started.json and finished.json.
+	KeepFiles []string `json:"keep_files,omitempty"`
+
+	jobsRe []*regexp.Regexp
+	keepRe []*regexp.Regexp
+}
+
+// PolicyFor returns the policy that applies to the job, or nil if its
+// artifacts are kept forever. repo is empty for jobs without a repo.
+func (ar *ArtifactRetention) PolicyFor(jobType prowapi.ProwJobType, repo, job string) *RetentionPolicy {
+	for i := range ar.Policies {
+		if ar.Policies[i].matches(jobType, repo, job) {
+			return &ar.Policies[i]
+		}
+	}
+	return nil
+}
+
+func (rp *RetentionPolicy) matches(jobType prowapi.ProwJobType, repo, job string) bool {
+	if len(rp.JobTypes) > 0 {
+		var matched bool
+		for _, t := range rp.JobTypes {
+			matched = matched || t == jobType
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rp.Repos) > 0 {
+		var matched bool
+		for _, r := range rp.Repos {
+			matched = matched || repo != "" && (r == repo || strings.HasPrefix(repo, r+"/"))
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rp.jobsRe) > 0 {
+		var matched bool
+		for _, re := range rp.jobsRe {
+			matched = matched || re.MatchString(job)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// Keeps determines whether the artifact at the given path relative to the
+// run directory is kept forever.
+func (rp *RetentionPolicy) Keeps(name string) bool {
+	for _, re := range rp.keepRe {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultAndValidate defaults the resync period and compiles the regular
+// expressions of the policies.
+func (ar *ArtifactRetention) DefaultAndValidate() error {
+	if ar.ResyncPeriod == nil {
+		ar.ResyncPeriod = &metav1.Duration{Duration: 24 * time.Hour}
+	}
+	if err := ar.PinAuthConfig.Validate(); err != nil {
+		return fmt.Errorf("pin_auth_config: %w", err)
+	}
+	for i := range ar.Policies {
+		policy := &ar.Policies[i]
+		if policy.MaxAge.Duration <= 0 {
+			return fmt.Errorf("policies[%d]: max_age must be positive", i)
+		}
+		for _, t := range policy.JobTypes {
+			switch t {
+			case prowapi.PresubmitJob, prowapi.PostsubmitJob, prowapi.PeriodicJob, prowapi.BatchJob:
+			default:
+				return fmt.Errorf("policies[%d]: invalid job type %q", i, t)
+			}
+		}
+		policy.jobsRe = nil
+		for _, job := range policy.Jobs {
+			re, err := regexp.Compile(job)
+			if err != nil {
+				return fmt.Errorf("policies[%d]: invalid jobs regexp %q: %w", i, job, err)
+			}
+			policy.jobsRe = append(policy.jobsRe, re)
+		}
+		policy.keepRe = nil
+		for _, keep := range policy.KeepFiles {
+			re, err := regexp.Compile(keep)
+			if err != nil {
+				return fmt.Errorf("policies[%d]: invalid keep_files regexp %q: %w", i, keep, err)
+			}
+			policy.keepRe = append(policy.keepRe, re)
+		}
+	}
+	return nil
+}
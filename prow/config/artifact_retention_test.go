@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestArtifactRetentionPolicyFor(t *testing.T) {
+	ar := ArtifactRetention{
+		Policies: []RetentionPolicy{
+			{
+				Jobs:   []string{"^ci-kubernetes-e2e-.*-scale$"},
+				MaxAge: metav1.Duration{Duration: 90 * 24 * time.Hour},
+			},
+			{
+				JobTypes: []prowapi.ProwJobType{prowapi.PresubmitJob, prowapi.BatchJob},
+				Repos:    []string{"kubernetes"},
+				MaxAge:   metav1.Duration{Duration: 30 * 24 * time.Hour},
+			},
+			{
+				Repos:  []string{"org/repo"},
+				MaxAge: metav1.Duration{Duration: 7 * 24 * time.Hour},
+			},
+		},
+	}
+	if err := ar.DefaultAndValidate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ar.ResyncPeriod.Duration != 24*time.Hour {
+		t.Errorf("expected resync period to default to a day, got %v", ar.ResyncPeriod.Duration)
+	}
+
+	testCases := []struct {
+		name     string
+		jobType  prowapi.ProwJobType
+		repo     string
+		job      string
+		expected int
+	}{
+		{
+			name:     "job name matches",
+			jobType:  prowapi.PeriodicJob,
+			job:      "ci-kubernetes-e2e-gce-scale",
+			expected: 0,
+		},
+		{
+			name:     "presubmit of org",
+			jobType:  prowapi.PresubmitJob,
+			repo:     "kubernetes/kubernetes",
+			job:      "pull-kubernetes-unit",
+			expected: 1,
+		},
+		{
+			name:     "postsubmit of org does not match job types",
+			jobType:  prowapi.PostsubmitJob,
+			repo:     "kubernetes/kubernetes",
+			job:      "post-kubernetes-push",
+			expected: -1,
+		},
+		{
+			name:     "repo matches",
+			jobType:  prowapi.PostsubmitJob,
+			repo:     "org/repo",
+			job:      "post-repo",
+			expected: 2,
+		},
+		{
+			name:     "repo prefix is no org",
+			jobType:  prowapi.PostsubmitJob,
+			repo:     "org/repository",
+			job:      "post-repository",
+			expected: -1,
+		},
+		{
+			name:     "periodic without repo",
+			jobType:  prowapi.PeriodicJob,
+			job:      "ci-cleanup",
+			expected: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := ar.PolicyFor(tc.jobType, tc.repo, tc.job)
+			if tc.expected < 0 {
+				if policy != nil {
+					t.Errorf("expected no policy, got %+v", *policy)
+				}
+				return
+			}
+			if policy != &ar.Policies[tc.expected] {
+				t.Errorf("expected policy %d, got %+v", tc.expected, policy)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyKeeps(t *testing.T) {
+	ar := ArtifactRetention{
+		Policies: []RetentionPolicy{{
+			MaxAge:    metav1.Duration{Duration: time.Hour},
+			KeepFiles: []string{`^finished\.json$`, `^artifacts/junit.*\.xml$`},
+		}},
+	}
+	if err := ar.DefaultAndValidate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	policy := ar.Policies[0]
+	for name, expected := range map[string]bool{
+		"finished.json":                  true,
+		"artifacts/junit_01.xml":         true,
+		"build-log.txt":                  false,
+		"artifacts/nested/finished.json": false,
+	} {
+		if actual := policy.Keeps(name); actual != expected {
+			t.Errorf("expected Keeps(%q) to be %t, got %t", name, expected, actual)
+		}
+	}
+}
+
+func TestArtifactRetentionValidation(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy RetentionPolicy
+	}{
+		{
+			name:   "no max age",
+			policy: RetentionPolicy{},
+		},
+		{
+			name:   "invalid job type",
+			policy: RetentionPolicy{MaxAge: metav1.Duration{Duration: time.Hour}, JobTypes: []prowapi.ProwJobType{"nightly"}},
+		},
+		{
+			name:   "invalid jobs regexp",
+			policy: RetentionPolicy{MaxAge: metav1.Duration{Duration: time.Hour}, Jobs: []string{"("}},
+		},
+		{
+			name:   "invalid keep_files regexp",
+			policy: RetentionPolicy{MaxAge: metav1.Duration{Duration: time.Hour}, KeepFiles: []string{"["}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ar := ArtifactRetention{Policies: []RetentionPolicy{tc.policy}}
+			if err := ar.DefaultAndValidate(); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
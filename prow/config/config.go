@@ -111,17 +111,19 @@ type JobConfig struct {
 // ProwConfig is config for all prow controllers
 type ProwConfig struct {
 	// The git sha from which this config was generated
-	ConfigVersionSHA string           `json:"config_version_sha,omitempty"`
-	Tide             Tide             `json:"tide,omitempty"`
-	Plank            Plank            `json:"plank,omitempty"`
-	Sinker           Sinker           `json:"sinker,omitempty"`
-	Deck             Deck             `json:"deck,omitempty"`
-	BranchProtection BranchProtection `json:"branch-protection"`
-	Gerrit           Gerrit           `json:"gerrit"`
-	GitHubReporter   GitHubReporter   `json:"github_reporter"`
+	ConfigVersionSHA  string            `json:"config_version_sha,omitempty"`
+	Tide              Tide              `json:"tide,omitempty"`
+	Plank             Plank             `json:"plank,omitempty"`
+	Sinker            Sinker            `json:"sinker,omitempty"`
+	ArtifactRetention ArtifactRetention `json:"artifact_retention,omitempty"`
+	Deck              Deck              `json:"deck,omitempty"`
+	BranchProtection  BranchProtection  `json:"branch-protection"`
+	Gerrit            Gerrit            `json:"gerrit"`
+	GitHubReporter    GitHubReporter    `json:"github_reporter"`
 	// Deprecated: this option will be removed in May 2020.
 	SlackReporter        *SlackReporter       `json:"slack_reporter,omitempty"`
 	SlackReporterConfigs SlackReporterConfigs `json:"slack_reporter_configs,omitempty"`
+	SlackBot             SlackBot             `json:"slack_bot,omitempty"`
 	InRepoConfig         InRepoConfig         `json:"in_repo_config"`
 
 	// TODO: Move this out of the main config.
@@ -485,6 +487,17 @@ type Plank struct {
 	// PodUnscheduledTimeout is after how long the controller will abort a prowjob
 	// stuck in an unscheduled state. Defaults to one day.
 	PodUnscheduledTimeout *metav1.Duration `json:"pod_unscheduled_timeout,omitempty"`
+	// PodRescheduleTimeout is after how long the controller will move a prowjob
+	// with candidate clusters whose pod cannot be scheduled to another cluster.
+	// Defaults to half of PodUnscheduledTimeout.
+	PodRescheduleTimeout *metav1.Duration `json:"pod_reschedule_timeout,omitempty"`
+	// ClusterGroups maps a group name to the build clusters that jobs naming
+	// the group in their cluster_group field may run in.
+	ClusterGroups map[string][]string `json:"cluster_groups,omitempty"`
+	// ClusterWeights biases the choice between candidate clusters. A cluster's
+	// pending pods are divided by its weight, so a cluster with twice the
+	// weight is picked until it has twice as many pending pods. Defaults to 1.
+	ClusterWeights map[string]int `json:"cluster_weights,omitempty"`
 	// DefaultDecorationConfigs holds the default decoration config for specific values.
 	// This config will be used on each Presubmit and Postsubmit's corresponding org/repo, and on Periodics
 	// if extraRefs[0] exists.
@@ -500,6 +513,27 @@ type Plank struct {
 	JobURLPrefixDisableAppendStorageProvider bool `json:"jobURLPrefixDisableAppendStorageProvider,omitempty"`
 }
 
+// CandidateClusters returns the build clusters the job may run in, or nil
+// if the job is pinned to a single cluster.
+func (p Plank) CandidateClusters(pj *prowapi.ProwJob) []string {
+	return p.candidateClusters(pj.Spec.Clusters, pj.Spec.ClusterGroup)
+}
+
+func (p Plank) candidateClusters(clusters []string, group string) []string {
+	if group != "" {
+		return p.ClusterGroups[group]
+	}
+	return clusters
+}
+
+// ClusterWeight returns the configured weight of the build cluster.
+func (p Plank) ClusterWeight(cluster string) int {
+	if weight, ok := p.ClusterWeights[cluster]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
 func (p Plank) GetDefaultDecorationConfigs(repo string) *prowapi.DecorationConfig {
 	def := p.DefaultDecorationConfigs["*"]
 	if dcByRepo, ok := p.DefaultDecorationConfigs[repo]; ok {
@@ -602,6 +636,10 @@ type Sinker struct {
 	TerminatedPodTTL *metav1.Duration `json:"terminated_pod_ttl,omitempty"`
 	// ExcludeClusters are build clusters that don't want to be managed by sinker
 	ExcludeClusters []string `json:"exclude_clusters,omitempty"`
+	// ArchiveLocation is where ProwJobs are archived before they are
+	// garbage-collected, e.g. gs://my-bucket/prowjobs. Deck searches the
+	// archive for old ProwJobs. ProwJobs are not archived if it is unset.
+	ArchiveLocation string `json:"archive_location,omitempty"`
 }
 
 // LensConfig names a specific lens, and optionally provides some configuration for it.
@@ -915,6 +953,10 @@ type SlackReporter struct {
 	JobStatesToReport []prowapi.ProwJobState `json:"job_states_to_report,omitempty"`
 	Channel           string                 `json:"channel"`
 	ReportTemplate    string                 `json:"report_template"`
+	// RerunButton adds a "Rerun" button next to the "View logs" button of
+	// the messages. Clicks on it are handled by the slack-bot, so it must
+	// be deployed.
+	RerunButton bool `json:"rerun_button,omitempty"`
 }
 
 // SlackReporterConfigs represents the config for the Slack reporter(s).
@@ -937,6 +979,21 @@ func (cfg SlackReporterConfigs) GetSlackReporter(refs *prowapi.Refs) SlackReport
 	return cfg["*"]
 }
 
+// SlackBot is the config for the slack-bot, which serves Prow slash commands
+// and the interactive elements of Slack messages.
+type SlackBot struct {
+	// GitHubUsers maps Slack user IDs to GitHub logins. Commands that change
+	// anything are only accepted from mapped users and are authorized as
+	// the GitHub user they map to.
+	GitHubUsers map[string]string `json:"github_users,omitempty"`
+}
+
+// GitHubUser returns the GitHub login the Slack user maps to, if any.
+func (cfg SlackBot) GitHubUser(slackUserID string) (string, bool) {
+	login, ok := cfg.GitHubUsers[slackUserID]
+	return login, ok && login != ""
+}
+
 func (cfg *SlackReporter) DefaultAndValidate() error {
 	// Default ReportTemplate
 	if cfg.ReportTemplate == "" {
@@ -1324,6 +1381,10 @@ func defaultPeriodics(periodics []Periodic, c *Config) error {
 
 // finalizeJobConfig mutates and fixes entries for jobspecs
 func (c *Config) finalizeJobConfig() error {
+	if err := c.expandMatrixJobs(); err != nil {
+		return err
+	}
+
 	if c.decorationRequested() {
 
 		def, ok := c.Plank.DefaultDecorationConfigs["*"]
@@ -1453,6 +1514,9 @@ func validateJobBase(v JobBase, jobType prowapi.ProwJobType, podNamespace string
 	if err := v.UtilityConfig.Validate(); err != nil {
 		return err
 	}
+	if v.DecorationConfig != nil && len(v.DecorationConfig.Steps) > 0 && len(v.Spec.Containers) > 1 {
+		return errors.New("decorated jobs with steps must have a single container")
+	}
 	for i := range v.Spec.Containers {
 		if err := validateDecoration(v.Spec.Containers[i], v.DecorationConfig); err != nil {
 			return err
@@ -1486,6 +1550,9 @@ func validatePresubmits(presubmits []Presubmit, podNamespace string) error {
 		if err := validateTriggering(ps); err != nil {
 			errs = append(errs, err)
 		}
+		if err := validateSparseCheckout(ps.JobBase, ps.RegexpChangeMatcher); err != nil {
+			errs = append(errs, fmt.Errorf("invalid presubmit job %s: %v", ps.Name, err))
+		}
 		if err := validateReporting(ps.JobBase, ps.Reporter); err != nil {
 			errs = append(errs, fmt.Errorf("invalid presubmit job %s: %v", ps.Name, err))
 		}
@@ -1545,6 +1612,9 @@ func validatePostsubmits(postsubmits []Postsubmit, podNamespace string) error {
 		if err := validateReporting(ps.JobBase, ps.Reporter); err != nil {
 			errs = append(errs, fmt.Errorf("invalid postsubmit job %s: %v", ps.Name, err))
 		}
+		if err := validateSparseCheckout(ps.JobBase, ps.RegexpChangeMatcher); err != nil {
+			errs = append(errs, fmt.Errorf("invalid postsubmit job %s: %v", ps.Name, err))
+		}
 		validPostsubmits[ps.Name] = append(validPostsubmits[ps.Name], ps)
 	}
 
@@ -1565,11 +1635,131 @@ func validatePeriodics(periodics []Periodic, podNamespace string) error {
 		if err := validateJobBase(p.JobBase, prowapi.PeriodicJob, podNamespace); err != nil {
 			return fmt.Errorf("invalid periodic job %s: %v", p.Name, err)
 		}
+		if err := validateSparseCheckout(p.JobBase, RegexpChangeMatcher{}); err != nil {
+			return fmt.Errorf("invalid periodic job %s: %v", p.Name, err)
+		}
 	}
 
 	return nil
 }
 
+// validatePeriodicTriggers validates the triggers of periodics and ensures
+// that chained periodics do not trigger each other in a loop.
+func validatePeriodicTriggers(periodics []Periodic) error {
+	var errs []error
+	downstream := map[string][]string{}
+	for _, p := range periodics {
+		for i, trigger := range p.TriggeredBy {
+			if err := trigger.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid trigger %d of periodic %s: %v", i, p.Name, err))
+				continue
+			}
+			if trigger.JobSucceeded != "" {
+				downstream[trigger.JobSucceeded] = append(downstream[trigger.JobSucceeded], p.Name)
+			}
+		}
+	}
+
+	// Search the chains for loops depth first. Jobs being visited are on the
+	// current path, so reaching one of them again closes a loop.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(job string)
+	visit = func(job string) {
+		switch state[job] {
+		case visited:
+			return
+		case visiting:
+			start := 0
+			for i, name := range path {
+				if name == job {
+					start = i
+				}
+			}
+			errs = append(errs, fmt.Errorf("periodics trigger each other in a loop: %s -> %s", strings.Join(path[start:], " -> "), job))
+			return
+		}
+		state[job] = visiting
+		path = append(path, job)
+		for _, next := range downstream[job] {
+			visit(next)
+		}
+		path = path[:len(path)-1]
+		state[job] = visited
+	}
+	for _, p := range periodics {
+		if state[p.Name] == unvisited {
+			visit(p.Name)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (t PeriodicTrigger) validate() error {
+	set := 0
+	if t.JobSucceeded != "" {
+		set++
+	}
+	if t.Postsubmit != nil {
+		set++
+		if parts := strings.Split(t.Postsubmit.Repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("postsubmit repo %q is not of the form org/repo", t.Postsubmit.Repo)
+		}
+		for _, state := range t.Postsubmit.States {
+			switch state {
+			case prowapi.SuccessState, prowapi.FailureState, prowapi.AbortedState, prowapi.ErrorState:
+			default:
+				return fmt.Errorf("postsubmit state %q is no final state", state)
+			}
+		}
+	}
+	if t.GCSObject != "" {
+		set++
+		if parsed, err := url.Parse(t.GCSObject); err != nil || parsed.Scheme != "gs" || parsed.Host == "" || strings.Trim(parsed.Path, "/") == "" {
+			return fmt.Errorf("gcs_object %q is not of the form gs://bucket/path", t.GCSObject)
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of job_succeeded, postsubmit and gcs_object must be set")
+	}
+	return nil
+}
+
+// validateCandidateClusters ensures that jobs list candidate clusters either
+// directly or through a configured cluster group.
+func (c *Config) validateCandidateClusters() error {
+	var bases []JobBase
+	for _, ps := range c.AllStaticPresubmits(nil) {
+		bases = append(bases, ps.JobBase)
+	}
+	for _, ps := range c.AllStaticPostsubmits(nil) {
+		bases = append(bases, ps.JobBase)
+	}
+	for _, p := range c.AllPeriodics() {
+		bases = append(bases, p.JobBase)
+	}
+
+	var errs []error
+	for _, base := range bases {
+		if len(base.Clusters) > 0 && base.ClusterGroup != "" {
+			errs = append(errs, fmt.Errorf("job %s: clusters and cluster_group cannot both be set", base.Name))
+			continue
+		}
+		if base.ClusterGroup != "" {
+			if _, ok := c.Plank.ClusterGroups[base.ClusterGroup]; !ok {
+				errs = append(errs, fmt.Errorf("job %s: cluster_group %q is not configured in plank.cluster_groups", base.Name, base.ClusterGroup))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 // ValidateJobConfig validates if all the jobspecs/presets are valid
 // if you are mutating the jobs, please add it to finalizeJobConfig above
 func (c *Config) ValidateJobConfig() error {
@@ -1594,13 +1784,23 @@ func (c *Config) ValidateJobConfig() error {
 		errs = append(errs, err)
 	}
 
+	if err := validatePeriodicTriggers(c.Periodics); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.validateCandidateClusters(); err != nil {
+		errs = append(errs, err)
+	}
+
 	// Set the interval on the periodic jobs. It doesn't make sense to do this
 	// for child jobs.
 	for j, p := range c.Periodics {
 		if p.Cron != "" && p.Interval != "" {
 			errs = append(errs, fmt.Errorf("cron and interval cannot be both set in periodic %s", p.Name))
 		} else if p.Cron == "" && p.Interval == "" {
-			errs = append(errs, fmt.Errorf("cron and interval cannot be both empty in periodic %s", p.Name))
+			if len(p.TriggeredBy) == 0 {
+				errs = append(errs, fmt.Errorf("cron and interval cannot be both empty in periodic %s", p.Name))
+			}
 		} else if p.Cron != "" {
 			if _, err := cron.Parse(p.Cron); err != nil {
 				errs = append(errs, fmt.Errorf("invalid cron string %s in periodic %s: %v", p.Cron, p.Name, err))
@@ -1636,6 +1836,23 @@ func parseProwConfig(c *Config) error {
 		c.Plank.PodUnscheduledTimeout = &metav1.Duration{Duration: 5 * time.Minute}
 	}
 
+	if c.Plank.PodRescheduleTimeout == nil {
+		c.Plank.PodRescheduleTimeout = &metav1.Duration{Duration: c.Plank.PodUnscheduledTimeout.Duration / 2}
+	} else if c.Plank.PodRescheduleTimeout.Duration >= c.Plank.PodUnscheduledTimeout.Duration {
+		return fmt.Errorf("plank.pod_reschedule_timeout (%v) must be shorter than plank.pod_unscheduled_timeout (%v)", c.Plank.PodRescheduleTimeout.Duration, c.Plank.PodUnscheduledTimeout.Duration)
+	}
+
+	for group, clusters := range c.Plank.ClusterGroups {
+		if len(clusters) == 0 {
+			return fmt.Errorf("plank.cluster_groups: group %q has no clusters", group)
+		}
+	}
+	for cluster, weight := range c.Plank.ClusterWeights {
+		if weight < 1 {
+			return fmt.Errorf("plank.cluster_weights: weight %d of cluster %q must be positive", weight, cluster)
+		}
+	}
+
 	if c.Gerrit.TickInterval == nil {
 		c.Gerrit.TickInterval = &metav1.Duration{Duration: time.Minute}
 	}
@@ -1781,6 +1998,16 @@ func parseProwConfig(c *Config) error {
 		c.Sinker.TerminatedPodTTL = &metav1.Duration{Duration: c.Sinker.MaxPodAge.Duration}
 	}
 
+	if c.Sinker.ArchiveLocation != "" {
+		if u, err := url.Parse(c.Sinker.ArchiveLocation); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("sinker.archive_location %q must be a storage path like gs://bucket/path", c.Sinker.ArchiveLocation)
+		}
+	}
+
+	if err := c.ArtifactRetention.DefaultAndValidate(); err != nil {
+		return fmt.Errorf("artifact_retention: %w", err)
+	}
+
 	if c.Tide.SyncPeriod == nil {
 		c.Tide.SyncPeriod = &metav1.Duration{Duration: time.Minute}
 	}
@@ -1988,6 +2215,12 @@ func validateDecoration(container v1.Container, config *prowapi.DecorationConfig
 	}
 	var args []string
 	args = append(append(args, container.Command...), container.Args...)
+	if len(config.Steps) > 0 {
+		if len(args) > 0 {
+			return errors.New("decorated job containers must not specify command or args when the job has steps")
+		}
+		return nil
+	}
 	if len(args) == 0 || args[0] == "" {
 		return errors.New("decorated job containers must specify command and/or args")
 	}
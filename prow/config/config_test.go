@@ -1224,6 +1224,30 @@ func TestValidateDecoration(t *testing.T) {
 			name:   "reject container that has no cmd, no args",
 			config: &defCfg,
 		},
+		{
+			name:   "happy case with steps",
+			config: withSteps(defCfg, prowapi.Step{Name: "build", Command: []string{"make"}}, prowapi.Step{Name: "test", Command: []string{"make", "test"}}),
+			pass:   true,
+		},
+		{
+			name:   "reject steps with a container cmd",
+			config: withSteps(defCfg, prowapi.Step{Name: "build", Command: []string{"make"}}),
+			container: v1.Container{
+				Command: []string{"hello", "world"},
+			},
+		},
+		{
+			name:   "reject unnamed step",
+			config: withSteps(defCfg, prowapi.Step{Command: []string{"make"}}),
+		},
+		{
+			name:   "reject duplicate steps",
+			config: withSteps(defCfg, prowapi.Step{Name: "build", Command: []string{"make"}}, prowapi.Step{Name: "build", Command: []string{"make", "all"}}),
+		},
+		{
+			name:   "reject step without command",
+			config: withSteps(defCfg, prowapi.Step{Name: "build"}),
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -1237,6 +1261,11 @@ func TestValidateDecoration(t *testing.T) {
 	}
 }
 
+func withSteps(config prowapi.DecorationConfig, steps ...prowapi.Step) *prowapi.DecorationConfig {
+	config.Steps = steps
+	return &config
+}
+
 func TestValidateLabels(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -2242,6 +2271,59 @@ periodics:
 				},
 			},
 		},
+		{
+			name: "matrix presubmit expands into templated jobs with presets",
+			prowConfig: `
+presets:
+- labels:
+    preset-cloud: gce
+  env:
+  - name: CLOUD_CREDENTIALS
+    value: /etc/gce`,
+			jobConfigs: []string{
+				`
+presubmits:
+  org/repo:
+  - name: pull-e2e
+    matrix:
+      cloud: [aws, gce]
+      k8s: ["1.20"]
+    labels:
+      preset-cloud: "{{.cloud}}"
+    spec:
+      containers:
+      - image: alpine
+        args: ["--cloud={{.cloud}}"]
+        env:
+        - name: KUBERNETES_VERSION
+          value: "v{{.k8s}}"`,
+			},
+			expectEnv: map[string][]v1.EnvVar{
+				"pull-e2e-aws-1.20": {
+					{Name: "KUBERNETES_VERSION", Value: "v1.20"},
+				},
+				"pull-e2e-gce-1.20": {
+					{Name: "KUBERNETES_VERSION", Value: "v1.20"},
+					{Name: "CLOUD_CREDENTIALS", Value: "/etc/gce"},
+				},
+			},
+			verify: func(c *Config) error {
+				var names []string
+				for _, ps := range c.PresubmitsStatic["org/repo"] {
+					names = append(names, ps.Name)
+					if !ps.TriggerMatches("/test " + ps.Name) {
+						return fmt.Errorf("job %s does not match its default trigger", ps.Name)
+					}
+					if expected := "--cloud=" + ps.MatrixValues()["cloud"]; ps.Spec.Containers[0].Args[0] != expected {
+						return fmt.Errorf("job %s: expected args %s, got %s", ps.Name, expected, ps.Spec.Containers[0].Args[0])
+					}
+				}
+				if diff := cmp.Diff([]string{"pull-e2e-aws-1.20", "pull-e2e-gce-1.20"}, names); diff != "" {
+					return fmt.Errorf("unexpected jobs: %s", diff)
+				}
+				return nil
+			},
+		},
 		{
 			name:       "decorated periodic missing `command`",
 			prowConfig: ``,
@@ -5160,6 +5242,47 @@ deck:
 	}
 }
 
+func TestSinkerArchiveLocation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		location    string
+		expectError bool
+	}{
+		{
+			name:     "storage path",
+			location: "gs://bucket/prowjobs",
+		},
+		{
+			name:        "no scheme",
+			location:    "bucket/prowjobs",
+			expectError: true,
+		},
+		{
+			name:        "no bucket",
+			location:    "gs:///prowjobs",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := loadConfigYaml(fmt.Sprintf("sinker:\n  archive_location: %s\n", tc.location), t)
+			if tc.expectError {
+				if err == nil {
+					t.Error("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Sinker.ArchiveLocation != tc.location {
+				t.Errorf("expected archive location %q, got %q", tc.location, cfg.Sinker.ArchiveLocation)
+			}
+		})
+	}
+}
+
 func loadConfigYaml(prowConfigYaml string, t *testing.T) (*Config, error) {
 	prowConfigDir, err := ioutil.TempDir("", "prowConfig")
 	if err != nil {
@@ -5208,3 +5331,159 @@ func TestGenYamlDocs(t *testing.T) {
 		t.Errorf("Actual result differs from expected: %s. If this is expected, re-run the tests with the UPDATE env var set to update the fixture: UPDATE=true go test ./...", diff)
 	}
 }
+
+func TestValidatePeriodicTriggers(t *testing.T) {
+	periodic := func(name string, triggers ...PeriodicTrigger) Periodic {
+		return Periodic{JobBase: JobBase{Name: name}, TriggeredBy: triggers}
+	}
+	testCases := []struct {
+		name        string
+		periodics   []Periodic
+		expectedErr string
+	}{
+		{
+			name: "valid chain and triggers",
+			periodics: []Periodic{
+				periodic("build"),
+				periodic("test", PeriodicTrigger{JobSucceeded: "build"}),
+				periodic("publish", PeriodicTrigger{JobSucceeded: "test"}, PeriodicTrigger{GCSObject: "gs://bucket/release/latest.txt"}),
+				periodic("postsubmit-follow-up", PeriodicTrigger{Postsubmit: &PostsubmitTrigger{Repo: "org/repo", Branches: []string{"main"}, States: []prowapi.ProwJobState{prowapi.FailureState}}}),
+			},
+		},
+		{
+			name:        "trigger without event",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{})},
+			expectedErr: "invalid trigger 0 of periodic test: exactly one of job_succeeded, postsubmit and gcs_object must be set",
+		},
+		{
+			name:        "trigger with two events",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{JobSucceeded: "build", GCSObject: "gs://bucket/object"})},
+			expectedErr: "invalid trigger 0 of periodic test: exactly one of job_succeeded, postsubmit and gcs_object must be set",
+		},
+		{
+			name:        "invalid GCS object",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{GCSObject: "gs://bucket"})},
+			expectedErr: `invalid trigger 0 of periodic test: gcs_object "gs://bucket" is not of the form gs://bucket/path`,
+		},
+		{
+			name:        "invalid postsubmit repo",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{Postsubmit: &PostsubmitTrigger{Repo: "org"}})},
+			expectedErr: `invalid trigger 0 of periodic test: postsubmit repo "org" is not of the form org/repo`,
+		},
+		{
+			name:        "postsubmit state which is not final",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{Postsubmit: &PostsubmitTrigger{Repo: "org/repo", States: []prowapi.ProwJobState{prowapi.PendingState}}})},
+			expectedErr: `invalid trigger 0 of periodic test: postsubmit state "pending" is no final state`,
+		},
+		{
+			name:        "periodic triggering itself",
+			periodics:   []Periodic{periodic("test", PeriodicTrigger{JobSucceeded: "test"})},
+			expectedErr: "periodics trigger each other in a loop: test -> test",
+		},
+		{
+			name: "loop through a chain",
+			periodics: []Periodic{
+				periodic("build", PeriodicTrigger{JobSucceeded: "publish"}),
+				periodic("test", PeriodicTrigger{JobSucceeded: "build"}),
+				periodic("publish", PeriodicTrigger{JobSucceeded: "test"}),
+			},
+			expectedErr: "periodics trigger each other in a loop: build -> test -> publish -> build",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validatePeriodicTriggers(tc.periodics)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, actualErr)
+			}
+		})
+	}
+}
+
+func TestValidateCandidateClusters(t *testing.T) {
+	periodic := func(name string, clusters []string, group string) Periodic {
+		return Periodic{JobBase: JobBase{Name: name, Clusters: clusters, ClusterGroup: group}}
+	}
+	testCases := []struct {
+		name        string
+		periodics   []Periodic
+		expectedErr string
+	}{
+		{
+			name: "candidate clusters and configured group",
+			periodics: []Periodic{
+				periodic("pinned", nil, ""),
+				periodic("listed", []string{"a", "b"}, ""),
+				periodic("grouped", nil, "gpu"),
+			},
+		},
+		{
+			name:        "clusters and group",
+			periodics:   []Periodic{periodic("both", []string{"a"}, "gpu")},
+			expectedErr: "job both: clusters and cluster_group cannot both be set",
+		},
+		{
+			name:        "unknown group",
+			periodics:   []Periodic{periodic("unknown", nil, "tpu")},
+			expectedErr: `job unknown: cluster_group "tpu" is not configured in plank.cluster_groups`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{
+				JobConfig:  JobConfig{Periodics: tc.periodics},
+				ProwConfig: ProwConfig{Plank: Plank{ClusterGroups: map[string][]string{"gpu": {"a", "b"}}}},
+			}
+			var errMsg string
+			if err := c.validateCandidateClusters(); err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.expectedErr {
+				t.Errorf("expected error %q, got %q", tc.expectedErr, errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateSparseCheckout(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fromRIC      bool
+		runIfChanged string
+		expectedErr  bool
+	}{
+		{
+			name: "not derived from run_if_changed",
+		},
+		{
+			name:         "derived from a usable run_if_changed",
+			fromRIC:      true,
+			runIfChanged: "^prow/",
+		},
+		{
+			name:        "derived without run_if_changed",
+			fromRIC:     true,
+			expectedErr: true,
+		},
+		{
+			name:         "derived from an unanchored run_if_changed",
+			fromRIC:      true,
+			runIfChanged: `\.go$`,
+			expectedErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jb := JobBase{UtilityConfig: UtilityConfig{SparseCheckoutFromRunIfChanged: tc.fromRIC}}
+			err := validateSparseCheckout(jb, RegexpChangeMatcher{RunIfChanged: tc.runIfChanged})
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error %t, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -121,6 +121,17 @@ func defaultProwYAMLGetter(
 }
 
 func DefaultAndValidateProwYAML(c *Config, p *ProwYAML, identifier string) error {
+	presubmits, err := expandPresubmits(p.Presubmits)
+	if err != nil {
+		return err
+	}
+	p.Presubmits = presubmits
+	postsubmits, err := expandPostsubmits(p.Postsubmits)
+	if err != nil {
+		return err
+	}
+	p.Postsubmits = postsubmits
+
 	if err := defaultPresubmits(p.Presubmits, c, identifier); err != nil {
 		return err
 	}
@@ -135,14 +146,22 @@ func DefaultAndValidateProwYAML(c *Config, p *ProwYAML, identifier string) error
 	}
 
 	var errs []error
+	var bases []JobBase
 	for _, pre := range p.Presubmits {
-		if !c.InRepoConfigAllowsCluster(pre.Cluster, identifier) {
-			errs = append(errs, fmt.Errorf("cluster %q is not allowed for repository %q", pre.Cluster, identifier))
-		}
+		bases = append(bases, pre.JobBase)
 	}
 	for _, post := range p.Postsubmits {
-		if !c.InRepoConfigAllowsCluster(post.Cluster, identifier) {
-			errs = append(errs, fmt.Errorf("cluster %q is not allowed for repository %q", post.Cluster, identifier))
+		bases = append(bases, post.JobBase)
+	}
+	for _, base := range bases {
+		clusters := c.Plank.candidateClusters(base.Clusters, base.ClusterGroup)
+		if len(clusters) == 0 {
+			clusters = []string{base.Cluster}
+		}
+		for _, cluster := range clusters {
+			if !c.InRepoConfigAllowsCluster(cluster, identifier) {
+				errs = append(errs, fmt.Errorf("cluster %q is not allowed for repository %q", cluster, identifier))
+			}
 		}
 	}
 
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobdiff
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+)
+
+// LoadAtRevision loads the configs at a revision of the git repository in
+// repoDir. The revision is checked out in a temporary worktree, so the
+// checkout in repoDir is left alone.
+func LoadAtRevision(repoDir, revision, configPath, jobConfigPath string) (*config.Config, error) {
+	dir, err := ioutil.TempDir("", "jobdiff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	worktree := filepath.Join(dir, "worktree")
+	if out, err := exec.Command("git", "-C", repoDir, "worktree", "add", "--detach", worktree, revision).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to check out %s: %v: %s", revision, err, string(out))
+	}
+	defer func() {
+		if out, err := exec.Command("git", "-C", repoDir, "worktree", "remove", "--force", worktree).CombinedOutput(); err != nil {
+			logrus.WithError(err).Warnf("Failed to remove worktree: %s", string(out))
+		}
+	}()
+	cfg, err := Load(worktree, configPath, jobConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config at %s: %w", revision, err)
+	}
+	return cfg, nil
+}
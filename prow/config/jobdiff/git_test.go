@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobdiff
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAtRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "jobdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, string(out))
+		}
+	}
+	write := func(jobs string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "jobs.yaml"), []byte(jobs), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-m", "update")
+	}
+	git("init")
+	write("periodics:\n- name: periodic\n  interval: 1h\n  spec:\n    containers:\n    - image: old\n")
+	git("tag", "base")
+	write("periodics:\n- name: periodic\n  interval: 1h\n  spec:\n    containers:\n    - image: new\n")
+
+	for revision, image := range map[string]string{"base": "old", "HEAD": "new"} {
+		cfg, err := LoadAtRevision(dir, revision, "config.yaml", "jobs.yaml")
+		if err != nil {
+			t.Fatalf("failed to load config at %s: %v", revision, err)
+		}
+		if len(cfg.Periodics) != 1 || cfg.Periodics[0].Spec.Containers[0].Image != image {
+			t.Errorf("expected periodic with image %q at %s, got %+v", image, revision, cfg.Periodics)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jobdiff compares the effective job configs of two loaded Prow
+// configs, i.e. the jobs after defaults, presets and decoration are applied.
+package jobdiff
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+// ChangeKind describes how a job changed.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is the difference of a single job between two configs.
+type Change struct {
+	Kind ChangeKind
+	Type prowapi.ProwJobType
+	// Repo is the org/repo the job is configured for, empty for periodics.
+	Repo string
+	Name string
+	// Diff is a unified diff of the effective job config in YAML.
+	Diff string
+	// After holds the config.Presubmit, config.Postsubmit or config.Periodic
+	// jobs with the name after the change. It is empty for removed jobs.
+	After []interface{}
+	// NewlyRunsOnEveryPR is set for presubmits that run on every PR after
+	// the change but did not before.
+	NewlyRunsOnEveryPR bool
+}
+
+// Load loads the Prow config and the job config from a checkout. The paths
+// are relative to dir and jobConfigPath may be empty.
+func Load(dir, configPath, jobConfigPath string) (*config.Config, error) {
+	if jobConfigPath != "" {
+		jobConfigPath = filepath.Join(dir, jobConfigPath)
+	}
+	return config.Load(filepath.Join(dir, configPath), jobConfigPath)
+}
+
+type jobKey struct {
+	Type prowapi.ProwJobType
+	Repo string
+	Name string
+}
+
+// A job name is only unique per repo and branch, so all jobs sharing a key
+// are compared together.
+type jobs map[jobKey][]interface{}
+
+func (j jobs) add(key jobKey, job interface{}) {
+	j[key] = append(j[key], job)
+}
+
+func jobsFrom(c config.JobConfig) jobs {
+	all := jobs{}
+	for repo, presubmits := range c.PresubmitsStatic {
+		for _, p := range presubmits {
+			all.add(jobKey{Type: prowapi.PresubmitJob, Repo: repo, Name: p.Name}, p)
+		}
+	}
+	for repo, postsubmits := range c.PostsubmitsStatic {
+		for _, p := range postsubmits {
+			all.add(jobKey{Type: prowapi.PostsubmitJob, Repo: repo, Name: p.Name}, p)
+		}
+	}
+	for _, p := range c.Periodics {
+		all.add(jobKey{Type: prowapi.PeriodicJob, Name: p.Name}, p)
+	}
+	return all
+}
+
+func marshal(jobs []interface{}) (string, error) {
+	var raw []byte
+	var err error
+	if len(jobs) == 1 {
+		raw, err = yaml.Marshal(jobs[0])
+	} else {
+		raw, err = yaml.Marshal(jobs)
+	}
+	return string(raw), err
+}
+
+// runsOnEveryPR determines if any of the presubmits is triggered for every PR.
+func runsOnEveryPR(jobs []interface{}) bool {
+	for _, job := range jobs {
+		if p, ok := job.(config.Presubmit); ok && p.AlwaysRun {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff compares the static jobs of both configs and returns the changes,
+// sorted by type, repo and name.
+func Diff(before, after *config.Config) ([]Change, error) {
+	beforeJobs, afterJobs := jobsFrom(before.JobConfig), jobsFrom(after.JobConfig)
+	keys := map[jobKey]bool{}
+	for key := range beforeJobs {
+		keys[key] = true
+	}
+	for key := range afterJobs {
+		keys[key] = true
+	}
+
+	var changes []Change
+	for key := range keys {
+		beforeYAML, err := marshal(beforeJobs[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s: %w", key.Type, key.Name, err)
+		}
+		afterYAML, err := marshal(afterJobs[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s: %w", key.Type, key.Name, err)
+		}
+		change := Change{Type: key.Type, Repo: key.Repo, Name: key.Name, After: afterJobs[key]}
+		switch {
+		case len(beforeJobs[key]) == 0:
+			change.Kind = Added
+			beforeYAML = ""
+		case len(afterJobs[key]) == 0:
+			change.Kind = Removed
+			afterYAML = ""
+		case beforeYAML != afterYAML:
+			change.Kind = Changed
+		default:
+			continue
+		}
+		change.Diff = unifiedDiff(beforeYAML, afterYAML)
+		change.NewlyRunsOnEveryPR = runsOnEveryPR(afterJobs[key]) && !runsOnEveryPR(beforeJobs[key])
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Type != changes[j].Type {
+			return changes[i].Type < changes[j].Type
+		}
+		if changes[i].Repo != changes[j].Repo {
+			return changes[i].Repo < changes[j].Repo
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns a line diff of a and b in unified format, without the
+// file header. Job configs are small, so a plain LCS is good enough.
+func unifiedDiff(a, b string) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	n, m := len(aLines), len(bLines)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if strings.TrimSuffix(aLines[i], "\n") == strings.TrimSuffix(bLines[j], "\n") {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && strings.TrimSuffix(aLines[i], "\n") == strings.TrimSuffix(bLines[j], "\n"):
+			lines = append(lines, diffLine{op: ' ', text: aLines[i]})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{op: '-', text: aLines[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{op: '+', text: bLines[j]})
+			j++
+		}
+	}
+
+	out := &strings.Builder{}
+	for start := 0; start < len(lines); {
+		// Find the next change and the end of its hunk.
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for k := first; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				last = k
+			} else if k-last > 2*diffContext {
+				break
+			}
+		}
+		from, to := max(first-diffContext, start), min(last+diffContext+1, len(lines))
+		aStart, bStart := 1, 1
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				aStart++
+			}
+			if l.op != '-' {
+				bStart++
+			}
+		}
+		var aCount, bCount int
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				aCount++
+			}
+			if l.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, l := range lines[from:to] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteByte('\n')
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range starts at the line before the hunk.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobdiff
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+const prowConfig = `
+plank:
+  default_decoration_configs:
+    '*':
+      gcs_configuration:
+        bucket: bucket
+        path_strategy: explicit
+      gcs_credentials_secret: gcs
+      utility_images:
+        clonerefs: clonerefs
+        initupload: initupload
+        entrypoint: entrypoint
+        sidecar: sidecar
+`
+
+const presets = `
+presets:
+- labels:
+    preset-creds: "true"
+  env:
+  - name: CREDS
+    value: /etc/creds
+`
+
+func writeConfig(t *testing.T, jobs string) string {
+	dir, err := ioutil.TempDir("", "jobdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(prowConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "jobs", "presets.yaml"), []byte(presets), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "jobs", "jobs.yaml"), []byte(jobs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const beforeJobs = `
+presubmits:
+  org/repo:
+  - name: pull-unchanged
+    run_if_changed: '^docs/'
+    spec:
+      containers:
+      - image: image
+  - name: pull-preset
+    labels:
+      preset-creds: "true"
+    spec:
+      containers:
+      - image: image
+  - name: pull-optional
+    run_if_changed: '^src/'
+    spec:
+      containers:
+      - image: image
+periodics:
+- name: periodic-removed
+  interval: 1h
+  decorate: true
+  spec:
+    containers:
+    - image: image
+      command: [test]
+`
+
+const afterJobs = `
+presubmits:
+  org/repo:
+  - name: pull-unchanged
+    run_if_changed: '^docs/'
+    spec:
+      containers:
+      - image: image
+  - name: pull-preset
+    spec:
+      containers:
+      - image: image
+  - name: pull-optional
+    always_run: true
+    spec:
+      containers:
+      - image: image
+  - name: pull-added
+    always_run: true
+    decorate: true
+    spec:
+      containers:
+      - image: image
+        command: [test]
+`
+
+func TestDiff(t *testing.T) {
+	before, err := Load(writeConfig(t, beforeJobs), "config.yaml", "jobs")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	after, err := Load(writeConfig(t, afterJobs), "config.yaml", "jobs")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+
+	type summary struct {
+		Kind               ChangeKind
+		Type               prowapi.ProwJobType
+		Repo, Name         string
+		NewlyRunsOnEveryPR bool
+	}
+	var actual []summary
+	diffs := map[string]string{}
+	for _, c := range changes {
+		actual = append(actual, summary{Kind: c.Kind, Type: c.Type, Repo: c.Repo, Name: c.Name, NewlyRunsOnEveryPR: c.NewlyRunsOnEveryPR})
+		diffs[c.Name] = c.Diff
+	}
+	expected := []summary{
+		{Kind: Removed, Type: prowapi.PeriodicJob, Name: "periodic-removed"},
+		{Kind: Added, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-added", NewlyRunsOnEveryPR: true},
+		{Kind: Changed, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-optional", NewlyRunsOnEveryPR: true},
+		{Kind: Changed, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-preset"},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatalf("unexpected changes (-want +got):\n%s", diff)
+	}
+
+	// The diffs are of the effective config, so they contain resolved
+	// presets and decoration defaults.
+	for name, contains := range map[string][]string{
+		"pull-preset":      {"-    - name: CREDS", "-      value: /etc/creds"},
+		"pull-added":       {"+decoration_config:", "+    bucket: bucket"},
+		"periodic-removed": {"-  gcs_credentials_secret: gcs"},
+		"pull-optional":    {"+always_run: true", "-run_if_changed: ^src/"},
+	} {
+		for _, line := range contains {
+			if !strings.Contains(diffs[name], line+"\n") {
+				t.Errorf("expected diff of %s to contain %q, got:\n%s", name, line, diffs[name])
+			}
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+		},
+		{
+			name:     "added",
+			b:        "a\nb\n",
+			expected: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "changed line with context",
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:        "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "separate hunks",
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:        "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			expected: "@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, unifiedDiff(tc.a, tc.b)); diff != "" {
+				t.Errorf("unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	changes := []Change{
+		{Kind: Added, Type: prowapi.PresubmitJob, Repo: "org/repo", Name: "pull-added", Diff: "@@ -0,0 +1 @@\n+name: pull-added\n", NewlyRunsOnEveryPR: true},
+		{Kind: Removed, Type: prowapi.PeriodicJob, Name: "periodic", Diff: "@@ -1 +0,0 @@\n-name: periodic\n"},
+	}
+	full := Markdown(changes, 0)
+	for _, expected := range []string{
+		"| presubmit | org/repo | `pull-added` | added |",
+		"These presubmits will now run on every PR: `pull-added` (org/repo)",
+		"+name: pull-added",
+		"-name: periodic",
+	} {
+		if !strings.Contains(full, expected) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", expected, full)
+		}
+	}
+
+	truncated := Markdown(changes, 650)
+	if strings.Contains(truncated, "-name: periodic") || !strings.Contains(truncated, "diffs of 1 jobs were omitted") {
+		t.Errorf("expected the second diff to be omitted, got:\n%s", truncated)
+	}
+
+	if got := Markdown(nil, 0); got != "No effective job config changes.\n" {
+		t.Errorf("unexpected markdown for no changes: %q", got)
+	}
+}
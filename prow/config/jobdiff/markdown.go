@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the changes as a summary table, followed by the diff of
+// every job in a collapsed section. Diffs are left out once the output would
+// grow beyond maxLength; a maxLength of zero disables the limit.
+func Markdown(changes []Change, maxLength int) string {
+	if len(changes) == 0 {
+		return "No effective job config changes.\n"
+	}
+
+	summary := &strings.Builder{}
+	fmt.Fprintf(summary, "%d jobs changed in their effective config:\n\n", len(changes))
+	summary.WriteString("| Type | Repo | Job | Change |\n| --- | --- | --- | --- |\n")
+	var everyPR []string
+	for _, c := range changes {
+		fmt.Fprintf(summary, "| %s | %s | `%s` | %s |\n", c.Type, c.Repo, c.Name, c.Kind)
+		if c.NewlyRunsOnEveryPR {
+			everyPR = append(everyPR, fmt.Sprintf("`%s` (%s)", c.Name, c.Repo))
+		}
+	}
+	if len(everyPR) > 0 {
+		fmt.Fprintf(summary, "\n:warning: These presubmits will now run on every PR: %s\n", strings.Join(everyPR, ", "))
+	}
+
+	diffs := &strings.Builder{}
+	var omitted int
+	for _, c := range changes {
+		section := fmt.Sprintf("\n<details><summary>%s %s <code>%s</code></summary>\n\n```diff\n%s```\n</details>\n", c.Kind, c.Type, c.Name, c.Diff)
+		if maxLength > 0 && summary.Len()+diffs.Len()+len(section) > maxLength-200 {
+			omitted++
+			continue
+		}
+		diffs.WriteString(section)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(diffs, "\nThe diffs of %d jobs were omitted because the output is too long.\n", omitted)
+	}
+	return summary.String() + diffs.String()
+}
@@ -19,7 +19,10 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"strings"
 	"time"
 
@@ -95,6 +98,13 @@ type JobBase struct {
 	// Cluster is the alias of the cluster to run this job in.
 	// (Default: kube.DefaultClusterAlias)
 	Cluster string `json:"cluster,omitempty"`
+	// Clusters lists candidate clusters to run this job in. Plank picks the
+	// healthiest, least loaded one when the job starts and moves the job to
+	// another one if its pod cannot be scheduled. Overrides Cluster.
+	Clusters []string `json:"clusters,omitempty"`
+	// ClusterGroup names a group of candidate clusters configured in
+	// plank.cluster_groups. Mutually exclusive with Clusters.
+	ClusterGroup string `json:"cluster_group,omitempty"`
 	// Namespace is the namespace in which pods schedule.
 	//   nil: results in config.PodNamespace (aka pod default)
 	//   empty: results in config.ProwJobNamespace (aka same as prowjob)
@@ -121,6 +131,12 @@ type JobBase struct {
 	// Presubmits and Postsubmits can also be set to hidden by
 	// adding their repository in Decks `hidden_repo` setting.
 	Hidden bool `json:"hidden,omitempty"`
+	// Matrix expands the job into one job for every combination of the
+	// values of its axes. The name, labels, annotations, container images,
+	// commands, args and env values of the job can refer to the value of
+	// an axis with Go templates, e.g. `{{.k8s}}`. If the name does not
+	// contain a template, the values are appended to it.
+	Matrix map[string][]string `json:"matrix,omitempty"`
 
 	UtilityConfig
 }
@@ -182,10 +198,48 @@ type Periodic struct {
 	Cron string `json:"cron,omitempty"`
 	// Tags for config entries
 	Tags []string `json:"tags,omitempty"`
+	// TriggeredBy lists events that trigger the periodic in addition to its
+	// interval or cron. A periodic with triggers needs neither.
+	TriggeredBy []PeriodicTrigger `json:"triggered_by,omitempty"`
 
 	interval time.Duration
 }
 
+// PeriodicTrigger is an event that triggers a periodic. Exactly one of
+// its fields must be set.
+type PeriodicTrigger struct {
+	// JobSucceeded triggers the periodic when a run of the named job
+	// succeeds. This chains jobs, e.g. build -> test -> publish.
+	JobSucceeded string `json:"job_succeeded,omitempty"`
+	// Postsubmit triggers the periodic when a postsubmit of a repo completes.
+	Postsubmit *PostsubmitTrigger `json:"postsubmit,omitempty"`
+	// GCSObject triggers the periodic when the object at this
+	// gs://bucket/path changes.
+	GCSObject string `json:"gcs_object,omitempty"`
+}
+
+// PostsubmitTrigger selects the postsubmits that trigger a periodic.
+type PostsubmitTrigger struct {
+	// Repo is the org/repo the postsubmits run for.
+	Repo string `json:"repo"`
+	// Branches limits the trigger to postsubmits for these branches.
+	// Defaults to all branches.
+	Branches []string `json:"branches,omitempty"`
+	// Job limits the trigger to the named postsubmit. Defaults to all
+	// postsubmits of the repo.
+	Job string `json:"job,omitempty"`
+	// States the postsubmit must complete with. Defaults to success.
+	States []prowapi.ProwJobState `json:"states,omitempty"`
+}
+
+// GetStates returns the states the postsubmit must complete with.
+func (t *PostsubmitTrigger) GetStates() []prowapi.ProwJobState {
+	if len(t.States) == 0 {
+		return []prowapi.ProwJobState{prowapi.SuccessState}
+	}
+	return t.States
+}
+
 // JenkinsSpec holds optional Jenkins job config
 type JenkinsSpec struct {
 	// Job is managed by the GH branch source plugin
@@ -310,6 +364,84 @@ func (cm RegexpChangeMatcher) RunsAgainstChanges(changes []string) bool {
 	return false
 }
 
+// SparseCheckoutPaths derives the directories a job needs to check out from
+// its run_if_changed regex. It only succeeds for regexes anchored at the
+// start of the path whose every match begins with a literal directory, e.g.
+// `^(prow|ghproxy)/` yields prow and ghproxy. Otherwise it returns nil.
+func (cm RegexpChangeMatcher) SparseCheckoutPaths() []string {
+	if cm.RunIfChanged == "" {
+		return nil
+	}
+	re, err := syntax.Parse(cm.RunIfChanged, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || (re.Sub[0].Op != syntax.OpBeginText && re.Sub[0].Op != syntax.OpBeginLine) {
+		return nil
+	}
+	prefixes, ok := literalPrefixes(re.Sub[1:])
+	if !ok {
+		return nil
+	}
+	dirs := sets.NewString()
+	for _, prefix := range prefixes {
+		// Only whole directories can be checked out, so a prefix like
+		// prow/cmd/ho needs all of prow/cmd.
+		i := strings.LastIndex(prefix, "/")
+		if i <= 0 {
+			return nil
+		}
+		dirs.Insert(path.Clean(prefix[:i]))
+	}
+	paths := dirs.List()
+	sort.Strings(paths)
+	return paths
+}
+
+// literalPrefixes returns the literal strings every match of the
+// concatenation of subs starts with, or false if it cannot tell.
+func literalPrefixes(subs []*syntax.Regexp) ([]string, bool) {
+	if len(subs) == 0 {
+		return []string{""}, true
+	}
+	rest := subs[1:]
+	var head string
+	switch re := subs[0]; re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+		head = string(re.Rune)
+	case syntax.OpCapture:
+		return literalPrefixes(append([]*syntax.Regexp{re.Sub[0]}, rest...))
+	case syntax.OpConcat:
+		return literalPrefixes(append(append([]*syntax.Regexp{}, re.Sub...), rest...))
+	case syntax.OpAlternate:
+		var prefixes []string
+		for _, alternative := range re.Sub {
+			altPrefixes, ok := literalPrefixes(append([]*syntax.Regexp{alternative}, rest...))
+			if !ok {
+				return nil, false
+			}
+			prefixes = append(prefixes, altPrefixes...)
+		}
+		return prefixes, true
+	default:
+		// Anything but a literal ends the prefix.
+		return []string{""}, true
+	}
+	tails, ok := literalPrefixes(rest)
+	if !ok {
+		return nil, false
+	}
+	prefixes := make([]string, 0, len(tails))
+	for _, tail := range tails {
+		prefixes = append(prefixes, head+tail)
+	}
+	return prefixes, true
+}
+
 // CouldRun determines if the postsubmit could run against a specific
 // base ref
 func (ps Postsubmit) CouldRun(baseRef string) bool {
@@ -426,6 +558,18 @@ type UtilityConfig struct {
 	// SkipFetchHead tells prow to avoid a git fetch <remote> call.
 	// The git fetch <remote> <BaseRef> call occurs regardless.
 	SkipFetchHead bool `json:"skip_fetch_head,omitempty"`
+	// SparseCheckout lists the directories of the repository
+	// under test to check out. Files at the root of the
+	// repository are always checked out.
+	SparseCheckout []string `json:"sparse_checkout,omitempty"`
+	// SparseCheckoutFromRunIfChanged adds the directories that
+	// run_if_changed matches to SparseCheckout. It requires a
+	// run_if_changed anchored at the start of the path, like
+	// `^(cmd|pkg)/`.
+	SparseCheckoutFromRunIfChanged bool `json:"sparse_checkout_from_run_if_changed,omitempty"`
+	// CloneFilter is a partial clone filter, such as `blob:none`
+	// or `tree:0`, used to clone the repository under test.
+	CloneFilter string `json:"clone_filter,omitempty"`
 
 	// ExtraRefs are auxiliary repositories that
 	// need to be cloned, determined from config
@@ -461,16 +605,53 @@ func (u *UtilityConfig) Validate() error {
 	if err := cloneURIValidate(u.CloneURI); err != nil {
 		return err
 	}
+	if err := validateCloneOptions(u.SparseCheckout, u.CloneFilter); err != nil {
+		return err
+	}
 
 	for i, ref := range u.ExtraRefs {
 		if err := cloneURIValidate(ref.CloneURI); err != nil {
 			return fmt.Errorf("extra_ref[%d]: %v", i, err)
 		}
+		if err := validateCloneOptions(ref.SparseCheckout, ref.CloneFilter); err != nil {
+			return fmt.Errorf("extra_ref[%d]: %v", i, err)
+		}
 	}
 
 	return nil
 }
 
+var cloneFilterRegex = regexp.MustCompile(`^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$`)
+
+// validateCloneOptions ensures sparse checkout paths are directories inside
+// the repository and that the partial clone filter is one git understands.
+func validateCloneOptions(sparseCheckout []string, cloneFilter string) error {
+	for _, dir := range sparseCheckout {
+		if dir == "" || path.IsAbs(dir) || strings.ContainsAny(dir, "*?[\\") {
+			return fmt.Errorf("sparse_checkout: %q must be a relative directory without wildcards", dir)
+		}
+		if cleaned := path.Clean(dir); cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return fmt.Errorf("sparse_checkout: %q must be a directory inside the repository", dir)
+		}
+	}
+	if cloneFilter != "" && !cloneFilterRegex.MatchString(cloneFilter) {
+		return fmt.Errorf("clone_filter: %q must be one of blob:none, blob:limit=<n> or tree:<depth>", cloneFilter)
+	}
+	return nil
+}
+
+// validateSparseCheckout ensures jobs that derive their sparse checkout from
+// run_if_changed have a run_if_changed to derive it from.
+func validateSparseCheckout(jb JobBase, cm RegexpChangeMatcher) error {
+	if !jb.SparseCheckoutFromRunIfChanged {
+		return nil
+	}
+	if cm.SparseCheckoutPaths() == nil {
+		return fmt.Errorf("sparse_checkout_from_run_if_changed: cannot derive directories from run_if_changed %q", cm.RunIfChanged)
+	}
+	return nil
+}
+
 // SetPresubmits updates c.PresubmitStatic to jobs, after compiling and validating their regexes.
 func (c *JobConfig) SetPresubmits(jobs map[string][]Presubmit) error {
 	nj := map[string][]Presubmit{}
@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	coreapi "k8s.io/api/core/v1"
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
@@ -1157,6 +1158,45 @@ func TestUtilityConfigValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			id:    "sparse checkout and clone filter, no error",
+			valid: true,
+			uc: UtilityConfig{
+				SparseCheckout: []string{"prow", "ghproxy/ghcache"},
+				CloneFilter:    "blob:none",
+				ExtraRefs: []prowapi.Refs{
+					{
+						Org:         "org1",
+						Repo:        "repo1",
+						CloneFilter: "tree:0",
+					},
+				},
+			},
+		},
+		{
+			id: "sparse checkout outside the repository, error",
+			uc: UtilityConfig{
+				SparseCheckout: []string{"../prow"},
+			},
+		},
+		{
+			id: "sparse checkout with wildcards, error",
+			uc: UtilityConfig{
+				SparseCheckout: []string{"prow/*"},
+			},
+		},
+		{
+			id: "unknown clone filter on an extra ref, error",
+			uc: UtilityConfig{
+				ExtraRefs: []prowapi.Refs{
+					{
+						Org:         "org1",
+						Repo:        "repo1",
+						CloneFilter: "sparse:oid=abc",
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1170,3 +1210,59 @@ func TestUtilityConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestSparseCheckoutPaths(t *testing.T) {
+	testCases := []struct {
+		name         string
+		runIfChanged string
+		expected     []string
+	}{
+		{
+			name: "no run_if_changed",
+		},
+		{
+			name:         "single directory",
+			runIfChanged: "^prow/",
+			expected:     []string{"prow"},
+		},
+		{
+			name:         "alternation of directories sharing a prefix",
+			runIfChanged: "^(prow|pkg|ghproxy)/",
+			expected:     []string{"ghproxy", "pkg", "prow"},
+		},
+		{
+			name:         "partial file names check out their directory",
+			runIfChanged: `^prow/cmd/(hook|horologium)/.*\.go$`,
+			expected:     []string{"prow/cmd/hook", "prow/cmd/horologium"},
+		},
+		{
+			name:         "partial directory names check out their parent",
+			runIfChanged: "^prow/cmd/ho",
+			expected:     []string{"prow/cmd"},
+		},
+		{
+			name:         "unanchored regex",
+			runIfChanged: "prow/",
+		},
+		{
+			name:         "file at the root of the repository",
+			runIfChanged: "^(prow/|go.mod)",
+		},
+		{
+			name:         "non-literal directory",
+			runIfChanged: "^[a-z]+/",
+		},
+		{
+			name:         "case insensitive",
+			runIfChanged: "(?i)^prow/",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cm := RegexpChangeMatcher{RunIfChanged: tc.runIfChanged}
+			if diff := cmp.Diff(tc.expected, cm.SparseCheckoutPaths()); diff != "" {
+				t.Errorf("SparseCheckoutPaths() got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func init() {
+	RegisterRule("resource-requests", "Containers must set resource requests.", newResourceRequestsRule)
+	RegisterRule("privileged-containers", "Privileged containers may only run in trusted clusters.", newPrivilegedContainersRule)
+	RegisterRule("required-annotations", "Jobs must set the given annotations.", newRequiredAnnotationsRule)
+}
+
+// unmarshalParams unmarshals the params of a rule, if any, into into.
+func unmarshalParams(params []byte, into interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, into); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+func containers(spec *v1.PodSpec) []v1.Container {
+	if spec == nil {
+		return nil
+	}
+	return append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+}
+
+type resourceRequestsParams struct {
+	// Resources that must be requested, defaults to cpu and memory.
+	Resources []v1.ResourceName `json:"resources,omitempty"`
+}
+
+func newResourceRequestsRule(raw []byte) (Rule, error) {
+	params := resourceRequestsParams{Resources: []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}}
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	return RuleFunc(func(job Job) []string {
+		var messages []string
+		for _, container := range containers(job.Spec) {
+			for _, resource := range params.Resources {
+				if _, ok := container.Resources.Requests[resource]; !ok {
+					messages = append(messages, fmt.Sprintf("container %q does not request %s", container.Name, resource))
+				}
+			}
+		}
+		return messages
+	}), nil
+}
+
+type privilegedContainersParams struct {
+	// TrustedClusters may run privileged containers.
+	TrustedClusters []string `json:"trusted_clusters,omitempty"`
+}
+
+func newPrivilegedContainersRule(raw []byte) (Rule, error) {
+	params := privilegedContainersParams{}
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	trusted := sets.NewString(params.TrustedClusters...)
+	return RuleFunc(func(job Job) []string {
+		if trusted.Has(job.Cluster) {
+			return nil
+		}
+		var messages []string
+		for _, container := range containers(job.Spec) {
+			if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+				messages = append(messages, fmt.Sprintf("container %q is privileged but cluster %q is not trusted", container.Name, job.Cluster))
+			}
+		}
+		return messages
+	}), nil
+}
+
+type requiredAnnotationsParams struct {
+	Annotations []string `json:"annotations"`
+}
+
+func newRequiredAnnotationsRule(raw []byte) (Rule, error) {
+	params := requiredAnnotationsParams{}
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if len(params.Annotations) == 0 {
+		return nil, fmt.Errorf("params.annotations must not be empty")
+	}
+	return RuleFunc(func(job Job) []string {
+		var messages []string
+		for _, annotation := range params.Annotations {
+			if job.Annotations[annotation] == "" {
+				messages = append(messages, fmt.Sprintf("annotation %q is not set", annotation))
+			}
+		}
+		return messages
+	}), nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// celEnv declares the variables CEL expressions are evaluated with: `job` is
+// the JSON form of the job config, `job_type` the type of the job and `repo`
+// the org/repo it is configured for, empty for periodics.
+var celEnv = func() *cel.Env {
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("job", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("job_type", decls.String),
+		decls.NewVar("repo", decls.String),
+	))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create CEL environment: %v", err))
+	}
+	return env
+}()
+
+// celRule reports jobs for which a CEL expression does not hold, e.g.
+// `job_type != "periodic" || has(job.annotations.owner)`.
+type celRule struct {
+	expression string
+	program    cel.Program
+	message    string
+}
+
+func newCELRule(expression, message string) (Rule, error) {
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid CEL expression %q: %w", expression, issues.Err())
+	}
+	if resultType := ast.ResultType(); resultType.GetPrimitive() != exprpb.Type_BOOL && resultType.GetDyn() == nil {
+		return nil, fmt.Errorf("CEL expression %q must evaluate to a bool", expression)
+	}
+	program, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEL expression %q: %w", expression, err)
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s does not hold", expression)
+	}
+	return &celRule{expression: expression, program: program, message: message}, nil
+}
+
+func (r *celRule) Check(job Job) []string {
+	holds, err := r.evaluate(job)
+	if err != nil {
+		return []string{fmt.Sprintf("could not evaluate %s: %v", r.expression, err)}
+	}
+	if !holds {
+		return []string{r.message}
+	}
+	return nil
+}
+
+func (r *celRule) evaluate(job Job) (bool, error) {
+	object := job.Config
+	if object == nil {
+		object = job.JobBase
+	}
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return false, err
+	}
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return false, err
+	}
+	out, _, err := r.program.Eval(map[string]interface{}{
+		"job":      data,
+		"job_type": string(job.Type),
+		"repo":     job.Repo,
+	})
+	if err != nil {
+		return false, err
+	}
+	holds, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a bool, got %v", out.Value())
+	}
+	return holds, nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lint implements a rule engine that checks job configs against
+// rules, either registered in code or declared in a YAML file.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+// SuppressAnnotation lists the comma-separated IDs of rules that are not
+// checked for a job.
+const SuppressAnnotation = "checkconfig.prow.k8s.io/suppress"
+
+// Severity is the level of a finding. The values match SARIF levels.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Job is a job under check.
+type Job struct {
+	config.JobBase
+	Type prowapi.ProwJobType
+	// Repo is the org/repo the job is configured for, empty for periodics.
+	Repo string
+	// Config is the Presubmit, Postsubmit or Periodic the JobBase belongs to.
+	Config interface{}
+}
+
+// JobsFromConfig returns all jobs in the job config, sorted by type, repo and name.
+func JobsFromConfig(c config.JobConfig) []Job {
+	var jobs []Job
+	for repo, presubmits := range c.PresubmitsStatic {
+		for _, p := range presubmits {
+			jobs = append(jobs, Job{JobBase: p.JobBase, Type: prowapi.PresubmitJob, Repo: repo, Config: p})
+		}
+	}
+	for repo, postsubmits := range c.PostsubmitsStatic {
+		for _, p := range postsubmits {
+			jobs = append(jobs, Job{JobBase: p.JobBase, Type: prowapi.PostsubmitJob, Repo: repo, Config: p})
+		}
+	}
+	for _, p := range c.Periodics {
+		jobs = append(jobs, Job{JobBase: p.JobBase, Type: prowapi.PeriodicJob, Config: p})
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Type != jobs[j].Type {
+			return jobs[i].Type < jobs[j].Type
+		}
+		if jobs[i].Repo != jobs[j].Repo {
+			return jobs[i].Repo < jobs[j].Repo
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+// Rule checks a job and returns a message for every problem it finds.
+type Rule interface {
+	Check(job Job) []string
+}
+
+// RuleFunc adapts a function to a Rule.
+type RuleFunc func(job Job) []string
+
+// Check calls f(job).
+func (f RuleFunc) Check(job Job) []string {
+	return f(job)
+}
+
+// RuleFactory builds a rule from the params it was configured with. The params
+// are the JSON form of the `params` field of the rule config and may be empty.
+type RuleFactory func(params []byte) (Rule, error)
+
+type registration struct {
+	description string
+	factory     RuleFactory
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]registration{}
+)
+
+// RegisterRule makes a rule available under the given name. It is meant to
+// be called from init functions and panics when a name is registered twice.
+func RegisterRule(name, description string, factory RuleFactory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("rule %q is already registered", name))
+	}
+	registry[name] = registration{description: description, factory: factory}
+}
+
+// RegisteredRules returns the sorted names of all registered rules.
+func RegisteredRules() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := sets.NewString()
+	for name := range registry {
+		names.Insert(name)
+	}
+	return names.List()
+}
+
+// Config is the configuration of the rules to check.
+type Config struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// RuleConfig configures a single rule. A rule either uses a registered rule
+// or declares a CEL expression that must hold for every job.
+type RuleConfig struct {
+	// ID identifies the rule in findings and suppression annotations.
+	ID string `json:"id"`
+	// Description is a short description of the rule. Defaults to the
+	// description of the registered rule.
+	Description string `json:"description,omitempty"`
+	// Severity of the findings of the rule, defaults to warning.
+	Severity Severity `json:"severity,omitempty"`
+	// JobTypes limits the rule to jobs of the given types. Defaults to all types.
+	JobTypes []prowapi.ProwJobType `json:"job_types,omitempty"`
+
+	// Uses names the registered rule to check. Defaults to ID when no
+	// Expression is set.
+	Uses string `json:"uses,omitempty"`
+	// Params are passed to the registered rule.
+	Params json.RawMessage `json:"params,omitempty"`
+
+	// Expression is a CEL expression, e.g. `has(job.annotations.owner)`, that
+	// must evaluate to true. It can use `job`, the job config as it is written
+	// in YAML, `job_type` and `repo`, the org/repo of the job.
+	Expression string `json:"expression,omitempty"`
+	// Message is reported for jobs violating Expression.
+	Message string `json:"message,omitempty"`
+}
+
+// LoadConfig loads a rule config from a YAML file.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(raw, c); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Finding is a violation of a rule by a job.
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Job      Job
+	Message  string
+}
+
+func (f Finding) String() string {
+	location := f.Job.Name
+	if f.Job.SourcePath != "" {
+		location = fmt.Sprintf("%s (%s)", location, f.Job.SourcePath)
+	}
+	return fmt.Sprintf("[%s] %s: %s", f.RuleID, location, f.Message)
+}
+
+type compiledRule struct {
+	RuleConfig
+	rule     Rule
+	jobTypes sets.String
+}
+
+// Linter checks jobs against a set of rules.
+type Linter struct {
+	rules []compiledRule
+}
+
+// NewLinter compiles the rules in the config.
+func NewLinter(c Config) (*Linter, error) {
+	l := &Linter{}
+	seen := sets.NewString()
+	for _, rc := range c.Rules {
+		if rc.ID == "" {
+			return nil, fmt.Errorf("rule %+v has no id", rc)
+		}
+		if seen.Has(rc.ID) {
+			return nil, fmt.Errorf("rule %q is configured more than once", rc.ID)
+		}
+		seen.Insert(rc.ID)
+		compiled, err := compile(rc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rc.ID, err)
+		}
+		l.rules = append(l.rules, *compiled)
+	}
+	return l, nil
+}
+
+func compile(rc RuleConfig) (*compiledRule, error) {
+	switch rc.Severity {
+	case "":
+		rc.Severity = SeverityWarning
+	case SeverityError, SeverityWarning, SeverityNote:
+	default:
+		return nil, fmt.Errorf("invalid severity %q, must be one of %q, %q or %q", rc.Severity, SeverityError, SeverityWarning, SeverityNote)
+	}
+	jobTypes := sets.NewString()
+	for _, t := range rc.JobTypes {
+		jobTypes.Insert(string(t))
+	}
+
+	if rc.Uses != "" && rc.Expression != "" {
+		return nil, fmt.Errorf("only one of uses or expression may be set")
+	}
+
+	var rule Rule
+	var err error
+	switch {
+	case rc.Expression != "":
+		rule, err = newCELRule(rc.Expression, rc.Message)
+	default:
+		if rc.Uses == "" {
+			rc.Uses = rc.ID
+		}
+		registryLock.RLock()
+		reg, ok := registry[rc.Uses]
+		registryLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("no such rule %q, registered rules: %v", rc.Uses, RegisteredRules())
+		}
+		if rc.Description == "" {
+			rc.Description = reg.description
+		}
+		rule, err = reg.factory(rc.Params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &compiledRule{RuleConfig: rc, rule: rule, jobTypes: jobTypes}, nil
+}
+
+// Lint checks all jobs against all rules, skipping rules the job suppresses.
+func (l *Linter) Lint(jobs []Job) []Finding {
+	var findings []Finding
+	for _, job := range jobs {
+		suppressed := suppressedRules(job)
+		for _, r := range l.rules {
+			if suppressed.Has(r.ID) {
+				continue
+			}
+			if r.jobTypes.Len() > 0 && !r.jobTypes.Has(string(job.Type)) {
+				continue
+			}
+			for _, message := range r.rule.Check(job) {
+				findings = append(findings, Finding{RuleID: r.ID, Severity: r.Severity, Job: job, Message: message})
+			}
+		}
+	}
+	return findings
+}
+
+func suppressedRules(job Job) sets.String {
+	suppressed := sets.NewString()
+	for _, id := range strings.Split(job.Annotations[SuppressAnnotation], ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			suppressed.Insert(id)
+		}
+	}
+	return suppressed
+}
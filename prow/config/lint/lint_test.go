@@ -0,0 +1,270 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+func jobConfig() config.JobConfig {
+	privileged := true
+	requests := v1.ResourceList{v1.ResourceCPU: resource.MustParse("1"), v1.ResourceMemory: resource.MustParse("1Gi")}
+	return config.JobConfig{
+		PresubmitsStatic: map[string][]config.Presubmit{
+			"org/repo": {
+				{
+					JobBase: config.JobBase{
+						Name:       "pull-privileged",
+						Cluster:    "default",
+						SourcePath: "org/repo/presubmits.yaml",
+						Spec: &v1.PodSpec{Containers: []v1.Container{{
+							Name:            "test",
+							Resources:       v1.ResourceRequirements{Requests: requests},
+							SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+						}}},
+					},
+				},
+				{
+					JobBase: config.JobBase{
+						Name:    "pull-trusted",
+						Cluster: "trusted",
+						Spec: &v1.PodSpec{Containers: []v1.Container{{
+							Name:            "test",
+							Resources:       v1.ResourceRequirements{Requests: requests},
+							SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+						}}},
+					},
+				},
+			},
+		},
+		PostsubmitsStatic: map[string][]config.Postsubmit{
+			"org/repo": {{
+				JobBase: config.JobBase{
+					Name: "post-no-requests",
+					Spec: &v1.PodSpec{Containers: []v1.Container{{Name: "test"}}},
+				},
+			}},
+		},
+		Periodics: []config.Periodic{
+			{
+				JobBase: config.JobBase{
+					Name:        "periodic-owned",
+					Annotations: map[string]string{"owner": "sig-testing"},
+					Spec:        &v1.PodSpec{Containers: []v1.Container{{Name: "test", Resources: v1.ResourceRequirements{Requests: requests}}}},
+				},
+			},
+			{
+				JobBase: config.JobBase{
+					Name:        "periodic-suppressed",
+					Annotations: map[string]string{SuppressAnnotation: "periodic-owner, resource-requests"},
+					Spec:        &v1.PodSpec{Containers: []v1.Container{{Name: "test"}}},
+				},
+			},
+			{
+				JobBase: config.JobBase{
+					Name: "periodic-unowned",
+					Spec: &v1.PodSpec{Containers: []v1.Container{{Name: "test", Resources: v1.ResourceRequirements{Requests: requests}}}},
+				},
+			},
+		},
+	}
+}
+
+type finding struct {
+	Rule, Job, Message string
+}
+
+func TestLint(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		rules    []RuleConfig
+		expected []finding
+	}{
+		{
+			name:  "resource requests",
+			rules: []RuleConfig{{ID: "resource-requests"}},
+			expected: []finding{
+				{Rule: "resource-requests", Job: "post-no-requests", Message: `container "test" does not request cpu`},
+				{Rule: "resource-requests", Job: "post-no-requests", Message: `container "test" does not request memory`},
+			},
+		},
+		{
+			name:  "resource requests with params",
+			rules: []RuleConfig{{ID: "resource-requests", Params: json.RawMessage(`{"resources":["memory"]}`)}},
+			expected: []finding{
+				{Rule: "resource-requests", Job: "post-no-requests", Message: `container "test" does not request memory`},
+			},
+		},
+		{
+			name:  "privileged containers in untrusted clusters",
+			rules: []RuleConfig{{ID: "trusted-clusters", Uses: "privileged-containers", Params: json.RawMessage(`{"trusted_clusters":["trusted"]}`)}},
+			expected: []finding{
+				{Rule: "trusted-clusters", Job: "pull-privileged", Message: `container "test" is privileged but cluster "default" is not trusted`},
+			},
+		},
+		{
+			name: "required annotations on periodics",
+			rules: []RuleConfig{{
+				ID:       "periodic-owner",
+				Uses:     "required-annotations",
+				JobTypes: []prowapi.ProwJobType{prowapi.PeriodicJob},
+				Params:   json.RawMessage(`{"annotations":["owner"]}`),
+			}},
+			expected: []finding{
+				{Rule: "periodic-owner", Job: "periodic-unowned", Message: `annotation "owner" is not set`},
+			},
+		},
+		{
+			name: "expression rule",
+			rules: []RuleConfig{{
+				ID:         "periodic-owner",
+				Expression: `job_type != "periodic" || ("annotations" in job && "owner" in job.annotations)`,
+				Message:    "periodics must have an owner annotation",
+			}},
+			expected: []finding{
+				{Rule: "periodic-owner", Job: "periodic-unowned", Message: "periodics must have an owner annotation"},
+			},
+		},
+		{
+			name: "expression rule with macro",
+			rules: []RuleConfig{{
+				ID:         "no-privileged",
+				JobTypes:   []prowapi.ProwJobType{prowapi.PresubmitJob},
+				Expression: `repo == "org/repo" && !job.spec.containers.exists(c, has(c.securityContext) && c.securityContext.privileged)`,
+			}},
+			expected: []finding{
+				{Rule: "no-privileged", Job: "pull-privileged", Message: `repo == "org/repo" && !job.spec.containers.exists(c, has(c.securityContext) && c.securityContext.privileged) does not hold`},
+				{Rule: "no-privileged", Job: "pull-trusted", Message: `repo == "org/repo" && !job.spec.containers.exists(c, has(c.securityContext) && c.securityContext.privileged) does not hold`},
+			},
+		},
+		{
+			name: "expression rule that fails to evaluate",
+			rules: []RuleConfig{{
+				ID:         "owner",
+				JobTypes:   []prowapi.ProwJobType{prowapi.PostsubmitJob},
+				Expression: `job.annotations.owner != ""`,
+			}},
+			expected: []finding{
+				{Rule: "owner", Job: "post-no-requests", Message: `could not evaluate job.annotations.owner != "": no such key: annotations`},
+			},
+		},
+	}
+
+	jobs := JobsFromConfig(jobConfig())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			linter, err := NewLinter(Config{Rules: tc.rules})
+			if err != nil {
+				t.Fatalf("failed to create linter: %v", err)
+			}
+			var actual []finding
+			for _, f := range linter.Lint(jobs) {
+				actual = append(actual, finding{Rule: f.RuleID, Job: f.Job.Name, Message: f.Message})
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected findings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewLinterErrors(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		rules []RuleConfig
+	}{
+		{name: "missing id", rules: []RuleConfig{{Uses: "resource-requests"}}},
+		{name: "duplicate id", rules: []RuleConfig{{ID: "resource-requests"}, {ID: "resource-requests"}}},
+		{name: "unknown rule", rules: []RuleConfig{{ID: "unknown"}}},
+		{name: "invalid severity", rules: []RuleConfig{{ID: "resource-requests", Severity: "fatal"}}},
+		{name: "uses and expression", rules: []RuleConfig{{ID: "rule", Uses: "resource-requests", Expression: "has(job.name)"}}},
+		{name: "invalid expression", rules: []RuleConfig{{ID: "rule", Expression: "has(job.name"}}},
+		{name: "undeclared variable", rules: []RuleConfig{{ID: "rule", Expression: `cluster == "default"`}}},
+		{name: "expression is not a bool", rules: []RuleConfig{{ID: "rule", Expression: `repo + "/"`}}},
+		{name: "invalid params", rules: []RuleConfig{{ID: "required-annotations", Params: json.RawMessage(`{"annotations":"owner"}`)}}},
+		{name: "missing params", rules: []RuleConfig{{ID: "required-annotations"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewLinter(Config{Rules: tc.rules}); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	linter, err := NewLinter(Config{Rules: []RuleConfig{{
+		ID:       "trusted-clusters",
+		Uses:     "privileged-containers",
+		Severity: SeverityError,
+		Params:   json.RawMessage(`{"trusted_clusters":["trusted"]}`),
+	}}})
+	if err != nil {
+		t.Fatalf("failed to create linter: %v", err)
+	}
+	findings := linter.Lint(JobsFromConfig(jobConfig()))
+
+	buf := &bytes.Buffer{}
+	if err := linter.WriteSARIF(buf, findings); err != nil {
+		t.Fatalf("failed to write SARIF: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("failed to unmarshal SARIF: %v", err)
+	}
+	expected := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name: "checkconfig",
+				Rules: []sarifRule{{
+					ID:               "trusted-clusters",
+					ShortDescription: sarifMessage{Text: "Privileged containers may only run in trusted clusters."},
+					DefaultLevel:     sarifConfig{Level: SeverityError},
+				}},
+			}},
+			Results: []sarifResult{{
+				RuleID:  "trusted-clusters",
+				Level:   SeverityError,
+				Message: sarifMessage{Text: `container "test" is privileged but cluster "default" is not trusted`},
+				Locations: []sarifLocation{{
+					PhysicalLocation: &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: "org/repo/presubmits.yaml"}},
+					LogicalLocations: []sarifLogicalLocation{{
+						Name:               "pull-privileged",
+						FullyQualifiedName: "presubmit/org/repo/pull-privileged",
+						Kind:               "job",
+					}},
+				}},
+			}},
+		}},
+	}
+	if diff := cmp.Diff(expected, log); diff != "" {
+		t.Errorf("unexpected SARIF (-want +got):\n%s", diff)
+	}
+}
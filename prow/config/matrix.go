@@ -0,0 +1,411 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+// maxMatrixJobs caps the number of jobs a single matrix can expand into,
+// so that a typo in the config can not create thousands of jobs.
+const maxMatrixJobs = 256
+
+var (
+	matrixAxisRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	matrixValueRe = regexp.MustCompile(`^[^\s,=]+$`)
+	matrixTestRe  = regexp.MustCompile(`(?m)^/test\s+(.*)$`)
+
+	matrixTemplateRe  = regexp.MustCompile(`\{\{.*?\}\}`)
+	matrixSeparatorRe = regexp.MustCompile(`[-_.]{2,}`)
+)
+
+// matrixExpansion is one combination of the values of the axes of a matrix.
+type matrixExpansion struct {
+	values map[string]string
+	// suffix is appended to the job name if it is not a template.
+	suffix string
+	// annotation is the value of the kube.MatrixValuesAnnotation.
+	annotation string
+}
+
+// matrixExpansions returns all combinations of the values of the axes of
+// the matrix, ordered by the axis names and the order of the values.
+func matrixExpansions(matrix map[string][]string) ([]matrixExpansion, error) {
+	axes := make([]string, 0, len(matrix))
+	total := 1
+	for axis, values := range matrix {
+		if !matrixAxisRe.MatchString(axis) {
+			return nil, fmt.Errorf("matrix axis %q must match %s", axis, matrixAxisRe.String())
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix axis %q has no values", axis)
+		}
+		seen := map[string]bool{}
+		for _, value := range values {
+			if !matrixValueRe.MatchString(value) {
+				return nil, fmt.Errorf("value %q of matrix axis %q must not be empty or contain whitespace, ',' or '='", value, axis)
+			}
+			if seen[value] {
+				return nil, fmt.Errorf("matrix axis %q has duplicate value %q", axis, value)
+			}
+			seen[value] = true
+		}
+		axes = append(axes, axis)
+		total *= len(values)
+		if total > maxMatrixJobs {
+			return nil, fmt.Errorf("matrix expands into more than %d jobs", maxMatrixJobs)
+		}
+	}
+	sort.Strings(axes)
+
+	combinations := []map[string]string{{}}
+	for _, axis := range axes {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range matrix[axis] {
+				values := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					values[k] = v
+				}
+				values[axis] = value
+				next = append(next, values)
+			}
+		}
+		combinations = next
+	}
+
+	expansions := make([]matrixExpansion, 0, len(combinations))
+	for _, values := range combinations {
+		var suffix, annotation []string
+		for _, axis := range axes {
+			suffix = append(suffix, values[axis])
+			annotation = append(annotation, axis+"="+values[axis])
+		}
+		expansions = append(expansions, matrixExpansion{
+			values:     values,
+			suffix:     strings.Join(suffix, "-"),
+			annotation: strings.Join(annotation, ","),
+		})
+	}
+	return expansions, nil
+}
+
+// renderMatrixTemplate executes the value as a template over the values of
+// the expansion. Values without template actions are returned unchanged.
+func renderMatrixTemplate(value string, values map[string]string) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	t, err := template.New("matrix").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %q: %w", value, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, values); err != nil {
+		return "", fmt.Errorf("failed to execute template %q: %w", value, err)
+	}
+	return buf.String(), nil
+}
+
+// matrixRenderer renders templates for one expansion and collects the
+// errors, so that callers can render many fields without checking each.
+type matrixRenderer struct {
+	values map[string]string
+	errs   []error
+}
+
+func (r *matrixRenderer) render(value *string) {
+	rendered, err := renderMatrixTemplate(*value, r.values)
+	if err != nil {
+		r.errs = append(r.errs, err)
+		return
+	}
+	*value = rendered
+}
+
+func (r *matrixRenderer) renderMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		r.render(&v)
+		out[k] = v
+	}
+	return out
+}
+
+func (r *matrixRenderer) renderContainers(containers []v1.Container) {
+	for i := range containers {
+		c := &containers[i]
+		r.render(&c.Image)
+		for j := range c.Command {
+			r.render(&c.Command[j])
+		}
+		for j := range c.Args {
+			r.render(&c.Args[j])
+		}
+		for j := range c.Env {
+			r.render(&c.Env[j].Value)
+		}
+	}
+}
+
+// MatrixName returns the name that identifies the matrix of the job with the
+// given name, e.g. in `/test` comments. That is the name without templates.
+func MatrixName(name string) string {
+	stripped := matrixTemplateRe.ReplaceAllString(name, "")
+	stripped = matrixSeparatorRe.ReplaceAllStringFunc(stripped, func(s string) string { return s[:1] })
+	return strings.Trim(stripped, "-_.")
+}
+
+// expandJobBase returns a copy of the job for every expansion of its matrix.
+// The copies share no mutable state with the job or each other.
+func expandJobBase(base JobBase, render func(*matrixRenderer)) ([]JobBase, error) {
+	expansions, err := matrixExpansions(base.Matrix)
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %w", base.Name, err)
+	}
+	jobs := make([]JobBase, 0, len(expansions))
+	for _, expansion := range expansions {
+		r := &matrixRenderer{values: expansion.values}
+		job := base
+		job.Matrix = nil
+		if strings.Contains(base.Name, "{{") {
+			r.render(&job.Name)
+		} else {
+			job.Name = base.Name + "-" + expansion.suffix
+		}
+		job.Labels = r.renderMap(base.Labels)
+		job.Annotations = r.renderMap(base.Annotations)
+		if job.Annotations == nil {
+			job.Annotations = map[string]string{}
+		}
+		job.Annotations[kube.MatrixAnnotation] = MatrixName(base.Name)
+		job.Annotations[kube.MatrixValuesAnnotation] = expansion.annotation
+		if base.Spec != nil {
+			job.Spec = base.Spec.DeepCopy()
+			r.renderContainers(job.Spec.InitContainers)
+			r.renderContainers(job.Spec.Containers)
+		}
+		if base.DecorationConfig != nil {
+			job.DecorationConfig = base.DecorationConfig.DeepCopy()
+		}
+		if render != nil {
+			render(r)
+		}
+		if err := utilerrors.NewAggregate(r.errs); err != nil {
+			return nil, fmt.Errorf("job %s: matrix expansion %s: %w", base.Name, expansion.annotation, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// expandPresubmits replaces every presubmit that has a matrix with the
+// presubmits it expands into.
+func expandPresubmits(presubmits []Presubmit) ([]Presubmit, error) {
+	var expanded []Presubmit
+	var errs []error
+	for _, ps := range presubmits {
+		if len(ps.Matrix) == 0 {
+			expanded = append(expanded, ps)
+			continue
+		}
+		var rendered [][3]string
+		bases, err := expandJobBase(ps.JobBase, func(r *matrixRenderer) {
+			fields := [3]string{ps.Context, ps.Trigger, ps.RerunCommand}
+			for i := range fields {
+				r.render(&fields[i])
+			}
+			rendered = append(rendered, fields)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, base := range bases {
+			job := ps
+			job.JobBase = base
+			job.Context, job.Trigger, job.RerunCommand = rendered[i][0], rendered[i][1], rendered[i][2]
+			expanded = append(expanded, job)
+		}
+	}
+	return expanded, utilerrors.NewAggregate(errs)
+}
+
+// expandPostsubmits replaces every postsubmit that has a matrix with the
+// postsubmits it expands into.
+func expandPostsubmits(postsubmits []Postsubmit) ([]Postsubmit, error) {
+	var expanded []Postsubmit
+	var errs []error
+	for _, ps := range postsubmits {
+		if len(ps.Matrix) == 0 {
+			expanded = append(expanded, ps)
+			continue
+		}
+		var contexts []string
+		bases, err := expandJobBase(ps.JobBase, func(r *matrixRenderer) {
+			context := ps.Context
+			r.render(&context)
+			contexts = append(contexts, context)
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, base := range bases {
+			job := ps
+			job.JobBase = base
+			job.Context = contexts[i]
+			expanded = append(expanded, job)
+		}
+	}
+	return expanded, utilerrors.NewAggregate(errs)
+}
+
+// expandPeriodics replaces every periodic that has a matrix with the
+// periodics it expands into.
+func expandPeriodics(periodics []Periodic) ([]Periodic, error) {
+	var expanded []Periodic
+	var errs []error
+	for _, p := range periodics {
+		if len(p.Matrix) == 0 {
+			expanded = append(expanded, p)
+			continue
+		}
+		bases, err := expandJobBase(p.JobBase, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, base := range bases {
+			job := p
+			job.JobBase = base
+			expanded = append(expanded, job)
+		}
+	}
+	return expanded, utilerrors.NewAggregate(errs)
+}
+
+// expandMatrixJobs replaces all jobs of the job config that have a matrix
+// with the jobs they expand into, so that consumers only see concrete jobs.
+func (c *JobConfig) expandMatrixJobs() error {
+	var errs []error
+	for repo, jobs := range c.PresubmitsStatic {
+		expanded, err := expandPresubmits(jobs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.PresubmitsStatic[repo] = expanded
+	}
+	for repo, jobs := range c.PostsubmitsStatic {
+		expanded, err := expandPostsubmits(jobs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.PostsubmitsStatic[repo] = expanded
+	}
+	expanded, err := expandPeriodics(c.Periodics)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		c.Periodics = expanded
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// MatrixValues returns the value of each axis of the matrix the job was
+// expanded from, or nil if the job was not expanded from a matrix.
+func (jb JobBase) MatrixValues() map[string]string {
+	annotation, ok := jb.Annotations[kube.MatrixValuesAnnotation]
+	if !ok {
+		return nil
+	}
+	return ParseMatrixValues(annotation)
+}
+
+// ParseMatrixValues parses the value of the kube.MatrixValuesAnnotation.
+func ParseMatrixValues(annotation string) map[string]string {
+	values := map[string]string{}
+	for _, pair := range strings.Split(annotation, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values
+}
+
+// MatrixMatches returns true if the comment body requests the job through the
+// matrix it was expanded from, e.g. `/test pull-e2e k8s=1.20,1.21 cloud=gce`.
+// Axes that are not filtered in the comment match any value.
+func (jb JobBase) MatrixMatches(body string) bool {
+	parent, ok := jb.Annotations[kube.MatrixAnnotation]
+	if !ok {
+		return false
+	}
+	values := jb.MatrixValues()
+	for _, match := range matrixTestRe.FindAllStringSubmatch(body, -1) {
+		fields := strings.Fields(match[1])
+		for i, field := range fields {
+			if strings.TrimSuffix(field, ",") != parent {
+				continue
+			}
+			if matrixFiltersMatch(fields[i+1:], values) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matrixFiltersMatch checks the axis=value filters at the start of the
+// fields against the values. Filtering on an unknown axis never matches.
+func matrixFiltersMatch(fields []string, values map[string]string) bool {
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			break
+		}
+		value, ok := values[parts[0]]
+		if !ok {
+			return false
+		}
+		matched := false
+		for _, allowed := range strings.Split(parts[1], ",") {
+			matched = matched || allowed == value
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestExpandPresubmits(t *testing.T) {
+	testCases := []struct {
+		name         string
+		presubmit    Presubmit
+		expected     []Presubmit
+		expectedErrs string
+	}{
+		{
+			name:      "job without matrix is unchanged",
+			presubmit: Presubmit{JobBase: JobBase{Name: "job"}},
+			expected:  []Presubmit{{JobBase: JobBase{Name: "job"}}},
+		},
+		{
+			name: "values are appended to names without templates",
+			presubmit: Presubmit{
+				JobBase: JobBase{
+					Name:   "pull-e2e",
+					Matrix: map[string][]string{"k8s": {"1.20", "1.21"}, "cloud": {"gce"}},
+					Spec: &v1.PodSpec{Containers: []v1.Container{{
+						Image: "e2e:{{.k8s}}",
+						Args:  []string{"--cloud={{.cloud}}"},
+						Env:   []v1.EnvVar{{Name: "K8S", Value: "{{.k8s}}"}},
+					}}},
+				},
+				Reporter: Reporter{Context: "e2e ({{.cloud}})"},
+			},
+			expected: []Presubmit{
+				{
+					JobBase: JobBase{
+						Name: "pull-e2e-gce-1.20",
+						Annotations: map[string]string{
+							kube.MatrixAnnotation:       "pull-e2e",
+							kube.MatrixValuesAnnotation: "cloud=gce,k8s=1.20",
+						},
+						Spec: &v1.PodSpec{Containers: []v1.Container{{
+							Image: "e2e:1.20",
+							Args:  []string{"--cloud=gce"},
+							Env:   []v1.EnvVar{{Name: "K8S", Value: "1.20"}},
+						}}},
+					},
+					Reporter: Reporter{Context: "e2e (gce)"},
+				},
+				{
+					JobBase: JobBase{
+						Name: "pull-e2e-gce-1.21",
+						Annotations: map[string]string{
+							kube.MatrixAnnotation:       "pull-e2e",
+							kube.MatrixValuesAnnotation: "cloud=gce,k8s=1.21",
+						},
+						Spec: &v1.PodSpec{Containers: []v1.Container{{
+							Image: "e2e:1.21",
+							Args:  []string{"--cloud=gce"},
+							Env:   []v1.EnvVar{{Name: "K8S", Value: "1.21"}},
+						}}},
+					},
+					Reporter: Reporter{Context: "e2e (gce)"},
+				},
+			},
+		},
+		{
+			name: "templated name, labels and trigger",
+			presubmit: Presubmit{
+				JobBase: JobBase{
+					Name:        "pull-{{.cloud}}-e2e",
+					Matrix:      map[string][]string{"cloud": {"aws"}},
+					Labels:      map[string]string{"preset-cloud": "{{.cloud}}"},
+					Annotations: map[string]string{"testgrid-tab-name": "e2e {{.cloud}}"},
+				},
+				Trigger:      `(?m)^/e2e {{.cloud}}$`,
+				RerunCommand: "/e2e {{.cloud}}",
+			},
+			expected: []Presubmit{
+				{
+					JobBase: JobBase{
+						Name:   "pull-aws-e2e",
+						Labels: map[string]string{"preset-cloud": "aws"},
+						Annotations: map[string]string{
+							"testgrid-tab-name":         "e2e aws",
+							kube.MatrixAnnotation:       "pull-e2e",
+							kube.MatrixValuesAnnotation: "cloud=aws",
+						},
+					},
+					Trigger:      `(?m)^/e2e aws$`,
+					RerunCommand: "/e2e aws",
+				},
+			},
+		},
+		{
+			name: "unknown axis in template",
+			presubmit: Presubmit{
+				JobBase: JobBase{Name: "pull-{{.typo}}", Matrix: map[string][]string{"cloud": {"aws"}}},
+			},
+			expectedErrs: `job pull-{{.typo}}: matrix expansion cloud=aws: failed to execute template "pull-{{.typo}}": template: matrix:1:7: executing "matrix" at <.typo>: map has no entry for key "typo"`,
+		},
+		{
+			name: "invalid value",
+			presubmit: Presubmit{
+				JobBase: JobBase{Name: "pull", Matrix: map[string][]string{"cloud": {"a,b"}}},
+			},
+			expectedErrs: `job pull: value "a,b" of matrix axis "cloud" must not be empty or contain whitespace, ',' or '='`,
+		},
+		{
+			name: "axis without values",
+			presubmit: Presubmit{
+				JobBase: JobBase{Name: "pull", Matrix: map[string][]string{"cloud": nil}},
+			},
+			expectedErrs: `job pull: matrix axis "cloud" has no values`,
+		},
+		{
+			name: "invalid axis",
+			presubmit: Presubmit{
+				JobBase: JobBase{Name: "pull", Matrix: map[string][]string{"k8s-version": {"1.20"}}},
+			},
+			expectedErrs: "job pull: matrix axis \"k8s-version\" must match ^[A-Za-z_][A-Za-z0-9_]*$",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := expandPresubmits([]Presubmit{tc.presubmit})
+			var errs string
+			if err != nil {
+				errs = err.Error()
+			}
+			if errs != tc.expectedErrs {
+				t.Fatalf("expected error %q, got %q", tc.expectedErrs, errs)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmp.AllowUnexported(Presubmit{}, Brancher{}, RegexpChangeMatcher{})); diff != "" {
+				t.Errorf("unexpected presubmits: %s", diff)
+			}
+		})
+	}
+}
+
+func TestExpandedJobsDoNotShareState(t *testing.T) {
+	periodics, err := expandPeriodics([]Periodic{{
+		JobBase: JobBase{
+			Name:   "ci-e2e",
+			Matrix: map[string][]string{"k8s": {"1.20", "1.21"}},
+			Spec:   &v1.PodSpec{Containers: []v1.Container{{Args: []string{"{{.k8s}}"}}}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	periodics[0].Spec.Containers[0].Env = append(periodics[0].Spec.Containers[0].Env, v1.EnvVar{Name: "FOO"})
+	periodics[0].Annotations["foo"] = "bar"
+	if len(periodics[1].Spec.Containers[0].Env) != 0 || periodics[1].Annotations["foo"] != "" {
+		t.Errorf("expanded periodics share state: %+v", periodics[1])
+	}
+	if periodics[1].Spec.Containers[0].Args[0] != "1.21" {
+		t.Errorf("expected args of second expansion to be rendered, got %v", periodics[1].Spec.Containers[0].Args)
+	}
+}
+
+func TestMatrixExpansionLimit(t *testing.T) {
+	values := make([]string, 17)
+	for i := range values {
+		values[i] = string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	if _, err := matrixExpansions(map[string][]string{"a": values, "b": values}); err == nil {
+		t.Error("expected an error for a matrix with more than the maximum number of jobs")
+	}
+}
+
+func TestMatrixName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"pull-e2e":                       "pull-e2e",
+		"pull-e2e-{{.cloud}}":            "pull-e2e",
+		"pull-{{.cloud}}-e2e-{{.k8s}}":   "pull-e2e",
+		"{{.cloud}}.e2e":                 "e2e",
+		"pull_{{.cloud}}_{{ .k8s }}_e2e": "pull_e2e",
+	} {
+		if actual := MatrixName(name); actual != expected {
+			t.Errorf("MatrixName(%q): expected %q, got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestMatrixMatches(t *testing.T) {
+	job := JobBase{
+		Name: "pull-e2e-gce-1.20",
+		Annotations: map[string]string{
+			kube.MatrixAnnotation:       "pull-e2e",
+			kube.MatrixValuesAnnotation: "cloud=gce,k8s=1.20",
+		},
+	}
+	testCases := []struct {
+		body     string
+		job      JobBase
+		expected bool
+	}{
+		{body: "/test pull-e2e", job: job, expected: true},
+		{body: "/test pull-e2e k8s=1.20", job: job, expected: true},
+		{body: "/test pull-e2e k8s=1.19,1.20 cloud=gce", job: job, expected: true},
+		{body: "/test pull-e2e k8s=1.21", job: job},
+		{body: "/test pull-e2e region=us", job: job},
+		{body: "/test other pull-e2e cloud=gce", job: job, expected: true},
+		{body: "/test pull-e2e cloud=aws\n/test pull-e2e k8s=1.20", job: job, expected: true},
+		{body: "/test pull-e2e-gce", job: job},
+		{body: "please /test pull-e2e", job: job},
+		{body: "/test pull-e2e", job: JobBase{Name: "pull-e2e"}},
+	}
+	for _, tc := range testCases {
+		if actual := tc.job.MatrixMatches(tc.body); actual != tc.expected {
+			t.Errorf("%s: body %q: expected %t, got %t", tc.job.Name, tc.body, tc.expected, actual)
+		}
+	}
+}
@@ -54,11 +54,21 @@ type gcsK8sReporter struct {
 type PodReport struct {
 	Pod    *v1.Pod    `json:"pod,omitempty"`
 	Events []v1.Event `json:"events,omitempty"`
+	// Node is the state of the node the pod ran on. It is only captured
+	// for jobs that failed.
+	Node *NodeReport `json:"node,omitempty"`
+}
+
+// NodeReport holds the parts of a node that help explain why a pod failed.
+type NodeReport struct {
+	Name       string             `json:"name"`
+	Conditions []v1.NodeCondition `json:"conditions,omitempty"`
 }
 
 type resourceGetter interface {
 	GetPod(ctx context.Context, cluster, namespace, name string) (*v1.Pod, error)
 	GetEvents(cluster, namespace string, pod *v1.Pod) ([]v1.Event, error)
+	GetNode(ctx context.Context, cluster, name string) (*v1.Node, error)
 	PatchPod(ctx context.Context, cluster, namespace, name string, pt types.PatchType, data []byte) error
 }
 
@@ -73,6 +83,13 @@ func (rg k8sResourceGetter) GetPod(ctx context.Context, cluster, namespace, name
 	return rg.podClientSets[cluster].Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
+func (rg k8sResourceGetter) GetNode(ctx context.Context, cluster, name string) (*v1.Node, error) {
+	if _, ok := rg.podClientSets[cluster]; !ok {
+		return nil, fmt.Errorf("couldn't find cluster %q", cluster)
+	}
+	return rg.podClientSets[cluster].Nodes().Get(ctx, name, metav1.GetOptions{})
+}
+
 func (rg k8sResourceGetter) PatchPod(ctx context.Context, cluster, namespace, name string, pt types.PatchType, data []byte) error {
 	if _, ok := rg.podClientSets[cluster]; !ok {
 		return fmt.Errorf("couldn't find cluster %q", cluster)
@@ -127,7 +144,7 @@ func (gr *gcsK8sReporter) report(ctx context.Context, log *logrus.Entry, pj *pro
 }
 
 func (gr *gcsK8sReporter) addFinalizer(ctx context.Context, pj *prowv1.ProwJob) error {
-	pod, err := gr.rg.GetPod(ctx, pj.Spec.Cluster, gr.cfg().PodNamespace, pj.Name)
+	pod, err := gr.rg.GetPod(ctx, pj.ClusterAlias(), gr.cfg().PodNamespace, pj.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get pod %s: %w", pj.Name, err)
 	}
@@ -149,7 +166,7 @@ func (gr *gcsK8sReporter) addFinalizer(ctx context.Context, pj *prowv1.ProwJob)
 		return fmt.Errorf("failed to construct patch: %w", err)
 	}
 
-	if err := gr.rg.PatchPod(ctx, pj.Spec.Cluster, pod.Namespace, pod.Name, patch.Type(), patchData); err != nil {
+	if err := gr.rg.PatchPod(ctx, pj.ClusterAlias(), pod.Namespace, pod.Name, patch.Type(), patchData); err != nil {
 		return fmt.Errorf("failed to patch pod: %w", err)
 	}
 
@@ -162,7 +179,7 @@ func (gr *gcsK8sReporter) reportPodInfo(ctx context.Context, log *logrus.Entry,
 		return errors.New("cannot report incomplete jobs")
 	}
 
-	pod, err := gr.rg.GetPod(ctx, pj.Spec.Cluster, gr.cfg().PodNamespace, pj.Name)
+	pod, err := gr.rg.GetPod(ctx, pj.ClusterAlias(), gr.cfg().PodNamespace, pj.Name)
 	if err != nil {
 		// If we return an error we will be retried ~indefinitely. Given that permanent errors
 		// are expected (pods will be garbage collected), this isn't useful. Instead, just
@@ -173,7 +190,7 @@ func (gr *gcsK8sReporter) reportPodInfo(ctx context.Context, log *logrus.Entry,
 
 	var events []v1.Event
 	if pod != nil {
-		events, err = gr.rg.GetEvents(pj.Spec.Cluster, gr.cfg().PodNamespace, pod)
+		events, err = gr.rg.GetEvents(pj.ClusterAlias(), gr.cfg().PodNamespace, pod)
 		if err != nil {
 			log.WithError(err).Info("Couldn't fetch events for pod")
 		}
@@ -188,6 +205,16 @@ func (gr *gcsK8sReporter) reportPodInfo(ctx context.Context, log *logrus.Entry,
 		Pod:    pod,
 		Events: events,
 	}
+	// Node conditions such as memory or disk pressure often explain failures,
+	// but are gone by the time anyone looks into them.
+	if pod != nil && pod.Spec.NodeName != "" && failed(pj) {
+		node, err := gr.rg.GetNode(ctx, pj.ClusterAlias(), pod.Spec.NodeName)
+		if err != nil {
+			log.WithError(err).Info("Couldn't fetch node for pod")
+		} else {
+			report.Node = &NodeReport{Name: node.Name, Conditions: node.Status.Conditions}
+		}
+	}
 
 	output, err := json.MarshalIndent(report, "", "\t")
 	if err != nil {
@@ -213,13 +240,17 @@ func (gr *gcsK8sReporter) reportPodInfo(ctx context.Context, log *logrus.Entry,
 		return nil
 	}
 
-	if err := gr.removeFinalizer(ctx, pj.Spec.Cluster, pod); err != nil {
+	if err := gr.removeFinalizer(ctx, pj.ClusterAlias(), pod); err != nil {
 		return fmt.Errorf("failed to remove %s finalizer: %w", kubernetesreporterapi.FinalizerName, err)
 	}
 
 	return nil
 }
 
+func failed(pj *prowv1.ProwJob) bool {
+	return pj.Status.State == prowv1.FailureState || pj.Status.State == prowv1.ErrorState
+}
+
 func (gr *gcsK8sReporter) removeFinalizer(ctx context.Context, cluster string, pod *v1.Pod) error {
 	finalizers := sets.NewString(pod.Finalizers...)
 	if !finalizers.Has(kubernetesreporterapi.FinalizerName) {
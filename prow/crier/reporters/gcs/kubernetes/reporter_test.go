@@ -126,6 +126,7 @@ type testResourceGetter struct {
 	cluster   string
 	pod       *v1.Pod
 	events    []v1.Event
+	node      *v1.Node
 	patchData string
 	patchType types.PatchType
 }
@@ -162,6 +163,16 @@ func (rg testResourceGetter) GetEvents(cluster, namespace string, pod *v1.Pod) (
 	return rg.events, nil
 }
 
+func (rg testResourceGetter) GetNode(_ context.Context, cluster, name string) (*v1.Node, error) {
+	if rg.cluster != cluster {
+		return nil, fmt.Errorf("expected cluster %q but got cluster %q", rg.cluster, cluster)
+	}
+	if rg.node == nil || rg.node.Name != name {
+		return nil, fmt.Errorf("no such node %q", name)
+	}
+	return rg.node, nil
+}
+
 func (rg testResourceGetter) PatchPod(ctx context.Context, cluster, namespace, name string, pt types.PatchType, data []byte) error {
 	if _, err := rg.GetPod(ctx, cluster, namespace, name); err != nil {
 		return err
@@ -185,10 +196,12 @@ func TestReportPodInfo(t *testing.T) {
 		pjState                 prowv1.ProwJobState
 		pod                     *v1.Pod
 		events                  []v1.Event
+		node                    *v1.Node
 		dryRun                  bool
 		expectReport            bool
 		expectErr               bool
 		expectedPatch           string
+		expectedNode            *NodeReport
 		expectedReconcileResult *reconcile.Result
 	}{
 		{
@@ -223,6 +236,62 @@ func TestReportPodInfo(t *testing.T) {
 			},
 			expectReport: true,
 		},
+		{
+			name:       "failed prowjob captures conditions of the node",
+			pjName:     "ba123965-4fd4-421f-8509-7590c129ab69",
+			pjComplete: true,
+			pjState:    prowv1.FailureState,
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ba123965-4fd4-421f-8509-7590c129ab69",
+					Namespace: "test-pods",
+					Labels:    map[string]string{"created-by-prow": "true"},
+				},
+				Spec: v1.PodSpec{NodeName: "node-1"},
+			},
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Status: v1.NodeStatus{
+					Conditions: []v1.NodeCondition{{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue, Message: "kubelet has insufficient memory available"}},
+					Images:     []v1.ContainerImage{{Names: []string{"golang:1.15"}}},
+				},
+			},
+			expectReport: true,
+			expectedNode: &NodeReport{
+				Name:       "node-1",
+				Conditions: []v1.NodeCondition{{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue, Message: "kubelet has insufficient memory available"}},
+			},
+		},
+		{
+			name:       "successful prowjob does not capture the node",
+			pjName:     "ba123965-4fd4-421f-8509-7590c129ab69",
+			pjComplete: true,
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ba123965-4fd4-421f-8509-7590c129ab69",
+					Namespace: "test-pods",
+					Labels:    map[string]string{"created-by-prow": "true"},
+				},
+				Spec: v1.PodSpec{NodeName: "node-1"},
+			},
+			node:         &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			expectReport: true,
+		},
+		{
+			name:       "failed prowjob is reported when its node is gone",
+			pjName:     "ba123965-4fd4-421f-8509-7590c129ab69",
+			pjComplete: true,
+			pjState:    prowv1.ErrorState,
+			pod: &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "ba123965-4fd4-421f-8509-7590c129ab69",
+					Namespace: "test-pods",
+					Labels:    map[string]string{"created-by-prow": "true"},
+				},
+				Spec: v1.PodSpec{NodeName: "node-1"},
+			},
+			expectReport: true,
+		},
 		{
 			name:         "prowjob with no pod reports nothing but does not error",
 			pjName:       "ba123965-4fd4-421f-8509-7590c129ab69",
@@ -370,6 +439,7 @@ func TestReportPodInfo(t *testing.T) {
 				cluster:   "the-build-cluster",
 				pod:       tc.pod,
 				events:    tc.events,
+				node:      tc.node,
 				patchData: tc.expectedPatch,
 				patchType: types.MergePatchType,
 			}
@@ -409,6 +479,9 @@ func TestReportPodInfo(t *testing.T) {
 			if !cmp.Equal(result.Events, tc.events) {
 				t.Errorf("Got mismatching events:\n%s", cmp.Diff(tc.events, result.Events))
 			}
+			if !cmp.Equal(result.Node, tc.expectedNode) {
+				t.Errorf("Got mismatching node:\n%s", cmp.Diff(tc.expectedNode, result.Node))
+			}
 		})
 	}
 }
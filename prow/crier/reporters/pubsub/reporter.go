@@ -42,6 +42,9 @@ const (
 	PubSubTopicLabel = "prow.k8s.io/pubsub.topic"
 	// PubSubRunIDLabel annotation
 	PubSubRunIDLabel = "prow.k8s.io/pubsub.runID"
+	// PubSubCorrelationIDLabel annotation, set by sub from the message that
+	// triggered the prowjob and echoed in its reports.
+	PubSubCorrelationIDLabel = "prow.k8s.io/pubsub.correlationID"
 )
 
 // ReportMessage is a message structure used to pass a prowjob status to Pub/Sub topic.s
@@ -55,6 +58,8 @@ type ReportMessage struct {
 	Refs    []prowapi.Refs       `json:"refs,omitempty"`
 	JobType prowapi.ProwJobType  `json:"job_type"`
 	JobName string               `json:"job_name"`
+	// CorrelationID identifies the Pub/Sub message that triggered the prowjob.
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
 // Client is a reporter client fed to crier controller
@@ -132,7 +137,7 @@ func (c *Client) Report(ctx context.Context, _ *logrus.Entry, pj *prowapi.ProwJo
 }
 
 func (c *Client) generateMessageFromPJ(pj *prowapi.ProwJob) *ReportMessage {
-	pubSubMap := findLabels(pj, PubSubProjectLabel, PubSubTopicLabel, PubSubRunIDLabel)
+	pubSubMap := findLabels(pj, PubSubProjectLabel, PubSubTopicLabel, PubSubRunIDLabel, PubSubCorrelationIDLabel)
 	var refs []prowapi.Refs
 	if pj.Spec.Refs != nil {
 		refs = append(refs, *pj.Spec.Refs)
@@ -176,5 +181,7 @@ func (c *Client) generateMessageFromPJ(pj *prowapi.ProwJob) *ReportMessage {
 		Refs:    refs,
 		JobType: pj.Spec.Type,
 		JobName: pj.Spec.Job,
+
+		CorrelationID: pubSubMap[PubSubCorrelationIDLabel],
 	}
 }
@@ -295,6 +295,28 @@ func TestGenerateMessageFromPJ(t *testing.T) {
 				GCSPath: "gs://test1",
 			},
 		},
+		{
+			name: "Prowjob with a correlation ID annotation should echo it",
+			pj: &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test1",
+					Annotations: map[string]string{
+						PubSubProjectLabel:       testPubSubProjectName,
+						PubSubTopicLabel:         testPubSubTopicName,
+						PubSubCorrelationIDLabel: "message-id",
+					},
+				},
+				Status: prowapi.ProwJobStatus{
+					State: prowapi.PendingState,
+				},
+			},
+			expectedMessage: &ReportMessage{
+				Project:       testPubSubProjectName,
+				Topic:         testPubSubTopicName,
+				Status:        prowapi.PendingState,
+				CorrelationID: "message-id",
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -35,6 +35,7 @@ const reporterName = "slackreporter"
 
 type slackClient interface {
 	WriteMessage(text, channel string) error
+	WriteMessageWithBlocks(text, channel string, blocks []slackclient.Block) error
 }
 
 type slackReporter struct {
@@ -95,13 +96,27 @@ func (sr *slackReporter) report(log *logrus.Entry, pj *v1.ProwJob) error {
 		log.WithField("messagetext", b.String()).Debug("Skipping reporting because dry-run is enabled")
 		return nil
 	}
-	if err := sr.client.WriteMessage(b.String(), channel); err != nil {
+	if err := sr.writeMessage(b.String(), channel, prowCfg.RerunButton, pj); err != nil {
 		log.WithError(err).Error("failed to write Slack message")
 		return fmt.Errorf("failed to write Slack message: %v", err)
 	}
 	return nil
 }
 
+// writeMessage posts the report. Reports of jobs with logs get buttons to view
+// the logs and, if enabled, to rerun the job through the slack-bot.
+func (sr *slackReporter) writeMessage(text, channel string, rerunButton bool, pj *v1.ProwJob) error {
+	if pj.Status.URL == "" {
+		return sr.client.WriteMessage(text, channel)
+	}
+	buttons := []slackclient.Element{slackclient.Button("View logs", slackclient.ViewLogsActionID, "", pj.Status.URL)}
+	if rerunButton {
+		buttons = append(buttons, slackclient.Button("Rerun", slackclient.RerunActionID, pj.Name, ""))
+	}
+	blocks := []slackclient.Block{slackclient.SectionBlock(text), slackclient.ActionsBlock(buttons...)}
+	return sr.client.WriteMessageWithBlocks(text, channel, blocks)
+}
+
 func (sr *slackReporter) GetName() string {
 	return reporterName
 }
@@ -20,10 +20,13 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	slackclient "k8s.io/test-infra/prow/slack"
 )
 
 func TestShouldReport(t *testing.T) {
@@ -477,6 +480,7 @@ func TestShouldReportDefaultsToExtraRefs(t *testing.T) {
 
 type fakeSlackClient struct {
 	messages map[string]string
+	blocks   map[string][]slackclient.Block
 }
 
 func (fsc *fakeSlackClient) WriteMessage(text, channel string) error {
@@ -487,6 +491,14 @@ func (fsc *fakeSlackClient) WriteMessage(text, channel string) error {
 	return nil
 }
 
+func (fsc *fakeSlackClient) WriteMessageWithBlocks(text, channel string, blocks []slackclient.Block) error {
+	if fsc.blocks == nil {
+		fsc.blocks = map[string][]slackclient.Block{}
+	}
+	fsc.blocks[channel] = blocks
+	return fsc.WriteMessage(text, channel)
+}
+
 var _ slackClient = &fakeSlackClient{}
 
 func TestReportDefaultsToExtraRefs(t *testing.T) {
@@ -521,3 +533,64 @@ func TestReportDefaultsToExtraRefs(t *testing.T) {
 		t.Errorf("expected the channel 'emergency' to contain message 'there you go' but wasn't the case, all messages: %v", sr.client.(*fakeSlackClient).messages)
 	}
 }
+
+func TestReportButtons(t *testing.T) {
+	testCases := []struct {
+		name           string
+		url            string
+		rerunButton    bool
+		expectedBlocks []slackclient.Block
+	}{
+		{
+			name: "job without logs gets no buttons",
+		},
+		{
+			name: "job with logs gets a view logs button",
+			url:  "https://prow.example.com/view/job",
+			expectedBlocks: []slackclient.Block{
+				slackclient.SectionBlock("Job ci-job ended"),
+				slackclient.ActionsBlock(slackclient.Button("View logs", slackclient.ViewLogsActionID, "", "https://prow.example.com/view/job")),
+			},
+		},
+		{
+			name:        "rerun button is added when enabled",
+			url:         "https://prow.example.com/view/job",
+			rerunButton: true,
+			expectedBlocks: []slackclient.Block{
+				slackclient.SectionBlock("Job ci-job ended"),
+				slackclient.ActionsBlock(
+					slackclient.Button("View logs", slackclient.ViewLogsActionID, "", "https://prow.example.com/view/job"),
+					slackclient.Button("Rerun", slackclient.RerunActionID, "job-name", ""),
+				),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := slackclient.NewFakeServer()
+			defer server.Close()
+			sr := slackReporter{
+				config: func(*v1.Refs) config.SlackReporter {
+					return config.SlackReporter{
+						Channel:        "prow",
+						ReportTemplate: "Job {{.Spec.Job}} ended",
+						RerunButton:    tc.rerunButton,
+					}
+				},
+				client: server.Client(),
+			}
+			job := &v1.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "job-name"},
+				Spec:       v1.ProwJobSpec{Type: v1.PeriodicJob, Job: "ci-job"},
+				Status:     v1.ProwJobStatus{State: v1.FailureState, URL: tc.url},
+			}
+			if _, _, err := sr.Report(context.Background(), logrus.NewEntry(logrus.StandardLogger()), job); err != nil {
+				t.Fatalf("reporting failed: %v", err)
+			}
+			expected := []slackclient.FakeMessage{{Channel: "prow", Text: "Job ci-job ended", Blocks: tc.expectedBlocks}}
+			if diff := cmp.Diff(expected, server.Messages()); diff != "" {
+				t.Errorf("messages differ from expected: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AuditEvent records who asked for a privileged action and whether it
+// was allowed.
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user,omitempty"`
+	Groups []string  `json:"groups,omitempty"`
+	// Action is what was asked for, like rerun, abort or pin.
+	Action string `json:"action"`
+	// Target is what the action applies to, like the name of a ProwJob.
+	Target  string `json:"target"`
+	Scope   Scope  `json:"scope"`
+	Allowed bool   `json:"allowed"`
+	// Error explains why the action could not be authorized or done.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditLog writes audit events as JSON lines.
+type AuditLog struct {
+	lock sync.Mutex
+	out  io.Writer
+	now  func() time.Time
+}
+
+// NewAuditLog returns an audit log that writes to out, or to the log of
+// the component if out is nil.
+func NewAuditLog(out io.Writer) *AuditLog {
+	return &AuditLog{out: out, now: time.Now}
+}
+
+// Record writes the event to the audit log. Failing to write it is logged
+// but does not fail the action.
+func (a *AuditLog) Record(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = a.now()
+	}
+	if a.out == nil {
+		logrus.WithFields(logrus.Fields{
+			"audit":   true,
+			"user":    event.User,
+			"groups":  event.Groups,
+			"action":  event.Action,
+			"target":  event.Target,
+			"scope":   event.Scope,
+			"allowed": event.Allowed,
+			"error":   event.Error,
+		}).Info("Privileged action requested.")
+		return
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encode audit event.")
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, err := a.out.Write(append(raw, '\n')); err != nil {
+		logrus.WithError(err).WithField("event", string(raw)).Error("Failed to write audit event.")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRecord(t *testing.T) {
+	var out bytes.Buffer
+	log := NewAuditLog(&out)
+	log.now = func() time.Time { return time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	log.Record(AuditEvent{
+		User:    "alice@example.com",
+		Groups:  []string{"eng@example.com"},
+		Action:  "abort",
+		Target:  "0a1b2c",
+		Scope:   Scope{Org: "kubernetes", Repo: "test-infra", Job: "pull-test-infra-bazel"},
+		Allowed: true,
+	})
+	log.Record(AuditEvent{
+		Action: "rerun",
+		Target: "3d4e5f",
+		Scope:  Scope{Job: "ci-cleanup"},
+		Error:  "not logged in",
+	})
+
+	expected := `{"time":"2021-03-01T12:00:00Z","user":"alice@example.com","groups":["eng@example.com"],"action":"abort","target":"0a1b2c","scope":{"org":"kubernetes","repo":"test-infra","job":"pull-test-infra-bazel"},"allowed":true}
+{"time":"2021-03-01T12:00:00Z","action":"rerun","target":"3d4e5f","scope":{"job":"ci-cleanup"},"allowed":false,"error":"not logged in"}
+`
+	if actual := out.String(); actual != expected {
+		t.Errorf("expected audit log\n%s\ngot\n%s", expected, actual)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbac decides what deck users may do based on a policy that
+// binds users and groups to roles, scoped to orgs, repos and jobs.
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// Permission is something a role allows its members to do.
+type Permission string
+
+const (
+	// PermissionViewPrivateJobs allows seeing hidden jobs in deck.
+	PermissionViewPrivateJobs Permission = "view_private_jobs"
+	// PermissionRerun allows rerunning jobs.
+	PermissionRerun Permission = "rerun"
+	// PermissionAbort allows aborting jobs that are still running.
+	PermissionAbort Permission = "abort"
+	// PermissionPin allows pinning and unpinning runs to keep their
+	// artifacts.
+	PermissionPin Permission = "pin"
+	// PermissionAdministerTide allows blocking Tide from merging.
+	PermissionAdministerTide Permission = "administer_tide"
+)
+
+var knownPermissions = sets.NewString(
+	string(PermissionViewPrivateJobs),
+	string(PermissionRerun),
+	string(PermissionAbort),
+	string(PermissionPin),
+	string(PermissionAdministerTide),
+)
+
+// Identity is who sent a request.
+type Identity struct {
+	// User is the username from the OIDC provider or the GitHub login.
+	User string
+	// Groups are the groups the OIDC provider reported for the user.
+	Groups []string
+}
+
+// Scope limits a binding to an org, a repo or a job. Empty fields match
+// anything.
+type Scope struct {
+	Org  string `json:"org,omitempty"`
+	Repo string `json:"repo,omitempty"`
+	Job  string `json:"job,omitempty"`
+}
+
+// matches determines whether the binding scope includes the target.
+func (s Scope) matches(target Scope) bool {
+	return (s.Org == "" || s.Org == target.Org) &&
+		(s.Repo == "" || s.Repo == target.Repo) &&
+		(s.Job == "" || s.Job == target.Job)
+}
+
+// ScopeForJob returns the scope a job falls into.
+func ScopeForJob(pj *prowapi.ProwJob) Scope {
+	scope := Scope{Job: pj.Spec.Job}
+	if pj.Spec.Refs != nil {
+		scope.Org, scope.Repo = pj.Spec.Refs.Org, pj.Spec.Refs.Repo
+	} else if len(pj.Spec.ExtraRefs) > 0 {
+		scope.Org, scope.Repo = pj.Spec.ExtraRefs[0].Org, pj.Spec.ExtraRefs[0].Repo
+	}
+	return scope
+}
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Binding grants a role to users and groups, optionally only within
+// some scopes.
+type Binding struct {
+	Role   string   `json:"role"`
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Scopes limit where the role applies. The role applies everywhere
+	// when there are none.
+	Scopes []Scope `json:"scopes,omitempty"`
+}
+
+// Policy maps users and groups to permissions.
+type Policy struct {
+	Roles    []Role    `json:"roles"`
+	Bindings []Binding `json:"bindings"`
+}
+
+// LoadPolicy reads and validates the policy in the given file.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(raw, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Validate checks that roles only use known permissions and that bindings
+// refer to roles and grant them to someone.
+func (p *Policy) Validate() error {
+	roles := sets.NewString()
+	for _, role := range p.Roles {
+		if role.Name == "" {
+			return errors.New("roles must have a name")
+		}
+		if roles.Has(role.Name) {
+			return fmt.Errorf("role %q is defined more than once", role.Name)
+		}
+		roles.Insert(role.Name)
+		for _, permission := range role.Permissions {
+			if !knownPermissions.Has(string(permission)) {
+				return fmt.Errorf("role %q has unknown permission %q, expected one of %v", role.Name, permission, knownPermissions.List())
+			}
+		}
+	}
+	for i, binding := range p.Bindings {
+		if !roles.Has(binding.Role) {
+			return fmt.Errorf("binding %d refers to undefined role %q", i, binding.Role)
+		}
+		if len(binding.Users) == 0 && len(binding.Groups) == 0 {
+			return fmt.Errorf("binding %d of role %q has neither users nor groups", i, binding.Role)
+		}
+		for _, scope := range binding.Scopes {
+			if scope.Repo != "" && scope.Org == "" {
+				return fmt.Errorf("binding %d of role %q scopes repo %q without its org", i, binding.Role, scope.Repo)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed determines whether the identity has the permission in the
+// target scope.
+func (p *Policy) Allowed(identity Identity, permission Permission, target Scope) bool {
+	if identity.User == "" && len(identity.Groups) == 0 {
+		return false
+	}
+	grants := map[string]sets.String{}
+	for _, role := range p.Roles {
+		permissions := sets.NewString()
+		for _, permission := range role.Permissions {
+			permissions.Insert(string(permission))
+		}
+		grants[role.Name] = permissions
+	}
+	groups := sets.NewString(identity.Groups...)
+	for _, binding := range p.Bindings {
+		if !grants[binding.Role].Has(string(permission)) {
+			continue
+		}
+		if !(identity.User != "" && sets.NewString(binding.Users...).Has(identity.User)) && !groups.HasAny(binding.Groups...) {
+			continue
+		}
+		if len(binding.Scopes) == 0 {
+			return true
+		}
+		for _, scope := range binding.Scopes {
+			if scope.matches(target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Groups returns the groups the bindings grant roles to. Other groups of
+// an identity make no difference to what it is allowed.
+func (p *Policy) Groups() sets.String {
+	groups := sets.NewString()
+	for _, binding := range p.Bindings {
+		groups.Insert(binding.Groups...)
+	}
+	return groups
+}
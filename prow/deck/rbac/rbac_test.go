@@ -0,0 +1,254 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      string
+		expectedErr bool
+	}{
+		{
+			name: "valid policy",
+			policy: `roles:
+- name: developer
+  permissions: [rerun, abort]
+- name: admin
+  permissions: [view_private_jobs, rerun, abort, pin, administer_tide]
+bindings:
+- role: developer
+  groups: [eng@example.com]
+  scopes:
+  - org: kubernetes
+    repo: test-infra
+- role: admin
+  users: [alice@example.com]
+`,
+		},
+		{
+			name: "unknown permission",
+			policy: `roles:
+- name: developer
+  permissions: [merge]
+`,
+			expectedErr: true,
+		},
+		{
+			name: "duplicate role",
+			policy: `roles:
+- name: developer
+- name: developer
+`,
+			expectedErr: true,
+		},
+		{
+			name: "binding of undefined role",
+			policy: `bindings:
+- role: developer
+  groups: [eng@example.com]
+`,
+			expectedErr: true,
+		},
+		{
+			name: "binding without subjects",
+			policy: `roles:
+- name: developer
+bindings:
+- role: developer
+`,
+			expectedErr: true,
+		},
+		{
+			name: "repo scope without org",
+			policy: `roles:
+- name: developer
+bindings:
+- role: developer
+  groups: [eng@example.com]
+  scopes:
+  - repo: test-infra
+`,
+			expectedErr: true,
+		},
+		{
+			name: "unknown field",
+			policy: `roles:
+- name: developer
+  permission: [rerun]
+`,
+			expectedErr: true,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "rbac")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("policy-%d.yaml", i))
+			if err := ioutil.WriteFile(path, []byte(tc.policy), 0644); err != nil {
+				t.Fatalf("failed to write policy: %v", err)
+			}
+			_, err := LoadPolicy(path)
+			if err != nil && !tc.expectedErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil && tc.expectedErr {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	policy := &Policy{
+		Roles: []Role{
+			{Name: "developer", Permissions: []Permission{PermissionRerun, PermissionAbort}},
+			{Name: "viewer", Permissions: []Permission{PermissionViewPrivateJobs}},
+		},
+		Bindings: []Binding{
+			{
+				Role:   "developer",
+				Groups: []string{"eng@example.com"},
+				Scopes: []Scope{{Org: "kubernetes", Repo: "test-infra"}, {Org: "acme", Job: "ci-acme-e2e"}},
+			},
+			{Role: "viewer", Users: []string{"alice@example.com"}},
+		},
+	}
+	testInfra := Scope{Org: "kubernetes", Repo: "test-infra", Job: "pull-test-infra-bazel"}
+	testCases := []struct {
+		name       string
+		identity   Identity
+		permission Permission
+		target     Scope
+		expected   bool
+	}{
+		{
+			name:       "group member in scope",
+			identity:   Identity{User: "bob@example.com", Groups: []string{"eng@example.com"}},
+			permission: PermissionRerun,
+			target:     testInfra,
+			expected:   true,
+		},
+		{
+			name:       "group member outside of the repo",
+			identity:   Identity{User: "bob@example.com", Groups: []string{"eng@example.com"}},
+			permission: PermissionAbort,
+			target:     Scope{Org: "kubernetes", Repo: "kubernetes", Job: "pull-kubernetes-e2e"},
+		},
+		{
+			name:       "group member on the job of a scope",
+			identity:   Identity{User: "bob@example.com", Groups: []string{"eng@example.com"}},
+			permission: PermissionAbort,
+			target:     Scope{Org: "acme", Repo: "widgets", Job: "ci-acme-e2e"},
+			expected:   true,
+		},
+		{
+			name:       "group member without the permission",
+			identity:   Identity{User: "bob@example.com", Groups: []string{"eng@example.com"}},
+			permission: PermissionViewPrivateJobs,
+			target:     testInfra,
+		},
+		{
+			name:       "user bound everywhere",
+			identity:   Identity{User: "alice@example.com"},
+			permission: PermissionViewPrivateJobs,
+			target:     Scope{Job: "ci-private"},
+			expected:   true,
+		},
+		{
+			name:       "stranger",
+			identity:   Identity{User: "mallory@example.com", Groups: []string{"contractors@example.com"}},
+			permission: PermissionRerun,
+			target:     testInfra,
+		},
+		{
+			name:       "anonymous",
+			permission: PermissionViewPrivateJobs,
+			target:     testInfra,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := policy.Allowed(tc.identity, tc.permission, tc.target); actual != tc.expected {
+				t.Errorf("expected allowed to be %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScopeForJob(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     prowapi.ProwJobSpec
+		expected Scope
+	}{
+		{
+			name:     "presubmit",
+			spec:     prowapi.ProwJobSpec{Job: "pull-test-infra-bazel", Refs: &prowapi.Refs{Org: "kubernetes", Repo: "test-infra"}},
+			expected: Scope{Org: "kubernetes", Repo: "test-infra", Job: "pull-test-infra-bazel"},
+		},
+		{
+			name:     "periodic with extra refs",
+			spec:     prowapi.ProwJobSpec{Job: "ci-e2e", ExtraRefs: []prowapi.Refs{{Org: "kubernetes", Repo: "kubernetes"}}},
+			expected: Scope{Org: "kubernetes", Repo: "kubernetes", Job: "ci-e2e"},
+		},
+		{
+			name:     "periodic without refs",
+			spec:     prowapi.ProwJobSpec{Job: "ci-cleanup"},
+			expected: Scope{Job: "ci-cleanup"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := ScopeForJob(&prowapi.ProwJob{Spec: tc.spec}); actual != tc.expected {
+				t.Errorf("expected scope %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	policy := &Policy{
+		Roles: []Role{{Name: "developer", Permissions: []Permission{PermissionRerun}}},
+		Bindings: []Binding{
+			{Role: "developer", Groups: []string{"eng@example.com", "oncall@example.com"}},
+			{Role: "developer", Users: []string{"alice@example.com"}},
+			{Role: "developer", Groups: []string{"eng@example.com"}, Scopes: []Scope{{Org: "kubernetes"}}},
+		},
+	}
+	expected := sets.NewString("eng@example.com", "oncall@example.com")
+	if actual := policy.Groups(); !actual.Equal(expected) {
+		t.Errorf("expected groups %v, got %v", expected.List(), actual.List())
+	}
+}
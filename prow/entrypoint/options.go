@@ -20,9 +20,11 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"time"
 
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
+	"k8s.io/test-infra/prow/resourceusage"
 )
 
 // NewOptions returns an empty Options with no nil fields
@@ -60,15 +62,55 @@ type Options struct {
 	// Primarily useful in case a subsequent entrypoint will read this entrypoint's marker
 	AlwaysZero bool `json:"always_zero,omitempty"`
 
+	// ResourceSampleInterval determines how often the CPU and memory
+	// usage of the container is sampled while the process runs. The
+	// samples are written to ResourceUsageFile. Zero disables sampling.
+	ResourceSampleInterval time.Duration `json:"resource_sample_interval,omitempty"`
+	// Resources are the requests and limits of the container, recorded
+	// alongside the samples.
+	Resources *resourceusage.Resources `json:"resources,omitempty"`
+
+	// PodAnnotationsFile is where the downward API projects the annotations
+	// of the pod. When set, entrypoint terminates the process like on an
+	// interrupt once plank annotates the pod to abort it.
+	PodAnnotationsFile string `json:"pod_annotations_file,omitempty"`
+
+	// Steps are run one after the other instead of Args, within
+	// Timeout. Their results are written to StepsFile.
+	Steps []Step `json:"steps,omitempty"`
+
+	// cgroupRoot overrides where the cgroup of the container is read from.
+	cgroupRoot string
+	// abortPollInterval overrides how often PodAnnotationsFile is read.
+	abortPollInterval time.Duration
+
 	*wrapper.Options
 }
 
+// Step is a named process that entrypoint runs as part of a multi-step job.
+type Step struct {
+	Name string `json:"name"`
+	// Args is the process to run and its arguments.
+	Args []string `json:"args"`
+	// Timeout determines how long the step may run.
+	// Zero lets it run until the job times out.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// AlwaysRun runs the step even if an earlier step
+	// failed or the job timed out.
+	AlwaysRun bool `json:"always_run,omitempty"`
+}
+
 // Validate ensures that the set of options are
 // self-consistent and valid
 func (o *Options) Validate() error {
-	if len(o.Args) == 0 {
+	if len(o.Args) == 0 && len(o.Steps) == 0 {
 		return errors.New("no process to wrap specified")
 	}
+	for _, step := range o.Steps {
+		if len(step.Args) == 0 {
+			return fmt.Errorf("no process to run specified for step %q", step.Name)
+		}
+	}
 
 	return o.Options.Validate()
 }
@@ -49,6 +49,28 @@ func TestOptions_Validate(t *testing.T) {
 			},
 			expectedErr: true,
 		},
+		{
+			name: "steps instead of args",
+			input: Options{
+				Steps: []Step{{Name: "build", Args: []string{"make"}}},
+				Options: &wrapper.Options{
+					ProcessLog: "output.txt",
+					MarkerFile: "marker.txt",
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "step without args",
+			input: Options{
+				Steps: []Step{{Name: "build"}},
+				Options: &wrapper.Options{
+					ProcessLog: "output.txt",
+					MarkerFile: "marker.txt",
+				},
+			},
+			expectedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -18,6 +18,7 @@ package entrypoint
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -33,7 +34,9 @@ import (
 	"github.com/sirupsen/logrus"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
+	"k8s.io/test-infra/prow/resourceusage"
 )
 
 const (
@@ -53,6 +56,9 @@ const (
 	// PreviousErrorCode indicates a previous step failed so we
 	// did not run this step.
 	PreviousErrorCode = internalCode + AbortedErrorCode
+	// TimedOutErrorCode is what we write to the marker file to
+	// indicate that the process did not finish before the timeout.
+	TimedOutErrorCode = internalCode + 124
 
 	// DefaultTimeout is the default timeout for the test
 	// process before SIGINT is sent
@@ -61,6 +67,10 @@ const (
 	// DefaultGracePeriod is the default timeout for the test
 	// process after SIGINT is sent before SIGKILL is sent
 	DefaultGracePeriod = 15 * time.Second
+
+	// defaultAbortPollInterval is how often the pod annotations
+	// are checked for an abort request
+	defaultAbortPollInterval = time.Second
 )
 
 var (
@@ -134,10 +144,40 @@ func (o Options) ExecuteProcess() (int, error) {
 		}
 	}
 
-	executable := o.Args[0]
+	stopProfiling := o.startProfiling()
+	defer stopProfiling()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	abortRequested := o.watchForAbort(ctx)
+	if len(o.Steps) > 0 {
+		return o.executeSteps(output, processLogFile, interrupt, abortRequested)
+	}
+	outcome := o.executeCommand(o.Args, optionOrDefault(o.Timeout, DefaultTimeout), optionOrDefault(o.GracePeriod, DefaultGracePeriod), output, processLogFile, interrupt, abortRequested)
+	return outcome.returnCode, outcome.err
+}
+
+// outcome is how a wrapped process ended.
+type outcome struct {
+	returnCode int
+	err        error
+	timedOut   bool
+	// aborted is set when entrypoint was interrupted
+	// or asked to abort the job.
+	aborted bool
+	// abortedAt is when the interrupt or the abort request
+	// was received.
+	abortedAt time.Time
+}
+
+// executeCommand runs the process until it exits or times out, or
+// entrypoint is interrupted or asked to abort the job. The process is
+// then interrupted and killed if it did not exit after the grace period.
+func (o Options) executeCommand(args []string, timeout, gracePeriod time.Duration, output, processLogFile io.Writer, interrupt <-chan os.Signal, abortRequested <-chan *downwardapi.AbortRequest) outcome {
+	executable := args[0]
 	var arguments []string
-	if len(o.Args) > 1 {
-		arguments = o.Args[1:]
+	if len(args) > 1 {
+		arguments = args[1:]
 	}
 	command := exec.Command(executable, arguments...)
 	command.Stderr = output
@@ -147,13 +187,12 @@ func (o Options) ExecuteProcess() (int, error) {
 		if _, err := processLogFile.Write([]byte(errs[0].Error())); err != nil {
 			errs = append(errs, err)
 		}
-		return InternalErrorCode, utilerrors.NewAggregate(errs)
+		return outcome{returnCode: InternalErrorCode, err: utilerrors.NewAggregate(errs)}
 	}
 
-	timeout := optionOrDefault(o.Timeout, DefaultTimeout)
-	gracePeriod := optionOrDefault(o.GracePeriod, DefaultGracePeriod)
 	var commandErr error
 	cancelled, aborted := false, false
+	var abortedAt time.Time
 	done := make(chan error)
 	go func() {
 		done <- command.Wait()
@@ -169,7 +208,14 @@ func (o Options) ExecuteProcess() (int, error) {
 		logrus.Errorf("Entrypoint received interrupt: %v", s)
 		cancelled = true
 		aborted = true
+		abortedAt = time.Now()
 		gracefullyTerminate(command, done, gracePeriod, &s)
+	case request := <-abortRequested:
+		logrus.Errorf("Entrypoint was asked to abort the job (%s): %s", request.Result, request.Reason)
+		cancelled = true
+		aborted = true
+		abortedAt = time.Now()
+		gracefullyTerminate(command, done, gracePeriod, nil)
 	}
 
 	var returnCode int
@@ -179,7 +225,7 @@ func (o Options) ExecuteProcess() (int, error) {
 			returnCode = AbortedErrorCode
 		} else {
 			commandErr = errTimedOut
-			returnCode = InternalErrorCode
+			returnCode = TimedOutErrorCode
 		}
 	} else {
 		if status, ok := command.ProcessState.Sys().(syscall.WaitStatus); ok {
@@ -194,7 +240,158 @@ func (o Options) ExecuteProcess() (int, error) {
 			commandErr = fmt.Errorf("wrapped process failed: %v", commandErr)
 		}
 	}
-	return returnCode, commandErr
+	return outcome{returnCode: returnCode, err: commandErr, timedOut: cancelled && !aborted, aborted: aborted, abortedAt: abortedAt}
+}
+
+// executeSteps runs the steps one after the other and writes their results
+// to the steps file. Once a step failed or the job timed out, only the steps
+// that always run are run. Once entrypoint was interrupted or asked to abort
+// the job, the interrupted step and the steps that always run share the grace
+// period from that moment on, as the pod is deleted soon after. The first
+// step that failed determines the return code.
+func (o Options) executeSteps(output, processLogFile io.Writer, interrupt <-chan os.Signal, abortRequested <-chan *downwardapi.AbortRequest) (int, error) {
+	deadline := time.Now().Add(optionOrDefault(o.Timeout, DefaultTimeout))
+	gracePeriod := optionOrDefault(o.GracePeriod, DefaultGracePeriod)
+	var results []wrapper.StepResult
+	returnCode, failed, aborted := 0, false, false
+	var abortDeadline time.Time
+	var err error
+	for _, step := range o.Steps {
+		result := wrapper.StepResult{Name: step.Name, Result: wrapper.StepSkipped}
+		remaining := time.Until(deadline)
+		skip := (failed || remaining <= 0) && !step.AlwaysRun
+		if aborted {
+			// The pod is about to be deleted, leave teardown steps what is left of the grace period.
+			remaining = time.Until(abortDeadline)
+			skip = !step.AlwaysRun || remaining <= 0
+		}
+		if skip {
+			logrus.Infof("Skipping step %s", step.Name)
+			results = append(results, result)
+			continue
+		}
+
+		timeout, stepGracePeriod := remaining, gracePeriod
+		switch {
+		case remaining <= 0:
+			// The job timed out, leave teardown steps their own timeout or the grace period.
+			timeout = optionOrDefault(step.Timeout, gracePeriod)
+		case step.Timeout > 0 && step.Timeout < remaining:
+			timeout = step.Timeout
+		}
+		if aborted && remaining-timeout < stepGracePeriod {
+			// The step must be killed before the grace period of the abort ends.
+			stepGracePeriod = remaining - timeout
+		}
+		logrus.Infof("Running step %s", step.Name)
+		start := time.Now()
+		outcome := o.executeCommand(step.Args, timeout, stepGracePeriod, output, processLogFile, interrupt, abortRequested)
+		result.Started = start.Unix()
+		result.Duration = time.Since(start).Seconds()
+		result.ExitCode = outcome.returnCode
+		switch {
+		case outcome.aborted:
+			result.Result = wrapper.StepAborted
+			if !aborted {
+				aborted = true
+				abortDeadline = outcome.abortedAt.Add(gracePeriod)
+			}
+		case outcome.timedOut:
+			result.Result = wrapper.StepTimedOut
+		case outcome.returnCode != 0:
+			result.Result = wrapper.StepFailed
+		default:
+			result.Result = wrapper.StepSucceeded
+		}
+		logrus.Infof("Step %s finished with %s after %s", step.Name, result.Result, result.Elapsed().Round(time.Second))
+		results = append(results, result)
+		if outcome.returnCode != 0 && !failed {
+			failed = true
+			returnCode, err = outcome.returnCode, fmt.Errorf("step %s: %w", step.Name, outcome.err)
+		}
+	}
+	o.writeStepResults(results)
+	return returnCode, err
+}
+
+// writeStepResults writes the results of the steps for sidecar to record them.
+func (o Options) writeStepResults(results []wrapper.StepResult) {
+	if o.StepsFile == "" {
+		return
+	}
+	raw, err := json.Marshal(results)
+	if err != nil {
+		logrus.WithError(err).Error("Could not marshal step results")
+		return
+	}
+	if err := ioutil.WriteFile(o.StepsFile, raw, 0644); err != nil {
+		logrus.WithError(err).Error("Could not write steps file")
+	}
+}
+
+// watchForAbort polls the pod annotations until plank asks the pod to
+// abort and sends the request on the returned channel. Nothing is ever
+// sent unless graceful aborts are enabled.
+func (o Options) watchForAbort(ctx context.Context) <-chan *downwardapi.AbortRequest {
+	requests := make(chan *downwardapi.AbortRequest, 1)
+	if o.PodAnnotationsFile == "" {
+		return requests
+	}
+	ticker := time.NewTicker(optionOrDefault(o.abortPollInterval, defaultAbortPollInterval))
+	go func() {
+		defer ticker.Stop()
+		for {
+			request, err := downwardapi.ReadAbortRequest(o.PodAnnotationsFile)
+			if err != nil {
+				logrus.WithError(err).Warn("Could not read the pod annotations")
+			} else if request != nil {
+				requests <- request
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return requests
+}
+
+// startProfiling samples the resource usage of the container until
+// the returned function is called, which writes the samples to the
+// resource usage file. It does nothing unless profiling is enabled.
+func (o Options) startProfiling() func() {
+	if o.ResourceSampleInterval <= 0 || o.ResourceUsageFile == "" {
+		return func() {}
+	}
+	root := o.cgroupRoot
+	if root == "" {
+		root = resourceusage.DefaultCgroupRoot
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	samples := make(chan []resourceusage.Sample)
+	go func() {
+		samples <- resourceusage.Record(ctx, resourceusage.CgroupSampler{Root: root}, o.ResourceSampleInterval)
+	}()
+	return func() {
+		cancel()
+		profile := resourceusage.Profile{
+			Container: o.ContainerName,
+			Samples:   <-samples,
+		}
+		if o.Resources != nil {
+			profile.Resources = *o.Resources
+		}
+		raw, err := json.Marshal(profile)
+		if err != nil {
+			logrus.WithError(err).Error("Could not marshal resource usage")
+			return
+		}
+		if err := ioutil.WriteFile(o.ResourceUsageFile, raw, 0644); err != nil {
+			logrus.WithError(err).Error("Could not write resource usage file")
+		}
+	}
 }
 
 func (o *Options) Mark(exitCode int) error {
@@ -17,6 +17,7 @@ limitations under the License.
 package entrypoint
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
+	"k8s.io/test-infra/prow/resourceusage"
 )
 
 func TestOptions_Run(t *testing.T) {
@@ -75,8 +77,8 @@ func TestOptions_Run(t *testing.T) {
 			timeout:        1 * time.Second,
 			gracePeriod:    1 * time.Second,
 			expectedLog:    "level=error msg=\"Process did not finish before 1s timeout\"\nlevel=error msg=\"Process gracefully exited before 1s grace period\"\n",
-			expectedMarker: strconv.Itoa(InternalErrorCode),
-			expectedCode:   InternalErrorCode,
+			expectedMarker: strconv.Itoa(TimedOutErrorCode),
+			expectedCode:   TimedOutErrorCode,
 		},
 		{
 			name:           "command times out and ignores interrupt",
@@ -84,8 +86,8 @@ func TestOptions_Run(t *testing.T) {
 			timeout:        1 * time.Second,
 			gracePeriod:    1 * time.Second,
 			expectedLog:    "level=error msg=\"Process did not finish before 1s timeout\"\nlevel=error msg=\"Process did not exit before 1s grace period\"\n",
-			expectedMarker: strconv.Itoa(InternalErrorCode),
-			expectedCode:   InternalErrorCode,
+			expectedMarker: strconv.Itoa(TimedOutErrorCode),
+			expectedCode:   TimedOutErrorCode,
 		},
 		{
 			// Ensure that environment variables get passed through
@@ -195,6 +197,259 @@ func TestOptions_Run(t *testing.T) {
 	}
 }
 
+func TestOptions_RunProfilesResourceUsage(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cgroupRoot := path.Join(tmpDir, "cgroup")
+	for name, content := range map[string]string{
+		"cgroup.controllers": "cpu memory",
+		"cpu.stat":           "usage_usec 1000",
+		"memory.current":     "4096",
+		"memory.stat":        "inactive_file 1024",
+	} {
+		if err := os.MkdirAll(cgroupRoot, 0755); err != nil {
+			t.Fatalf("could not create cgroup root: %v", err)
+		}
+		if err := ioutil.WriteFile(path.Join(cgroupRoot, name), []byte(content), 0644); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	options := Options{
+		ResourceSampleInterval: 10 * time.Millisecond,
+		Resources:              &resourceusage.Resources{Requests: resourceusage.Quantities{CPU: 1}},
+		cgroupRoot:             cgroupRoot,
+		Options: &wrapper.Options{
+			Args:              []string{"sleep", "0.2"},
+			ContainerName:     "test",
+			ProcessLog:        path.Join(tmpDir, "process-log.txt"),
+			MarkerFile:        path.Join(tmpDir, "marker-file.txt"),
+			ResourceUsageFile: path.Join(tmpDir, "resource-usage.json"),
+		},
+	}
+	if code := options.Run(); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	raw, err := ioutil.ReadFile(options.ResourceUsageFile)
+	if err != nil {
+		t.Fatalf("could not read resource usage file: %v", err)
+	}
+	var profile resourceusage.Profile
+	if err := json.Unmarshal(raw, &profile); err != nil {
+		t.Fatalf("could not unmarshal resource usage: %v", err)
+	}
+	if profile.Container != "test" || profile.Requests.CPU != 1 {
+		t.Errorf("expected profile of container test requesting one core, got %+v", profile)
+	}
+	if len(profile.Samples) == 0 {
+		t.Fatal("expected samples to be recorded")
+	}
+	if memory := profile.Samples[0].Memory; memory != 3072 {
+		t.Errorf("expected a working set of 3072 bytes, got %d", memory)
+	}
+}
+
+func TestOptions_RunAbortsWhenAnnotated(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "abort")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	annotations := path.Join(tmpDir, "annotations")
+	if err := ioutil.WriteFile(annotations, []byte("prow.k8s.io/job=\"ci-job\"\n"), 0644); err != nil {
+		t.Fatalf("could not write annotations: %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		content := "prow.k8s.io/abort=\"aborted\"\nprow.k8s.io/abort-reason=\"superseded\"\nprow.k8s.io/job=\"ci-job\"\n"
+		if err := ioutil.WriteFile(annotations, []byte(content), 0644); err != nil {
+			t.Errorf("could not write annotations: %v", err)
+		}
+	}()
+
+	options := Options{
+		Timeout:            10 * time.Second,
+		GracePeriod:        time.Second,
+		PodAnnotationsFile: annotations,
+		abortPollInterval:  10 * time.Millisecond,
+		Options: &wrapper.Options{
+			Args:       []string{"sleep", "10"},
+			ProcessLog: path.Join(tmpDir, "process-log.txt"),
+			MarkerFile: path.Join(tmpDir, "marker-file.txt"),
+		},
+	}
+	if code := options.Run(); code != AbortedErrorCode {
+		t.Errorf("expected exit code %d, got %d", AbortedErrorCode, code)
+	}
+	compareFileContents("abort", options.ProcessLog, "level=error msg=\"Entrypoint was asked to abort the job (aborted): superseded\"\nlevel=error msg=\"Process gracefully exited before 1s grace period\"\n", t)
+	compareFileContents("abort", options.MarkerFile, strconv.Itoa(AbortedErrorCode), t)
+}
+
+func TestOptions_RunStepsRunsTeardownAfterAbort(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "abort-steps")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	annotations := path.Join(tmpDir, "annotations")
+	if err := ioutil.WriteFile(annotations, []byte("prow.k8s.io/job=\"ci-job\"\n"), 0644); err != nil {
+		t.Fatalf("could not write annotations: %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		content := "prow.k8s.io/abort=\"aborted\"\nprow.k8s.io/abort-reason=\"superseded\"\nprow.k8s.io/job=\"ci-job\"\n"
+		if err := ioutil.WriteFile(annotations, []byte(content), 0644); err != nil {
+			t.Errorf("could not write annotations: %v", err)
+		}
+	}()
+
+	options := Options{
+		Timeout:            10 * time.Second,
+		GracePeriod:        time.Second,
+		PodAnnotationsFile: annotations,
+		abortPollInterval:  10 * time.Millisecond,
+		Steps: []Step{
+			{Name: "test", Args: []string{"sleep", "10"}},
+			{Name: "report", Args: []string{"sh", "-c", "exit 0"}},
+			{Name: "teardown", Args: []string{"sh", "-c", "exit 0"}, AlwaysRun: true},
+			{Name: "cleanup", Args: []string{"sh", "-c", "trap '' INT; exec sleep 10"}, AlwaysRun: true},
+		},
+		Options: &wrapper.Options{
+			ProcessLog: path.Join(tmpDir, "process-log.txt"),
+			MarkerFile: path.Join(tmpDir, "marker-file.txt"),
+			StepsFile:  path.Join(tmpDir, "steps.json"),
+		},
+	}
+	start := time.Now()
+	if code := options.Run(); code != AbortedErrorCode {
+		t.Errorf("expected exit code %d, got %d", AbortedErrorCode, code)
+	}
+	// The aborted step and the teardown share the grace period that starts with the abort.
+	if elapsed, limit := time.Since(start), 200*time.Millisecond+options.GracePeriod+500*time.Millisecond; elapsed > limit {
+		t.Errorf("expected the steps to finish within %s after the abort, took %s", options.GracePeriod, elapsed)
+	}
+
+	raw, err := ioutil.ReadFile(options.StepsFile)
+	if err != nil {
+		t.Fatalf("could not read steps file: %v", err)
+	}
+	var results []wrapper.StepResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		t.Fatalf("could not unmarshal step results: %v", err)
+	}
+	// The last step does not finish within the grace period.
+	expected := []string{wrapper.StepAborted, wrapper.StepSkipped, wrapper.StepSucceeded, wrapper.StepTimedOut}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d step results, got %+v", len(expected), results)
+	}
+	for i, result := range results {
+		if result.Result != expected[i] {
+			t.Errorf("expected step %s to end with %s, got %+v", result.Name, expected[i], result)
+		}
+	}
+}
+
+func TestOptions_RunSteps(t *testing.T) {
+	testCases := []struct {
+		name            string
+		timeout         time.Duration
+		steps           []Step
+		expectedResults []string
+		expectedCode    int
+	}{
+		{
+			name: "all steps pass",
+			steps: []Step{
+				{Name: "build", Args: []string{"sh", "-c", "exit 0"}},
+				{Name: "test", Args: []string{"sh", "-c", "exit 0"}},
+			},
+			expectedResults: []string{wrapper.StepSucceeded, wrapper.StepSucceeded},
+		},
+		{
+			name: "failed step skips the next steps but teardown",
+			steps: []Step{
+				{Name: "build", Args: []string{"sh", "-c", "exit 3"}},
+				{Name: "test", Args: []string{"sh", "-c", "exit 0"}},
+				{Name: "teardown", Args: []string{"sh", "-c", "exit 4"}, AlwaysRun: true},
+			},
+			expectedResults: []string{wrapper.StepFailed, wrapper.StepSkipped, wrapper.StepFailed},
+			expectedCode:    3,
+		},
+		{
+			name: "step times out",
+			steps: []Step{
+				{Name: "build", Args: []string{"sleep", "10"}, Timeout: 100 * time.Millisecond},
+				{Name: "teardown", Args: []string{"sh", "-c", "exit 0"}, AlwaysRun: true},
+			},
+			expectedResults: []string{wrapper.StepTimedOut, wrapper.StepSucceeded},
+			expectedCode:    TimedOutErrorCode,
+		},
+		{
+			name:    "job times out",
+			timeout: 100 * time.Millisecond,
+			steps: []Step{
+				{Name: "build", Args: []string{"sleep", "10"}},
+				{Name: "test", Args: []string{"sh", "-c", "exit 0"}},
+				{Name: "teardown", Args: []string{"sh", "-c", "exit 0"}, AlwaysRun: true},
+			},
+			expectedResults: []string{wrapper.StepTimedOut, wrapper.StepSkipped, wrapper.StepSucceeded},
+			expectedCode:    TimedOutErrorCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "steps")
+			if err != nil {
+				t.Fatalf("error creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			options := Options{
+				Timeout:     tc.timeout,
+				GracePeriod: time.Second,
+				Steps:       tc.steps,
+				Options: &wrapper.Options{
+					ProcessLog: path.Join(tmpDir, "process-log.txt"),
+					MarkerFile: path.Join(tmpDir, "marker-file.txt"),
+					StepsFile:  path.Join(tmpDir, "steps.json"),
+				},
+			}
+			if code := options.Run(); code != tc.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			compareFileContents(tc.name, options.MarkerFile, strconv.Itoa(tc.expectedCode), t)
+
+			raw, err := ioutil.ReadFile(options.StepsFile)
+			if err != nil {
+				t.Fatalf("could not read steps file: %v", err)
+			}
+			var results []wrapper.StepResult
+			if err := json.Unmarshal(raw, &results); err != nil {
+				t.Fatalf("could not unmarshal step results: %v", err)
+			}
+			if len(results) != len(tc.steps) {
+				t.Fatalf("expected %d step results, got %+v", len(tc.steps), results)
+			}
+			for i, result := range results {
+				if result.Name != tc.steps[i].Name || result.Result != tc.expectedResults[i] {
+					t.Errorf("expected step %s to end with %s, got %+v", tc.steps[i].Name, tc.expectedResults[i], result)
+				}
+				if skipped := result.Result == wrapper.StepSkipped; skipped != (result.Started == 0) {
+					t.Errorf("expected only skipped steps to have no start time, got %+v", result)
+				}
+			}
+		})
+	}
+}
+
 func compareFileContents(name, file, expected string, t *testing.T) {
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
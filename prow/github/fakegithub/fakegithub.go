@@ -362,6 +362,7 @@ func (f *FakeClient) CreateIssue(org, repo, title, body string, milestone int, l
 	}
 	new := &github.Issue{
 		ID:        f.IssueID,
+		Number:    f.IssueID,
 		Title:     title,
 		Body:      body,
 		Milestone: github.Milestone{Number: milestone},
@@ -385,6 +386,30 @@ func (f *FakeClient) CloseIssue(org, repo string, number int) error {
 	return nil
 }
 
+// ClosePR closes a PR.
+func (f *FakeClient) ClosePR(org, repo string, number int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.PullRequests[number]; !ok {
+		return fmt.Errorf("pull request number %d does not exist", number)
+	}
+
+	f.PullRequests[number].State = "closed"
+	return nil
+}
+
+// UpdatePullRequestBranch merges the base branch into a PR.
+func (f *FakeClient) UpdatePullRequestBranch(org, repo string, number int, expectedHeadSha *string) error {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if _, ok := f.PullRequests[number]; !ok {
+		return fmt.Errorf("pull request number %d does not exist", number)
+	}
+	return nil
+}
+
 // GetPullRequestChanges returns the file modifications in a PR.
 func (f *FakeClient) GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error) {
 	f.lock.RLock()
@@ -38,7 +38,9 @@ import (
 	_ "k8s.io/test-infra/prow/plugins/hold"
 	_ "k8s.io/test-infra/prow/plugins/invalidcommitmsg"
 	_ "k8s.io/test-infra/prow/plugins/jira"
+	_ "k8s.io/test-infra/prow/plugins/jobdiff"
 	_ "k8s.io/test-infra/prow/plugins/label"
+	_ "k8s.io/test-infra/prow/plugins/label-guard"
 	_ "k8s.io/test-infra/prow/plugins/lgtm"
 	_ "k8s.io/test-infra/prow/plugins/lifecycle"
 	_ "k8s.io/test-infra/prow/plugins/merge-method-comment"
@@ -61,6 +61,11 @@ type Attributes struct {
 	ContentEncoding string
 	// Size is the size of the blob's content in bytes.
 	Size int64
+	// Generation is the generation of the object's content. It is only
+	// set for GCS objects.
+	Generation int64
+	// Updated is the time the object was last changed.
+	Updated time.Time
 }
 
 // Opener has methods to read and write paths
@@ -71,6 +76,7 @@ type Opener interface {
 	Attributes(ctx context.Context, path string) (Attributes, error)
 	SignedURL(ctx context.Context, path string, opts SignedURLOptions) (string, error)
 	Iterator(ctx context.Context, prefix, delimiter string) (ObjectIterator, error)
+	Delete(ctx context.Context, path string) error
 }
 
 type opener struct {
@@ -316,6 +322,8 @@ func (o *opener) Attributes(ctx context.Context, path string) (Attributes, error
 		return Attributes{
 			ContentEncoding: attr.ContentEncoding,
 			Size:            attr.Size,
+			Generation:      attr.Generation,
+			Updated:         attr.Updated,
 		}, nil
 	}
 
@@ -331,9 +339,30 @@ func (o *opener) Attributes(ctx context.Context, path string) (Attributes, error
 	return Attributes{
 		ContentEncoding: attr.ContentEncoding,
 		Size:            attr.Size,
+		Updated:         attr.ModTime,
 	}, nil
 }
 
+// Delete removes the object at path, returning an IsNotExist() error when missing
+func (o *opener) Delete(ctx context.Context, path string) error {
+	if strings.HasPrefix(path, providers.GS+"://") {
+		g, err := o.openGCS(path)
+		if err != nil {
+			return fmt.Errorf("bad gcs path: %v", err)
+		}
+		return g.Delete(ctx)
+	}
+	if strings.HasPrefix(path, "/") {
+		return os.Remove(path)
+	}
+
+	bucket, relativePath, err := o.getBucket(ctx, path)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(ctx, relativePath)
+}
+
 const (
 	GSAnonHost   = "storage.googleapis.com"
 	GSCookieHost = "storage.cloud.google.com"
@@ -204,3 +204,26 @@ func TestIsNotExist(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opener")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/artifact.txt"
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	o := &opener{}
+	if err := o.Delete(context.Background(), path); err != nil {
+		t.Fatalf("unexpected error deleting %s: %v", path, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be deleted, stat returned: %v", path, err)
+	}
+	if err := o.Delete(context.Background(), path); !IsNotExist(err) {
+		t.Errorf("expected a not-exist error deleting a missing file, got: %v", err)
+	}
+}
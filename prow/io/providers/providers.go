@@ -27,13 +27,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/memblob"
 	"gocloud.dev/blob/s3blob"
 )
 
 const (
-	S3 = "s3"
-	GS = "gs"
+	S3   = "s3"
+	GS   = "gs"
+	File = "file"
 )
 
 // GetBucket opens and returns a gocloud blob.Bucket based on credentials and a path.
@@ -68,6 +70,13 @@ func GetBucket(ctx context.Context, s3Credentials []byte, path string) (*blob.Bu
 	if storageProvider == S3 && len(s3Credentials) > 0 {
 		return getS3Bucket(ctx, s3Credentials, bucket)
 	}
+	if storageProvider == File {
+		bkt, err := fileblob.OpenBucket("/"+bucket, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file bucket: %v", err)
+		}
+		return bkt, nil
+	}
 
 	bkt, err := blob.OpenBucket(ctx, fmt.Sprintf("%s://%s", storageProvider, bucket))
 	if err != nil {
@@ -132,7 +141,7 @@ func getS3Bucket(ctx context.Context, creds []byte, bucketName string) (*blob.Bu
 // * gs/kubernetes-jenkins returns true
 // * kubernetes-jenkins returns false
 func HasStorageProviderPrefix(path string) bool {
-	return strings.HasPrefix(path, GS+"/") || strings.HasPrefix(path, S3+"/")
+	return strings.HasPrefix(path, GS+"/") || strings.HasPrefix(path, S3+"/") || strings.HasPrefix(path, File+"/")
 }
 
 // ParseStoragePath parses storagePath and returns the storageProvider, bucket and relativePath
@@ -142,6 +151,8 @@ func HasStorageProviderPrefix(path string) bool {
 // compatibility reasons.
 // File paths are split into a directory and a file. Directory is returned as bucket, file is returned.
 // as relativePath.
+// For file paths the first directory is treated as bucket, so file:///tmp/logs/test.log and
+// file://tmp/logs/test.log both result in (file, tmp, logs/test.log).
 // For all other paths the first part is treated as storageProvider prefix, the second segment as bucket
 // and everything after the bucket as relativePath.
 func ParseStoragePath(storagePath string) (storageProvider, bucket, relativePath string, err error) {
@@ -153,6 +164,13 @@ func ParseStoragePath(storagePath string) (storageProvider, bucket, relativePath
 	storageProvider = parsedPath.Scheme
 	bucket, relativePath = parsedPath.Host, parsedPath.Path
 	relativePath = strings.TrimPrefix(relativePath, "/")
+	if storageProvider == File && bucket == "" {
+		split := strings.SplitN(relativePath, "/", 2)
+		bucket, relativePath = split[0], ""
+		if len(split) == 2 {
+			relativePath = split[1]
+		}
+	}
 
 	if bucket == "" {
 		return "", "", "", fmt.Errorf("could not find bucket in storagePath %q", storagePath)
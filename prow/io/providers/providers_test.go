@@ -38,6 +38,11 @@ func TestHasStorageProviderPrefix(t *testing.T) {
 			path: "gs/kubernetes-jenkins",
 			want: true,
 		},
+		{
+			name: "file prefix",
+			path: "file/tmp",
+			want: true,
+		},
 		{
 			name: "no prefix",
 			path: "kubernetes-jenkins",
@@ -107,6 +112,25 @@ func TestParseStoragePath(t *testing.T) {
 			args:    args{storagePath: "gs://"},
 			wantErr: true,
 		},
+		{
+			name:                "parse file path",
+			args:                args{storagePath: "file:///tmp/prowjob-out/logs/test.log"},
+			wantStorageProvider: providers.File,
+			wantBucket:          "tmp",
+			wantRelativePath:    "prowjob-out/logs/test.log",
+		},
+		{
+			name:                "parse file path with bucket as host",
+			args:                args{storagePath: "file://tmp/prowjob-out"},
+			wantStorageProvider: providers.File,
+			wantBucket:          "tmp",
+			wantRelativePath:    "prowjob-out",
+		},
+		{
+			name:    "parse file root path fails",
+			args:    args{storagePath: "file:///"},
+			wantErr: true,
+		},
 		{
 			name:                "parse unknown prefix path",
 			args:                args{storagePath: "s4://prow-artifacts/pr-logs/bazel-build/test.log"},
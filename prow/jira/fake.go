@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Fake is a fake Jira client with injectable fields
+type Fake struct {
+	URL string
+	// Issues maps issue keys to the issues known to the fake
+	Issues map[string]*jira.Issue
+	// IssueErrors maps issue keys to errors returned for any request on the issue
+	IssueErrors map[string]error
+	RemoteLinks map[string][]jira.RemoteLink
+	// Transitions are available for all issues. Doing a transition moves
+	// the issue to the status the transition leads to.
+	Transitions []jira.Transition
+	// DoneTransitions records the IDs of the transitions done per issue key
+	DoneTransitions map[string][]string
+	// CreatedIssueLinks records the issue links created
+	CreatedIssueLinks []jira.IssueLink
+
+	lock sync.Mutex
+}
+
+func (f *Fake) issue(id string) (*jira.Issue, error) {
+	if err := f.IssueErrors[id]; err != nil {
+		return nil, err
+	}
+	issue, exists := f.Issues[id]
+	if !exists {
+		return nil, NewNotFoundError(fmt.Errorf("issue %s not registered in the fake", id))
+	}
+	return issue, nil
+}
+
+// GetIssue returns a copy of the issue, if registered, or an error, if set,
+// or responds with an error that matches IsNotFound
+func (f *Fake) GetIssue(id string) (*jira.Issue, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	issue, err := f.issue(id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *issue
+	if issue.Fields != nil {
+		fields := *issue.Fields
+		copied.Fields = &fields
+	}
+	return &copied, nil
+}
+
+// GetRemoteLinks returns the remote links of the issue
+func (f *Fake) GetRemoteLinks(id string) ([]jira.RemoteLink, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.issue(id); err != nil {
+		return nil, err
+	}
+	return f.RemoteLinks[id], nil
+}
+
+// AddRemoteLink adds the remote link to the issue
+func (f *Fake) AddRemoteLink(id string, link *jira.RemoteLink) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.issue(id); err != nil {
+		return err
+	}
+	if f.RemoteLinks == nil {
+		f.RemoteLinks = map[string][]jira.RemoteLink{}
+	}
+	f.RemoteLinks[id] = append(f.RemoteLinks[id], *link)
+	return nil
+}
+
+// ListProjects returns the projects of all registered issues
+func (f *Fake) ListProjects() (*jira.ProjectList, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keys := sets.NewString()
+	for key := range f.Issues {
+		keys.Insert(strings.SplitN(key, "-", 2)[0])
+	}
+	projects := make(jira.ProjectList, keys.Len())
+	for i, key := range keys.List() {
+		projects[i].Key = key
+		projects[i].Name = key
+	}
+	return &projects, nil
+}
+
+// GetTransitions returns the transitions configured in the fake
+func (f *Fake) GetTransitions(issueID string) ([]jira.Transition, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, err := f.issue(issueID); err != nil {
+		return nil, err
+	}
+	return f.Transitions, nil
+}
+
+// DoTransition moves the issue to the status the transition leads to
+func (f *Fake) DoTransition(issueID, transitionID string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	issue, err := f.issue(issueID)
+	if err != nil {
+		return err
+	}
+	for _, transition := range f.Transitions {
+		if transition.ID != transitionID {
+			continue
+		}
+		if issue.Fields == nil {
+			issue.Fields = &jira.IssueFields{}
+		}
+		status := transition.To
+		issue.Fields.Status = &status
+		if f.DoneTransitions == nil {
+			f.DoneTransitions = map[string][]string{}
+		}
+		f.DoneTransitions[issueID] = append(f.DoneTransitions[issueID], transitionID)
+		return nil
+	}
+	return fmt.Errorf("transition %s not registered in the fake", transitionID)
+}
+
+// CreateIssue registers the issue with the next free key in its project
+func (f *Fake) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if issue.Fields == nil || issue.Fields.Project.Key == "" {
+		return nil, fmt.Errorf("issue has no project")
+	}
+	if f.Issues == nil {
+		f.Issues = map[string]*jira.Issue{}
+	}
+	created := *issue
+	fields := *issue.Fields
+	created.Fields = &fields
+	for i := len(f.Issues) + 1; ; i++ {
+		key := fmt.Sprintf("%s-%d", fields.Project.Key, i)
+		if _, exists := f.Issues[key]; !exists {
+			created.Key, created.ID = key, key
+			break
+		}
+	}
+	f.Issues[created.Key] = &created
+	return &created, nil
+}
+
+// CreateIssueLink records the link and adds it to the linked issues
+func (f *Fake) CreateIssueLink(link *jira.IssueLink) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if link.InwardIssue == nil || link.OutwardIssue == nil {
+		return fmt.Errorf("link must have an inward and an outward issue")
+	}
+	inward, err := f.issue(link.InwardIssue.Key)
+	if err != nil {
+		return err
+	}
+	outward, err := f.issue(link.OutwardIssue.Key)
+	if err != nil {
+		return err
+	}
+	f.CreatedIssueLinks = append(f.CreatedIssueLinks, *link)
+	// Jira only returns the other end of a link on an issue
+	for _, issue := range []*jira.Issue{inward, outward} {
+		if issue.Fields == nil {
+			issue.Fields = &jira.IssueFields{}
+		}
+		copied := jira.IssueLink{Type: link.Type}
+		if issue == inward {
+			copied.OutwardIssue = &jira.Issue{Key: outward.Key}
+		} else {
+			copied.InwardIssue = &jira.Issue{Key: inward.Key}
+		}
+		issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, &copied)
+	}
+	return nil
+}
+
+// JiraClient is not implemented by the fake
+func (f *Fake) JiraClient() *jira.Client {
+	panic("not implemented")
+}
+
+// JiraURL returns the URL configured in the fake
+func (f *Fake) JiraURL() string {
+	return f.URL
+}
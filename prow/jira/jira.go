@@ -37,6 +37,10 @@ type Client interface {
 	GetRemoteLinks(id string) ([]jira.RemoteLink, error)
 	AddRemoteLink(id string, link *jira.RemoteLink) error
 	ListProjects() (*jira.ProjectList, error)
+	GetTransitions(issueID string) ([]jira.Transition, error)
+	DoTransition(issueID, transitionID string) error
+	CreateIssue(issue *jira.Issue) (*jira.Issue, error)
+	CreateIssueLink(link *jira.IssueLink) error
 	JiraClient() *jira.Client
 	JiraURL() string
 }
@@ -175,6 +179,41 @@ func (jc *client) AddRemoteLink(id string, link *jira.RemoteLink) error {
 	return nil
 }
 
+func (jc *client) GetTransitions(issueID string) ([]jira.Transition, error) {
+	transitions, resp, err := jc.upstream.Issue.GetTransitions(issueID)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, NotFoundError{err}
+		}
+		return nil, JiraError(resp, err)
+	}
+	return transitions, nil
+}
+
+func (jc *client) DoTransition(issueID, transitionID string) error {
+	resp, err := jc.upstream.Issue.DoTransition(issueID, transitionID)
+	if err != nil {
+		return fmt.Errorf("failed to transition issue: %w", JiraError(resp, err))
+	}
+	return nil
+}
+
+func (jc *client) CreateIssue(issue *jira.Issue) (*jira.Issue, error) {
+	result, resp, err := jc.upstream.Issue.Create(issue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create issue: %w", JiraError(resp, err))
+	}
+	return result, nil
+}
+
+func (jc *client) CreateIssueLink(link *jira.IssueLink) error {
+	resp, err := jc.upstream.Issue.AddLink(link)
+	if err != nil {
+		return fmt.Errorf("failed to create issue link: %w", JiraError(resp, err))
+	}
+	return nil
+}
+
 func (jc *client) JiraURL() string {
 	return jc.url
 }
@@ -0,0 +1,251 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jobhistory lists the runs of a job that were uploaded to blob storage.
+// It assumes the layout specified here:
+// https://github.com/kubernetes/test-infra/tree/master/gubernator#gcs-bucket-layout
+package jobhistory
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+const (
+	// LogsPrefix is the prefix of the roots whose runs are stored as directories
+	// directly below the root. Every other root holds symlinks to the runs.
+	LogsPrefix = gcs.NonPRLogs
+	// PRLogsDirectory is the prefix of the roots holding symlinks to presubmit runs.
+	PRLogsDirectory = gcs.PRLogs + "/directory"
+)
+
+var (
+	linkRe = regexp.MustCompile("/([0-9]+)\\.txt$")
+)
+
+// Bucket reads the runs of jobs from a single storage bucket.
+type Bucket struct {
+	Name            string
+	StorageProvider string
+	pkgio.Opener
+}
+
+// NewBucket returns a Bucket reading from the named bucket of the given storage provider.
+func NewBucket(name, storageProvider string, opener pkgio.Opener) Bucket {
+	return Bucket{Name: name, StorageProvider: storageProvider, Opener: opener}
+}
+
+// ParseBucket returns a Bucket for a bucket as configured in a GCSConfiguration,
+// which is a GCS bucket unless it is prefixed by a storage provider, e.g.:
+// * kubernetes-jenkins => gs, kubernetes-jenkins
+// * s3://prow-logs => s3, prow-logs
+func ParseBucket(bucket string, opener pkgio.Opener) (Bucket, error) {
+	if !strings.Contains(bucket, "://") {
+		return NewBucket(bucket, providers.GS, opener), nil
+	}
+	storageProvider, name, _, err := providers.ParseStoragePath(bucket)
+	if err != nil {
+		return Bucket{}, err
+	}
+	return NewBucket(name, storageProvider, opener), nil
+}
+
+// ReadObject reads the object with the given key.
+func (bucket Bucket) ReadObject(ctx context.Context, key string) ([]byte, error) {
+	rc, err := bucket.Opener.Reader(ctx, bucket.objectPath(key))
+	if err != nil {
+		return nil, fmt.Errorf("creating reader for object %s: %w", key, err)
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// WriteObject overwrites the object with the given key.
+func (bucket Bucket) WriteObject(ctx context.Context, key string, content []byte) error {
+	w, err := bucket.Opener.Writer(ctx, bucket.objectPath(key))
+	if err != nil {
+		return fmt.Errorf("creating writer for object %s: %w", key, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return fmt.Errorf("writing object %s: %w", key, err)
+	}
+	return w.Close()
+}
+
+// DeleteObject deletes the object with the given key.
+func (bucket Bucket) DeleteObject(ctx context.Context, key string) error {
+	if err := bucket.Opener.Delete(ctx, bucket.objectPath(key)); err != nil {
+		return fmt.Errorf("deleting object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (bucket Bucket) objectPath(key string) string {
+	return fmt.Sprintf("%s://%s/%s", bucket.StorageProvider, bucket.Name, key)
+}
+
+// ResolveSymLink resolves symlinks into the actual log directory for a particular test run, e.g.:
+// * input:  gs://prow-artifacts/pr-logs/pull/cluster-api-provider-openstack/1687/bazel-build/1248207834168954881
+// * output: pr-logs/pull/cluster-api-provider-openstack/1687/bazel-build/1248207834168954881
+func (bucket Bucket) ResolveSymLink(ctx context.Context, symLink string) (string, error) {
+	data, err := bucket.ReadObject(ctx, symLink)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", symLink, err)
+	}
+	// strip gs://<bucket-name> from global address `u`
+	u := strings.TrimSpace(string(data))
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(parsedURL.Path, "/"), nil
+}
+
+// GetPath returns the key of fname in the run with the given id below root.
+func (bucket Bucket) GetPath(ctx context.Context, root, id, fname string) (string, error) {
+	if strings.HasPrefix(root, LogsPrefix) {
+		return path.Join(root, id, fname), nil
+	}
+	symLink := path.Join(root, id+".txt")
+	dir, err := bucket.ResolveSymLink(ctx, symLink)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve sym link: %w", err)
+	}
+	return path.Join(dir, fname), nil
+}
+
+// ListSubDirs lists the "directory paths" immediately under prefix.
+func (bucket Bucket) ListSubDirs(ctx context.Context, prefix string) ([]string, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	it, err := bucket.Opener.Iterator(ctx, fmt.Sprintf("%s://%s/%s", bucket.StorageProvider, bucket.Name, prefix), "/")
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{}
+	for {
+		attrs, err := it.Next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return dirs, err
+		}
+		if attrs.IsDir {
+			dirs = append(dirs, attrs.Name)
+		}
+	}
+	return dirs, nil
+}
+
+// ListAll lists all keys with given prefix.
+func (bucket Bucket) ListAll(ctx context.Context, prefix string) ([]string, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	it, err := bucket.Opener.Iterator(ctx, fmt.Sprintf("%s://%s/%s", bucket.StorageProvider, bucket.Name, prefix), "")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for {
+		attrs, err := it.Next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return keys, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+	return keys, nil
+}
+
+// ListBuildIDs gets all build ids for a job. The ids that could be listed are
+// returned even if listing fails part way through.
+func (bucket Bucket) ListBuildIDs(ctx context.Context, root string) ([]int64, error) {
+	var ids []int64
+	if strings.HasPrefix(root, LogsPrefix) {
+		dirs, listErr := bucket.ListSubDirs(ctx, root)
+		for _, dir := range dirs {
+			leaf := path.Base(dir)
+			i, err := strconv.ParseInt(leaf, 10, 64)
+			if err == nil {
+				ids = append(ids, i)
+			} else {
+				logrus.WithField("gcs-path", dir).Warningf("unrecognized directory name (expected int64): %s", leaf)
+			}
+		}
+		if listErr != nil {
+			return ids, fmt.Errorf("failed to list directories: %w", listErr)
+		}
+	} else {
+		keys, listErr := bucket.ListAll(ctx, root)
+		for _, key := range keys {
+			matches := linkRe.FindStringSubmatch(key)
+			if len(matches) == 2 {
+				i, err := strconv.ParseInt(matches[1], 10, 64)
+				if err == nil {
+					ids = append(ids, i)
+				} else {
+					logrus.Warningf("unrecognized file name (expected <int64>.txt): %s", key)
+				}
+			}
+		}
+		if listErr != nil {
+			return ids, fmt.Errorf("failed to list keys: %w", listErr)
+		}
+	}
+	return ids, nil
+}
+
+// RootForRun returns the job history root and the build id of the run stored
+// in the given directory, e.g.:
+// * logs/ci-kubernetes-e2e/1234 => logs/ci-kubernetes-e2e, 1234
+// * pr-logs/pull/org_repo/56/pull-unit/1234 => pr-logs/directory/pull-unit, 1234
+func RootForRun(dir string) (string, int64, error) {
+	dir = strings.Trim(dir, "/")
+	id, err := strconv.ParseInt(path.Base(dir), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("run directory %q does not end in a build id: %w", dir, err)
+	}
+	job := path.Base(path.Dir(dir))
+	switch {
+	case strings.HasPrefix(dir, LogsPrefix+"/"):
+		return path.Dir(dir), id, nil
+	case strings.HasPrefix(dir, gcs.PRLogs+"/"):
+		return path.Join(PRLogsDirectory, job), id, nil
+	default:
+		return "", 0, fmt.Errorf("run directory %q is neither below %s/ nor %s/", dir, LogsPrefix, gcs.PRLogs)
+	}
+}
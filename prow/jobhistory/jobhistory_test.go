@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobhistory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/test-infra/prow/io"
+)
+
+func TestRootForRun(t *testing.T) {
+	testCases := []struct {
+		name         string
+		dir          string
+		expectedRoot string
+		expectedID   int64
+		expectedErr  bool
+	}{
+		{
+			name:         "periodic or postsubmit run",
+			dir:          "logs/ci-kubernetes-e2e/1234",
+			expectedRoot: "logs/ci-kubernetes-e2e",
+			expectedID:   1234,
+		},
+		{
+			name:         "presubmit run",
+			dir:          "pr-logs/pull/org_repo/56/pull-unit/1234/",
+			expectedRoot: "pr-logs/directory/pull-unit",
+			expectedID:   1234,
+		},
+		{
+			name:         "batch run",
+			dir:          "pr-logs/pull/batch/pull-unit/1234",
+			expectedRoot: "pr-logs/directory/pull-unit",
+			expectedID:   1234,
+		},
+		{
+			name:        "no build id",
+			dir:         "logs/ci-kubernetes-e2e",
+			expectedErr: true,
+		},
+		{
+			name:        "unknown layout",
+			dir:         "other/ci-kubernetes-e2e/1234",
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, id, err := RootForRun(tc.dir)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if root != tc.expectedRoot {
+				t.Errorf("expected root %q, got %q", tc.expectedRoot, root)
+			}
+			if id != tc.expectedID {
+				t.Errorf("expected id %d, got %d", tc.expectedID, id)
+			}
+		})
+	}
+}
+
+func TestParseBucket(t *testing.T) {
+	testCases := []struct {
+		bucket                  string
+		expectedName            string
+		expectedStorageProvider string
+	}{
+		{
+			bucket:                  "kubernetes-jenkins",
+			expectedName:            "kubernetes-jenkins",
+			expectedStorageProvider: "gs",
+		},
+		{
+			bucket:                  "gs://kubernetes-jenkins",
+			expectedName:            "kubernetes-jenkins",
+			expectedStorageProvider: "gs",
+		},
+		{
+			bucket:                  "s3://prow-logs",
+			expectedName:            "prow-logs",
+			expectedStorageProvider: "s3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.bucket, func(t *testing.T) {
+			bucket, err := ParseBucket(tc.bucket, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bucket.Name != tc.expectedName || bucket.StorageProvider != tc.expectedStorageProvider {
+				t.Errorf("expected %s://%s, got %s://%s", tc.expectedStorageProvider, tc.expectedName, bucket.StorageProvider, bucket.Name)
+			}
+		})
+	}
+}
+
+// TestListBuildIDsReturnsResultsOnError verifies that we get results even when there was an error,
+// mostly important so we can timeout it and still get some results.
+func TestListBuildIDsReturnsResultsOnError(t *testing.T) {
+	t.Run("logs-prefix", func(t *testing.T) {
+		bucket := Bucket{Opener: fakeOpener{iterator: fakeIterator{
+			result: io.ObjectAttributes{Name: "1327350934719696896", IsDir: true},
+			err:    errors.New("some-err"),
+		}}}
+		ids, err := bucket.ListBuildIDs(context.Background(), LogsPrefix)
+		if err == nil || err.Error() != "failed to list directories: some-err" {
+			t.Fatalf("didn't get expected error message 'failed to list directories: some-err' but got err %v", err)
+		}
+		if n := len(ids); n != 1 {
+			t.Errorf("didn't get result back, ids were %v", ids)
+		}
+	})
+	t.Run("no-prefix", func(t *testing.T) {
+		bucket := Bucket{Opener: fakeOpener{iterator: fakeIterator{
+			result: io.ObjectAttributes{Name: "/1327350934719696896.txt", IsDir: false},
+			err:    errors.New("some-err"),
+		}}}
+		ids, err := bucket.ListBuildIDs(context.Background(), "")
+		if err == nil || err.Error() != "failed to list keys: some-err" {
+			t.Fatalf("didn't get expected error message 'failed to list keys: some-err' but got err %v", err)
+		}
+		if n := len(ids); n != 1 {
+			t.Errorf("didn't get result back, ids were %v", ids)
+		}
+	})
+}
+
+type fakeIterator struct {
+	ranOnce bool
+	result  io.ObjectAttributes
+	err     error
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (io.ObjectAttributes, error) {
+	if !fi.ranOnce {
+		fi.ranOnce = true
+		return fi.result, nil
+	}
+	return io.ObjectAttributes{}, fi.err
+}
+
+type fakeOpener struct {
+	io.Opener
+	iterator fakeIterator
+}
+
+func (fo fakeOpener) Iterator(_ context.Context, _, _ string) (io.ObjectIterator, error) {
+	return &fo.iterator, nil
+}
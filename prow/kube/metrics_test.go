@@ -77,9 +77,11 @@ func TestGetJobLabelMap(t *testing.T) {
 					Repo:    "repo1",
 					BaseRef: "release-4.1",
 				},
+				Cluster: "build01",
 			},
 			Status: prowapi.ProwJobStatus{
-				State: prowapi.PendingState,
+				State:   prowapi.PendingState,
+				Cluster: "build02",
 			},
 		},
 		{
@@ -104,10 +106,10 @@ func TestGetJobLabelMap(t *testing.T) {
 	jobLabelMap := getJobLabelMap(pjs)
 
 	expected := map[jobLabel]float64{
-		{jobName: "test-job-1", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "master", state: string(prowapi.PendingState)}:      2,
-		{jobName: "test-job-2", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "master", state: string(prowapi.PendingState)}:      1,
-		{jobName: "test-job-2", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "release-4.1", state: string(prowapi.PendingState)}: 1,
-		{jobName: "test-job-3", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "release-4.2", state: string(prowapi.FailureState)}: 1,
+		{jobName: "test-job-1", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "master", state: string(prowapi.PendingState), cluster: "default"}:      2,
+		{jobName: "test-job-2", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "master", state: string(prowapi.PendingState), cluster: "default"}:      1,
+		{jobName: "test-job-2", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "release-4.1", state: string(prowapi.PendingState), cluster: "build02"}: 1,
+		{jobName: "test-job-3", jobType: string(prowapi.PresubmitJob), org: "org1", repo: "repo1", baseRef: "release-4.2", state: string(prowapi.FailureState), cluster: "default"}: 1,
 	}
 
 	if !reflect.DeepEqual(expected, jobLabelMap) {
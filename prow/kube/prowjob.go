@@ -53,4 +53,10 @@ const (
 	// PullLabel is added in resources created by prow and
 	// carries the PR number associated with the job, eg 321.
 	PullLabel = "prow.k8s.io/refs.pull"
+	// MatrixAnnotation is added to jobs expanded from a job matrix and
+	// carries the name of the job the matrix was defined on.
+	MatrixAnnotation = "prow.k8s.io/matrix"
+	// MatrixValuesAnnotation is added to jobs expanded from a job matrix
+	// and carries the value of each axis for this job, eg k8s=1.20,cloud=gce.
+	MatrixValuesAnnotation = "prow.k8s.io/matrix-values"
 )
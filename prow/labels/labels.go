@@ -35,6 +35,8 @@ const (
 	Hold                        = "do-not-merge/hold"
 	InvalidOwners               = "do-not-merge/invalid-owners-file"
 	InvalidBug                  = "bugzilla/invalid-bug"
+	JiraInvalidRef              = "jira/invalid-ref"
+	JiraValidRef                = "jira/valid-ref"
 	LGTM                        = "lgtm"
 	LifecycleActive             = "lifecycle/active"
 	LifecycleFrozen             = "lifecycle/frozen"
@@ -0,0 +1,364 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oidcauth logs users in with a generic OpenID Connect provider,
+// like Google or Okta, and remembers who they are and which groups they
+// belong to.
+package oidcauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/coreos/go-oidc"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	identitySession    = "oidc-identity-session"
+	oauthSessionCookie = "oidc-oauth-session"
+	stateKey           = "state"
+	nonceKey           = "nonce"
+	destKey            = "dest"
+	userKey            = "user"
+	groupsKey          = "groups"
+	expiryKey          = "expiry"
+)
+
+// Config is the configuration of the OIDC client. It also has a Cookie
+// Store that retains the identity of logged in users.
+type Config struct {
+	// IssuerURL identifies the provider, e.g. https://accounts.google.com.
+	IssuerURL    string `json:"issuer_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURL  string `json:"redirect_url"`
+	// Scopes are requested in addition to openid. Defaults to email and
+	// profile. Some providers need a scope like groups to report groups.
+	Scopes []string `json:"scopes,omitempty"`
+	// UsernameClaim is the claim of the ID token that names the user.
+	// Defaults to email.
+	UsernameClaim string `json:"username_claim,omitempty"`
+	// GroupsClaim is the claim of the ID token that lists the groups of
+	// the user. Defaults to groups.
+	GroupsClaim string `json:"groups_claim,omitempty"`
+
+	CookieStore *sessions.CookieStore `json:"-"`
+	// KeepGroup selects the groups of a user that are saved in the cookie,
+	// as browsers limit cookies to 4 KB. All groups are kept when it is nil.
+	KeepGroup func(group string) bool `json:"-"`
+}
+
+// Validate checks that the client is fully configured.
+func (c *Config) Validate() error {
+	if c.IssuerURL == "" || c.ClientID == "" || c.ClientSecret == "" || c.RedirectURL == "" {
+		return errors.New("issuer_url, client_id, client_secret and redirect_url are required")
+	}
+	return nil
+}
+
+// InitOIDCConfig defaults the config and sets the Cookie Store that retains
+// the identity of logged in users.
+func (c *Config) InitOIDCConfig(cookie *sessions.CookieStore) {
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"email", "profile"}
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "email"
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	c.CookieStore = cookie
+}
+
+// Identity is a user logged in with the OIDC provider.
+type Identity struct {
+	User   string
+	Groups []string
+}
+
+// Agent handles the login of users with the OIDC provider and tells who
+// sent a request.
+type Agent struct {
+	config   *Config
+	provider *Provider
+	verifier *oidc.IDTokenVerifier
+	oauth    *oauth2.Config
+	logger   *logrus.Entry
+}
+
+// NewAgent returns a new OIDC Agent.
+func NewAgent(config *Config, provider *Provider, logger *logrus.Entry) *Agent {
+	scopes := []string{"openid"}
+	for _, scope := range config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return &Agent{
+		config:   config,
+		provider: provider,
+		// The verifier checks the signature, issuer, audience and expiry
+		// of ID tokens.
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		oauth: &oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Scopes:       scopes,
+			Endpoint:     provider.Endpoint(),
+		},
+		logger: logger,
+	}
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// HandleLogin starts a new OIDC session and redirects the user to the
+// provider for authentication. The user is sent back to the dest query
+// parameter once logged in.
+func (a *Agent) HandleLogin(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := randomToken()
+		if err != nil {
+			a.serverError(w, "Generate state", err)
+			return
+		}
+		nonce, err := randomToken()
+		if err != nil {
+			a.serverError(w, "Generate nonce", err)
+			return
+		}
+		oauthSession, err := a.config.CookieStore.New(r, oauthSessionCookie)
+		if err != nil {
+			a.serverError(w, "Creating new OAuth session", err)
+			return
+		}
+		oauthSession.Options.Secure = secure
+		oauthSession.Options.HttpOnly = true
+		oauthSession.Options.MaxAge = 10 * 60
+		oauthSession.Values[stateKey] = state
+		oauthSession.Values[nonceKey] = nonce
+		oauthSession.Values[destKey] = r.URL.Query().Get("dest")
+		if err := oauthSession.Save(r, w); err != nil {
+			a.serverError(w, "Save oauth session", err)
+			return
+		}
+		http.Redirect(w, r, a.oauth.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), http.StatusFound)
+	}
+}
+
+// HandleRedirect handles the redirection from the provider. It exchanges
+// the code for an ID token, verifies it and saves the identity of the user
+// in a cookie until the ID token expires.
+func (a *Agent) HandleRedirect(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		oauthSession, err := a.config.CookieStore.Get(r, oauthSessionCookie)
+		if err != nil {
+			a.serverError(w, "Get cookie", err)
+			return
+		}
+		secretState, ok := oauthSession.Values[stateKey].(string)
+		if !ok {
+			a.serverError(w, "Get secret state", errors.New("no state in the OAuth session, which probably expired"))
+			return
+		}
+		// Validate the state parameter to prevent cross-site attack.
+		state := r.FormValue("state")
+		if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(secretState)) != 1 {
+			a.serverError(w, "Validate state", errors.New("invalid state"))
+			return
+		}
+		if providerError := r.FormValue("error"); providerError != "" {
+			a.logger.WithFields(logrus.Fields{
+				"oidc_error":             providerError,
+				"oidc_error_description": r.FormValue("error_description"),
+			}).Error("OIDC provider passed errors in callback")
+			a.serverError(w, "Authenticate with the OIDC provider", errors.New(providerError))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), oauth2.HTTPClient, a.provider.client)
+		token, err := a.oauth.Exchange(ctx, r.FormValue("code"))
+		if err != nil {
+			a.serverError(w, "Exchange code for token", err)
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok {
+			a.serverError(w, "Get ID token", errors.New("the token response has no ID token"))
+			return
+		}
+		idToken, err := a.verifier.Verify(ctx, rawIDToken)
+		if err != nil {
+			a.serverError(w, "Verify ID token", err)
+			return
+		}
+		secretNonce, _ := oauthSession.Values[nonceKey].(string)
+		if secretNonce == "" || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(secretNonce)) != 1 {
+			a.serverError(w, "Validate nonce", errors.New("invalid nonce"))
+			return
+		}
+		claims := map[string]interface{}{}
+		if err := idToken.Claims(&claims); err != nil {
+			a.serverError(w, "Get claims", err)
+			return
+		}
+		identity, err := a.identityFromClaims(claims)
+		if err != nil {
+			a.serverError(w, "Get identity", err)
+			return
+		}
+		expiry := idToken.Expiry
+
+		// The OAuth session is only needed for the login.
+		oauthSession.Options.MaxAge = -1
+		if err := oauthSession.Save(r, w); err != nil {
+			a.serverError(w, "Save invalidated OAuth session", err)
+			return
+		}
+		session, err := a.config.CookieStore.New(r, identitySession)
+		if err != nil {
+			a.serverError(w, "Create new session", err)
+			return
+		}
+		session.Options.Secure = secure
+		session.Options.HttpOnly = true
+		session.Options.MaxAge = int(time.Until(expiry).Seconds())
+		session.Values[userKey] = identity.User
+		session.Values[groupsKey] = identity.Groups
+		session.Values[expiryKey] = expiry.Unix()
+		if err := session.Save(r, w); err != nil {
+			a.serverError(w, "Save session", err)
+			return
+		}
+		a.logger.WithField("user", identity.User).Info("User logged in with OIDC.")
+		dest, _ := oauthSession.Values[destKey].(string)
+		http.Redirect(w, r, localDest(dest), http.StatusFound)
+	}
+}
+
+// localDest returns the path and query of dest to redirect the user to
+// after the login. Destinations that could lead outside of deck, i.e. with
+// a scheme, a host, backslashes, whitespace or control characters, are
+// replaced with the front page.
+func localDest(dest string) string {
+	if strings.ContainsRune(dest, '\\') || strings.IndexFunc(dest, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "/"
+	}
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || u.Opaque != "" {
+		return "/"
+	}
+	local := "/" + strings.TrimLeft(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		local += "?" + u.RawQuery
+	}
+	return local
+}
+
+// identityFromClaims reads the user and the groups to keep from the claims
+// of a verified ID token.
+func (a *Agent) identityFromClaims(claims map[string]interface{}) (*Identity, error) {
+	user, _ := claims[a.config.UsernameClaim].(string)
+	if user == "" {
+		return nil, fmt.Errorf("the ID token has no %s claim", a.config.UsernameClaim)
+	}
+	// Anyone could claim an email address they did not verify.
+	if verified, ok := claims["email_verified"].(bool); a.config.UsernameClaim == "email" && ok && !verified {
+		return nil, fmt.Errorf("the email address %s is not verified", user)
+	}
+	var groups []string
+	switch claimed := claims[a.config.GroupsClaim].(type) {
+	case string:
+		groups = []string{claimed}
+	case []interface{}:
+		for _, group := range claimed {
+			if name, ok := group.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+	}
+	identity := &Identity{User: user}
+	for _, group := range groups {
+		if a.config.KeepGroup == nil || a.config.KeepGroup(group) {
+			identity.Groups = append(identity.Groups, group)
+		}
+	}
+	return identity, nil
+}
+
+// GetIdentity returns the identity of the logged in user.
+func (a *Agent) GetIdentity(r *http.Request) (*Identity, error) {
+	session, err := a.config.CookieStore.Get(r, identitySession)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := session.Values[userKey].(string)
+	if !ok || user == "" {
+		return nil, errors.New("not logged in with OIDC")
+	}
+	if expiry, _ := session.Values[expiryKey].(int64); time.Now().Unix() >= expiry {
+		return nil, errors.New("the OIDC login expired")
+	}
+	groups, _ := session.Values[groupsKey].([]string)
+	return &Identity{User: user, Groups: groups}, nil
+}
+
+// HandleLogout forgets the identity of the user and redirects back to the
+// front page.
+func (a *Agent) HandleLogout(secure bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := a.config.CookieStore.Get(r, identitySession)
+		if err != nil {
+			a.serverError(w, "Get cookie", err)
+			return
+		}
+		session.Options.Secure = secure
+		session.Options.HttpOnly = true
+		session.Options.MaxAge = -1
+		if err := session.Save(r, w); err != nil {
+			a.serverError(w, "Save invalidated session on log out", err)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// Handles server errors.
+func (a *Agent) serverError(w http.ResponseWriter, action string, err error) {
+	a.logger.WithError(err).Errorf("Error %s.", action)
+	msg := fmt.Sprintf("500 Internal server error %s: %v", action, err)
+	http.Error(w, msg, http.StatusInternalServerError)
+}
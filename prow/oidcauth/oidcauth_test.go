@@ -0,0 +1,344 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidcauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go/v4"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeProvider is an OIDC provider that issues the ID token built by
+// claims for any code.
+type fakeProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims func(issuer string) jwt.MapClaims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p := &fakeProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]map[string]string{"keys": {{
+			"kid": "key-1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.claims(p.URL))
+		token.Header["kid"] = "key-1"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Errorf("failed to sign ID token: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func TestLogin(t *testing.T) {
+	provider := newFakeProvider(t)
+	defer provider.Close()
+	discovered, err := Discover(provider.Client(), provider.URL+"/")
+	if err != nil {
+		t.Fatalf("failed to discover provider: %v", err)
+	}
+	config := &Config{
+		IssuerURL:    provider.URL,
+		ClientID:     "deck",
+		ClientSecret: "secret",
+		RedirectURL:  "https://prow.example.com/oidc-login/redirect",
+		Scopes:       []string{"email", "groups"},
+	}
+	config.InitOIDCConfig(sessions.NewCookieStore([]byte("cookie-secret")))
+	agent := NewAgent(config, discovered, logrus.WithField("client", "oidc"))
+
+	testCases := []struct {
+		name        string
+		claims      func(issuer, nonce string) jwt.MapClaims
+		keepGroup   func(group string) bool
+		wrongState  bool
+		expected    *Identity
+		expectedErr bool
+	}{
+		{
+			name: "user with groups logs in",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{
+					"iss":            issuer,
+					"aud":            "deck",
+					"exp":            time.Now().Add(time.Hour).Unix(),
+					"nonce":          nonce,
+					"email":          "alice@example.com",
+					"email_verified": true,
+					"groups":         []string{"eng@example.com", "prow-admins@example.com"},
+				}
+			},
+			expected: &Identity{User: "alice@example.com", Groups: []string{"eng@example.com", "prow-admins@example.com"}},
+		},
+		{
+			name: "only the kept groups are saved",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{
+					"iss":    issuer,
+					"aud":    "deck",
+					"exp":    time.Now().Add(time.Hour).Unix(),
+					"nonce":  nonce,
+					"email":  "alice@example.com",
+					"groups": []string{"eng@example.com", "prow-admins@example.com", "everyone@example.com"},
+				}
+			},
+			keepGroup: func(group string) bool { return group == "prow-admins@example.com" },
+			expected:  &Identity{User: "alice@example.com", Groups: []string{"prow-admins@example.com"}},
+		},
+		{
+			name: "user without groups logs in",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "bob@example.com"}
+			},
+			expected: &Identity{User: "bob@example.com"},
+		},
+		{
+			name: "token for another client",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "other", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "token from another issuer",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": "https://evil.example.com", "aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "token without issuer",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "token without expiry",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "nonce": nonce, "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "expired token",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "exp": time.Now().Add(-time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "replayed token",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": "old", "email": "alice@example.com"}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "unverified email",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com", "email_verified": false}
+			},
+			expectedErr: true,
+		},
+		{
+			name: "forged state",
+			claims: func(issuer, nonce string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "deck", "exp": time.Now().Add(time.Hour).Unix(), "nonce": nonce, "email": "alice@example.com"}
+			},
+			wrongState:  true,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config.KeepGroup = tc.keepGroup
+			login := httptest.NewRecorder()
+			agent.HandleLogin(true).ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/oidc-login?dest=?job=ci-e2e", nil))
+			if login.Code != http.StatusFound {
+				t.Fatalf("expected login to redirect, got %d: %s", login.Code, login.Body.String())
+			}
+			authURL, err := url.Parse(login.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("invalid redirect to provider: %v", err)
+			}
+			state, nonce := authURL.Query().Get("state"), authURL.Query().Get("nonce")
+			if state == "" || nonce == "" {
+				t.Fatalf("expected redirect to provider with state and nonce, got %s", authURL)
+			}
+			if tc.wrongState {
+				state = "forged"
+			}
+			provider.claims = func(issuer string) jwt.MapClaims { return tc.claims(issuer, nonce) }
+
+			redirect := httptest.NewRequest(http.MethodGet, "/oidc-login/redirect?code=code&state="+state, nil)
+			for _, cookie := range login.Result().Cookies() {
+				redirect.AddCookie(cookie)
+			}
+			loggedIn := httptest.NewRecorder()
+			agent.HandleRedirect(true).ServeHTTP(loggedIn, redirect)
+			if tc.expectedErr {
+				if loggedIn.Code != http.StatusInternalServerError {
+					t.Errorf("expected login to fail, got %d", loggedIn.Code)
+				}
+				return
+			}
+			if loggedIn.Code != http.StatusFound {
+				t.Fatalf("expected redirect after login, got %d: %s", loggedIn.Code, loggedIn.Body.String())
+			}
+			if location := loggedIn.Header().Get("Location"); location != "/?job=ci-e2e" {
+				t.Errorf("expected redirect to the destination, got %q", location)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/rerun", nil)
+			for _, cookie := range loggedIn.Result().Cookies() {
+				if cookie.MaxAge >= 0 {
+					request.AddCookie(cookie)
+				}
+			}
+			identity, err := agent.GetIdentity(request)
+			if err != nil {
+				t.Fatalf("failed to get identity: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, identity); diff != "" {
+				t.Errorf("unexpected identity (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLocalDest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dest     string
+		expected string
+	}{
+		{
+			name:     "no destination",
+			expected: "/",
+		},
+		{
+			name:     "query",
+			dest:     "?job=ci-e2e",
+			expected: "/?job=ci-e2e",
+		},
+		{
+			name:     "relative path",
+			dest:     "view/gs/bucket/logs/job/1",
+			expected: "/view/gs/bucket/logs/job/1",
+		},
+		{
+			name:     "absolute path with query",
+			dest:     "/pr?query=is%3Apr",
+			expected: "/pr?query=is%3Apr",
+		},
+		{
+			name:     "tab before the path",
+			dest:     "\t/",
+			expected: "/",
+		},
+		{
+			name:     "tab in a protocol-relative URL",
+			dest:     "/\t/evil.example.com",
+			expected: "/",
+		},
+		{
+			name:     "backslash",
+			dest:     `/\evil.example.com`,
+			expected: "/",
+		},
+		{
+			name:     "protocol-relative URL",
+			dest:     "//evil.example.com/path",
+			expected: "/",
+		},
+		{
+			name:     "absolute URL",
+			dest:     "https://evil.example.com/path",
+			expected: "/",
+		},
+		{
+			name:     "scheme without slashes",
+			dest:     "javascript:alert(1)",
+			expected: "/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := localDest(tc.dest); actual != tc.expected {
+				t.Errorf("expected redirect to %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetIdentityWithoutLogin(t *testing.T) {
+	provider := newFakeProvider(t)
+	defer provider.Close()
+	discovered, err := Discover(provider.Client(), provider.URL)
+	if err != nil {
+		t.Fatalf("failed to discover provider: %v", err)
+	}
+	config := &Config{}
+	config.InitOIDCConfig(sessions.NewCookieStore([]byte("cookie-secret")))
+	agent := NewAgent(config, discovered, logrus.WithField("client", "oidc"))
+	if _, err := agent.GetIdentity(httptest.NewRequest(http.MethodGet, "/rerun", nil)); err == nil {
+		t.Error("expected an error for a request without an OIDC session")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oidcauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-oidc"
+)
+
+// Provider is an OpenID Connect provider, found through its discovery
+// document.
+type Provider struct {
+	*oidc.Provider
+
+	client *http.Client
+}
+
+// Discover reads the discovery document of the provider with the given
+// issuer URL. The client is used for all requests to the provider,
+// including the ones that fetch its signing keys later on, so it should
+// have a timeout.
+func Discover(client *http.Client, issuerURL string) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// The context is kept to fetch the rotated signing keys of the
+	// provider, so it must not be canceled.
+	ctx := oidc.ClientContext(context.Background(), client)
+	provider, err := oidc.NewProvider(ctx, strings.TrimSuffix(issuerURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+	return &Provider{Provider: provider, client: client}, nil
+}
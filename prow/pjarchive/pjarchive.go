@@ -0,0 +1,229 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pjarchive stores ProwJobs that sinker garbage-collects and
+// searches them afterwards.
+//
+// ProwJobs are grouped by the day they started on. Every write creates a new
+// gzipped JSON lines object in the directory of the day, e.g.
+// gs://bucket/prowjobs/2021-03-14/1615734000000000000.jsonl.gz.
+package pjarchive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/io/providers"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	extension = ".jsonl.gz"
+)
+
+// Archive reads and writes the ProwJobs archived under a storage path.
+type Archive struct {
+	opener   pkgio.Opener
+	location string
+}
+
+// New returns an Archive for the given storage path, e.g. gs://bucket/prowjobs.
+func New(opener pkgio.Opener, location string) *Archive {
+	return &Archive{opener: opener, location: strings.TrimSuffix(location, "/")}
+}
+
+// Day returns the day a ProwJob is archived under.
+func Day(pj *prowapi.ProwJob) string {
+	return pj.Status.StartTime.UTC().Format(dayFormat)
+}
+
+// Write archives the given ProwJobs. now is used to name the objects, so it
+// must differ between calls.
+func (a *Archive) Write(ctx context.Context, pjs []prowapi.ProwJob, now time.Time) error {
+	byDay := map[string][]prowapi.ProwJob{}
+	for _, pj := range pjs {
+		day := Day(&pj)
+		byDay[day] = append(byDay[day], pj)
+	}
+	for day, dayJobs := range byDay {
+		name := fmt.Sprintf("%s/%s/%d%s", a.location, day, now.UnixNano(), extension)
+		if err := a.writeObject(ctx, name, dayJobs); err != nil {
+			return fmt.Errorf("failed to archive ProwJobs to %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) writeObject(ctx context.Context, name string, pjs []prowapi.ProwJob) (err error) {
+	w, err := a.opener.Writer(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	for i := range pjs {
+		if err := enc.Encode(&pjs[i]); err != nil {
+			return err
+		}
+	}
+	return gz.Close()
+}
+
+// Query selects archived ProwJobs. Empty fields match all ProwJobs.
+type Query struct {
+	// From and To are the first and the last day, inclusive, on which the
+	// ProwJobs started.
+	From, To time.Time
+	// Repo is an "org" or an "org/repo". Periodics belong to the repo of
+	// their first extra ref.
+	Repo string
+	// PR is the number of a pull request the ProwJob tested.
+	PR int
+	// Author is the author of a pull request the ProwJob tested.
+	Author string
+	// State is the state of the ProwJob.
+	State prowapi.ProwJobState
+}
+
+// Matches determines whether a ProwJob is selected by the query. It ignores
+// the date range.
+func (q *Query) Matches(pj *prowapi.ProwJob) bool {
+	if q.State != "" && pj.Status.State != q.State {
+		return false
+	}
+	refs := pj.Spec.Refs
+	if refs == nil && len(pj.Spec.ExtraRefs) > 0 {
+		refs = &pj.Spec.ExtraRefs[0]
+	}
+	if q.Repo != "" {
+		if refs == nil {
+			return false
+		}
+		if q.Repo != refs.Org && q.Repo != refs.Org+"/"+refs.Repo {
+			return false
+		}
+	}
+	if q.PR == 0 && q.Author == "" {
+		return true
+	}
+	if refs == nil {
+		return false
+	}
+	for _, pull := range refs.Pulls {
+		if (q.PR == 0 || pull.Number == q.PR) && (q.Author == "" || strings.EqualFold(pull.Author, q.Author)) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the archived ProwJobs selected by the query, most recently
+// started first.
+func (a *Archive) List(ctx context.Context, q Query) ([]prowapi.ProwJob, error) {
+	storageProvider, bucket, _, err := providers.ParseStoragePath(a.location)
+	if err != nil {
+		return nil, err
+	}
+	from, to := q.From.UTC().Truncate(24*time.Hour), q.To.UTC()
+	if to.Before(from) {
+		return nil, fmt.Errorf("the end of the range %s is before its start %s", to.Format(dayFormat), from.Format(dayFormat))
+	}
+
+	var pjs []prowapi.ProwJob
+	// A ProwJob is archived again if sinker fails to delete it after archiving it.
+	seen := sets.NewString()
+	for day := from; !day.After(to); day = day.Add(24 * time.Hour) {
+		it, err := a.opener.Iterator(ctx, fmt.Sprintf("%s/%s/", a.location, day.Format(dayFormat)), "")
+		if err != nil {
+			return nil, err
+		}
+		for {
+			attrs, err := it.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if attrs.IsDir || !strings.HasSuffix(attrs.Name, extension) {
+				continue
+			}
+			name := fmt.Sprintf("%s://%s/%s", storageProvider, bucket, attrs.Name)
+			if err := a.readObject(ctx, name, func(pj *prowapi.ProwJob) {
+				if seen.Has(pj.Name) || !q.Matches(pj) {
+					return
+				}
+				seen.Insert(pj.Name)
+				pjs = append(pjs, *pj)
+			}); err != nil {
+				return nil, fmt.Errorf("failed to read archived ProwJobs from %s: %w", name, err)
+			}
+		}
+	}
+
+	sort.SliceStable(pjs, func(i, j int) bool {
+		return pjs[i].Status.StartTime.After(pjs[j].Status.StartTime.Time)
+	})
+	return pjs, nil
+}
+
+func (a *Archive) readObject(ctx context.Context, name string, fn func(*prowapi.ProwJob)) error {
+	r, err := a.opener.Reader(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	dec := json.NewDecoder(gz)
+	for {
+		var pj prowapi.ProwJob
+		if err := dec.Decode(&pj); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fn(&pj)
+	}
+}
+
+// ParseDay parses a day in the format the archive uses, e.g. 2021-03-14.
+func ParseDay(day string) (time.Time, error) {
+	return time.Parse(dayFormat, day)
+}
+
+// FormatDay formats a day like ParseDay parses it.
+func FormatDay(day time.Time) string {
+	return day.UTC().Format(dayFormat)
+}
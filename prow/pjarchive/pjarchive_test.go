@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pjarchive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+const bucket = "gs://bucket/"
+
+// fakeOpener keeps the objects of a single bucket in memory.
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string][]byte
+}
+
+func (fo *fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	content, ok := fo.objects[strings.TrimPrefix(p, bucket)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (fo *fakeOpener) Writer(_ context.Context, p string, _ ...pkgio.WriterOptions) (pkgio.WriteCloser, error) {
+	return &fakeWriter{key: strings.TrimPrefix(p, bucket), objects: fo.objects}, nil
+}
+
+func (fo *fakeOpener) Iterator(_ context.Context, p, _ string) (pkgio.ObjectIterator, error) {
+	var attrs []pkgio.ObjectAttributes
+	for key := range fo.objects {
+		if strings.HasPrefix(key, strings.TrimPrefix(p, bucket)) {
+			attrs = append(attrs, pkgio.ObjectAttributes{Name: key})
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeWriter struct {
+	bytes.Buffer
+	key     string
+	objects map[string][]byte
+}
+
+func (fw *fakeWriter) Close() error {
+	fw.objects[fw.key] = fw.Bytes()
+	return nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	attrs := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return attrs, nil
+}
+
+func prowJob(name string, start time.Time, state prowapi.ProwJobState, refs *prowapi.Refs) prowapi.ProwJob {
+	return prowapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       prowapi.ProwJobSpec{Job: "job-" + name, Refs: refs},
+		Status: prowapi.ProwJobStatus{
+			State:     state,
+			StartTime: metav1.NewTime(start),
+		},
+	}
+}
+
+func TestWriteAndList(t *testing.T) {
+	day1 := time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	day3 := day2.Add(24 * time.Hour)
+	pr := &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: 1, Author: "Alice"}}}
+	otherPR := &prowapi.Refs{Org: "org", Repo: "other", Pulls: []prowapi.Pull{{Number: 2, Author: "bob"}}}
+	postsubmit := &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master"}
+	periodic := prowJob("e", day2, prowapi.FailureState, nil)
+	periodic.Spec.ExtraRefs = []prowapi.Refs{{Org: "other-org", Repo: "repo"}}
+
+	a := New(&fakeOpener{objects: map[string][]byte{}}, bucket+"prowjobs/")
+	if err := a.Write(context.Background(), []prowapi.ProwJob{
+		prowJob("a", day1, prowapi.SuccessState, pr),
+		prowJob("b", day1.Add(time.Hour), prowapi.FailureState, otherPR),
+		prowJob("c", day2, prowapi.SuccessState, postsubmit),
+	}, day3); err != nil {
+		t.Fatalf("failed to archive ProwJobs: %v", err)
+	}
+	if err := a.Write(context.Background(), []prowapi.ProwJob{
+		// Archived again after sinker failed to delete it.
+		prowJob("c", day2, prowapi.SuccessState, postsubmit),
+		prowJob("d", day3, prowapi.AbortedState, pr),
+		periodic,
+	}, day3.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to archive ProwJobs: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		query    Query
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "everything",
+			query:    Query{From: day1, To: day3},
+			expected: []string{"d", "c", "e", "b", "a"},
+		},
+		{
+			name:     "single day",
+			query:    Query{From: day2, To: day2},
+			expected: []string{"c", "e"},
+		},
+		{
+			name:     "days are inclusive",
+			query:    Query{From: day1.Add(12 * time.Hour), To: day2.Add(-12 * time.Hour)},
+			expected: []string{"b", "a"},
+		},
+		{
+			name:     "org",
+			query:    Query{From: day1, To: day3, Repo: "org"},
+			expected: []string{"d", "c", "b", "a"},
+		},
+		{
+			name:     "repo",
+			query:    Query{From: day1, To: day3, Repo: "org/other"},
+			expected: []string{"b"},
+		},
+		{
+			name:     "periodics belong to their first extra ref",
+			query:    Query{From: day1, To: day3, Repo: "other-org/repo"},
+			expected: []string{"e"},
+		},
+		{
+			name:     "pull request",
+			query:    Query{From: day1, To: day3, PR: 1},
+			expected: []string{"d", "a"},
+		},
+		{
+			name:     "author is case insensitive",
+			query:    Query{From: day1, To: day3, Author: "alice"},
+			expected: []string{"d", "a"},
+		},
+		{
+			name:     "state",
+			query:    Query{From: day1, To: day3, State: prowapi.FailureState},
+			expected: []string{"e", "b"},
+		},
+		{
+			name:  "end before start",
+			query: Query{From: day2, To: day1},
+			err:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pjs, err := a.List(context.Background(), tc.query)
+			if err != nil {
+				if !tc.err {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Fatal("expected an error, got none")
+			}
+			var names []string
+			for _, pj := range pjs {
+				names = append(names, pj.Name)
+			}
+			if diff := cmp.Diff(tc.expected, names); diff != "" {
+				t.Errorf("unexpected ProwJobs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -47,6 +47,14 @@ func CommandFilter(body string) Filter {
 	}
 }
 
+// MatrixFilter builds a filter for `/test foo axis=value`, which runs the jobs
+// expanded from the matrix of job foo that have the given axis values.
+func MatrixFilter(body string) Filter {
+	return func(p config.Presubmit) (bool, bool, bool) {
+		return p.MatrixMatches(body), p.MatrixMatches(body), true
+	}
+}
+
 // TestAllFilter builds a filter for the automatic behavior of `/test all`.
 // Jobs that explicitly match `/test all` in their trigger regex will be
 // handled by a commandFilter for the comment in question.
@@ -113,7 +121,7 @@ func PresubmitFilter(honorOkToTest bool, contextGetter contextGetter, body strin
 	// as they have precedence -- filters that override the false default should
 	// match before others. We order filters by amount of specificity.
 	var filters []Filter
-	filters = append(filters, CommandFilter(body))
+	filters = append(filters, CommandFilter(body), MatrixFilter(body))
 	if RetestRe.MatchString(body) {
 		logger.Info("Using retest filter.")
 		failedContexts, allContexts, err := contextGetter()
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
 	"reflect"
 	"testing"
 
@@ -161,6 +162,65 @@ func TestCommandFilter(t *testing.T) {
 	}
 }
 
+func TestMatrixFilter(t *testing.T) {
+	matrixJob := func(name, values string) config.Presubmit {
+		return config.Presubmit{
+			JobBase: config.JobBase{
+				Name: name,
+				Annotations: map[string]string{
+					kube.MatrixAnnotation:       "pull-e2e",
+					kube.MatrixValuesAnnotation: values,
+				},
+			},
+		}
+	}
+	var testCases = []struct {
+		name       string
+		body       string
+		presubmits []config.Presubmit
+		expected   [][]bool
+	}{
+		{
+			name: "matrix name matches all expanded jobs",
+			body: "/test pull-e2e",
+			presubmits: []config.Presubmit{
+				matrixJob("pull-e2e-1.20", "k8s=1.20"),
+				matrixJob("pull-e2e-1.21", "k8s=1.21"),
+				{JobBase: config.JobBase{Name: "pull-e2e"}},
+			},
+			expected: [][]bool{{true, true, true}, {true, true, true}, {false, false, true}},
+		},
+		{
+			name: "axis values select expanded jobs",
+			body: "/test pull-e2e k8s=1.21",
+			presubmits: []config.Presubmit{
+				matrixJob("pull-e2e-1.20", "k8s=1.20"),
+				matrixJob("pull-e2e-1.21", "k8s=1.21"),
+			},
+			expected: [][]bool{{false, false, true}, {true, true, true}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := MatrixFilter(testCase.body)
+			for i, presubmit := range testCase.presubmits {
+				actualFiltered, actualForced, actualDefault := filter(presubmit)
+				expectedFiltered, expectedForced, expectedDefault := testCase.expected[i][0], testCase.expected[i][1], testCase.expected[i][2]
+				if actualFiltered != expectedFiltered {
+					t.Errorf("%s: filter did not evaluate correctly, expected %v but got %v for %v", testCase.name, expectedFiltered, actualFiltered, presubmit.Name)
+				}
+				if actualForced != expectedForced {
+					t.Errorf("%s: filter did not determine forced correctly, expected %v but got %v for %v", testCase.name, expectedForced, actualForced, presubmit.Name)
+				}
+				if actualDefault != expectedDefault {
+					t.Errorf("%s: filter did not determine default correctly, expected %v but got %v for %v", testCase.name, expectedDefault, actualDefault, presubmit.Name)
+				}
+			}
+		})
+	}
+}
+
 func fakeChangedFilesProvider(shouldError bool) config.ChangedFilesProvider {
 	return func() ([]string, error) {
 		if shouldError {
@@ -26,6 +26,7 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
@@ -113,6 +114,7 @@ func PresubmitSpec(p config.Presubmit, refs prowapi.Refs) prowapi.ProwJobSpec {
 		}
 	}
 	pjs.Refs = CompletePrimaryRefs(refs, p.JobBase)
+	completeSparseCheckout(pjs.Refs, p.JobBase, p.RegexpChangeMatcher)
 
 	return pjs
 }
@@ -124,6 +126,7 @@ func PostsubmitSpec(p config.Postsubmit, refs prowapi.Refs) prowapi.ProwJobSpec
 	pjs.Context = p.Context
 	pjs.Report = !p.SkipReport
 	pjs.Refs = CompletePrimaryRefs(refs, p.JobBase)
+	completeSparseCheckout(pjs.Refs, p.JobBase, p.RegexpChangeMatcher)
 	if p.JenkinsSpec != nil {
 		pjs.JenkinsSpec = &prowapi.JenkinsSpec{
 			GitHubBranchSourceJob: p.JenkinsSpec.GitHubBranchSourceJob,
@@ -149,6 +152,7 @@ func BatchSpec(p config.Presubmit, refs prowapi.Refs) prowapi.ProwJobSpec {
 	pjs.Type = prowapi.BatchJob
 	pjs.Context = p.Context
 	pjs.Refs = CompletePrimaryRefs(refs, p.JobBase)
+	completeSparseCheckout(pjs.Refs, p.JobBase, p.RegexpChangeMatcher)
 
 	return pjs
 }
@@ -162,6 +166,8 @@ func specFromJobBase(jb config.JobBase) prowapi.ProwJobSpec {
 		Job:             jb.Name,
 		Agent:           prowapi.ProwJobAgent(jb.Agent),
 		Cluster:         jb.Cluster,
+		Clusters:        jb.Clusters,
+		ClusterGroup:    jb.ClusterGroup,
 		Namespace:       namespace,
 		MaxConcurrency:  jb.MaxConcurrency,
 		ErrorOnEviction: jb.ErrorOnEviction,
@@ -194,9 +200,28 @@ func CompletePrimaryRefs(refs prowapi.Refs, jb config.JobBase) *prowapi.Refs {
 	if jb.SkipFetchHead {
 		refs.SkipFetchHead = jb.SkipFetchHead
 	}
+	if len(jb.SparseCheckout) > 0 {
+		refs.SparseCheckout = jb.SparseCheckout
+	}
+	if jb.CloneFilter != "" {
+		refs.CloneFilter = jb.CloneFilter
+	}
 	return &refs
 }
 
+// completeSparseCheckout adds the directories matched by the run_if_changed
+// of jobs that derive their sparse checkout from it.
+func completeSparseCheckout(refs *prowapi.Refs, jb config.JobBase, cm config.RegexpChangeMatcher) {
+	if !jb.SparseCheckoutFromRunIfChanged {
+		return
+	}
+	paths := cm.SparseCheckoutPaths()
+	if len(paths) == 0 {
+		return
+	}
+	refs.SparseCheckout = sets.NewString(refs.SparseCheckout...).Insert(paths...).List()
+}
+
 // PartitionActive separates the provided prowjobs into pending and triggered
 // and returns them inside channels so that they can be consumed in parallel
 // by different goroutines. Complete prowjobs are filtered out. Controller
@@ -194,6 +194,29 @@ func TestPresubmitSpec(t *testing.T) {
 				Report: true,
 			},
 		},
+		{
+			name: "sparse checkout is derived from run_if_changed",
+			p: config.Presubmit{
+				JobBase: config.JobBase{
+					UtilityConfig: config.UtilityConfig{
+						SparseCheckout:                 []string{"hack"},
+						SparseCheckoutFromRunIfChanged: true,
+						CloneFilter:                    "blob:none",
+					},
+				},
+				RegexpChangeMatcher: config.RegexpChangeMatcher{
+					RunIfChanged: "^(prow|label_sync)/",
+				},
+			},
+			expected: prowapi.ProwJobSpec{
+				Type: prowapi.PresubmitJob,
+				Refs: &prowapi.Refs{
+					SparseCheckout: []string{"hack", "label_sync", "prow"},
+					CloneFilter:    "blob:none",
+				},
+				Report: true,
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -345,6 +368,19 @@ func TestCompletePrimaryRefs(t *testing.T) {
 				CloneDepth: 2,
 			},
 		},
+		{
+			name: "sparse checkout and clone filter from job base",
+			jobBase: config.JobBase{
+				UtilityConfig: config.UtilityConfig{
+					SparseCheckout: []string{"prow"},
+					CloneFilter:    "tree:0",
+				},
+			},
+			expected: prowapi.Refs{
+				SparseCheckout: []string{"prow"},
+				CloneFilter:    "tree:0",
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plank
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	kubernetesreporterapi "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes/api"
+	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+// clusterFailureCooldown is how long a build cluster in which a pod could
+// not be created is avoided when picking a cluster for a job.
+const clusterFailureCooldown = 5 * time.Minute
+
+// clusterHealth remembers the build clusters that recently failed to create pods.
+type clusterHealth struct {
+	lock     sync.Mutex
+	failures map[string]time.Time
+}
+
+func (h *clusterHealth) markFailed(cluster string, now time.Time) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.failures == nil {
+		h.failures = map[string]time.Time{}
+	}
+	h.failures[cluster] = now
+}
+
+func (h *clusterHealth) healthy(cluster string, now time.Time) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	failed, ok := h.failures[cluster]
+	return !ok || now.Sub(failed) >= clusterFailureCooldown
+}
+
+// selectCluster picks the build cluster to run a job with candidate clusters in.
+// Clusters the job was already moved away from are skipped and healthy clusters
+// are preferred. Among those, the cluster with the fewest pending pods relative
+// to its weight wins, with ties going to the cluster listed first. An empty
+// string is returned when no candidate is left.
+func (r *reconciler) selectCluster(ctx context.Context, pj *prowv1.ProwJob, exclude sets.String) string {
+	now := r.clock.Now()
+	var healthy, unhealthy []string
+	for _, cluster := range r.config().Plank.CandidateClusters(pj) {
+		if exclude.Has(cluster) {
+			continue
+		}
+		if _, ok := r.buildClients[cluster]; !ok {
+			r.log.WithFields(pjutil.ProwJobFields(pj)).WithField("cluster", cluster).Warn("Skipping candidate cluster without a build client.")
+			continue
+		}
+		if r.clusterHealth.healthy(cluster, now) {
+			healthy = append(healthy, cluster)
+		} else {
+			unhealthy = append(unhealthy, cluster)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		// Every remaining cluster failed recently; trying one of them again beats
+		// leaving the job waiting.
+		candidates = unhealthy
+	}
+
+	var best string
+	var bestLoad float64
+	for _, cluster := range candidates {
+		pending, err := r.pendingPods(ctx, cluster)
+		if err != nil {
+			r.log.WithFields(pjutil.ProwJobFields(pj)).WithField("cluster", cluster).WithError(err).Warn("Failed to count pending pods, skipping cluster.")
+			continue
+		}
+		load := float64(pending) / float64(r.config().Plank.ClusterWeight(cluster))
+		if best == "" || load < bestLoad {
+			best, bestLoad = cluster, load
+		}
+	}
+	return best
+}
+
+// pendingPods counts the pods created by prow that are pending in the build cluster.
+func (r *reconciler) pendingPods(ctx context.Context, cluster string) (int, error) {
+	pods := &corev1.PodList{}
+	if err := r.buildClients[cluster].List(ctx, pods,
+		ctrlruntimeclient.InNamespace(r.config().PodNamespace),
+		ctrlruntimeclient.MatchingLabels{kube.CreatedByProw: "true"},
+	); err != nil {
+		return 0, fmt.Errorf("failed to list pods in cluster %s: %w", cluster, err)
+	}
+	var pending int
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodPending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// unschedulableSince returns when the pod was found to be unschedulable, or
+// nil if the scheduler has not given up on it.
+func unschedulableSince(pod *corev1.Pod) *time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			return &condition.LastTransitionTime.Time
+		}
+	}
+	return nil
+}
+
+// reschedule moves a job whose pod cannot be scheduled to another candidate
+// cluster by deleting the pod and recording the new cluster, so that the pod
+// gets re-created there. It reports false if there is no other cluster to try.
+func (r *reconciler) reschedule(ctx context.Context, pj *prowv1.ProwJob, pod *corev1.Pod) (bool, error) {
+	from := pj.ClusterAlias()
+	exclude := sets.NewString(pj.Status.RescheduledFrom...).Insert(from)
+	to := r.selectCluster(ctx, pj, exclude)
+	if to == "" {
+		return false, nil
+	}
+
+	client := r.buildClients[from]
+	if finalizers := sets.NewString(pod.Finalizers...); finalizers.Has(kubernetesreporterapi.FinalizerName) {
+		// The job goes on in another cluster, so this pod must not hold up its reporting
+		oldPod := pod.DeepCopy()
+		pod.Finalizers = finalizers.Delete(kubernetesreporterapi.FinalizerName).UnsortedList()
+		if err := client.Patch(ctx, pod, ctrlruntimeclient.MergeFrom(oldPod)); err != nil {
+			return false, fmt.Errorf("failed to patch pod trying to remove %s finalizer: %w", kubernetesreporterapi.FinalizerName, err)
+		}
+	}
+	if err := ctrlruntimeclient.IgnoreNotFound(client.Delete(ctx, pod)); err != nil {
+		return false, fmt.Errorf("failed to delete pod %s/%s in cluster %s: %w", pod.Namespace, pod.Name, from, err)
+	}
+
+	pj.Status.RescheduledFrom = append(pj.Status.RescheduledFrom, from)
+	pj.Status.Cluster = to
+	pj.Status.Description = fmt.Sprintf("Pod could not be scheduled in cluster %s, rescheduled to %s.", from, to)
+	r.log.WithFields(pjutil.ProwJobFields(pj)).WithField("from", from).WithField("to", to).Info("Rescheduled unschedulable pod to another cluster.")
+	return true, nil
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/kube"
+)
+
+func pendingPodsInCluster(cluster string, count int) []runtime.Object {
+	var pods []runtime.Object
+	for i := 0; i < count; i++ {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", cluster, i),
+				Namespace: "pods",
+				Labels:    map[string]string{kube.CreatedByProw: "true"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodPending},
+		})
+	}
+	return pods
+}
+
+func TestSelectCluster(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name     string
+		exclude  sets.String
+		expected string
+	}{
+		{
+			name:     "least loaded healthy cluster relative to its weight wins",
+			exclude:  sets.NewString(),
+			expected: "heavy",
+		},
+		{
+			name:     "excluded clusters are skipped",
+			exclude:  sets.NewString("heavy"),
+			expected: "light",
+		},
+		{
+			name:     "unhealthy clusters are used when nothing else is left",
+			exclude:  sets.NewString("heavy", "light"),
+			expected: "broken",
+		},
+		{
+			name:     "no cluster left",
+			exclude:  sets.NewString("heavy", "light", "broken"),
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newFakeConfigAgent(t, 0)
+			cfg.c.Plank.ClusterGroups = map[string][]string{"group": {"light", "heavy", "broken", "missing"}}
+			cfg.c.Plank.ClusterWeights = map[string]int{"heavy": 4}
+			r := &reconciler{
+				buildClients: map[string]ctrlruntimeclient.Client{
+					"light":  fakectrlruntimeclient.NewFakeClient(pendingPodsInCluster("light", 2)...),
+					"heavy":  fakectrlruntimeclient.NewFakeClient(pendingPodsInCluster("heavy", 4)...),
+					"broken": fakectrlruntimeclient.NewFakeClient(),
+				},
+				log:    logrus.NewEntry(logrus.StandardLogger()),
+				config: cfg.Config,
+				clock:  clock.NewFakeClock(now),
+			}
+			r.clusterHealth.markFailed("broken", now.Add(-time.Minute))
+			pj := &prowapi.ProwJob{Spec: prowapi.ProwJobSpec{ClusterGroup: "group"}}
+
+			if actual := r.selectCluster(context.Background(), pj, tc.exclude); actual != tc.expected {
+				t.Errorf("expected cluster %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestClusterHealthCooldown(t *testing.T) {
+	now := time.Now()
+	var h clusterHealth
+	if !h.healthy("cluster", now) {
+		t.Error("expected a cluster without failures to be healthy")
+	}
+	h.markFailed("cluster", now)
+	if h.healthy("cluster", now.Add(clusterFailureCooldown-time.Second)) {
+		t.Error("expected a recently failed cluster to be unhealthy")
+	}
+	if !h.healthy("cluster", now.Add(clusterFailureCooldown)) {
+		t.Error("expected a cluster to be healthy again after the cooldown")
+	}
+}
+
+func TestSyncTriggeredJobPicksCluster(t *testing.T) {
+	pj := &prowapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "prowjobs"},
+		Spec: prowapi.ProwJobSpec{
+			Job:      "job",
+			Agent:    prowapi.KubernetesAgent,
+			Type:     prowapi.PeriodicJob,
+			Clusters: []string{"busy", "idle"},
+			PodSpec:  &corev1.PodSpec{Containers: []corev1.Container{{Name: "test-name", Env: []corev1.EnvVar{}}}},
+		},
+		Status: prowapi.ProwJobStatus{State: prowapi.TriggeredState},
+	}
+	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
+	defer totServ.Close()
+	pjClient := fakectrlruntimeclient.NewFakeClient(pj.DeepCopy())
+	idle := fakectrlruntimeclient.NewFakeClient()
+	r := &reconciler{
+		pjClient: pjClient,
+		buildClients: map[string]ctrlruntimeclient.Client{
+			"busy": fakectrlruntimeclient.NewFakeClient(pendingPodsInCluster("busy", 1)...),
+			"idle": idle,
+		},
+		log:    logrus.NewEntry(logrus.StandardLogger()),
+		config: newFakeConfigAgent(t, 0).Config,
+		totURL: totServ.URL,
+		clock:  clock.RealClock{},
+	}
+
+	if _, err := r.syncTriggeredJob(context.Background(), pj); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pjClient.Get(context.Background(), types.NamespacedName{Namespace: "prowjobs", Name: "job"}, pj); err != nil {
+		t.Fatalf("failed to get prowjob: %v", err)
+	}
+	if pj.Status.Cluster != "idle" || pj.ClusterAlias() != "idle" {
+		t.Errorf("expected the job to be scheduled to cluster idle, got %q", pj.Status.Cluster)
+	}
+	if err := idle.Get(context.Background(), types.NamespacedName{Namespace: "pods", Name: "job"}, &corev1.Pod{}); err != nil {
+		t.Errorf("expected pod to be created in cluster idle: %v", err)
+	}
+}
+
+type patchFailingClient struct {
+	ctrlruntimeclient.Client
+}
+
+func (c *patchFailingClient) Patch(ctx context.Context, obj ctrlruntimeclient.Object, patch ctrlruntimeclient.Patch, opts ...ctrlruntimeclient.PatchOption) error {
+	return kapierrors.NewConflict(prowapi.Resource("prowjobs"), obj.GetName(), fmt.Errorf("conflict"))
+}
+
+func TestSyncTriggeredJobPersistsClusterBeforeStartingPod(t *testing.T) {
+	pj := &prowapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "prowjobs"},
+		Spec: prowapi.ProwJobSpec{
+			Job:      "job",
+			Agent:    prowapi.KubernetesAgent,
+			Type:     prowapi.PeriodicJob,
+			Clusters: []string{"first", "second"},
+			PodSpec:  &corev1.PodSpec{Containers: []corev1.Container{{Name: "test-name", Env: []corev1.EnvVar{}}}},
+		},
+		Status: prowapi.ProwJobStatus{State: prowapi.TriggeredState},
+	}
+	totServ := httptest.NewServer(http.HandlerFunc(handleTot))
+	defer totServ.Close()
+	buildClients := map[string]ctrlruntimeclient.Client{
+		"first":  fakectrlruntimeclient.NewFakeClient(),
+		"second": fakectrlruntimeclient.NewFakeClient(),
+	}
+	r := &reconciler{
+		pjClient:     &patchFailingClient{Client: fakectrlruntimeclient.NewFakeClient(pj.DeepCopy())},
+		buildClients: buildClients,
+		log:          logrus.NewEntry(logrus.StandardLogger()),
+		config:       newFakeConfigAgent(t, 0).Config,
+		totURL:       totServ.URL,
+		clock:        clock.RealClock{},
+	}
+
+	if _, err := r.syncTriggeredJob(context.Background(), pj); err == nil {
+		t.Fatal("expected an error when the cluster cannot be persisted")
+	}
+	for name, client := range buildClients {
+		pods := &corev1.PodList{}
+		if err := client.List(context.Background(), pods); err != nil {
+			t.Fatalf("failed to list pods: %v", err)
+		}
+		if len(pods.Items) != 0 {
+			t.Errorf("expected no pod before the cluster is persisted, got %d in cluster %s", len(pods.Items), name)
+		}
+	}
+}
+
+func TestSyncPendingJobReschedules(t *testing.T) {
+	now := time.Now()
+	unschedulablePod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "job",
+				Namespace:         "pods",
+				CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Minute)),
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodScheduled,
+					Status:             corev1.ConditionFalse,
+					Reason:             corev1.PodReasonUnschedulable,
+					LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Minute)),
+				}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		clusters         []string
+		rescheduledFrom  []string
+		expectedCluster  string
+		expectedFrom     []string
+		expectPodDeleted bool
+	}{
+		{
+			name:             "unschedulable pod moves to another candidate",
+			clusters:         []string{"first", "second"},
+			expectedCluster:  "second",
+			expectedFrom:     []string{"first"},
+			expectPodDeleted: true,
+		},
+		{
+			name:            "no candidate left keeps the pod",
+			clusters:        []string{"first", "second"},
+			rescheduledFrom: []string{"second"},
+			expectedCluster: "first",
+			expectedFrom:    []string{"second"},
+		},
+		{
+			name:            "jobs without candidates are not rescheduled",
+			expectedCluster: "first",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pj := &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "prowjobs"},
+				Spec:       prowapi.ProwJobSpec{Job: "job", Agent: prowapi.KubernetesAgent, Cluster: "first", Clusters: tc.clusters},
+				Status: prowapi.ProwJobStatus{
+					State:           prowapi.PendingState,
+					PodName:         "job",
+					Cluster:         "first",
+					RescheduledFrom: tc.rescheduledFrom,
+				},
+			}
+			if len(tc.clusters) == 0 {
+				pj.Status.Cluster = ""
+			}
+			pjClient := fakectrlruntimeclient.NewFakeClient(pj.DeepCopy())
+			first := fakectrlruntimeclient.NewFakeClient(unschedulablePod())
+			cfg := newFakeConfigAgent(t, 0)
+			cfg.c.Plank.PodRescheduleTimeout = &metav1.Duration{Duration: time.Minute}
+			r := &reconciler{
+				pjClient: pjClient,
+				buildClients: map[string]ctrlruntimeclient.Client{
+					"first":  first,
+					"second": fakectrlruntimeclient.NewFakeClient(),
+				},
+				log:    logrus.NewEntry(logrus.StandardLogger()),
+				config: cfg.Config,
+				clock:  clock.NewFakeClock(now),
+			}
+
+			if err := r.syncPendingJob(context.Background(), pj); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := pjClient.Get(context.Background(), types.NamespacedName{Namespace: "prowjobs", Name: "job"}, pj); err != nil {
+				t.Fatalf("failed to get prowjob: %v", err)
+			}
+			if actual := pj.ClusterAlias(); actual != tc.expectedCluster {
+				t.Errorf("expected cluster %q, got %q", tc.expectedCluster, actual)
+			}
+			if !sets.NewString(pj.Status.RescheduledFrom...).Equal(sets.NewString(tc.expectedFrom...)) {
+				t.Errorf("expected to be rescheduled from %v, got %v", tc.expectedFrom, pj.Status.RescheduledFrom)
+			}
+			err := first.Get(context.Background(), types.NamespacedName{Namespace: "pods", Name: "job"}, &corev1.Pod{})
+			if deleted := kapierrors.IsNotFound(err); deleted != tc.expectPodDeleted {
+				t.Errorf("expected pod deleted to be %t, got error %v", tc.expectPodDeleted, err)
+			}
+			if pj.Complete() {
+				t.Errorf("expected job to keep running, got state %s: %s", pj.Status.State, pj.Status.Description)
+			}
+		})
+	}
+}
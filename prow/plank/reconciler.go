@@ -47,6 +47,7 @@ import (
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/pod-utils/decorate"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/version"
 )
 
@@ -131,6 +132,7 @@ type reconciler struct {
 	config             config.Getter
 	totURL             string
 	clock              clock.Clock
+	clusterHealth      clusterHealth
 	serializationLocks *shardedLock
 }
 
@@ -228,7 +230,7 @@ func (r *reconciler) reconcile(ctx context.Context, pj *prowv1.ProwJob) (*reconc
 	case prowv1.TriggeredState:
 		return r.syncTriggeredJob(ctx, pj)
 	case prowv1.AbortedState:
-		return nil, r.syncAbortedJob(ctx, pj)
+		return r.syncAbortedJob(ctx, pj)
 	}
 
 	return nil, nil
@@ -341,6 +343,18 @@ func (r *reconciler) syncPendingJob(ctx context.Context, pj *prowv1.ProwJob) err
 		case corev1.PodPending:
 			maxPodPending := r.config().Plank.PodPendingTimeout.Duration
 			maxPodUnscheduled := r.config().Plank.PodUnscheduledTimeout.Duration
+			if since := unschedulableSince(pod); since != nil && pod.DeletionTimestamp == nil && len(r.config().Plank.CandidateClusters(pj)) > 0 &&
+				r.clock.Since(*since) >= r.config().Plank.PodRescheduleTimeout.Duration {
+				// The pod can't be scheduled in this cluster, try another candidate
+				// before the job runs into the unscheduled timeout.
+				rescheduled, err := r.reschedule(ctx, pj, pod)
+				if err != nil {
+					return err
+				}
+				if rescheduled {
+					break
+				}
+			}
 			if pod.Status.StartTime.IsZero() {
 				if time.Since(pod.CreationTimestamp.Time) >= maxPodUnscheduled {
 					// Pod is stuck in unscheduled state longer than maxPodUncheduled
@@ -382,11 +396,14 @@ func (r *reconciler) syncPendingJob(ctx context.Context, pj *prowv1.ProwJob) err
 
 			// Pod is stuck in running state longer than maxPodRunning
 			// abort the job, and talk to GitHub
-			pj.SetComplete()
 			pj.Status.State = prowv1.AbortedState
 			pj.Status.Description = "Pod running timeout."
-			if err := r.deletePod(ctx, pj); err != nil {
-				return fmt.Errorf("failed to delete pod %s/%s in cluster %s: %w", pod.Namespace, pod.Name, pj.ClusterAlias(), err)
+			wait, err := r.abortPod(ctx, pj, pod, downwardapi.AbortResultTimedOut, pj.Status.Description)
+			if err != nil {
+				return fmt.Errorf("failed to abort pod %s/%s in cluster %s: %w", pod.Namespace, pod.Name, pj.ClusterAlias(), err)
+			}
+			if wait == 0 {
+				pj.SetComplete()
 			}
 		default:
 			if pod.DeletionTimestamp == nil {
@@ -447,6 +464,21 @@ func (r *reconciler) syncPendingJob(ctx context.Context, pj *prowv1.ProwJob) err
 func (r *reconciler) syncTriggeredJob(ctx context.Context, pj *prowv1.ProwJob) (*reconcile.Result, error) {
 	prevPJ := pj.DeepCopy()
 
+	if pj.Status.Cluster == "" && len(r.config().Plank.CandidateClusters(pj)) > 0 {
+		cluster := r.selectCluster(ctx, pj, sets.NewString())
+		if cluster == "" {
+			return nil, fmt.Errorf("none of the candidate clusters %v is available", r.config().Plank.CandidateClusters(pj))
+		}
+		pj.Status.Cluster = cluster
+		// Persist the cluster before the pod is created there. Otherwise a
+		// sync after a failed update could pick another cluster, miss the
+		// pod and create a second one.
+		if err := r.pjClient.Patch(ctx, pj.DeepCopy(), ctrlruntimeclient.MergeFrom(prevPJ)); err != nil {
+			return nil, fmt.Errorf("patch prowjob cluster: %w", err)
+		}
+		prevPJ = pj.DeepCopy()
+	}
+
 	var id, pn string
 
 	pod, podExists, err := r.pod(ctx, pj)
@@ -527,25 +559,106 @@ func (r *reconciler) syncTriggeredJob(ctx context.Context, pj *prowv1.ProwJob) (
 
 // syncAbortedJob syncs jobs that got aborted because their result isn't needed anymore,
 // for example because of a new push or because a pull request got closed.
-func (r *reconciler) syncAbortedJob(ctx context.Context, pj *prowv1.ProwJob) error {
+func (r *reconciler) syncAbortedJob(ctx context.Context, pj *prowv1.ProwJob) (*reconcile.Result, error) {
+	pod, _, err := r.pod(ctx, pj)
+	if err != nil {
+		return nil, err
+	}
+	wait, err := r.abortPod(ctx, pj, pod, downwardapi.AbortResultAborted, pj.Status.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to abort pod %s in cluster %s: %w", pj.Name, pj.ClusterAlias(), err)
+	}
+	if wait > 0 {
+		// The job is only completed once its pod is gone, completed jobs
+		// are not reconciled anymore.
+		return &reconcile.Result{RequeueAfter: wait}, nil
+	}
+	if pj.Complete() {
+		return nil, nil
+	}
+
+	originalPJ := pj.DeepCopy()
+	pj.SetComplete()
+	return nil, r.pjClient.Patch(ctx, pj, ctrlruntimeclient.MergeFrom(originalPJ))
+}
 
+// abortPod deletes the pod of an aborted job. If the job enabled graceful aborts,
+// the pod is first annotated to let the pod utilities terminate the test process
+// and upload the artifacts, and only deleted once sidecar terminated or the
+// graceful abort timeout passed. It returns how long to wait for the pod before
+// trying again, or zero once the pod was deleted.
+func (r *reconciler) abortPod(ctx context.Context, pj *prowv1.ProwJob, pod *corev1.Pod, result, reason string) (time.Duration, error) {
+	if pod == nil {
+		return 0, nil
+	}
 	buildClient, ok := r.buildClients[pj.ClusterAlias()]
 	if !ok {
-		return fmt.Errorf("no build client available for cluster %s", pj.ClusterAlias())
+		return 0, TerminalError(fmt.Errorf("no build client found for cluster %q", pj.ClusterAlias()))
+	}
+	log := r.log.WithFields(pjutil.ProwJobFields(pj))
+
+	if timeout := gracefulAbortTimeout(pj, pod); timeout > 0 {
+		abortTime, annotated := pod.Annotations[downwardapi.AbortTimeAnnotation]
+		if !annotated {
+			originalPod := pod.DeepCopy()
+			if pod.Annotations == nil {
+				pod.Annotations = map[string]string{}
+			}
+			pod.Annotations[downwardapi.AbortAnnotation] = result
+			pod.Annotations[downwardapi.AbortReasonAnnotation] = reason
+			pod.Annotations[downwardapi.AbortTimeAnnotation] = r.clock.Now().Format(time.RFC3339)
+			if err := buildClient.Patch(ctx, pod, ctrlruntimeclient.MergeFrom(originalPod)); err != nil {
+				return 0, ctrlruntimeclient.IgnoreNotFound(err)
+			}
+			log.Info("Asked the pod to abort gracefully.")
+			return timeout, nil
+		}
+
+		if sidecar := sidecarStatus(pod); sidecar != nil && sidecar.State.Terminated != nil {
+			if sidecar.State.Terminated.Message == downwardapi.UploadCompleteMessage {
+				log.Info("Sidecar uploaded the artifacts of the aborted pod.")
+			} else {
+				log.Warn("Sidecar of the aborted pod terminated without uploading the artifacts.")
+			}
+		} else if requested, err := time.Parse(time.RFC3339, abortTime); err != nil {
+			log.WithError(err).Warnf("Invalid %s annotation, deleting the pod.", downwardapi.AbortTimeAnnotation)
+		} else if remaining := requested.Add(timeout).Sub(r.clock.Now()); remaining > 0 {
+			return remaining, nil
+		} else {
+			log.Warn("Pod did not abort gracefully in time, deleting it.")
+		}
 	}
 
-	// Just optimistically delete and swallow the potential 404
-	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
-		Name:      pj.Name,
-		Namespace: r.config().PodNamespace,
-	}}
 	if err := ctrlruntimeclient.IgnoreNotFound(buildClient.Delete(ctx, pod)); err != nil {
-		return fmt.Errorf("failed to delete pod %s/%s in cluster %s: %w", pod.Namespace, pod.Name, pj.ClusterAlias(), err)
+		return 0, fmt.Errorf("failed to delete pod: %w", err)
 	}
+	return 0, nil
+}
 
-	originalPJ := pj.DeepCopy()
-	pj.SetComplete()
-	return r.pjClient.Patch(ctx, pj, ctrlruntimeclient.MergeFrom(originalPJ))
+// gracefulAbortTimeout returns how long to wait for the pod of an aborted job
+// to upload its artifacts, or zero if it should be deleted right away because
+// graceful aborts are disabled or there is nothing left to abort.
+func gracefulAbortTimeout(pj *prowv1.ProwJob, pod *corev1.Pod) time.Duration {
+	if pj.Spec.DecorationConfig == nil || pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return 0
+	}
+	// Pods created before graceful aborts were enabled can not see their annotations.
+	_, podAnnotations := decorate.PodAnnotationsMountAndVolume()
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == podAnnotations.Name {
+			return pj.Spec.DecorationConfig.GracefulAbortTimeout.Get()
+		}
+	}
+	return 0
+}
+
+func sidecarStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+	for i, status := range pod.Status.ContainerStatuses {
+		if status.Name == decorate.SidecarName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return nil
 }
 
 // pod Gets pod for a pj, returns pod, whether pod exist, and error.
@@ -614,6 +727,10 @@ func (r *reconciler) startPod(ctx context.Context, pj *prowv1.ProwJob) (string,
 	err = client.Create(ctx, pod)
 	r.log.WithFields(pjutil.ProwJobFields(pj)).Debug("Create Pod.")
 	if err != nil {
+		if !isRequestError(err) {
+			// Avoid this cluster when picking one for jobs with candidate clusters
+			r.clusterHealth.markFailed(pj.ClusterAlias(), r.clock.Now())
+		}
 		return "", "", err
 	}
 
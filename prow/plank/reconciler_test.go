@@ -31,6 +31,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/rest"
 	toolscache "k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -45,6 +46,8 @@ import (
 
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/pod-utils/decorate"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 )
 
 func TestAdd(t *testing.T) {
@@ -490,3 +493,176 @@ func TestStartPodBlocksUntilItHasThePodInCache(t *testing.T) {
 		t.Errorf("couldn't get pod, this likely means startPod didn't block: %v", err)
 	}
 }
+
+func TestSyncAbortedJobGracefully(t *testing.T) {
+	now := time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)
+	_, podAnnotationsVolume := decorate.PodAnnotationsMountAndVolume()
+	abortedPod := func(abortTime time.Time) map[string]string {
+		return map[string]string{
+			downwardapi.AbortAnnotation:       downwardapi.AbortResultAborted,
+			downwardapi.AbortReasonAnnotation: "Aborted by trigger plugin.",
+			downwardapi.AbortTimeAnnotation:   abortTime.Format(time.RFC3339),
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		annotations         map[string]string
+		volumes             []corev1.Volume
+		sidecar             corev1.ContainerState
+		expectedAnnotations map[string]string
+		expectedRequeue     time.Duration
+		expectDelete        bool
+	}{
+		{
+			name:                "running pod is asked to abort",
+			volumes:             []corev1.Volume{podAnnotationsVolume},
+			sidecar:             corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			expectedAnnotations: abortedPod(now),
+			expectedRequeue:     5 * time.Minute,
+		},
+		{
+			name:                "pod is given time to upload",
+			annotations:         abortedPod(now.Add(-time.Minute)),
+			volumes:             []corev1.Volume{podAnnotationsVolume},
+			sidecar:             corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			expectedAnnotations: abortedPod(now.Add(-time.Minute)),
+			expectedRequeue:     4 * time.Minute,
+		},
+		{
+			name:         "pod is deleted once sidecar uploaded the artifacts",
+			annotations:  abortedPod(now.Add(-time.Minute)),
+			volumes:      []corev1.Volume{podAnnotationsVolume},
+			sidecar:      corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: downwardapi.UploadCompleteMessage}},
+			expectDelete: true,
+		},
+		{
+			name:         "pod is deleted once the timeout passed",
+			annotations:  abortedPod(now.Add(-5 * time.Minute)),
+			volumes:      []corev1.Volume{podAnnotationsVolume},
+			sidecar:      corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			expectDelete: true,
+		},
+		{
+			name:         "pod that can not see its annotations is deleted right away",
+			sidecar:      corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			expectDelete: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pj := &prowv1.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pj"},
+				Spec: prowv1.ProwJobSpec{
+					Cluster: "cluster",
+					DecorationConfig: &prowv1.DecorationConfig{
+						GracefulAbortTimeout: &prowv1.Duration{Duration: 5 * time.Minute},
+					},
+				},
+				Status: prowv1.ProwJobStatus{
+					State:       prowv1.AbortedState,
+					Description: "Aborted by trigger plugin.",
+				},
+			}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pj", Namespace: "pods", Annotations: tc.annotations},
+				Spec:       corev1.PodSpec{Volumes: tc.volumes},
+				Status: corev1.PodStatus{
+					Phase:             corev1.PodRunning,
+					ContainerStatuses: []corev1.ContainerStatus{{Name: decorate.SidecarName, State: tc.sidecar}},
+				},
+			}
+			podClient := &deleteTrackingFakeClient{Client: fakectrlruntimeclient.NewFakeClient(pod)}
+			pjClient := fakectrlruntimeclient.NewFakeClient(pj.DeepCopy())
+			r := &reconciler{
+				log:          logrus.NewEntry(logrus.StandardLogger()),
+				config:       func() *config.Config { return &config.Config{ProwConfig: config.ProwConfig{PodNamespace: "pods"}} },
+				pjClient:     pjClient,
+				buildClients: map[string]ctrlruntimeclient.Client{"cluster": podClient},
+				clock:        clock.NewFakeClock(now),
+			}
+
+			res, err := r.syncAbortedJob(context.Background(), pj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var requeue time.Duration
+			if res != nil {
+				requeue = res.RequeueAfter
+			}
+			if requeue != tc.expectedRequeue {
+				t.Errorf("expected to requeue after %s, got %s", tc.expectedRequeue, requeue)
+			}
+			if deleted := podClient.deleted.Has(pod.Name); deleted != tc.expectDelete {
+				t.Errorf("expected delete: %t, got delete: %t", tc.expectDelete, deleted)
+			}
+
+			if err := pjClient.Get(context.Background(), types.NamespacedName{Name: pj.Name}, pj); err != nil {
+				t.Fatalf("failed to get job from client: %v", err)
+			}
+			if pj.Complete() != tc.expectDelete {
+				t.Errorf("expected complete: %t, got complete: %t", tc.expectDelete, pj.Complete())
+			}
+			if tc.expectDelete {
+				return
+			}
+			if err := podClient.Get(context.Background(), types.NamespacedName{Namespace: "pods", Name: pod.Name}, pod); err != nil {
+				t.Fatalf("failed to get pod from client: %v", err)
+			}
+			if diff := deep.Equal(pod.Annotations, tc.expectedAnnotations); diff != nil {
+				t.Errorf("unexpected pod annotations: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSyncPendingJobTimesOutGracefully(t *testing.T) {
+	now := time.Now()
+	_, podAnnotationsVolume := decorate.PodAnnotationsMountAndVolume()
+	pj := &prowv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "endless", Namespace: "prowjobs"},
+		Spec: prowv1.ProwJobSpec{
+			DecorationConfig: &prowv1.DecorationConfig{
+				GracefulAbortTimeout: &prowv1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+		Status: prowv1.ProwJobStatus{State: prowv1.PendingState, PodName: "endless"},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "endless", Namespace: "pods"},
+		Spec:       corev1.PodSpec{Volumes: []corev1.Volume{podAnnotationsVolume}},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &metav1.Time{Time: now.Add(-podRunningTimeout)},
+		},
+	}
+	podClient := &deleteTrackingFakeClient{Client: fakectrlruntimeclient.NewFakeClient(pod)}
+	pjClient := fakectrlruntimeclient.NewFakeClient(pj.DeepCopy())
+	r := &reconciler{
+		log:          logrus.NewEntry(logrus.StandardLogger()),
+		config:       newFakeConfigAgent(t, 0).Config,
+		pjClient:     pjClient,
+		buildClients: map[string]ctrlruntimeclient.Client{prowv1.DefaultClusterAlias: podClient},
+		clock:        clock.NewFakeClock(now),
+	}
+
+	if err := r.syncPendingJob(context.Background(), pj); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pjClient.Get(context.Background(), types.NamespacedName{Namespace: "prowjobs", Name: pj.Name}, pj); err != nil {
+		t.Fatalf("failed to get job from client: %v", err)
+	}
+	if pj.Status.State != prowv1.AbortedState || pj.Complete() {
+		t.Errorf("expected an aborted job waiting for its pod, got state %s, complete: %t", pj.Status.State, pj.Complete())
+	}
+	if podClient.deleted.Has(pod.Name) {
+		t.Error("expected the pod to be kept until it uploaded the artifacts")
+	}
+	if err := podClient.Get(context.Background(), types.NamespacedName{Namespace: "pods", Name: pod.Name}, pod); err != nil {
+		t.Fatalf("failed to get pod from client: %v", err)
+	}
+	if result := pod.Annotations[downwardapi.AbortAnnotation]; result != downwardapi.AbortResultTimedOut {
+		t.Errorf("expected the pod to be asked to abort as %q, got %q", downwardapi.AbortResultTimedOut, result)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package approve
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -68,6 +69,8 @@ type githubClient interface {
 	AddLabel(org, repo string, number int, label string) error
 	RemoveLabel(org, repo string, number int, label string) error
 	ListIssueEvents(org, repo string, num int) ([]github.ListedIssueEvent, error)
+	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
+	GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error)
 }
 
 type ownersClient interface {
@@ -84,6 +87,7 @@ type state struct {
 	author    string
 	assignees []github.User
 	htmlURL   string
+	headSHA   string
 }
 
 func init() {
@@ -109,7 +113,7 @@ func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo)
 	approveConfig := map[string]string{}
 	for _, repo := range enabledRepos {
 		opts := config.ApproveFor(repo.Org, repo.Repo)
-		approveConfig[repo.String()] = fmt.Sprintf("Pull requests %s require an associated issue.<br>Pull request authors %s implicitly approve their own PRs.<br>The /lgtm [cancel] command(s) %s act as approval.<br>A GitHub approved or changes requested review %s act as approval or cancel respectively.", doNot(opts.IssueRequired), doNot(opts.HasSelfApproval()), willNot(opts.LgtmActsAsApprove), willNot(opts.ConsiderReviewState()))
+		approveConfig[repo.String()] = fmt.Sprintf("Pull requests %s require an associated issue.<br>Pull request authors %s implicitly approve their own PRs.<br>The /lgtm [cancel] command(s) %s act as approval.<br>A GitHub approved or changes requested review %s act as approval or cancel respectively.<br>Approvals %s expire when later commits change files the approver covers.<br>The required reviewers of OWNERS files %s have to approve.", doNot(opts.IssueRequired), doNot(opts.HasSelfApproval()), willNot(opts.LgtmActsAsApprove), willNot(opts.ConsiderReviewState()), willNot(opts.ExpireApprovalsOnChange), doNot(opts.EnforceRequiredReviewers))
 	}
 
 	yamlSnippet, err := plugins.CommentMap.GenYaml(&plugins.Configuration{
@@ -205,6 +209,7 @@ func handleGenericComment(log *logrus.Entry, ghc githubClient, oc ownersClient,
 			author:    ce.IssueAuthor.Login,
 			assignees: ce.Assignees,
 			htmlURL:   ce.IssueHTMLURL,
+			headSHA:   pr.Head.SHA,
 		},
 	)
 }
@@ -275,6 +280,7 @@ func handleReview(log *logrus.Entry, ghc githubClient, oc ownersClient, githubCo
 			author:    re.PullRequest.User.Login,
 			assignees: re.PullRequest.Assignees,
 			htmlURL:   re.PullRequest.HTMLURL,
+			headSHA:   re.PullRequest.Head.SHA,
 		},
 	)
 
@@ -334,6 +340,7 @@ func handlePullRequest(log *logrus.Entry, ghc githubClient, oc ownersClient, git
 			author:    pre.PullRequest.User.Login,
 			assignees: pre.PullRequest.Assignees,
 			htmlURL:   pre.PullRequest.HTMLURL,
+			headSHA:   pre.PullRequest.Head.SHA,
 		},
 	)
 }
@@ -432,6 +439,7 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 		log.WithError(err).Errorf("Failed to find associated issue from PR body: %v", err)
 	}
 	approversHandler.RequireIssue = opts.IssueRequired
+	approversHandler.RequireReviewers = opts.EnforceRequiredReviewers
 	approversHandler.ManuallyApproved = humanAddedApproved(ghc, log, pr.org, pr.repo, pr.number, botUserChecker, hasApprovedLabel)
 
 	// Author implicitly approves their own PR if config allows it
@@ -451,6 +459,15 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
 	})
 	approveComments := filterComments(comments, approvalMatcher(botUserChecker, opts.LgtmActsAsApprove, opts.ConsiderReviewState()))
+	notifications := filterComments(commentsFromIssueComments, notificationMatcher(botUserChecker))
+	latestNotification := getLast(notifications)
+	var approvalHeads map[string]string
+	if opts.ExpireApprovalsOnChange {
+		approveComments, approvalHeads, err = expireApprovals(ghc, repo, pr, approveComments, parseApprovalHeads(latestNotification))
+		if err != nil {
+			return err
+		}
+	}
 	addApprovers(&approversHandler, approveComments, pr.author, opts.ConsiderReviewState())
 	log.WithField("duration", time.Since(start).String()).Debug("Completed filtering approval comments in handle")
 
@@ -459,9 +476,7 @@ func handle(log *logrus.Entry, ghc githubClient, repo approvers.Repo, githubConf
 	}
 
 	start = time.Now()
-	notifications := filterComments(commentsFromIssueComments, notificationMatcher(botUserChecker))
-	latestNotification := getLast(notifications)
-	newMessage := updateNotification(githubConfig.LinkURL, opts.CommandHelpLink, opts.PrProcessLink, pr.org, pr.repo, pr.branch, latestNotification, approversHandler)
+	newMessage := updateNotification(githubConfig.LinkURL, opts.CommandHelpLink, opts.PrProcessLink, pr.org, pr.repo, pr.branch, latestNotification, approversHandler, approvalHeads)
 	log.WithField("duration", time.Since(start).String()).Debug("Completed getting notifications in handle")
 	start = time.Now()
 	if newMessage != nil {
@@ -580,14 +595,132 @@ func notificationMatcher(isBot func(string) bool) func(*comment) bool {
 	}
 }
 
-func updateNotification(linkURL *url.URL, commandHelpLink, prProcessLink, org, repo, branch string, latestNotification *comment, approversHandler approvers.Approvers) *string {
+func updateNotification(linkURL *url.URL, commandHelpLink, prProcessLink, org, repo, branch string, latestNotification *comment, approversHandler approvers.Approvers, approvalHeads map[string]string) *string {
 	message := approvers.GetMessage(approversHandler, linkURL, commandHelpLink, prProcessLink, org, repo, branch)
+	if message != nil && approvalHeads != nil {
+		*message += formatApprovalHeads(approvalHeads)
+	}
 	if message == nil || (latestNotification != nil && strings.Contains(latestNotification.Body, *message)) {
 		return nil
 	}
 	return message
 }
 
+// expiredHead marks approvals that expired in the approval heads.
+const expiredHead = "expired"
+
+// approvalHeadsRegex finds the approval heads in a notification.
+var approvalHeadsRegex = regexp.MustCompile(`<!-- APPROVAL_HEADS=(\{.*?\}) -->`)
+
+// approvalKey identifies an approval comment or review in the approval heads.
+func approvalKey(c *comment) string {
+	if i := strings.LastIndex(c.HTMLURL, "#"); i >= 0 {
+		return c.HTMLURL[i+1:]
+	}
+	return strconv.Itoa(c.ID)
+}
+
+// parseApprovalHeads reads the head of the PR at which each approval was
+// first seen from the notification, as commit dates are set by the PR author
+// and cannot be trusted to tell whether a commit came after an approval.
+func parseApprovalHeads(notification *comment) map[string]string {
+	heads := map[string]string{}
+	if notification == nil {
+		return heads
+	}
+	if match := approvalHeadsRegex.FindStringSubmatch(notification.Body); match != nil {
+		if err := json.Unmarshal([]byte(match[1]), &heads); err != nil {
+			return map[string]string{}
+		}
+	}
+	return heads
+}
+
+func formatApprovalHeads(heads map[string]string) string {
+	raw, err := json.Marshal(heads)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\n<!-- APPROVAL_HEADS=%s -->", raw)
+}
+
+// expireApprovals drops the approval comments whose author can approve a file
+// that changed since the head of the PR at which the approval was given. It
+// returns the remaining approvals and the heads to record for all approvals.
+// Approvals given at a head that is no longer part of the PR, e.g. after a
+// rebase, expire as well.
+func expireApprovals(ghc githubClient, repo approvers.Repo, pr *state, approveComments []*comment, heads map[string]string) ([]*comment, map[string]string, error) {
+	var commits []github.RepositoryCommit
+	files := map[string][]string{}
+	// changedSince returns the files changed by the commits after sha, or
+	// false if sha is not a commit of the PR.
+	changedSince := func(sha string) ([]string, bool, error) {
+		if commits == nil {
+			var err error
+			if commits, err = ghc.ListPRCommits(pr.org, pr.repo, pr.number); err != nil {
+				return nil, false, fmt.Errorf("failed to list commits for %s/%s#%d: %v", pr.org, pr.repo, pr.number, err)
+			}
+		}
+		var changed []string
+		found := false
+		for _, commit := range commits {
+			if !found {
+				found = commit.SHA == sha
+				continue
+			}
+			if _, ok := files[commit.SHA]; !ok {
+				// The commits listing does not include the files.
+				full, err := ghc.GetSingleCommit(pr.org, pr.repo, commit.SHA)
+				if err != nil {
+					return nil, false, fmt.Errorf("failed to get commit %s for %s/%s#%d: %v", commit.SHA, pr.org, pr.repo, pr.number, err)
+				}
+				for _, file := range full.Files {
+					files[commit.SHA] = append(files[commit.SHA], file.Filename)
+				}
+			}
+			changed = append(changed, files[commit.SHA]...)
+		}
+		return changed, found, nil
+	}
+
+	var current []*comment
+	next := map[string]string{}
+	for _, c := range approveComments {
+		key := approvalKey(c)
+		head, seen := heads[key]
+		switch {
+		case !seen || head == pr.headSHA:
+			next[key] = pr.headSHA
+			current = append(current, c)
+			continue
+		case head == expiredHead:
+			next[key] = expiredHead
+			continue
+		}
+		changed, found, err := changedSince(head)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !found || approvesAny(repo, github.NormLogin(c.Author), changed) {
+			next[key] = expiredHead
+			continue
+		}
+		next[key] = pr.headSHA
+		current = append(current, c)
+	}
+	return current, next, nil
+}
+
+// approvesAny checks whether the approver can approve any of the files.
+func approvesAny(repo approvers.Repo, approver string, files []string) bool {
+	for _, file := range files {
+		if repo.Approvers(file).Has(approver) {
+			return true
+		}
+	}
+	return false
+}
+
 // addApprovers iterates through the list of comments on a PR
 // and identifies all of the people that have said /approve and adds
 // them to the Approvers.  The function uses the latest approve or cancel comment
@@ -138,6 +138,10 @@ type fakeRepo struct {
 	// dir -> allowed
 	autoApproveUnownedSubfolders map[string]bool
 	dirBlacklist                 []*regexp.Regexp
+	// file -> approvals
+	requiredApprovals map[string]int
+	// file -> required reviewers
+	requiredReviewers map[string]sets.String
 }
 
 func (fr fakeRepo) Filenames() ownersconfig.Filenames {
@@ -162,6 +166,15 @@ func (fr fakeRepo) IsAutoApproveUnownedSubfolders(ownerFilePath string) bool {
 func (fr fakeRepo) TopLevelApprovers() sets.String {
 	return nil
 }
+func (fr fakeRepo) RequiredApprovals(path string) int {
+	if n, ok := fr.requiredApprovals[path]; ok {
+		return n
+	}
+	return 1
+}
+func (fr fakeRepo) RequiredReviewers(path string) sets.String {
+	return fr.requiredReviewers[path]
+}
 
 func (fr fakeRepo) ParseSimpleConfig(path string) (repoowners.SimpleConfig, error) {
 	dir := filepath.Dir(path)
@@ -1223,6 +1236,108 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 	}
 }
 
+func TestExpireApprovals(t *testing.T) {
+	fr := fakeRepo{
+		approvers: map[string]layeredsets.String{
+			"a/a.go":   layeredsets.NewString("alice"),
+			"a/b/b.go": layeredsets.NewString("alice", "bob"),
+			"c/c.go":   layeredsets.NewString("cjwagner"),
+		},
+	}
+	approveComments := []*comment{
+		{Author: "Alice", Body: "/approve", HTMLURL: "https://github.com/org/repo/pull/1#issuecomment-1"},
+		{Author: "bob", Body: "/approve", HTMLURL: "https://github.com/org/repo/pull/1#pullrequestreview-2"},
+		{Author: "cjwagner", Body: "/approve", HTMLURL: "https://github.com/org/repo/pull/1#issuecomment-3"},
+	}
+	commit := func(sha string, files ...string) github.RepositoryCommit {
+		c := github.RepositoryCommit{SHA: sha}
+		for _, file := range files {
+			c.Files = append(c.Files, github.CommitFile{Filename: file})
+		}
+		return c
+	}
+	commits := []github.RepositoryCommit{
+		commit("1", "a/a.go", "a/b/b.go", "c/c.go"),
+		commit("2", "a/a.go"),
+		commit("3", "c/c.go"),
+	}
+
+	tests := []struct {
+		name          string
+		heads         map[string]string
+		expected      []string
+		expectedHeads map[string]string
+	}{
+		{
+			name:          "approvals seen for the first time are given at the head",
+			heads:         map[string]string{},
+			expected:      []string{"Alice", "bob", "cjwagner"},
+			expectedHeads: map[string]string{"issuecomment-1": "3", "pullrequestreview-2": "3", "issuecomment-3": "3"},
+		},
+		{
+			name:          "approvals given at the head stay",
+			heads:         map[string]string{"issuecomment-1": "3", "pullrequestreview-2": "3", "issuecomment-3": "3"},
+			expected:      []string{"Alice", "bob", "cjwagner"},
+			expectedHeads: map[string]string{"issuecomment-1": "3", "pullrequestreview-2": "3", "issuecomment-3": "3"},
+		},
+		{
+			name:          "later commits only expire approvals of the files they change",
+			heads:         map[string]string{"issuecomment-1": "1", "pullrequestreview-2": "1", "issuecomment-3": "2"},
+			expected:      []string{"bob"},
+			expectedHeads: map[string]string{"issuecomment-1": expiredHead, "pullrequestreview-2": "3", "issuecomment-3": expiredHead},
+		},
+		{
+			name:          "expired approvals stay expired",
+			heads:         map[string]string{"issuecomment-1": expiredHead, "pullrequestreview-2": "3", "issuecomment-3": "3"},
+			expected:      []string{"bob", "cjwagner"},
+			expectedHeads: map[string]string{"issuecomment-1": expiredHead, "pullrequestreview-2": "3", "issuecomment-3": "3"},
+		},
+		{
+			name:          "approvals given at a head that is no longer part of the PR expire",
+			heads:         map[string]string{"issuecomment-1": "3", "pullrequestreview-2": "rebased", "issuecomment-3": "3"},
+			expected:      []string{"Alice", "cjwagner"},
+			expectedHeads: map[string]string{"issuecomment-1": "3", "pullrequestreview-2": expiredHead, "issuecomment-3": "3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fghc := fakegithub.NewFakeClient()
+			fghc.CommitMap = map[string][]github.RepositoryCommit{}
+			fghc.Commits = map[string]github.RepositoryCommit{}
+			for _, c := range commits {
+				fghc.CommitMap["org/repo#1"] = append(fghc.CommitMap["org/repo#1"], github.RepositoryCommit{SHA: c.SHA})
+				fghc.Commits[c.SHA] = c
+			}
+			current, heads, err := expireApprovals(fghc, fr, &state{org: "org", repo: "repo", number: prNumber, headSHA: "3"}, approveComments, test.heads)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var authors []string
+			for _, c := range current {
+				authors = append(authors, c.Author)
+			}
+			if diff := cmp.Diff(test.expected, authors); diff != "" {
+				t.Errorf("unexpected approvals left: %s", diff)
+			}
+			if diff := cmp.Diff(test.expectedHeads, heads); diff != "" {
+				t.Errorf("unexpected approval heads: %s", diff)
+			}
+		})
+	}
+}
+
+func TestApprovalHeadsRoundTrip(t *testing.T) {
+	heads := map[string]string{"issuecomment-1": "abc", "pullrequestreview-2": expiredHead}
+	notification := &comment{Body: "[APPROVALNOTIFIER] This PR is **APPROVED**\n" + formatApprovalHeads(heads)}
+	if diff := cmp.Diff(heads, parseApprovalHeads(notification)); diff != "" {
+		t.Errorf("unexpected approval heads: %s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{}, parseApprovalHeads(nil)); diff != "" {
+		t.Errorf("unexpected approval heads without notification: %s", diff)
+	}
+}
+
 // TODO: cache approvers 'GetFilesApprovers' and 'GetCCs' since these are called repeatedly and are
 // expensive.
 
@@ -1252,10 +1367,6 @@ func (fro fakeRepoOwners) Reviewers(path string) layeredsets.String {
 	return layeredsets.NewString()
 }
 
-func (fro fakeRepoOwners) RequiredReviewers(path string) sets.String {
-	return sets.NewString()
-}
-
 func TestHandleGenericComment(t *testing.T) {
 	tests := []struct {
 		name              string
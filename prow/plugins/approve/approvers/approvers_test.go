@@ -120,6 +120,109 @@ func TestUnapprovedFiles(t *testing.T) {
 	}
 }
 
+func TestUnapprovedFilesWithRequirements(t *testing.T) {
+	FakeRepoMap := map[string]sets.String{
+		"":  sets.NewString("Alice", "Bob"),
+		"a": sets.NewString("Art", "Anne"),
+		"b": sets.NewString("Bill", "Ben"),
+	}
+	tests := []struct {
+		testName           string
+		filenames          []string
+		requiredApprovals  map[string]int
+		requiredReviewers  map[string]sets.String
+		requireReviewers   bool
+		currentlyApproved  sets.String
+		expectedUnapproved sets.String
+	}{
+		{
+			testName:           "Two approvals required, one given",
+			filenames:          []string{"a/secret.go", "b/test.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			currentlyApproved:  sets.NewString("Art", "Bill"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Two approvals required, two given",
+			filenames:          []string{"a/secret.go", "b/test.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			currentlyApproved:  sets.NewString("Art", "Anne", "Bill"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Two approvals required, one from a parent",
+			filenames:          []string{"a/secret.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			currentlyApproved:  sets.NewString("Art", "Alice"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Two approvals required, only approved in another directory",
+			filenames:          []string{"a/secret.go", "b/test.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			currentlyApproved:  sets.NewString("Art", "Bill", "Ben"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Required reviewer missing",
+			filenames:          []string{"a/secret.go"},
+			requiredReviewers:  map[string]sets.String{"a/secret.go": sets.NewString("anne")},
+			requireReviewers:   true,
+			currentlyApproved:  sets.NewString("Art"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Required reviewer approved",
+			filenames:          []string{"a/secret.go"},
+			requiredReviewers:  map[string]sets.String{"a/secret.go": sets.NewString("anne")},
+			requireReviewers:   true,
+			currentlyApproved:  sets.NewString("Anne"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Two approvals and a required reviewer, reviewer missing",
+			filenames:          []string{"a/secret.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			requiredReviewers:  map[string]sets.String{"a/secret.go": sets.NewString("anne")},
+			requireReviewers:   true,
+			currentlyApproved:  sets.NewString("Art", "Alice"),
+			expectedUnapproved: sets.NewString("a"),
+		},
+		{
+			testName:           "Required reviewer missing but not required",
+			filenames:          []string{"a/secret.go"},
+			requiredReviewers:  map[string]sets.String{"a/secret.go": sets.NewString("anne")},
+			currentlyApproved:  sets.NewString("Art"),
+			expectedUnapproved: sets.NewString(),
+		},
+		{
+			testName:           "Two approvals and a required reviewer, all given",
+			filenames:          []string{"a/secret.go"},
+			requiredApprovals:  map[string]int{"a/secret.go": 2},
+			requiredReviewers:  map[string]sets.String{"a/secret.go": sets.NewString("anne")},
+			requireReviewers:   true,
+			currentlyApproved:  sets.NewString("Art", "Anne"),
+			expectedUnapproved: sets.NewString(),
+		},
+	}
+
+	for _, test := range tests {
+		repo := createFakeRepo(FakeRepoMap, func(fr *FakeRepo) {
+			fr.requiredApprovalsMap = test.requiredApprovals
+			fr.requiredReviewersMap = test.requiredReviewers
+		})
+		testApprovers := NewApprovers(Owners{filenames: test.filenames, repo: repo, seed: TestSeed, log: logrus.WithField("plugin", "some_plugin")})
+		testApprovers.RequireReviewers = test.requireReviewers
+		for approver := range test.currentlyApproved {
+			testApprovers.AddApprover(approver, "REFERENCE", false)
+		}
+		calculated := testApprovers.UnapprovedFiles()
+		if !test.expectedUnapproved.Equal(calculated) {
+			t.Errorf("Failed for test %v.  Expected unapproved files: %v. Found %v", test.testName, test.expectedUnapproved, calculated)
+		}
+	}
+}
+
 func TestGetFiles(t *testing.T) {
 	rootApprovers := sets.NewString("Alice", "Bob")
 	aApprovers := sets.NewString("Art", "Anne")
@@ -158,7 +261,7 @@ func TestGetFiles(t *testing.T) {
 			testName:          "Single File PR in B No One Approved",
 			filenames:         []string{"b/test.go"},
 			currentlyApproved: sets.NewString(),
-			expectedFiles:     []File{UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "b", ownersconfig.DefaultOwnersFile, "master"}},
+			expectedFiles:     []File{UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "b", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"}},
 		},
 		{
 			testName:          "Single File PR in B Fully Approved",
@@ -170,15 +273,15 @@ func TestGetFiles(t *testing.T) {
 			testName:          "Single Root File PR No One Approved",
 			filenames:         []string{"kubernetes.go"},
 			currentlyApproved: sets.NewString(),
-			expectedFiles:     []File{UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "", ownersconfig.DefaultOwnersFile, "master"}},
+			expectedFiles:     []File{UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"}},
 		},
 		{
 			testName:          "Combo and Other; Neither Approved",
 			filenames:         []string{"a/combo/test.go", "a/d/test.go"},
 			currentlyApproved: sets.NewString(),
 			expectedFiles: []File{
-				UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/combo", ownersconfig.DefaultOwnersFile, "master"},
-				UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/d", ownersconfig.DefaultOwnersFile, "master"},
+				UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "a/combo", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"},
+				UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "a/d", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"},
 			},
 		},
 		{
@@ -187,7 +290,7 @@ func TestGetFiles(t *testing.T) {
 			currentlyApproved: eApprovers,
 			expectedFiles: []File{
 				ApprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/combo", ownersconfig.DefaultOwnersFile, eApprovers, "master"},
-				UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/d", ownersconfig.DefaultOwnersFile, "master"},
+				UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "a/d", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"},
 			},
 		},
 		{
@@ -205,7 +308,7 @@ func TestGetFiles(t *testing.T) {
 			currentlyApproved: cApprovers,
 			expectedFiles: []File{
 				ApprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/combo", ownersconfig.DefaultOwnersFile, cApprovers, "master"},
-				UnapprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "a/d", ownersconfig.DefaultOwnersFile, "master"},
+				UnapprovedFile{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, filepath: "a/d", ownersFilename: ownersconfig.DefaultOwnersFile, branch: "master"},
 				ApprovedFile{&url.URL{Scheme: "https", Host: "github.com", Path: "org/repo"}, "c", ownersconfig.DefaultOwnersFile, cApprovers, "master"},
 			},
 		},
@@ -1035,3 +1138,46 @@ Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a commen
 		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
 	}
 }
+
+func TestGetMessageRequiredApprovals(t *testing.T) {
+	ap := NewApprovers(
+		Owners{
+			filenames: []string{"a/a.go", "b/b.go"},
+			repo: createFakeRepo(map[string]sets.String{
+				"a": sets.NewString("Alice", "Anne"),
+				"b": sets.NewString("Bill", "Ben"),
+			}, func(fr *FakeRepo) {
+				fr.requiredApprovalsMap = map[string]int{"a/a.go": 2}
+				fr.requiredReviewersMap = map[string]sets.String{"b/b.go": sets.NewString("ben")}
+			}),
+			log: logrus.WithField("plugin", "some_plugin"),
+		},
+	)
+	ap.RequireReviewers = true
+	ap.AddApprover("Alice", "REFERENCE", false)
+	ap.AddApprover("Bill", "REFERENCE", false)
+
+	want := `[APPROVALNOTIFIER] This PR is **NOT APPROVED**
+
+This pull-request has been approved by: *<a href="REFERENCE" title="Approved">Alice</a>*, *<a href="REFERENCE" title="Approved">Bill</a>*
+To complete the [pull request process](https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process), please assign **anne**, **ben** after the PR has been reviewed.
+You can assign the PR to them by writing ` + "`/assign @anne @ben`" + ` in a comment when ready.
+
+The full list of commands accepted by this bot can be found [here](https://go.k8s.io/bot-commands?repo=org%2Frepo).
+
+<details open>
+Needs approval from an approver in each of these files:
+
+- **[a/OWNERS](https://github.com/org/repo/blob/dev/a/OWNERS)** (1 of 2 approvals)
+- **[b/OWNERS](https://github.com/org/repo/blob/dev/b/OWNERS)** (approval from one of ben)
+
+Approvers can indicate their approval by writing ` + "`/approve`" + ` in a comment
+Approvers can cancel approval by writing ` + "`/approve cancel`" + ` in a comment
+</details>
+<!-- META={"approvers":["anne","ben"]} -->`
+	if got := GetMessage(ap, &url.URL{Scheme: "https", Host: "github.com"}, "https://go.k8s.io/bot-commands", "https://git.k8s.io/community/contributors/guide/owners.md#the-code-review-process", "org", "repo", "dev"); got == nil {
+		t.Error("GetMessage() failed")
+	} else if *got != want {
+		t.Errorf("GetMessage() = %+v, want = %+v", *got, want)
+	}
+}
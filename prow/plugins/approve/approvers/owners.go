@@ -47,6 +47,8 @@ type Repo interface {
 	FindApproverOwnersForFile(file string) string
 	IsNoParentOwners(path string) bool
 	IsAutoApproveUnownedSubfolders(directory string) bool
+	RequiredApprovals(path string) int
+	RequiredReviewers(path string) sets.String
 	Filenames() ownersconfig.Filenames
 }
 
@@ -92,6 +94,64 @@ func (o Owners) GetLeafApprovers() map[string]sets.String {
 	return ownersToApprovers
 }
 
+// filesByOwnersFile returns a map from ownersFiles -> files of the change
+// that need approval from them
+func (o Owners) filesByOwnersFile() map[string][]string {
+	ownersSet := o.GetOwnersSet()
+	byOwnersFile := map[string][]string{}
+	for _, file := range o.filenames {
+		dir := o.repo.FindApproverOwnersForFile(file)
+		if strings.Contains(filepath.Dir(filepath.Dir(file)), dir) && o.repo.IsAutoApproveUnownedSubfolders(dir) {
+			continue
+		}
+		// Subdirs are removed from the owners set in favor of their parents
+		for {
+			if ownersSet.Has(dir) {
+				byOwnersFile[dir] = append(byOwnersFile[dir], file)
+				break
+			}
+			if dir == "" {
+				break
+			}
+			if dir = filepath.Dir(dir); dir == "." {
+				dir = ""
+			}
+		}
+	}
+	return byOwnersFile
+}
+
+// GetRequiredApprovals returns a map from ownersFiles -> number of approvals they need.
+// This is the highest number required by any of the files they need to approve.
+func (o Owners) GetRequiredApprovals() map[string]int {
+	required := map[string]int{}
+	for ownersFile, files := range o.filesByOwnersFile() {
+		required[ownersFile] = 1
+		for _, file := range files {
+			if n := o.repo.RequiredApprovals(file); n > required[ownersFile] {
+				required[ownersFile] = n
+			}
+		}
+	}
+	return required
+}
+
+// GetRequiredReviewers returns a map from ownersFiles -> people of whom one needs
+// to approve the files in them, for ownersFiles with required_reviewers.
+func (o Owners) GetRequiredReviewers() map[string]sets.String {
+	required := map[string]sets.String{}
+	for ownersFile, files := range o.filesByOwnersFile() {
+		reviewers := sets.NewString()
+		for _, file := range files {
+			reviewers.Insert(o.repo.RequiredReviewers(file).UnsortedList()...)
+		}
+		if reviewers.Len() > 0 {
+			required[ownersFile] = reviewers
+		}
+	}
+	return required
+}
+
 // GetAllPotentialApprovers returns the people from relevant owners files needed to get the PR approved
 func (o Owners) GetAllPotentialApprovers() []string {
 	approversOnly := []string{}
@@ -137,8 +197,9 @@ func findMostCoveringApprover(allApprovers []string, reverseMap map[string]sets.
 
 // temporaryUnapprovedFiles returns the list of files that wouldn't be
 // approved by the given set of approvers.
-func (o Owners) temporaryUnapprovedFiles(approvers sets.String) sets.String {
+func (o Owners) temporaryUnapprovedFiles(approvers sets.String, requireReviewers bool) sets.String {
 	ap := NewApprovers(o)
+	ap.RequireReviewers = requireReviewers
 	for approver := range approvers {
 		ap.AddApprover(approver, "", false)
 	}
@@ -146,16 +207,17 @@ func (o Owners) temporaryUnapprovedFiles(approvers sets.String) sets.String {
 }
 
 // KeepCoveringApprovers finds who we should keep as suggested approvers given a pre-selection
-// knownApprovers must be a subset of potentialApprovers.
-func (o Owners) KeepCoveringApprovers(reverseMap map[string]sets.String, knownApprovers sets.String, potentialApprovers []string) sets.String {
+// knownApprovers must be a subset of potentialApprovers. requireReviewers makes the
+// required reviewers of OWNERS files a requirement, as Approvers.RequireReviewers does.
+func (o Owners) KeepCoveringApprovers(reverseMap map[string]sets.String, knownApprovers sets.String, potentialApprovers []string, requireReviewers bool) sets.String {
 	if len(potentialApprovers) == 0 {
 		o.log.Debug("No potential approvers exist to filter for relevance. Does this repo have OWNERS files?")
 	}
 	keptApprovers := sets.NewString()
 
-	unapproved := o.temporaryUnapprovedFiles(knownApprovers)
+	unapproved := o.temporaryUnapprovedFiles(knownApprovers, requireReviewers)
 
-	for _, suggestedApprover := range o.GetSuggestedApprovers(reverseMap, potentialApprovers).List() {
+	for _, suggestedApprover := range o.GetSuggestedApprovers(reverseMap, potentialApprovers, requireReviewers).List() {
 		if reverseMap[suggestedApprover].Intersection(unapproved).Len() != 0 {
 			keptApprovers.Insert(suggestedApprover)
 		}
@@ -166,10 +228,19 @@ func (o Owners) KeepCoveringApprovers(reverseMap map[string]sets.String, knownAp
 
 // GetSuggestedApprovers solves the exact cover problem, finding an approver capable of
 // approving every OWNERS file in the PR
-func (o Owners) GetSuggestedApprovers(reverseMap map[string]sets.String, potentialApprovers []string) sets.String {
+func (o Owners) GetSuggestedApprovers(reverseMap map[string]sets.String, potentialApprovers []string, requireReviewers bool) sets.String {
 	ap := NewApprovers(o)
+	ap.RequireReviewers = requireReviewers
 	for !ap.RequirementsMet() {
-		newApprover := findMostCoveringApprover(potentialApprovers, reverseMap, ap.UnapprovedFiles())
+		// Files may need more than one approval, so only suggest new people
+		current := ap.GetCurrentApproversSet()
+		var candidates []string
+		for _, approver := range potentialApprovers {
+			if !current.Has(approver) {
+				candidates = append(candidates, approver)
+			}
+		}
+		newApprover := findMostCoveringApprover(candidates, reverseMap, ap.UnapprovedFiles())
 		if newApprover == "" {
 			o.log.Debugf("Couldn't find/suggest approvers for each files. Unapproved: %q", ap.UnapprovedFiles().List())
 			return ap.GetCurrentApproversSet()
@@ -270,6 +341,9 @@ type Approvers struct {
 	assignees       sets.String
 	AssociatedIssue int
 	RequireIssue    bool
+	// RequireReviewers makes the required_reviewers of OWNERS files a
+	// requirement for approval instead of only a hint for blunderbuss.
+	RequireReviewers bool
 
 	ManuallyApproved func() bool
 }
@@ -442,11 +516,40 @@ func (ap Approvers) NoIssueApprovers() map[string]Approval {
 	return nia
 }
 
+// requirements holds what the owners files of the change need to be approved.
+type requirements struct {
+	approvals map[string]int
+	reviewers map[string]sets.String
+	// current is the set of people that approved the change
+	current sets.String
+}
+
+func (ap Approvers) requirements() requirements {
+	r := requirements{
+		approvals: ap.owners.GetRequiredApprovals(),
+		current:   ap.GetCurrentApproversSet(),
+	}
+	if ap.RequireReviewers {
+		r.reviewers = ap.owners.GetRequiredReviewers()
+	}
+	return r
+}
+
+// approved checks whether the approvers of the owners file meet its requirements.
+func (r requirements) approved(ownersFile string, approvers sets.String) bool {
+	if approvers.Len() == 0 || approvers.Len() < r.approvals[ownersFile] {
+		return false
+	}
+	reviewers, ok := r.reviewers[ownersFile]
+	return !ok || CaseInsensitiveIntersection(reviewers, r.current).Len() > 0
+}
+
 // UnapprovedFiles returns owners files that still need approval
 func (ap Approvers) UnapprovedFiles() sets.String {
 	unapproved := sets.NewString()
+	reqs := ap.requirements()
 	for fn, approvers := range ap.GetFilesApprovers() {
-		if len(approvers) == 0 {
+		if !reqs.approved(fn, approvers) {
 			unapproved.Insert(fn)
 		}
 	}
@@ -457,14 +560,23 @@ func (ap Approvers) UnapprovedFiles() sets.String {
 func (ap Approvers) GetFiles(baseURL *url.URL, branch string) []File {
 	var allOwnersFiles []File
 	filesApprovers := ap.GetFilesApprovers()
+	reqs := ap.requirements()
 	for _, file := range ap.owners.GetOwnersSet().List() {
-		if len(filesApprovers[file]) == 0 {
-			allOwnersFiles = append(allOwnersFiles, UnapprovedFile{
+		if !reqs.approved(file, filesApprovers[file]) {
+			unapproved := UnapprovedFile{
 				baseURL:        baseURL,
 				filepath:       file,
 				ownersFilename: ap.owners.repo.Filenames().Owners,
 				branch:         branch,
-			})
+			}
+			if reqs.approvals[file] > 1 {
+				unapproved.approvers = filesApprovers[file]
+				unapproved.required = reqs.approvals[file]
+			}
+			if reviewers, ok := reqs.reviewers[file]; ok && CaseInsensitiveIntersection(reviewers, reqs.current).Len() == 0 {
+				unapproved.requiredReviewers = reviewers
+			}
+			allOwnersFiles = append(allOwnersFiles, unapproved)
 		} else {
 			allOwnersFiles = append(allOwnersFiles, ApprovedFile{
 				baseURL:        baseURL,
@@ -501,13 +613,18 @@ func (ap Approvers) GetCCs() []string {
 	currentApprovers := ap.GetCurrentApproversSet()
 	approversAndAssignees := currentApprovers.Union(ap.assignees)
 	leafReverseMap := ap.owners.GetReverseMap(ap.owners.GetLeafApprovers())
-	suggested := ap.owners.KeepCoveringApprovers(leafReverseMap, approversAndAssignees, randomizedApprovers)
+	suggested := ap.owners.KeepCoveringApprovers(leafReverseMap, approversAndAssignees, randomizedApprovers, ap.RequireReviewers)
 	approversAndSuggested := currentApprovers.Union(suggested)
 	everyone := approversAndSuggested.Union(ap.assignees)
 	fullReverseMap := ap.owners.GetReverseMap(ap.owners.GetApprovers())
-	keepAssignees := ap.owners.KeepCoveringApprovers(fullReverseMap, approversAndSuggested, everyone.List())
+	keepAssignees := ap.owners.KeepCoveringApprovers(fullReverseMap, approversAndSuggested, everyone.List(), ap.RequireReviewers)
 
-	return suggested.Union(keepAssignees).List()
+	// People that already approved may still cover files needing more approvals
+	alreadyApproved := sets.NewString()
+	for approver := range currentApprovers {
+		alreadyApproved.Insert(strings.ToLower(approver))
+	}
+	return suggested.Union(keepAssignees).Difference(alreadyApproved).List()
 }
 
 // AreFilesApproved returns a bool indicating whether or not OWNERS files associated with
@@ -577,6 +694,13 @@ type UnapprovedFile struct {
 	filepath       string
 	ownersFilename string
 	branch         string
+	// approvers is the set of users that approved this file change, if
+	// more approvals are required.
+	approvers sets.String
+	// required is the number of approvals this file change needs.
+	required int
+	// requiredReviewers is set if one of them still has to approve.
+	requiredReviewers sets.String
 }
 
 func (a ApprovedFile) String() string {
@@ -602,6 +726,16 @@ func (ua UnapprovedFile) String() string {
 		ua.branch,
 		fullOwnersPath,
 	)
+	var needs []string
+	if ua.required > 1 {
+		needs = append(needs, fmt.Sprintf("%d of %d approvals", ua.approvers.Len(), ua.required))
+	}
+	if ua.requiredReviewers.Len() > 0 {
+		needs = append(needs, "approval from one of "+strings.Join(ua.requiredReviewers.List(), ", "))
+	}
+	if len(needs) > 0 {
+		return fmt.Sprintf("- **[%s](%s)** (%s)\n", fullOwnersPath, link, strings.Join(needs, "; "))
+	}
 	return fmt.Sprintf("- **[%s](%s)**\n", fullOwnersPath, link)
 }
 
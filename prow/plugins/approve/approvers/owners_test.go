@@ -39,6 +39,8 @@ type FakeRepo struct {
 	leafApproversMap             map[string]sets.String
 	noParentOwnersMap            map[string]bool
 	autoApproveUnownedSubfolders map[string]bool
+	requiredApprovalsMap         map[string]int
+	requiredReviewersMap         map[string]sets.String
 }
 
 func (f FakeRepo) Filenames() ownersconfig.Filenames {
@@ -70,6 +72,17 @@ func (f FakeRepo) IsAutoApproveUnownedSubfolders(ownerFilePath string) bool {
 	return f.autoApproveUnownedSubfolders[ownerFilePath]
 }
 
+func (f FakeRepo) RequiredApprovals(path string) int {
+	if n, ok := f.requiredApprovalsMap[path]; ok {
+		return n
+	}
+	return 1
+}
+
+func (f FakeRepo) RequiredReviewers(path string) sets.String {
+	return f.requiredReviewersMap[path]
+}
+
 type dir struct {
 	fullPath  string
 	approvers sets.String
@@ -386,7 +399,7 @@ func TestGetSuggestedApprovers(t *testing.T) {
 			seed:      TestSeed,
 			log:       logrus.WithField("plugin", "some_plugin"),
 		}
-		suggested := testOwners.GetSuggestedApprovers(testOwners.GetReverseMap(testOwners.GetLeafApprovers()), testOwners.GetShuffledApprovers())
+		suggested := testOwners.GetSuggestedApprovers(testOwners.GetReverseMap(testOwners.GetLeafApprovers()), testOwners.GetShuffledApprovers(), false)
 		for _, ownersSet := range test.expectedOwners {
 			if ownersSet.Intersection(suggested).Len() == 0 {
 				t.Errorf("Failed for test %v.  Didn't find an approver from: %v. Actual Owners %v", test.testName, ownersSet, suggested)
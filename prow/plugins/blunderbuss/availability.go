@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package blunderbuss
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pkg/layeredsets"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// reviewerPicker picks reviewers from sets of candidates, skipping the ones
+// that are not available and optionally balancing the review load.
+type reviewerPicker struct {
+	ghc githubClient
+	log *logrus.Entry
+	org string
+	now time.Time
+
+	useStatusAvailability bool
+	maxReviewLoad         int
+	balanceReviewLoad     bool
+	fairnessWindow        time.Duration
+	outOfOffice           []plugins.OutOfOffice
+
+	// unavailable caches the candidates we already passed over
+	unavailable sets.String
+	// openReviews and recentReviews cache the results of the search API
+	openReviews   map[string]int
+	recentReviews map[string]int
+}
+
+func newReviewerPicker(ghc githubClient, log *logrus.Entry, config plugins.Blunderbuss, repo *github.Repo, pr *github.PullRequest) *reviewerPicker {
+	p := &reviewerPicker{
+		ghc:                   ghc,
+		log:                   log,
+		org:                   repo.Owner.Login,
+		now:                   time.Now(),
+		useStatusAvailability: config.UseStatusAvailability,
+		maxReviewLoad:         config.MaxReviewLoad,
+		balanceReviewLoad:     config.BalanceReviewLoad,
+		fairnessWindow:        config.FairnessWindowDuration,
+		outOfOffice:           config.OutOfOffice,
+		unavailable:           sets.NewString(),
+		openReviews:           map[string]int{},
+		recentReviews:         map[string]int{},
+	}
+	if config.AvailabilityFile != "" {
+		outOfOffice, err := loadAvailabilityFile(ghc, repo.Owner.Login, repo.Name, config.AvailabilityFile, pr.Base.Ref)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to load availability file %q.", config.AvailabilityFile)
+		}
+		p.outOfOffice = append(p.outOfOffice, outOfOffice...)
+	}
+	return p
+}
+
+// loadAvailabilityFile reads the out of office periods listed in a file of the repo.
+// A missing file is not an error.
+func loadAvailabilityFile(ghc githubClient, org, repo, path, ref string) ([]plugins.OutOfOffice, error) {
+	raw, err := ghc.GetFile(org, repo, path, ref)
+	if err != nil {
+		if _, notFound := err.(*github.FileNotFound); notFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var outOfOffice []plugins.OutOfOffice
+	if err := yaml.Unmarshal(raw, &outOfOffice); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %v", err)
+	}
+	return outOfOffice, nil
+}
+
+// find pops candidates from a set until it finds one that is available.
+func (p *reviewerPicker) find(targetSet *layeredsets.String) string {
+	for targetSet.Len() > 0 {
+		candidate := p.pop(*targetSet)
+		if p.unavailable.Has(candidate) {
+			// we've already verified this reviewer is not available
+			continue
+		}
+		if p.isAvailable(candidate) {
+			return candidate
+		}
+		p.unavailable.Insert(candidate)
+	}
+	return ""
+}
+
+// pop removes a candidate from the first non-empty layer of the set. Without
+// load balancing the candidate is random, otherwise it is one of the candidates
+// with the lowest load.
+func (p *reviewerPicker) pop(targetSet layeredsets.String) string {
+	if !p.balanceReviewLoad {
+		return targetSet.PopRandom()
+	}
+	for _, layer := range targetSet {
+		if layer.Len() == 0 {
+			continue
+		}
+		var lowest []string
+		lowestLoad := -1
+		for _, candidate := range layer.List() {
+			load := p.load(candidate)
+			if lowestLoad == -1 || load < lowestLoad {
+				lowest, lowestLoad = nil, load
+			}
+			if load == lowestLoad {
+				lowest = append(lowest, candidate)
+			}
+		}
+		sort.Strings(lowest)
+		sel := lowest[rand.Intn(len(lowest))]
+		targetSet.Delete(sel)
+		return sel
+	}
+	return ""
+}
+
+// isAvailable checks whether a candidate can be requested for a review. Errors
+// are logged and do not make a candidate unavailable.
+func (p *reviewerPicker) isAvailable(candidate string) bool {
+	log := p.log.WithField("candidate", candidate)
+	for _, o := range p.outOfOffice {
+		if github.NormLogin(o.User) != github.NormLogin(candidate) {
+			continue
+		}
+		away, err := o.Covers(p.now)
+		if err != nil {
+			log.WithError(err).Warn("Invalid out of office entry.")
+			continue
+		}
+		if away {
+			log.Debugf("Candidate is out of office from %s to %s.", o.Start, o.End)
+			return false
+		}
+	}
+	if p.useStatusAvailability {
+		busy, err := isUserBusy(p.ghc, candidate)
+		if err != nil {
+			log.Errorf("error checking user availability: %v", err)
+		}
+		if busy {
+			return false
+		}
+	}
+	if p.maxReviewLoad > 0 {
+		open, err := p.countOpenReviews(candidate)
+		if err != nil {
+			log.WithError(err).Error("Failed to get the review load.")
+		} else if open >= p.maxReviewLoad {
+			log.Debugf("Candidate has %d open review requests.", open)
+			return false
+		}
+	}
+	return true
+}
+
+// load is the number of open review requests of a candidate, plus the reviews
+// they did within the fairness window.
+func (p *reviewerPicker) load(candidate string) int {
+	open, err := p.countOpenReviews(candidate)
+	if err != nil {
+		p.log.WithError(err).WithField("candidate", candidate).Error("Failed to get the review load.")
+	}
+	if p.fairnessWindow <= 0 {
+		return open
+	}
+	recent, ok := p.recentReviews[candidate]
+	if !ok {
+		since := p.now.Add(-p.fairnessWindow).UTC().Format("2006-01-02T15:04:05Z")
+		recent, err = countSearchResults(p.ghc, fmt.Sprintf("is:pr org:%s reviewed-by:%s -author:%s updated:>=%s", p.org, candidate, candidate, since))
+		if err != nil {
+			p.log.WithError(err).WithField("candidate", candidate).Error("Failed to get the review history.")
+		}
+		p.recentReviews[candidate] = recent
+	}
+	return open + recent
+}
+
+func (p *reviewerPicker) countOpenReviews(candidate string) (int, error) {
+	if open, ok := p.openReviews[candidate]; ok {
+		return open, nil
+	}
+	open, err := countSearchResults(p.ghc, fmt.Sprintf("is:pr is:open archived:false org:%s review-requested:%s", p.org, candidate))
+	if err != nil {
+		return 0, err
+	}
+	p.openReviews[candidate] = open
+	return open, nil
+}
+
+type githubSearchCountQuery struct {
+	Search struct {
+		IssueCount githubql.Int
+	} `graphql:"search(type: ISSUE, first: 1, query: $query)"`
+}
+
+func countSearchResults(ghc githubClient, query string) (int, error) {
+	var q githubSearchCountQuery
+	vars := map[string]interface{}{
+		"query": githubql.String(query),
+	}
+	err := ghc.Query(context.Background(), &q, vars)
+	return int(q.Search.IssueCount), err
+}
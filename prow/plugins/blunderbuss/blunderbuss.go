@@ -68,13 +68,20 @@ func helpProvider(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhel
 			MaxReviewerCount:      3,
 			ExcludeApprovers:      true,
 			UseStatusAvailability: true,
+			MaxReviewLoad:         10,
+			BalanceReviewLoad:     true,
+			FairnessWindow:        "168h",
+			OutOfOffice: []plugins.OutOfOffice{
+				{User: "alice", Start: "2021-06-01", End: "2021-06-14"},
+			},
+			AvailabilityFile: "OUT_OF_OFFICE.yaml",
 		},
 	})
 	if err != nil {
 		logrus.WithError(err).Warnf("cannot generate comments for %s plugin", PluginName)
 	}
 	pluginHelp := &pluginhelp.PluginHelp{
-		Description: "The blunderbuss plugin automatically requests reviews from reviewers when a new PR is created. The reviewers are selected based on the reviewers specified in the OWNERS files that apply to the files modified by the PR.",
+		Description: "The blunderbuss plugin automatically requests reviews from reviewers when a new PR is created. The reviewers are selected based on the reviewers specified in the OWNERS files that apply to the files modified by the PR. Reviewers that are out of office, busy or already have too many open review requests can be skipped, and reviews can be spread evenly across the candidates.",
 		Config: map[string]string{
 			"": configString(reviewCount),
 		},
@@ -125,6 +132,7 @@ type githubClient interface {
 	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	Query(context.Context, interface{}, map[string]interface{}) error
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
 }
 
 type repoownersClient interface {
@@ -157,17 +165,7 @@ func handlePullRequest(ghc githubClient, roc repoownersClient, log *logrus.Entry
 		return nil
 	}
 
-	return handle(
-		ghc,
-		roc,
-		log,
-		config.ReviewerCount,
-		config.MaxReviewerCount,
-		config.ExcludeApprovers,
-		config.UseStatusAvailability,
-		repo,
-		pr,
-	)
+	return handle(ghc, roc, log, config, repo, pr)
 }
 
 func handleGenericCommentEvent(pc plugins.Agent, ce github.GenericCommentEvent) error {
@@ -199,20 +197,10 @@ func handleGenericComment(ghc githubClient, roc repoownersClient, log *logrus.En
 		return fmt.Errorf("error loading PullRequest: %v", err)
 	}
 
-	return handle(
-		ghc,
-		roc,
-		log,
-		config.ReviewerCount,
-		config.MaxReviewerCount,
-		config.ExcludeApprovers,
-		config.UseStatusAvailability,
-		repo,
-		pr,
-	)
+	return handle(ghc, roc, log, config, repo, pr)
 }
 
-func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerCount *int, maxReviewers int, excludeApprovers bool, useStatusAvailability bool, repo *github.Repo, pr *github.PullRequest) error {
+func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, config plugins.Blunderbuss, repo *github.Repo, pr *github.PullRequest) error {
 	oc, err := roc.LoadRepoOwners(repo.Owner.Login, repo.Name, pr.Base.Ref)
 	if err != nil {
 		return fmt.Errorf("error loading RepoOwners: %v", err)
@@ -225,19 +213,20 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 
 	var reviewers []string
 	var requiredReviewers []string
-	if reviewerCount != nil {
-		reviewers, requiredReviewers, err = getReviewers(oc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability)
+	if config.ReviewerCount != nil {
+		picker := newReviewerPicker(ghc, log, config, repo, pr)
+		reviewers, requiredReviewers, err = getReviewers(oc, picker, pr.User.Login, changes, *config.ReviewerCount)
 		if err != nil {
 			return err
 		}
-		if missing := *reviewerCount - len(reviewers); missing > 0 {
-			if !excludeApprovers {
+		if missing := *config.ReviewerCount - len(reviewers); missing > 0 {
+			if !config.ExcludeApprovers {
 				// Attempt to use approvers as additional reviewers. This must use
 				// reviewerCount instead of missing because owners can be both reviewers
 				// and approvers and the search might stop too early if it finds
 				// duplicates.
 				frc := fallbackReviewersClient{ownersClient: oc}
-				approvers, _, err := getReviewers(frc, ghc, log, pr.User.Login, changes, *reviewerCount, useStatusAvailability)
+				approvers, _, err := getReviewers(frc, picker, pr.User.Login, changes, *config.ReviewerCount)
 				if err != nil {
 					return err
 				}
@@ -250,17 +239,17 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 						added++
 					}
 				}
-				log.Infof("Added %d approvers as reviewers. %d/%d reviewers found.", added, combinedReviewers.Len(), *reviewerCount)
+				log.Infof("Added %d approvers as reviewers. %d/%d reviewers found.", added, combinedReviewers.Len(), *config.ReviewerCount)
 			}
 		}
-		if missing := *reviewerCount - len(reviewers); missing > 0 {
-			log.Debugf("Not enough reviewers found in OWNERS files for files touched by this PR. %d/%d reviewers found.", len(reviewers), *reviewerCount)
+		if missing := *config.ReviewerCount - len(reviewers); missing > 0 {
+			log.Debugf("Not enough reviewers found in OWNERS files for files touched by this PR. %d/%d reviewers found.", len(reviewers), *config.ReviewerCount)
 		}
 	}
 
-	if maxReviewers > 0 && len(reviewers) > maxReviewers {
-		log.Infof("Limiting request of %d reviewers to %d maxReviewers.", len(reviewers), maxReviewers)
-		reviewers = reviewers[:maxReviewers]
+	if config.MaxReviewerCount > 0 && len(reviewers) > config.MaxReviewerCount {
+		log.Infof("Limiting request of %d reviewers to %d maxReviewers.", len(reviewers), config.MaxReviewerCount)
+		reviewers = reviewers[:config.MaxReviewerCount]
 	}
 
 	// add required reviewers if any
@@ -273,12 +262,11 @@ func handle(ghc githubClient, roc repoownersClient, log *logrus.Entry, reviewerC
 	return nil
 }
 
-func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, author string, files []github.PullRequestChange, minReviewers int, useStatusAvailability bool) ([]string, []string, error) {
+func getReviewers(rc reviewersClient, picker *reviewerPicker, author string, files []github.PullRequestChange, minReviewers int) ([]string, []string, error) {
 	authorSet := sets.NewString(github.NormLogin(author))
 	reviewers := layeredsets.NewString()
 	requiredReviewers := sets.NewString()
 	leafReviewers := layeredsets.NewString()
-	busyReviewers := sets.NewString()
 	ownersSeen := sets.NewString()
 	// first build 'reviewers' by taking a unique reviewer from each OWNERS file.
 	for _, file := range files {
@@ -296,14 +284,14 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 			continue
 		}
 		leafReviewers = leafReviewers.Union(fileUnusedLeafs)
-		if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &fileUnusedLeafs); r != "" {
+		if r := picker.find(&fileUnusedLeafs); r != "" {
 			reviewers.Insert(0, r)
 		}
 	}
 	// now ensure that we request review from at least minReviewers reviewers. Favor leaf reviewers.
 	unusedLeafs := leafReviewers.Difference(reviewers.Set())
 	for reviewers.Len() < minReviewers && unusedLeafs.Len() > 0 {
-		if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &unusedLeafs); r != "" {
+		if r := picker.find(&unusedLeafs); r != "" {
 			reviewers.Insert(1, r)
 		}
 	}
@@ -313,7 +301,7 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 		}
 		fileReviewers := rc.Reviewers(file.Filename).Difference(authorSet)
 		for reviewers.Len() < minReviewers && fileReviewers.Len() > 0 {
-			if r := findReviewer(ghc, log, useStatusAvailability, &busyReviewers, &fileReviewers); r != "" {
+			if r := picker.find(&fileReviewers); r != "" {
 				reviewers.Insert(2, r)
 			}
 		}
@@ -321,38 +309,6 @@ func getReviewers(rc reviewersClient, ghc githubClient, log *logrus.Entry, autho
 	return reviewers.List(), requiredReviewers.List(), nil
 }
 
-// findReviewer finds a reviewer from a set, potentially using status
-// availability.
-func findReviewer(ghc githubClient, log *logrus.Entry, useStatusAvailability bool, busyReviewers *sets.String, targetSet *layeredsets.String) string {
-	// if we don't care about status availability, just pop a target from the set
-	if !useStatusAvailability {
-		return targetSet.PopRandom()
-	}
-
-	// if we do care, start looping through the candidates
-	for {
-		if targetSet.Len() == 0 {
-			// if there are no candidates left, then break
-			break
-		}
-		candidate := targetSet.PopRandom()
-		if busyReviewers.Has(candidate) {
-			// we've already verified this reviewer is busy
-			continue
-		}
-		busy, err := isUserBusy(ghc, candidate)
-		if err != nil {
-			log.Errorf("error checking user availability: %v", err)
-		}
-		if !busy {
-			return candidate
-		}
-		// if we haven't returned the candidate, then they must be busy.
-		busyReviewers.Insert(candidate)
-	}
-	return ""
-}
-
 type githubAvailabilityQuery struct {
 	User struct {
 		Login  githubql.String
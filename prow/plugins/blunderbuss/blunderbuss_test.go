@@ -19,6 +19,7 @@ package blunderbuss
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
@@ -26,6 +27,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -41,9 +43,11 @@ import (
 )
 
 type fakeGitHubClient struct {
-	pr        *github.PullRequest
-	changes   []github.PullRequestChange
-	requested []string
+	pr           *github.PullRequest
+	changes      []github.PullRequestChange
+	requested    []string
+	files        map[string]string
+	searchCounts map[string]int
 }
 
 func newFakeGitHubClient(pr *github.PullRequest, filesChanged []string) *fakeGitHubClient {
@@ -86,6 +90,12 @@ func (c *fakeGitHubClient) GetPullRequest(org, repo string, num int) (*github.Pu
 }
 
 func (c *fakeGitHubClient) Query(ctx context.Context, q interface{}, vars map[string]interface{}) error {
+	if cq, ok := q.(*githubSearchCountQuery); ok {
+		// the review history query ends with a timestamp
+		query := strings.SplitN(string(vars["query"].(githubql.String)), " updated:", 2)[0]
+		cq.Search.IssueCount = githubql.Int(c.searchCounts[query])
+		return nil
+	}
 	sq, ok := q.(*githubAvailabilityQuery)
 	if !ok {
 		return errors.New("unexpected query type")
@@ -97,6 +107,14 @@ func (c *fakeGitHubClient) Query(ctx context.Context, q interface{}, vars map[st
 	return nil
 }
 
+func (c *fakeGitHubClient) GetFile(org, repo, filepath, commit string) ([]byte, error) {
+	content, ok := c.files[filepath]
+	if !ok {
+		return nil, &github.FileNotFound{}
+	}
+	return []byte(content), nil
+}
+
 type fakeRepoownersClient struct {
 	foc *fakeOwnersClient
 }
@@ -139,6 +157,10 @@ func (foc *fakeOwnersClient) RequiredReviewers(path string) sets.String {
 	return foc.requiredReviewers[path]
 }
 
+func (foc *fakeOwnersClient) RequiredApprovals(path string) int {
+	return 1
+}
+
 func (foc *fakeOwnersClient) LeafReviewers(path string) sets.String {
 	return foc.leafReviewers[path]
 }
@@ -338,7 +360,7 @@ func TestHandleWithExcludeApproversOnlyReviewers(t *testing.T) {
 
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, true, false, &repo, &pr,
+			plugins.Blunderbuss{ReviewerCount: &tc.reviewerCount, MaxReviewerCount: tc.maxReviewerCount, ExcludeApprovers: true}, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -380,7 +402,7 @@ func TestHandleWithoutExcludeApproversNoReviewers(t *testing.T) {
 
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, false, &repo, &pr,
+			plugins.Blunderbuss{ReviewerCount: &tc.reviewerCount, MaxReviewerCount: tc.maxReviewerCount}, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -520,7 +542,7 @@ func TestHandleWithoutExcludeApproversMixed(t *testing.T) {
 		fghc := newFakeGitHubClient(&pr, tc.filesChanged)
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, false, &repo, &pr,
+			plugins.Blunderbuss{ReviewerCount: &tc.reviewerCount, MaxReviewerCount: tc.maxReviewerCount}, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -813,7 +835,7 @@ func TestPopActiveReviewer(t *testing.T) {
 		fghc := newFakeGitHubClient(&pr, tc.filesChanged)
 		if err := handle(
 			fghc, froc, logrus.WithField("plugin", PluginName),
-			&tc.reviewerCount, tc.maxReviewerCount, false, true, &repo, &pr,
+			plugins.Blunderbuss{ReviewerCount: &tc.reviewerCount, MaxReviewerCount: tc.maxReviewerCount, UseStatusAvailability: true}, &repo, &pr,
 		); err != nil {
 			t.Errorf("[%s] unexpected error from handle: %v", tc.name, err)
 			continue
@@ -833,3 +855,136 @@ func TestPopActiveReviewer(t *testing.T) {
 		}
 	}
 }
+
+func TestReviewerAvailability(t *testing.T) {
+	froc := &fakeRepoownersClient{
+		foc: &fakeOwnersClient{
+			owners: map[string]string{"a.go": "1"},
+			reviewers: map[string]layeredsets.String{
+				"a.go": layeredsets.NewString("alice", "bob", "carol"),
+			},
+			leafReviewers: map[string]sets.String{
+				"a.go": sets.NewString("alice", "bob", "carol"),
+			},
+		},
+	}
+	today := time.Now().UTC()
+	day := func(offset int) string {
+		return today.AddDate(0, 0, offset).Format("2006-01-02")
+	}
+	openQuery := func(user string) string {
+		return "is:pr is:open archived:false org:org review-requested:" + user
+	}
+	historyQuery := func(user string) string {
+		return "is:pr org:org reviewed-by:" + user + " -author:" + user
+	}
+
+	var testcases = []struct {
+		name                       string
+		config                     plugins.Blunderbuss
+		files                      map[string]string
+		searchCounts               map[string]int
+		expectedRequested          []string
+		alternateExpectedRequested []string
+	}{
+		{
+			name: "out of office users from the config are skipped",
+			config: plugins.Blunderbuss{
+				OutOfOffice: []plugins.OutOfOffice{
+					{User: "alice", Start: day(-1), End: day(1)},
+					{User: "Bob", Start: day(0), End: day(0)},
+				},
+			},
+			expectedRequested: []string{"carol"},
+		},
+		{
+			name:   "out of office users from the availability file are skipped",
+			config: plugins.Blunderbuss{AvailabilityFile: "OOO.yaml"},
+			files: map[string]string{
+				"OOO.yaml": fmt.Sprintf("- user: alice\n  start: %s\n  end: %s\n- user: carol\n  start: %s\n  end: %s\n", day(-3), day(3), day(-10), day(0)),
+			},
+			expectedRequested: []string{"bob"},
+		},
+		{
+			name: "past and future out of office periods do not count",
+			config: plugins.Blunderbuss{
+				OutOfOffice: []plugins.OutOfOffice{
+					{User: "alice", Start: day(-5), End: day(-1)},
+					{User: "bob", Start: day(1), End: day(5)},
+					{User: "carol", Start: day(-5), End: day(5)},
+				},
+			},
+			expectedRequested:          []string{"alice"},
+			alternateExpectedRequested: []string{"bob"},
+		},
+		{
+			name:   "missing availability file is ignored",
+			config: plugins.Blunderbuss{AvailabilityFile: "OOO.yaml", MaxReviewLoad: 1},
+			searchCounts: map[string]int{
+				openQuery("alice"): 1,
+				openQuery("carol"): 1,
+			},
+			expectedRequested: []string{"bob"},
+		},
+		{
+			name:   "users at the maximum review load are skipped",
+			config: plugins.Blunderbuss{MaxReviewLoad: 5},
+			searchCounts: map[string]int{
+				openQuery("alice"): 5,
+				openQuery("bob"):   7,
+				openQuery("carol"): 4,
+			},
+			expectedRequested: []string{"carol"},
+		},
+		{
+			name:   "balancing picks the user with the fewest open reviews",
+			config: plugins.Blunderbuss{BalanceReviewLoad: true},
+			searchCounts: map[string]int{
+				openQuery("alice"): 3,
+				openQuery("bob"):   1,
+				openQuery("carol"): 2,
+			},
+			expectedRequested: []string{"bob"},
+		},
+		{
+			name:   "balancing counts the review history",
+			config: plugins.Blunderbuss{BalanceReviewLoad: true, FairnessWindowDuration: 7 * 24 * time.Hour},
+			searchCounts: map[string]int{
+				openQuery("bob"):      1,
+				openQuery("carol"):    2,
+				historyQuery("alice"): 5,
+				historyQuery("bob"):   1,
+			},
+			expectedRequested:          []string{"bob"},
+			alternateExpectedRequested: []string{"carol"},
+		},
+		{
+			name:   "balancing skips unavailable users",
+			config: plugins.Blunderbuss{BalanceReviewLoad: true, OutOfOffice: []plugins.OutOfOffice{{User: "alice", Start: day(0), End: day(0)}}},
+			searchCounts: map[string]int{
+				openQuery("bob"):   1,
+				openQuery("carol"): 2,
+			},
+			expectedRequested: []string{"bob"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := github.PullRequest{Number: 5, User: github.User{Login: "author"}}
+			repo := github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+			fghc := newFakeGitHubClient(&pr, []string{"a.go"})
+			fghc.files = tc.files
+			fghc.searchCounts = tc.searchCounts
+			one := 1
+			tc.config.ReviewerCount = &one
+			tc.config.ExcludeApprovers = true
+			if err := handle(fghc, froc, logrus.WithField("plugin", PluginName), tc.config, &repo, &pr); err != nil {
+				t.Fatalf("unexpected error from handle: %v", err)
+			}
+
+			if !reflect.DeepEqual(fghc.requested, tc.expectedRequested) && !reflect.DeepEqual(fghc.requested, tc.alternateExpectedRequested) {
+				t.Errorf("expected the requested reviewers to be %q, but got %q.", tc.expectedRequested, fghc.requested)
+			}
+		})
+	}
+}
@@ -43,6 +43,7 @@ import (
 
 const (
 	defaultBlunderbussReviewerCount = 2
+	defaultLifecycleGracePeriod     = "168h"
 )
 
 // Configuration is the top-level serialization target for plugin Configuration.
@@ -74,8 +75,11 @@ type Configuration struct {
 	Goose                Goose                        `json:"goose,omitempty"`
 	Heart                Heart                        `json:"heart,omitempty"`
 	Label                Label                        `json:"label,omitempty"`
+	LabelGuard           LabelGuard                   `json:"label_guard,omitempty"`
 	Lgtm                 []Lgtm                       `json:"lgtm,omitempty"`
+	Lifecycle            []Lifecycle                  `json:"lifecycle,omitempty"`
 	Jira                 *Jira                        `json:"jira,omitempty"`
+	JobDiff              []JobDiff                    `json:"jobdiff,omitempty"`
 	MilestoneApplier     map[string]BranchToMilestone `json:"milestone_applier,omitempty"`
 	RepoMilestone        map[string]Milestone         `json:"repo_milestone,omitempty"`
 	Project              ProjectConfig                `json:"project_config,omitempty"`
@@ -150,6 +154,54 @@ type Blunderbuss struct {
 	// additional token per successful reviewer (and potentially more depending on
 	// how many busy reviewers it had to pass over).
 	UseStatusAvailability bool `json:"use_status_availability,omitempty"`
+	// MaxReviewLoad is the number of open pull requests in the org awaiting a
+	// review from a user at which blunderbuss stops requesting reviews from
+	// them. Defaults to 0 meaning no limit. This uses one additional token per
+	// candidate reviewer.
+	MaxReviewLoad int `json:"max_review_load,omitempty"`
+	// BalanceReviewLoad makes blunderbuss prefer the candidates with the fewest
+	// open review requests in the org instead of picking one at random.
+	BalanceReviewLoad bool `json:"balance_review_load,omitempty"`
+	// FairnessWindow is the period over which reviews are remembered when
+	// balancing the review load, e.g. "168h". Pull requests a candidate reviewed
+	// that were updated within this period count towards their load, so reviews
+	// are spread evenly over time. Only used with BalanceReviewLoad.
+	FairnessWindow         string        `json:"fairness_window,omitempty"`
+	FairnessWindowDuration time.Duration `json:"-"`
+	// OutOfOffice lists the periods in which users are away. Blunderbuss never
+	// requests reviews from users that are out of office.
+	OutOfOffice []OutOfOffice `json:"out_of_office,omitempty"`
+	// AvailabilityFile is the path of a YAML file in the repo, read from the base
+	// branch of the pull request, that lists more out of office periods in the
+	// same format as OutOfOffice.
+	AvailabilityFile string `json:"availability_file,omitempty"`
+}
+
+// OutOfOffice is a period in which a user is not available for reviews.
+type OutOfOffice struct {
+	// User is the GitHub login of the user that is away.
+	User string `json:"user"`
+	// Start is the first day the user is away, formatted as YYYY-MM-DD.
+	Start string `json:"start"`
+	// End is the last day the user is away, formatted as YYYY-MM-DD.
+	End string `json:"end"`
+}
+
+// Covers returns whether the user is away at the given time. Days are in UTC.
+func (o OutOfOffice) Covers(t time.Time) (bool, error) {
+	start, err := time.Parse("2006-01-02", o.Start)
+	if err != nil {
+		return false, fmt.Errorf("invalid start %q for %s: %v", o.Start, o.User, err)
+	}
+	end, err := time.Parse("2006-01-02", o.End)
+	if err != nil {
+		return false, fmt.Errorf("invalid end %q for %s: %v", o.End, o.User, err)
+	}
+	if end.Before(start) {
+		return false, fmt.Errorf("end %s is before start %s for %s", o.End, o.Start, o.User)
+	}
+	t = t.UTC()
+	return !t.Before(start) && t.Before(end.AddDate(0, 0, 1)), nil
 }
 
 // Owners contains configuration related to handling OWNERS files.
@@ -297,6 +349,13 @@ type Approve struct {
 	// * an APPROVE github review is equivalent to leaving an "/approve" message.
 	// * A REQUEST_CHANGES github review is equivalent to leaving an /approve cancel" message.
 	IgnoreReviewState *bool `json:"ignore_review_state,omitempty"`
+	// ExpireApprovalsOnChange causes an approval to be dropped when commits pushed
+	// after it change files that the approver approved.
+	ExpireApprovalsOnChange bool `json:"expire_approvals_on_change,omitempty"`
+	// EnforceRequiredReviewers requires one of the required_reviewers of an
+	// OWNERS file to approve the files it covers. Otherwise required_reviewers
+	// are only requested for review by blunderbuss.
+	EnforceRequiredReviewers bool `json:"enforce_required_reviewers,omitempty"`
 	// CommandHelpLink is the link to the help page which shows the available commands for each repo.
 	// The default value is "https://go.k8s.io/bot-commands". The command help page is served by Deck
 	// and available under https://<deck-url>/command-help, e.g. "https://prow.k8s.io/command-help"
@@ -324,6 +383,42 @@ func (a Approve) ConsiderReviewState() bool {
 	return true
 }
 
+// Lifecycle is a policy for marking inactive issues and PRs as stale and rotten
+// and for closing them. The policies are applied by the lifecycle-controller.
+type Lifecycle struct {
+	// Repos are either of the form org/repo or just org.
+	Repos []string `json:"repos,omitempty"`
+	// StaleAfter is how long an issue or PR has to be inactive to be marked as
+	// lifecycle/stale, e.g. "2160h". Items are not marked as stale if unset.
+	StaleAfter         string        `json:"stale_after,omitempty"`
+	StaleAfterDuration time.Duration `json:"-"`
+	// RottenAfter is how long an issue or PR has to be lifecycle/stale to be
+	// marked as lifecycle/rotten. Items are not marked as rotten if unset.
+	RottenAfter         string        `json:"rotten_after,omitempty"`
+	RottenAfterDuration time.Duration `json:"-"`
+	// CloseAfter is how long an issue or PR has to be lifecycle/rotten to be
+	// closed. Items are not closed if unset.
+	CloseAfter         string        `json:"close_after,omitempty"`
+	CloseAfterDuration time.Duration `json:"-"`
+	// GracePeriod is how long to wait after the last comment from a human
+	// before marking an item as rotten or closing it. Defaults to "168h",
+	// "0s" disables it.
+	GracePeriod         string        `json:"grace_period,omitempty"`
+	GracePeriodDuration time.Duration `json:"-"`
+	// ExemptLabels are labels that exempt issues and PRs from the policy.
+	// lifecycle/frozen always exempts them.
+	ExemptLabels []string `json:"exempt_labels,omitempty"`
+	// ExemptMilestones are milestones that exempt issues and PRs from the policy.
+	ExemptMilestones []string `json:"exempt_milestones,omitempty"`
+	// SkipIssues excludes issues from the policy.
+	SkipIssues bool `json:"skip_issues,omitempty"`
+	// SkipPullRequests excludes PRs from the policy.
+	SkipPullRequests bool `json:"skip_pull_requests,omitempty"`
+	// UpdateStaleBranches merges the base branch into PRs when they are marked
+	// as stale, so that their tests run again against the current base.
+	UpdateStaleBranches bool `json:"update_stale_branches,omitempty"`
+}
+
 // Lgtm specifies a configuration for a single lgtm.
 // The configuration for the lgtm plugin is defined as a list of these structures.
 type Lgtm struct {
@@ -349,6 +444,132 @@ type Jira struct {
 	// for example including `enterprise` here would disable linking for all issues
 	// that start with `enterprise-` like `enterprise-4.` Matching is case-insenitive.
 	DisabledJiraProjects []string `json:"disabled_jira_projects,omitempty"`
+
+	// Lifecycle configures validating the Jira issues referenced in the titles
+	// of pull requests and moving them along as the pull requests progress.
+	// Pull requests are only validated on branches that have options configured.
+	Lifecycle JiraLifecycle `json:"lifecycle,omitempty"`
+}
+
+// JiraOptionsWildcard is the branch name whose Jira options apply to all branches.
+const JiraOptionsWildcard = `*`
+
+// JiraLifecycle holds options for checking Jira issues in a defaulting hierarchy.
+type JiraLifecycle struct {
+	// Default settings mapped by branch in any repo in any org.
+	// The `*` wildcard will apply to all branches.
+	Default map[string]JiraBranchOptions `json:"default,omitempty"`
+	// Options for specific orgs.
+	Orgs map[string]JiraOrgOptions `json:"orgs,omitempty"`
+}
+
+// JiraOrgOptions holds options for checking Jira issues for an org.
+type JiraOrgOptions struct {
+	// Default settings mapped by branch in any repo in this org.
+	// The `*` wildcard will apply to all branches.
+	Default map[string]JiraBranchOptions `json:"default,omitempty"`
+	// Options for specific repos.
+	Repos map[string]JiraRepoOptions `json:"repos,omitempty"`
+}
+
+// JiraRepoOptions holds options for checking Jira issues for a repo.
+type JiraRepoOptions struct {
+	// Options for specific branches in this repo.
+	// The `*` wildcard will apply to all branches.
+	Branches map[string]JiraBranchOptions `json:"branches,omitempty"`
+}
+
+// JiraBranchOptions describes how to check if a Jira issue is valid and to
+// which statuses it is transitioned. Statuses are matched case-insensitively.
+type JiraBranchOptions struct {
+	// ExcludeDefaults excludes defaults from more generic Jira configurations.
+	ExcludeDefaults *bool `json:"exclude_defaults,omitempty"`
+
+	// ValidateByDefault determines whether pull requests that do not reference
+	// an issue in their title are reported as such.
+	ValidateByDefault *bool `json:"validate_by_default,omitempty"`
+
+	// ValidStatuses determine which statuses an issue may have to be valid.
+	ValidStatuses *[]string `json:"valid_statuses,omitempty"`
+	// TargetVersion determines which fix version an issue needs to have to be
+	// valid. It is also the fix version of issues cloned for cherry-picks.
+	TargetVersion *string `json:"target_version,omitempty"`
+
+	// StatusAfterValidation is the status to which the issue will be transitioned
+	// after being deemed valid and linked to a pull request. Will implicitly be
+	// considered a part of `valid_statuses` if those are set.
+	StatusAfterValidation *string `json:"status_after_validation,omitempty"`
+	// StatusAfterMerge is the status to which the issue will be transitioned
+	// after all pull requests linked to it have been merged.
+	StatusAfterMerge *string `json:"status_after_merge,omitempty"`
+	// StatusAfterClose is the status to which the issue will be transitioned
+	// after all pull requests linked to it have been closed without merging.
+	StatusAfterClose *string `json:"status_after_close,omitempty"`
+}
+
+// ResolveJiraOptions implements defaulting for a parent/child configuration,
+// preferring child fields where set.
+func ResolveJiraOptions(parent, child JiraBranchOptions) JiraBranchOptions {
+	output := JiraBranchOptions{}
+
+	if child.ExcludeDefaults == nil || !*child.ExcludeDefaults {
+		output = parent
+	}
+
+	// override with the child
+	if child.ExcludeDefaults != nil {
+		output.ExcludeDefaults = child.ExcludeDefaults
+	}
+	if child.ValidateByDefault != nil {
+		output.ValidateByDefault = child.ValidateByDefault
+	}
+	if child.ValidStatuses != nil {
+		output.ValidStatuses = child.ValidStatuses
+	}
+	if child.TargetVersion != nil {
+		output.TargetVersion = child.TargetVersion
+	}
+	if child.StatusAfterValidation != nil {
+		output.StatusAfterValidation = child.StatusAfterValidation
+	}
+	if child.StatusAfterMerge != nil {
+		output.StatusAfterMerge = child.StatusAfterMerge
+	}
+	if child.StatusAfterClose != nil {
+		output.StatusAfterClose = child.StatusAfterClose
+	}
+
+	return output
+}
+
+// jiraOptionsForItem resolves the options for an item, honoring the `*`
+// wildcard. The second return value reports whether any options were found.
+func jiraOptionsForItem(item string, config map[string]JiraBranchOptions) (JiraBranchOptions, bool) {
+	wildcard, hasWildcard := config[JiraOptionsWildcard]
+	options, hasItem := config[item]
+	return ResolveJiraOptions(wildcard, options), hasWildcard || hasItem
+}
+
+// OptionsForBranch determines the criteria for a valid Jira issue on a branch of a repo
+// by defaulting in a cascading way, in the following order (later entries override earlier
+// ones), always searching for the wildcard as well as the branch name: global, then org,
+// repo, and finally branch-specific configuration. The second return value reports
+// whether the branch has any options configured.
+func (l *JiraLifecycle) OptionsForBranch(org, repo, branch string) (JiraBranchOptions, bool) {
+	options, configured := jiraOptionsForItem(branch, l.Default)
+	orgOptions, exists := l.Orgs[org]
+	if !exists {
+		return options, configured
+	}
+	branchOptions, found := jiraOptionsForItem(branch, orgOptions.Default)
+	options, configured = ResolveJiraOptions(options, branchOptions), configured || found
+
+	repoOptions, exists := orgOptions.Repos[repo]
+	if !exists {
+		return options, configured
+	}
+	branchOptions, found = jiraOptionsForItem(branch, repoOptions.Branches)
+	return ResolveJiraOptions(options, branchOptions), configured || found
 }
 
 // Cat contains the configuration for the cat plugin.
@@ -370,6 +591,14 @@ type Label struct {
 	AdditionalLabels []string `json:"additional_labels"`
 }
 
+// LabelGuard contains the configuration for the label-guard plugin.
+type LabelGuard struct {
+	// LabelsPath is the path to the labels.yaml file read by label_sync.
+	// The permissions declared for each label in it are enforced on every
+	// repo the plugin is enabled for.
+	LabelsPath string `json:"labels_path,omitempty"`
+}
+
 // Trigger specifies a configuration for a single trigger.
 //
 // The configuration for the trigger plugin is defined as a list of these structures.
@@ -786,6 +1015,59 @@ func (c *Configuration) LgtmFor(org, repo string) *Lgtm {
 	return &Lgtm{}
 }
 
+// LifecycleFor finds the Lifecycle policy for a repo, if one exists.
+// A policy can be listed for the repo itself or for its org.
+func (c *Configuration) LifecycleFor(org, repo string) *Lifecycle {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	for _, lifecycle := range c.Lifecycle {
+		if !sets.NewString(lifecycle.Repos...).Has(fullName) {
+			continue
+		}
+		return &lifecycle
+	}
+	// If you don't find anything, loop again looking for an org config
+	for _, lifecycle := range c.Lifecycle {
+		if !sets.NewString(lifecycle.Repos...).Has(org) {
+			continue
+		}
+		return &lifecycle
+	}
+	return nil
+}
+
+// JobDiff holds configuration for the jobdiff plugin.
+type JobDiff struct {
+	// Repos are either of the form org/repo or just org.
+	Repos []string `json:"repos,omitempty"`
+	// ConfigPath is the path of the Prow config in the repo, e.g.
+	// config/prow/config.yaml.
+	ConfigPath string `json:"config_path,omitempty"`
+	// JobConfigPath is the path of the job configs in the repo, e.g.
+	// config/jobs. Optional.
+	JobConfigPath string `json:"job_config_path,omitempty"`
+}
+
+// JobDiffFor finds the JobDiff for a repo, if one exists.
+// A config can be listed for the repo itself or for the
+// owning organization.
+func (c *Configuration) JobDiffFor(org, repo string) *JobDiff {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+	for _, jd := range c.JobDiff {
+		if !sets.NewString(jd.Repos...).Has(fullName) {
+			continue
+		}
+		return &jd
+	}
+	// If you don't find anything, loop again looking for an org config
+	for _, jd := range c.JobDiff {
+		if !sets.NewString(jd.Repos...).Has(org) {
+			continue
+		}
+		return &jd
+	}
+	return nil
+}
+
 // TriggerFor finds the Trigger for a repo, if one exists
 // a trigger can be listed for the repo itself or for the
 // owning organization
@@ -969,6 +1251,11 @@ func (c *Configuration) setDefaults() {
 			c.RequireMatchingLabel[i].GracePeriod = "5s"
 		}
 	}
+	for i, l := range c.Lifecycle {
+		if l.GracePeriod == "" {
+			c.Lifecycle[i].GracePeriod = defaultLifecycleGracePeriod
+		}
+	}
 }
 
 // validatePluginsDupes will return an error if there are duplicated plugins.
@@ -1056,6 +1343,17 @@ func validateBlunderbuss(b *Blunderbuss) error {
 	if b.ReviewerCount != nil && *b.ReviewerCount < 1 {
 		return fmt.Errorf("invalid request_count: %v (needs to be positive)", *b.ReviewerCount)
 	}
+	if b.MaxReviewLoad < 0 {
+		return fmt.Errorf("invalid max_review_load: %v (needs to be positive)", b.MaxReviewLoad)
+	}
+	for _, o := range b.OutOfOffice {
+		if o.User == "" {
+			return errors.New("out_of_office entries need a user")
+		}
+		if _, err := o.Covers(time.Time{}); err != nil {
+			return fmt.Errorf("invalid out_of_office entry: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -1095,6 +1393,28 @@ func validateConfigUpdater(updater *ConfigUpdater) error {
 	return nil
 }
 
+func validateLifecycle(policies []Lifecycle) error {
+	repos := sets.NewString()
+	for i, l := range policies {
+		if len(l.Repos) == 0 {
+			return fmt.Errorf("lifecycle policy #%d has no repos", i)
+		}
+		for _, repo := range l.Repos {
+			if repos.Has(repo) {
+				return fmt.Errorf("%s has more than one lifecycle policy", repo)
+			}
+			repos.Insert(repo)
+		}
+		if l.StaleAfterDuration < 0 || l.RottenAfterDuration < 0 || l.CloseAfterDuration < 0 || l.GracePeriodDuration < 0 {
+			return fmt.Errorf("lifecycle policy #%d has a negative duration", i)
+		}
+		if l.SkipIssues && l.SkipPullRequests {
+			return fmt.Errorf("lifecycle policy #%d skips both issues and pull requests", i)
+		}
+	}
+	return nil
+}
+
 func validateRequireMatchingLabel(rs []RequireMatchingLabel) error {
 	for i, r := range rs {
 		if err := r.validate(); err != nil {
@@ -1146,6 +1466,18 @@ func validateProjectManager(pm ProjectManager) error {
 
 var warnTriggerTrustedOrg time.Time
 
+func validateJobDiff(jds []JobDiff) error {
+	for i, jd := range jds {
+		if jd.ConfigPath == "" {
+			return fmt.Errorf("jobdiff config %d for repos %v has no config_path", i, jd.Repos)
+		}
+		if path.IsAbs(jd.ConfigPath) || path.IsAbs(jd.JobConfigPath) {
+			return fmt.Errorf("jobdiff config %d for repos %v: paths must be relative to the repo root", i, jd.Repos)
+		}
+	}
+	return nil
+}
+
 func validateTrigger(triggers []Trigger) error {
 	for _, trigger := range triggers {
 		if trigger.TrustedOrg != "" {
@@ -1200,6 +1532,37 @@ func compileRegexpsAndDurations(pc *Configuration) error {
 		}
 		rs[i].GracePeriodDuration = dur
 	}
+
+	for i := range pc.Lifecycle {
+		l := &pc.Lifecycle[i]
+		for _, d := range []struct {
+			name  string
+			value string
+			dur   *time.Duration
+		}{
+			{name: "stale_after", value: l.StaleAfter, dur: &l.StaleAfterDuration},
+			{name: "rotten_after", value: l.RottenAfter, dur: &l.RottenAfterDuration},
+			{name: "close_after", value: l.CloseAfter, dur: &l.CloseAfterDuration},
+			{name: "grace_period", value: l.GracePeriod, dur: &l.GracePeriodDuration},
+		} {
+			if d.value == "" {
+				continue
+			}
+			dur, err := time.ParseDuration(d.value)
+			if err != nil {
+				return fmt.Errorf("failed to compile lifecycle %s duration: %q, error: %v", d.name, d.value, err)
+			}
+			*d.dur = dur
+		}
+	}
+
+	if pc.Blunderbuss.FairnessWindow != "" {
+		dur, err := time.ParseDuration(pc.Blunderbuss.FairnessWindow)
+		if err != nil {
+			return fmt.Errorf("failed to compile fairness window duration: %q, error: %v", pc.Blunderbuss.FairnessWindow, err)
+		}
+		pc.Blunderbuss.FairnessWindowDuration = dur
+	}
 	return nil
 }
 
@@ -1224,6 +1587,9 @@ func (c *Configuration) Validate() error {
 	if err := validateBlunderbuss(&c.Blunderbuss); err != nil {
 		return err
 	}
+	if err := validateLifecycle(c.Lifecycle); err != nil {
+		return err
+	}
 	if err := validateConfigUpdater(&c.ConfigUpdater); err != nil {
 		return err
 	}
@@ -1239,6 +1605,9 @@ func (c *Configuration) Validate() error {
 	if err := validateTrigger(c.Triggers); err != nil {
 		return err
 	}
+	if err := validateJobDiff(c.JobDiff); err != nil {
+		return err
+	}
 
 	return nil
 }
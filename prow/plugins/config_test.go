@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 
@@ -1520,3 +1521,228 @@ orgA/repoB:
 		t.Error("expected unmarshal error but didn't get one")
 	}
 }
+
+func TestValidateJobDiff(t *testing.T) {
+	testCases := []struct {
+		name        string
+		jobDiff     []JobDiff
+		expectedErr bool
+	}{
+		{
+			name:    "valid",
+			jobDiff: []JobDiff{{Repos: []string{"org"}, ConfigPath: "config/prow/config.yaml", JobConfigPath: "config/jobs"}},
+		},
+		{
+			name:        "missing config path",
+			jobDiff:     []JobDiff{{Repos: []string{"org"}, JobConfigPath: "config/jobs"}},
+			expectedErr: true,
+		},
+		{
+			name:        "absolute job config path",
+			jobDiff:     []JobDiff{{Repos: []string{"org"}, ConfigPath: "config/prow/config.yaml", JobConfigPath: "/config/jobs"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateJobDiff(tc.jobDiff); (err != nil) != tc.expectedErr {
+				t.Errorf("expected error %t, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestJiraLifecycleOptionsForBranch(t *testing.T) {
+	open, review, closed, yes := "Open", "Code Review", "Closed", true
+	lifecycle := JiraLifecycle{
+		Default: map[string]JiraBranchOptions{
+			"*": {ValidStatuses: &[]string{open}, StatusAfterValidation: &review},
+		},
+		Orgs: map[string]JiraOrgOptions{
+			"org": {
+				Default: map[string]JiraBranchOptions{
+					"release-1.0": {StatusAfterClose: &closed},
+				},
+				Repos: map[string]JiraRepoOptions{
+					"repo": {Branches: map[string]JiraBranchOptions{
+						"release-1.0": {ExcludeDefaults: &yes, StatusAfterMerge: &closed},
+					}},
+				},
+			},
+		},
+	}
+	unconfigured := JiraLifecycle{Orgs: map[string]JiraOrgOptions{"org": {Repos: map[string]JiraRepoOptions{"repo": {}}}}}
+
+	var testCases = []struct {
+		name               string
+		lifecycle          JiraLifecycle
+		org, repo, branch  string
+		expected           JiraBranchOptions
+		expectedConfigured bool
+	}{
+		{
+			name:               "global defaults apply to other orgs",
+			lifecycle:          lifecycle,
+			org:                "other",
+			repo:               "repo",
+			branch:             "main",
+			expected:           JiraBranchOptions{ValidStatuses: &[]string{open}, StatusAfterValidation: &review},
+			expectedConfigured: true,
+		},
+		{
+			name:               "org defaults are merged with global defaults",
+			lifecycle:          lifecycle,
+			org:                "org",
+			repo:               "other",
+			branch:             "release-1.0",
+			expected:           JiraBranchOptions{ValidStatuses: &[]string{open}, StatusAfterValidation: &review, StatusAfterClose: &closed},
+			expectedConfigured: true,
+		},
+		{
+			name:               "branch options can exclude defaults",
+			lifecycle:          lifecycle,
+			org:                "org",
+			repo:               "repo",
+			branch:             "release-1.0",
+			expected:           JiraBranchOptions{ExcludeDefaults: &yes, StatusAfterMerge: &closed},
+			expectedConfigured: true,
+		},
+		{
+			name:      "branches without options are not configured",
+			lifecycle: unconfigured,
+			org:       "org",
+			repo:      "repo",
+			branch:    "main",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, configured := tc.lifecycle.OptionsForBranch(tc.org, tc.repo, tc.branch)
+			if configured != tc.expectedConfigured {
+				t.Errorf("expected configured to be %t, got %t", tc.expectedConfigured, configured)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("unexpected options (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutOfOfficeCovers(t *testing.T) {
+	testCases := []struct {
+		name        string
+		outOfOffice OutOfOffice
+		time        time.Time
+		expected    bool
+		expectedErr bool
+	}{
+		{
+			name:        "before the start",
+			outOfOffice: OutOfOffice{User: "alice", Start: "2021-06-01", End: "2021-06-14"},
+			time:        time.Date(2021, 5, 31, 23, 59, 0, 0, time.UTC),
+		},
+		{
+			name:        "first day",
+			outOfOffice: OutOfOffice{User: "alice", Start: "2021-06-01", End: "2021-06-14"},
+			time:        time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			expected:    true,
+		},
+		{
+			name:        "last day",
+			outOfOffice: OutOfOffice{User: "alice", Start: "2021-06-01", End: "2021-06-14"},
+			time:        time.Date(2021, 6, 14, 23, 59, 0, 0, time.UTC),
+			expected:    true,
+		},
+		{
+			name:        "after the end",
+			outOfOffice: OutOfOffice{User: "alice", Start: "2021-06-01", End: "2021-06-14"},
+			time:        time.Date(2021, 6, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "invalid start",
+			outOfOffice: OutOfOffice{User: "alice", Start: "June 1st", End: "2021-06-14"},
+			expectedErr: true,
+		},
+		{
+			name:        "end before start",
+			outOfOffice: OutOfOffice{User: "alice", Start: "2021-06-14", End: "2021-06-01"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			covers, err := tc.outOfOffice.Covers(tc.time)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if covers != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, covers)
+			}
+		})
+	}
+}
+
+func TestValidateLifecycle(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policies    []Lifecycle
+		expectedErr bool
+	}{
+		{
+			name:     "valid policies",
+			policies: []Lifecycle{{Repos: []string{"org"}, StaleAfter: "24h"}, {Repos: []string{"org/repo"}, CloseAfter: "24h"}},
+		},
+		{
+			name:        "no repos",
+			policies:    []Lifecycle{{StaleAfter: "24h"}},
+			expectedErr: true,
+		},
+		{
+			name:        "repo with two policies",
+			policies:    []Lifecycle{{Repos: []string{"org"}}, {Repos: []string{"org"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid duration",
+			policies:    []Lifecycle{{Repos: []string{"org"}, StaleAfter: "90d"}},
+			expectedErr: true,
+		},
+		{
+			name:        "negative duration",
+			policies:    []Lifecycle{{Repos: []string{"org"}, GracePeriod: "-1h"}},
+			expectedErr: true,
+		},
+		{
+			name:        "skips everything",
+			policies:    []Lifecycle{{Repos: []string{"org"}, SkipIssues: true, SkipPullRequests: true}},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Configuration{Lifecycle: tc.policies}
+			err := compileRegexpsAndDurations(c)
+			if err == nil {
+				err = validateLifecycle(c.Lifecycle)
+			}
+			if (err != nil) != tc.expectedErr {
+				t.Errorf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestLifecycleGracePeriodDefault(t *testing.T) {
+	c := &Configuration{Lifecycle: []Lifecycle{{Repos: []string{"org"}}, {Repos: []string{"org/repo"}, GracePeriod: "0s"}}}
+	c.setDefaults()
+	if err := compileRegexpsAndDurations(c); err != nil {
+		t.Fatalf("failed to compile durations: %v", err)
+	}
+	if actual := c.Lifecycle[0].GracePeriodDuration; actual != 7*24*time.Hour {
+		t.Errorf("expected the grace period to default to a week, got %v", actual)
+	}
+	if actual := c.Lifecycle[1].GracePeriodDuration; actual != 0 {
+		t.Errorf("expected an explicit grace period of 0s to disable it, got %v", actual)
+	}
+}
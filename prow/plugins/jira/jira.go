@@ -31,6 +31,7 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	jiraclient "k8s.io/test-infra/prow/jira"
+	"k8s.io/test-infra/prow/labels"
 	"k8s.io/test-infra/prow/pluginhelp"
 	"k8s.io/test-infra/prow/plugins"
 )
@@ -61,13 +62,37 @@ func extractCandidatesFromText(t string) []string {
 
 func init() {
 	plugins.RegisterGenericCommentHandler(PluginName, handleGenericComment, helpProvider)
+	plugins.RegisterPullRequestHandler(PluginName, handlePullRequest, helpProvider)
 }
 
-func helpProvider(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
-	// The Config field is omitted because this plugin is not configurable.
+func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	configInfo := map[string]string{}
+	if config.Jira != nil {
+		for _, repo := range enabledRepos {
+			if options, configured := config.Jira.Lifecycle.OptionsForBranch(repo.Org, repo.Repo, plugins.JiraOptionsWildcard); configured {
+				configInfo[repo.String()] = describeLifecycle(options)
+			}
+		}
+	}
 	pluginHelp := &pluginhelp.PluginHelp{
-		Description: "The Jira plugin links Pull Requests and Issues to Jira issues",
+		Description: `The Jira plugin links Pull Requests and Issues to Jira issues.
+When the lifecycle is configured for a branch, the plugin also validates the Jira issue referenced in the title of pull requests, applies the ` + labels.JiraValidRef + ` or ` + labels.JiraInvalidRef + ` label and transitions the issue as the pull request is opened, merged or closed.`,
+		Config: configInfo,
 	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/jira refresh",
+		Description: "Check Jira for a valid issue referenced in the PR title",
+		Featured:    false,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/jira refresh"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/jira cherrypick",
+		Description: "Clone the Jira issue of the pull request this automated cherry-pick was created from for the target version of the branch",
+		Featured:    false,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/jira cherrypick"},
+	})
 	return pluginHelp, nil
 }
 
@@ -78,7 +103,10 @@ type githubClient interface {
 }
 
 func handleGenericComment(pc plugins.Agent, e github.GenericCommentEvent) error {
-	return handle(pc.JiraClient, pc.GitHubClient, pc.PluginConfig.Jira, pc.Logger, &e)
+	return utilerrors.NewAggregate([]error{
+		handleLifecycleComment(pc.JiraClient, pc.GitHubClient, pc.PluginConfig.Jira, pc.Logger, e),
+		handle(pc.JiraClient, pc.GitHubClient, pc.PluginConfig.Jira, pc.Logger, &e),
+	})
 }
 
 func handle(jc jiraclient.Client, ghc githubClient, cfg *plugins.Jira, log *logrus.Entry, e *github.GenericCommentEvent) error {
@@ -133,7 +161,7 @@ func handleWithProjectCache(jc jiraclient.Client, ghc githubClient, cfg *plugins
 		wg.Add(1)
 		go func(issue string) {
 			defer wg.Done()
-			if err := upsertGitHubLinkToIssue(log, issue, jc, e); err != nil {
+			if err := upsertGitHubLinkToIssue(log, issue, jc, e.HTMLURL, fmt.Sprintf("%s#%d: %s", e.Repo.FullName, e.Number, e.IssueTitle)); err != nil {
 				log.WithField("Issue", issue).WithError(err).Error("Failed to ensure GitHub link on Jira issue")
 			}
 		}(issue)
@@ -224,13 +252,12 @@ func replaceStringIfHasntSquareBracketOrSlashPrefix(text, old, new string) strin
 	return result
 }
 
-func upsertGitHubLinkToIssue(log *logrus.Entry, issueID string, jc jiraclient.Client, e *github.GenericCommentEvent) error {
+func upsertGitHubLinkToIssue(log *logrus.Entry, issueID string, jc jiraclient.Client, url, title string) error {
 	links, err := jc.GetRemoteLinks(issueID)
 	if err != nil {
 		return fmt.Errorf("failed to get remote links: %w", err)
 	}
 
-	url := e.HTMLURL
 	if idx := strings.Index(url, "#"); idx != -1 {
 		url = url[:idx]
 	}
@@ -243,7 +270,7 @@ func upsertGitHubLinkToIssue(log *logrus.Entry, issueID string, jc jiraclient.Cl
 	link := &jira.RemoteLink{
 		Object: &jira.RemoteLinkObject{
 			URL:   url,
-			Title: fmt.Sprintf("%s#%d: %s", e.Repo.FullName, e.Number, e.IssueTitle),
+			Title: title,
 			Icon: &jira.RemoteLinkIcon{
 				Url16x16: "https://github.com/favicon.ico",
 				Title:    "GitHub",
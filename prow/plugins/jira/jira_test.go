@@ -100,6 +100,7 @@ func TestRegex(t *testing.T) {
 }
 
 type fakeJiraClient struct {
+	jiraclient.Client
 	existingIssues []jira.Issue
 	existingLinks  map[string][]jira.RemoteLink
 	newLinks       []jira.RemoteLink
@@ -0,0 +1,685 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	jiraclient "k8s.io/test-infra/prow/jira"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+var (
+	titleMatch             = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-[0-9]+):`)
+	refreshCommandMatch    = regexp.MustCompile(`(?mi)^/jira refresh\s*$`)
+	cherrypickCommandMatch = regexp.MustCompile(`(?mi)^/jira cherrypick\s*$`)
+	cherrypickPRMatch      = regexp.MustCompile(`This is an automated cherry-pick of #([0-9]+)`)
+	pullRequestURLMatch    = regexp.MustCompile(`/([^/]+)/([^/]+)/pull/([0-9]+)$`)
+)
+
+const (
+	issueLink = `[Jira issue %s](%s/browse/%s)`
+	// clonersLinkType is the Jira issue link type that links cherry-pick
+	// issues to the issue they were cloned from.
+	clonersLinkType = "Cloners"
+)
+
+type lifecycleGitHubClient interface {
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	CreateComment(owner, repo string, number int, comment string) error
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	AddLabel(owner, repo string, number int, label string) error
+	RemoveLabel(owner, repo string, number int, label string) error
+}
+
+type lifecycleEvent struct {
+	org, repo, baseRef     string
+	number                 int
+	issueKey               string
+	missing                bool
+	merged, closed, opened bool
+	state, title           string
+	body, htmlURL, login   string
+	cherrypick             bool
+	cherrypickFromPRNum    int
+}
+
+func (e *lifecycleEvent) comment(gc lifecycleGitHubClient) func(body string) error {
+	return func(body string) error {
+		return gc.CreateComment(e.org, e.repo, e.number, plugins.FormatResponseRaw(e.body, e.htmlURL, e.login, body))
+	}
+}
+
+func (e *lifecycleEvent) pullRequestURL() string {
+	if idx := strings.Index(e.htmlURL, "#"); idx != -1 {
+		return e.htmlURL[:idx]
+	}
+	return e.htmlURL
+}
+
+// issueKeyFromTitle returns the key of the Jira issue referenced in the title,
+// ignoring issues in disabled projects.
+func issueKeyFromTitle(title string, cfg *plugins.Jira) (string, bool) {
+	mat := titleMatch.FindStringSubmatch(title)
+	if mat == nil {
+		return "", true
+	}
+	project := strings.ToLower(strings.Split(mat[1], "-")[0])
+	for _, disabled := range cfg.DisabledJiraProjects {
+		if strings.ToLower(disabled) == project {
+			return "", true
+		}
+	}
+	return mat[1], false
+}
+
+func handlePullRequest(pc plugins.Agent, pre github.PullRequestEvent) error {
+	cfg := pc.PluginConfig.Jira
+	if cfg == nil {
+		return nil
+	}
+	options, configured := cfg.Lifecycle.OptionsForBranch(pre.PullRequest.Base.Repo.Owner.Login, pre.PullRequest.Base.Repo.Name, pre.PullRequest.Base.Ref)
+	if !configured {
+		return nil
+	}
+	event, err := digestPR(pc.Logger, pre, cfg, options.ValidateByDefault)
+	if err != nil || event == nil {
+		return err
+	}
+	return handleLifecycle(*event, pc.GitHubClient, pc.JiraClient, options, pc.Logger)
+}
+
+// digestPR determines if any action is necessary and creates the event for handleLifecycle() if it is
+func digestPR(log *logrus.Entry, pre github.PullRequestEvent, cfg *plugins.Jira, validateByDefault *bool) (*lifecycleEvent, error) {
+	// These are the only actions indicating the PR title may have changed or that the PR merged or was closed
+	if pre.Action != github.PullRequestActionOpened &&
+		pre.Action != github.PullRequestActionReopened &&
+		pre.Action != github.PullRequestActionEdited &&
+		pre.Action != github.PullRequestActionClosed {
+		return nil, nil
+	}
+
+	e := &lifecycleEvent{
+		org:     pre.PullRequest.Base.Repo.Owner.Login,
+		repo:    pre.PullRequest.Base.Repo.Name,
+		baseRef: pre.PullRequest.Base.Ref,
+		number:  pre.PullRequest.Number,
+		merged:  pre.PullRequest.Merged,
+		closed:  pre.Action == github.PullRequestActionClosed,
+		opened:  pre.Action == github.PullRequestActionOpened,
+		state:   pre.PullRequest.State,
+		title:   pre.PullRequest.Title,
+		body:    pre.PullRequest.Title,
+		htmlURL: pre.PullRequest.HTMLURL,
+		login:   pre.PullRequest.User.Login,
+	}
+	e.issueKey, e.missing = issueKeyFromTitle(e.title, cfg)
+
+	if match := cherrypickPRMatch.FindStringSubmatch(pre.PullRequest.Body); match != nil && e.opened {
+		// the regex guarantees that this is a number
+		e.cherrypickFromPRNum, _ = strconv.Atoi(match[1])
+		e.cherrypick = true
+		return e, nil
+	}
+
+	if e.closed {
+		return e, nil
+	}
+
+	// when the title did not previously reference an issue, we only want to
+	// handle the event if an issue is currently referenced or we are validating
+	// by default
+	var intermediate *lifecycleEvent
+	if !e.missing || (validateByDefault != nil && *validateByDefault) {
+		intermediate = e
+	}
+
+	var changes struct {
+		Title struct {
+			From string `json:"from"`
+		} `json:"title"`
+	}
+	if err := json.Unmarshal(pre.Changes, &changes); err != nil {
+		// we're detecting this best-effort so we can handle it anyway
+		return intermediate, nil
+	}
+	previousKey, missing := issueKeyFromTitle(changes.Title.From, cfg)
+	if missing {
+		return intermediate, nil
+	}
+	if previousKey == e.issueKey {
+		log.Debugf("Referenced Jira issue (%s) has not changed, not handling event.", e.issueKey)
+		return nil, nil
+	}
+	// the PR previously referenced an issue, so whether it currently does or
+	// does not, we should handle the event to update the labels
+	return e, nil
+}
+
+func handleLifecycleComment(jc jiraclient.Client, gc lifecycleGitHubClient, cfg *plugins.Jira, log *logrus.Entry, gce github.GenericCommentEvent) error {
+	if cfg == nil || gce.Action != github.GenericCommentActionCreated {
+		return nil
+	}
+	refresh, cherrypick := refreshCommandMatch.MatchString(gce.Body), cherrypickCommandMatch.MatchString(gce.Body)
+	if !refresh && !cherrypick {
+		return nil
+	}
+	var (
+		org    = gce.Repo.Owner.Login
+		repo   = gce.Repo.Name
+		number = gce.Number
+	)
+	respond := func(response string) error {
+		return gc.CreateComment(org, repo, number, plugins.FormatResponseRaw(gce.Body, gce.HTMLURL, gce.User.Login, response))
+	}
+
+	// We don't support linking issues to Jira issues
+	if !gce.IsPR {
+		log.Debug("Jira command requested on an issue, ignoring")
+		return respond("Jira issue referencing is only supported for Pull Requests, not issues.")
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	options, configured := cfg.Lifecycle.OptionsForBranch(org, repo, pr.Base.Ref)
+	if !configured {
+		return respond(fmt.Sprintf("The Jira lifecycle is not configured for the %s branch.", pr.Base.Ref))
+	}
+
+	e := &lifecycleEvent{org: org, repo: repo, baseRef: pr.Base.Ref, number: number, merged: pr.Merged, state: pr.State, title: pr.Title, body: gce.Body, htmlURL: gce.HTMLURL, login: gce.User.Login}
+	e.issueKey, e.missing = issueKeyFromTitle(pr.Title, cfg)
+	if cherrypick {
+		match := cherrypickPRMatch.FindStringSubmatch(pr.Body)
+		if match == nil {
+			return respond("This pull request is not an automated cherry-pick, so there is no Jira issue to clone.")
+		}
+		e.cherrypickFromPRNum, _ = strconv.Atoi(match[1])
+		e.cherrypick = true
+	}
+	return handleLifecycle(*e, gc, jc, options, log)
+}
+
+func handleLifecycle(e lifecycleEvent, gc lifecycleGitHubClient, jc jiraclient.Client, options plugins.JiraBranchOptions, log *logrus.Entry) error {
+	// merges, closes and cherrypicks follow a different pattern from the normal validation
+	switch {
+	case e.merged:
+		return handleMerge(e, gc, jc, options, log)
+	case e.closed:
+		return handleClose(e, gc, jc, options, log)
+	case e.cherrypick:
+		return handleCherrypick(e, gc, jc, options, log)
+	}
+
+	comment := e.comment(gc)
+	var needsValidLabel, needsInvalidLabel bool
+	var response string
+	if e.missing {
+		log.Debug("No Jira issue referenced.")
+		response = `No Jira issue is referenced in the title of this pull request.
+To reference an issue, add 'ABC-123:' to the title of this pull request and request another issue refresh with <code>/jira refresh</code>.`
+	} else {
+		log = log.WithField("issue", e.issueKey)
+		issue, err := getIssue(jc, e.issueKey, log, comment)
+		if err != nil || issue == nil {
+			return err
+		}
+
+		valid, validationsRun, why := validateIssue(issue, options)
+		needsValidLabel, needsInvalidLabel = valid, !valid
+		if valid {
+			log.Debug("Valid issue found.")
+			response = fmt.Sprintf("This pull request references "+issueLink+", which is valid.", e.issueKey, jc.JiraURL(), e.issueKey)
+			if options.StatusAfterValidation != nil {
+				moved, err := transitionIssue(jc, issue, *options.StatusAfterValidation)
+				if err != nil {
+					log.WithError(err).Warn("Unexpected error transitioning Jira issue.")
+					return comment(formatError(fmt.Sprintf("moving to the %s status", *options.StatusAfterValidation), jc.JiraURL(), e.issueKey, err))
+				}
+				if moved {
+					response += fmt.Sprintf(" The issue has been moved to the %s status.", *options.StatusAfterValidation)
+				}
+			}
+			if err := upsertGitHubLinkToIssue(log, e.issueKey, jc, e.pullRequestURL(), fmt.Sprintf("%s/%s#%d: %s", e.org, e.repo, e.number, e.title)); err != nil {
+				log.WithError(err).Warn("Unexpected error linking the pull request to the Jira issue.")
+				return comment(formatError("linking this pull request", jc.JiraURL(), e.issueKey, err))
+			}
+
+			response += "\n\n<details>"
+			if len(validationsRun) == 0 {
+				response += "<summary>No validations were run on this issue</summary>"
+			} else {
+				response += fmt.Sprintf("<summary>%d validation(s) were run on this issue</summary>\n", len(validationsRun))
+			}
+			for _, validation := range validationsRun {
+				response += fmt.Sprint("\n* ", validation)
+			}
+			response += "</details>"
+		} else {
+			log.Debug("Invalid issue found.")
+			var formattedReasons string
+			for _, reason := range why {
+				formattedReasons += fmt.Sprintf(" - %s\n", reason)
+			}
+			response = fmt.Sprintf(`This pull request references `+issueLink+`, which is invalid:
+%s
+Comment <code>/jira refresh</code> to re-evaluate validity if changes to the Jira issue are made, or edit the title of this pull request to link to a different issue.`, e.issueKey, jc.JiraURL(), e.issueKey, formattedReasons)
+		}
+	}
+
+	syncLabels(e, gc, needsValidLabel, needsInvalidLabel, log)
+	return comment(response)
+}
+
+// syncLabels ensures the label state is correct. Errors are not propagated
+// as it is more important to report to the user than to fail early on a
+// label check.
+func syncLabels(e lifecycleEvent, gc lifecycleGitHubClient, needsValidLabel, needsInvalidLabel bool, log *logrus.Entry) {
+	currentLabels, err := gc.GetIssueLabels(e.org, e.repo, e.number)
+	if err != nil {
+		log.WithError(err).Warn("Could not list labels on PR")
+	}
+	var hasValidLabel, hasInvalidLabel bool
+	for _, l := range currentLabels {
+		switch l.Name {
+		case labels.JiraValidRef:
+			hasValidLabel = true
+		case labels.JiraInvalidRef:
+			hasInvalidLabel = true
+		}
+	}
+
+	for _, label := range []struct {
+		name       string
+		needs, has bool
+	}{
+		{name: labels.JiraValidRef, needs: needsValidLabel, has: hasValidLabel},
+		{name: labels.JiraInvalidRef, needs: needsInvalidLabel, has: hasInvalidLabel},
+	} {
+		if label.needs && !label.has {
+			if err := gc.AddLabel(e.org, e.repo, e.number, label.name); err != nil {
+				log.WithError(err).Errorf("Failed to add %s label.", label.name)
+			}
+		} else if !label.needs && label.has {
+			if err := gc.RemoveLabel(e.org, e.repo, e.number, label.name); err != nil {
+				log.WithError(err).Errorf("Failed to remove %s label.", label.name)
+			}
+		}
+	}
+}
+
+func issueStatus(issue *jira.Issue) string {
+	if issue.Fields == nil || issue.Fields.Status == nil {
+		return ""
+	}
+	return issue.Fields.Status.Name
+}
+
+func issueHasStatus(issue *jira.Issue, statuses []string) bool {
+	for _, status := range statuses {
+		if strings.EqualFold(issueStatus(issue), status) {
+			return true
+		}
+	}
+	return false
+}
+
+func issueHasFixVersion(issue *jira.Issue, version string) bool {
+	if issue.Fields == nil {
+		return false
+	}
+	for _, fixVersion := range issue.Fields.FixVersions {
+		if fixVersion != nil && fixVersion.Name == version {
+			return true
+		}
+	}
+	return false
+}
+
+func allowedStatuses(options plugins.JiraBranchOptions) []string {
+	var allowed []string
+	if options.ValidStatuses != nil {
+		allowed = append(allowed, *options.ValidStatuses...)
+	}
+	if options.StatusAfterValidation != nil {
+		allowed = append(allowed, *options.StatusAfterValidation)
+	}
+	return allowed
+}
+
+// validateIssue determines if the issue matches the options and returns a description of why not
+func validateIssue(issue *jira.Issue, options plugins.JiraBranchOptions) (bool, []string, []string) {
+	valid := true
+	var errors, validations []string
+
+	if options.ValidStatuses != nil {
+		allowed := allowedStatuses(options)
+		if !issueHasStatus(issue, allowed) {
+			valid = false
+			errors = append(errors, fmt.Sprintf("expected the issue to be in one of the following statuses: %s, but it is %s instead", strings.Join(allowed, ", "), issueStatus(issue)))
+		} else {
+			validations = append(validations, fmt.Sprintf("issue is in the status %s, which is one of the valid statuses (%s)", issueStatus(issue), strings.Join(allowed, ", ")))
+		}
+	}
+
+	if options.TargetVersion != nil {
+		if !issueHasFixVersion(issue, *options.TargetVersion) {
+			valid = false
+			var versions []string
+			if issue.Fields != nil {
+				for _, fixVersion := range issue.Fields.FixVersions {
+					versions = append(versions, fixVersion.Name)
+				}
+			}
+			if len(versions) == 0 {
+				errors = append(errors, fmt.Sprintf("expected the issue to target the %q version, but no fix version was set", *options.TargetVersion))
+			} else {
+				errors = append(errors, fmt.Sprintf("expected the issue to target the %q version, but it targets %s instead", *options.TargetVersion, strings.Join(versions, ", ")))
+			}
+		} else {
+			validations = append(validations, fmt.Sprintf("issue fix versions contain the configured target version for branch (%s)", *options.TargetVersion))
+		}
+	}
+
+	return valid, validations, errors
+}
+
+// transitionIssue moves the issue to the status unless it is already in it and
+// reports whether the issue was moved.
+func transitionIssue(jc jiraclient.Client, issue *jira.Issue, status string) (bool, error) {
+	if strings.EqualFold(issueStatus(issue), status) {
+		return false, nil
+	}
+	transitions, err := jc.GetTransitions(issue.Key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get transitions: %w", err)
+	}
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.To.Name, status) {
+			return true, jc.DoTransition(issue.Key, transition.ID)
+		}
+	}
+	return false, fmt.Errorf("no transition to the %s status is available from the %s status", status, issueStatus(issue))
+}
+
+type linkedPullRequest struct {
+	org, repo string
+	number    int
+}
+
+func (pr linkedPullRequest) String() string {
+	return fmt.Sprintf("[%s/%s#%d](https://github.com/%s/%s/pull/%d)", pr.org, pr.repo, pr.number, pr.org, pr.repo, pr.number)
+}
+
+// linkedPullRequests returns the GitHub pull requests linked to the issue
+// other than the one of the event.
+func linkedPullRequests(jc jiraclient.Client, e lifecycleEvent) ([]linkedPullRequest, error) {
+	links, err := jc.GetRemoteLinks(e.issueKey)
+	if err != nil {
+		return nil, err
+	}
+	var prs []linkedPullRequest
+	for _, link := range links {
+		if link.Object == nil {
+			continue
+		}
+		match := pullRequestURLMatch.FindStringSubmatch(link.Object.URL)
+		if match == nil {
+			continue
+		}
+		// the regex guarantees that this is a number
+		number, _ := strconv.Atoi(match[3])
+		pr := linkedPullRequest{org: match[1], repo: match[2], number: number}
+		if pr.org == e.org && pr.repo == e.repo && pr.number == e.number {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+func handleMerge(e lifecycleEvent, gc lifecycleGitHubClient, jc jiraclient.Client, options plugins.JiraBranchOptions, log *logrus.Entry) error {
+	if options.StatusAfterMerge == nil || e.missing {
+		return nil
+	}
+	comment := e.comment(gc)
+	issue, err := getIssue(jc, e.issueKey, log, comment)
+	if err != nil || issue == nil {
+		return err
+	}
+	// we should only transition if we can be fairly certain that the issue
+	// is not in a status that required human intervention to get to.
+	if allowed := allowedStatuses(options); len(allowed) > 0 && !issueHasStatus(issue, allowed) {
+		return comment(fmt.Sprintf(issueLink+" is in an unrecognized status (%s) and will not be moved to the %s status.", e.issueKey, jc.JiraURL(), e.issueKey, issueStatus(issue), *options.StatusAfterMerge))
+	}
+
+	prs, err := linkedPullRequests(jc, e)
+	if err != nil {
+		log.WithError(err).Warn("Unexpected error listing remote links of Jira issue.")
+		return comment(formatError("searching for linked pull requests", jc.JiraURL(), e.issueKey, err))
+	}
+	var unmerged []string
+	for _, linked := range prs {
+		pr, err := gc.GetPullRequest(linked.org, linked.repo, linked.number)
+		if err != nil {
+			log.WithError(err).Warn("Unexpected error checking merge state of related pull request.")
+			return comment(formatError(fmt.Sprintf("checking the state of a related pull request at https://github.com/%s/%s/pull/%d", linked.org, linked.repo, linked.number), jc.JiraURL(), e.issueKey, err))
+		}
+		if !pr.Merged {
+			unmerged = append(unmerged, fmt.Sprintf(" * %s is %s", linked, pr.State))
+		}
+	}
+	if len(unmerged) > 0 {
+		return comment(fmt.Sprintf(`The following pull requests linked to `+issueLink+` have not merged:
+%s
+
+These pull requests must merge or be unlinked from the Jira issue in order for it to move to the %s status. Once unlinked, request an issue refresh with <code>/jira refresh</code>.`, e.issueKey, jc.JiraURL(), e.issueKey, strings.Join(unmerged, "\n"), *options.StatusAfterMerge))
+	}
+
+	if _, err := transitionIssue(jc, issue, *options.StatusAfterMerge); err != nil {
+		log.WithError(err).Warn("Unexpected error transitioning Jira issue.")
+		return comment(formatError(fmt.Sprintf("moving to the %s status", *options.StatusAfterMerge), jc.JiraURL(), e.issueKey, err))
+	}
+	return comment(fmt.Sprintf("All pull requests linked to "+issueLink+" have merged. The issue has been moved to the %s status.", e.issueKey, jc.JiraURL(), e.issueKey, *options.StatusAfterMerge))
+}
+
+func handleClose(e lifecycleEvent, gc lifecycleGitHubClient, jc jiraclient.Client, options plugins.JiraBranchOptions, log *logrus.Entry) error {
+	if options.StatusAfterClose == nil || e.missing {
+		return nil
+	}
+	comment := e.comment(gc)
+	prs, err := linkedPullRequests(jc, e)
+	if err != nil {
+		log.WithError(err).Warn("Unexpected error listing remote links of Jira issue.")
+		return comment(formatError("searching for linked pull requests", jc.JiraURL(), e.issueKey, err))
+	}
+	for _, linked := range prs {
+		pr, err := gc.GetPullRequest(linked.org, linked.repo, linked.number)
+		if err != nil {
+			log.WithError(err).Warn("Unexpected error checking state of related pull request.")
+			return comment(formatError(fmt.Sprintf("checking the state of a related pull request at https://github.com/%s/%s/pull/%d", linked.org, linked.repo, linked.number), jc.JiraURL(), e.issueKey, err))
+		}
+		if pr.Merged || pr.State != "closed" {
+			// the issue is still being worked on or was fixed elsewhere
+			return nil
+		}
+	}
+
+	issue, err := getIssue(jc, e.issueKey, log, comment)
+	if err != nil || issue == nil {
+		return err
+	}
+	moved, err := transitionIssue(jc, issue, *options.StatusAfterClose)
+	if err != nil {
+		log.WithError(err).Warn("Unexpected error transitioning Jira issue.")
+		return comment(formatError(fmt.Sprintf("moving to the %s status", *options.StatusAfterClose), jc.JiraURL(), e.issueKey, err))
+	}
+	if !moved {
+		return nil
+	}
+	return comment(fmt.Sprintf("All pull requests linked to "+issueLink+" have been closed. The issue has been moved to the %s status.", e.issueKey, jc.JiraURL(), e.issueKey, *options.StatusAfterClose))
+}
+
+func handleCherrypick(e lifecycleEvent, gc lifecycleGitHubClient, jc jiraclient.Client, options plugins.JiraBranchOptions, log *logrus.Entry) error {
+	comment := e.comment(gc)
+	parent, err := gc.GetPullRequest(e.org, e.repo, e.cherrypickFromPRNum)
+	if err != nil {
+		log.WithError(err).Warn("Unexpected error getting title of pull request being cherrypicked from.")
+		return comment(fmt.Sprintf("Error creating a cherry-pick issue in Jira: failed to check the state of cherrypicked pull request at https://github.com/%s/%s/pull/%d: %v.\nPlease contact an administrator to resolve this issue, then request a cherry-pick with <code>/jira cherrypick</code>.", e.org, e.repo, e.cherrypickFromPRNum, err))
+	}
+	match := titleMatch.FindStringSubmatch(parent.Title)
+	if match == nil {
+		log.Debugf("Parent PR %d doesn't have an associated issue; not creating cherrypicked issue", parent.Number)
+		return nil
+	}
+	key := match[1]
+	// Since getIssue generates a comment itself, we have to add a prefix explaining that this was a cherrypick attempt to the comment
+	commentWithPrefix := func(body string) error {
+		return comment(fmt.Sprintf("Failed to create a cherry-pick issue in Jira: %s", body))
+	}
+	issue, err := getIssue(jc, key, log, commentWithPrefix)
+	if err != nil || issue == nil {
+		return err
+	}
+	oldLink := fmt.Sprintf(issueLink, key, jc.JiraURL(), key)
+	if options.TargetVersion == nil {
+		return comment(fmt.Sprintf("Could not make automatic cherrypick of %s for this PR as the target_version is not set for this branch in the jira plugin config. Running refresh:\n/jira refresh", oldLink))
+	}
+	targetVersion := *options.TargetVersion
+
+	if issue.Fields != nil {
+		for _, link := range issue.Fields.IssueLinks {
+			if link == nil || link.Type.Name != clonersLinkType {
+				continue
+			}
+			for _, linked := range []*jira.Issue{link.InwardIssue, link.OutwardIssue} {
+				if linked == nil || linked.Key == key {
+					continue
+				}
+				clone, err := jc.GetIssue(linked.Key)
+				if err != nil {
+					log.WithError(err).Debugf("Failed to get clone %s", linked.Key)
+					continue
+				}
+				if issueHasFixVersion(clone, targetVersion) {
+					return comment(fmt.Sprintf("Detected clone of %s with correct target version. Retitling PR to link to clone:\n/retitle %s", oldLink, strings.Replace(e.title, key, clone.Key, 1)))
+				}
+			}
+		}
+	}
+
+	clone, err := jc.CreateIssue(cloneIssue(issue, targetVersion))
+	if err != nil {
+		log.WithError(err).Debugf("Failed to clone issue %s", key)
+		return comment(formatError("cloning issue for cherrypick", jc.JiraURL(), key, err))
+	}
+	cloneLink := fmt.Sprintf(issueLink, clone.Key, jc.JiraURL(), clone.Key)
+	if err := jc.CreateIssueLink(&jira.IssueLink{
+		Type:         jira.IssueLinkType{Name: clonersLinkType},
+		InwardIssue:  &jira.Issue{Key: key},
+		OutwardIssue: &jira.Issue{Key: clone.Key},
+	}); err != nil {
+		log.WithError(err).Debugf("Failed to link clone %s to issue %s", clone.Key, key)
+		return comment(formatError(fmt.Sprintf("linking cherry-pick issue in Jira: Created cherrypick %s, but encountered error linking it", cloneLink), jc.JiraURL(), clone.Key, err))
+	}
+	newTitle := strings.Replace(e.title, key, clone.Key, 1)
+	if !strings.Contains(e.title, key) {
+		newTitle = fmt.Sprintf("%s: %s", clone.Key, e.title)
+	}
+	return comment(fmt.Sprintf("%s has been cloned as %s. Retitling PR to link against new issue.\n/retitle %s", oldLink, cloneLink, newTitle))
+}
+
+// cloneIssue returns a copy of the issue for creation that targets the version.
+func cloneIssue(issue *jira.Issue, targetVersion string) *jira.Issue {
+	fields := &jira.IssueFields{FixVersions: []*jira.FixVersion{{Name: targetVersion}}}
+	if issue.Fields != nil {
+		fields.Project = jira.Project{Key: issue.Fields.Project.Key}
+		fields.Type = jira.IssueType{Name: issue.Fields.Type.Name}
+		fields.Summary = issue.Fields.Summary
+		fields.Description = issue.Fields.Description
+	}
+	return &jira.Issue{Fields: fields}
+}
+
+func getIssue(jc jiraclient.Client, key string, log *logrus.Entry, comment func(string) error) (*jira.Issue, error) {
+	issue, err := jc.GetIssue(key)
+	if err != nil && !jiraclient.IsNotFound(err) {
+		log.WithError(err).Warn("Unexpected error searching for Jira issue.")
+		return nil, comment(formatError("searching", jc.JiraURL(), key, err))
+	}
+	if jiraclient.IsNotFound(err) || issue == nil {
+		log.Debug("No issue found.")
+		return nil, comment(fmt.Sprintf(`No Jira issue with key %s exists in the tracker at %s.
+Once a valid issue is referenced in the title of this pull request, request an issue refresh with <code>/jira refresh</code>.`,
+			key, jc.JiraURL()))
+	}
+	return issue, nil
+}
+
+func formatError(action, endpoint, key string, err error) string {
+	return fmt.Sprintf(`An error was encountered %s for issue %s on the Jira server at %s.
+
+<details><summary>Full error message.</summary>
+
+<code>
+%v
+</code>
+
+</details>
+
+Please contact an administrator to resolve this issue, then request an issue refresh with <code>/jira refresh</code>.`,
+		action, key, endpoint, err)
+}
+
+// describeLifecycle summarizes the options for the plugin help.
+func describeLifecycle(options plugins.JiraBranchOptions) string {
+	var conditions []string
+	if options.ValidStatuses != nil {
+		conditions = append(conditions, fmt.Sprintf("be in one of the following statuses: %s", strings.Join(allowedStatuses(options), ", ")))
+	}
+	if options.TargetVersion != nil {
+		conditions = append(conditions, fmt.Sprintf("target the %q version", *options.TargetVersion))
+	}
+	message := "By default, valid Jira issues must exist"
+	if len(conditions) > 0 {
+		message = "By default, valid Jira issues must " + strings.Join(conditions, " and ")
+	}
+	message += "."
+	for _, transition := range []struct {
+		when   string
+		status *string
+	}{
+		{when: "being linked to a pull request", status: options.StatusAfterValidation},
+		{when: "all linked pull requests merged", status: options.StatusAfterMerge},
+		{when: "all linked pull requests were closed", status: options.StatusAfterClose},
+	} {
+		if transition.status != nil {
+			message += fmt.Sprintf(" After %s, issues are moved to the %s status.", transition.when, *transition.status)
+		}
+	}
+	return message
+}
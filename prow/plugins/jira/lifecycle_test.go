@@ -0,0 +1,437 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	jiraclient "k8s.io/test-infra/prow/jira"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+func TestDigestPR(t *testing.T) {
+	yes := true
+	cfg := &plugins.Jira{DisabledJiraProjects: []string{"private"}}
+	pr := func(title, body string) github.PullRequest {
+		return github.PullRequest{
+			Base:    github.PullRequestBranch{Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}, Ref: "main"},
+			Number:  1,
+			Title:   title,
+			Body:    body,
+			HTMLURL: "https://github.com/org/repo/pull/1",
+			User:    github.User{Login: "user"},
+		}
+	}
+	changes := func(from string) json.RawMessage {
+		return json.RawMessage(`{"title":{"from":"` + from + `"}}`)
+	}
+
+	var testCases = []struct {
+		name              string
+		event             github.PullRequestEvent
+		validateByDefault *bool
+		expected          *lifecycleEvent
+	}{
+		{
+			name:  "unrelated action is ignored",
+			event: github.PullRequestEvent{Action: github.PullRequestActionLabeled, PullRequest: pr("ABC-1: fix", "")},
+		},
+		{
+			name:  "opened PR referencing an issue is handled",
+			event: github.PullRequestEvent{Action: github.PullRequestActionOpened, PullRequest: pr("ABC-1: fix", "")},
+			expected: &lifecycleEvent{
+				org: "org", repo: "repo", baseRef: "main", number: 1, issueKey: "ABC-1", opened: true,
+				title: "ABC-1: fix", body: "ABC-1: fix", htmlURL: "https://github.com/org/repo/pull/1", login: "user",
+			},
+		},
+		{
+			name:  "opened PR not referencing an issue is ignored",
+			event: github.PullRequestEvent{Action: github.PullRequestActionOpened, PullRequest: pr("fix", "")},
+		},
+		{
+			name:  "opened PR referencing an issue in a disabled project is ignored",
+			event: github.PullRequestEvent{Action: github.PullRequestActionOpened, PullRequest: pr("PRIVATE-1: fix", "")},
+		},
+		{
+			name:              "opened PR not referencing an issue is handled when validating by default",
+			event:             github.PullRequestEvent{Action: github.PullRequestActionOpened, PullRequest: pr("fix", "")},
+			validateByDefault: &yes,
+			expected: &lifecycleEvent{
+				org: "org", repo: "repo", baseRef: "main", number: 1, missing: true, opened: true,
+				title: "fix", body: "fix", htmlURL: "https://github.com/org/repo/pull/1", login: "user",
+			},
+		},
+		{
+			name:  "edited title referencing the same issue is ignored",
+			event: github.PullRequestEvent{Action: github.PullRequestActionEdited, PullRequest: pr("ABC-1: fix things", ""), Changes: changes("ABC-1: fix")},
+		},
+		{
+			name:  "edited title no longer referencing an issue is handled",
+			event: github.PullRequestEvent{Action: github.PullRequestActionEdited, PullRequest: pr("fix", ""), Changes: changes("ABC-1: fix")},
+			expected: &lifecycleEvent{
+				org: "org", repo: "repo", baseRef: "main", number: 1, missing: true,
+				title: "fix", body: "fix", htmlURL: "https://github.com/org/repo/pull/1", login: "user",
+			},
+		},
+		{
+			name:  "opened cherry-pick is handled as such",
+			event: github.PullRequestEvent{Action: github.PullRequestActionOpened, PullRequest: pr("[release-1.0] ABC-1: fix", "This is an automated cherry-pick of #3")},
+			expected: &lifecycleEvent{
+				org: "org", repo: "repo", baseRef: "main", number: 1, issueKey: "ABC-1", opened: true,
+				title: "[release-1.0] ABC-1: fix", body: "[release-1.0] ABC-1: fix", htmlURL: "https://github.com/org/repo/pull/1", login: "user",
+				cherrypick: true, cherrypickFromPRNum: 3,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := digestPR(logrus.WithField("test", tc.name), tc.event, cfg, tc.validateByDefault)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmp.AllowUnexported(lifecycleEvent{})); diff != "" {
+				t.Errorf("unexpected event (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleLifecycle(t *testing.T) {
+	inProgress, codeReview, verified, closed := "In Progress", "Code Review", "Verified", "Closed"
+	version := "v1.0"
+	transitions := []jira.Transition{
+		{ID: "1", Name: "Review", To: jira.Status{Name: codeReview}},
+		{ID: "2", Name: "Verify", To: jira.Status{Name: verified}},
+		{ID: "3", Name: "Close", To: jira.Status{Name: closed}},
+	}
+	newIssue := func(key, status string, fixVersions ...string) *jira.Issue {
+		issue := &jira.Issue{Key: key, Fields: &jira.IssueFields{
+			Project: jira.Project{Key: strings.Split(key, "-")[0]},
+			Type:    jira.IssueType{Name: "Bug"},
+			Summary: "Something is broken",
+			Status:  &jira.Status{Name: status},
+		}}
+		for _, v := range fixVersions {
+			issue.Fields.FixVersions = append(issue.Fields.FixVersions, &jira.FixVersion{Name: v})
+		}
+		return issue
+	}
+	prLink := func(number string) jira.RemoteLink {
+		return jira.RemoteLink{Object: &jira.RemoteLinkObject{URL: "https://github.com/org/repo/pull/" + number}}
+	}
+	options := plugins.JiraBranchOptions{
+		ValidStatuses:         &[]string{inProgress},
+		StatusAfterValidation: &codeReview,
+		StatusAfterMerge:      &verified,
+		StatusAfterClose:      &closed,
+		TargetVersion:         &version,
+	}
+
+	var testCases = []struct {
+		name                  string
+		event                 lifecycleEvent
+		issues                map[string]*jira.Issue
+		remoteLinks           map[string][]jira.RemoteLink
+		prs                   map[int]*github.PullRequest
+		labels                []string
+		options               plugins.JiraBranchOptions
+		expectedLabelsAdded   []string
+		expectedLabelsRemoved []string
+		expectedStatus        string
+		expectedComment       string
+		expectedRemoteLinks   int
+		expectedCreatedIssue  string
+	}{
+		{
+			name:                  "valid issue is transitioned, labeled and linked",
+			event:                 lifecycleEvent{issueKey: "ABC-1", opened: true, title: "ABC-1: fix"},
+			issues:                map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", inProgress, version)},
+			labels:                []string{labels.JiraInvalidRef},
+			options:               options,
+			expectedLabelsAdded:   []string{labels.JiraValidRef},
+			expectedLabelsRemoved: []string{labels.JiraInvalidRef},
+			expectedStatus:        codeReview,
+			expectedComment:       "which is valid. The issue has been moved to the Code Review status.",
+			expectedRemoteLinks:   1,
+		},
+		{
+			name:                  "issue in an invalid status is labeled as invalid",
+			event:                 lifecycleEvent{issueKey: "ABC-1", opened: true, title: "ABC-1: fix"},
+			issues:                map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", closed, version)},
+			labels:                []string{labels.JiraValidRef},
+			options:               options,
+			expectedLabelsAdded:   []string{labels.JiraInvalidRef},
+			expectedLabelsRemoved: []string{labels.JiraValidRef},
+			expectedStatus:        closed,
+			expectedComment:       "expected the issue to be in one of the following statuses: In Progress, Code Review, but it is Closed instead",
+		},
+		{
+			name:                "issue without the target version is invalid",
+			event:               lifecycleEvent{issueKey: "ABC-1", opened: true, title: "ABC-1: fix"},
+			issues:              map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", inProgress)},
+			options:             options,
+			expectedLabelsAdded: []string{labels.JiraInvalidRef},
+			expectedStatus:      inProgress,
+			expectedComment:     `expected the issue to target the "v1.0" version, but no fix version was set`,
+		},
+		{
+			name:            "missing issue is reported",
+			event:           lifecycleEvent{issueKey: "ABC-2", opened: true, title: "ABC-2: fix"},
+			issues:          map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", inProgress, version)},
+			options:         options,
+			expectedStatus:  inProgress,
+			expectedComment: "No Jira issue with key ABC-2 exists in the tracker",
+		},
+		{
+			name:                  "labels are removed when the title no longer references an issue",
+			event:                 lifecycleEvent{missing: true, title: "fix"},
+			issues:                map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", inProgress, version)},
+			labels:                []string{labels.JiraValidRef},
+			options:               options,
+			expectedLabelsRemoved: []string{labels.JiraValidRef},
+			expectedStatus:        inProgress,
+			expectedComment:       "No Jira issue is referenced in the title of this pull request.",
+		},
+		{
+			name:                "merged PR moves the issue when all linked PRs merged",
+			event:               lifecycleEvent{issueKey: "ABC-1", merged: true, closed: true, title: "ABC-1: fix"},
+			issues:              map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", codeReview, version)},
+			remoteLinks:         map[string][]jira.RemoteLink{"ABC-1": {prLink("1"), prLink("2")}},
+			prs:                 map[int]*github.PullRequest{2: {Number: 2, Merged: true, State: "closed"}},
+			options:             options,
+			expectedStatus:      verified,
+			expectedComment:     "All pull requests linked to [Jira issue ABC-1](https://jira.example.com/browse/ABC-1) have merged. The issue has been moved to the Verified status.",
+			expectedRemoteLinks: 2,
+		},
+		{
+			name:                "merged PR does not move the issue when a linked PR is open",
+			event:               lifecycleEvent{issueKey: "ABC-1", merged: true, closed: true, title: "ABC-1: fix"},
+			issues:              map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", codeReview, version)},
+			remoteLinks:         map[string][]jira.RemoteLink{"ABC-1": {prLink("1"), prLink("2")}},
+			prs:                 map[int]*github.PullRequest{2: {Number: 2, State: "open"}},
+			options:             options,
+			expectedStatus:      codeReview,
+			expectedComment:     " * [org/repo#2](https://github.com/org/repo/pull/2) is open",
+			expectedRemoteLinks: 2,
+		},
+		{
+			name:            "merged PR does not move an issue in an unrecognized status",
+			event:           lifecycleEvent{issueKey: "ABC-1", merged: true, closed: true, title: "ABC-1: fix"},
+			issues:          map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", closed, version)},
+			options:         options,
+			expectedStatus:  closed,
+			expectedComment: "is in an unrecognized status (Closed) and will not be moved to the Verified status.",
+		},
+		{
+			name:                "closed PR moves the issue when no other PR is linked",
+			event:               lifecycleEvent{issueKey: "ABC-1", closed: true, title: "ABC-1: fix"},
+			issues:              map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", codeReview, version)},
+			remoteLinks:         map[string][]jira.RemoteLink{"ABC-1": {prLink("1")}},
+			options:             options,
+			expectedStatus:      closed,
+			expectedComment:     "have been closed. The issue has been moved to the Closed status.",
+			expectedRemoteLinks: 1,
+		},
+		{
+			name:                "closed PR does not move the issue when another PR is open",
+			event:               lifecycleEvent{issueKey: "ABC-1", closed: true, title: "ABC-1: fix"},
+			issues:              map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", codeReview, version)},
+			remoteLinks:         map[string][]jira.RemoteLink{"ABC-1": {prLink("1"), prLink("2")}},
+			prs:                 map[int]*github.PullRequest{2: {Number: 2, State: "open"}},
+			options:             options,
+			expectedStatus:      codeReview,
+			expectedRemoteLinks: 2,
+		},
+		{
+			name:                 "cherry-pick clones the issue for the target version",
+			event:                lifecycleEvent{issueKey: "ABC-1", opened: true, title: "[release-1.0] ABC-1: fix", cherrypick: true, cherrypickFromPRNum: 2},
+			issues:               map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", verified, "v2.0")},
+			prs:                  map[int]*github.PullRequest{2: {Number: 2, Title: "ABC-1: fix"}},
+			options:              options,
+			expectedStatus:       verified,
+			expectedComment:      "has been cloned as [Jira issue ABC-2](https://jira.example.com/browse/ABC-2). Retitling PR to link against new issue.\n/retitle [release-1.0] ABC-2: fix",
+			expectedCreatedIssue: "ABC-2",
+		},
+		{
+			name:  "cherry-pick reuses an existing clone for the target version",
+			event: lifecycleEvent{issueKey: "ABC-1", opened: true, title: "[release-1.0] ABC-1: fix", cherrypick: true, cherrypickFromPRNum: 2},
+			issues: map[string]*jira.Issue{
+				"ABC-1": func() *jira.Issue {
+					issue := newIssue("ABC-1", verified, "v2.0")
+					issue.Fields.IssueLinks = []*jira.IssueLink{{Type: jira.IssueLinkType{Name: clonersLinkType}, OutwardIssue: &jira.Issue{Key: "ABC-5"}}}
+					return issue
+				}(),
+				"ABC-5": newIssue("ABC-5", inProgress, version),
+			},
+			prs:             map[int]*github.PullRequest{2: {Number: 2, Title: "ABC-1: fix"}},
+			options:         options,
+			expectedStatus:  verified,
+			expectedComment: "Retitling PR to link to clone:\n/retitle [release-1.0] ABC-5: fix",
+		},
+		{
+			name:            "cherry-pick without a target version is reported",
+			event:           lifecycleEvent{issueKey: "ABC-1", opened: true, title: "[release-1.0] ABC-1: fix", cherrypick: true, cherrypickFromPRNum: 2},
+			issues:          map[string]*jira.Issue{"ABC-1": newIssue("ABC-1", verified, "v2.0")},
+			prs:             map[int]*github.PullRequest{2: {Number: 2, Title: "ABC-1: fix"}},
+			expectedStatus:  verified,
+			expectedComment: "the target_version is not set for this branch",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.event.org, tc.event.repo, tc.event.number = "org", "repo", 1
+			tc.event.htmlURL = "https://github.com/org/repo/pull/1"
+			gc := fakegithub.NewFakeClient()
+			gc.PullRequests = tc.prs
+			for _, label := range tc.labels {
+				gc.IssueLabelsExisting = append(gc.IssueLabelsExisting, "org/repo#1:"+label)
+			}
+			jc := &jiraclient.Fake{URL: "https://jira.example.com", Issues: tc.issues, RemoteLinks: tc.remoteLinks, Transitions: transitions}
+
+			if err := handleLifecycle(tc.event, gc, jc, tc.options, logrus.WithField("test", tc.name)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			prefixed := func(labels []string) []string {
+				var result []string
+				for _, label := range labels {
+					result = append(result, "org/repo#1:"+label)
+				}
+				return result
+			}
+			if diff := cmp.Diff(prefixed(tc.expectedLabelsAdded), gc.IssueLabelsAdded); diff != "" {
+				t.Errorf("unexpected labels added (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(prefixed(tc.expectedLabelsRemoved), gc.IssueLabelsRemoved); diff != "" {
+				t.Errorf("unexpected labels removed (-want +got):\n%s", diff)
+			}
+			if status := issueStatus(jc.Issues["ABC-1"]); status != tc.expectedStatus {
+				t.Errorf("expected issue to be in status %q, got %q", tc.expectedStatus, status)
+			}
+			switch {
+			case tc.expectedComment == "" && len(gc.IssueCommentsAdded) > 0:
+				t.Errorf("expected no comment, got %v", gc.IssueCommentsAdded)
+			case tc.expectedComment != "" && (len(gc.IssueCommentsAdded) != 1 || !strings.Contains(gc.IssueCommentsAdded[0], tc.expectedComment)):
+				t.Errorf("expected a comment containing %q, got %v", tc.expectedComment, gc.IssueCommentsAdded)
+			}
+			if links := len(jc.RemoteLinks["ABC-1"]); links != tc.expectedRemoteLinks {
+				t.Errorf("expected %d remote links, got %d", tc.expectedRemoteLinks, links)
+			}
+			if tc.expectedCreatedIssue != "" {
+				clone, exists := jc.Issues[tc.expectedCreatedIssue]
+				if !exists {
+					t.Fatalf("expected issue %s to be created", tc.expectedCreatedIssue)
+				}
+				if !issueHasFixVersion(clone, version) || clone.Fields.Summary != "Something is broken" {
+					t.Errorf("unexpected clone: %+v", clone.Fields)
+				}
+				if len(jc.CreatedIssueLinks) != 1 || jc.CreatedIssueLinks[0].Type.Name != clonersLinkType {
+					t.Errorf("expected clone to be linked to the issue, got %v", jc.CreatedIssueLinks)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleLifecycleComment(t *testing.T) {
+	status := "In Progress"
+	cfg := &plugins.Jira{Lifecycle: plugins.JiraLifecycle{
+		Orgs: map[string]plugins.JiraOrgOptions{"org": {Repos: map[string]plugins.JiraRepoOptions{"repo": {Branches: map[string]plugins.JiraBranchOptions{
+			"main": {ValidStatuses: &[]string{status}},
+		}}}}},
+	}}
+	comment := func(body string, isPR bool) github.GenericCommentEvent {
+		return github.GenericCommentEvent{
+			Action:  github.GenericCommentActionCreated,
+			IsPR:    isPR,
+			Body:    body,
+			HTMLURL: "https://github.com/org/repo/pull/1#issuecomment-1",
+			Repo:    github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			Number:  1,
+			User:    github.User{Login: "user"},
+		}
+	}
+
+	var testCases = []struct {
+		name            string
+		event           github.GenericCommentEvent
+		base            string
+		body            string
+		expectedComment string
+		expectedLabels  []string
+	}{
+		{
+			name:  "other comments are ignored",
+			event: comment("/lgtm", true),
+			base:  "main",
+		},
+		{
+			name:            "refresh validates the issue",
+			event:           comment("/jira refresh", true),
+			base:            "main",
+			expectedComment: "which is valid.",
+			expectedLabels:  []string{"org/repo#1:" + labels.JiraValidRef},
+		},
+		{
+			name:            "refresh on an issue is rejected",
+			event:           comment("/jira refresh", false),
+			base:            "main",
+			expectedComment: "only supported for Pull Requests",
+		},
+		{
+			name:            "refresh on a branch without lifecycle is reported",
+			event:           comment("/jira refresh", true),
+			base:            "release-1.0",
+			expectedComment: "The Jira lifecycle is not configured for the release-1.0 branch.",
+		},
+		{
+			name:            "cherrypick on a regular PR is rejected",
+			event:           comment("/jira cherrypick", true),
+			base:            "main",
+			expectedComment: "This pull request is not an automated cherry-pick",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := fakegithub.NewFakeClient()
+			gc.PullRequests = map[int]*github.PullRequest{1: {Number: 1, Title: "ABC-1: fix", Body: tc.body, Base: github.PullRequestBranch{Ref: tc.base}}}
+			jc := &jiraclient.Fake{URL: "https://jira.example.com", Issues: map[string]*jira.Issue{"ABC-1": {Key: "ABC-1", Fields: &jira.IssueFields{Status: &jira.Status{Name: status}}}}}
+
+			if err := handleLifecycleComment(jc, gc, cfg, logrus.WithField("test", tc.name), tc.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch {
+			case tc.expectedComment == "" && len(gc.IssueCommentsAdded) > 0:
+				t.Errorf("expected no comment, got %v", gc.IssueCommentsAdded)
+			case tc.expectedComment != "" && (len(gc.IssueCommentsAdded) != 1 || !strings.Contains(gc.IssueCommentsAdded[0], tc.expectedComment)):
+				t.Errorf("expected a comment containing %q, got %v", tc.expectedComment, gc.IssueCommentsAdded)
+			}
+			if diff := cmp.Diff(tc.expectedLabels, gc.IssueLabelsAdded); diff != "" {
+				t.Errorf("unexpected labels added (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jobdiff comments on pull requests that change the Prow config with
+// the resulting changes to the effective job configs.
+package jobdiff
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/jobdiff"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+const (
+	// PluginName defines this plugin's registered name.
+	PluginName = "jobdiff"
+
+	// commentMarker identifies the comments of this plugin, so that the
+	// previous one can be removed when the PR changes.
+	commentMarker = "<!-- jobdiff -->"
+	// GitHub rejects comments over 65536 characters.
+	maxCommentLength = 60000
+)
+
+func init() {
+	plugins.RegisterPullRequestHandler(PluginName, handlePullRequest, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	configInfo := map[string]string{}
+	for _, repo := range enabledRepos {
+		jd := config.JobDiffFor(repo.Org, repo.Repo)
+		if jd == nil {
+			configInfo[repo.String()] = "The plugin is not configured for this repository."
+			continue
+		}
+		msg := fmt.Sprintf("Changes to the Prow config at <code>%s</code>", jd.ConfigPath)
+		if jd.JobConfigPath != "" {
+			msg += fmt.Sprintf(" and the job configs at <code>%s</code>", jd.JobConfigPath)
+		}
+		configInfo[repo.String()] = msg + " are analyzed."
+	}
+	yamlSnippet, err := plugins.CommentMap.GenYaml(&plugins.Configuration{
+		JobDiff: []plugins.JobDiff{{
+			Repos:         []string{"kubernetes/test-infra"},
+			ConfigPath:    "config/prow/config.yaml",
+			JobConfigPath: "config/jobs",
+		}},
+	})
+	if err != nil {
+		logrus.WithError(err).Warnf("cannot generate comments for %s plugin", PluginName)
+	}
+	return &pluginhelp.PluginHelp{
+		Description: "The jobdiff plugin comments on pull requests that change the Prow config or job configs with the changes to the effective jobs, after defaults, presets and decoration are applied. It also points out presubmits that will newly run on every PR.",
+		Config:      configInfo,
+		Snippet:     yamlSnippet,
+	}, nil
+}
+
+type githubClient interface {
+	CreateComment(org, repo string, number int, comment string) error
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+}
+
+type pruneClient interface {
+	PruneComments(func(ic github.IssueComment) bool)
+}
+
+func handlePullRequest(pc plugins.Agent, pre github.PullRequestEvent) error {
+	if pre.Action != github.PullRequestActionOpened &&
+		pre.Action != github.PullRequestActionReopened &&
+		pre.Action != github.PullRequestActionSynchronize {
+		return nil
+	}
+	jd := pc.PluginConfig.JobDiffFor(pre.Repo.Owner.Login, pre.Repo.Name)
+	if jd == nil {
+		return nil
+	}
+	cp, err := pc.CommentPruner()
+	if err != nil {
+		return err
+	}
+	return handle(pc.GitHubClient, pc.GitClient, cp, pc.Logger, &pre, *jd)
+}
+
+// touchesConfig determines if the file is the Prow config or one of the job configs.
+func touchesConfig(file string, jd plugins.JobDiff) bool {
+	if file == path.Clean(jd.ConfigPath) {
+		return true
+	}
+	if jd.JobConfigPath == "" {
+		return false
+	}
+	jobConfigPath := path.Clean(jd.JobConfigPath)
+	return file == jobConfigPath || strings.HasPrefix(file, jobConfigPath+"/")
+}
+
+func handle(ghc githubClient, gc git.ClientFactory, cp pruneClient, log *logrus.Entry, pre *github.PullRequestEvent, jd plugins.JobDiff) error {
+	var (
+		org  = pre.PullRequest.Base.Repo.Owner.Login
+		repo = pre.PullRequest.Base.Repo.Name
+		num  = pre.PullRequest.Number
+	)
+
+	changes, err := ghc.GetPullRequestChanges(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("failed to get PR changes: %w", err)
+	}
+	var relevant bool
+	for _, change := range changes {
+		if touchesConfig(change.Filename, jd) || (change.PreviousFilename != "" && touchesConfig(change.PreviousFilename, jd)) {
+			relevant = true
+			break
+		}
+	}
+	if !relevant {
+		return nil
+	}
+
+	r, err := gc.ClientFor(org, repo)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			log.WithError(err).Error("Error cleaning up repo.")
+		}
+	}()
+	if err := r.CheckoutPullRequest(num); err != nil {
+		return err
+	}
+
+	baseSHA, headSHA := pre.PullRequest.Base.SHA, pre.PullRequest.Head.SHA
+	if err := r.Checkout(baseSHA); err != nil {
+		return err
+	}
+	before, err := jobdiff.Load(r.Directory(), jd.ConfigPath, jd.JobConfigPath)
+	if err != nil {
+		// The PR can't be blamed for a broken base.
+		return fmt.Errorf("failed to load config at base %s: %w", baseSHA, err)
+	}
+	// Compare against the merge result, so that changes on the base branch
+	// don't show up as reverted by the PR.
+	if err := r.Config("user.name", "prow"); err != nil {
+		return err
+	}
+	if err := r.Config("user.email", "prow@localhost"); err != nil {
+		return err
+	}
+	if err := r.Config("commit.gpgsign", "false"); err != nil {
+		return err
+	}
+	mergeMethod := before.Tide.MergeMethod(config.OrgRepo{Org: org, Repo: repo})
+	if err := r.MergeAndCheckout(baseSHA, string(mergeMethod), headSHA); err != nil {
+		return fmt.Errorf("failed to merge: %w", err)
+	}
+
+	var body string
+	if after, err := jobdiff.Load(r.Directory(), jd.ConfigPath, jd.JobConfigPath); err != nil {
+		body = fmt.Sprintf("The config can't be loaded with the changes of this PR:\n```\n%v\n```\n", err)
+	} else {
+		diff, err := jobdiff.Diff(before, after)
+		if err != nil {
+			return err
+		}
+		body = jobdiff.Markdown(diff, maxCommentLength)
+	}
+
+	cp.PruneComments(func(ic github.IssueComment) bool {
+		return strings.Contains(ic.Body, commentMarker)
+	})
+	log.Info("Commenting with the job config changes.")
+	return ghc.CreateComment(org, repo, num, fmt.Sprintf("%s\n#### Effective job config changes at %s\n\n%s", commentMarker, headSHA, body))
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jobdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/git/localgit"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+type fakeGitHubClient struct {
+	changes  []github.PullRequestChange
+	comments []string
+}
+
+func (f *fakeGitHubClient) CreateComment(org, repo string, number int, comment string) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func (f *fakeGitHubClient) GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error) {
+	return f.changes, nil
+}
+
+type fakePruner struct {
+	pruned bool
+}
+
+func (f *fakePruner) PruneComments(func(ic github.IssueComment) bool) {
+	f.pruned = true
+}
+
+func TestTouchesConfig(t *testing.T) {
+	jd := plugins.JobDiff{ConfigPath: "config/prow/config.yaml", JobConfigPath: "config/jobs/"}
+	for file, expected := range map[string]bool{
+		"config/prow/config.yaml":     true,
+		"config/jobs/org/repo.yaml":   true,
+		"config/jobs-other/repo.yaml": false,
+		"config/prow/plugins.yaml":    false,
+	} {
+		if actual := touchesConfig(file, jd); actual != expected {
+			t.Errorf("%s: expected %t, got %t", file, expected, actual)
+		}
+	}
+}
+
+const jobs = `
+presubmits:
+  org/repo:
+  - name: pull-job
+    %s
+    spec:
+      containers:
+      - image: image
+`
+
+func TestHandle(t *testing.T) {
+	lg, gc, err := localgit.NewV2()
+	if err != nil {
+		t.Fatalf("Making localgit: %v", err)
+	}
+	defer func() {
+		if err := lg.Clean(); err != nil {
+			t.Errorf("Cleaning up localgit: %v", err)
+		}
+		if err := gc.Clean(); err != nil {
+			t.Errorf("Cleaning up client: %v", err)
+		}
+	}()
+	if err := lg.MakeFakeRepo("org", "repo"); err != nil {
+		t.Fatalf("Making fake repo: %v", err)
+	}
+	if err := lg.AddCommit("org", "repo", map[string][]byte{
+		"config.yaml":    []byte("{}"),
+		"jobs/jobs.yaml": []byte(strings.Replace(jobs, "%s", "run_if_changed: '^src/'", 1)),
+	}); err != nil {
+		t.Fatalf("Adding commit: %v", err)
+	}
+	baseSHA, err := lg.RevParse("org", "repo", "HEAD")
+	if err != nil {
+		t.Fatalf("Getting base SHA: %v", err)
+	}
+	if err := lg.CheckoutNewBranch("org", "repo", "pull/1/head"); err != nil {
+		t.Fatalf("Checking out pull branch: %v", err)
+	}
+	if err := lg.AddCommit("org", "repo", map[string][]byte{
+		"jobs/jobs.yaml": []byte(strings.Replace(jobs, "%s", "always_run: true", 1)),
+	}); err != nil {
+		t.Fatalf("Adding commit: %v", err)
+	}
+	headSHA, err := lg.RevParse("org", "repo", "HEAD")
+	if err != nil {
+		t.Fatalf("Getting head SHA: %v", err)
+	}
+	if err := lg.Checkout("org", "repo", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+
+	repo := github.Repo{Owner: github.User{Login: "org"}, Name: "repo"}
+	pre := &github.PullRequestEvent{
+		Action: github.PullRequestActionOpened,
+		Number: 1,
+		PullRequest: github.PullRequest{
+			Number: 1,
+			Base:   github.PullRequestBranch{Repo: repo, SHA: baseSHA, Ref: "master"},
+			Head:   github.PullRequestBranch{Repo: repo, SHA: headSHA},
+		},
+	}
+	jd := plugins.JobDiff{ConfigPath: "config.yaml", JobConfigPath: "jobs"}
+
+	var testCases = []struct {
+		name             string
+		changes          []github.PullRequestChange
+		expectedComments []string
+	}{
+		{
+			name:    "unrelated change",
+			changes: []github.PullRequestChange{{Filename: "README.md"}},
+		},
+		{
+			name:    "job config change",
+			changes: []github.PullRequestChange{{Filename: "jobs/jobs.yaml"}},
+			expectedComments: []string{
+				commentMarker,
+				"| presubmit | org/repo | `pull-job` | changed |",
+				"These presubmits will now run on every PR: `pull-job` (org/repo)",
+				"+always_run: true",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ghc := &fakeGitHubClient{changes: tc.changes}
+			cp := &fakePruner{}
+			if err := handle(ghc, gc, cp, logrus.WithField("plugin", PluginName), pre, jd); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tc.expectedComments) == 0 {
+				if len(ghc.comments) != 0 || cp.pruned {
+					t.Errorf("expected no comments, got %v", ghc.comments)
+				}
+				return
+			}
+			if len(ghc.comments) != 1 || !cp.pruned {
+				t.Fatalf("expected previous comments to be pruned and one comment, got %v", ghc.comments)
+			}
+			for _, expected := range tc.expectedComments {
+				if !strings.Contains(ghc.comments[0], expected) {
+					t.Errorf("expected comment to contain %q, got:\n%s", expected, ghc.comments[0])
+				}
+			}
+		})
+	}
+}
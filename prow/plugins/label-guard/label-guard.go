@@ -0,0 +1,256 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package labelguard implements the `label-guard` plugin, which reverts label
+// changes made by anyone the label's permissions in label_sync's labels.yaml
+// do not allow.
+package labelguard
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+const pluginName = "label-guard"
+
+type githubClient interface {
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
+	CreateComment(org, repo string, number int, content string) error
+	BotUserChecker() (func(candidate string) bool, error)
+	GetTeamBySlug(slug string, org string) (*github.Team, error)
+	TeamHasMember(org string, teamID int, memberLogin string) (bool, error)
+}
+
+func init() {
+	plugins.RegisterIssueHandler(pluginName, handleIssue, helpProvider)
+	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	yamlSnippet, err := plugins.CommentMap.GenYaml(&plugins.Configuration{
+		LabelGuard: plugins.LabelGuard{
+			LabelsPath: "/etc/labels/labels.yaml",
+		},
+	})
+	if err != nil {
+		logrus.WithError(err).Warnf("cannot generate comments for %s plugin", pluginName)
+	}
+	return &pluginhelp.PluginHelp{
+			Description: "The label-guard plugin enforces the permissions declared for labels in label_sync's labels.yaml. When a label is added or removed by a user who is neither listed in the label's addableBy/removableBy permissions nor a member of a listed team, the change is reverted and the user is told who may make it.",
+			Config: map[string]string{
+				"": fmt.Sprintf("Label permissions are read from %q.", config.LabelGuard.LabelsPath),
+			},
+			Snippet: yamlSnippet,
+		},
+		nil
+}
+
+// labelsConfig holds the parts of label_sync's labels.yaml this plugin enforces.
+type labelsConfig struct {
+	Default repoConfig            `json:"default"`
+	Orgs    map[string]repoConfig `json:"orgs,omitempty"`
+	Repos   map[string]repoConfig `json:"repos,omitempty"`
+}
+
+type repoConfig struct {
+	Labels []label `json:"labels"`
+}
+
+type label struct {
+	Name        string       `json:"name"`
+	Permissions *permissions `json:"permissions,omitempty"`
+}
+
+type permissions struct {
+	AddableBy   []string `json:"addableBy,omitempty"`
+	RemovableBy []string `json:"removableBy,omitempty"`
+}
+
+// permissionsFor returns the permissions of the named label in org/repo, or
+// nil if the label is unknown or unrestricted. Repo labels take precedence
+// over org labels, which take precedence over default labels.
+func (c *labelsConfig) permissionsFor(org, repo, name string) *permissions {
+	for _, rc := range []repoConfig{c.Repos[org+"/"+repo], c.Orgs[org], c.Default} {
+		for _, l := range rc.Labels {
+			if strings.EqualFold(l.Name, name) {
+				return l.Permissions
+			}
+		}
+	}
+	return nil
+}
+
+// labelsLoader caches the parsed labels.yaml until the file changes on disk.
+type labelsLoader struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	config  *labelsConfig
+}
+
+var loader = &labelsLoader{}
+
+func (l *labelsLoader) load(path string) (*labelsConfig, error) {
+	l.Lock()
+	defer l.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if l.config != nil && l.path == path && l.modTime.Equal(info.ModTime()) {
+		return l.config, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c labelsConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	l.path, l.modTime, l.config = path, info.ModTime(), &c
+	return &c, nil
+}
+
+type event struct {
+	org    string
+	repo   string
+	number int
+	label  string
+	added  bool
+	sender string
+}
+
+func handleIssue(pc plugins.Agent, ie github.IssueEvent) error {
+	if ie.Action != github.IssueActionLabeled && ie.Action != github.IssueActionUnlabeled {
+		return nil
+	}
+	return handleEvent(pc, event{
+		org:    ie.Repo.Owner.Login,
+		repo:   ie.Repo.Name,
+		number: ie.Issue.Number,
+		label:  ie.Label.Name,
+		added:  ie.Action == github.IssueActionLabeled,
+		sender: ie.Sender.Login,
+	})
+}
+
+func handlePullRequest(pc plugins.Agent, pre github.PullRequestEvent) error {
+	if pre.Action != github.PullRequestActionLabeled && pre.Action != github.PullRequestActionUnlabeled {
+		return nil
+	}
+	return handleEvent(pc, event{
+		org:    pre.Repo.Owner.Login,
+		repo:   pre.Repo.Name,
+		number: pre.Number,
+		label:  pre.Label.Name,
+		added:  pre.Action == github.PullRequestActionLabeled,
+		sender: pre.Sender.Login,
+	})
+}
+
+func handleEvent(pc plugins.Agent, e event) error {
+	if pc.PluginConfig.LabelGuard.LabelsPath == "" {
+		return nil
+	}
+	labels, err := loader.load(pc.PluginConfig.LabelGuard.LabelsPath)
+	if err != nil {
+		return err
+	}
+	return handle(pc.GitHubClient, pc.Logger, labels, e)
+}
+
+func handle(ghc githubClient, log *logrus.Entry, labels *labelsConfig, e event) error {
+	perms := labels.permissionsFor(e.org, e.repo, e.label)
+	if perms == nil {
+		return nil
+	}
+	allowedBy, verb := perms.AddableBy, "added"
+	if !e.added {
+		allowedBy, verb = perms.RemovableBy, "removed"
+	}
+	if len(allowedBy) == 0 {
+		return nil
+	}
+
+	// Changes made by the bot come from plugins acting on someone's behalf,
+	// including the reverts made here.
+	isBot, err := ghc.BotUserChecker()
+	if err != nil {
+		return err
+	}
+	if isBot(e.sender) {
+		return nil
+	}
+	allowed, err := isAllowed(ghc, e.sender, allowedBy)
+	if err != nil {
+		return err
+	}
+	if allowed {
+		return nil
+	}
+
+	log.WithFields(logrus.Fields{"label": e.label, "sender": e.sender, "added": e.added}).Info("Reverting unauthorized label change.")
+	if e.added {
+		err = ghc.RemoveLabel(e.org, e.repo, e.number, e.label)
+	} else {
+		err = ghc.AddLabel(e.org, e.repo, e.number, e.label)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revert change to label %q: %v", e.label, err)
+	}
+	msg := fmt.Sprintf("the `%s` label may only be %s by %s, so I have reverted this change.", e.label, verb, strings.Join(allowedBy, ", "))
+	return ghc.CreateComment(e.org, e.repo, e.number, plugins.FormatSimpleResponse(e.sender, msg))
+}
+
+// isAllowed determines whether the user is one of the listed logins or a
+// member of one of the listed org/team-slug teams.
+func isAllowed(ghc githubClient, user string, allowedBy []string) (bool, error) {
+	for _, entry := range allowedBy {
+		parts := strings.SplitN(entry, "/", 2)
+		if len(parts) == 1 {
+			if github.NormLogin(entry) == github.NormLogin(user) {
+				return true, nil
+			}
+			continue
+		}
+		team, err := ghc.GetTeamBySlug(parts[1], parts[0])
+		if err != nil {
+			return false, fmt.Errorf("failed to get team %s: %v", entry, err)
+		}
+		member, err := ghc.TeamHasMember(parts[0], team.ID, user)
+		if err != nil {
+			return false, fmt.Errorf("failed to check membership of %s in %s: %v", user, entry, err)
+		}
+		if member {
+			return true, nil
+		}
+	}
+	return false, nil
+}
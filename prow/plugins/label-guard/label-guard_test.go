@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package labelguard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github/fakegithub"
+)
+
+const labelsYAML = `
+default:
+  labels:
+  - name: lgtm
+    color: 15dd18
+    permissions:
+      addableBy:
+      - k8s-ci-robot
+      removableBy:
+      - org/Leads
+  - name: help wanted
+    color: 006b75
+orgs:
+  org:
+    labels:
+    - name: do-not-merge/hold
+      color: e11d21
+      permissions:
+        removableBy:
+        - Alice
+repos:
+  org/repo:
+    labels:
+    - name: approved
+      color: 0ffa16
+      permissions:
+        addableBy:
+        - k8s-ci-robot
+`
+
+func TestHandle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "label-guard")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "labels.yaml")
+	if err := ioutil.WriteFile(path, []byte(labelsYAML), 0644); err != nil {
+		t.Fatalf("failed to write labels: %v", err)
+	}
+	labels, err := loader.load(path)
+	if err != nil {
+		t.Fatalf("failed to load labels: %v", err)
+	}
+
+	testcases := []struct {
+		name string
+		e    event
+
+		expectAdded   []string
+		expectRemoved []string
+		expectComment string
+	}{
+		{
+			name:          "human adding a bot-only label is reverted",
+			e:             event{org: "org", repo: "other", number: 1, label: "lgtm", added: true, sender: "bob"},
+			expectRemoved: []string{"org/other#1:lgtm"},
+			expectComment: "may only be added by k8s-ci-robot",
+		},
+		{
+			name: "permitted user may add the label",
+			e:    event{org: "org", repo: "other", number: 1, label: "LGTM", added: true, sender: "K8s-CI-Robot"},
+		},
+		{
+			name: "bot changes are never reverted",
+			e:    event{org: "org", repo: "other", number: 1, label: "lgtm", added: false, sender: "k8s-ci-robot"},
+		},
+		{
+			name: "team member may remove the label",
+			e:    event{org: "org", repo: "other", number: 1, label: "lgtm", added: false, sender: "sig-lead"},
+		},
+		{
+			name:          "non team member removing the label is reverted",
+			e:             event{org: "org", repo: "other", number: 1, label: "lgtm", added: false, sender: "bob"},
+			expectAdded:   []string{"org/other#1:lgtm"},
+			expectComment: "may only be removed by org/Leads",
+		},
+		{
+			name: "unrestricted action is allowed",
+			e:    event{org: "org", repo: "other", number: 1, label: "do-not-merge/hold", added: true, sender: "bob"},
+		},
+		{
+			name:          "org label permissions apply",
+			e:             event{org: "org", repo: "other", number: 1, label: "do-not-merge/hold", added: false, sender: "bob"},
+			expectAdded:   []string{"org/other#1:do-not-merge/hold"},
+			expectComment: "may only be removed by Alice",
+		},
+		{
+			name:          "repo label permissions apply",
+			e:             event{org: "org", repo: "repo", number: 2, label: "approved", added: true, sender: "bob"},
+			expectRemoved: []string{"org/repo#2:approved"},
+			expectComment: "may only be added by k8s-ci-robot",
+		},
+		{
+			name: "repo label permissions do not leak to other repos",
+			e:    event{org: "org", repo: "other", number: 2, label: "approved", added: true, sender: "bob"},
+		},
+		{
+			name: "labels without permissions are ignored",
+			e:    event{org: "org", repo: "repo", number: 2, label: "help wanted", added: true, sender: "bob"},
+		},
+		{
+			name: "labels missing from the config are ignored",
+			e:    event{org: "org", repo: "repo", number: 2, label: "unknown", added: true, sender: "bob"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := fakegithub.NewFakeClient()
+			if err := handle(fc, logrus.WithField("plugin", pluginName), labels, tc.e); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expectAdded, fc.IssueLabelsAdded) {
+				t.Errorf("expected labels %v to be added, got %v", tc.expectAdded, fc.IssueLabelsAdded)
+			}
+			if !reflect.DeepEqual(tc.expectRemoved, fc.IssueLabelsRemoved) {
+				t.Errorf("expected labels %v to be removed, got %v", tc.expectRemoved, fc.IssueLabelsRemoved)
+			}
+			comments := fc.IssueComments[tc.e.number]
+			switch {
+			case tc.expectComment == "" && len(comments) > 0:
+				t.Errorf("expected no comment, got %v", comments)
+			case tc.expectComment != "" && (len(comments) != 1 || !strings.Contains(comments[0].Body, tc.expectComment)):
+				t.Errorf("expected a comment containing %q, got %v", tc.expectComment, comments)
+			}
+		})
+	}
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package lifecycle
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
@@ -37,9 +39,31 @@ func init() {
 	plugins.RegisterGenericCommentHandler("lifecycle", lifecycleHandleGenericComment, help)
 }
 
-func help(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+func help(cfg *plugins.Configuration, enabledRepos []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	policies := map[string]string{}
+	for _, repo := range enabledRepos {
+		if policy := cfg.LifecycleFor(repo.Org, repo.Repo); policy != nil {
+			policies[repo.String()] = policyString(policy)
+		}
+	}
+	yamlSnippet, err := plugins.CommentMap.GenYaml(&plugins.Configuration{
+		Lifecycle: []plugins.Lifecycle{{
+			Repos:            []string{"ORGANIZATION", "ORGANIZATION/REPOSITORY"},
+			StaleAfter:       "2160h",
+			RottenAfter:      "720h",
+			CloseAfter:       "720h",
+			GracePeriod:      "168h",
+			ExemptLabels:     []string{"help wanted"},
+			ExemptMilestones: []string{"v1.0"},
+		}},
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("cannot generate comments for lifecycle plugin")
+	}
 	pluginHelp := &pluginhelp.PluginHelp{
-		Description: "Close, reopen, flag and/or unflag an issue or PR as frozen/stale/rotten",
+		Description: "Close, reopen, flag and/or unflag an issue or PR as frozen/stale/rotten. The lifecycle-controller applies the configured policies to mark inactive issues and PRs as stale and rotten and to close them.",
+		Config:      policies,
+		Snippet:     yamlSnippet,
 	}
 	pluginHelp.AddCommand(pluginhelp.Command{
 		Usage:       "/close",
@@ -65,6 +89,23 @@ func help(config *plugins.Configuration, _ []config.OrgRepo) (*pluginhelp.Plugin
 	return pluginHelp, nil
 }
 
+func policyString(policy *plugins.Lifecycle) string {
+	var steps []string
+	if policy.StaleAfter != "" {
+		steps = append(steps, fmt.Sprintf("marked as %s after %s of inactivity", labels.LifecycleStale, policy.StaleAfter))
+	}
+	if policy.RottenAfter != "" {
+		steps = append(steps, fmt.Sprintf("marked as %s after being stale for %s", labels.LifecycleRotten, policy.RottenAfter))
+	}
+	if policy.CloseAfter != "" {
+		steps = append(steps, fmt.Sprintf("closed after being rotten for %s", policy.CloseAfter))
+	}
+	if len(steps) == 0 {
+		return "No lifecycle transitions are configured."
+	}
+	return fmt.Sprintf("Issues and PRs are %s.", strings.Join(steps, ", "))
+}
+
 type lifecycleClient interface {
 	AddLabel(owner, repo string, number int, label string) error
 	RemoveLabel(owner, repo string, number int, label string) error
@@ -0,0 +1,382 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+// searchTimeFormat is the format of dates in GitHub search queries.
+const searchTimeFormat = "2006-01-02T15:04:05Z"
+
+// PolicyClient is the GitHub client needed to apply lifecycle policies.
+type PolicyClient interface {
+	FindIssues(query, sort string, asc bool) ([]github.Issue, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	ListIssueEvents(org, repo string, num int) ([]github.ListedIssueEvent, error)
+	BotUserChecker() (func(candidate string) bool, error)
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
+	CreateComment(org, repo string, number int, comment string) error
+	CloseIssue(org, repo string, number int) error
+	ClosePR(org, repo string, number int) error
+	UpdatePullRequestBranch(org, repo string, number int, expectedHeadSha *string) error
+}
+
+// Transition is a change a lifecycle policy makes to an issue or PR.
+type Transition string
+
+const (
+	// TransitionStale marks an inactive issue or PR as lifecycle/stale.
+	TransitionStale Transition = "stale"
+	// TransitionRotten marks a stale issue or PR as lifecycle/rotten.
+	TransitionRotten Transition = "rotten"
+	// TransitionClose closes a rotten issue or PR.
+	TransitionClose Transition = "close"
+)
+
+// Action is a transition that applies to an issue or PR.
+type Action struct {
+	Org         string
+	Repo        string
+	Number      int
+	Title       string
+	PullRequest bool
+	Transition  Transition
+	// Inactive is how long the issue or PR has been in its current state.
+	Inactive time.Duration
+
+	policy plugins.Lifecycle
+}
+
+func (a Action) String() string {
+	kind := "issue"
+	if a.PullRequest {
+		kind = "PR"
+	}
+	var what string
+	switch a.Transition {
+	case TransitionStale:
+		what = "mark as " + labels.LifecycleStale
+	case TransitionRotten:
+		what = "mark as " + labels.LifecycleRotten
+	case TransitionClose:
+		what = "close"
+	}
+	return fmt.Sprintf("%s/%s#%d (%s %q): %s after %s", a.Org, a.Repo, a.Number, kind, a.Title, what, a.Inactive.Round(time.Hour))
+}
+
+// PlanActions finds the transitions the lifecycle policies of the config apply
+// to the open issues and PRs of their repos.
+func PlanActions(client PolicyClient, log *logrus.Entry, config *plugins.Configuration, now time.Time) ([]Action, error) {
+	isBot, err := client.BotUserChecker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the bot user checker: %v", err)
+	}
+	// Repos with their own policy are skipped by the policies of their org.
+	repoPolicies := sets.NewString()
+	for _, policy := range config.Lifecycle {
+		for _, scope := range policy.Repos {
+			if strings.Contains(scope, "/") {
+				repoPolicies.Insert(scope)
+			}
+		}
+	}
+
+	var actions []Action
+	for _, policy := range config.Lifecycle {
+		for _, scope := range policy.Repos {
+			var excluded []string
+			if !strings.Contains(scope, "/") {
+				for _, repo := range repoPolicies.List() {
+					if strings.HasPrefix(repo, scope+"/") {
+						excluded = append(excluded, repo)
+					}
+				}
+			}
+			planner := policyPlanner{client: client, log: log.WithField("scope", scope), isBot: isBot, policy: policy, now: now}
+			scopeActions, err := planner.plan(scope, excluded)
+			if err != nil {
+				return nil, fmt.Errorf("failed to plan lifecycle actions for %s: %v", scope, err)
+			}
+			actions = append(actions, scopeActions...)
+		}
+	}
+	return actions, nil
+}
+
+type policyPlanner struct {
+	client PolicyClient
+	log    *logrus.Entry
+	isBot  func(candidate string) bool
+	policy plugins.Lifecycle
+	now    time.Time
+}
+
+func (p *policyPlanner) plan(scope string, excluded []string) ([]Action, error) {
+	var actions []Action
+	if p.policy.StaleAfterDuration > 0 {
+		cutoff := p.now.Add(-p.policy.StaleAfterDuration)
+		query := p.query(scope, excluded, "-label:"+labels.LifecycleStale, "-label:"+labels.LifecycleRotten, "updated:<="+cutoff.UTC().Format(searchTimeFormat))
+		issues, err := p.client.FindIssues(query, "updated", true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for %q: %v", query, err)
+		}
+		for _, issue := range issues {
+			if p.exempt(issue) || github.HasLabel(labels.LifecycleStale, issue.Labels) || github.HasLabel(labels.LifecycleRotten, issue.Labels) {
+				continue
+			}
+			actions = append(actions, p.action(issue, TransitionStale, p.now.Sub(issue.UpdatedAt)))
+		}
+	}
+	for _, step := range []struct {
+		label      string
+		after      time.Duration
+		transition Transition
+	}{
+		{label: labels.LifecycleStale, after: p.policy.RottenAfterDuration, transition: TransitionRotten},
+		{label: labels.LifecycleRotten, after: p.policy.CloseAfterDuration, transition: TransitionClose},
+	} {
+		if step.after <= 0 {
+			continue
+		}
+		query := p.query(scope, excluded, "label:"+step.label)
+		issues, err := p.client.FindIssues(query, "updated", true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for %q: %v", query, err)
+		}
+		for _, issue := range issues {
+			if p.exempt(issue) || !github.HasLabel(step.label, issue.Labels) {
+				continue
+			}
+			if step.transition == TransitionRotten && github.HasLabel(labels.LifecycleRotten, issue.Labels) {
+				continue
+			}
+			action := p.action(issue, step.transition, 0)
+			since, err := p.labeledAt(action, step.label, issue.UpdatedAt)
+			if err != nil {
+				return nil, err
+			}
+			if action.Inactive = p.now.Sub(since); action.Inactive < step.after {
+				continue
+			}
+			if p.policy.GracePeriodDuration > 0 {
+				humanActivity, err := p.lastHumanActivity(action, issue.CreatedAt)
+				if err != nil {
+					return nil, err
+				}
+				if p.now.Sub(humanActivity) < p.policy.GracePeriodDuration {
+					p.log.WithField("number", issue.Number).Debugf("Skipping %s, a human was active at %s.", step.transition, humanActivity)
+					continue
+				}
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// query builds the search query for the open issues and PRs of the scope
+// that are not exempt from the policy.
+func (p *policyPlanner) query(scope string, excluded []string, terms ...string) string {
+	parts := []string{"is:open", "archived:false"}
+	if strings.Contains(scope, "/") {
+		parts = append(parts, "repo:"+scope)
+	} else {
+		parts = append(parts, "org:"+scope)
+	}
+	for _, repo := range excluded {
+		parts = append(parts, "-repo:"+repo)
+	}
+	if p.policy.SkipIssues {
+		parts = append(parts, "is:pr")
+	}
+	if p.policy.SkipPullRequests {
+		parts = append(parts, "is:issue")
+	}
+	parts = append(parts, "-label:"+labels.LifecycleFrozen)
+	for _, label := range p.policy.ExemptLabels {
+		parts = append(parts, fmt.Sprintf("-label:%q", label))
+	}
+	for _, milestone := range p.policy.ExemptMilestones {
+		parts = append(parts, fmt.Sprintf("-milestone:%q", milestone))
+	}
+	return strings.Join(append(parts, terms...), " ")
+}
+
+// exempt double checks the search results, which may be stale.
+func (p *policyPlanner) exempt(issue github.Issue) bool {
+	if github.HasLabel(labels.LifecycleFrozen, issue.Labels) {
+		return true
+	}
+	for _, label := range p.policy.ExemptLabels {
+		if github.HasLabel(label, issue.Labels) {
+			return true
+		}
+	}
+	if sets.NewString(p.policy.ExemptMilestones...).Has(issue.Milestone.Title) {
+		return true
+	}
+	return (issue.IsPullRequest() && p.policy.SkipPullRequests) || (!issue.IsPullRequest() && p.policy.SkipIssues)
+}
+
+func (p *policyPlanner) action(issue github.Issue, transition Transition, inactive time.Duration) Action {
+	org, repo := repoFromURL(issue.HTMLURL)
+	return Action{
+		Org:         org,
+		Repo:        repo,
+		Number:      issue.Number,
+		Title:       issue.Title,
+		PullRequest: issue.IsPullRequest(),
+		Transition:  transition,
+		Inactive:    inactive,
+		policy:      p.policy,
+	}
+}
+
+// labeledAt returns when the label was last added, or the fallback if we
+// cannot tell.
+func (p *policyPlanner) labeledAt(a Action, label string, fallback time.Time) (time.Time, error) {
+	events, err := p.client.ListIssueEvents(a.Org, a.Repo, a.Number)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list events of %s/%s#%d: %v", a.Org, a.Repo, a.Number, err)
+	}
+	var labeled time.Time
+	for _, event := range events {
+		if event.Event == github.IssueActionLabeled && event.Label.Name == label && event.CreatedAt.After(labeled) {
+			labeled = event.CreatedAt
+		}
+	}
+	if labeled.IsZero() {
+		return fallback, nil
+	}
+	return labeled, nil
+}
+
+// lastHumanActivity returns when a human last commented, or when the issue or
+// PR was created.
+func (p *policyPlanner) lastHumanActivity(a Action, created time.Time) (time.Time, error) {
+	comments, err := p.client.ListIssueComments(a.Org, a.Repo, a.Number)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to list comments of %s/%s#%d: %v", a.Org, a.Repo, a.Number, err)
+	}
+	last := created
+	for _, comment := range comments {
+		if !p.isBot(comment.User.Login) && comment.CreatedAt.After(last) {
+			last = comment.CreatedAt
+		}
+	}
+	return last, nil
+}
+
+// repoFromURL extracts the org and repo from the HTML URL of an issue or PR,
+// which is all the search API returns about them.
+func repoFromURL(htmlURL string) (string, string) {
+	u, err := url.Parse(htmlURL)
+	if err != nil {
+		return "", ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// ApplyAction applies a transition to its issue or PR.
+func ApplyAction(client PolicyClient, log *logrus.Entry, a Action) error {
+	var updatedBranch bool
+	switch a.Transition {
+	case TransitionStale:
+		if err := client.AddLabel(a.Org, a.Repo, a.Number, labels.LifecycleStale); err != nil {
+			return fmt.Errorf("failed to add %s: %v", labels.LifecycleStale, err)
+		}
+		if a.PullRequest && a.policy.UpdateStaleBranches {
+			// The update fails if the PR has conflicts, which the author has to resolve anyway.
+			if err := client.UpdatePullRequestBranch(a.Org, a.Repo, a.Number, nil); err != nil {
+				log.WithError(err).Infof("Failed to update the branch of %s/%s#%d.", a.Org, a.Repo, a.Number)
+			} else {
+				updatedBranch = true
+			}
+		}
+	case TransitionRotten:
+		if err := client.AddLabel(a.Org, a.Repo, a.Number, labels.LifecycleRotten); err != nil {
+			return fmt.Errorf("failed to add %s: %v", labels.LifecycleRotten, err)
+		}
+		if err := client.RemoveLabel(a.Org, a.Repo, a.Number, labels.LifecycleStale); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", labels.LifecycleStale, err)
+		}
+	case TransitionClose:
+		closeFunc := client.CloseIssue
+		if a.PullRequest {
+			closeFunc = client.ClosePR
+		}
+		if err := closeFunc(a.Org, a.Repo, a.Number); err != nil {
+			return fmt.Errorf("failed to close: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown transition %q", a.Transition)
+	}
+	return client.CreateComment(a.Org, a.Repo, a.Number, transitionComment(a, updatedBranch))
+}
+
+func transitionComment(a Action, updatedBranch bool) string {
+	kind := "issue"
+	if a.PullRequest {
+		kind = "PR"
+	}
+	var lines []string
+	switch a.Transition {
+	case TransitionStale:
+		lines = append(lines, fmt.Sprintf("This %s has had no activity for %s and is now stale.", kind, a.Inactive.Round(time.Hour)))
+		if updatedBranch {
+			lines = append(lines, "The base branch was merged into it so that its tests run against the current base.")
+		}
+	case TransitionRotten:
+		lines = append(lines, fmt.Sprintf("This %s has been stale for %s and is now rotten.", kind, a.Inactive.Round(time.Hour)))
+	case TransitionClose:
+		lines = append(lines, fmt.Sprintf("This %s has been rotten for %s and is now closed.", kind, a.Inactive.Round(time.Hour)))
+		lines = append(lines, "Reopen it with `/reopen`.")
+	}
+	if a.Transition != TransitionClose {
+		if next := nextTransition(a); next != "" {
+			lines = append(lines, next)
+		}
+		lines = append(lines, fmt.Sprintf("Mark it as fresh with `/remove-lifecycle %s`, or exempt it with `/lifecycle frozen`.", a.Transition))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func nextTransition(a Action) string {
+	switch {
+	case a.Transition == TransitionStale && a.policy.RottenAfterDuration > 0:
+		return fmt.Sprintf("Stale issues and PRs rot after %s.", a.policy.RottenAfterDuration)
+	case a.Transition == TransitionRotten && a.policy.CloseAfterDuration > 0:
+		return fmt.Sprintf("Rotten issues and PRs are closed after %s.", a.policy.CloseAfterDuration)
+	}
+	return ""
+}
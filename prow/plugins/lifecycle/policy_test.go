@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/plugins"
+)
+
+type fakePolicyClient struct {
+	issues   []github.Issue
+	events   map[int][]github.ListedIssueEvent
+	comments map[int][]github.IssueComment
+
+	queries []string
+	changes []string
+}
+
+func (c *fakePolicyClient) FindIssues(query, sort string, asc bool) ([]github.Issue, error) {
+	c.queries = append(c.queries, query)
+	var issues []github.Issue
+	for _, issue := range c.issues {
+		stale, rotten := github.HasLabel(labels.LifecycleStale, issue.Labels), github.HasLabel(labels.LifecycleRotten, issue.Labels)
+		switch {
+		case strings.Contains(query, " label:"+labels.LifecycleStale):
+			if !stale {
+				continue
+			}
+		case strings.Contains(query, " label:"+labels.LifecycleRotten):
+			if !rotten {
+				continue
+			}
+		default:
+			if stale || rotten {
+				continue
+			}
+			cutoff, err := time.Parse(searchTimeFormat, strings.SplitN(query, "updated:<=", 2)[1])
+			if err != nil {
+				return nil, err
+			}
+			if issue.UpdatedAt.After(cutoff) {
+				continue
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func (c *fakePolicyClient) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	return c.comments[number], nil
+}
+
+func (c *fakePolicyClient) ListIssueEvents(org, repo string, num int) ([]github.ListedIssueEvent, error) {
+	return c.events[num], nil
+}
+
+func (c *fakePolicyClient) BotUserChecker() (func(candidate string) bool, error) {
+	return func(candidate string) bool { return candidate == "k8s-ci-robot" }, nil
+}
+
+func (c *fakePolicyClient) AddLabel(org, repo string, number int, label string) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: add %s", org, repo, number, label))
+	return nil
+}
+
+func (c *fakePolicyClient) RemoveLabel(org, repo string, number int, label string) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: remove %s", org, repo, number, label))
+	return nil
+}
+
+func (c *fakePolicyClient) CreateComment(org, repo string, number int, comment string) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: comment", org, repo, number))
+	return nil
+}
+
+func (c *fakePolicyClient) CloseIssue(org, repo string, number int) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: close issue", org, repo, number))
+	return nil
+}
+
+func (c *fakePolicyClient) ClosePR(org, repo string, number int) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: close PR", org, repo, number))
+	return nil
+}
+
+func (c *fakePolicyClient) UpdatePullRequestBranch(org, repo string, number int, expectedHeadSha *string) error {
+	c.changes = append(c.changes, fmt.Sprintf("%s/%s#%d: update branch", org, repo, number))
+	return nil
+}
+
+func TestPlanActions(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time {
+		return now.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+	issue := func(number, updatedDaysAgo int, labelNames ...string) github.Issue {
+		i := github.Issue{
+			Number:    number,
+			Title:     fmt.Sprintf("issue %d", number),
+			HTMLURL:   fmt.Sprintf("https://github.com/org/repo/issues/%d", number),
+			CreatedAt: daysAgo(200),
+			UpdatedAt: daysAgo(updatedDaysAgo),
+		}
+		for _, name := range labelNames {
+			i.Labels = append(i.Labels, github.Label{Name: name})
+		}
+		return i
+	}
+	labeled := func(label string, days int) []github.ListedIssueEvent {
+		return []github.ListedIssueEvent{
+			{Event: github.IssueActionLabeled, Label: github.Label{Name: "kind/bug"}, CreatedAt: daysAgo(150)},
+			{Event: github.IssueActionLabeled, Label: github.Label{Name: label}, CreatedAt: daysAgo(days)},
+		}
+	}
+	milestone := issue(5, 100)
+	milestone.Milestone.Title = "v1.0"
+	pr := issue(10, 31, labels.LifecycleRotten)
+	pr.PullRequest = &struct{}{}
+	pr.HTMLURL = "https://github.com/org/repo/pull/10"
+
+	client := &fakePolicyClient{
+		issues: []github.Issue{
+			issue(1, 100),
+			issue(2, 10),
+			issue(3, 100, labels.LifecycleFrozen),
+			issue(4, 100, "important"),
+			milestone,
+			issue(6, 40, labels.LifecycleStale),
+			issue(7, 2, labels.LifecycleStale),
+			issue(8, 2, labels.LifecycleStale),
+			issue(9, 10, labels.LifecycleStale),
+			pr,
+			issue(11, 40, labels.LifecycleStale),
+		},
+		events: map[int][]github.ListedIssueEvent{
+			6:  labeled(labels.LifecycleStale, 40),
+			7:  labeled(labels.LifecycleStale, 40),
+			8:  labeled(labels.LifecycleStale, 40),
+			9:  labeled(labels.LifecycleStale, 10),
+			10: labeled(labels.LifecycleRotten, 31),
+		},
+		comments: map[int][]github.IssueComment{
+			7: {{User: github.User{Login: "alice"}, CreatedAt: daysAgo(2)}},
+			8: {{User: github.User{Login: "k8s-ci-robot"}, CreatedAt: daysAgo(2)}},
+		},
+	}
+	config := &plugins.Configuration{
+		Lifecycle: []plugins.Lifecycle{{
+			Repos:               []string{"org/repo"},
+			StaleAfterDuration:  90 * 24 * time.Hour,
+			RottenAfterDuration: 30 * 24 * time.Hour,
+			CloseAfterDuration:  30 * 24 * time.Hour,
+			GracePeriodDuration: 7 * 24 * time.Hour,
+			ExemptLabels:        []string{"important"},
+			ExemptMilestones:    []string{"v1.0"},
+		}},
+	}
+
+	actions, err := PlanActions(client, logrus.WithField("plugin", "lifecycle"), config, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, action := range actions {
+		got = append(got, action.String())
+	}
+	expected := []string{
+		`org/repo#1 (issue "issue 1"): mark as lifecycle/stale after 2400h0m0s`,
+		`org/repo#6 (issue "issue 6"): mark as lifecycle/rotten after 960h0m0s`,
+		`org/repo#8 (issue "issue 8"): mark as lifecycle/rotten after 960h0m0s`,
+		`org/repo#11 (issue "issue 11"): mark as lifecycle/rotten after 960h0m0s`,
+		`org/repo#10 (PR "issue 10"): close after 744h0m0s`,
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("actions differ from expected: %s", diff)
+	}
+}
+
+func TestPlanActionsQueries(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	config := &plugins.Configuration{
+		Lifecycle: []plugins.Lifecycle{
+			{
+				Repos:              []string{"org", "other"},
+				StaleAfterDuration: 24 * time.Hour,
+				ExemptLabels:       []string{"help wanted"},
+			},
+			{
+				Repos:               []string{"org/special"},
+				RottenAfterDuration: 24 * time.Hour,
+				SkipIssues:          true,
+			},
+		},
+	}
+	client := &fakePolicyClient{}
+	if _, err := PlanActions(client, logrus.WithField("plugin", "lifecycle"), config, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		`is:open archived:false org:org -repo:org/special -label:lifecycle/frozen -label:"help wanted" -label:lifecycle/stale -label:lifecycle/rotten updated:<=2021-05-31T12:00:00Z`,
+		`is:open archived:false org:other -label:lifecycle/frozen -label:"help wanted" -label:lifecycle/stale -label:lifecycle/rotten updated:<=2021-05-31T12:00:00Z`,
+		`is:open archived:false repo:org/special is:pr -label:lifecycle/frozen label:lifecycle/stale`,
+	}
+	if diff := cmp.Diff(expected, client.queries); diff != "" {
+		t.Errorf("queries differ from expected: %s", diff)
+	}
+}
+
+func TestApplyAction(t *testing.T) {
+	policy := plugins.Lifecycle{UpdateStaleBranches: true, RottenAfterDuration: time.Hour}
+	testCases := []struct {
+		name     string
+		action   Action
+		expected []string
+	}{
+		{
+			name:     "stale PR gets its branch updated",
+			action:   Action{Org: "org", Repo: "repo", Number: 1, PullRequest: true, Transition: TransitionStale, policy: policy},
+			expected: []string{"org/repo#1: add lifecycle/stale", "org/repo#1: update branch", "org/repo#1: comment"},
+		},
+		{
+			name:     "stale issue",
+			action:   Action{Org: "org", Repo: "repo", Number: 1, Transition: TransitionStale, policy: policy},
+			expected: []string{"org/repo#1: add lifecycle/stale", "org/repo#1: comment"},
+		},
+		{
+			name:     "rotten replaces stale",
+			action:   Action{Org: "org", Repo: "repo", Number: 1, Transition: TransitionRotten, policy: policy},
+			expected: []string{"org/repo#1: add lifecycle/rotten", "org/repo#1: remove lifecycle/stale", "org/repo#1: comment"},
+		},
+		{
+			name:     "close issue",
+			action:   Action{Org: "org", Repo: "repo", Number: 1, Transition: TransitionClose, policy: policy},
+			expected: []string{"org/repo#1: close issue", "org/repo#1: comment"},
+		},
+		{
+			name:     "close PR",
+			action:   Action{Org: "org", Repo: "repo", Number: 1, PullRequest: true, Transition: TransitionClose, policy: policy},
+			expected: []string{"org/repo#1: close PR", "org/repo#1: comment"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &fakePolicyClient{}
+			if err := ApplyAction(client, logrus.WithField("plugin", "lifecycle"), tc.action); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, client.changes); diff != "" {
+				t.Errorf("changes differ from expected: %s", diff)
+			}
+		})
+	}
+}
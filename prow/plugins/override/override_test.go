@@ -86,6 +86,10 @@ func (foc *fakeOwnersClient) RequiredReviewers(path string) sets.String {
 	return sets.String{}
 }
 
+func (foc *fakeOwnersClient) RequiredApprovals(path string) int {
+	return 1
+}
+
 func (foc *fakeOwnersClient) LeafReviewers(path string) sets.String {
 	return sets.String{}
 }
@@ -83,7 +83,7 @@ func handleGenericComment(c Client, trigger plugins.Trigger, gc github.GenericCo
 		!mayNeedHelpComment(gc.Body) {
 		matched := false
 		for _, presubmit := range presubmits {
-			matched = matched || presubmit.TriggerMatches(gc.Body)
+			matched = matched || presubmit.TriggerMatches(gc.Body) || presubmit.MatrixMatches(gc.Body)
 			if matched {
 				break
 			}
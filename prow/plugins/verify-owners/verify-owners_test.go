@@ -240,6 +240,10 @@ func (foc *fakeOwnersClient) RequiredReviewers(path string) sets.String {
 	return foc.requiredReviewers[path]
 }
 
+func (foc *fakeOwnersClient) RequiredApprovals(path string) int {
+	return 1
+}
+
 func (foc *fakeOwnersClient) LeafReviewers(path string) sets.String {
 	return foc.leafReviewers[path]
 }
@@ -18,10 +18,14 @@ package clone
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -40,10 +44,15 @@ type runnable interface {
 
 // Run clones the refs under the prescribed directory and optionally
 // configures the git username and email in the repository as well.
-func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, env []string, oauthToken string) Record {
+//
+// If referenceCache is set and holds a usable mirror for the refs, the
+// clone borrows objects from that mirror through git alternates. If the
+// mirror is missing or the clone fails while using it, Run falls back to
+// a normal clone.
+func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, env []string, oauthToken, referenceCache string) Record {
 	if len(oauthToken) > 0 {
 		logrus.SetFormatter(logrusutil.NewCensoringFormatter(logrus.StandardLogger().Formatter, func() sets.String {
-			return sets.NewString(oauthToken)
+			return sets.NewString(oauthToken, basicAuth(oauthToken))
 		}))
 	}
 	logrus.WithFields(logrus.Fields{"refs": refs}).Info("Cloning refs")
@@ -73,8 +82,24 @@ func Run(refs prowapi.Refs, dir, gitUserName, gitUserEmail, cookiePath string, e
 	}
 
 	g := gitCtxForRefs(refs, dir, env, oauthToken)
+	if referenceCache != "" {
+		mirror := MirrorPath(referenceCache, refs)
+		if formattedCommand, output, err := validateMirror(mirror); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"command": formattedCommand, "output": output}).Info("Reference cache is not usable, cloning without it")
+		} else {
+			g.alternates = filepath.Join(mirror, "objects")
+		}
+	}
 	if err := runCommands(g.commandsForBaseRef(refs, gitUserName, gitUserEmail, cookiePath)); err != nil {
-		return record
+		if g.alternates == "" {
+			return record
+		}
+		logrus.WithError(err).WithField("alternates", g.alternates).Warn("Clone using the reference cache failed, retrying without it")
+		record.Failed = false
+		g.alternates = ""
+		if err := runCommands(append([]runnable{g.cleanCommand()}, g.commandsForBaseRef(refs, gitUserName, gitUserEmail, cookiePath)...)); err != nil {
+			return record
+		}
 	}
 
 	timestamp, err := g.gitHeadTimestamp()
@@ -100,9 +125,16 @@ func censorToken(msg, token string) string {
 		return msg
 	}
 	censored := bytes.ReplaceAll([]byte(msg), []byte(token), []byte("CENSORED"))
+	censored = bytes.ReplaceAll(censored, []byte(basicAuth(token)), []byte("CENSORED"))
 	return string(censored)
 }
 
+// basicAuth encodes the token as the credentials git sends when the token
+// is part of the repository URI.
+func basicAuth(token string) string {
+	return base64.StdEncoding.EncodeToString([]byte(token + ":x-oauth-basic"))
+}
+
 // PathForRefs determines the full path to where
 // refs should be cloned
 func PathForRefs(baseDir string, refs prowapi.Refs) string {
@@ -119,11 +151,53 @@ func PathForRefs(baseDir string, refs prowapi.Refs) string {
 	return path.Join(baseDir, "src", clonePath)
 }
 
+// MirrorPath determines the full path to the bare mirror
+// of the repository for refs in a reference cache.
+func MirrorPath(cacheDir string, refs prowapi.Refs) string {
+	var repoPath string
+	if refs.RepoLink != "" {
+		// Drop the protocol from the RepoLink
+		parts := strings.Split(refs.RepoLink, "://")
+		repoPath = parts[len(parts)-1]
+	} else {
+		repoPath = fmt.Sprintf("github.com/%s/%s", refs.Org, refs.Repo)
+	}
+	return path.Join(cacheDir, repoPath+".git")
+}
+
+// validateMirror checks that mirror is a bare repository we can borrow objects from.
+func validateMirror(mirror string) (string, string, error) {
+	if info, err := os.Stat(filepath.Join(mirror, "objects")); err != nil {
+		return fmt.Sprintf("golang: stat %q", mirror), "", err
+	} else if !info.IsDir() {
+		return fmt.Sprintf("golang: stat %q", mirror), "", fmt.Errorf("%s/objects is not a directory", mirror)
+	}
+	formattedCommand, output, err := cloneCommand{dir: "/", command: "git", args: []string{"--git-dir", mirror, "rev-parse", "--is-bare-repository"}}.run()
+	if err != nil {
+		return formattedCommand, output, err
+	}
+	if strings.TrimSpace(output) != "true" {
+		return formattedCommand, output, fmt.Errorf("%s is not a bare repository", mirror)
+	}
+	return formattedCommand, output, nil
+}
+
 // gitCtx collects a few common values needed for all git commands.
 type gitCtx struct {
 	cloneDir      string
 	env           []string
 	repositoryURI string
+	// remoteURI is the repository URI without credentials, which is
+	// safe to store in the configuration of the clone.
+	remoteURI string
+	// authConfig passes the credentials of the repository to git
+	// commands of partial clones, if any.
+	authConfig string
+	// alternates is the objects directory of a reference
+	// mirror to borrow objects from, if any.
+	alternates string
+	// filter is the partial clone filter to fetch with, if any.
+	filter string
 }
 
 // gitCtxForRefs creates a gitCtx based on the provide refs and baseDir.
@@ -139,21 +213,32 @@ func gitCtxForRefs(refs prowapi.Refs, baseDir string, env []string, oauthToken s
 		cloneDir:      PathForRefs(baseDir, refs),
 		env:           env,
 		repositoryURI: repoURI,
+		filter:        refs.CloneFilter,
 	}
 	if refs.CloneURI != "" {
 		g.repositoryURI = refs.CloneURI
 	}
+	g.remoteURI = g.repositoryURI
 
 	if len(oauthToken) > 0 {
 		u, _ := url.Parse(g.repositoryURI)
 		u.User = url.UserPassword(oauthToken, "x-oauth-basic")
 		g.repositoryURI = u.String()
+		// Partial clones fetch from a configured remote, and later lazily
+		// fetch missing objects from it, so the token cannot be passed in
+		// the URI without storing it in the clone.
+		if g.filter != "" {
+			g.authConfig = fmt.Sprintf("http.%s.extraHeader=Authorization: Basic %s", g.remoteURI, basicAuth(oauthToken))
+		}
 	}
 
 	return g
 }
 
 func (g *gitCtx) gitCommand(args ...string) cloneCommand {
+	if g.authConfig != "" {
+		args = append([]string{"-c", g.authConfig}, args...)
+	}
 	return cloneCommand{dir: g.cloneDir, env: g.env, command: "git", args: args}
 }
 
@@ -167,8 +252,24 @@ var (
 	}
 )
 
+// partialCloneRemote is the name of the promisor remote partial clones
+// fetch from, which git also uses to lazily fetch filtered out objects.
+const partialCloneRemote = "origin"
+
+// remote returns what to fetch from. Partial clones need a configured
+// promisor remote, other clones fetch from the repository URI directly.
+func (g *gitCtx) remote() string {
+	if g.filter != "" {
+		return partialCloneRemote
+	}
+	return g.repositoryURI
+}
+
 func (g *gitCtx) gitFetch(fetchArgs ...string) retryCommand {
 	args := []string{"fetch"}
+	if g.filter != "" {
+		args = append(args, "--filter="+g.filter)
+	}
 	args = append(args, fetchArgs...)
 
 	return retryCommand{
@@ -185,6 +286,12 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 	commands = append(commands, cloneCommand{dir: "/", env: g.env, command: "mkdir", args: []string{"-p", g.cloneDir}})
 
 	commands = append(commands, g.gitCommand("init"))
+	if g.alternates != "" {
+		commands = append(commands, alternatesCommand{
+			path:       filepath.Join(g.cloneDir, ".git", "objects", "info", "alternates"),
+			alternates: g.alternates,
+		})
+	}
 	if gitUserName != "" {
 		commands = append(commands, g.gitCommand("config", "user.name", gitUserName))
 	}
@@ -194,6 +301,20 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 	if cookiePath != "" && refs.SkipSubmodules {
 		commands = append(commands, g.gitCommand("config", "http.cookiefile", cookiePath))
 	}
+	if g.filter != "" {
+		commands = append(commands,
+			g.gitCommand("remote", "add", partialCloneRemote, g.remoteURI),
+			g.gitCommand("config", "remote."+partialCloneRemote+".promisor", "true"),
+			g.gitCommand("config", "remote."+partialCloneRemote+".partialclonefilter", g.filter),
+			g.gitCommand("config", "extensions.partialClone", partialCloneRemote),
+		)
+	}
+	if len(refs.SparseCheckout) > 0 {
+		commands = append(commands,
+			g.gitCommand("sparse-checkout", "init", "--cone"),
+			g.gitCommand(append([]string{"sparse-checkout", "set"}, refs.SparseCheckout...)...),
+		)
+	}
 
 	var depthArgs []string
 	if d := refs.CloneDepth; d > 0 {
@@ -201,14 +322,14 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 	}
 
 	if !refs.SkipFetchHead {
-		fetchArgs := []string{g.repositoryURI, "--tags", "--prune"}
+		fetchArgs := []string{g.remote(), "--tags", "--prune"}
 		fetchArgs = append(fetchArgs, depthArgs...)
 		commands = append(commands, g.gitFetch(fetchArgs...))
 	}
 
 	{
 		fetchArgs := append([]string{}, depthArgs...)
-		fetchArgs = append(fetchArgs, g.repositoryURI, refs.BaseRef)
+		fetchArgs = append(fetchArgs, g.remote(), refs.BaseRef)
 		commands = append(commands, g.gitFetch(fetchArgs...))
 	}
 
@@ -231,6 +352,11 @@ func (g *gitCtx) commandsForBaseRef(refs prowapi.Refs, gitUserName, gitUserEmail
 	return commands
 }
 
+// cleanCommand removes anything a previous clone attempt left behind.
+func (g *gitCtx) cleanCommand() runnable {
+	return cloneCommand{dir: "/", env: g.env, command: "rm", args: []string{"-rf", g.cloneDir}}
+}
+
 // gitHeadTimestamp returns the timestamp of the HEAD commit as seconds from the
 // UNIX epoch. If unable to read the timestamp for any reason (such as missing
 // the git, or not using a git repo), it returns 0 and an error.
@@ -287,7 +413,7 @@ func (g *gitCtx) commandsForPullRefs(refs prowapi.Refs, fakeTimestamp int) []run
 		if prRef.Ref != "" {
 			ref = prRef.Ref
 		}
-		commands = append(commands, g.gitFetch(g.repositoryURI, ref))
+		commands = append(commands, g.gitFetch(g.remote(), ref))
 		var prCheckout string
 		if prRef.SHA != "" {
 			prCheckout = prRef.SHA
@@ -332,6 +458,21 @@ func (rc retryCommand) run() (string, string, error) {
 	return cmd, out, err
 }
 
+// alternatesCommand points a repository at another
+// object store by writing its alternates file.
+type alternatesCommand struct {
+	path       string
+	alternates string
+}
+
+func (c alternatesCommand) run() (string, string, error) {
+	formattedCommand := fmt.Sprintf("golang: write %q", c.path)
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return formattedCommand, "", err
+	}
+	return formattedCommand, "", ioutil.WriteFile(c.path, []byte(c.alternates+"\n"), 0644)
+}
+
 type cloneCommand struct {
 	dir     string
 	env     []string
@@ -40,6 +40,7 @@ import (
 	"k8s.io/test-infra/prow/pod-utils/clone"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
+	"k8s.io/test-infra/prow/resourceusage"
 	"k8s.io/test-infra/prow/sidecar"
 )
 
@@ -60,6 +61,11 @@ const (
 	outputMountName         = "output"
 	outputMountPath         = "/output"
 	oauthTokenFilename      = "oauth-token"
+	gitCacheMountName       = "git-cache"
+	gitCacheMountPath       = "/git-cache"
+	podAnnotationsMountName = "pod-annotations"
+	podAnnotationsMountPath = "/etc/pod-annotations"
+	podAnnotationsFilename  = "annotations"
 )
 
 // Labels returns a string slice with label consts from kube.
@@ -84,7 +90,7 @@ func VolumeMountPathsOnTestContainer() sets.String {
 
 // PodUtilsContainerNames returns a string set with pod utility container name consts in it.
 func PodUtilsContainerNames() sets.String {
-	return sets.NewString(cloneRefsName, initUploadName, entrypointName, sidecarName)
+	return sets.NewString(cloneRefsName, initUploadName, entrypointName, SidecarName)
 }
 
 // LabelsAndAnnotationsForSpec returns a minimal set of labels to add to prowjobs or its owned resources.
@@ -238,11 +244,13 @@ func CloneLogPath(logMount coreapi.VolumeMount) string {
 	return filepath.Join(logMount.MountPath, cloneLogPath)
 }
 
+// SidecarName is the name of the container that uploads the artifacts.
+const SidecarName = "sidecar"
+
 // Exposed for testing
 const (
 	entrypointName   = "place-entrypoint"
 	initUploadName   = "initupload"
-	sidecarName      = "sidecar"
 	cloneRefsName    = "clonerefs"
 	cloneRefsCommand = "/clonerefs"
 )
@@ -357,6 +365,34 @@ func cookiefileVolume(secret string) (coreapi.Volume, coreapi.VolumeMount, strin
 	return vol, mount, path.Join(mount.MountPath, base)
 }
 
+// gitCacheVolume converts a git cache into the corresponding volume and read-only mount.
+//
+// Clones borrow objects from the cache through git alternates, so any container
+// working with the cloned repositories needs this mount at the same path.
+func gitCacheVolume(cache prowapi.GitCache) (coreapi.Volume, coreapi.VolumeMount) {
+	var source coreapi.VolumeSource
+	if cache.PVC != "" {
+		source.PersistentVolumeClaim = &coreapi.PersistentVolumeClaimVolumeSource{
+			ClaimName: cache.PVC,
+			ReadOnly:  true,
+		}
+	} else {
+		source.HostPath = &coreapi.HostPathVolumeSource{
+			Path: cache.HostPath,
+		}
+	}
+	vol := coreapi.Volume{
+		Name:         gitCacheMountName,
+		VolumeSource: source,
+	}
+	mount := coreapi.VolumeMount{
+		Name:      vol.Name,
+		MountPath: gitCacheMountPath,
+		ReadOnly:  true,
+	}
+	return vol, mount
+}
+
 // CloneRefs constructs the container and volumes necessary to clone the refs requested by the ProwJob.
 //
 // The container checks out repositories specified by the ProwJob Refs to `codeMount`.
@@ -421,6 +457,14 @@ func CloneRefs(pj prowapi.ProwJob, codeMount, logMount coreapi.VolumeMount) (*co
 		cloneArgs = append(cloneArgs, "--cookiefile="+cookiefilePath)
 	}
 
+	var referenceCache string
+	if cache := pj.Spec.DecorationConfig.GitCache; cache != nil {
+		v, vm := gitCacheVolume(*cache)
+		cloneMounts = append(cloneMounts, vm)
+		cloneVolumes = append(cloneVolumes, v)
+		referenceCache = vm.MountPath
+	}
+
 	env, err := cloneEnv(clonerefs.Options{
 		CookiePath:       cookiefilePath,
 		GitRefs:          refs,
@@ -431,6 +475,7 @@ func CloneRefs(pj prowapi.ProwJob, codeMount, logMount coreapi.VolumeMount) (*co
 		Log:              CloneLogPath(logMount),
 		SrcRoot:          codeMount.MountPath,
 		OauthTokenFile:   oauthMountPath,
+		ReferenceCache:   referenceCache,
 	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("clone env: %v", err)
@@ -473,6 +518,20 @@ func metadataFile(log coreapi.VolumeMount, prefix string) string {
 	return filepath.Join(ad, fmt.Sprintf("%s-metadata.json", prefix))
 }
 
+func resourceUsageFile(log coreapi.VolumeMount, prefix string) string {
+	if prefix == "" {
+		return filepath.Join(log.MountPath, resourceusage.FileName)
+	}
+	return filepath.Join(log.MountPath, fmt.Sprintf("%s-%s", prefix, resourceusage.FileName))
+}
+
+func stepsFile(log coreapi.VolumeMount, prefix string) string {
+	if prefix == "" {
+		return filepath.Join(log.MountPath, "steps.json")
+	}
+	return filepath.Join(log.MountPath, fmt.Sprintf("%s-steps.json", prefix))
+}
+
 func artifactsDir(log coreapi.VolumeMount) string {
 	return filepath.Join(log.MountPath, "artifacts")
 }
@@ -481,8 +540,15 @@ func entrypointLocation(tools coreapi.VolumeMount) string {
 	return filepath.Join(tools.MountPath, "entrypoint")
 }
 
+func podAnnotationsFile(podAnnotations coreapi.VolumeMount) string {
+	return filepath.Join(podAnnotations.MountPath, podAnnotationsFilename)
+}
+
 // InjectEntrypoint will make the entrypoint binary in the tools volume the container's entrypoint, which will output to the log volume.
-func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration, prefix, previousMarker string, exitZero bool, log, tools coreapi.VolumeMount) (*wrapper.Options, error) {
+// A non-zero resourceSampleInterval makes entrypoint profile the resource usage of the container.
+// A non-nil podAnnotations mount makes entrypoint terminate the process once the pod is annotated to abort.
+// Steps replace the command of the container.
+func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod, resourceSampleInterval time.Duration, prefix, previousMarker string, exitZero bool, log, tools coreapi.VolumeMount, podAnnotations *coreapi.VolumeMount, steps []prowapi.Step) (*wrapper.Options, error) {
 	wrapperOptions := &wrapper.Options{
 		Args:          append(c.Command, c.Args...),
 		ContainerName: c.Name,
@@ -490,15 +556,36 @@ func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration,
 		MarkerFile:    markerFile(log, prefix),
 		MetadataFile:  metadataFile(log, prefix),
 	}
-	// TODO(fejta): use flags
-	entrypointConfigEnv, err := entrypoint.Encode(entrypoint.Options{
+	entrypointOptions := entrypoint.Options{
 		ArtifactDir:    artifactsDir(log),
 		GracePeriod:    gracePeriod,
 		Options:        wrapperOptions,
 		Timeout:        timeout,
 		AlwaysZero:     exitZero,
 		PreviousMarker: previousMarker,
-	})
+	}
+	if resourceSampleInterval > 0 {
+		wrapperOptions.ResourceUsageFile = resourceUsageFile(log, prefix)
+		resources := resourceusage.ResourcesFor(c.Resources)
+		entrypointOptions.ResourceSampleInterval = resourceSampleInterval
+		entrypointOptions.Resources = &resources
+	}
+	if podAnnotations != nil {
+		entrypointOptions.PodAnnotationsFile = podAnnotationsFile(*podAnnotations)
+	}
+	if len(steps) > 0 {
+		wrapperOptions.StepsFile = stepsFile(log, prefix)
+		for _, step := range steps {
+			entrypointOptions.Steps = append(entrypointOptions.Steps, entrypoint.Step{
+				Name:      step.Name,
+				Args:      step.Command,
+				Timeout:   step.Timeout.Get(),
+				AlwaysRun: step.AlwaysRun,
+			})
+		}
+	}
+	// TODO(fejta): use flags
+	entrypointConfigEnv, err := entrypoint.Encode(entrypointOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -507,6 +594,9 @@ func InjectEntrypoint(c *coreapi.Container, timeout, gracePeriod time.Duration,
 	c.Args = nil
 	c.Env = append(c.Env, KubeEnv(map[string]string{entrypoint.JSONConfigEnvVar: entrypointConfigEnv})...)
 	c.VolumeMounts = append(c.VolumeMounts, log, tools)
+	if podAnnotations != nil {
+		c.VolumeMounts = append(c.VolumeMounts, *podAnnotations)
+	}
 	return wrapperOptions, nil
 }
 
@@ -647,6 +737,26 @@ func ToolsMountAndVolume() (coreapi.VolumeMount, coreapi.Volume) {
 		}
 }
 
+// PodAnnotationsMountAndVolume returns the canonical volume and mount used to expose the annotations of the pod
+// to the pod utilities, which is how plank asks them to abort gracefully.
+func PodAnnotationsMountAndVolume() (coreapi.VolumeMount, coreapi.Volume) {
+	return coreapi.VolumeMount{
+			Name:      podAnnotationsMountName,
+			MountPath: podAnnotationsMountPath,
+			ReadOnly:  true,
+		}, coreapi.Volume{
+			Name: podAnnotationsMountName,
+			VolumeSource: coreapi.VolumeSource{
+				DownwardAPI: &coreapi.DownwardAPIVolumeSource{
+					Items: []coreapi.DownwardAPIVolumeFile{{
+						Path:     podAnnotationsFilename,
+						FieldRef: &coreapi.ObjectFieldSelector{FieldPath: "metadata.annotations"},
+					}},
+				},
+			},
+		}
+}
+
 func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]string, outputDir string) error {
 	// TODO(fejta): we should pass around volume names rather than forcing particular mount paths.
 
@@ -655,6 +765,12 @@ func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]stri
 	logMount, logVolume := LogMountAndVolume()
 	codeMount, codeVolume := CodeMountAndVolume()
 	toolsMount, toolsVolume := ToolsMountAndVolume()
+	var podAnnotationsMount *coreapi.VolumeMount
+	var podAnnotationsVolume *coreapi.Volume
+	if pj.Spec.DecorationConfig.GracefulAbortTimeout.Get() > 0 {
+		mount, volume := PodAnnotationsMountAndVolume()
+		podAnnotationsMount, podAnnotationsVolume = &mount, &volume
+	}
 
 	// The output volume is only used if outputDir is specified, indicating the pod-utils should
 	// copy files instead of uploading to GCS.
@@ -713,14 +829,14 @@ func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]stri
 		if len(spec.Containers) == 1 {
 			prefix = ""
 		}
-		wrapperOptions, err := InjectEntrypoint(&spec.Containers[i], pj.Spec.DecorationConfig.Timeout.Get(), pj.Spec.DecorationConfig.GracePeriod.Get(), prefix, previous, exitZero, logMount, toolsMount)
+		wrapperOptions, err := InjectEntrypoint(&spec.Containers[i], pj.Spec.DecorationConfig.Timeout.Get(), pj.Spec.DecorationConfig.GracePeriod.Get(), pj.Spec.DecorationConfig.ResourceSampleInterval.Get(), prefix, previous, exitZero, logMount, toolsMount, podAnnotationsMount, pj.Spec.DecorationConfig.Steps)
 		if err != nil {
 			return fmt.Errorf("wrap container: %v", err)
 		}
 		wrappers = append(wrappers, *wrapperOptions)
 	}
 
-	sidecar, err := Sidecar(pj.Spec.DecorationConfig, blobStorageOptions, blobStorageMounts, logMount, outputMount, encodedJobSpec, !RequirePassingEntries, !IgnoreInterrupts, wrappers...)
+	sidecar, err := Sidecar(pj.Spec.DecorationConfig, blobStorageOptions, blobStorageMounts, logMount, outputMount, podAnnotationsMount, encodedJobSpec, !RequirePassingEntries, !IgnoreInterrupts, wrappers...)
 	if err != nil {
 		return fmt.Errorf("create sidecar: %v", err)
 	}
@@ -730,11 +846,18 @@ func decorate(spec *coreapi.PodSpec, pj *prowapi.ProwJob, rawEnv map[string]stri
 	if outputVolume != nil {
 		spec.Volumes = append(spec.Volumes, *outputVolume)
 	}
+	if podAnnotationsVolume != nil {
+		spec.Volumes = append(spec.Volumes, *podAnnotationsVolume)
+	}
 
 	if len(refs) > 0 {
 		for i, container := range spec.Containers {
 			spec.Containers[i].WorkingDir = DetermineWorkDir(codeMount.MountPath, refs)
 			spec.Containers[i].VolumeMounts = append(container.VolumeMounts, codeMount)
+			if cache := pj.Spec.DecorationConfig.GitCache; cache != nil && cloner != nil {
+				_, cacheMount := gitCacheVolume(*cache)
+				spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, cacheMount)
+			}
 		}
 		spec.Volumes = append(spec.Volumes, append(cloneVolumes, codeVolume)...)
 	}
@@ -775,14 +898,21 @@ const (
 	IgnoreInterrupts = true
 )
 
-func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blobStorageMounts []coreapi.VolumeMount, logMount coreapi.VolumeMount, outputMount *coreapi.VolumeMount, encodedJobSpec string, requirePassingEntries, ignoreInterrupts bool, wrappers ...wrapper.Options) (*coreapi.Container, error) {
+// Sidecar creates the container that uploads the artifacts of the wrapped containers.
+// A non-nil podAnnotationsMount makes sidecar record graceful aborts and report when it uploaded everything.
+func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blobStorageMounts []coreapi.VolumeMount, logMount coreapi.VolumeMount, outputMount, podAnnotationsMount *coreapi.VolumeMount, encodedJobSpec string, requirePassingEntries, ignoreInterrupts bool, wrappers ...wrapper.Options) (*coreapi.Container, error) {
 	gcsOptions.Items = append(gcsOptions.Items, artifactsDir(logMount))
-	sidecarConfigEnv, err := sidecar.Encode(sidecar.Options{
+	sidecarOptions := sidecar.Options{
 		GcsOptions:       &gcsOptions,
 		Entries:          wrappers,
 		EntryError:       requirePassingEntries,
 		IgnoreInterrupts: ignoreInterrupts,
-	})
+	}
+	if podAnnotationsMount != nil {
+		sidecarOptions.PodAnnotationsFile = podAnnotationsFile(*podAnnotationsMount)
+		sidecarOptions.UploadCompleteFile = coreapi.TerminationMessagePathDefault
+	}
+	sidecarConfigEnv, err := sidecar.Encode(sidecarOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -791,9 +921,12 @@ func Sidecar(config *prowapi.DecorationConfig, gcsOptions gcsupload.Options, blo
 	if outputMount != nil {
 		mounts = append(mounts, *outputMount)
 	}
+	if podAnnotationsMount != nil {
+		mounts = append(mounts, *podAnnotationsMount)
+	}
 
 	container := &coreapi.Container{
-		Name:    sidecarName,
+		Name:    SidecarName,
 		Image:   config.UtilityImages.Sidecar,
 		Command: []string{"/sidecar"}, // TODO(fejta): remove, use image's entrypoint
 		Env: KubeEnv(map[string]string{
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package downwardapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Graceful aborts let the pod utilities upload the artifacts of a job before
+// its pod is deleted. Instead of deleting the pod right away, plank annotates
+// it with the abort annotations. The annotations are exposed to entrypoint
+// and sidecar through the downward API: entrypoint terminates the test
+// process and sidecar records the result in finished.json, uploads the
+// artifacts and writes UploadCompleteMessage to its termination message.
+// Plank deletes the pod once it sees that message.
+const (
+	// AbortAnnotation carries the result of an aborted job, either
+	// AbortResultAborted or AbortResultTimedOut.
+	AbortAnnotation = "prow.k8s.io/abort"
+	// AbortReasonAnnotation carries why the job was aborted.
+	AbortReasonAnnotation = "prow.k8s.io/abort-reason"
+	// AbortTimeAnnotation carries when plank asked the pod to abort,
+	// in RFC 3339 format.
+	AbortTimeAnnotation = "prow.k8s.io/abort-time"
+
+	// AbortResultAborted is the result of jobs that were aborted, e.g.
+	// because a newer version of the job started.
+	AbortResultAborted = "aborted"
+	// AbortResultTimedOut is the result of jobs that ran for too long.
+	AbortResultTimedOut = "timed_out"
+
+	// UploadCompleteMessage is what sidecar writes to its termination
+	// message once it uploaded all artifacts.
+	UploadCompleteMessage = "upload complete"
+)
+
+// AbortRequest is how plank asked a pod to abort.
+type AbortRequest struct {
+	// Result is either AbortResultAborted or AbortResultTimedOut.
+	Result string
+	// Reason is why the job was aborted.
+	Reason string
+}
+
+// ReadAbortRequest reads the annotations file that the downward API
+// projects into the pod and returns how the pod was asked to abort, or nil
+// if it was not.
+func ReadAbortRequest(path string) (*AbortRequest, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	annotations, err := parseAnnotations(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	result, ok := annotations[AbortAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	return &AbortRequest{Result: result, Reason: annotations[AbortReasonAnnotation]}, nil
+}
+
+// parseAnnotations parses annotations in the format of the downward API,
+// one key="value" pair per line with the value quoted like a Go string.
+func parseAnnotations(raw string) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, line := range strings.Split(raw, "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %q is not a key=value pair", line)
+		}
+		value, err := strconv.Unquote(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("value of %s is not quoted: %w", parts[0], err)
+		}
+		annotations[parts[0]] = value
+	}
+	return annotations, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package downwardapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadAbortRequest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations *string
+		expected    *AbortRequest
+		expectedErr bool
+	}{
+		{
+			name: "no annotations file",
+		},
+		{
+			name:        "not aborted",
+			annotations: stringPtr("prow.k8s.io/job=\"ci-job\"\n"),
+		},
+		{
+			name:        "aborted",
+			annotations: stringPtr("prow.k8s.io/abort=\"aborted\"\nprow.k8s.io/abort-reason=\"Aborted as the \\\"newer\\\" version of this job is running.\"\nprow.k8s.io/job=\"ci-job\"\n"),
+			expected:    &AbortRequest{Result: AbortResultAborted, Reason: `Aborted as the "newer" version of this job is running.`},
+		},
+		{
+			name:        "timed out without a reason",
+			annotations: stringPtr("prow.k8s.io/abort=\"timed_out\""),
+			expected:    &AbortRequest{Result: AbortResultTimedOut},
+		},
+		{
+			name:        "malformed",
+			annotations: stringPtr("prow.k8s.io/abort=aborted\n"),
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "annotations")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "annotations")
+			if tc.annotations != nil {
+				if err := ioutil.WriteFile(path, []byte(*tc.annotations), 0644); err != nil {
+					t.Fatalf("failed to write annotations: %v", err)
+				}
+			}
+
+			actual, err := ReadAbortRequest(path)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -58,6 +58,17 @@ type Options struct {
 	// Prow will parse the file and merge it into
 	// the `metadata` field in finished.json
 	MetadataFile string `json:"metadata_file"`
+
+	// ResourceUsageFile is where entrypoint writes the
+	// samples of the CPU and memory used by the container
+	// when resource profiling is enabled. Sidecar uploads
+	// it alongside the build log.
+	ResourceUsageFile string `json:"resource_usage_file,omitempty"`
+
+	// StepsFile is where entrypoint writes the results
+	// of the steps of a multi-step job. Sidecar records
+	// them in the `metadata` field in finished.json.
+	StepsFile string `json:"steps_file,omitempty"`
 }
 
 type MarkerResult struct {
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StepsMetadataKey is where sidecar records the results of the
+// steps of a multi-step job in the metadata of finished.json.
+const StepsMetadataKey = "steps"
+
+// The results of a step.
+const (
+	StepSucceeded = "SUCCESS"
+	StepFailed    = "FAILURE"
+	StepTimedOut  = "TIMED_OUT"
+	StepAborted   = "ABORTED"
+	StepSkipped   = "SKIPPED"
+)
+
+// StepResult records how a step of a multi-step job ended.
+type StepResult struct {
+	Name string `json:"name"`
+	// Result is one of the Step* results.
+	Result string `json:"result"`
+	// Started is when the step started, in seconds since the epoch.
+	// Skipped steps never started.
+	Started int64 `json:"started,omitempty"`
+	// Duration is how long the step ran, in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	// ExitCode is what the process of the step exited with.
+	ExitCode int `json:"exit_code,omitempty"`
+}
+
+// Elapsed returns how long the step ran.
+func (r StepResult) Elapsed() time.Duration {
+	return time.Duration(r.Duration * float64(time.Second))
+}
+
+// StepResultsFromMetadata returns the step results recorded in the
+// metadata of finished.json, or nil if the job had no steps.
+func StepResultsFromMetadata(metadata map[string]interface{}) ([]StepResult, error) {
+	raw, ok := metadata[StepsMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+	// The metadata was decoded without knowing its type.
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var results []StepResult
+	if err := json.Unmarshal(encoded, &results); err != nil {
+		return nil, fmt.Errorf("invalid %s metadata: %w", StepsMetadataKey, err)
+	}
+	return results, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wrapper
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStepResultsFromMetadata(t *testing.T) {
+	testCases := []struct {
+		name        string
+		finished    string
+		expected    []StepResult
+		expectedErr bool
+	}{
+		{
+			name:     "no steps",
+			finished: `{"metadata": {"node": "n1"}}`,
+		},
+		{
+			name:     "steps",
+			finished: `{"metadata": {"steps": [{"name": "build", "result": "SUCCESS", "started": 1615716000, "duration": 90.5}, {"name": "test", "result": "FAILURE", "started": 1615716091, "duration": 30, "exit_code": 2}, {"name": "push", "result": "SKIPPED"}]}}`,
+			expected: []StepResult{
+				{Name: "build", Result: StepSucceeded, Started: 1615716000, Duration: 90.5},
+				{Name: "test", Result: StepFailed, Started: 1615716091, Duration: 30, ExitCode: 2},
+				{Name: "push", Result: StepSkipped},
+			},
+		},
+		{
+			name:        "malformed steps",
+			finished:    `{"metadata": {"steps": "build"}}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var finished struct {
+				Metadata map[string]interface{} `json:"metadata"`
+			}
+			if err := json.Unmarshal([]byte(tc.finished), &finished); err != nil {
+				t.Fatalf("failed to unmarshal finished.json: %v", err)
+			}
+			actual, err := StepResultsFromMetadata(finished.Metadata)
+			if err != nil != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package subscriber
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"cloud.google.com/go/pubsub"
@@ -31,12 +32,16 @@ import (
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	reporter "k8s.io/test-infra/prow/crier/reporters/pubsub"
+	"k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/pjutil"
 )
 
 const (
-	prowEventType        = "prow.k8s.io/pubsub.EventType"
-	periodicProwJobEvent = "prow.k8s.io/pubsub.PeriodicProwJobEvent"
+	prowEventType          = "prow.k8s.io/pubsub.EventType"
+	periodicProwJobEvent   = "prow.k8s.io/pubsub.PeriodicProwJobEvent"
+	presubmitProwJobEvent  = "prow.k8s.io/pubsub.PresubmitProwJobEvent"
+	postsubmitProwJobEvent = "prow.k8s.io/pubsub.PostsubmitProwJobEvent"
 )
 
 // PeriodicProwJobEvent contains the minimum information required to start a ProwJob.
@@ -70,6 +75,56 @@ func (pe *PeriodicProwJobEvent) ToMessage() (*pubsub.Message, error) {
 	return &message, nil
 }
 
+// ProwJobEvent contains the information required to start a ProwJob for
+// the given refs.
+type ProwJobEvent struct {
+	Name        string            `json:"name"`
+	Refs        *prowapi.Refs     `json:"refs,omitempty"`
+	Envs        map[string]string `json:"envs,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// FromPayload set the ProwJobEvent from the PubSub message payload.
+func (pe *ProwJobEvent) FromPayload(data []byte) error {
+	return json.Unmarshal(data, pe)
+}
+
+func (pe *ProwJobEvent) toMessage(eventType string) (*pubsub.Message, error) {
+	data, err := json.Marshal(pe)
+	if err != nil {
+		return nil, err
+	}
+	return &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			prowEventType: eventType,
+		},
+	}, nil
+}
+
+// PresubmitProwJobEvent starts a presubmit. Its refs must contain the base
+// and the pull requests to test.
+type PresubmitProwJobEvent struct {
+	ProwJobEvent
+}
+
+// ToMessage generates a PubSub Message from a PresubmitProwJobEvent.
+func (pe *PresubmitProwJobEvent) ToMessage() (*pubsub.Message, error) {
+	return pe.toMessage(presubmitProwJobEvent)
+}
+
+// PostsubmitProwJobEvent starts a postsubmit. Its refs must contain the base
+// to test and no pull requests.
+type PostsubmitProwJobEvent struct {
+	ProwJobEvent
+}
+
+// ToMessage generates a PubSub Message from a PostsubmitProwJobEvent.
+func (pe *PostsubmitProwJobEvent) ToMessage() (*pubsub.Message, error) {
+	return pe.toMessage(postsubmitProwJobEvent)
+}
+
 // ProwJobClient mostly for testing.
 type ProwJobClient interface {
 	Create(context.Context, *prowapi.ProwJob, metav1.CreateOptions) (*prowapi.ProwJob, error)
@@ -83,6 +138,9 @@ type Subscriber struct {
 	Metrics       *Metrics
 	ProwJobClient ProwJobClient
 	Reporter      reportClient
+	// GitClient is used to resolve in-repo config jobs. It may be nil if
+	// in-repo config is not enabled.
+	GitClient git.ClientFactory
 }
 
 type messageInterface interface {
@@ -149,6 +207,20 @@ func (s *Subscriber) handleMessage(msg messageInterface, subscription string) er
 			s.Metrics.ErrorCounter.With(prometheus.Labels{subscriptionLabel: subscription})
 		}
 		return err
+	case presubmitProwJobEvent:
+		err := s.handlePresubmitJob(l, msg, subscription)
+		if err != nil {
+			l.WithError(err).Error("failed to create Prow Presubmit Job")
+			s.Metrics.ErrorCounter.With(prometheus.Labels{subscriptionLabel: subscription})
+		}
+		return err
+	case postsubmitProwJobEvent:
+		err := s.handlePostsubmitJob(l, msg, subscription)
+		if err != nil {
+			l.WithError(err).Error("failed to create Prow Postsubmit Job")
+			s.Metrics.ErrorCounter.With(prometheus.Labels{subscriptionLabel: subscription})
+		}
+		return err
 	}
 	err = fmt.Errorf("unsupported event type")
 	l.WithError(err).Error("failed to read message")
@@ -156,62 +228,209 @@ func (s *Subscriber) handleMessage(msg messageInterface, subscription string) er
 	return err
 }
 
-func (s *Subscriber) handlePeriodicJob(l *logrus.Entry, msg messageInterface, subscription string) error {
+// correlationID returns the ID that is echoed in the reports of the ProwJob
+// created for the message. Callers can set it as a message attribute, it
+// defaults to the ID of the message.
+func correlationID(msg messageInterface) string {
+	if id := msg.getAttributes()[reporter.PubSubCorrelationIDLabel]; id != "" {
+		return id
+	}
+	return msg.getID()
+}
 
-	var pe PeriodicProwJobEvent
-	var prowJob prowapi.ProwJob
-
-	reportProwJobFailure := func(pj *prowapi.ProwJob, err error) {
-		pj.Status.State = prowapi.ErrorState
-		pj.Status.Description = err.Error()
-		if s.Reporter.ShouldReport(context.TODO(), l, &prowJob) {
-			if _, _, err := s.Reporter.Report(context.TODO(), l, &prowJob); err != nil {
-				l.Warningf("failed to report status. %v", err)
+// annotationsFor returns the annotations of the event including the
+// correlation ID of the message.
+func annotationsFor(msg messageInterface, annotations map[string]string) map[string]string {
+	res := map[string]string{}
+	for k, v := range annotations {
+		res[k] = v
+	}
+	res[reporter.PubSubCorrelationIDLabel] = correlationID(msg)
+	return res
+}
+
+func (s *Subscriber) reportProwJobFailure(l *logrus.Entry, pj *prowapi.ProwJob, err error) {
+	pj.Status.State = prowapi.ErrorState
+	pj.Status.Description = err.Error()
+	if s.Reporter.ShouldReport(context.TODO(), l, pj) {
+		if _, _, err := s.Reporter.Report(context.TODO(), l, pj); err != nil {
+			l.Warningf("failed to report status. %v", err)
+		}
+	}
+}
+
+// failToCreate reports that the job of the event can not be created.
+func (s *Subscriber) failToCreate(l *logrus.Entry, name string, annotations map[string]string, err error) error {
+	l.WithError(err).Errorf("failed to create job %q", name)
+	prowJob := pjutil.NewProwJob(prowapi.ProwJobSpec{}, nil, annotations)
+	s.reportProwJobFailure(l, &prowJob, err)
+	return err
+}
+
+// createProwJob creates a ProwJob from the spec, adding the labels,
+// annotations and environment variables of the event.
+func (s *Subscriber) createProwJob(l *logrus.Entry, name string, spec prowapi.ProwJobSpec, jobLabels, eventLabels, annotations, envs map[string]string) error {
+	// Adds / Updates Labels from prow job event
+	labels := map[string]string{}
+	for k, v := range jobLabels {
+		labels[k] = v
+	}
+	for k, v := range eventLabels {
+		labels[k] = v
+	}
+
+	// Adds annotations
+	prowJob := pjutil.NewProwJob(spec, labels, annotations)
+	// Adds / Updates Environments to containers
+	if prowJob.Spec.PodSpec != nil {
+		for i, c := range prowJob.Spec.PodSpec.Containers {
+			for k, v := range envs {
+				c.Env = append(c.Env, coreapi.EnvVar{Name: k, Value: v})
 			}
+			prowJob.Spec.PodSpec.Containers[i].Env = c.Env
 		}
 	}
 
+	if _, err := s.ProwJobClient.Create(context.TODO(), &prowJob, metav1.CreateOptions{}); err != nil {
+		l.WithError(err).Errorf("failed to create job %q as %q", name, prowJob.Name)
+		s.reportProwJobFailure(l, &prowJob, err)
+		return err
+	}
+	l.Infof("%s job %q created as %q", spec.Type, name, prowJob.Name)
+	return nil
+}
+
+func (s *Subscriber) handlePeriodicJob(l *logrus.Entry, msg messageInterface, subscription string) error {
+	var pe PeriodicProwJobEvent
 	if err := pe.FromPayload(msg.getPayload()); err != nil {
 		return err
 	}
+	annotations := annotationsFor(msg, pe.Annotations)
+
 	var periodicJob *config.Periodic
 	for _, job := range s.ConfigAgent.Config().AllPeriodics() {
 		if job.Name == pe.Name {
+			job := job
 			periodicJob = &job
 			break
 		}
 	}
 	if periodicJob == nil {
-		err := fmt.Errorf("failed to find associated periodic job %q", pe.Name)
-		l.WithError(err).Errorf("failed to create job %q", pe.Name)
-		prowJob = pjutil.NewProwJob(prowapi.ProwJobSpec{}, nil, pe.Annotations)
-		reportProwJobFailure(&prowJob, err)
-		return err
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("failed to find associated periodic job %q", pe.Name))
 	}
 
-	prowJobSpec := pjutil.PeriodicSpec(*periodicJob)
-	// Adds / Updates Labels from prow job event
-	for k, v := range pe.Labels {
-		periodicJob.Labels[k] = v
+	return s.createProwJob(l, pe.Name, pjutil.PeriodicSpec(*periodicJob), periodicJob.Labels, pe.Labels, annotations, pe.Envs)
+}
+
+// validateRefs checks that the refs of an event identify the commits to
+// test, so that the job config can be resolved at them.
+func validateRefs(refs *prowapi.Refs, withPulls bool) error {
+	if refs == nil {
+		return errors.New("refs are required")
+	}
+	if refs.Org == "" || refs.Repo == "" || refs.BaseRef == "" || refs.BaseSHA == "" {
+		return errors.New("refs must contain org, repo, base_ref and base_sha")
+	}
+	if withPulls && len(refs.Pulls) == 0 {
+		return errors.New("refs must contain the pulls to test")
+	}
+	if !withPulls && len(refs.Pulls) > 0 {
+		return errors.New("refs of postsubmits must not contain pulls")
+	}
+	for _, pull := range refs.Pulls {
+		if pull.Number == 0 || pull.SHA == "" {
+			return errors.New("pulls must contain number and sha")
+		}
+	}
+	return nil
+}
+
+func refGetter(sha string) config.RefGetter {
+	return func() (string, error) {
+		return sha, nil
 	}
+}
 
-	// Adds annotations
-	prowJob = pjutil.NewProwJob(prowJobSpec, periodicJob.Labels, pe.Annotations)
-	// Adds / Updates Environments to containers
-	if prowJob.Spec.PodSpec != nil {
-		for i, c := range prowJob.Spec.PodSpec.Containers {
-			for k, v := range pe.Envs {
-				c.Env = append(c.Env, coreapi.EnvVar{Name: k, Value: v})
-			}
-			prowJob.Spec.PodSpec.Containers[i].Env = c.Env
+// checkInRepoConfig fails if the jobs of the repo can not be resolved.
+func (s *Subscriber) checkInRepoConfig(orgRepo string) error {
+	if s.GitClient == nil && s.ConfigAgent.Config().InRepoConfigEnabled(orgRepo) {
+		return fmt.Errorf("in-repo config is enabled for %s but no git client is configured", orgRepo)
+	}
+	return nil
+}
+
+func (s *Subscriber) handlePresubmitJob(l *logrus.Entry, msg messageInterface, subscription string) error {
+	var pe PresubmitProwJobEvent
+	if err := pe.FromPayload(msg.getPayload()); err != nil {
+		return err
+	}
+	annotations := annotationsFor(msg, pe.Annotations)
+	if err := validateRefs(pe.Refs, true); err != nil {
+		return s.failToCreate(l, pe.Name, annotations, err)
+	}
+	orgRepo := pe.Refs.Org + "/" + pe.Refs.Repo
+	if err := s.checkInRepoConfig(orgRepo); err != nil {
+		return s.failToCreate(l, pe.Name, annotations, err)
+	}
+
+	var headSHAGetters []config.RefGetter
+	for _, pull := range pe.Refs.Pulls {
+		headSHAGetters = append(headSHAGetters, refGetter(pull.SHA))
+	}
+	presubmits, err := s.ConfigAgent.Config().GetPresubmits(s.GitClient, orgRepo, refGetter(pe.Refs.BaseSHA), headSHAGetters...)
+	if err != nil {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("failed to get presubmits for %s: %v", orgRepo, err))
+	}
+	var presubmitJob *config.Presubmit
+	for _, job := range presubmits {
+		if job.Name == pe.Name {
+			job := job
+			presubmitJob = &job
+			break
 		}
 	}
+	if presubmitJob == nil {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("failed to find associated presubmit job %q for %s", pe.Name, orgRepo))
+	}
+	if !presubmitJob.CouldRun(pe.Refs.BaseRef) {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("presubmit job %q does not run on branch %q", pe.Name, pe.Refs.BaseRef))
+	}
 
-	if _, err := s.ProwJobClient.Create(context.TODO(), &prowJob, metav1.CreateOptions{}); err != nil {
-		l.WithError(err).Errorf("failed to create job %q as %q", pe.Name, prowJob.Name)
-		reportProwJobFailure(&prowJob, err)
+	return s.createProwJob(l, pe.Name, pjutil.PresubmitSpec(*presubmitJob, *pe.Refs), presubmitJob.Labels, pe.Labels, annotations, pe.Envs)
+}
+
+func (s *Subscriber) handlePostsubmitJob(l *logrus.Entry, msg messageInterface, subscription string) error {
+	var pe PostsubmitProwJobEvent
+	if err := pe.FromPayload(msg.getPayload()); err != nil {
 		return err
 	}
-	l.Infof("periodic job %q created as %q", pe.Name, prowJob.Name)
-	return nil
+	annotations := annotationsFor(msg, pe.Annotations)
+	if err := validateRefs(pe.Refs, false); err != nil {
+		return s.failToCreate(l, pe.Name, annotations, err)
+	}
+	orgRepo := pe.Refs.Org + "/" + pe.Refs.Repo
+	if err := s.checkInRepoConfig(orgRepo); err != nil {
+		return s.failToCreate(l, pe.Name, annotations, err)
+	}
+
+	postsubmits, err := s.ConfigAgent.Config().GetPostsubmits(s.GitClient, orgRepo, refGetter(pe.Refs.BaseSHA))
+	if err != nil {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("failed to get postsubmits for %s: %v", orgRepo, err))
+	}
+	var postsubmitJob *config.Postsubmit
+	for _, job := range postsubmits {
+		if job.Name == pe.Name {
+			job := job
+			postsubmitJob = &job
+			break
+		}
+	}
+	if postsubmitJob == nil {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("failed to find associated postsubmit job %q for %s", pe.Name, orgRepo))
+	}
+	if !postsubmitJob.CouldRun(pe.Refs.BaseRef) {
+		return s.failToCreate(l, pe.Name, annotations, fmt.Errorf("postsubmit job %q does not run on branch %q", pe.Name, pe.Refs.BaseRef))
+	}
+
+	return s.createProwJob(l, pe.Name, pjutil.PostsubmitSpec(*postsubmitJob, *pe.Refs), postsubmitJob.Labels, pe.Labels, annotations, pe.Envs)
 }
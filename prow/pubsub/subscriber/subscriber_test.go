@@ -31,6 +31,7 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clienttesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -661,3 +662,192 @@ func TestPullServer_RunConfigChange(t *testing.T) {
 		}
 	}
 }
+
+func TestHandlePresubmitAndPostsubmitJob(t *testing.T) {
+	presubmits := []config.Presubmit{{
+		JobBase: config.JobBase{
+			Name:   "pull-test",
+			Labels: map[string]string{"job": "label"},
+			Spec:   &v1.PodSpec{Containers: []v1.Container{{Name: "test"}}},
+		},
+		Brancher: config.Brancher{Branches: []string{"master"}},
+	}}
+	if err := config.SetPresubmitRegexes(presubmits); err != nil {
+		t.Fatalf("failed to set presubmit regexes: %v", err)
+	}
+	postsubmits := []config.Postsubmit{{
+		JobBase: config.JobBase{
+			Name: "post-test",
+			Spec: &v1.PodSpec{Containers: []v1.Container{{Name: "test"}}},
+		},
+		Brancher: config.Brancher{Branches: []string{"master"}},
+	}}
+	if err := config.SetPostsubmitRegexes(postsubmits); err != nil {
+		t.Fatalf("failed to set postsubmit regexes: %v", err)
+	}
+	pubsubAnnotations := map[string]string{
+		reporter.PubSubProjectLabel: "project",
+		reporter.PubSubTopicLabel:   "topic",
+	}
+	pullRefs := &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []prowapi.Pull{{Number: 1, SHA: "head"}}}
+	baseRefs := &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"}
+
+	for _, tc := range []struct {
+		name                  string
+		msg                   func() (*pubsub.Message, error)
+		attributes            map[string]string
+		inRepoConfig          bool
+		err                   string
+		reported              bool
+		expectedType          prowapi.ProwJobType
+		expectedCorrelationID string
+	}{
+		{
+			name: "presubmit is created",
+			msg: (&PresubmitProwJobEvent{ProwJobEvent{
+				Name:        "pull-test",
+				Refs:        pullRefs,
+				Labels:      map[string]string{"event": "label"},
+				Envs:        map[string]string{"ENV": "value"},
+				Annotations: pubsubAnnotations,
+			}}).ToMessage,
+			expectedType:          prowapi.PresubmitJob,
+			expectedCorrelationID: "id",
+		},
+		{
+			name: "postsubmit is created with correlation ID from the attributes",
+			msg: (&PostsubmitProwJobEvent{ProwJobEvent{
+				Name: "post-test",
+				Refs: baseRefs,
+			}}).ToMessage,
+			attributes:            map[string]string{reporter.PubSubCorrelationIDLabel: "correlation"},
+			expectedType:          prowapi.PostsubmitJob,
+			expectedCorrelationID: "correlation",
+		},
+		{
+			name: "presubmit without pulls",
+			msg: (&PresubmitProwJobEvent{ProwJobEvent{
+				Name:        "pull-test",
+				Refs:        baseRefs,
+				Annotations: pubsubAnnotations,
+			}}).ToMessage,
+			err:      "refs must contain the pulls to test",
+			reported: true,
+		},
+		{
+			name: "postsubmit with pulls",
+			msg: (&PostsubmitProwJobEvent{ProwJobEvent{
+				Name: "post-test",
+				Refs: pullRefs,
+			}}).ToMessage,
+			err: "refs of postsubmits must not contain pulls",
+		},
+		{
+			name: "presubmit without refs",
+			msg: (&PresubmitProwJobEvent{ProwJobEvent{
+				Name: "pull-test",
+			}}).ToMessage,
+			err: "refs are required",
+		},
+		{
+			name: "unknown presubmit",
+			msg: (&PresubmitProwJobEvent{ProwJobEvent{
+				Name:        "pull-unknown",
+				Refs:        pullRefs,
+				Annotations: pubsubAnnotations,
+			}}).ToMessage,
+			err:      "failed to find associated presubmit job \"pull-unknown\" for org/repo",
+			reported: true,
+		},
+		{
+			name: "postsubmit on other branch",
+			msg: (&PostsubmitProwJobEvent{ProwJobEvent{
+				Name: "post-test",
+				Refs: &prowapi.Refs{Org: "org", Repo: "repo", BaseRef: "release", BaseSHA: "base"},
+			}}).ToMessage,
+			err: "postsubmit job \"post-test\" does not run on branch \"release\"",
+		},
+		{
+			name: "in-repo config without git client",
+			msg: (&PresubmitProwJobEvent{ProwJobEvent{
+				Name: "pull-test",
+				Refs: pullRefs,
+			}}).ToMessage,
+			inRepoConfig: true,
+			err:          "in-repo config is enabled for org/repo but no git client is configured",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				JobConfig: config.JobConfig{
+					PresubmitsStatic:  map[string][]config.Presubmit{"org/repo": presubmits},
+					PostsubmitsStatic: map[string][]config.Postsubmit{"org/repo": postsubmits},
+				},
+				ProwConfig: config.ProwConfig{ProwJobNamespace: "prowjobs"},
+			}
+			if tc.inRepoConfig {
+				enabled := true
+				cfg.InRepoConfig.Enabled = map[string]*bool{"org/repo": &enabled}
+			}
+			ca := &config.Agent{}
+			ca.Set(cfg)
+			fakeProwJobClient := fake.NewSimpleClientset()
+			fr := fakeReporter{}
+			s := Subscriber{
+				Metrics:       NewMetrics(),
+				ProwJobClient: fakeProwJobClient.ProwV1().ProwJobs("prowjobs"),
+				ConfigAgent:   ca,
+				Reporter:      &fr,
+			}
+			m, err := tc.msg()
+			if err != nil {
+				t.Fatalf("failed to create message: %v", err)
+			}
+			m.ID = "id"
+			for k, v := range tc.attributes {
+				m.Attributes[k] = v
+			}
+
+			err = s.handleMessage(&pubSubMessage{*m}, "subscription")
+			if err != nil {
+				if err.Error() != tc.err {
+					t.Fatalf("Expected error %q got %q", tc.err, err.Error())
+				}
+			} else if tc.err != "" {
+				t.Fatalf("Expected error %q got none", tc.err)
+			}
+			if fr.reported != tc.reported {
+				t.Errorf("Expected Reporting: %t, found: %t", tc.reported, fr.reported)
+			}
+			if tc.err != "" {
+				return
+			}
+
+			pjs, err := fakeProwJobClient.ProwV1().ProwJobs("prowjobs").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list prowjobs: %v", err)
+			}
+			if len(pjs.Items) != 1 {
+				t.Fatalf("Expected to create 1 ProwJobs, got %d", len(pjs.Items))
+			}
+			pj := pjs.Items[0]
+			if pj.Spec.Type != tc.expectedType {
+				t.Errorf("Expected job type %s, got %s", tc.expectedType, pj.Spec.Type)
+			}
+			if pj.Spec.Refs == nil || pj.Spec.Refs.BaseSHA != "base" {
+				t.Errorf("Expected refs to be set, got %v", pj.Spec.Refs)
+			}
+			if id := pj.Annotations[reporter.PubSubCorrelationIDLabel]; id != tc.expectedCorrelationID {
+				t.Errorf("Expected correlation ID %q, got %q", tc.expectedCorrelationID, id)
+			}
+			if tc.expectedType == prowapi.PresubmitJob {
+				if pj.Labels["job"] != "label" || pj.Labels["event"] != "label" {
+					t.Errorf("Expected labels of the job and the event, got %v", pj.Labels)
+				}
+				if env := pj.Spec.PodSpec.Containers[0].Env; len(env) != 1 || env[0].Name != "ENV" {
+					t.Errorf("Expected env from the event, got %v", env)
+				}
+			}
+		})
+	}
+}
@@ -57,6 +57,7 @@ type Config struct {
 	Approvers         []string `json:"approvers,omitempty"`
 	Reviewers         []string `json:"reviewers,omitempty"`
 	RequiredReviewers []string `json:"required_reviewers,omitempty"`
+	RequiredApprovals int      `json:"required_approvals,omitempty"`
 	Labels            []string `json:"labels,omitempty"`
 }
 
@@ -68,7 +69,7 @@ type SimpleConfig struct {
 
 // Empty checks if a SimpleConfig could be considered empty
 func (s *SimpleConfig) Empty() bool {
-	return len(s.Approvers) == 0 && len(s.Reviewers) == 0 && len(s.RequiredReviewers) == 0 && len(s.Labels) == 0
+	return len(s.Approvers) == 0 && len(s.Reviewers) == 0 && len(s.RequiredReviewers) == 0 && s.RequiredApprovals == 0 && len(s.Labels) == 0
 }
 
 // FullConfig contains Filters which apply specific Config to files matching its regexp
@@ -229,6 +230,7 @@ type RepoOwner interface {
 	LeafReviewers(path string) sets.String
 	Reviewers(path string) layeredsets.String
 	RequiredReviewers(path string) sets.String
+	RequiredApprovals(path string) int
 	ParseSimpleConfig(path string) (SimpleConfig, error)
 	ParseFullConfig(path string) (FullConfig, error)
 	TopLevelApprovers() sets.String
@@ -244,6 +246,7 @@ type RepoOwners struct {
 	approvers         map[string]map[*regexp.Regexp]sets.String
 	reviewers         map[string]map[*regexp.Regexp]sets.String
 	requiredReviewers map[string]map[*regexp.Regexp]sets.String
+	requiredApprovals map[string]map[*regexp.Regexp]int
 	labels            map[string]map[*regexp.Regexp]sets.String
 	options           map[string]dirOptions
 
@@ -486,6 +489,7 @@ func loadOwnersFrom(baseDir string, mdYaml bool, aliases RepoAliases, dirIgnorel
 		approvers:         make(map[string]map[*regexp.Regexp]sets.String),
 		reviewers:         make(map[string]map[*regexp.Regexp]sets.String),
 		requiredReviewers: make(map[string]map[*regexp.Regexp]sets.String),
+		requiredApprovals: make(map[string]map[*regexp.Regexp]int),
 		labels:            make(map[string]map[*regexp.Regexp]sets.String),
 		options:           make(map[string]dirOptions),
 
@@ -718,6 +722,12 @@ func (o *RepoOwners) applyConfigToPath(path string, re *regexp.Regexp, config *C
 		}
 		o.requiredReviewers[path][re] = o.ExpandAliases(NormLogins(config.RequiredReviewers))
 	}
+	if config.RequiredApprovals > 0 {
+		if o.requiredApprovals[path] == nil {
+			o.requiredApprovals[path] = make(map[*regexp.Regexp]int)
+		}
+		o.requiredApprovals[path][re] = config.RequiredApprovals
+	}
 	if len(config.Labels) > 0 {
 		if o.labels[path] == nil {
 			o.labels[path] = make(map[*regexp.Regexp]sets.String)
@@ -883,6 +893,36 @@ func (o *RepoOwners) RequiredReviewers(path string) sets.String {
 	return o.entriesForFile(path, o.requiredReviewers, false).Set()
 }
 
+// RequiredApprovals returns the number of approvals required for changes to
+// the requested file. This is the highest required_approvals of the OWNERS
+// files that apply to the file (including those in parent dirs), or one if
+// none of them sets it.
+func (o *RepoOwners) RequiredApprovals(path string) int {
+	d := path
+	if !o.enableMDYAML || !strings.HasSuffix(path, ".md") {
+		d = canonicalize(d)
+	}
+
+	required := 1
+	for {
+		relative, err := filepath.Rel(d, path)
+		if err != nil {
+			o.log.WithError(err).WithField("path", path).Errorf("Unable to find relative path between %q and path.", d)
+			return required
+		}
+		for re, n := range o.requiredApprovals[d] {
+			if (re == nil || re.MatchString(relative)) && n > required {
+				required = n
+			}
+		}
+		if d == baseDirConvention || o.options[d].NoParentOwners {
+			break
+		}
+		d = canonicalize(filepath.Dir(d))
+	}
+	return required
+}
+
 func (o *RepoOwners) TopLevelApprovers() sets.String {
 	return o.entriesForFile(".", o.approvers, true).Set()
 }
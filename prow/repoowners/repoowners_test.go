@@ -1149,6 +1149,55 @@ func TestFindLabelsForPath(t *testing.T) {
 	}
 }
 
+func TestRequiredApprovals(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{
+			name:     "defaults to one",
+			path:     "d/foo.go",
+			expected: 1,
+		},
+		{
+			name:     "set in the directory",
+			path:     "a/foo.go",
+			expected: 2,
+		},
+		{
+			name:     "highest of the directory and its parents",
+			path:     "a/b/c/foo.go",
+			expected: 2,
+		},
+		{
+			name:     "filename regexp",
+			path:     "a/b/c/secret.key",
+			expected: 3,
+		},
+		{
+			name:     "parents are ignored with no_parent_owners",
+			path:     "a/b/c/d/foo.go",
+			expected: 1,
+		},
+	}
+
+	testOwners := &RepoOwners{
+		requiredApprovals: map[string]map[*regexp.Regexp]int{
+			"a":     {nil: 2},
+			leafDir: {regexp.MustCompile(`\.key$`): 3},
+		},
+		options: map[string]dirOptions{
+			"a/b/c/d": {NoParentOwners: true},
+		},
+	}
+	for _, test := range tests {
+		if got := testOwners.RequiredApprovals(test.path); got != test.expected {
+			t.Errorf("[%s] Expected %d required approvals for path %q, but got %d.", test.name, test.expected, test.path, got)
+		}
+	}
+}
+
 func TestCanonicalize(t *testing.T) {
 	tests := []struct {
 		name         string
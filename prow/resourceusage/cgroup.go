@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourceusage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCgroupRoot is where the cgroup filesystem of a container is mounted.
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// Sampler reads the cumulative CPU time and the current working set
+// of a container.
+type Sampler interface {
+	Sample() (cpu time.Duration, memory int64, err error)
+}
+
+// CgroupSampler reads the usage of the cgroup mounted at Root, which
+// is the cgroup of the container the process runs in. Both cgroup v1
+// and the unified cgroup v2 hierarchy are supported.
+type CgroupSampler struct {
+	Root string
+}
+
+// Sample implements Sampler. The working set is the memory usage
+// minus inactive file pages, matching what the kubelet reports.
+func (s CgroupSampler) Sample() (time.Duration, int64, error) {
+	if _, err := os.Stat(filepath.Join(s.Root, "cgroup.controllers")); err == nil {
+		return s.sampleV2()
+	}
+	return s.sampleV1()
+}
+
+func (s CgroupSampler) sampleV1() (time.Duration, int64, error) {
+	usage, err := readInt(filepath.Join(s.Root, "cpuacct", "cpuacct.usage"))
+	if os.IsNotExist(err) {
+		usage, err = readInt(filepath.Join(s.Root, "cpu,cpuacct", "cpuacct.usage"))
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cpu usage: %w", err)
+	}
+	memory, err := readInt(filepath.Join(s.Root, "memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	inactive, err := readStat(filepath.Join(s.Root, "memory", "memory.stat"), "total_inactive_file")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory stats: %w", err)
+	}
+	return time.Duration(usage), workingSet(memory, inactive), nil
+}
+
+func (s CgroupSampler) sampleV2() (time.Duration, int64, error) {
+	usage, err := readStat(filepath.Join(s.Root, "cpu.stat"), "usage_usec")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cpu usage: %w", err)
+	}
+	memory, err := readInt(filepath.Join(s.Root, "memory.current"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	inactive, err := readStat(filepath.Join(s.Root, "memory.stat"), "inactive_file")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory stats: %w", err)
+	}
+	return time.Duration(usage) * time.Microsecond, workingSet(memory, inactive), nil
+}
+
+func workingSet(usage, inactive int64) int64 {
+	if inactive > usage {
+		return 0
+	}
+	return usage - inactive
+}
+
+func readInt(path string) (int64, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// readStat reads a single value from a file of "<key> <value>" lines.
+func readStat(path, key string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s has no %s", path, key)
+}
+
+// Record samples the usage every interval until ctx is done and
+// returns the samples. The CPU usage of a sample is averaged over
+// the interval before it, so the first reading only sets a baseline.
+func Record(ctx context.Context, sampler Sampler, interval time.Duration) []Sample {
+	var samples []Sample
+	lastCPU, _, err := sampler.Sample()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to sample resource usage, not recording it.")
+		return nil
+	}
+	last := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return samples
+		case now := <-ticker.C:
+			cpu, memory, err := sampler.Sample()
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to sample resource usage.")
+				continue
+			}
+			samples = append(samples, Sample{
+				Time:   now,
+				CPU:    (cpu - lastCPU).Seconds() / now.Sub(last).Seconds(),
+				Memory: memory,
+			})
+			lastCPU, last = cpu, now
+		}
+	}
+}
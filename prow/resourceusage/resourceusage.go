@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resourceusage records and summarizes the CPU and memory
+// used by the test containers of decorated jobs.
+package resourceusage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+)
+
+// FileName is the name of the artifact holding the profile of a test
+// container. Jobs with several test containers upload one profile per
+// container, prefixed with its name.
+const FileName = "resource-usage.json"
+
+// ArtifactName returns the name the profile of the named container
+// is uploaded under.
+func ArtifactName(container string, multipleContainers bool) string {
+	if !multipleContainers {
+		return FileName
+	}
+	return fmt.Sprintf("%s-%s", container, FileName)
+}
+
+// Quantities is an amount of CPU and memory.
+type Quantities struct {
+	// CPU is in cores.
+	CPU float64 `json:"cpu,omitempty"`
+	// Memory is in bytes.
+	Memory int64 `json:"memory,omitempty"`
+}
+
+// QuantitiesFor converts a resource list into Quantities,
+// ignoring resources other than CPU and memory.
+func QuantitiesFor(list coreapi.ResourceList) Quantities {
+	var q Quantities
+	if cpu, ok := list[coreapi.ResourceCPU]; ok {
+		q.CPU = float64(cpu.MilliValue()) / 1000
+	}
+	if memory, ok := list[coreapi.ResourceMemory]; ok {
+		q.Memory = memory.Value()
+	}
+	return q
+}
+
+// Resources are the requests and limits of a container.
+type Resources struct {
+	Requests Quantities `json:"requests,omitempty"`
+	Limits   Quantities `json:"limits,omitempty"`
+}
+
+// ResourcesFor converts the resource requirements of a container.
+func ResourcesFor(requirements coreapi.ResourceRequirements) Resources {
+	return Resources{
+		Requests: QuantitiesFor(requirements.Requests),
+		Limits:   QuantitiesFor(requirements.Limits),
+	}
+}
+
+// Sample is the usage of a container at a point in time.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// CPU is the average number of cores used since the previous sample.
+	CPU float64 `json:"cpu"`
+	// Memory is the working set of the container in bytes.
+	Memory int64 `json:"memory"`
+}
+
+// Profile is the usage of a test container over the course of a run.
+type Profile struct {
+	Container string `json:"container,omitempty"`
+	Resources
+	Samples []Sample `json:"samples"`
+}
+
+// Percentile returns the pth percentile of the CPU and of the memory
+// samples of the profile.
+func (p Profile) Percentile(pct float64) Quantities {
+	cpu := make([]float64, 0, len(p.Samples))
+	memory := make([]float64, 0, len(p.Samples))
+	for _, sample := range p.Samples {
+		cpu = append(cpu, sample.CPU)
+		memory = append(memory, float64(sample.Memory))
+	}
+	return Quantities{
+		CPU:    Percentile(cpu, pct),
+		Memory: int64(Percentile(memory, pct)),
+	}
+}
+
+// Peak returns the highest CPU and memory usage of the profile.
+func (p Profile) Peak() Quantities {
+	return p.Percentile(100)
+}
+
+// Percentile returns the pth percentile of values using the nearest-rank
+// method. It returns zero if there are no values.
+func Percentile(values []float64, pct float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(pct / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
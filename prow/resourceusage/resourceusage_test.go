@@ -0,0 +1,205 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourceusage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPercentile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		values   []float64
+		pct      float64
+		expected float64
+	}{
+		{
+			name: "no values",
+			pct:  95,
+		},
+		{
+			name:     "single value",
+			values:   []float64{3},
+			pct:      95,
+			expected: 3,
+		},
+		{
+			name:     "p95 of twenty values is the nineteenth",
+			values:   []float64{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			pct:      95,
+			expected: 19,
+		},
+		{
+			name:     "p100 is the maximum",
+			values:   []float64{1, 5, 2},
+			pct:      100,
+			expected: 5,
+		},
+		{
+			name:     "p0 is the minimum",
+			values:   []float64{4, 5, 2},
+			pct:      0,
+			expected: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := Percentile(tc.values, tc.pct); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResourcesFor(t *testing.T) {
+	actual := ResourcesFor(coreapi.ResourceRequirements{
+		Requests: coreapi.ResourceList{
+			coreapi.ResourceCPU:    resource.MustParse("500m"),
+			coreapi.ResourceMemory: resource.MustParse("1Gi"),
+		},
+		Limits: coreapi.ResourceList{
+			coreapi.ResourceCPU: resource.MustParse("2"),
+		},
+	})
+	expected := Resources{
+		Requests: Quantities{CPU: 0.5, Memory: 1 << 30},
+		Limits:   Quantities{CPU: 2},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("resources differ from expected: %s", diff)
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestCgroupSampler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		files          map[string]string
+		expectedCPU    time.Duration
+		expectedMemory int64
+		expectedErr    bool
+	}{
+		{
+			name: "cgroup v1",
+			files: map[string]string{
+				"cpuacct/cpuacct.usage":        "1500000000\n",
+				"memory/memory.usage_in_bytes": "4096\n",
+				"memory/memory.stat":           "cache 100\ntotal_inactive_file 1024\n",
+			},
+			expectedCPU:    1500 * time.Millisecond,
+			expectedMemory: 3072,
+		},
+		{
+			name: "cgroup v1 with combined cpu controllers",
+			files: map[string]string{
+				"cpu,cpuacct/cpuacct.usage":    "2000000000\n",
+				"memory/memory.usage_in_bytes": "4096\n",
+				"memory/memory.stat":           "total_inactive_file 0\n",
+			},
+			expectedCPU:    2 * time.Second,
+			expectedMemory: 4096,
+		},
+		{
+			name: "cgroup v2",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory\n",
+				"cpu.stat":           "usage_usec 250000\nuser_usec 200000\n",
+				"memory.current":     "8192\n",
+				"memory.stat":        "anon 4096\ninactive_file 2048\n",
+			},
+			expectedCPU:    250 * time.Millisecond,
+			expectedMemory: 6144,
+		},
+		{
+			name: "missing memory stats",
+			files: map[string]string{
+				"cpuacct/cpuacct.usage":        "1\n",
+				"memory/memory.usage_in_bytes": "4096\n",
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "cgroup")
+			if err != nil {
+				t.Fatalf("failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(root)
+			writeFiles(t, root, tc.files)
+
+			cpu, memory, err := CgroupSampler{Root: root}.Sample()
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if cpu != tc.expectedCPU {
+				t.Errorf("expected cpu %s, got %s", tc.expectedCPU, cpu)
+			}
+			if memory != tc.expectedMemory {
+				t.Errorf("expected memory %d, got %d", tc.expectedMemory, memory)
+			}
+		})
+	}
+}
+
+// fakeSampler uses one second of CPU time per sample.
+type fakeSampler struct {
+	cpu time.Duration
+}
+
+func (f *fakeSampler) Sample() (time.Duration, int64, error) {
+	f.cpu += time.Second
+	return f.cpu, 1024, nil
+}
+
+func TestRecord(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	samples := Record(ctx, &fakeSampler{}, 10*time.Millisecond)
+	if len(samples) == 0 {
+		t.Fatal("expected samples to be recorded")
+	}
+	for _, sample := range samples {
+		if sample.Memory != 1024 {
+			t.Errorf("expected memory of 1024, got %d", sample.Memory)
+		}
+		// a second of CPU time every ~10ms is ~100 cores
+		if sample.CPU < 10 {
+			t.Errorf("expected a high CPU usage, got %v", sample.CPU)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package retention deletes the artifacts of old runs according to the
+// artifact retention policies and records which runs expired or are pinned.
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+)
+
+const (
+	// ExpiredFile is written to the directory of a run once its artifacts
+	// have been deleted.
+	ExpiredFile = "artifacts-expired.json"
+	// PinFile is written to the directory of a run to keep its artifacts
+	// until it is deleted again.
+	PinFile = "pinned.json"
+)
+
+// Expired is the content of the ExpiredFile.
+type Expired struct {
+	// Time is when the artifacts were deleted.
+	Time time.Time `json:"time"`
+	// Started is when the run started, as started.json may be gone.
+	Started time.Time `json:"started"`
+	// MaxAge is the age after which the artifacts of the run were deleted.
+	MaxAge string `json:"max_age"`
+	// Kept lists the artifacts that were not deleted.
+	Kept []string `json:"kept,omitempty"`
+}
+
+// Pin is the content of the PinFile.
+type Pin struct {
+	// User is who pinned the run.
+	User string `json:"user"`
+	// Reason optionally explains why the run is pinned.
+	Reason string `json:"reason,omitempty"`
+	// Time is when the run was pinned.
+	Time time.Time `json:"time"`
+}
+
+// Result describes what Clean did to a run.
+type Result string
+
+const (
+	// Retained runs are younger than the max age or have not started yet.
+	Retained Result = "retained"
+	// Pinned runs are kept until they are unpinned.
+	Pinned Result = "pinned"
+	// AlreadyExpired runs had their artifacts deleted before.
+	AlreadyExpired Result = "already-expired"
+	// Deleted runs had their artifacts deleted now.
+	Deleted Result = "deleted"
+)
+
+// Clean deletes the artifacts of the run in dir if it started longer than
+// the max age of the policy ago and it is not pinned. The artifacts that the
+// policy keeps are left alone, and an ExpiredFile takes the place of the
+// others. Nothing is deleted in dry run mode.
+func Clean(ctx context.Context, bucket jobhistory.Bucket, dir string, policy *config.RetentionPolicy, now time.Time, dryRun bool) (Result, error) {
+	dir = strings.TrimSuffix(dir, "/")
+	if _, err := ReadExpired(ctx, bucket, dir); err == nil {
+		return AlreadyExpired, nil
+	} else if !pkgio.IsNotExist(err) {
+		return "", err
+	}
+	if _, err := ReadPin(ctx, bucket, dir); err == nil {
+		return Pinned, nil
+	} else if !pkgio.IsNotExist(err) {
+		return "", err
+	}
+
+	var started gcs.Started
+	if err := readJSON(ctx, bucket, path.Join(dir, prowapi.StartedStatusFile), &started); err != nil {
+		if pkgio.IsNotExist(err) {
+			return Retained, nil
+		}
+		return "", err
+	}
+	startTime := time.Unix(started.Timestamp, 0)
+	if now.Sub(startTime) < policy.MaxAge.Duration {
+		return Retained, nil
+	}
+
+	keys, err := bucket.ListAll(ctx, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	expired := Expired{
+		Time:    now,
+		Started: startTime,
+		MaxAge:  policy.MaxAge.Duration.String(),
+	}
+	var toDelete []string
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, dir+"/")
+		if policy.Keeps(name) {
+			expired.Kept = append(expired.Kept, name)
+		} else {
+			toDelete = append(toDelete, key)
+		}
+	}
+	sort.Strings(expired.Kept)
+	if dryRun {
+		return Deleted, nil
+	}
+	for _, key := range toDelete {
+		if err := bucket.DeleteObject(ctx, key); err != nil && !pkgio.IsNotExist(err) {
+			return "", err
+		}
+	}
+	// The marker is written last, so that runs that were only partially
+	// deleted are picked up again.
+	return Deleted, writeJSON(ctx, bucket, path.Join(dir, ExpiredFile), expired)
+}
+
+// ReadExpired reads the ExpiredFile of the run in dir. The error satisfies
+// io.IsNotExist if the artifacts of the run have not expired.
+func ReadExpired(ctx context.Context, bucket jobhistory.Bucket, dir string) (*Expired, error) {
+	var expired Expired
+	if err := readJSON(ctx, bucket, path.Join(dir, ExpiredFile), &expired); err != nil {
+		return nil, err
+	}
+	return &expired, nil
+}
+
+// ReadPin reads the PinFile of the run in dir. The error satisfies
+// io.IsNotExist if the run is not pinned.
+func ReadPin(ctx context.Context, bucket jobhistory.Bucket, dir string) (*Pin, error) {
+	var pin Pin
+	if err := readJSON(ctx, bucket, path.Join(dir, PinFile), &pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// WritePin pins the run in dir.
+func WritePin(ctx context.Context, bucket jobhistory.Bucket, dir string, pin Pin) error {
+	return writeJSON(ctx, bucket, path.Join(dir, PinFile), pin)
+}
+
+// DeletePin unpins the run in dir. Unpinning a run that is not pinned is
+// not an error.
+func DeletePin(ctx context.Context, bucket jobhistory.Bucket, dir string) error {
+	if err := bucket.DeleteObject(ctx, path.Join(dir, PinFile)); err != nil && !pkgio.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func readJSON(ctx context.Context, bucket jobhistory.Bucket, key string, v interface{}) error {
+	raw, err := bucket.ReadObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return nil
+}
+
+func writeJSON(ctx context.Context, bucket jobhistory.Bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	return bucket.WriteObject(ctx, key, raw)
+}
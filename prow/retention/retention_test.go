@@ -0,0 +1,263 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/jobhistory"
+)
+
+const prefix = "gs://bucket/"
+
+// fakeOpener keeps the objects of a single bucket in memory.
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string]string
+}
+
+func (fo *fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	content, ok := fo.objects[strings.TrimPrefix(p, prefix)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo *fakeOpener) Writer(_ context.Context, p string, _ ...pkgio.WriterOptions) (pkgio.WriteCloser, error) {
+	return &fakeWriter{key: strings.TrimPrefix(p, prefix), objects: fo.objects}, nil
+}
+
+func (fo *fakeOpener) Delete(_ context.Context, p string) error {
+	key := strings.TrimPrefix(p, prefix)
+	if _, ok := fo.objects[key]; !ok {
+		return os.ErrNotExist
+	}
+	delete(fo.objects, key)
+	return nil
+}
+
+func (fo *fakeOpener) Iterator(_ context.Context, p, _ string) (pkgio.ObjectIterator, error) {
+	var attrs []pkgio.ObjectAttributes
+	for key := range fo.objects {
+		if strings.HasPrefix(key, strings.TrimPrefix(p, prefix)) {
+			attrs = append(attrs, pkgio.ObjectAttributes{Name: key})
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeWriter struct {
+	bytes.Buffer
+	key     string
+	objects map[string]string
+}
+
+func (fw *fakeWriter) Close() error {
+	fw.objects[fw.key] = fw.String()
+	return nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+func TestClean(t *testing.T) {
+	now := time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)
+	old := fmt.Sprintf(`{"timestamp":%d}`, now.Add(-31*24*time.Hour).Unix())
+	recent := fmt.Sprintf(`{"timestamp":%d}`, now.Add(-time.Hour).Unix())
+	dir := "pr-logs/pull/org_repo/1/pull-unit/42"
+	others := map[string]string{
+		"pr-logs/directory/pull-unit/42.txt":  "gs://bucket/" + dir,
+		"pr-logs/pull/org_repo/1/pull-unit/4": "{}",
+	}
+
+	testCases := []struct {
+		name            string
+		objects         map[string]string
+		dryRun          bool
+		expectedResult  Result
+		expectedObjects sets.String
+		expectedKept    []string
+	}{
+		{
+			name: "old run is deleted except for the kept files",
+			objects: map[string]string{
+				dir + "/started.json":           old,
+				dir + "/finished.json":          "{}",
+				dir + "/build-log.txt":          "log",
+				dir + "/artifacts/junit_01.xml": "<testsuites/>",
+			},
+			expectedResult:  Deleted,
+			expectedObjects: sets.NewString(dir+"/finished.json", dir+"/"+ExpiredFile),
+			expectedKept:    []string{"finished.json"},
+		},
+		{
+			name: "dry run deletes nothing",
+			objects: map[string]string{
+				dir + "/started.json":  old,
+				dir + "/build-log.txt": "log",
+			},
+			dryRun:          true,
+			expectedResult:  Deleted,
+			expectedObjects: sets.NewString(dir+"/started.json", dir+"/build-log.txt"),
+		},
+		{
+			name: "recent run is retained",
+			objects: map[string]string{
+				dir + "/started.json":  recent,
+				dir + "/build-log.txt": "log",
+			},
+			expectedResult:  Retained,
+			expectedObjects: sets.NewString(dir+"/started.json", dir+"/build-log.txt"),
+		},
+		{
+			name: "pinned run is retained",
+			objects: map[string]string{
+				dir + "/started.json":  old,
+				dir + "/build-log.txt": "log",
+				dir + "/" + PinFile:    `{"user":"investigator"}`,
+			},
+			expectedResult:  Pinned,
+			expectedObjects: sets.NewString(dir+"/started.json", dir+"/build-log.txt", dir+"/"+PinFile),
+		},
+		{
+			name: "run without started.json is retained",
+			objects: map[string]string{
+				dir + "/build-log.txt": "log",
+			},
+			expectedResult:  Retained,
+			expectedObjects: sets.NewString(dir + "/build-log.txt"),
+		},
+		{
+			name: "expired run is left alone",
+			objects: map[string]string{
+				dir + "/finished.json":  "{}",
+				dir + "/" + ExpiredFile: "{}",
+			},
+			expectedResult:  AlreadyExpired,
+			expectedObjects: sets.NewString(dir+"/finished.json", dir+"/"+ExpiredFile),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ar := config.ArtifactRetention{}
+			if err := loadPolicy(&ar); err != nil {
+				t.Fatalf("failed to load policy: %v", err)
+			}
+			for key, content := range others {
+				tc.objects[key] = content
+			}
+			opener := &fakeOpener{objects: tc.objects}
+			bucket := jobhistory.NewBucket("bucket", "gs", opener)
+
+			result, err := Clean(context.Background(), bucket, dir+"/", &ar.Policies[0], now, tc.dryRun)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expectedResult {
+				t.Errorf("expected result %q, got %q", tc.expectedResult, result)
+			}
+			for key := range others {
+				tc.expectedObjects.Insert(key)
+			}
+			actualObjects := sets.StringKeySet(opener.objects)
+			if diff := cmp.Diff(tc.expectedObjects.List(), actualObjects.List()); diff != "" {
+				t.Errorf("objects differ from expected (-want +got):\n%s", diff)
+			}
+			if tc.expectedResult != Deleted || tc.dryRun {
+				return
+			}
+			expired, err := ReadExpired(context.Background(), bucket, dir)
+			if err != nil {
+				t.Fatalf("failed to read expired marker: %v", err)
+			}
+			expected := Expired{
+				Time:    now,
+				Started: now.Add(-31 * 24 * time.Hour).Truncate(time.Second),
+				MaxAge:  "720h0m0s",
+				Kept:    tc.expectedKept,
+			}
+			if diff := cmp.Diff(expected, *expired, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+				t.Errorf("expired marker differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// loadPolicy loads a policy keeping presubmit artifacts for 30 days and
+// finished.json forever, the way it is loaded from the config.
+func loadPolicy(ar *config.ArtifactRetention) error {
+	raw := `{"policies":[{"job_types":["presubmit"],"max_age":"720h","keep_files":["^finished\\.json$"]}]}`
+	if err := json.Unmarshal([]byte(raw), ar); err != nil {
+		return err
+	}
+	return ar.DefaultAndValidate()
+}
+
+func TestPins(t *testing.T) {
+	opener := &fakeOpener{objects: map[string]string{}}
+	bucket := jobhistory.NewBucket("bucket", "gs", opener)
+	dir := "logs/ci-job/1"
+	ctx := context.Background()
+
+	if _, err := ReadPin(ctx, bucket, dir); !pkgio.IsNotExist(err) {
+		t.Fatalf("expected a not exist error for an unpinned run, got %v", err)
+	}
+	pin := Pin{User: "investigator", Reason: "flake", Time: time.Unix(100, 0).UTC()}
+	if err := WritePin(ctx, bucket, dir, pin); err != nil {
+		t.Fatalf("failed to pin: %v", err)
+	}
+	actual, err := ReadPin(ctx, bucket, dir)
+	if err != nil {
+		t.Fatalf("failed to read pin: %v", err)
+	}
+	if diff := cmp.Diff(pin, *actual); diff != "" {
+		t.Errorf("pin differs from expected (-want +got):\n%s", diff)
+	}
+	if err := DeletePin(ctx, bucket, dir); err != nil {
+		t.Fatalf("failed to unpin: %v", err)
+	}
+	if err := DeletePin(ctx, bucket, dir); err != nil {
+		t.Errorf("unpinning an unpinned run should not fail, got %v", err)
+	}
+}
@@ -75,6 +75,15 @@ type Options struct {
 	// longer than the `entrypoint` grace period for the test process and the time
 	// taken by `sidecar` to upload all relevant artifacts.
 	IgnoreInterrupts bool `json:"ignore_interrupts,omitempty"`
+
+	// PodAnnotationsFile is where the downward API projects the annotations
+	// of the pod. When set, finished.json records whether plank aborted the
+	// job or it timed out, and why.
+	PodAnnotationsFile string `json:"pod_annotations_file,omitempty"`
+	// UploadCompleteFile is where sidecar writes once it uploaded all
+	// artifacts. It is the termination message path of the container, so
+	// that plank knows when it can delete the pod of an aborted job.
+	UploadCompleteFile string `json:"upload_complete_file,omitempty"`
 }
 
 func (o Options) entries() []wrapper.Options {
@@ -36,13 +36,14 @@ import (
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
+	"k8s.io/test-infra/prow/resourceusage"
 )
 
 func nameEntry(idx int, opt wrapper.Options) string {
 	return fmt.Sprintf("entry %d: %s", idx, strings.Join(opt.Args, " "))
 }
 
-func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, int) {
+func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, bool, int) {
 
 	var paths []string
 
@@ -53,18 +54,19 @@ func wait(ctx context.Context, entries []wrapper.Options) (bool, bool, int) {
 	results := wrapper.WaitForMarkers(ctx, paths...)
 
 	passed := true
-	var aborted bool
+	var aborted, timedOut bool
 	var failures int
 
 	for _, res := range results {
 		passed = passed && res.Err == nil && res.ReturnCode == 0
 		aborted = aborted || res.ReturnCode == entrypoint.AbortedErrorCode
+		timedOut = timedOut || res.ReturnCode == entrypoint.TimedOutErrorCode
 		if res.ReturnCode != 0 && res.ReturnCode != entrypoint.PreviousErrorCode {
 			failures++
 		}
 	}
 
-	return passed, aborted, failures
+	return passed, aborted, timedOut, failures
 
 }
 
@@ -105,7 +107,7 @@ func (o Options) Run(ctx context.Context) (int, error) {
 		logrus.Warnf("Using deprecated wrapper_options instead of entries. Please update prow/pod-utils/decorate before June 2019")
 	}
 	entries := o.entries()
-	passed, aborted, failures := wait(ctx, entries)
+	passed, aborted, timedOut, failures := wait(ctx, entries)
 
 	cancel()
 	// If we are being asked to terminate by the kubelet but we have
@@ -116,11 +118,86 @@ func (o Options) Run(ctx context.Context) (int, error) {
 	signal.Ignore(os.Interrupt, syscall.SIGTERM)
 
 	buildLogs := logReaders(entries)
+	for name, reader := range resourceUsageReaders(entries) {
+		buildLogs[name] = reader
+	}
 	metadata := combineMetadata(entries)
-	return failures, o.doUpload(spec, passed, aborted, metadata, buildLogs)
+	if steps := stepResults(entries); len(steps) > 0 {
+		metadata[wrapper.StepsMetadataKey] = steps
+	}
+	abort := o.abortRequest()
+	if reason := abortReason(passed, timedOut, abort); reason != "" {
+		metadata[abortReasonKey] = reason
+	}
+	if err := o.doUpload(spec, finishedResult(passed, aborted, timedOut, abort), passed, metadata, buildLogs); err != nil {
+		return failures, err
+	}
+	o.markUploadComplete()
+	return failures, nil
+}
+
+const (
+	errorKey = "sidecar-errors"
+	// abortReasonKey records in finished.json why the job was aborted
+	// or timed out.
+	abortReasonKey = "abort-reason"
+	// timeoutReason is the abort reason of jobs whose test process
+	// entrypoint stopped at its timeout.
+	timeoutReason = "the test process did not finish before its timeout"
+)
+
+// abortRequest returns how plank asked the pod to abort, if it did.
+func (o Options) abortRequest() *downwardapi.AbortRequest {
+	if o.PodAnnotationsFile == "" {
+		return nil
+	}
+	abort, err := downwardapi.ReadAbortRequest(o.PodAnnotationsFile)
+	if err != nil {
+		logrus.WithError(err).Warn("Could not read the pod annotations")
+	}
+	return abort
 }
 
-const errorKey = "sidecar-errors"
+// markUploadComplete tells plank that the pod can be deleted.
+func (o Options) markUploadComplete() {
+	if o.UploadCompleteFile == "" {
+		return
+	}
+	if err := ioutil.WriteFile(o.UploadCompleteFile, []byte(downwardapi.UploadCompleteMessage), 0644); err != nil {
+		logrus.WithError(err).Warn("Could not mark the upload as complete")
+	}
+}
+
+// finishedResult is the result recorded in finished.json.
+func finishedResult(passed, aborted, timedOut bool, abort *downwardapi.AbortRequest) string {
+	switch {
+	case passed:
+		return "SUCCESS"
+	case abort != nil && abort.Result == downwardapi.AbortResultTimedOut:
+		return "TIMED_OUT"
+	case aborted || abort != nil:
+		return "ABORTED"
+	case timedOut:
+		return "TIMED_OUT"
+	default:
+		return "FAILURE"
+	}
+}
+
+// abortReason is the reason recorded in finished.json for jobs that
+// did not pass because plank aborted them or entrypoint timed out.
+func abortReason(passed, timedOut bool, abort *downwardapi.AbortRequest) string {
+	switch {
+	case passed:
+		return ""
+	case abort != nil:
+		return abort.Reason
+	case timedOut:
+		return timeoutReason
+	default:
+		return ""
+	}
+}
 
 func logReaders(entries []wrapper.Options) map[string]io.Reader {
 	readers := make(map[string]io.Reader)
@@ -140,6 +217,24 @@ func logReaders(entries []wrapper.Options) map[string]io.Reader {
 	return readers
 }
 
+// resourceUsageReaders opens the resource usage profiles written by
+// entrypoint. Entries that were not profiled are skipped.
+func resourceUsageReaders(entries []wrapper.Options) map[string]io.Reader {
+	readers := make(map[string]io.Reader)
+	for _, opt := range entries {
+		if opt.ResourceUsageFile == "" {
+			continue
+		}
+		profile, err := os.Open(opt.ResourceUsageFile)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to open %s", opt.ResourceUsageFile)
+			continue
+		}
+		readers[resourceusage.ArtifactName(opt.ContainerName, len(entries) > 1)] = profile
+	}
+	return readers
+}
+
 func combineMetadata(entries []wrapper.Options) map[string]interface{} {
 	errors := map[string]error{}
 	metadata := map[string]interface{}{}
@@ -177,23 +272,36 @@ func combineMetadata(entries []wrapper.Options) map[string]interface{} {
 	return metadata
 }
 
-func (o Options) doUpload(spec *downwardapi.JobSpec, passed, aborted bool, metadata map[string]interface{}, logReaders map[string]io.Reader) error {
+// stepResults reads the results of the steps that entrypoint ran for
+// multi-step jobs.
+func stepResults(entries []wrapper.Options) []wrapper.StepResult {
+	var results []wrapper.StepResult
+	for _, opt := range entries {
+		if opt.StepsFile == "" {
+			continue
+		}
+		raw, err := ioutil.ReadFile(opt.StepsFile)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to read %s", opt.StepsFile)
+			continue
+		}
+		var steps []wrapper.StepResult
+		if err := json.Unmarshal(raw, &steps); err != nil {
+			logrus.WithError(err).Warnf("Failed to unmarshal %s", opt.StepsFile)
+			continue
+		}
+		results = append(results, steps...)
+	}
+	return results
+}
+
+func (o Options) doUpload(spec *downwardapi.JobSpec, result string, passed bool, metadata map[string]interface{}, logReaders map[string]io.Reader) error {
 	uploadTargets := make(map[string]gcs.UploadFunc)
 
 	for logName, reader := range logReaders {
 		uploadTargets[logName] = gcs.DataUpload(reader)
 	}
 
-	var result string
-	switch {
-	case passed:
-		result = "SUCCESS"
-	case aborted:
-		result = "ABORTED"
-	default:
-		result = "FAILURE"
-	}
-
 	now := time.Now().Unix()
 	finished := gcs.Finished{
 		Timestamp: &now,
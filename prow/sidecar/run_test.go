@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"k8s.io/test-infra/prow/entrypoint"
+	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/wrapper"
 
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -41,6 +42,7 @@ var re = regexp.MustCompile(`(?m)(Failed to open) .*log\.txt: .*$`)
 func TestWait(t *testing.T) {
 	aborted := strconv.Itoa(entrypoint.AbortedErrorCode)
 	skip := strconv.Itoa(entrypoint.PreviousErrorCode)
+	timedOut := strconv.Itoa(entrypoint.TimedOutErrorCode)
 	const (
 		pass = "0"
 		fail = "1"
@@ -49,6 +51,7 @@ func TestWait(t *testing.T) {
 		name         string
 		markers      []string
 		abort        bool
+		timedOut     bool
 		pass         bool
 		accessDenied bool
 		missing      bool
@@ -86,6 +89,12 @@ func TestWait(t *testing.T) {
 			abort:    true,
 			failures: 2,
 		},
+		{
+			name:     "time out and fail when 1 item times out",
+			markers:  []string{pass, timedOut},
+			timedOut: true,
+			failures: 1,
+		},
 		{
 			name:     "fail when marker cannot be read",
 			markers:  []string{pass, "not-an-exit-code", pass},
@@ -135,7 +144,7 @@ func TestWait(t *testing.T) {
 				go cancel()
 			}
 
-			pass, abort, failures := wait(ctx, entries)
+			pass, abort, timedOut, failures := wait(ctx, entries)
 			cancel()
 			if pass != tc.pass {
 				t.Errorf("expected pass %t != actual %t", tc.pass, pass)
@@ -143,6 +152,9 @@ func TestWait(t *testing.T) {
 			if abort != tc.abort {
 				t.Errorf("expected abort %t != actual %t", tc.abort, abort)
 			}
+			if timedOut != tc.timedOut {
+				t.Errorf("expected timed out %t != actual %t", tc.timedOut, timedOut)
+			}
 			if failures != tc.failures {
 				t.Errorf("expected failures %d != actual %d", tc.failures, failures)
 			}
@@ -250,7 +262,7 @@ func TestWaitParallelContainers(t *testing.T) {
 			waitResultsCh := make(chan WaitResult)
 
 			go func() {
-				pass, abort, failures := wait(ctx, entries)
+				pass, abort, _, failures := wait(ctx, entries)
 				waitResultsCh <- WaitResult{pass, abort, failures}
 			}()
 
@@ -530,3 +542,182 @@ func TestLogReaders(t *testing.T) {
 	}
 
 }
+
+func TestResourceUsageReaders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "resource-usage")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ioutil.WriteFile(path.Join(tmpDir, "test1-resource-usage.json"), []byte("profile"), 0600); err != nil {
+		t.Fatalf("could not create profile: %v", err)
+	}
+	entries := []wrapper.Options{
+		{ContainerName: "test1", ResourceUsageFile: path.Join(tmpDir, "test1-resource-usage.json")},
+		// profiling failed
+		{ContainerName: "test2", ResourceUsageFile: path.Join(tmpDir, "test2-resource-usage.json")},
+		// profiling disabled
+		{ContainerName: "test3"},
+	}
+
+	actual := map[string]string{}
+	for name, reader := range resourceUsageReaders(entries) {
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to read all: %v", err)
+		}
+		actual[name] = string(buf)
+	}
+	expected := map[string]string{"test1-resource-usage.json": "profile"}
+	if !equality.Semantic.DeepEqual(expected, actual) {
+		t.Errorf("maps do not match:\n%s", diff.ObjectReflectDiff(expected, actual))
+	}
+}
+
+func TestStepResults(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "steps")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	steps := `[{"name":"build","result":"SUCCESS","started":1615716000,"duration":90.5},{"name":"test","result":"SKIPPED"}]`
+	if err := ioutil.WriteFile(path.Join(tmpDir, "steps.json"), []byte(steps), 0600); err != nil {
+		t.Fatalf("could not create steps file: %v", err)
+	}
+	entries := []wrapper.Options{
+		{ContainerName: "test1", StepsFile: path.Join(tmpDir, "steps.json")},
+		// entrypoint did not get to write the results
+		{ContainerName: "test2", StepsFile: path.Join(tmpDir, "missing.json")},
+		// no steps
+		{ContainerName: "test3"},
+	}
+
+	expected := []wrapper.StepResult{
+		{Name: "build", Result: wrapper.StepSucceeded, Started: 1615716000, Duration: 90.5},
+		{Name: "test", Result: wrapper.StepSkipped},
+	}
+	if actual := stepResults(entries); !equality.Semantic.DeepEqual(expected, actual) {
+		t.Errorf("step results do not match:\n%s", diff.ObjectReflectDiff(expected, actual))
+	}
+}
+
+func TestFinishedResult(t *testing.T) {
+	testCases := []struct {
+		name     string
+		passed   bool
+		aborted  bool
+		timedOut bool
+		abort    *downwardapi.AbortRequest
+		expected string
+	}{
+		{
+			name:     "passed",
+			passed:   true,
+			expected: "SUCCESS",
+		},
+		{
+			name:     "passed before the abort took effect",
+			passed:   true,
+			abort:    &downwardapi.AbortRequest{Result: downwardapi.AbortResultAborted},
+			expected: "SUCCESS",
+		},
+		{
+			name:     "failed",
+			expected: "FAILURE",
+		},
+		{
+			name:     "interrupted",
+			aborted:  true,
+			expected: "ABORTED",
+		},
+		{
+			name:     "aborted by plank",
+			aborted:  true,
+			abort:    &downwardapi.AbortRequest{Result: downwardapi.AbortResultAborted},
+			expected: "ABORTED",
+		},
+		{
+			name:     "timed out in plank",
+			aborted:  true,
+			abort:    &downwardapi.AbortRequest{Result: downwardapi.AbortResultTimedOut},
+			expected: "TIMED_OUT",
+		},
+		{
+			name:     "timed out in entrypoint",
+			timedOut: true,
+			expected: "TIMED_OUT",
+		},
+		{
+			name:     "aborted by plank while a step timed out",
+			aborted:  true,
+			timedOut: true,
+			abort:    &downwardapi.AbortRequest{Result: downwardapi.AbortResultAborted},
+			expected: "ABORTED",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := finishedResult(tc.passed, tc.aborted, tc.timedOut, tc.abort); actual != tc.expected {
+				t.Errorf("expected result %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAbortReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		passed   bool
+		timedOut bool
+		abort    *downwardapi.AbortRequest
+		expected string
+	}{
+		{
+			name: "failed",
+		},
+		{
+			name:   "passed before the abort took effect",
+			passed: true,
+			abort:  &downwardapi.AbortRequest{Result: downwardapi.AbortResultAborted, Reason: "newer commit"},
+		},
+		{
+			name:     "aborted by plank",
+			abort:    &downwardapi.AbortRequest{Result: downwardapi.AbortResultAborted, Reason: "newer commit"},
+			expected: "newer commit",
+		},
+		{
+			name:     "timed out in entrypoint",
+			timedOut: true,
+			expected: timeoutReason,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := abortReason(tc.passed, tc.timedOut, tc.abort); actual != tc.expected {
+				t.Errorf("expected reason %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMarkUploadComplete(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "upload-complete")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	o := Options{UploadCompleteFile: path.Join(tmpDir, "termination-log")}
+	o.markUploadComplete()
+	raw, err := ioutil.ReadFile(o.UploadCompleteFile)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", o.UploadCompleteFile, err)
+	}
+	if string(raw) != downwardapi.UploadCompleteMessage {
+		t.Errorf("expected %q, got %q", downwardapi.UploadCompleteMessage, string(raw))
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+// Block is a Slack Block Kit layout block. Only the fields used by Prow are modelled.
+// See https://api.slack.com/reference/block-kit/blocks
+type Block struct {
+	Type     string      `json:"type"`
+	BlockID  string      `json:"block_id,omitempty"`
+	Text     *TextObject `json:"text,omitempty"`
+	Elements []Element   `json:"elements,omitempty"`
+}
+
+// TextObject is a Slack Block Kit text object.
+type TextObject struct {
+	// Type is either "plain_text" or "mrkdwn".
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Element is an interactive Slack Block Kit element. Prow only uses buttons.
+type Element struct {
+	Type     string      `json:"type"`
+	Text     *TextObject `json:"text,omitempty"`
+	ActionID string      `json:"action_id,omitempty"`
+	// URL is opened by the button when set.
+	URL   string `json:"url,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Style is either empty, "primary" or "danger".
+	Style string `json:"style,omitempty"`
+}
+
+// Action IDs of the buttons on the messages of Prow.
+const (
+	// RerunActionID is the action ID of "Rerun" buttons. Their value is the
+	// name of the ProwJob to rerun.
+	RerunActionID = "rerun"
+	// ViewLogsActionID is the action ID of "View logs" buttons.
+	ViewLogsActionID = "view-logs"
+)
+
+// SectionBlock returns a block showing the mrkdwn text.
+func SectionBlock(text string) Block {
+	return Block{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: text}}
+}
+
+// ActionsBlock returns a block holding the interactive elements.
+func ActionsBlock(elements ...Element) Block {
+	return Block{Type: "actions", Elements: elements}
+}
+
+// Button returns a button element. The button opens the URL if set,
+// otherwise clicking it sends an interaction with the action ID and value.
+func Button(text, actionID, value, url string) Element {
+	return Element{
+		Type:     "button",
+		Text:     &TextObject{Type: "plain_text", Text: text},
+		ActionID: actionID,
+		Value:    value,
+		URL:      url,
+	}
+}
+
+// Response types for responses to slash commands and interactions.
+const (
+	// ResponseTypeEphemeral responses are only shown to the user who triggered them.
+	ResponseTypeEphemeral = "ephemeral"
+	// ResponseTypeInChannel responses are shown to everyone in the channel.
+	ResponseTypeInChannel = "in_channel"
+)
+
+// Response is a message sent in response to a slash command or an interaction.
+type Response struct {
+	ResponseType    string  `json:"response_type,omitempty"`
+	Text            string  `json:"text"`
+	Blocks          []Block `json:"blocks,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+}
+
+// SlashCommand is the form Slack posts when a user invokes a slash command.
+// See https://api.slack.com/interactivity/slash-commands
+type SlashCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	ChannelID   string
+	ResponseURL string
+}
+
+// InteractionPayload is the JSON payload Slack posts when a user clicks
+// on an interactive element of a message.
+// See https://api.slack.com/reference/interaction-payloads/block-actions
+type InteractionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	ResponseURL string   `json:"response_url"`
+	Actions     []Action `json:"actions"`
+}
+
+// Action is an interaction with a single element.
+type Action struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Value    string `json:"value"`
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/slack"
+	"k8s.io/test-infra/prow/tide"
+)
+
+// maxStatusJobs is the number of runs shown by the status command.
+const maxStatusJobs = 5
+
+// status lists the latest runs of the job.
+func (s *Server) status(job string) slack.Response {
+	pjs, err := s.ProwJobClient.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list ProwJobs.")
+		return ephemeral(fmt.Sprintf("Failed to list ProwJobs: %v", err))
+	}
+	var runs []prowapi.ProwJob
+	for _, pj := range pjs.Items {
+		if pj.Spec.Job == job {
+			runs = append(runs, pj)
+		}
+	}
+	if len(runs) == 0 {
+		return ephemeral(fmt.Sprintf("There are no runs of the job `%s`.", job))
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[j].Status.StartTime.Before(&runs[i].Status.StartTime)
+	})
+	if len(runs) > maxStatusJobs {
+		runs = runs[:maxStatusJobs]
+	}
+	lines := []string{fmt.Sprintf("Latest runs of `%s`:", job)}
+	for _, pj := range runs {
+		line := fmt.Sprintf("• %s %s", pj.Status.StartTime.Format(time.RFC3339), pj.Status.State)
+		if pj.Spec.Refs != nil {
+			line += fmt.Sprintf(" on %s/%s %s", pj.Spec.Refs.Org, pj.Spec.Refs.Repo, pj.Spec.Refs.String())
+		}
+		if pj.Status.URL != "" {
+			line += fmt.Sprintf(" <%s|View logs>", pj.Status.URL)
+		}
+		lines = append(lines, line)
+	}
+	return ephemeral(strings.Join(lines, "\n"))
+}
+
+// findProwJob finds the ProwJob a user referred to. Users can refer to a job by
+// the URL of its logs, by a Deck URL with a prowjob query parameter or by name.
+func (s *Server) findProwJob(ref string) (*prowapi.ProwJob, error) {
+	// Slack formats links as <url> or <url|text>.
+	ref = strings.TrimSuffix(strings.TrimPrefix(ref, "<"), ">")
+	if i := strings.Index(ref, "|"); i >= 0 {
+		ref = ref[:i]
+	}
+	if u, err := url.Parse(ref); err == nil {
+		if name := u.Query().Get("prowjob"); name != "" {
+			return s.ProwJobClient.Get(context.TODO(), name, metav1.GetOptions{})
+		}
+	}
+	pjs, err := s.ProwJobClient.List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ProwJobs: %v", err)
+	}
+	for i := range pjs.Items {
+		if pjs.Items[i].Name == ref || (pjs.Items[i].Status.URL != "" && pjs.Items[i].Status.URL == ref) {
+			return &pjs.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no ProwJob matches %s", ref)
+}
+
+// canTrigger checks the rerun auth configs of Deck and of the job, just like
+// Deck does before it reruns a job.
+func (s *Server) canTrigger(login string, pj *prowapi.ProwJob) (bool, error) {
+	var org string
+	if pj.Spec.Refs != nil {
+		org = pj.Spec.Refs.Org
+	} else if len(pj.Spec.ExtraRefs) > 0 {
+		org = pj.Spec.ExtraRefs[0].Org
+	}
+	authConfig := s.ConfigAgent().Deck.RerunAuthConfigs.GetRerunAuthConfig(pj.Spec.Refs)
+	if allowed, err := authConfig.IsAuthorized(org, login, s.GitHubClient); err != nil || allowed {
+		return allowed, err
+	}
+	return pj.Spec.RerunAuthConfig.IsAuthorized(org, login, s.GitHubClient)
+}
+
+// authorizedProwJob resolves the reference to a ProwJob the Slack user may
+// trigger, or returns the response explaining why there is none.
+func (s *Server) authorizedProwJob(slackUserID, ref string, log *logrus.Entry) (*prowapi.ProwJob, string, *slack.Response) {
+	login, denied := s.githubUser(slackUserID)
+	if denied != nil {
+		return nil, "", denied
+	}
+	pj, err := s.findProwJob(ref)
+	if err != nil {
+		response := ephemeral(fmt.Sprintf("Could not find the job: %v", err))
+		return nil, "", &response
+	}
+	allowed, err := s.canTrigger(login, pj)
+	if err != nil {
+		log.WithError(err).WithField("prowjob", pj.Name).Error("Failed to check if user can trigger job.")
+		response := ephemeral(fmt.Sprintf("Failed to check if @%s may trigger `%s`: %v", login, pj.Spec.Job, err))
+		return nil, "", &response
+	}
+	if !allowed {
+		response := ephemeral(fmt.Sprintf("GitHub user @%s is not allowed to trigger `%s`.", login, pj.Spec.Job))
+		return nil, "", &response
+	}
+	return pj, login, nil
+}
+
+// rerun creates a new run of the referenced job.
+func (s *Server) rerun(slackUserID, ref string) slack.Response {
+	log := logrus.WithFields(logrus.Fields{"slack-user": slackUserID, "command": "rerun"})
+	pj, login, denied := s.authorizedProwJob(slackUserID, ref, log)
+	if denied != nil {
+		return *denied
+	}
+	newPJ := pjutil.NewProwJob(pj.Spec, pj.ObjectMeta.Labels, pj.ObjectMeta.Annotations)
+	if _, err := s.ProwJobClient.Create(context.TODO(), &newPJ, metav1.CreateOptions{}); err != nil {
+		log.WithError(err).WithField("job", pj.Spec.Job).Error("Failed to create ProwJob.")
+		return ephemeral(fmt.Sprintf("Failed to rerun `%s`: %v", pj.Spec.Job, err))
+	}
+	log.WithFields(pjutil.ProwJobFields(&newPJ)).WithField("user", login).Info("Rerunning job.")
+	return inChannel(fmt.Sprintf("@%s reran `%s` as %s.", login, pj.Spec.Job, newPJ.Name))
+}
+
+// abort aborts the referenced job if it is still running.
+func (s *Server) abort(slackUserID, ref string) slack.Response {
+	log := logrus.WithFields(logrus.Fields{"slack-user": slackUserID, "command": "abort"})
+	pj, login, denied := s.authorizedProwJob(slackUserID, ref, log)
+	if denied != nil {
+		return *denied
+	}
+	if pj.Complete() {
+		return ephemeral(fmt.Sprintf("`%s` already finished with state %s.", pj.Spec.Job, pj.Status.State))
+	}
+	// Plank deletes the pod of aborted jobs and marks them as complete.
+	pj.Status.State = prowapi.AbortedState
+	pj.Status.Description = fmt.Sprintf("Aborted by @%s from Slack.", login)
+	if _, err := s.ProwJobClient.Update(context.TODO(), pj, metav1.UpdateOptions{}); err != nil {
+		log.WithError(err).WithField("prowjob", pj.Name).Error("Failed to abort ProwJob.")
+		return ephemeral(fmt.Sprintf("Failed to abort `%s`: %v", pj.Spec.Job, err))
+	}
+	log.WithFields(pjutil.ProwJobFields(pj)).WithField("user", login).Info("Aborted job.")
+	return inChannel(fmt.Sprintf("@%s aborted `%s` (%s).", login, pj.Spec.Job, pj.Name))
+}
+
+// tide summarizes the Tide pools of the repo.
+func (s *Server) tide(orgRepo string) slack.Response {
+	org, repo, err := splitOrgRepo(orgRepo)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	if s.TideURL == "" {
+		return ephemeral("The slack-bot was not configured with the URL of Tide.")
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(s.TideURL)
+	if err != nil {
+		return ephemeral(fmt.Sprintf("Failed to get the Tide pools: %v", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ephemeral(fmt.Sprintf("Failed to get the Tide pools: status %d", resp.StatusCode))
+	}
+	var pools []tide.Pool
+	if err := json.NewDecoder(resp.Body).Decode(&pools); err != nil {
+		return ephemeral(fmt.Sprintf("Failed to decode the Tide pools: %v", err))
+	}
+
+	lines := []string{fmt.Sprintf("Tide pools of %s/%s:", org, repo)}
+	for _, pool := range pools {
+		if pool.Org != org || pool.Repo != repo {
+			continue
+		}
+		line := fmt.Sprintf("• `%s`: %s, %d mergeable, %d pending, %d missing requirements", pool.Branch, pool.Action, len(pool.SuccessPRs), len(pool.PendingPRs), len(pool.MissingPRs))
+		if len(pool.Target) > 0 {
+			var targets []string
+			for _, pr := range pool.Target {
+				targets = append(targets, fmt.Sprintf("#%d", pr.Number))
+			}
+			line += ", targeting " + strings.Join(targets, " ")
+		}
+		for _, blocker := range pool.Blockers {
+			line += fmt.Sprintf(", blocked by <%s|#%d>", blocker.URL, blocker.Number)
+		}
+		if pool.Error != "" {
+			line += ", error: " + pool.Error
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 1 {
+		return ephemeral(fmt.Sprintf("Tide has no pools for %s/%s.", org, repo))
+	}
+	return ephemeral(strings.Join(lines, "\n"))
+}
+
+// holdTitle is the title of the blocker issues created by the hold command.
+// Tide only blocks the branch named in the title.
+func holdTitle(branch string) string {
+	return fmt.Sprintf("Merges held from Slack for branch:%s", branch)
+}
+
+// hold stops or resumes merges into the branch by opening or closing a Tide
+// merge blocker issue. Only collaborators of the repo may do so.
+func (s *Server) hold(slackUserID, orgRepo, branch string, hold bool) slack.Response {
+	log := logrus.WithFields(logrus.Fields{"slack-user": slackUserID, "command": "hold", "hold": hold})
+	login, denied := s.githubUser(slackUserID)
+	if denied != nil {
+		return *denied
+	}
+	org, repo, err := splitOrgRepo(orgRepo)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	blockerLabel := s.ConfigAgent().Tide.BlockerLabel
+	if blockerLabel == "" {
+		return ephemeral("Holding merges needs `tide.blocker_label` to be configured.")
+	}
+	if ok, err := s.GitHubClient.IsCollaborator(org, repo, login); err != nil {
+		log.WithError(err).Error("Failed to check if user is a collaborator.")
+		return ephemeral(fmt.Sprintf("Failed to check if @%s is a collaborator of %s/%s: %v", login, org, repo, err))
+	} else if !ok {
+		return ephemeral(fmt.Sprintf("Only collaborators of %s/%s may hold merges, @%s is none.", org, repo, login))
+	}
+
+	issues, err := s.GitHubClient.ListOpenIssues(org, repo)
+	if err != nil {
+		return ephemeral(fmt.Sprintf("Failed to list the issues of %s/%s: %v", org, repo, err))
+	}
+	title := holdTitle(branch)
+	var holds []int
+	for _, issue := range issues {
+		if issue.IsPullRequest() || issue.State == "closed" || issue.Title != title || !issue.HasLabel(blockerLabel) {
+			continue
+		}
+		holds = append(holds, issue.Number)
+	}
+
+	if hold {
+		if len(holds) > 0 {
+			return ephemeral(fmt.Sprintf("Merges into %s/%s:%s are already held by #%d.", org, repo, branch, holds[0]))
+		}
+		body := fmt.Sprintf("@%s held merges into `%s` from Slack. Close this issue or run `/prow unhold %s/%s %s` to let Tide merge again.", login, branch, org, repo, branch)
+		number, err := s.GitHubClient.CreateIssue(org, repo, title, body, 0, []string{blockerLabel}, nil)
+		if err != nil {
+			log.WithError(err).Error("Failed to create merge blocker issue.")
+			return ephemeral(fmt.Sprintf("Failed to create the merge blocker issue: %v", err))
+		}
+		log.WithField("user", login).Infof("Held merges into %s/%s:%s with #%d.", org, repo, branch, number)
+		linkURL := "https://github.com"
+		if u := s.ConfigAgent().GitHubOptions.LinkURL; u != nil {
+			linkURL = u.String()
+		}
+		return inChannel(fmt.Sprintf("@%s held merges into %s/%s:%s with %s/%s/%s/issues/%d.", login, org, repo, branch, linkURL, org, repo, number))
+	}
+
+	if len(holds) == 0 {
+		return ephemeral(fmt.Sprintf("Merges into %s/%s:%s are not held from Slack.", org, repo, branch))
+	}
+	for _, number := range holds {
+		if err := s.GitHubClient.CloseIssue(org, repo, number); err != nil {
+			log.WithError(err).Error("Failed to close merge blocker issue.")
+			return ephemeral(fmt.Sprintf("Failed to close the merge blocker issue #%d: %v", number, err))
+		}
+	}
+	log.WithField("user", login).Infof("Resumed merges into %s/%s:%s.", org, repo, branch)
+	return inChannel(fmt.Sprintf("@%s let Tide merge into %s/%s:%s again.", login, org, repo, branch))
+}
+
+func splitOrgRepo(orgRepo string) (string, string, error) {
+	parts := strings.Split(orgRepo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q is not of the form org/repo", orgRepo)
+	}
+	return parts[0], parts[1], nil
+}
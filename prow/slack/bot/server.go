@@ -0,0 +1,218 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bot serves Prow slash commands and the interactive elements of the
+// messages Prow posts to Slack.
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowv1 "k8s.io/test-infra/prow/client/clientset/versioned/typed/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/slack"
+)
+
+// maxBodySize bounds the size of requests Slack can send.
+const maxBodySize = 1 << 20
+
+type githubClient interface {
+	github.RerunClient
+	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
+	CloseIssue(org, repo string, number int) error
+	ListOpenIssues(org, repo string) ([]github.Issue, error)
+}
+
+type slackClient interface {
+	Respond(responseURL string, response slack.Response) error
+}
+
+// Server handles the slash commands and the interactions Slack sends to the
+// request URL of the Prow Slack app.
+type Server struct {
+	ConfigAgent   config.Getter
+	ProwJobClient prowv1.ProwJobInterface
+	GitHubClient  githubClient
+	SlackClient   slackClient
+	// TokenGenerator returns the signing secret of the Slack app.
+	TokenGenerator func() []byte
+	// TideURL is the URL at which Tide serves its pools.
+	TideURL string
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// ServeHTTP validates an incoming Slack request and dispatches it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "400 Bad Request: failed to read body", http.StatusBadRequest)
+		return
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	if err := slack.VerifyRequest(s.TokenGenerator(), r.Header, body, now()); err != nil {
+		logrus.WithError(err).Info("Rejected a request that was not signed by Slack.")
+		http.Error(w, "403 Forbidden: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "400 Bad Request: body is no form", http.StatusBadRequest)
+		return
+	}
+
+	if payload := form.Get("payload"); payload != "" {
+		var interaction slack.InteractionPayload
+		if err := json.Unmarshal([]byte(payload), &interaction); err != nil {
+			http.Error(w, "400 Bad Request: invalid interaction payload", http.StatusBadRequest)
+			return
+		}
+		s.handleInteraction(interaction)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	command := slack.SlashCommand{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		ResponseURL: form.Get("response_url"),
+	}
+	if command.Command == "" {
+		http.Error(w, "400 Bad Request: neither a slash command nor an interaction", http.StatusBadRequest)
+		return
+	}
+	response := s.handleCommand(command)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("Failed to write the response to a slash command.")
+	}
+}
+
+// handleInteraction handles clicks on the buttons of Prow messages. Slack
+// expects interactions to be acknowledged right away, so results are sent to
+// the response URL of the interaction.
+func (s *Server) handleInteraction(interaction slack.InteractionPayload) {
+	log := logrus.WithFields(logrus.Fields{"slack-user": interaction.User.ID, "type": interaction.Type})
+	for _, action := range interaction.Actions {
+		var response slack.Response
+		switch action.ActionID {
+		case slack.RerunActionID:
+			response = s.rerun(interaction.User.ID, action.Value)
+		default:
+			// Buttons which only open a URL, like "View logs", send
+			// interactions too. There is nothing to do for them.
+			continue
+		}
+		if interaction.ResponseURL == "" {
+			continue
+		}
+		if err := s.SlackClient.Respond(interaction.ResponseURL, response); err != nil {
+			log.WithError(err).WithField("action", action.ActionID).Error("Failed to respond to interaction.")
+		}
+	}
+}
+
+// handleCommand runs a slash command like "/prow status <job>".
+func (s *Server) handleCommand(command slack.SlashCommand) slack.Response {
+	logrus.WithFields(logrus.Fields{"slack-user": command.UserID, "command": command.Command, "text": command.Text}).Info("Handling slash command.")
+	args := strings.Fields(command.Text)
+	if len(args) == 0 {
+		return ephemeral(usage(command.Command))
+	}
+	switch subcommand, args := args[0], args[1:]; subcommand {
+	case "status":
+		if len(args) != 1 {
+			return ephemeral(usage(command.Command))
+		}
+		return s.status(args[0])
+	case "rerun":
+		if len(args) != 1 {
+			return ephemeral(usage(command.Command))
+		}
+		return s.rerun(command.UserID, args[0])
+	case "abort":
+		if len(args) != 1 {
+			return ephemeral(usage(command.Command))
+		}
+		return s.abort(command.UserID, args[0])
+	case "tide":
+		if len(args) != 1 {
+			return ephemeral(usage(command.Command))
+		}
+		return s.tide(args[0])
+	case "hold", "unhold":
+		if len(args) != 2 {
+			return ephemeral(usage(command.Command))
+		}
+		return s.hold(command.UserID, args[0], args[1], subcommand == "hold")
+	default:
+		return ephemeral(usage(command.Command))
+	}
+}
+
+func usage(command string) string {
+	if command == "" {
+		command = "/prow"
+	}
+	return strings.Join([]string{
+		"Usage:",
+		fmt.Sprintf("`%s status <job>` shows the latest runs of the job", command),
+		fmt.Sprintf("`%s rerun <job-url>` reruns the job", command),
+		fmt.Sprintf("`%s abort <job-url>` aborts the job if it is still running", command),
+		fmt.Sprintf("`%s tide <org/repo>` shows the Tide pools of the repo", command),
+		fmt.Sprintf("`%s hold <org/repo> <branch>` stops Tide from merging into the branch", command),
+		fmt.Sprintf("`%s unhold <org/repo> <branch>` lets Tide merge into the branch again", command),
+	}, "\n")
+}
+
+func ephemeral(text string) slack.Response {
+	return slack.Response{ResponseType: slack.ResponseTypeEphemeral, Text: text}
+}
+
+func inChannel(text string) slack.Response {
+	return slack.Response{ResponseType: slack.ResponseTypeInChannel, Text: text}
+}
+
+// githubUser returns the GitHub login of the Slack user or a response explaining
+// why the user may not run commands that change anything.
+func (s *Server) githubUser(slackUserID string) (string, *slack.Response) {
+	login, ok := s.ConfigAgent().SlackBot.GitHubUser(slackUserID)
+	if !ok {
+		response := ephemeral(fmt.Sprintf("Your Slack user %s is not mapped to a GitHub user in the `slack_bot.github_users` config, so you may only run read-only commands.", slackUserID))
+		return "", &response
+	}
+	return login, nil
+}
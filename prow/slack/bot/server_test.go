@@ -0,0 +1,363 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/slack"
+	"k8s.io/test-infra/prow/tide"
+	"k8s.io/test-infra/prow/tide/blockers"
+)
+
+var (
+	secret  = []byte("signing-secret")
+	fakeNow = time.Unix(1600000000, 0)
+)
+
+func prowJob(name, job string, state prowapi.ProwJobState, started time.Time) *prowapi.ProwJob {
+	pj := &prowapi.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prowjobs"},
+		Spec: prowapi.ProwJobSpec{
+			Type: prowapi.PeriodicJob,
+			Job:  job,
+		},
+		Status: prowapi.ProwJobStatus{
+			State:     state,
+			StartTime: metav1.NewTime(started),
+			URL:       "https://prow.example.com/view/" + name,
+		},
+	}
+	if state != prowapi.PendingState && state != prowapi.TriggeredState {
+		pj.SetComplete()
+	}
+	return pj
+}
+
+func newServer(pjs ...runtime.Object) (*Server, *fakegithub.FakeClient, *slack.FakeServer) {
+	cfg := &config.Config{ProwConfig: config.ProwConfig{
+		SlackBot: config.SlackBot{GitHubUsers: map[string]string{"U-ADMIN": "admin", "U-DEV": "dev"}},
+		Deck: config.Deck{RerunAuthConfigs: config.RerunAuthConfigs{
+			"*": prowapi.RerunAuthConfig{GitHubUsers: []string{"admin"}},
+		}},
+		Tide: config.Tide{BlockerLabel: "tide/merge-blocker"},
+	}}
+	ghc := fakegithub.NewFakeClient()
+	ghc.Collaborators = []string{"admin"}
+	slackServer := slack.NewFakeServer()
+	return &Server{
+		ConfigAgent:    func() *config.Config { return cfg },
+		ProwJobClient:  fake.NewSimpleClientset(pjs...).ProwV1().ProwJobs("prowjobs"),
+		GitHubClient:   ghc,
+		SlackClient:    slackServer.Client(),
+		TokenGenerator: func() []byte { return secret },
+		now:            func() time.Time { return fakeNow },
+	}, ghc, slackServer
+}
+
+func signedRequest(form url.Values) *http.Request {
+	body := form.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	timestamp := strconv.FormatInt(fakeNow.Unix(), 10)
+	req.Header.Set(slack.TimestampHeader, timestamp)
+	req.Header.Set(slack.SignatureHeader, slack.Sign(secret, timestamp, []byte(body)))
+	return req
+}
+
+func runCommand(t *testing.T, s *Server, user, text string) slack.Response {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, signedRequest(url.Values{"command": {"/prow"}, "text": {text}, "user_id": {user}}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response slack.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestServeHTTPRejectsUnsignedRequests(t *testing.T) {
+	s, _, slackServer := newServer()
+	defer slackServer.Close()
+
+	req := signedRequest(url.Values{"command": {"/prow"}, "text": {"status job"}})
+	req.Header.Set(slack.SignatureHeader, slack.Sign([]byte("other"), req.Header.Get(slack.TimestampHeader), []byte("command=%2Fprow")))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	pjs := []runtime.Object{
+		prowJob("old", "ci-job", prowapi.FailureState, fakeNow.Add(-2*time.Hour)),
+		prowJob("new", "ci-job", prowapi.PendingState, fakeNow.Add(-time.Hour)),
+		prowJob("other", "other-job", prowapi.SuccessState, fakeNow),
+	}
+	testCases := []struct {
+		name           string
+		user           string
+		text           string
+		expectedType   string
+		expectedText   []string
+		unexpectedText []string
+		expectedJobs   int
+		expectedState  map[string]prowapi.ProwJobState
+	}{
+		{
+			name:         "no subcommand shows usage",
+			user:         "U-ADMIN",
+			text:         "",
+			expectedType: slack.ResponseTypeEphemeral,
+			expectedText: []string{"Usage:", "`/prow status <job>`"},
+			expectedJobs: 3,
+		},
+		{
+			name:           "status lists the runs of the job, newest first",
+			user:           "U-UNMAPPED",
+			text:           "status ci-job",
+			expectedType:   slack.ResponseTypeEphemeral,
+			expectedText:   []string{"pending <https://prow.example.com/view/new|View logs>\n• " + fakeNow.Add(-2*time.Hour).UTC().Format(time.RFC3339) + " failure"},
+			unexpectedText: []string{"other"},
+			expectedJobs:   3,
+		},
+		{
+			name:         "status of unknown job",
+			user:         "U-ADMIN",
+			text:         "status unknown",
+			expectedType: slack.ResponseTypeEphemeral,
+			expectedText: []string{"There are no runs of the job `unknown`."},
+			expectedJobs: 3,
+		},
+		{
+			name:         "unmapped user cannot rerun",
+			user:         "U-UNMAPPED",
+			text:         "rerun <https://prow.example.com/view/old>",
+			expectedType: slack.ResponseTypeEphemeral,
+			expectedText: []string{"not mapped to a GitHub user"},
+			expectedJobs: 3,
+		},
+		{
+			name:         "unauthorized user cannot rerun",
+			user:         "U-DEV",
+			text:         "rerun https://prow.example.com/view/old",
+			expectedType: slack.ResponseTypeEphemeral,
+			expectedText: []string{"GitHub user @dev is not allowed to trigger `ci-job`."},
+			expectedJobs: 3,
+		},
+		{
+			name:         "authorized user reruns by log URL",
+			user:         "U-ADMIN",
+			text:         "rerun <https://prow.example.com/view/old>",
+			expectedType: slack.ResponseTypeInChannel,
+			expectedText: []string{"@admin reran `ci-job`"},
+			expectedJobs: 4,
+		},
+		{
+			name:         "authorized user reruns by Deck URL",
+			user:         "U-ADMIN",
+			text:         "rerun https://prow.example.com/rerun?prowjob=other",
+			expectedType: slack.ResponseTypeInChannel,
+			expectedText: []string{"@admin reran `other-job`"},
+			expectedJobs: 4,
+		},
+		{
+			name:         "unknown job cannot be rerun",
+			user:         "U-ADMIN",
+			text:         "rerun https://prow.example.com/view/unknown",
+			expectedType: slack.ResponseTypeEphemeral,
+			expectedText: []string{"Could not find the job"},
+			expectedJobs: 3,
+		},
+		{
+			name:          "authorized user aborts running job",
+			user:          "U-ADMIN",
+			text:          "abort new",
+			expectedType:  slack.ResponseTypeInChannel,
+			expectedText:  []string{"@admin aborted `ci-job` (new)."},
+			expectedJobs:  3,
+			expectedState: map[string]prowapi.ProwJobState{"new": prowapi.AbortedState},
+		},
+		{
+			name:          "finished job cannot be aborted",
+			user:          "U-ADMIN",
+			text:          "abort old",
+			expectedType:  slack.ResponseTypeEphemeral,
+			expectedText:  []string{"already finished"},
+			expectedJobs:  3,
+			expectedState: map[string]prowapi.ProwJobState{"old": prowapi.FailureState},
+		},
+		{
+			name:          "unauthorized user cannot abort",
+			user:          "U-DEV",
+			text:          "abort new",
+			expectedType:  slack.ResponseTypeEphemeral,
+			expectedText:  []string{"not allowed"},
+			expectedJobs:  3,
+			expectedState: map[string]prowapi.ProwJobState{"new": prowapi.PendingState},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, slackServer := newServer(pjs...)
+			defer slackServer.Close()
+			response := runCommand(t, s, tc.user, tc.text)
+			if response.ResponseType != tc.expectedType {
+				t.Errorf("expected response type %q, got %q", tc.expectedType, response.ResponseType)
+			}
+			for _, text := range tc.expectedText {
+				if !strings.Contains(response.Text, text) {
+					t.Errorf("expected response to contain %q, got %q", text, response.Text)
+				}
+			}
+			for _, text := range tc.unexpectedText {
+				if strings.Contains(response.Text, text) {
+					t.Errorf("expected response not to contain %q, got %q", text, response.Text)
+				}
+			}
+			list, err := s.ProwJobClient.List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list ProwJobs: %v", err)
+			}
+			if len(list.Items) != tc.expectedJobs {
+				t.Errorf("expected %d ProwJobs, got %d", tc.expectedJobs, len(list.Items))
+			}
+			for name, state := range tc.expectedState {
+				pj, err := s.ProwJobClient.Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get ProwJob %s: %v", name, err)
+				}
+				if pj.Status.State != state {
+					t.Errorf("expected ProwJob %s to be in state %s, got %s", name, state, pj.Status.State)
+				}
+			}
+		})
+	}
+}
+
+func TestRerunInteraction(t *testing.T) {
+	s, _, slackServer := newServer(prowJob("old", "ci-job", prowapi.FailureState, fakeNow))
+	defer slackServer.Close()
+
+	var payload slack.InteractionPayload
+	payload.Type = "block_actions"
+	payload.User.ID = "U-ADMIN"
+	payload.ResponseURL = slackServer.ResponseURL("1")
+	payload.Actions = []slack.Action{{ActionID: slack.ViewLogsActionID}, {ActionID: slack.RerunActionID, Value: "old"}}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, signedRequest(url.Values{"payload": {string(raw)}}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	responses := slackServer.Responses("1")
+	if len(responses) != 1 || !strings.Contains(responses[0].Text, "@admin reran `ci-job`") {
+		t.Errorf("expected a single response about the rerun, got %+v", responses)
+	}
+	list, err := s.ProwJobClient.List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list ProwJobs: %v", err)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("expected the job to be rerun, got %d ProwJobs", len(list.Items))
+	}
+}
+
+func TestTide(t *testing.T) {
+	pools := []tide.Pool{
+		{Org: "org", Repo: "repo", Branch: "main", Action: tide.Merge, SuccessPRs: []tide.PullRequest{{}, {}}, Blockers: []blockers.Blocker{{Number: 3, URL: "https://github.com/org/repo/issues/3"}}},
+		{Org: "org", Repo: "other", Branch: "main", Action: tide.Wait},
+	}
+	tideServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pools)
+	}))
+	defer tideServer.Close()
+	s, _, slackServer := newServer()
+	defer slackServer.Close()
+	s.TideURL = tideServer.URL
+
+	response := runCommand(t, s, "U-UNMAPPED", "tide org/repo")
+	expected := "Tide pools of org/repo:\n• `main`: MERGE, 2 mergeable, 0 pending, 0 missing requirements, blocked by <https://github.com/org/repo/issues/3|#3>"
+	if response.Text != expected {
+		t.Errorf("expected %q, got %q", expected, response.Text)
+	}
+	if response := runCommand(t, s, "U-UNMAPPED", "tide org/unknown"); response.Text != "Tide has no pools for org/unknown." {
+		t.Errorf("unexpected response for repo without pools: %q", response.Text)
+	}
+}
+
+func TestHold(t *testing.T) {
+	s, ghc, slackServer := newServer()
+	defer slackServer.Close()
+
+	if response := runCommand(t, s, "U-DEV", "hold org/repo main"); !strings.Contains(response.Text, "Only collaborators") {
+		t.Errorf("expected non-collaborator to be rejected, got %q", response.Text)
+	}
+	if response := runCommand(t, s, "U-ADMIN", "hold org/repo main"); response.ResponseType != slack.ResponseTypeInChannel {
+		t.Errorf("expected hold to succeed, got %q", response.Text)
+	}
+	if len(ghc.Issues) != 1 {
+		t.Fatalf("expected a blocker issue, got %d issues", len(ghc.Issues))
+	}
+	for _, issue := range ghc.Issues {
+		if issue.Title != "Merges held from Slack for branch:main" || !issue.HasLabel("tide/merge-blocker") {
+			t.Errorf("unexpected blocker issue %+v", issue)
+		}
+	}
+	if response := runCommand(t, s, "U-ADMIN", "hold org/repo main"); !strings.Contains(response.Text, "already held") {
+		t.Errorf("expected second hold to be a no-op, got %q", response.Text)
+	}
+	if response := runCommand(t, s, "U-ADMIN", "unhold org/repo main"); response.ResponseType != slack.ResponseTypeInChannel {
+		t.Errorf("expected unhold to succeed, got %q", response.Text)
+	}
+	for _, issue := range ghc.Issues {
+		if issue.State != "closed" {
+			t.Errorf("expected blocker issue #%d to be closed", issue.Number)
+		}
+	}
+	if response := runCommand(t, s, "U-ADMIN", "unhold org/repo main"); !strings.Contains(response.Text, "not held") {
+		t.Errorf("expected second unhold to be a no-op, got %q", response.Text)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package slack
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -40,21 +41,38 @@ type Client struct {
 
 	tokenGenerator func() []byte
 	fake           bool
+	baseURL        string
 }
 
 const (
-	chatPostMessage = "https://slack.com/api/chat.postMessage"
+	defaultBaseURL  = "https://slack.com/api"
+	chatPostMessage = "/chat.postMessage"
 
 	botName      = "prow"
 	botIconEmoji = ":prow:"
 )
 
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sets the URL of the Slack Web API, e.g. to use a fake Slack server.
+func WithBaseURL(baseURL string) Option {
+	return func(sl *Client) {
+		sl.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
 // NewClient creates a slack client with an API token.
-func NewClient(tokenGenerator func() []byte) *Client {
-	return &Client{
+func NewClient(tokenGenerator func() []byte, opts ...Option) *Client {
+	sl := &Client{
 		logger:         logrus.WithField("client", "slack"),
 		tokenGenerator: tokenGenerator,
+		baseURL:        defaultBaseURL,
 	}
+	for _, opt := range opts {
+		opt(sl)
+	}
+	return sl
 }
 
 // NewFakeClient returns a client that takes no actions.
@@ -83,11 +101,15 @@ func (sl *Client) urlValues() *url.Values {
 	return &uv
 }
 
-func (sl *Client) postMessage(url string, uv *url.Values) error {
-	resp, err := http.PostForm(url, *uv)
+func (sl *Client) postMessage(method string, uv *url.Values) error {
+	resp, err := http.PostForm(sl.baseURL+method, *uv)
 	if err != nil {
 		return err
 	}
+	return checkResponse(resp)
+}
+
+func checkResponse(resp *http.Response) error {
 	defer resp.Body.Close()
 
 	body, _ := ioutil.ReadAll(resp.Body)
@@ -120,3 +142,47 @@ func (sl *Client) WriteMessage(text, channel string) error {
 
 	return sl.postMessage(chatPostMessage, uv)
 }
+
+// WriteMessageWithBlocks adds a message with Block Kit blocks to the channel.
+// The text is shown in notifications and by clients that cannot render blocks.
+func (sl *Client) WriteMessageWithBlocks(text, channel string, blocks []Block) error {
+	sl.log("WriteMessageWithBlocks", text, channel)
+	if sl.fake {
+		return nil
+	}
+
+	raw, err := json.Marshal(blocks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal blocks: %v", err)
+	}
+	var uv = sl.urlValues()
+	uv.Add("channel", channel)
+	uv.Add("text", text)
+	uv.Add("blocks", string(raw))
+
+	return sl.postMessage(chatPostMessage, uv)
+}
+
+// Respond posts the response to the response URL of a slash command or an
+// interaction. Response URLs need no token.
+func (sl *Client) Respond(responseURL string, response Response) error {
+	sl.log("Respond", responseURL, response.Text)
+	if sl.fake {
+		return nil
+	}
+
+	raw, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %v", err)
+	}
+	resp, err := http.Post(responseURL, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("response failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWriteMessage(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	client := server.Client()
+
+	if err := client.WriteMessage("hello", "#prow"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	blocks := []Block{
+		SectionBlock("*hello*"),
+		ActionsBlock(Button("View logs", "", "", "https://prow.example.com/view/1"), Button("Rerun", "rerun", "job", "")),
+	}
+	if err := client.WriteMessageWithBlocks("hello", "#prow", blocks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.WriteMessage("hello", ""); err == nil {
+		t.Error("expected an error for a message without channel")
+	}
+
+	expected := []FakeMessage{
+		{Channel: "#prow", Text: "hello"},
+		{Channel: "#prow", Text: "hello", Blocks: blocks},
+	}
+	if actual := server.Messages(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected messages %+v, got %+v", expected, actual)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	client := server.Client()
+
+	response := Response{ResponseType: ResponseTypeEphemeral, Text: "done"}
+	if err := client.Respond(server.ResponseURL("1"), response); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := server.Responses("1"); !reflect.DeepEqual(actual, []Response{response}) {
+		t.Errorf("expected responses %+v, got %+v", []Response{response}, actual)
+	}
+	if err := client.Respond(server.URL+"/unknown", response); err == nil {
+		t.Error("expected an error for an unknown response URL")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeMessage is a message posted to the fake Slack server.
+type FakeMessage struct {
+	Channel string
+	Text    string
+	Blocks  []Block
+}
+
+// FakeServer is a fake Slack server that records the messages posted through
+// the Web API and the responses posted to its response URLs.
+type FakeServer struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	messages  []FakeMessage
+	responses map[string][]Response
+}
+
+// NewFakeServer starts a fake Slack server. Callers must Close it.
+func NewFakeServer() *FakeServer {
+	f := &FakeServer{responses: map[string][]Response{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc(chatPostMessage, f.handlePostMessage)
+	mux.HandleFunc("/response/", f.handleResponse)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// Client returns a client talking to the fake server.
+func (f *FakeServer) Client() *Client {
+	return NewClient(func() []byte { return []byte("token") }, WithBaseURL(f.URL))
+}
+
+// ResponseURL returns a response URL served by the fake.
+func (f *FakeServer) ResponseURL(id string) string {
+	return f.URL + "/response/" + id
+}
+
+// Messages returns the messages posted so far.
+func (f *FakeServer) Messages() []FakeMessage {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]FakeMessage(nil), f.messages...)
+}
+
+// Responses returns the responses posted so far to the response URL with the id.
+func (f *FakeServer) Responses(id string) []Response {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]Response(nil), f.responses[id]...)
+}
+
+func (f *FakeServer) handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("token") == "" {
+		w.Write([]byte(`{"ok":false,"error":"not_authed"}`))
+		return
+	}
+	message := FakeMessage{Channel: r.FormValue("channel"), Text: r.FormValue("text")}
+	if raw := r.FormValue("blocks"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &message.Blocks); err != nil {
+			w.Write([]byte(`{"ok":false,"error":"invalid_blocks"}`))
+			return
+		}
+	}
+	if message.Channel == "" {
+		w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+		return
+	}
+	f.lock.Lock()
+	f.messages = append(f.messages, message)
+	f.lock.Unlock()
+	w.Write([]byte(`{"ok":true}`))
+}
+
+func (f *FakeServer) handleResponse(w http.ResponseWriter, r *http.Request) {
+	var response Response
+	if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/response/")
+	f.lock.Lock()
+	f.responses[id] = append(f.responses[id], response)
+	f.lock.Unlock()
+}
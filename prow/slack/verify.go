@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader holds the signature of requests sent by Slack.
+	SignatureHeader = "X-Slack-Signature"
+	// TimestampHeader holds the time at which Slack sent the request.
+	TimestampHeader = "X-Slack-Request-Timestamp"
+
+	signatureVersion = "v0"
+	// maxRequestAge bounds the window in which a signed request can be replayed.
+	maxRequestAge = 5 * time.Minute
+)
+
+// Sign returns the signature Slack sends along with a request with the
+// given timestamp and body.
+// See https://api.slack.com/authentication/verifying-requests-from-slack
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s:%s:", signatureVersion, timestamp)
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequest checks that the request body was signed by Slack with the
+// signing secret less than five minutes before now.
+func VerifyRequest(secret []byte, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get(TimestampHeader)
+	if timestamp == "" {
+		return errors.New("missing " + TimestampHeader + " header")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header %q: %v", TimestampHeader, timestamp, err)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return fmt.Errorf("request timestamp %s is too far from the current time", timestamp)
+	}
+	signature := header.Get(SignatureHeader)
+	if signature == "" {
+		return errors.New("missing " + SignatureHeader + " header")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
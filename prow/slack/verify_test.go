@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte("command=%2Fprow&text=status")
+	now := time.Unix(1600000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	testCases := []struct {
+		name      string
+		timestamp string
+		signature string
+		expectErr bool
+	}{
+		{
+			name:      "valid signature",
+			timestamp: timestamp,
+			signature: Sign(secret, timestamp, body),
+		},
+		{
+			name:      "signed with another secret",
+			timestamp: timestamp,
+			signature: Sign([]byte("other"), timestamp, body),
+			expectErr: true,
+		},
+		{
+			name:      "body was changed",
+			timestamp: timestamp,
+			signature: Sign(secret, timestamp, []byte("command=%2Fprow&text=abort")),
+			expectErr: true,
+		},
+		{
+			name:      "request is too old",
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: Sign(secret, strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10), body),
+			expectErr: true,
+		},
+		{
+			name:      "missing signature",
+			timestamp: timestamp,
+			expectErr: true,
+		},
+		{
+			name:      "missing timestamp",
+			signature: Sign(secret, timestamp, body),
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.timestamp != "" {
+				header.Set(TimestampHeader, tc.timestamp)
+			}
+			if tc.signature != "" {
+				header.Set(SignatureHeader, tc.signature)
+			}
+			err := VerifyRequest(secret, header, body, now)
+			if err != nil && !tc.expectErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err == nil && tc.expectErr {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
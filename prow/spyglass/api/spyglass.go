@@ -17,7 +17,10 @@ limitations under the License.
 package api
 
 import (
+	"context"
 	"encoding/json"
+
+	pkgio "k8s.io/test-infra/prow/io"
 )
 
 // Key types specify the way Spyglass will fetch artifact handles
@@ -38,6 +41,17 @@ type Lens interface {
 	Callback(artifacts []Artifact, resourceRoot string, data string, config json.RawMessage) string
 }
 
+// RunLens is implemented by lenses that look beyond the artifacts of the run being
+// viewed, e.g. at the results of earlier runs of the same job. Lens servers bind such
+// lenses to the run they render before calling Header, Body or Callback.
+type RunLens interface {
+	Lens
+	// ForRun returns a copy of the lens that reads storage through opener and
+	// knows that its artifacts belong to the run stored at runPath,
+	// e.g. gs://bucket/logs/job/123.
+	ForRun(ctx context.Context, opener pkgio.Opener, runPath string) Lens
+}
+
 // Artifact represents some output of a prow job
 type Artifact interface {
 	// ReadAt reads len(p) bytes of the artifact at offset off. (unsupported on some compressed files)
@@ -33,6 +33,7 @@ import (
 
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
+	pkgio "k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/io/providers"
 	"k8s.io/test-infra/prow/spyglass/api"
 )
@@ -51,6 +52,7 @@ func NewLensServer(
 	storageArtifactFetcher ArtifactFetcher,
 	podLogArtifactFetcher ArtifactFetcher,
 	cfg config.Getter,
+	opener pkgio.Opener,
 	lenses []LensWithConfiguration,
 ) (*http.Server, error) {
 
@@ -69,6 +71,7 @@ func NewLensServer(
 			StorageArtifactFetcher: storageArtifactFetcher,
 			PodLogArtifactFetcher:  podLogArtifactFetcher,
 			ConfigGetter:           cfg,
+			Opener:                 opener,
 			LensOpt:                lens.Config,
 		}
 		mux.Handle(DyanmicPathForLens(lens.Config.LensName), newLensHandler(lens.Lens, opt))
@@ -92,6 +95,8 @@ type lensHandlerOpts struct {
 	StorageArtifactFetcher ArtifactFetcher
 	PodLogArtifactFetcher  ArtifactFetcher
 	ConfigGetter           config.Getter
+	// Opener is handed to lenses implementing api.RunLens, it may be nil.
+	Opener pkgio.Opener
 	LensOpt
 }
 
@@ -121,6 +126,16 @@ func newLensHandler(lens api.Lens, opts lensHandlerOpts) http.HandlerFunc {
 			return
 		}
 
+		lens := lens
+		if runLens, ok := lens.(api.RunLens); ok && opts.Opener != nil {
+			runPath, err := storagePathForSource(opts.PJFetcher, opts.ConfigGetter, request.ArtifactSource)
+			if err != nil {
+				logrus.WithError(err).WithField("src", request.ArtifactSource).Warn("Failed to resolve storage path of run, rendering lens without it")
+			} else {
+				lens = runLens.ForRun(r.Context(), opts.Opener, runPath)
+			}
+		}
+
 		switch request.Action {
 		case api.RequestActionInitial:
 			w.Header().Set("Content-Type", "text/html; encoding=utf-8")
@@ -182,23 +197,9 @@ func FetchArtifacts(
 ) ([]api.Artifact, error) {
 	artStart := time.Now()
 	arts := []api.Artifact{}
-	keyType, key, err := splitSrc(src)
+	gcsKey, err := storagePathForSource(pjFetcher, cfg, src)
 	if err != nil {
-		return arts, fmt.Errorf("error parsing src: %v", err)
-	}
-	gcsKey := ""
-	switch keyType {
-	case api.ProwKeyType:
-		storageProvider, key, err := ProwToGCS(pjFetcher, cfg, key)
-		if err != nil {
-			logrus.Warningln(err)
-		}
-		gcsKey = fmt.Sprintf("%s://%s", storageProvider, strings.TrimSuffix(key, "/"))
-	default:
-		if keyType == api.GCSKeyType {
-			keyType = providers.GS
-		}
-		gcsKey = fmt.Sprintf("%s://%s", keyType, strings.TrimSuffix(key, "/"))
+		return arts, err
 	}
 
 	logsNeeded := []string{}
@@ -234,6 +235,28 @@ func FetchArtifacts(
 	return arts, nil
 }
 
+// storagePathForSource returns the storage path of the run the given src points to,
+// e.g. gs://bucket/logs/job/123.
+func storagePathForSource(pjFetcher ProwJobFetcher, cfg config.Getter, src string) (string, error) {
+	keyType, key, err := splitSrc(src)
+	if err != nil {
+		return "", fmt.Errorf("error parsing src: %v", err)
+	}
+	switch keyType {
+	case api.ProwKeyType:
+		storageProvider, key, err := ProwToGCS(pjFetcher, cfg, key)
+		if err != nil {
+			logrus.Warningln(err)
+		}
+		return fmt.Sprintf("%s://%s", storageProvider, strings.TrimSuffix(key, "/")), nil
+	default:
+		if keyType == api.GCSKeyType {
+			keyType = providers.GS
+		}
+		return fmt.Sprintf("%s://%s", keyType, strings.TrimSuffix(key, "/")), nil
+	}
+}
+
 // ProwJobFetcher knows how to get a ProwJob
 type ProwJobFetcher interface {
 	GetProwJob(job string, id string) (prowv1.ProwJob, error)
@@ -0,0 +1,408 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+	"github.com/sirupsen/logrus"
+
+	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	pkgio "k8s.io/test-infra/prow/io"
+	"k8s.io/test-infra/prow/io/providers"
+	"k8s.io/test-infra/prow/jobhistory"
+	"k8s.io/test-infra/prow/spyglass/api"
+)
+
+const (
+	// historyTimeout bounds the time spent reading earlier runs, whatever could
+	// be read by then is shown.
+	historyTimeout = 30 * time.Second
+
+	newFailure        failureClass = "new"
+	knownFlakyFailure failureClass = "flaky"
+	persistentFailure failureClass = "persistent"
+)
+
+var _ api.RunLens = Lens{}
+
+// lensConfig is the configuration of the junit lens in the Spyglass config.
+type lensConfig struct {
+	// HistoryRuns is the number of earlier runs of the job that failures are
+	// compared with. Zero disables the comparison.
+	HistoryRuns int `json:"history_runs,omitempty"`
+	// BaseJobs maps presubmits to the postsubmit or periodic job running the same
+	// tests on the base branch. Failures of a presubmit are diffed against the
+	// latest run of that job on the presubmit's base branch.
+	BaseJobs map[string]string `json:"base_jobs,omitempty"`
+}
+
+// failureClass tells how a failure relates to earlier runs of the job.
+type failureClass string
+
+// Label is the text shown for the class of failure.
+func (c failureClass) Label() string {
+	switch c {
+	case newFailure:
+		return "new failure"
+	case knownFlakyFailure:
+		return "known flaky"
+	case persistentFailure:
+		return "persistent failure"
+	}
+	return string(c)
+}
+
+// RunHistory summarizes how the failures of a run compare with earlier runs of the job.
+type RunHistory struct {
+	// Runs is the number of earlier runs the results were compared with.
+	Runs int
+	// New, Flaky and Persistent count the failures of each class.
+	New        int
+	Flaky      int
+	Persistent int
+	// BaseJob is the job running the tests on the base branch, if one is configured.
+	BaseJob string
+	// BaseBuild and BaseLink identify the run of BaseJob that failures were diffed against.
+	// They are empty when no such run was found.
+	BaseBuild string
+	BaseLink  string
+	// FailsOnBase counts the failures that also fail in that run.
+	FailsOnBase int
+}
+
+// TestHistory describes how a test fared in earlier runs of the job.
+type TestHistory struct {
+	// Class is set for tests that failed in this run.
+	Class failureClass
+	// Runs holds the results of the test in earlier runs, oldest first.
+	Runs []HistoricResult
+	// FailsOnBase is set for failures that also fail on the base branch.
+	FailsOnBase bool
+}
+
+// MedianDuration is the median duration of the test over the earlier runs.
+func (h TestHistory) MedianDuration() time.Duration {
+	if len(h.Runs) == 0 {
+		return 0
+	}
+	var durations []time.Duration
+	for _, run := range h.Runs {
+		durations = append(durations, run.Duration)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2]
+}
+
+// HistoricResult is the result of a test in an earlier run.
+type HistoricResult struct {
+	BuildID  string
+	Link     string
+	Status   testStatus
+	Duration time.Duration
+}
+
+// historyKey identifies a test across runs.
+type historyKey struct {
+	class string
+	name  string
+}
+
+func keyFor(test TestResult) historyKey {
+	return historyKey{class: test.Junit[0].ClassName, name: test.Junit[0].Name}
+}
+
+// run holds the results of the tests in a single run of a job.
+type run struct {
+	id    int64
+	link  string
+	tests map[historyKey]HistoricResult
+}
+
+// history reads the results of other runs of the job whose run the lens renders.
+type history struct {
+	ctx    context.Context
+	bucket jobhistory.Bucket
+	// dir is the directory of the rendered run in the bucket.
+	dir string
+}
+
+// ForRun binds the lens to the run stored at runPath, so that its results can be
+// compared with earlier runs of the same job.
+func (lens Lens) ForRun(ctx context.Context, opener pkgio.Opener, runPath string) api.Lens {
+	storageProvider, bucket, dir, err := providers.ParseStoragePath(runPath)
+	if err != nil {
+		logrus.WithError(err).WithField("run", runPath).Warn("Error parsing storage path of run.")
+		return lens
+	}
+	return Lens{history: &history{
+		ctx:    ctx,
+		bucket: jobhistory.NewBucket(bucket, storageProvider, opener),
+		dir:    dir,
+	}}
+}
+
+// annotate compares the results in jvd with up to conf.HistoryRuns earlier runs
+// of the job and with the latest run of the base branch job, if configured.
+// Earlier runs are read from the same junit files as the rendered run.
+func (h *history) annotate(jvd *JVD, junitPaths []string, conf lensConfig) {
+	ctx, cancel := context.WithTimeout(h.ctx, historyTimeout)
+	defer cancel()
+	log := logrus.WithField("run", h.dir)
+
+	root, id, err := jobhistory.RootForRun(h.dir)
+	if err != nil {
+		log.WithError(err).Info("Not comparing with earlier runs.")
+		return
+	}
+	ids, err := h.bucket.ListBuildIDs(ctx, root)
+	if err != nil {
+		log.WithError(err).Warn("Error listing earlier runs, comparing with those that could be listed.")
+	}
+	ids = previousIDs(ids, id, conf.HistoryRuns)
+
+	runs := make([]*run, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id int64) {
+			defer wg.Done()
+			dir, err := h.bucket.GetPath(ctx, root, strconv.FormatInt(id, 10), "")
+			if err != nil {
+				log.WithError(err).WithField("build", id).Warn("Error finding earlier run.")
+				return
+			}
+			runs[i] = h.readRun(ctx, id, dir, junitPaths)
+		}(i, id)
+	}
+	var base *run
+	baseJob := conf.BaseJobs[path.Base(root)]
+	if baseJob != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			base = h.baseRun(ctx, baseJob, junitPaths, conf.HistoryRuns)
+		}()
+	}
+	wg.Wait()
+
+	// oldest first, so that trends read from left to right
+	var earlier []*run
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i] != nil {
+			earlier = append(earlier, runs[i])
+		}
+	}
+
+	summary := &RunHistory{Runs: len(earlier), BaseJob: baseJob}
+	if base != nil {
+		summary.BaseBuild = strconv.FormatInt(base.id, 10)
+		summary.BaseLink = base.link
+	}
+	for i := range jvd.Failed {
+		hist := testHistory(keyFor(jvd.Failed[i]), earlier)
+		hist.Class = classify(hist.Runs)
+		switch hist.Class {
+		case newFailure:
+			summary.New++
+		case knownFlakyFailure:
+			summary.Flaky++
+		case persistentFailure:
+			summary.Persistent++
+		}
+		if base != nil && base.tests[keyFor(jvd.Failed[i])].Status == failedStatus {
+			hist.FailsOnBase = true
+			summary.FailsOnBase++
+		}
+		jvd.Failed[i].History = &hist
+	}
+	for _, results := range [][]TestResult{jvd.Flaky, jvd.Passed} {
+		for i := range results {
+			hist := testHistory(keyFor(results[i]), earlier)
+			results[i].History = &hist
+		}
+	}
+	jvd.History = summary
+}
+
+// previousIDs returns up to n of the ids that precede current, newest first.
+func previousIDs(ids []int64, current int64, n int) []int64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	var previous []int64
+	for _, id := range ids {
+		if len(previous) == n {
+			break
+		}
+		if id < current {
+			previous = append(previous, id)
+		}
+	}
+	return previous
+}
+
+func testHistory(key historyKey, runs []*run) TestHistory {
+	var hist TestHistory
+	for _, run := range runs {
+		if result, ok := run.tests[key]; ok && result.Status != skippedStatus {
+			hist.Runs = append(hist.Runs, result)
+		}
+	}
+	return hist
+}
+
+// classify tells whether a test that failed in this run never failed before, is
+// known to flake or failed every time it ran before.
+func classify(results []HistoricResult) failureClass {
+	var failed, passed bool
+	for _, result := range results {
+		switch result.Status {
+		case failedStatus:
+			failed = true
+		case passedStatus:
+			passed = true
+		case flakyStatus:
+			failed, passed = true, true
+		}
+	}
+	switch {
+	case !failed:
+		return newFailure
+	case !passed:
+		return persistentFailure
+	default:
+		return knownFlakyFailure
+	}
+}
+
+// baseRun finds the latest run of baseJob on the base branch of the rendered run
+// that has test results, looking at no more than limit runs.
+func (h *history) baseRun(ctx context.Context, baseJob string, junitPaths []string, limit int) *run {
+	log := logrus.WithFields(logrus.Fields{"run": h.dir, "base-job": baseJob})
+	baseRef := h.baseRef(ctx, h.dir)
+	root := path.Join(jobhistory.LogsPrefix, baseJob)
+	ids, err := h.bucket.ListBuildIDs(ctx, root)
+	if err != nil {
+		log.WithError(err).Warn("Error listing runs of base job, looking at those that could be listed.")
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		dir := path.Join(root, strconv.FormatInt(id, 10))
+		if baseRef != "" && h.baseRef(ctx, dir) != baseRef {
+			continue
+		}
+		if run := h.readRun(ctx, id, dir, junitPaths); run != nil {
+			return run
+		}
+	}
+	log.Info("No run of base job with test results found.")
+	return nil
+}
+
+// baseRef returns the branch the run in dir tested, as recorded in its prowjob.json.
+func (h *history) baseRef(ctx context.Context, dir string) string {
+	raw, err := h.bucket.ReadObject(ctx, path.Join(dir, "prowjob.json"))
+	if err != nil {
+		logrus.WithError(err).WithField("run", dir).Debug("Error reading prowjob.")
+		return ""
+	}
+	var pj prowv1.ProwJob
+	if err := json.Unmarshal(raw, &pj); err != nil {
+		logrus.WithError(err).WithField("run", dir).Warn("Error decoding prowjob.")
+		return ""
+	}
+	if pj.Spec.Refs != nil {
+		return pj.Spec.Refs.BaseRef
+	}
+	if len(pj.Spec.ExtraRefs) > 0 {
+		return pj.Spec.ExtraRefs[0].BaseRef
+	}
+	return ""
+}
+
+// readRun reads the results of the run in dir from the given junit files.
+// It returns nil if none of the files could be read.
+func (h *history) readRun(ctx context.Context, id int64, dir string, junitPaths []string) *run {
+	r := &run{
+		id:    id,
+		link:  path.Join("/view", h.bucket.StorageProvider, h.bucket.Name, dir),
+		tests: map[historyKey]HistoricResult{},
+	}
+	var read bool
+	for _, junitPath := range junitPaths {
+		contents, err := h.bucket.ReadObject(ctx, path.Join(dir, junitPath))
+		if err != nil {
+			logrus.WithError(err).WithField("run", dir).Debug("Error reading junit file of earlier run.")
+			continue
+		}
+		suites, err := junit.Parse(contents)
+		if err != nil {
+			logrus.WithError(err).WithField("run", dir).Info("Error parsing junit file of earlier run.")
+			continue
+		}
+		read = true
+		groups := map[historyKey][]JunitResult{}
+		var record func(suite junit.Suite)
+		record = func(suite junit.Suite) {
+			for _, subSuite := range suite.Suites {
+				record(subSuite)
+			}
+			for _, test := range suite.Results {
+				k := historyKey{class: test.ClassName, name: test.Name}
+				groups[k] = append(groups[k], JunitResult{Result: test})
+			}
+		}
+		for _, suite := range suites.Suites {
+			record(suite)
+		}
+		for k, tests := range groups {
+			result := HistoricResult{
+				BuildID:  strconv.FormatInt(id, 10),
+				Link:     r.link,
+				Duration: tests[0].Duration(),
+			}
+			switch skipped, passed, failed, flaky := summarize(tests); {
+			case failed:
+				result.Status = failedStatus
+			case flaky:
+				result.Status = flakyStatus
+			case passed:
+				result.Status = passedStatus
+			case skipped:
+				result.Status = skippedStatus
+			}
+			// the same test may be reported by several files, a failure wins
+			if previous, ok := r.tests[k]; ok && previous.Status == failedStatus {
+				continue
+			}
+			r.tests[k] = result
+		}
+	}
+	if !read {
+		return nil
+	}
+	return r
+}
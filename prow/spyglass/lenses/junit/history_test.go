@@ -0,0 +1,264 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package junit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+	"github.com/google/go-cmp/cmp"
+
+	pkgio "k8s.io/test-infra/prow/io"
+)
+
+// fakeOpener serves the objects of a single bucket from memory.
+type fakeOpener struct {
+	pkgio.Opener
+	objects map[string]string
+}
+
+func (fo fakeOpener) Reader(_ context.Context, p string) (pkgio.ReadCloser, error) {
+	content, ok := fo.objects[strings.TrimPrefix(p, "gs://bucket/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (fo fakeOpener) Iterator(_ context.Context, prefix, delimiter string) (pkgio.ObjectIterator, error) {
+	prefix = strings.TrimPrefix(prefix, "gs://bucket/")
+	seen := map[string]bool{}
+	var attrs []pkgio.ObjectAttributes
+	for key := range fo.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			dir := prefix + rest[:i+1]
+			if !seen[dir] {
+				seen[dir] = true
+				attrs = append(attrs, pkgio.ObjectAttributes{Name: dir, IsDir: true})
+			}
+			continue
+		}
+		attrs = append(attrs, pkgio.ObjectAttributes{Name: key})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+	return &fakeIterator{attrs: attrs}, nil
+}
+
+type fakeIterator struct {
+	attrs []pkgio.ObjectAttributes
+}
+
+func (fi *fakeIterator) Next(_ context.Context) (pkgio.ObjectAttributes, error) {
+	if len(fi.attrs) == 0 {
+		return pkgio.ObjectAttributes{}, io.EOF
+	}
+	next := fi.attrs[0]
+	fi.attrs = fi.attrs[1:]
+	return next, nil
+}
+
+// junitXML renders a suite with the given tests, mapping test names to whether they failed.
+func junitXML(tests map[string]bool) string {
+	var names []string
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(`<testsuites><testsuite name="suite">`)
+	for _, name := range names {
+		if tests[name] {
+			fmt.Fprintf(&b, `<testcase classname="class" name="%s" time="2"><failure>boom</failure></testcase>`, name)
+		} else {
+			fmt.Fprintf(&b, `<testcase classname="class" name="%s" time="1"></testcase>`, name)
+		}
+	}
+	b.WriteString(`</testsuite></testsuites>`)
+	return b.String()
+}
+
+func prowJobJSON(baseRef string) string {
+	return fmt.Sprintf(`{"spec": {"refs": {"org": "org", "repo": "repo", "base_ref": %q}}}`, baseRef)
+}
+
+func TestAnnotate(t *testing.T) {
+	objects := map[string]string{
+		"pr-logs/pull/org_repo/7/pull-unit/105/prowjob.json": prowJobJSON("master"),
+		// the run before the rendered one is from another job's directory and must be ignored
+		"pr-logs/directory/pull-other/104.txt": "gs://bucket/pr-logs/pull/org_repo/6/pull-other/104",
+		// a run newer than the rendered one must be ignored as well
+		"pr-logs/directory/pull-unit/106.txt":                   "gs://bucket/pr-logs/pull/org_repo/8/pull-unit/106",
+		"pr-logs/pull/org_repo/8/pull-unit/106/artifacts/j.xml": junitXML(map[string]bool{"new": true, "persistent": true, "flaky": true}),
+		// the runs compared with
+		"pr-logs/directory/pull-unit/103.txt":                   "gs://bucket/pr-logs/pull/org_repo/6/pull-unit/103",
+		"pr-logs/pull/org_repo/6/pull-unit/103/artifacts/j.xml": junitXML(map[string]bool{"new": false, "persistent": true, "flaky": true, "passing": false}),
+		"pr-logs/directory/pull-unit/102.txt":                   "gs://bucket/pr-logs/pull/org_repo/5/pull-unit/102",
+		"pr-logs/pull/org_repo/5/pull-unit/102/artifacts/j.xml": junitXML(map[string]bool{"new": false, "persistent": true, "flaky": false, "passing": false}),
+		// only the runs before these two are compared with
+		"pr-logs/directory/pull-unit/101.txt":                   "gs://bucket/pr-logs/pull/org_repo/4/pull-unit/101",
+		"pr-logs/pull/org_repo/4/pull-unit/101/artifacts/j.xml": junitXML(map[string]bool{"new": true}),
+		// the latest base job run is on another branch
+		"logs/post-unit/51/prowjob.json":    prowJobJSON("release-1.0"),
+		"logs/post-unit/51/artifacts/j.xml": junitXML(map[string]bool{"new": true, "persistent": true}),
+		"logs/post-unit/50/prowjob.json":    prowJobJSON("master"),
+		"logs/post-unit/50/artifacts/j.xml": junitXML(map[string]bool{"new": false, "persistent": true, "flaky": false}),
+	}
+	failed := func(name string) TestResult {
+		return TestResult{Junit: []JunitResult{{Result: junit.Result{ClassName: "class", Name: name, Failure: new(string)}}}}
+	}
+	jvd := JVD{
+		NumTests: 4,
+		Failed:   []TestResult{failed("new"), failed("persistent"), failed("flaky")},
+		Passed:   []TestResult{{Junit: []JunitResult{{Result: junit.Result{ClassName: "class", Name: "passing"}}}}},
+	}
+
+	lens := Lens{}.ForRun(context.Background(), fakeOpener{objects: objects}, "gs://bucket/pr-logs/pull/org_repo/7/pull-unit/105").(Lens)
+	lens.history.annotate(&jvd, []string{"artifacts/j.xml"}, lensConfig{
+		HistoryRuns: 2,
+		BaseJobs:    map[string]string{"pull-unit": "post-unit"},
+	})
+
+	expectedSummary := &RunHistory{
+		Runs:        2,
+		New:         1,
+		Flaky:       1,
+		Persistent:  1,
+		BaseJob:     "post-unit",
+		BaseBuild:   "50",
+		BaseLink:    "/view/gs/bucket/logs/post-unit/50",
+		FailsOnBase: 1,
+	}
+	if diff := cmp.Diff(expectedSummary, jvd.History); diff != "" {
+		t.Errorf("summary differs from expected: %s", diff)
+	}
+
+	result := func(id string, org int, status testStatus) HistoricResult {
+		duration := time.Second
+		if status == failedStatus {
+			duration = 2 * time.Second
+		}
+		return HistoricResult{
+			BuildID:  id,
+			Link:     fmt.Sprintf("/view/gs/bucket/pr-logs/pull/org_repo/%d/pull-unit/%s", org, id),
+			Status:   status,
+			Duration: duration,
+		}
+	}
+	expected := map[string]*TestHistory{
+		"new": {
+			Class: newFailure,
+			Runs:  []HistoricResult{result("102", 5, passedStatus), result("103", 6, passedStatus)},
+		},
+		"persistent": {
+			Class:       persistentFailure,
+			Runs:        []HistoricResult{result("102", 5, failedStatus), result("103", 6, failedStatus)},
+			FailsOnBase: true,
+		},
+		"flaky": {
+			Class: knownFlakyFailure,
+			Runs:  []HistoricResult{result("102", 5, passedStatus), result("103", 6, failedStatus)},
+		},
+		"passing": {
+			Runs: []HistoricResult{result("102", 5, passedStatus), result("103", 6, passedStatus)},
+		},
+	}
+	for _, test := range append(jvd.Failed, jvd.Passed...) {
+		name := test.Junit[0].Name
+		if diff := cmp.Diff(expected[name], test.History); diff != "" {
+			t.Errorf("history of %s differs from expected: %s", name, diff)
+		}
+	}
+
+	tmpl, err := template.ParseFiles("template.html")
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "body", jvd); err != nil {
+		t.Fatalf("failed to execute template: %v", err)
+	}
+	for _, expected := range []string{"new failure", "known flaky", "persistent failure", "also fails on base branch", "post-unit #50"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected rendered body to contain %q", expected)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []testStatus
+		expected failureClass
+	}{
+		{
+			name:     "never ran before",
+			expected: newFailure,
+		},
+		{
+			name:     "always passed before",
+			statuses: []testStatus{passedStatus, passedStatus},
+			expected: newFailure,
+		},
+		{
+			name:     "always failed before",
+			statuses: []testStatus{failedStatus, failedStatus},
+			expected: persistentFailure,
+		},
+		{
+			name:     "failed and passed before",
+			statuses: []testStatus{failedStatus, passedStatus},
+			expected: knownFlakyFailure,
+		},
+		{
+			name:     "flaked within a run before",
+			statuses: []testStatus{flakyStatus},
+			expected: knownFlakyFailure,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var results []HistoricResult
+			for _, status := range tc.statuses {
+				results = append(results, HistoricResult{Status: status})
+			}
+			if actual := classify(results); actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMedianDuration(t *testing.T) {
+	hist := TestHistory{Runs: []HistoricResult{{Duration: 5 * time.Second}, {Duration: time.Second}, {Duration: 3 * time.Second}}}
+	if actual, expected := hist.MedianDuration(), 3*time.Second; actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
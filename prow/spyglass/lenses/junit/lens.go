@@ -40,6 +40,7 @@ const (
 	passedStatus  testStatus = "Passed"
 	failedStatus  testStatus = "Failed"
 	skippedStatus testStatus = "Skipped"
+	flakyStatus   testStatus = "Flaky"
 )
 
 func init() {
@@ -49,7 +50,10 @@ func init() {
 type testStatus string
 
 // Lens is the implementation of a JUnit-rendering Spyglass lens.
-type Lens struct{}
+type Lens struct {
+	// history is set once the lens was bound to a run through ForRun.
+	history *history
+}
 
 type JVD struct {
 	NumTests int
@@ -57,6 +61,8 @@ type JVD struct {
 	Failed   []TestResult
 	Skipped  []TestResult
 	Flaky    []TestResult
+	// History is set when the results were compared with earlier runs of the job.
+	History *RunHistory
 }
 
 // Config returns the lens's configuration.
@@ -108,11 +114,61 @@ func (jr JunitResult) Status() testStatus {
 type TestResult struct {
 	Junit []JunitResult
 	Link  string
+	// History is set when the results were compared with earlier runs of the job.
+	History *TestHistory
+}
+
+type testIdentifier struct {
+	suite string
+	class string
+	name  string
+}
+
+// summarize tells how a test that may have been run several times fared overall.
+// A test that both failed and passed is flaky.
+func summarize(tests []JunitResult) (skipped, passed, failed, flaky bool) {
+	for _, test := range tests {
+		// skipped test has no reason to rerun, so no deduplication
+		if test.Status() == skippedStatus {
+			skipped = true
+		} else if test.Status() == failedStatus {
+			if passed {
+				passed = false
+				failed = false
+				flaky = true
+			}
+			if !flaky {
+				failed = true
+			}
+		} else if failed { // Test succeeded but marked failed previously
+			passed = false
+			failed = false
+			flaky = true
+		} else if !flaky { // Test succeeded and not marked as flaky
+			passed = true
+		}
+	}
+	return skipped, passed, failed, flaky
 }
 
 // Body renders the <body> for JUnit tests
 func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string, config json.RawMessage) string {
 	jvd := lens.getJvd(artifacts)
+	if lens.history != nil {
+		var conf lensConfig
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &conf); err != nil {
+				logrus.WithError(err).Error("Error decoding lens config.")
+			}
+		}
+		if conf.HistoryRuns > 0 {
+			var paths []string
+			for _, artifact := range artifacts {
+				paths = append(paths, artifact.JobPath())
+			}
+			lens.history.annotate(&jvd, paths, conf)
+		}
+	}
 
 	junitTemplate, err := template.ParseFiles(filepath.Join(resourceDir, "template.html"))
 	if err != nil {
@@ -136,11 +192,6 @@ func (lens Lens) getJvd(artifacts []api.Artifact) JVD {
 		path  string
 		err   error
 	}
-	type testIdentifier struct {
-		suite string
-		class string
-		name  string
-	}
 	resultChan := make(chan testResults)
 	for _, artifact := range artifacts {
 		go func(artifact api.Artifact) {
@@ -206,33 +257,7 @@ func (lens Lens) getJvd(artifacts []api.Artifact) JVD {
 			continue
 		}
 		for _, tests := range result.junit {
-			var (
-				skipped bool
-				passed  bool
-				failed  bool
-				flaky   bool
-			)
-			for _, test := range tests {
-				// skipped test has no reason to rerun, so no deduplication
-				if test.Status() == skippedStatus {
-					skipped = true
-				} else if test.Status() == failedStatus {
-					if passed {
-						passed = false
-						failed = false
-						flaky = true
-					}
-					if !flaky {
-						failed = true
-					}
-				} else if failed { // Test succeeded but marked failed previously
-					passed = false
-					failed = false
-					flaky = true
-				} else if !flaky { // Test succeeded and not marked as flaky
-					passed = true
-				}
-			}
+			skipped, _, failed, flaky := summarize(tests)
 
 			if skipped {
 				jvd.Skipped = append(jvd.Skipped, TestResult{
@@ -36,6 +36,7 @@ import (
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	k8sreporter "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/pod-utils/wrapper"
 	"k8s.io/test-infra/prow/spyglass/api"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 )
@@ -92,6 +93,7 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 		Errored      bool
 		Elapsed      time.Duration
 		Hint         string
+		Steps        []stepView
 		Metadata     map[string]interface{}
 	}
 	metadataViewData := MetadataViewData{}
@@ -144,6 +146,8 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 		metadataViewData.Elapsed = metadataViewData.Elapsed.Round(time.Second)
 	}
 
+	metadataViewData.Steps = stepsFromMetadata(finished.Metadata, metadataViewData.Elapsed)
+
 	metadataViewData.Metadata = map[string]interface{}{"node": started.Node}
 
 	metadatas := []metadata.Metadata{started.Metadata, finished.Metadata}
@@ -164,6 +168,60 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 	return buf.String()
 }
 
+// stepView is a row of the step breakdown of a multi-step job.
+type stepView struct {
+	Name    string
+	Result  string
+	Class   string
+	Elapsed time.Duration
+	// Percent is the share of the job's time spent in the step.
+	Percent int
+}
+
+// stepsFromMetadata breaks the elapsed time of a job down by the steps
+// recorded in its finished.json, if it had any.
+func stepsFromMetadata(m metadata.Metadata, elapsed time.Duration) []stepView {
+	results, err := wrapper.StepResultsFromMetadata(m)
+	if err != nil {
+		logrus.WithError(err).Info("Failed to read the step results from finished.json")
+		return nil
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	// Fall back to the time spent in steps when the job has no usable start
+	// and finish times, so that the shares still add up.
+	if elapsed <= 0 {
+		for _, r := range results {
+			elapsed += r.Elapsed()
+		}
+	}
+	var steps []stepView
+	for _, r := range results {
+		step := stepView{
+			Name:    r.Name,
+			Result:  r.Result,
+			Elapsed: r.Elapsed().Round(time.Second),
+		}
+		switch r.Result {
+		case wrapper.StepSucceeded:
+			step.Class = "passed"
+		case wrapper.StepSkipped:
+			step.Class = "skipped"
+		default:
+			step.Class = "failed"
+		}
+		if elapsed > 0 {
+			step.Percent = int(100 * r.Elapsed() / elapsed)
+			if step.Percent > 100 {
+				step.Percent = 100
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
 var failedMountRegex = regexp.MustCompile(`MountVolume.SetUp failed for volume "(.+?)" : (.+)`)
 
 func hintFromPodInfo(buf []byte) string {
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -566,3 +567,60 @@ func TestHintFromProwJob(t *testing.T) {
 		})
 	}
 }
+
+func TestStepsFromMetadata(t *testing.T) {
+	testCases := []struct {
+		name     string
+		metadata map[string]interface{}
+		elapsed  time.Duration
+		expected []stepView
+	}{
+		{
+			name:     "job without steps",
+			metadata: map[string]interface{}{"repo": "k8s.io/test-infra"},
+			elapsed:  time.Minute,
+		},
+		{
+			name: "steps are broken down by their share of the job",
+			metadata: map[string]interface{}{
+				"steps": []interface{}{
+					map[string]interface{}{"name": "build", "result": "SUCCESS", "started": 100.0, "duration": 15.0},
+					map[string]interface{}{"name": "test", "result": "FAILURE", "started": 115.0, "duration": 30.0, "exit_code": 1.0},
+					map[string]interface{}{"name": "deploy", "result": "SKIPPED"},
+				},
+			},
+			elapsed: time.Minute,
+			expected: []stepView{
+				{Name: "build", Result: "SUCCESS", Class: "passed", Elapsed: 15 * time.Second, Percent: 25},
+				{Name: "test", Result: "FAILURE", Class: "failed", Elapsed: 30 * time.Second, Percent: 50},
+				{Name: "deploy", Result: "SKIPPED", Class: "skipped"},
+			},
+		},
+		{
+			name: "shares fall back to the time spent in steps",
+			metadata: map[string]interface{}{
+				"steps": []interface{}{
+					map[string]interface{}{"name": "test", "result": "TIMED_OUT", "duration": 30.0},
+					map[string]interface{}{"name": "cleanup", "result": "SUCCESS", "duration": 10.0},
+				},
+			},
+			expected: []stepView{
+				{Name: "test", Result: "TIMED_OUT", Class: "failed", Elapsed: 30 * time.Second, Percent: 75},
+				{Name: "cleanup", Result: "SUCCESS", Class: "passed", Elapsed: 10 * time.Second, Percent: 25},
+			},
+		},
+		{
+			name:     "invalid steps are ignored",
+			metadata: map[string]interface{}{"steps": "build,test"},
+			elapsed:  time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, stepsFromMetadata(tc.metadata, tc.elapsed)); diff != "" {
+				t.Errorf("unexpected steps (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podinfo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	k8sreporter "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes"
+)
+
+// imagePullReasons are the reasons a container waits for its image.
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// nodePressures are the node conditions that are only reported when
+// something is wrong.
+var nodePressures = []v1.NodeConditionType{
+	v1.NodeMemoryPressure,
+	v1.NodeDiskPressure,
+	v1.NodePIDPressure,
+	v1.NodeNetworkUnavailable,
+}
+
+// diagnosis collects human readable explanations of why a pod failed,
+// leaving out duplicates.
+type diagnosis struct {
+	seen     map[string]bool
+	findings []string
+}
+
+func (d *diagnosis) add(format string, args ...interface{}) {
+	finding := fmt.Sprintf(format, args...)
+	if d.seen[finding] {
+		return
+	}
+	if d.seen == nil {
+		d.seen = map[string]bool{}
+	}
+	d.seen[finding] = true
+	d.findings = append(d.findings, finding)
+}
+
+// diagnose explains why the pod of a job may have failed from the state of
+// its containers, its events and the conditions of its node. peakMemory holds
+// the highest memory usage of the containers by name, if it was recorded.
+func diagnose(report k8sreporter.PodReport, peakMemory map[string]int64) []string {
+	var d diagnosis
+	if pod := report.Pod; pod != nil {
+		switch pod.Status.Reason {
+		case "Evicted":
+			d.add("The pod was evicted: %s", pod.Status.Message)
+		case "DeadlineExceeded":
+			d.add("The pod ran longer than its active deadline.")
+		}
+		if pod.Spec.NodeName == "" {
+			if event := latestEvent(report.Events, "FailedScheduling"); event != nil {
+				d.add("The pod could not be scheduled: %s", event.Message)
+			}
+		}
+		containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			var limit *int64
+			for _, container := range containers {
+				if container.Name == status.Name {
+					if memory, ok := container.Resources.Limits[v1.ResourceMemory]; ok {
+						value := memory.Value()
+						limit = &value
+					}
+				}
+			}
+			peak, recorded := peakMemory[status.Name]
+			diagnoseContainer(&d, status, limit, peak, recorded)
+		}
+	}
+
+	for _, event := range report.Events {
+		switch {
+		case event.Reason == "FailedMount" || event.Reason == "FailedAttachVolume":
+			d.add("A volume could not be mounted: %s", event.Message)
+		case event.Reason == "Failed" && strings.Contains(event.Message, "Failed to pull image"):
+			d.add("%s", describeImagePull(event.Message))
+		}
+	}
+
+	if node := report.Node; node != nil {
+		for _, condition := range node.Conditions {
+			if condition.Type == v1.NodeReady && condition.Status != v1.ConditionTrue {
+				d.add("Node %s was not ready: %s", node.Name, condition.Message)
+			}
+			for _, pressure := range nodePressures {
+				if condition.Type == pressure && condition.Status == v1.ConditionTrue {
+					d.add("Node %s reported %s: %s", node.Name, condition.Type, condition.Message)
+				}
+			}
+		}
+	}
+	return d.findings
+}
+
+func diagnoseContainer(d *diagnosis, status v1.ContainerStatus, limit *int64, peak int64, peakRecorded bool) {
+	for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+		if terminated == nil || terminated.Reason != "OOMKilled" {
+			continue
+		}
+		finding := fmt.Sprintf("Container %q was killed by OOM", status.Name)
+		if peakRecorded {
+			finding += " at " + formatBytes(peak)
+		}
+		if limit != nil {
+			finding += ", limit " + formatBytes(*limit)
+		}
+		d.add("%s.", finding)
+	}
+
+	if last := status.LastTerminationState.Terminated; last != nil && last.ExitCode != 0 && last.Reason != "OOMKilled" {
+		d.add("Container %q restarted %d times, it last exited with code %d%s.", status.Name, status.RestartCount, last.ExitCode, describeTermination(last))
+	}
+	if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 && terminated.Message != "" && terminated.Reason != "OOMKilled" {
+		d.add("Container %q exited with code %d: %s", status.Name, terminated.ExitCode, strings.TrimSpace(terminated.Message))
+	}
+
+	if waiting := status.State.Waiting; waiting != nil {
+		switch {
+		case imagePullReasons[waiting.Reason]:
+			if isImageNotFound(waiting.Message) {
+				d.add("Image %q not found.", status.Image)
+			} else {
+				d.add("Image %q could not be pulled: %s", status.Image, waiting.Message)
+			}
+		case waiting.Reason == "CreateContainerConfigError" || waiting.Reason == "CreateContainerError":
+			d.add("Container %q could not be created: %s", status.Name, waiting.Message)
+		}
+	}
+}
+
+// describeTermination adds the reason and termination message of a
+// terminated container, if there are any.
+func describeTermination(terminated *v1.ContainerStateTerminated) string {
+	var description string
+	if terminated.Reason != "" {
+		description += " (" + terminated.Reason + ")"
+	}
+	if message := strings.TrimSpace(terminated.Message); message != "" {
+		description += ": " + message
+	}
+	return description
+}
+
+// describeImagePull explains a "Failed to pull image" event, e.g.:
+// Failed to pull image "gcr.io/k8s-testimages/nope": rpc error: code = NotFound desc = failed to pull and unpack image: not found
+func describeImagePull(message string) string {
+	image := message
+	if parts := strings.SplitN(message, `"`, 3); len(parts) == 3 {
+		image = parts[1]
+	}
+	if isImageNotFound(message) {
+		return fmt.Sprintf("Image %q not found.", image)
+	}
+	return message
+}
+
+func isImageNotFound(message string) bool {
+	return strings.Contains(message, "not found") || strings.Contains(message, "manifest unknown")
+}
+
+// latestEvent returns the most recent event with the given reason.
+func latestEvent(events []v1.Event, reason string) *v1.Event {
+	var latest *v1.Event
+	for i, event := range events {
+		if event.Reason != reason {
+			continue
+		}
+		if latest == nil || !event.LastTimestamp.Before(&latest.LastTimestamp) {
+			latest = &events[i]
+		}
+	}
+	return latest
+}
+
+// formatBytes formats memory in binary units with at most one decimal, e.g. 3.9Gi.
+func formatBytes(bytes int64) string {
+	units := []string{"", "Ki", "Mi", "Gi", "Ti"}
+	value := float64(bytes)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(math.Round(value*10)/10, 'f', -1, 64) + units[unit]
+}
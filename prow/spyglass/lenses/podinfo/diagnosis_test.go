@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8sreporter "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes"
+)
+
+func TestDiagnose(t *testing.T) {
+	testContainer := v1.Container{
+		Name:  "test",
+		Image: "golang:1.15",
+		Resources: v1.ResourceRequirements{
+			Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")},
+		},
+	}
+	now := time.Now()
+	testCases := []struct {
+		name       string
+		report     k8sreporter.PodReport
+		peakMemory map[string]int64
+		expected   []string
+	}{
+		{
+			name: "healthy pod",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec: v1.PodSpec{NodeName: "node", Containers: []v1.Container{testContainer}},
+					Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+						Name:  "test",
+						State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}},
+					}}},
+				},
+				Node: &k8sreporter.NodeReport{Name: "node", Conditions: []v1.NodeCondition{
+					{Type: v1.NodeReady, Status: v1.ConditionTrue},
+					{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+				}},
+			},
+		},
+		{
+			name: "OOM kill with recorded usage",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec: v1.PodSpec{NodeName: "node", Containers: []v1.Container{testContainer}},
+					Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+						Name:  "test",
+						State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+					}}},
+				},
+			},
+			peakMemory: map[string]int64{"test": 4187593113},
+			expected:   []string{`Container "test" was killed by OOM at 3.9Gi, limit 4Gi.`},
+		},
+		{
+			name: "restarted container without limit",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec: v1.PodSpec{NodeName: "node", Containers: []v1.Container{{Name: "test"}}},
+					Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+						Name:                 "test",
+						RestartCount:         1,
+						LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+					}}},
+				},
+			},
+			expected: []string{`Container "test" was killed by OOM.`},
+		},
+		{
+			name: "termination messages of current and previous containers",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec: v1.PodSpec{NodeName: "node", Containers: []v1.Container{testContainer}},
+					Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+						Name:                 "test",
+						RestartCount:         2,
+						State:                v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1, Message: "config missing\n"}},
+						LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 2, Message: "no such file"}},
+					}}},
+				},
+			},
+			expected: []string{
+				`Container "test" restarted 2 times, it last exited with code 2 (Error): no such file.`,
+				`Container "test" exited with code 1: config missing`,
+			},
+		},
+		{
+			name: "missing image",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec: v1.PodSpec{NodeName: "node", Containers: []v1.Container{testContainer}},
+					Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{{
+						Name:  "test",
+						Image: "golang:1.15",
+						State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: `Back-off pulling image "golang:1.15": manifest unknown`}},
+					}}},
+				},
+				Events: []v1.Event{{
+					Reason:  "Failed",
+					Message: `Failed to pull image "golang:1.15": rpc error: code = NotFound desc = failed to pull and unpack image: not found`,
+				}},
+			},
+			expected: []string{`Image "golang:1.15" not found.`},
+		},
+		{
+			name: "unschedulable pod",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{testContainer}}},
+				Events: []v1.Event{
+					{Reason: "FailedScheduling", Message: "0/3 nodes are available: 3 Insufficient cpu.", LastTimestamp: metav1.NewTime(now)},
+					{Reason: "FailedScheduling", Message: "0/3 nodes are available: 3 Insufficient memory.", LastTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+				},
+			},
+			expected: []string{"The pod could not be scheduled: 0/3 nodes are available: 3 Insufficient cpu."},
+		},
+		{
+			name: "evicted pod on an unhealthy node",
+			report: k8sreporter.PodReport{
+				Pod: &v1.Pod{
+					Spec:   v1.PodSpec{NodeName: "node"},
+					Status: v1.PodStatus{Reason: "Evicted", Message: "The node was low on resource: ephemeral-storage."},
+				},
+				Events: []v1.Event{{Reason: "FailedMount", Message: `MountVolume.SetUp failed for volume "gcs-credentials"`}},
+				Node: &k8sreporter.NodeReport{Name: "node", Conditions: []v1.NodeCondition{
+					{Type: v1.NodeReady, Status: v1.ConditionUnknown, Message: "Kubelet stopped posting node status."},
+					{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue, Message: "kubelet has disk pressure"},
+				}},
+			},
+			expected: []string{
+				"The pod was evicted: The node was low on resource: ephemeral-storage.",
+				`A volume could not be mounted: MountVolume.SetUp failed for volume "gcs-credentials"`,
+				"Node node was not ready: Kubelet stopped posting node status.",
+				"Node node reported DiskPressure: kubelet has disk pressure",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, diagnose(tc.report, tc.peakMemory)); diff != "" {
+				t.Errorf("diagnosis differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
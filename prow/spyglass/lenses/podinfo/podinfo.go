@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -30,6 +31,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	k8sreporter "k8s.io/test-infra/prow/crier/reporters/gcs/kubernetes"
 	"k8s.io/test-infra/prow/entrypoint"
+	"k8s.io/test-infra/prow/resourceusage"
 	"sigs.k8s.io/yaml"
 
 	"k8s.io/test-infra/prow/spyglass/api"
@@ -40,6 +42,8 @@ const (
 	name     = "podinfo"
 	title    = "Job Pod Info"
 	priority = 20
+
+	podInfoFile = "podinfo.json"
 )
 
 func init() {
@@ -84,6 +88,15 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 	}
 
 	artifact := artifacts[0]
+	peakMemory := map[string]int64{}
+	for _, a := range artifacts {
+		name := path.Base(a.JobPath())
+		if name == podInfoFile {
+			artifact = a
+		} else if strings.HasSuffix(name, resourceusage.FileName) {
+			readPeakMemory(a, peakMemory)
+		}
+	}
 
 	content, err := artifact.ReadAll()
 	if err != nil {
@@ -106,9 +119,11 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 	t := struct {
 		PodReport  k8sreporter.PodReport
 		Containers []containerInfo
+		Diagnosis  []string
 	}{
 		PodReport:  p,
 		Containers: append(assembleContainers(p.Pod.Spec.InitContainers, p.Pod.Status.InitContainerStatuses), assembleContainers(p.Pod.Spec.Containers, p.Pod.Status.ContainerStatuses)...),
+		Diagnosis:  diagnose(p, peakMemory),
 	}
 
 	var buf bytes.Buffer
@@ -119,6 +134,22 @@ func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string,
 	return buf.String()
 }
 
+// readPeakMemory records the peak memory usage of the container profiled in
+// the artifact, which is used to explain OOM kills.
+func readPeakMemory(artifact api.Artifact, peakMemory map[string]int64) {
+	content, err := artifact.ReadAll()
+	if err != nil {
+		logrus.WithError(err).Info("Couldn't read resource usage")
+		return
+	}
+	var profile resourceusage.Profile
+	if err := json.Unmarshal(content, &profile); err != nil {
+		logrus.WithError(err).Info("Couldn't unmarshal resource usage")
+		return
+	}
+	peakMemory[profile.Container] = profile.Peak().Memory
+}
+
 type containerInfo struct {
 	// Container is a container spec
 	Container *v1.Container
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resourceusage provides a Spyglass lens charting the CPU and
+// memory used by the test containers of a job against their requests
+// and limits.
+package resourceusage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/resourceusage"
+	"k8s.io/test-infra/prow/spyglass/api"
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+const (
+	name     = "resourceusage"
+	title    = "Resource Usage"
+	priority = 25
+
+	chartWidth  = 600
+	chartHeight = 150
+	// headroom leaves some space above the highest value on a chart.
+	headroom = 1.1
+)
+
+func init() {
+	lenses.RegisterLens(Lens{})
+}
+
+// Lens is the implementation of a resource usage rendering Spyglass lens.
+type Lens struct{}
+
+// Config returns the lens's configuration.
+func (lens Lens) Config() lenses.LensConfig {
+	return lenses.LensConfig{
+		Name:     name,
+		Title:    title,
+		Priority: priority,
+	}
+}
+
+// Header renders the content of <head> from template.html.
+func (lens Lens) Header(artifacts []api.Artifact, resourceDir string, config json.RawMessage) string {
+	output, err := renderTemplate(resourceDir, "header", nil)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to render header")
+		return "Error: " + err.Error()
+	}
+	return output
+}
+
+// Callback does nothing.
+func (lens Lens) Callback(artifacts []api.Artifact, resourceDir string, data string, config json.RawMessage) string {
+	return ""
+}
+
+// Body renders one chart per resource and test container.
+func (lens Lens) Body(artifacts []api.Artifact, resourceDir string, data string, config json.RawMessage) string {
+	var containers []container
+	for _, artifact := range artifacts {
+		content, err := artifact.ReadAll()
+		if err != nil {
+			logrus.WithError(err).WithField("artifact", artifact.JobPath()).Warn("Failed to read resource usage")
+			continue
+		}
+		var profile resourceusage.Profile
+		if err := json.Unmarshal(content, &profile); err != nil {
+			logrus.WithError(err).WithField("artifact", artifact.JobPath()).Warn("Failed to parse resource usage")
+			continue
+		}
+		if profile.Container == "" {
+			profile.Container = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(artifact.JobPath()), resourceusage.FileName), "-")
+		}
+		containers = append(containers, containerFor(profile))
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	output, err := renderTemplate(resourceDir, "body", struct{ Containers []container }{containers})
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to render body")
+		return "Error: " + err.Error()
+	}
+	return output
+}
+
+// container is the view of the profile of a single test container.
+type container struct {
+	Name     string
+	Duration time.Duration
+	Samples  int
+	Charts   []chart
+}
+
+// chart plots the usage of one resource over the course of a run.
+type chart struct {
+	Resource string
+	Width    int
+	Height   int
+	// Points are the samples in the SVG polyline format.
+	Points string
+	// Request and Limit are the heights of the horizontal lines drawn for
+	// the resource requirements of the container. They are nil if the
+	// container does not request or limit the resource.
+	Request *int
+	Limit   *int
+
+	RequestValue string
+	LimitValue   string
+	Median       string
+	P95          string
+	Peak         string
+}
+
+func containerFor(profile resourceusage.Profile) container {
+	c := container{
+		Name:    profile.Container,
+		Samples: len(profile.Samples),
+	}
+	if len(profile.Samples) > 1 {
+		c.Duration = profile.Samples[len(profile.Samples)-1].Time.Sub(profile.Samples[0].Time).Round(time.Second)
+	}
+	cpu := make([]float64, 0, len(profile.Samples))
+	memory := make([]float64, 0, len(profile.Samples))
+	for _, sample := range profile.Samples {
+		cpu = append(cpu, sample.CPU)
+		memory = append(memory, float64(sample.Memory))
+	}
+	c.Charts = []chart{
+		chartFor("CPU", profile.Samples, cpu, profile.Requests.CPU, profile.Limits.CPU, formatCPU),
+		chartFor("Memory", profile.Samples, memory, float64(profile.Requests.Memory), float64(profile.Limits.Memory), formatMemory),
+	}
+	return c
+}
+
+func chartFor(resource string, samples []resourceusage.Sample, values []float64, request, limit float64, format func(float64) string) chart {
+	c := chart{
+		Resource: resource,
+		Width:    chartWidth,
+		Height:   chartHeight,
+		Median:   format(resourceusage.Percentile(values, 50)),
+		P95:      format(resourceusage.Percentile(values, 95)),
+		Peak:     format(resourceusage.Percentile(values, 100)),
+	}
+
+	top := resourceusage.Percentile(values, 100)
+	if request > top {
+		top = request
+	}
+	if limit > top {
+		top = limit
+	}
+	top *= headroom
+	if top == 0 {
+		top = 1
+	}
+	y := func(value float64) int {
+		return chartHeight - int(value/top*chartHeight)
+	}
+
+	if request > 0 {
+		height := y(request)
+		c.Request = &height
+		c.RequestValue = format(request)
+	}
+	if limit > 0 {
+		height := y(limit)
+		c.Limit = &height
+		c.LimitValue = format(limit)
+	}
+
+	if len(samples) == 0 {
+		return c
+	}
+	start, end := samples[0].Time, samples[len(samples)-1].Time
+	span := end.Sub(start)
+	points := make([]string, 0, len(samples))
+	for i, sample := range samples {
+		x := 0
+		if span > 0 {
+			x = int(float64(sample.Time.Sub(start)) / float64(span) * chartWidth)
+		}
+		points = append(points, fmt.Sprintf("%d,%d", x, y(values[i])))
+	}
+	c.Points = strings.Join(points, " ")
+	return c
+}
+
+func formatCPU(cores float64) string {
+	return fmt.Sprintf("%.2f cores", cores)
+}
+
+func formatMemory(memory float64) string {
+	return fmt.Sprintf("%.0f MiB", memory/(1<<20))
+}
+
+func renderTemplate(resourceDir, block string, params interface{}) (string, error) {
+	t, err := template.ParseFiles(filepath.Join(resourceDir, "template.html"))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, block, params); err != nil {
+		return "", fmt.Errorf("failed to execute template: %v", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resourceusage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"k8s.io/test-infra/prow/resourceusage"
+	"k8s.io/test-infra/prow/spyglass/api"
+)
+
+type fakeArtifact struct {
+	path    string
+	content []byte
+}
+
+func (fa *fakeArtifact) JobPath() string       { return fa.path }
+func (fa *fakeArtifact) Size() (int64, error)  { return int64(len(fa.content)), nil }
+func (fa *fakeArtifact) CanonicalLink() string { return fa.path }
+func (fa *fakeArtifact) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader(fa.content).ReadAt(b, off)
+}
+func (fa *fakeArtifact) ReadAll() ([]byte, error)             { return ioutil.ReadAll(bytes.NewReader(fa.content)) }
+func (fa *fakeArtifact) ReadAtMost(n int64) ([]byte, error)   { return fa.content, nil }
+func (fa *fakeArtifact) ReadTail(n int64) ([]byte, error)     { return nil, nil }
+func (fa *fakeArtifact) UseContext(ctx context.Context) error { return nil }
+
+func TestContainerFor(t *testing.T) {
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	profile := resourceusage.Profile{
+		Container: "test",
+		Resources: resourceusage.Resources{
+			Requests: resourceusage.Quantities{CPU: 1, Memory: 1 << 30},
+			Limits:   resourceusage.Quantities{Memory: 2 << 30},
+		},
+		Samples: []resourceusage.Sample{
+			{Time: start, CPU: 0.5, Memory: 512 << 20},
+			{Time: start.Add(30 * time.Second), CPU: 2, Memory: 1 << 30},
+			{Time: start.Add(60 * time.Second), CPU: 1, Memory: 768 << 20},
+		},
+	}
+	request, limit := 82, 14
+	cpuRequest := 82
+	expected := container{
+		Name:     "test",
+		Duration: time.Minute,
+		Samples:  3,
+		Charts: []chart{
+			{
+				Resource:     "CPU",
+				Width:        chartWidth,
+				Height:       chartHeight,
+				Points:       "0,116 300,14 600,82",
+				Request:      &cpuRequest,
+				RequestValue: "1.00 cores",
+				Median:       "1.00 cores",
+				P95:          "2.00 cores",
+				Peak:         "2.00 cores",
+			},
+			{
+				Resource:     "Memory",
+				Width:        chartWidth,
+				Height:       chartHeight,
+				Points:       "0,116 300,82 600,99",
+				Request:      &request,
+				Limit:        &limit,
+				RequestValue: "1024 MiB",
+				LimitValue:   "2048 MiB",
+				Median:       "768 MiB",
+				P95:          "1024 MiB",
+				Peak:         "1024 MiB",
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, containerFor(profile)); diff != "" {
+		t.Errorf("containerFor() differs from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestBody(t *testing.T) {
+	profile := resourceusage.Profile{
+		Samples: []resourceusage.Sample{{Time: time.Now(), CPU: 0.25, Memory: 1 << 20}},
+	}
+	raw, err := json.Marshal(profile)
+	if err != nil {
+		t.Fatalf("failed to marshal profile: %v", err)
+	}
+	artifacts := []api.Artifact{
+		&fakeArtifact{path: "build/resource-usage.json", content: raw},
+		&fakeArtifact{path: "build/other-resource-usage.json", content: []byte("not json")},
+	}
+	body := Lens{}.Body(artifacts, ".", "", nil)
+	for _, expected := range []string{"<polyline", "0.25 cores", "1 MiB"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected body to contain %q, got:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, "No resource usage") {
+		t.Errorf("expected the valid profile to be rendered, got:\n%s", body)
+	}
+}
@@ -38,6 +38,7 @@ import (
 	pkgio "k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/io/providers"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/retention"
 	"k8s.io/test-infra/prow/spyglass/api"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 )
@@ -48,6 +49,9 @@ const (
 	prowKeyType = api.ProwKeyType
 )
 
+// prowJobFile is the artifact the GCS reporter of crier writes the ProwJob to.
+const prowJobFile = "prowjob.json"
+
 // Spyglass records which sets of artifacts need views for a Prow job. The metaphor
 // can be understood as follows: A spyglass receives light from a source through
 // an eyepiece, which has a lens that ultimately presents a view of the light source
@@ -450,6 +454,75 @@ func (sg *Spyglass) ExtraLinks(ctx context.Context, src string) ([]ExtraLink, er
 	return extraLinks, nil
 }
 
+// Retention returns the expiry marker written by the artifact retention
+// controller and the pin written by Deck for the run specified in src.
+// Either is nil if the artifacts of the run have not expired or the run is
+// not pinned.
+func (sg *Spyglass) Retention(ctx context.Context, src string) (*retention.Expired, *retention.Pin, error) {
+	artifacts, err := sg.FetchArtifacts(ctx, src, "", 1000000, []string{retention.ExpiredFile, retention.PinFile})
+	if err != nil {
+		return nil, nil, err
+	}
+	var expired *retention.Expired
+	var pin *retention.Pin
+	for _, artifact := range artifacts {
+		content, err := artifact.ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch path.Base(artifact.JobPath()) {
+		case retention.ExpiredFile:
+			expired = &retention.Expired{}
+			err = json.Unmarshal(content, expired)
+		case retention.PinFile:
+			pin = &retention.Pin{}
+			err = json.Unmarshal(content, pin)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", artifact.JobPath(), err)
+		}
+	}
+	return expired, pin, nil
+}
+
+// ProwJob returns the ProwJob of the run specified in src. It is taken from
+// the job agent while the agent knows the run and from the prowjob.json
+// artifact after that. It returns nil if neither has the ProwJob.
+func (sg *Spyglass) ProwJob(ctx context.Context, src string) (*prowv1.ProwJob, error) {
+	src = strings.TrimSuffix(src, "/")
+	keyType, key, err := splitSrc(src)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing src: %v", src)
+	}
+	split := strings.Split(key, "/")
+	if len(split) < 2 {
+		return nil, fmt.Errorf("invalid key %s: expected <job-name>/<build-id>", key)
+	}
+	job, err := sg.jobAgent.GetProwJob(split[len(split)-2], split[len(split)-1])
+	if err == nil {
+		return &job, nil
+	}
+	if !jobs.IsErrProwJobNotFound(err) {
+		return nil, err
+	}
+	if keyType == prowKeyType {
+		return nil, nil
+	}
+	artifacts, err := sg.FetchArtifacts(ctx, src, "", 1000000, []string{prowJobFile})
+	if err != nil || len(artifacts) == 0 {
+		return nil, err
+	}
+	content, err := artifacts[0].ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	pj := &prowv1.ProwJob{}
+	if err := json.Unmarshal(content, pj); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", prowJobFile, err)
+	}
+	return pj, nil
+}
+
 // TestGridLink returns a link to a relevant TestGrid tab for the given source string.
 // Because there is a one-to-many mapping from job names to TestGrid tabs, the returned tab
 // link may not be deterministic.
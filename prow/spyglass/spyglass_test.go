@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	coreapi "k8s.io/api/core/v1"
 	"k8s.io/test-infra/prow/gcsupload"
@@ -41,6 +42,7 @@ import (
 	"k8s.io/test-infra/prow/deck/jobs"
 	"k8s.io/test-infra/prow/io"
 	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/retention"
 	"k8s.io/test-infra/prow/spyglass/api"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 	"k8s.io/test-infra/prow/spyglass/lenses/common"
@@ -1579,3 +1581,144 @@ func TestExtraLinks(t *testing.T) {
 		})
 	}
 }
+
+func TestRetention(t *testing.T) {
+	testCases := []struct {
+		name            string
+		objects         map[string]string
+		expectedExpired *retention.Expired
+		expectedPin     *retention.Pin
+		expectErr       bool
+	}{
+		{
+			name: "neither expired nor pinned",
+		},
+		{
+			name: "expired",
+			objects: map[string]string{
+				retention.ExpiredFile: `{"time":"2021-06-30T12:00:00Z","max_age":"720h0m0s","kept":["finished.json"]}`,
+			},
+			expectedExpired: &retention.Expired{
+				Time:   time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC),
+				MaxAge: "720h0m0s",
+				Kept:   []string{"finished.json"},
+			},
+		},
+		{
+			name: "pinned",
+			objects: map[string]string{
+				retention.PinFile: `{"user":"investigator","reason":"flake","time":"2021-06-01T00:00:00Z"}`,
+			},
+			expectedPin: &retention.Pin{User: "investigator", Reason: "flake", Time: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "malformed pin",
+			objects: map[string]string{
+				retention.PinFile: "not json",
+			},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []fakestorage.Object
+			for name, content := range tc.objects {
+				objects = append(objects, fakestorage.Object{
+					BucketName: "test-bucket",
+					Name:       "logs/some-job/42/" + name,
+					Content:    []byte(content),
+				})
+			}
+			gcsServer := fakestorage.NewServer(objects)
+			defer gcsServer.Stop()
+
+			fakeConfigAgent := fca{}
+			fakeJa = jobs.NewJobAgent(context.Background(), fkc{}, false, true, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA"), "trusted": fpkc("clusterB")}, fakeConfigAgent.Config)
+			fakeJa.Start()
+			sg := New(context.Background(), fakeJa, fakeConfigAgent.Config, io.NewGCSOpener(gcsServer.Client()), false)
+
+			expired, pin, err := sg.Retention(context.Background(), "gcs/test-bucket/logs/some-job/42")
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectErr, err)
+			}
+			if !reflect.DeepEqual(expired, tc.expectedExpired) {
+				t.Errorf("expected expired %#v, got %#v", tc.expectedExpired, expired)
+			}
+			if !reflect.DeepEqual(pin, tc.expectedPin) {
+				t.Errorf("expected pin %#v, got %#v", tc.expectedPin, pin)
+			}
+		})
+	}
+}
+
+func TestProwJob(t *testing.T) {
+	testCases := []struct {
+		name      string
+		jobs      fkc
+		objects   map[string]string
+		expected  *prowapi.ProwJob
+		expectErr bool
+	}{
+		{
+			name: "unknown run",
+		},
+		{
+			name: "run known to the job agent",
+			jobs: fkc{prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "running"},
+				Spec:       prowapi.ProwJobSpec{Job: "some-job", Hidden: true},
+				Status:     prowapi.ProwJobStatus{BuildID: "42"},
+			}},
+			expected: &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "running"},
+				Spec:       prowapi.ProwJobSpec{Job: "some-job", Hidden: true},
+				Status:     prowapi.ProwJobStatus{BuildID: "42"},
+			},
+		},
+		{
+			name: "run from storage",
+			objects: map[string]string{
+				"prowjob.json": `{"metadata":{"name":"archived"},"spec":{"job":"some-job","hidden":true},"status":{"build_id":"42"}}`,
+			},
+			expected: &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "archived"},
+				Spec:       prowapi.ProwJobSpec{Job: "some-job", Hidden: true},
+				Status:     prowapi.ProwJobStatus{BuildID: "42"},
+			},
+		},
+		{
+			name: "malformed prowjob.json",
+			objects: map[string]string{
+				"prowjob.json": "not json",
+			},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []fakestorage.Object
+			for name, content := range tc.objects {
+				objects = append(objects, fakestorage.Object{
+					BucketName: "test-bucket",
+					Name:       "logs/some-job/42/" + name,
+					Content:    []byte(content),
+				})
+			}
+			gcsServer := fakestorage.NewServer(objects)
+			defer gcsServer.Stop()
+
+			fakeConfigAgent := fca{}
+			fakeJa = jobs.NewJobAgent(context.Background(), tc.jobs, false, true, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA"), "trusted": fpkc("clusterB")}, fakeConfigAgent.Config)
+			fakeJa.Start()
+			sg := New(context.Background(), fakeJa, fakeConfigAgent.Config, io.NewGCSOpener(gcsServer.Client()), false)
+
+			pj, err := sg.ProwJob(context.Background(), "gcs/test-bucket/logs/some-job/42")
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectErr, err)
+			}
+			if !reflect.DeepEqual(pj, tc.expected) {
+				t.Errorf("expected ProwJob %#v, got %#v", tc.expected, pj)
+			}
+		})
+	}
+}
@@ -45,6 +45,9 @@ type History struct {
 	sync.Mutex
 	logSizeLimit int
 
+	// stats aggregates the actions per repo and day, see stats.go.
+	stats *stats
+
 	opener opener
 	path   string
 }
@@ -89,6 +92,14 @@ func readHistory(maxRecordsPerKey int, opener opener, path string) (map[string]*
 }
 
 func writeHistory(opener opener, path string, hist map[string][]*Record) error {
+	b, err := json.Marshal(hist)
+	if err != nil {
+		return fmt.Errorf("marshal: %v", err)
+	}
+	return writeRaw(opener, path, b)
+}
+
+func writeRaw(opener opener, path string, b []byte) error {
 	// a write's duration will scale with the volume of data to write but large
 	// data sets can finish in about 500ms; a timeout of 30s should not evict
 	// well-behaved writes
@@ -98,10 +109,6 @@ func writeHistory(opener opener, path string, hist map[string][]*Record) error {
 	if err != nil {
 		return fmt.Errorf("open: %v", err)
 	}
-	b, err := json.Marshal(hist)
-	if err != nil {
-		return fmt.Errorf("marshal: %v", err)
-	}
 	if _, err := fmt.Fprint(writer, string(b)); err != nil {
 		io.LogClose(writer)
 		return fmt.Errorf("write: %v", err)
@@ -126,6 +133,7 @@ func New(maxRecordsPerKey int, opener io.Opener, path string) (*History, error)
 	hist := &History{
 		logs:         map[string]*recordLog{},
 		logSizeLimit: maxRecordsPerKey,
+		stats:        newStats(),
 		opener:       opener,
 		path:         path,
 	}
@@ -142,6 +150,11 @@ func New(maxRecordsPerKey int, opener io.Opener, path string) (*History, error)
 			"duration": time.Since(start).String(),
 			"path":     hist.path,
 		}).Debugf("Successfully read action history for %d pools.", len(hist.logs))
+
+		hist.stats, err = readStats(hist.opener, statsPath(hist.path))
+		if err != nil {
+			return nil, fmt.Errorf("stats: %v", err)
+		}
 	}
 
 	return hist, nil
@@ -151,6 +164,11 @@ func New(maxRecordsPerKey int, opener io.Opener, path string) (*History, error)
 func (h *History) Record(poolKey, action, baseSHA, err string, targets []prowapi.Pull) {
 	t := now()
 	sort.Sort(ByNum(targets))
+	if err == "" {
+		h.Lock()
+		h.stats.record(repoFromPoolKey(poolKey), action, targets, t)
+		h.Unlock()
+	}
 	h.addRecord(
 		poolKey,
 		&Record{
@@ -186,6 +204,9 @@ func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Flush writes the action history to persistent storage if configured to do so.
 func (h *History) Flush() {
+	h.Lock()
+	h.stats.prune(now())
+	h.Unlock()
 	if h.path == "" {
 		return
 	}
@@ -201,6 +222,9 @@ func (h *History) Flush() {
 	} else {
 		log.Debugf("Successfully flushed action history for %d pools.", len(h.logs))
 	}
+	if err := h.flushStats(); err != nil {
+		log.WithError(err).Error("Error flushing action history stats.")
+	}
 }
 
 // AllRecords generates a map from pool key -> sorted records for the pool.
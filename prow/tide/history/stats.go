@@ -0,0 +1,263 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/io"
+)
+
+const (
+	// dayFormat is the format of the keys of the daily stats.
+	dayFormat = "2006-01-02"
+	// statsRetention is how long daily stats are kept.
+	statsRetention = 90 * 24 * time.Hour
+	// prRetention is how long a PR that is no longer seen in a pool is
+	// remembered before it is considered closed or unapproved.
+	prRetention = 7 * 24 * time.Hour
+	// maxObservationGap caps the time between two observations of a PR that is
+	// counted as blocked time. Larger gaps are caused by Tide restarts or by
+	// the PR leaving the pool and are not accounted for.
+	maxObservationGap = 10 * time.Minute
+)
+
+// DayStats aggregates the actions Tide took for a repo on a single day.
+type DayStats struct {
+	// Merged is the number of PRs merged.
+	Merged int `json:"merged"`
+	// ApprovalToMergeSeconds is the sum of the time the merged PRs spent in
+	// the pool, i.e. from the first time Tide saw them as approved until merge.
+	ApprovalToMergeSeconds float64 `json:"approvalToMergeSeconds"`
+	// Retests is the sum of the number of times Tide triggered tests for the
+	// merged PRs after the first run.
+	Retests int `json:"retests"`
+	// BatchesTriggered and BatchesMerged count batch tests and batch merges.
+	BatchesTriggered int `json:"batchesTriggered"`
+	BatchesMerged    int `json:"batchesMerged"`
+	// BlockedSeconds maps status contexts to the PR seconds in the pool that
+	// were blocked by the context failing. When several contexts fail at the
+	// same time the blocked time is split evenly between them.
+	BlockedSeconds map[string]float64 `json:"blockedSeconds,omitempty"`
+}
+
+// prState is what is known about a PR that is in a pool.
+type prState struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Triggers  int       `json:"triggers"`
+}
+
+// stats is the persisted form of the statistics.
+type stats struct {
+	// PRs maps "org/repo#number" to the PRs currently in a pool.
+	PRs map[string]*prState `json:"prs"`
+	// Days maps "org/repo" to the day in dayFormat to the stats of the day.
+	Days map[string]map[string]*DayStats `json:"days"`
+}
+
+func newStats() *stats {
+	return &stats{
+		PRs:  map[string]*prState{},
+		Days: map[string]map[string]*DayStats{},
+	}
+}
+
+// repoFromPoolKey returns the org/repo part of a pool key.
+func repoFromPoolKey(poolKey string) string {
+	return strings.SplitN(poolKey, ":", 2)[0]
+}
+
+func prStateKey(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", repo, number)
+}
+
+func (s *stats) day(repo string, t time.Time) *DayStats {
+	if s.Days[repo] == nil {
+		s.Days[repo] = map[string]*DayStats{}
+	}
+	key := t.UTC().Format(dayFormat)
+	if s.Days[repo][key] == nil {
+		s.Days[repo][key] = &DayStats{}
+	}
+	return s.Days[repo][key]
+}
+
+func (s *stats) pr(repo string, number int, t time.Time) *prState {
+	key := prStateKey(repo, number)
+	if s.PRs[key] == nil {
+		s.PRs[key] = &prState{FirstSeen: t, LastSeen: t}
+	}
+	return s.PRs[key]
+}
+
+func (s *stats) observe(repo string, number int, blockedBy []string, t time.Time) {
+	pr := s.pr(repo, number, t)
+	if gap := t.Sub(pr.LastSeen); len(blockedBy) > 0 && gap > 0 && gap <= maxObservationGap {
+		day := s.day(repo, t)
+		if day.BlockedSeconds == nil {
+			day.BlockedSeconds = map[string]float64{}
+		}
+		for _, context := range blockedBy {
+			day.BlockedSeconds[context] += gap.Seconds() / float64(len(blockedBy))
+		}
+	}
+	pr.LastSeen = t
+}
+
+func (s *stats) record(repo, action string, targets []prowapi.Pull, t time.Time) {
+	switch action {
+	case "TRIGGER":
+		for _, target := range targets {
+			s.pr(repo, target.Number, t).Triggers++
+		}
+	case "TRIGGER_BATCH":
+		s.day(repo, t).BatchesTriggered++
+	case "MERGE", "MERGE_BATCH":
+		day := s.day(repo, t)
+		if action == "MERGE_BATCH" {
+			day.BatchesMerged++
+		}
+		for _, target := range targets {
+			key := prStateKey(repo, target.Number)
+			pr := s.PRs[key]
+			if pr == nil {
+				// The PR was merged before it was ever observed,
+				// so there is nothing to account for.
+				continue
+			}
+			day.Merged++
+			day.ApprovalToMergeSeconds += t.Sub(pr.FirstSeen).Seconds()
+			if pr.Triggers > 1 {
+				day.Retests += pr.Triggers - 1
+			}
+			delete(s.PRs, key)
+		}
+	}
+}
+
+// prune forgets PRs that have not been seen for a while and old daily stats.
+func (s *stats) prune(t time.Time) {
+	for key, pr := range s.PRs {
+		if t.Sub(pr.LastSeen) > prRetention {
+			delete(s.PRs, key)
+		}
+	}
+	oldest := t.Add(-statsRetention).UTC().Format(dayFormat)
+	for repo, days := range s.Days {
+		for day := range days {
+			if day < oldest {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(s.Days, repo)
+		}
+	}
+}
+
+// statsPath returns the path the stats are persisted at next to the records.
+func statsPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + "-stats.json"
+}
+
+func readStats(opener opener, path string) (*stats, error) {
+	reader, err := opener.Reader(context.Background(), path)
+	if io.IsNotExist(err) { // No stats exist yet. This is not an error.
+		return newStats(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: %v", err)
+	}
+	defer io.LogClose(reader)
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+	s := newStats()
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("unmarshal: %v", err)
+	}
+	if s.PRs == nil {
+		s.PRs = map[string]*prState{}
+	}
+	if s.Days == nil {
+		s.Days = map[string]map[string]*DayStats{}
+	}
+	return s, nil
+}
+
+// ObservePR notes that the PR is in the pool at the current time. blockedBy
+// lists the status contexts whose failure currently keeps the PR from
+// merging; the time since the PR was last observed is accounted to them.
+func (h *History) ObservePR(poolKey string, number int, blockedBy []string) {
+	t := now()
+	h.Lock()
+	defer h.Unlock()
+	h.stats.observe(repoFromPoolKey(poolKey), number, blockedBy, t)
+}
+
+// AllStats generates a map from org/repo -> day -> stats of the day.
+func (h *History) AllStats() map[string]map[string]DayStats {
+	h.Lock()
+	defer h.Unlock()
+
+	res := make(map[string]map[string]DayStats, len(h.stats.Days))
+	for repo, days := range h.stats.Days {
+		res[repo] = make(map[string]DayStats, len(days))
+		for day, stats := range days {
+			copied := *stats
+			copied.BlockedSeconds = make(map[string]float64, len(stats.BlockedSeconds))
+			for context, seconds := range stats.BlockedSeconds {
+				copied.BlockedSeconds[context] = seconds
+			}
+			res[repo][day] = copied
+		}
+	}
+	return res
+}
+
+// ServeStats serves a JSON mapping from org/repo -> day -> stats of the day.
+func (h *History) ServeStats(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(h.AllStats())
+	if err != nil {
+		logrus.WithError(err).Error("Encoding JSON history stats.")
+		b = []byte("{}")
+	}
+	if _, err = w.Write(b); err != nil {
+		logrus.WithError(err).Debug("Writing JSON history stats response.")
+	}
+}
+
+func (h *History) flushStats() error {
+	h.Lock()
+	b, err := json.Marshal(h.stats)
+	h.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshal: %v", err)
+	}
+	return writeRaw(h.opener, statsPath(h.path), b)
+}
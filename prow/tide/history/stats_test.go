@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestStats(t *testing.T) {
+	nowTime := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldNow := now
+	now = func() time.Time { return nowTime }
+	defer func() { now = oldNow }()
+	advance := func(d time.Duration) { nowTime = nowTime.Add(d) }
+
+	hist, err := New(10, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create history client: %v", err)
+	}
+	pr := func(num int) []prowapi.Pull { return []prowapi.Pull{{Number: num}} }
+
+	hist.ObservePR("o/r:master", 1, nil)
+	hist.ObservePR("o/r:master", 2, nil)
+	hist.Record("o/r:master", "TRIGGER", "sha", "", pr(1))
+	advance(5 * time.Minute)
+	// Both contexts failed during the last 5 minutes.
+	hist.ObservePR("o/r:master", 1, []string{"a", "b"})
+	hist.ObservePR("o/r:master", 2, []string{"a"})
+	advance(20 * time.Minute)
+	// The gap is too large to be accounted for.
+	hist.ObservePR("o/r:master", 1, []string{"a"})
+	hist.Record("o/r:master", "TRIGGER", "sha", "", pr(1))
+	hist.Record("o/r:master", "TRIGGER", "sha", "", pr(1))
+	// Failed actions are not accounted for.
+	hist.Record("o/r:master", "MERGE", "sha", "merge failed", pr(1))
+	advance(5 * time.Minute)
+	hist.Record("o/r:master", "MERGE", "sha", "", pr(1))
+	hist.Record("o/r:release", "TRIGGER_BATCH", "sha", "", []prowapi.Pull{{Number: 2}, {Number: 3}})
+	hist.Record("o/r:release", "TRIGGER_BATCH", "sha", "", []prowapi.Pull{{Number: 2}, {Number: 3}})
+	// PR 3 was never observed, only PR 2 is accounted for.
+	hist.Record("o/r:release", "MERGE_BATCH", "sha", "", []prowapi.Pull{{Number: 2}, {Number: 3}})
+
+	expected := map[string]map[string]DayStats{
+		"o/r": {
+			"2021-03-01": {
+				Merged:                 2,
+				ApprovalToMergeSeconds: 2 * 30 * 60,
+				Retests:                2,
+				BatchesTriggered:       2,
+				BatchesMerged:          1,
+				BlockedSeconds:         map[string]float64{"a": 150 + 300, "b": 150},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, hist.AllStats()); diff != "" {
+		t.Errorf("Unexpected stats (-want +got):\n%s", diff)
+	}
+	if len(hist.stats.PRs) != 0 {
+		t.Errorf("Expected merged PRs to be forgotten, got %v", hist.stats.PRs)
+	}
+
+	// PRs that are no longer seen and old days are pruned.
+	hist.ObservePR("o/r:master", 4, nil)
+	advance(statsRetention + 24*time.Hour)
+	hist.Flush()
+	if stats := hist.AllStats(); len(stats) != 0 {
+		t.Errorf("Expected stats to be pruned, got %v", stats)
+	}
+	if len(hist.stats.PRs) != 0 {
+		t.Errorf("Expected PRs to be pruned, got %v", hist.stats.PRs)
+	}
+}
+
+func TestReadWriteStats(t *testing.T) {
+	s := newStats()
+	s.PRs["o/r#1"] = &prState{FirstSeen: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), LastSeen: time.Date(2021, 3, 1, 13, 0, 0, 0, time.UTC), Triggers: 2}
+	s.day("o/r", time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)).Merged = 3
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Failed to marshal stats: %v", err)
+	}
+
+	obj := &testOpener{}
+	if err := writeRaw(obj, fakePath, raw); err != nil {
+		t.Fatalf("Unexpected error writing stats: %v.", err)
+	}
+	obj.closed = false
+	read, err := readStats(obj, fakePath)
+	if err != nil {
+		t.Fatalf("Unexpected error reading stats: %v.", err)
+	}
+	if diff := cmp.Diff(s, read, cmp.AllowUnexported(stats{})); diff != "" {
+		t.Errorf("Unexpected stats after round trip (-want +got):\n%s", diff)
+	}
+
+	read, err = readStats(&testOpener{dne: true}, fakePath)
+	if err != nil {
+		t.Fatalf("Unexpected error reading non-existent stats: %v.", err)
+	}
+	if diff := cmp.Diff(newStats(), read, cmp.AllowUnexported(stats{})); diff != "" {
+		t.Errorf("Unexpected stats for non-existent file (-want +got):\n%s", diff)
+	}
+}
+
+func TestStatsPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"gs://bucket/tide/history.json": "gs://bucket/tide/history-stats.json",
+		"/local/history":                "/local/history-stats.json",
+	} {
+		if actual := statsPath(path); actual != expected {
+			t.Errorf("statsPath(%q) = %q, expected %q", path, actual, expected)
+		}
+	}
+}
@@ -537,7 +537,12 @@ func (c *Controller) filterSubpools(mergeAllowed func(*PullRequest) (string, err
 				return
 			}
 			key := poolKey(sp.org, sp.repo, sp.branch)
-			if spFiltered := filterSubpool(c.ghc, mergeAllowed, sp); spFiltered != nil {
+			prs := sp.prs
+			spFiltered := filterSubpool(c.ghc, mergeAllowed, sp)
+			for _, pr := range prs {
+				c.History.ObservePR(key, int(pr.Number), sp.failingContexts[int(pr.Number)])
+			}
+			if spFiltered != nil {
 				sp.log.WithField("key", key).WithField("pool", spFiltered).Debug("filtered sub-pool")
 
 				lock.Lock()
@@ -620,18 +625,30 @@ func filterPR(ghc githubClient, mergeAllowed func(*PullRequest) (string, error),
 		}
 		return false
 	}
+	// All failing contexts are collected so that the blocked time can be
+	// accounted to them in the history stats.
+	var failing []string
+	var filtered bool
 	for _, ctx := range unsuccessfulContexts(contexts, sp.cc[int(pr.Number)], log) {
 		if ctx.State != githubql.StatusStatePending {
 			log.WithField("context", ctx.Context).Debug("filtering out PR as unsuccessful context is not pending")
-			return true
+			failing = append(failing, string(ctx.Context))
+			filtered = true
+			continue
 		}
 		if !presubmitsHaveContext(string(ctx.Context)) {
 			log.WithField("context", ctx.Context).Debug("filtering out PR as unsuccessful context is not Prow-controlled")
-			return true
+			filtered = true
+		}
+	}
+	if len(failing) > 0 {
+		if sp.failingContexts == nil {
+			sp.failingContexts = map[int][]string{}
 		}
+		sp.failingContexts[int(pr.Number)] = failing
 	}
 
-	return false
+	return filtered
 }
 
 // mergeChecker provides a function to check if a PR can be merged with
@@ -1629,6 +1646,9 @@ type subpool struct {
 	// presubmit contains all required presubmits for each PR
 	// in this subpool
 	presubmits map[int][]config.Presubmit
+	// failingContexts contains the failing status contexts that
+	// filtered a PR out of this subpool
+	failingContexts map[int][]string
 }
 
 func poolKey(org, repo, branch string) string {
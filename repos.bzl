@@ -157,6 +157,16 @@ def go_repositories():
         sum = "h1:uZuxRZCz65cG1o6K/xUqImNcYKtmk9ylqaH0itMSvzA=",
         version = "v0.0.0-20180407024304-ca021399b1a6",
     )
+
+    go_repository(
+        name = "com_github_antlr_antlr4",
+        build_file_generation = "on",
+        build_file_proto_mode = "disable",
+        importpath = "github.com/antlr/antlr4",
+        sum = "h1:0cEys61Sr2hUBEXfNV8eyQP01oZuBgoMeHunebPirK8=",
+        version = "v0.0.0-20200503195918-621b933c7a7f",
+    )
+
     go_repository(
         name = "com_github_apache_thrift",
         build_file_generation = "on",
@@ -1886,6 +1896,16 @@ def go_repositories():
         sum = "h1:0udJVsspx3VBr5FwtLhQQtuAsVc79tTq0ocGIPAU6qo=",
         version = "v1.0.0",
     )
+
+    go_repository(
+        name = "com_github_google_cel_go",
+        build_file_generation = "on",
+        build_file_proto_mode = "disable",
+        importpath = "github.com/google/cel-go",
+        sum = "h1:Li+angxmgvzlwDsPuFc1/nbqnq3gc4K/X7NrWjOADFI=",
+        version = "v0.6.0",
+    )
+
     go_repository(
         name = "com_github_google_go_cmp",
         build_file_generation = "on",
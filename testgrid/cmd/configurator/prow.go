@@ -29,6 +29,7 @@ import (
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	prowGCS "k8s.io/test-infra/prow/pod-utils/gcs"
@@ -64,6 +65,9 @@ func (pac *prowAwareConfigurator) tabDescriptionForProwJob(j prowConfig.JobBase)
 		url := pac.prowJobURLPrefix + strings.TrimPrefix(j.SourcePath, pac.prowJobConfigPath)
 		fields = append(fields, fmt.Sprintf("prowjob_config_url: %v", url))
 	}
+	if m := j.Annotations[kube.MatrixAnnotation]; m != "" {
+		fields = append(fields, fmt.Sprintf("prowjob_matrix: %v %v", m, j.Annotations[kube.MatrixValuesAnnotation]))
+	}
 	if d := j.Annotations[descriptionAnnotation]; d != "" {
 		fields = append(fields, fmt.Sprintf("prowjob_description: %v", d))
 		if !pac.updateDescription {
@@ -229,14 +233,30 @@ func (pac *prowAwareConfigurator) applySingleProwjobAnnotations(c *configpb.Conf
 	return nil
 }
 
-// sortPeriodics sorts all periodics by name (ascending).
+// jobLess orders jobs by name, except that jobs expanded from a matrix are
+// grouped at the position of the matrix name so that their tabs are adjacent.
+func jobLess(a, b prowConfig.JobBase) bool {
+	groupA, groupB := a.Name, b.Name
+	if m, ok := a.Annotations[kube.MatrixAnnotation]; ok {
+		groupA = m
+	}
+	if m, ok := b.Annotations[kube.MatrixAnnotation]; ok {
+		groupB = m
+	}
+	if groupA != groupB {
+		return groupA < groupB
+	}
+	return a.Name < b.Name
+}
+
+// sortPeriodics sorts all periodics by name (ascending), grouping matrix jobs.
 func sortPeriodics(per []prowConfig.Periodic) {
 	sort.Slice(per, func(a, b int) bool {
-		return per[a].Name < per[b].Name
+		return jobLess(per[a].JobBase, per[b].JobBase)
 	})
 }
 
-// sortPostsubmits sorts all postsubmits by name and returns a sorted list of org/repos (ascending).
+// sortPostsubmits sorts all postsubmits by name, grouping matrix jobs, and returns a sorted list of org/repos (ascending).
 func sortPostsubmits(post map[string][]prowConfig.Postsubmit) []string {
 	postRepos := make([]string, 0, len(post))
 
@@ -248,14 +268,14 @@ func sortPostsubmits(post map[string][]prowConfig.Postsubmit) []string {
 
 	for _, orgrepo := range postRepos {
 		sort.Slice(post[orgrepo], func(a, b int) bool {
-			return post[orgrepo][a].Name < post[orgrepo][b].Name
+			return jobLess(post[orgrepo][a].JobBase, post[orgrepo][b].JobBase)
 		})
 	}
 
 	return postRepos
 }
 
-// sortPresubmits sorts all presubmits by name and returns a sorted list of org/repos (ascending).
+// sortPresubmits sorts all presubmits by name, grouping matrix jobs, and returns a sorted list of org/repos (ascending).
 func sortPresubmits(pre map[string][]prowConfig.Presubmit) []string {
 	preRepos := make([]string, 0, len(pre))
 
@@ -267,7 +287,7 @@ func sortPresubmits(pre map[string][]prowConfig.Presubmit) []string {
 
 	for _, orgrepo := range preRepos {
 		sort.Slice(pre[orgrepo], func(a, b int) bool {
-			return pre[orgrepo][a].Name < pre[orgrepo][b].Name
+			return jobLess(pre[orgrepo][a].JobBase, pre[orgrepo][b].JobBase)
 		})
 	}
 
@@ -26,6 +26,7 @@ import (
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/pjutil"
 )
 
@@ -919,6 +920,21 @@ func TestSortPeriodicJobOrder(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "matrix jobs are grouped at the position of the matrix",
+			periodics: []prowConfig.Periodic{
+				{JobBase: prowConfig.JobBase{Name: "ci-e2e-b"}},
+				{JobBase: prowConfig.JobBase{Name: "gce-ci-e2e", Annotations: map[string]string{kube.MatrixAnnotation: "ci-e2e"}}},
+				{JobBase: prowConfig.JobBase{Name: "ci-e2e-a"}},
+				{JobBase: prowConfig.JobBase{Name: "aws-ci-e2e", Annotations: map[string]string{kube.MatrixAnnotation: "ci-e2e"}}},
+			},
+			expectedPeriodics: []prowConfig.Periodic{
+				{JobBase: prowConfig.JobBase{Name: "aws-ci-e2e", Annotations: map[string]string{kube.MatrixAnnotation: "ci-e2e"}}},
+				{JobBase: prowConfig.JobBase{Name: "gce-ci-e2e", Annotations: map[string]string{kube.MatrixAnnotation: "ci-e2e"}}},
+				{JobBase: prowConfig.JobBase{Name: "ci-e2e-a"}},
+				{JobBase: prowConfig.JobBase{Name: "ci-e2e-b"}},
+			},
+		},
 	}
 
 	for _, test := range tests {